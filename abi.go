@@ -0,0 +1,83 @@
+package resurgo
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/arch/x86/x86asm"
+)
+
+const (
+	// ABISysV is the System V AMD64 calling convention of Linux, the BSDs
+	// and macOS: RBX, RBP and R12-R15 are callee-saved.
+	ABISysV ABI = "sysv"
+	// ABIWin64 is the Microsoft x64 calling convention, also followed by
+	// MinGW and Wine: RDI and RSI are callee-saved as well.
+	ABIWin64 ABI = "win64"
+)
+
+// ABI selects the calling convention of x86-64 code.
+type ABI string
+
+// calleeSavedAMD64 maps each ABI to its callee-saved general-purpose
+// registers.
+var calleeSavedAMD64 = map[ABI][]x86asm.Reg{
+	ABISysV:  {x86asm.RBX, x86asm.RBP, x86asm.R12, x86asm.R13, x86asm.R14, x86asm.R15},
+	ABIWin64: {x86asm.RBX, x86asm.RBP, x86asm.RDI, x86asm.RSI, x86asm.R12, x86asm.R13, x86asm.R14, x86asm.R15},
+}
+
+// WithABI selects the calling convention of x86-64 code, whose callee-saved
+// registers are the ones push-only prologues and the pushes following a
+// frame pointer setup may save. By default PE files are analyzed under
+// ABIWin64 and any other input under ABISysV. Requesting another ABI is an
+// error.
+func WithABI(abi ABI) Option {
+	return func(o *options) {
+		o.abi = abi
+	}
+}
+
+// WithCalleeSavedRegs replaces the callee-saved registers of the selected
+// ABI with regs, the 64-bit general-purpose registers named as in Intel
+// syntax, e.g. "rbx" or "r12", for code following a custom calling
+// convention. Naming any other register is an error.
+func WithCalleeSavedRegs(regs ...string) Option {
+	return func(o *options) {
+		o.calleeSavedRegs = regs
+	}
+}
+
+// calleeSavedRegsAMD64 returns the x86-64 callee-saved registers selected by
+// WithABI and WithCalleeSavedRegs.
+func (o *options) calleeSavedRegsAMD64() ([]x86asm.Reg, error) {
+	if o.calleeSavedRegs != nil {
+		regs := make([]x86asm.Reg, 0, len(o.calleeSavedRegs))
+		for _, name := range o.calleeSavedRegs {
+			reg, ok := gpr64(name)
+			if !ok {
+				return nil, fmt.Errorf("unsupported callee-saved register: %s", name)
+			}
+			regs = append(regs, reg)
+		}
+		return regs, nil
+	}
+	abi := o.abi
+	if abi == "" {
+		abi = ABISysV
+	}
+	regs, ok := calleeSavedAMD64[abi]
+	if !ok {
+		return nil, fmt.Errorf("unsupported ABI: %s", abi)
+	}
+	return regs, nil
+}
+
+// gpr64 returns the 64-bit general-purpose register named name.
+func gpr64(name string) (x86asm.Reg, bool) {
+	for reg := x86asm.RAX; reg <= x86asm.R15; reg++ {
+		if strings.EqualFold(reg.String(), name) {
+			return reg, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,137 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// VirtualToOffset returns the offset in the file f of the byte loaded at the
+// virtual address addr, e.g. of a detected prologue, as uprobes and binary
+// patching expect. It is computed from the PT_LOAD program headers. It is an
+// error if no segment loads addr from the file, as for the zero-filled tail
+// of a data segment (.bss) and for relocatable objects, which have no
+// segments.
+func VirtualToOffset(f *elf.File, addr uint64) (uint64, error) {
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_LOAD && addr >= p.Vaddr && addr-p.Vaddr < p.Filesz {
+			return p.Off + (addr - p.Vaddr), nil
+		}
+	}
+	return 0, fmt.Errorf("virtual address %#x is not loaded from the file", addr)
+}
+
+// OffsetToVirtual returns the virtual address at which the byte at offset
+// off of the file f is loaded, the inverse of VirtualToOffset. It is an
+// error if no PT_LOAD segment loads off.
+func OffsetToVirtual(f *elf.File, off uint64) (uint64, error) {
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_LOAD && off >= p.Off && off-p.Off < p.Filesz {
+			return p.Vaddr + (off - p.Off), nil
+		}
+	}
+	return 0, fmt.Errorf("file offset %#x is not loaded", off)
+}
+
+// segmentPermissions returns the permissions of the PT_LOAD segment of f
+// loading addr, as "r", "w" and "x" in that order with "-" for those
+// missing, or "" if no segment loads addr, as in relocatable objects.
+func segmentPermissions(f *elf.File, addr uint64) string {
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_LOAD || addr < p.Vaddr || addr-p.Vaddr >= p.Memsz {
+			continue
+		}
+		return progPermissions(p)
+	}
+	return ""
+}
+
+// progPermissions returns the permissions of the segment p, as "r", "w" and
+// "x" in that order with "-" for those missing.
+func progPermissions(p *elf.Prog) string {
+	perms := []byte("---")
+	for i, flag := range []elf.ProgFlag{elf.PF_R, elf.PF_W, elf.PF_X} {
+		if p.Flags&flag != 0 {
+			perms[i] = "rwx"[i]
+		}
+	}
+	return string(perms)
+}
+
+// WithLoadBias sets the load bias of the ELF file being analyzed, the
+// difference between the runtime address of its code and the virtual
+// address it is linked at, e.g. as returned by LoadBias. The addresses
+// reported by DetectProloguesFromELF and DetectFunctionsFromELF for PIE
+// executables and shared libraries (ET_DYN) are then runtime addresses.
+// Other ELF files are loaded at their link addresses and ignore it, as do
+// the other formats.
+func WithLoadBias(bias uint64) Option {
+	return func(o *options) {
+		o.loadBias = bias
+	}
+}
+
+// LoadBias returns the load bias of the ELF file f from one of its file
+// mappings in a running process, as listed by /proc/pid/maps: start is the
+// address the mapping begins at, off its offset in the file and perms its
+// permissions, e.g. "r-xp". Segments sharing a page with their neighbour
+// are mapped from the same page offset, so the mapping is matched to the
+// PT_LOAD segment starting in the page at off with the same permissions.
+// It is an error if there is none.
+func LoadBias(f *elf.File, start, off uint64, perms string) (uint64, error) {
+	pageSize := uint64(os.Getpagesize())
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_LOAD || off != p.Off&^(pageSize-1) {
+			continue
+		}
+		if !strings.HasPrefix(perms, progPermissions(p)) {
+			continue
+		}
+		// Mappings begin at page boundaries, which lie below the segment
+		// address by the same amount as below its offset.
+		return start - (p.Vaddr - (p.Off - off)), nil
+	}
+	return 0, fmt.Errorf("file offset %#x is not loaded with permissions %q", off, perms)
+}
+
+// rebasePrologues adds the load bias to the addresses of prologues found in
+// f.
+func (o *options) rebasePrologues(prologues []Prologue, f *elf.File) {
+	if o.loadBias == 0 || f.Type != elf.ET_DYN {
+		return
+	}
+	for i := range prologues {
+		prologues[i].Address += o.loadBias
+	}
+}
+
+// rebaseCandidates adds the load bias to the addresses of candidates found
+// in f, including those of their call and jump sites.
+func (o *options) rebaseCandidates(candidates []FunctionCandidate, f *elf.File) {
+	if o.loadBias == 0 || f.Type != elf.ET_DYN {
+		return
+	}
+	for i := range candidates {
+		c := &candidates[i]
+		c.Address += o.loadBias
+		if c.End != 0 {
+			c.End += o.loadBias
+		}
+		c.CalledFrom = o.rebaseSites(c.CalledFrom)
+		c.JumpedFrom = o.rebaseSites(c.JumpedFrom)
+	}
+}
+
+// rebaseSites returns sites with the load bias added, in a new slice since
+// detectors may share them between candidates.
+func (o *options) rebaseSites(sites []uint64) []uint64 {
+	if sites == nil {
+		return nil
+	}
+	rebased := make([]uint64, len(sites))
+	for i, site := range sites {
+		rebased[i] = site + o.loadBias
+	}
+	return rebased
+}
@@ -0,0 +1,206 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestVirtualToOffset(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	path := filepath.Join(t.TempDir(), "demo-app-c")
+	if out, err := exec.Command("gcc", "-O0", "-o", path, "testdata/demo-app.c").CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse ELF: %v", err)
+	}
+
+	prologues, err := resurgo.DetectProloguesFromELF(f)
+	if err != nil {
+		t.Fatalf("DetectProloguesFromELF: %v", err)
+	}
+	if len(prologues) == 0 {
+		t.Fatal("no prologues detected")
+	}
+	for _, p := range prologues {
+		off, err := resurgo.VirtualToOffset(f, p.Address)
+		if err != nil {
+			t.Fatalf("VirtualToOffset(0x%x): %v", p.Address, err)
+		}
+		sec := f.Section(p.Section)
+		code, err := sec.Data()
+		if err != nil {
+			t.Fatalf("read %s: %v", p.Section, err)
+		}
+		// The file holds the prologue bytes at the offset.
+		want := code[p.Address-sec.Addr:][:p.Size]
+		if got := data[off:][:p.Size]; !bytes.Equal(got, want) {
+			t.Errorf("0x%x at offset 0x%x: got bytes %x, want %x", p.Address, off, got, want)
+		}
+		addr, err := resurgo.OffsetToVirtual(f, off)
+		if err != nil || addr != p.Address {
+			t.Errorf("OffsetToVirtual(0x%x) = 0x%x, %v, want 0x%x", off, addr, err, p.Address)
+		}
+	}
+
+	if bss := f.Section(".bss"); bss != nil {
+		if _, err := resurgo.VirtualToOffset(f, bss.Addr+bss.Size-1); err == nil {
+			t.Error("expected an error for an address in .bss")
+		}
+	}
+	if _, err := resurgo.OffsetToVirtual(f, uint64(len(data))+1); err == nil {
+		t.Error("expected an error for an offset past the end of the file")
+	}
+}
+
+func TestWithLoadBias(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	path := filepath.Join(t.TempDir(), "demo-app-c")
+	if out, err := exec.Command("gcc", "-O0", "-pie", "-fPIE", "-o", path, "testdata/demo-app.c").CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	// The mapping of the code segment, as listed by /proc/pid/maps.
+	const start = 0x555555554000
+	var text *elf.Prog
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_LOAD && p.Flags&elf.PF_X != 0 {
+			text = p
+			break
+		}
+	}
+	if text == nil {
+		t.Fatal("no executable segment")
+	}
+	page := text.Off &^ uint64(os.Getpagesize()-1)
+	bias, err := resurgo.LoadBias(f, start+text.Vaddr-(text.Off-page), page, "r-xp")
+	if err != nil {
+		t.Fatalf("LoadBias: %v", err)
+	}
+	if bias != start {
+		t.Fatalf("LoadBias = 0x%x, want 0x%x", bias, uint64(start))
+	}
+
+	linked, err := resurgo.DetectProloguesFromELF(f)
+	if err != nil {
+		t.Fatalf("DetectProloguesFromELF: %v", err)
+	}
+	runtime, err := resurgo.DetectProloguesFromELF(f, resurgo.WithLoadBias(bias))
+	if err != nil {
+		t.Fatalf("DetectProloguesFromELF(WithLoadBias): %v", err)
+	}
+	if len(linked) == 0 || len(runtime) != len(linked) {
+		t.Fatalf("got %d prologues with the bias, %d without", len(runtime), len(linked))
+	}
+	for i := range linked {
+		if runtime[i].Address != linked[i].Address+bias {
+			t.Errorf("prologue %d at 0x%x, want 0x%x", i, runtime[i].Address, linked[i].Address+bias)
+		}
+	}
+
+	candidates, err := resurgo.DetectFunctionsFromELF(f)
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromELF: %v", err)
+	}
+	rebased, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithLoadBias(bias))
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromELF(WithLoadBias): %v", err)
+	}
+	if len(rebased) != len(candidates) {
+		t.Fatalf("got %d candidates with the bias, %d without", len(rebased), len(candidates))
+	}
+	for i, c := range candidates {
+		if rebased[i].Address != c.Address+bias {
+			t.Errorf("candidate %d at 0x%x, want 0x%x", i, rebased[i].Address, c.Address+bias)
+		}
+		for j, site := range c.CalledFrom {
+			if rebased[i].CalledFrom[j] != site+bias {
+				t.Errorf("call site 0x%x of 0x%x not rebased", site, c.Address)
+			}
+		}
+	}
+}
+
+func TestLoadBias_SharedPage(t *testing.T) {
+	// A shared library whose data segment starts in the last page of its
+	// code segment: both are mapped from file offset 0.
+	progs := []elf.Prog64{{
+		Type:   uint32(elf.PT_LOAD),
+		Flags:  uint32(elf.PF_R | elf.PF_X),
+		Filesz: 0xd00,
+		Memsz:  0xd00,
+		Align:  0x10000,
+	}, {
+		Type:   uint32(elf.PT_LOAD),
+		Flags:  uint32(elf.PF_R | elf.PF_W),
+		Off:    0xdd8,
+		Vaddr:  0x10dd8,
+		Filesz: 0x200,
+		Memsz:  0x200,
+		Align:  0x10000,
+	}}
+	var img bytes.Buffer
+	hdr := elf.Header64{
+		Type:      uint16(elf.ET_DYN),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     64,
+		Ehsize:    64,
+		Phentsize: 56,
+		Phnum:     uint16(len(progs)),
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	binary.Write(&img, binary.LittleEndian, hdr)
+	binary.Write(&img, binary.LittleEndian, progs)
+	img.Write(make([]byte, 0x1000-img.Len()))
+	f, err := elf.NewFile(bytes.NewReader(img.Bytes()))
+	if err != nil {
+		t.Fatalf("failed to parse ELF: %v", err)
+	}
+
+	const bias = 0x7f0000000000
+	for _, m := range []struct {
+		start uint64
+		perms string
+	}{
+		{bias, "r-xp"},
+		{bias + 0x10000, "rw-p"},
+	} {
+		got, err := resurgo.LoadBias(f, m.start, 0, m.perms)
+		if err != nil {
+			t.Fatalf("LoadBias(%#x, %s): %v", m.start, m.perms, err)
+		}
+		if got != bias {
+			t.Errorf("LoadBias(%#x, %s) = %#x, want %#x", m.start, m.perms, got, uint64(bias))
+		}
+	}
+	if _, err := resurgo.LoadBias(f, bias, 0, "r--p"); err == nil {
+		t.Error("expected an error for a mapping matching no segment permissions")
+	}
+}
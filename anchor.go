@@ -0,0 +1,69 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"fmt"
+	"slices"
+)
+
+// DetectionAnchor is assigned to function candidates read from the ELF
+// header and dynamic section: the entry point and the DT_INIT and DT_FINI
+// functions. Exported functions keep DetectionExport.
+const DetectionAnchor DetectionType = "anchor"
+
+// WithAnchors sets whether DetectFunctionsFromELF seeds the results with the
+// anchors emitted by AnchorDetector and restores those a filter removes
+// (default true). Anchors such as _start are function entries by
+// definition, whatever their shape. Candidates at an exported function are
+// named after it and raised to ConfidenceHigh.
+func WithAnchors(enabled bool) Option {
+	return func(o *options) {
+		o.anchors = enabled
+	}
+}
+
+// AnchorDetector is a CandidateDetector that emits the functions the ELF
+// file names itself: the entry point from the ELF header (e_entry), and the
+// functions of the DT_INIT and DT_FINI dynamic tags. Each candidate carries
+// DetectionAnchor and ConfidenceHigh. Addresses outside executable sections,
+// such as the zero entry point of a shared library, are skipped, and
+// relocatable objects, which have no entry point, emit none. The exported
+// functions emitted by ExportDetector are anchors too, and keep their
+// DetectionExport. The entry thunks of interpreters are not emitted:
+// nothing in the ELF file marks them, and they are left to the detectors.
+//
+// DetectFunctionsFromELF runs it after the detectors unless WithAnchors
+// disables it, so it need not be listed in WithDetectors.
+func AnchorDetector(f *elf.File) ([]FunctionCandidate, error) {
+	if f.Type == elf.ET_REL {
+		return nil, nil
+	}
+	addrs := []uint64{f.Entry}
+	for _, tag := range []elf.DynTag{elf.DT_INIT, elf.DT_FINI} {
+		vals, err := f.DynValue(tag)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", tag, err)
+		}
+		addrs = append(addrs, vals...)
+	}
+	slices.Sort(addrs)
+	addrs = slices.Compact(addrs)
+
+	exec := execRanges(f)
+	var candidates []FunctionCandidate
+	for _, addr := range addrs {
+		if !inRanges(addr, exec) {
+			continue
+		}
+		candidates = append(candidates, FunctionCandidate{
+			Address:       addr,
+			DetectionType: DetectionAnchor,
+			Confidence:    ConfidenceHigh,
+		})
+	}
+	exports, err := ExportDetector(f)
+	if err != nil {
+		return nil, err
+	}
+	return mergeCandidates(candidates, exports), nil
+}
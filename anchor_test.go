@@ -0,0 +1,82 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+// TestAnchorDetector verifies that the entry point and the DT_INIT and
+// DT_FINI functions survive a filter that removes every candidate.
+func TestAnchorDetector(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	outPath := filepath.Join(t.TempDir(), "demo-app-c")
+	cmd := exec.Command("gcc", "-O2", "-o", outPath, "testdata/demo-app.c")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	want := make(map[uint64]string)
+	for _, s := range syms {
+		switch s.Name {
+		case "_start", "_init", "_fini":
+			want[s.Value] = s.Name
+		}
+	}
+	if len(want) != 3 {
+		t.Fatalf("expected _start, _init and _fini symbols, got %v", want)
+	}
+
+	dropAll := func([]resurgo.FunctionCandidate, *elf.File) ([]resurgo.FunctionCandidate, error) {
+		return nil, nil
+	}
+
+	tests := []struct {
+		name string
+		opts []resurgo.Option
+		want int
+	}{{
+		name: "default",
+		opts: []resurgo.Option{resurgo.WithFilters(dropAll)},
+		want: 3,
+	}, {
+		name: "disabled",
+		opts: []resurgo.Option{resurgo.WithFilters(dropAll), resurgo.WithAnchors(false)},
+		want: 0,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, err := resurgo.DetectFunctionsFromELF(f, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(candidates) != tt.want {
+				t.Fatalf("expected %d candidates, got %+v", tt.want, candidates)
+			}
+			for _, c := range candidates {
+				if _, ok := want[c.Address]; !ok {
+					t.Errorf("unexpected candidate 0x%x", c.Address)
+				}
+				if c.Confidence != resurgo.ConfidenceHigh {
+					t.Errorf("0x%x: expected ConfidenceHigh, got %s", c.Address, c.Confidence)
+				}
+			}
+		})
+	}
+}
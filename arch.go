@@ -0,0 +1,99 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ArchDetector implements prologue matching and call-site analysis for an
+// architecture resurgo does not ship, registered with RegisterArch.
+type ArchDetector interface {
+	// DetectPrologues returns the function prologues in code, the machine
+	// code mapped at baseAddr.
+	DetectPrologues(code []byte, baseAddr uint64) ([]Prologue, error)
+	// DetectCallSites returns the call sites in code, the machine code
+	// mapped at baseAddr.
+	DetectCallSites(code []byte, baseAddr uint64) ([]CallSiteEdge, error)
+}
+
+// ELFArchDetector is an ArchDetector that also claims ELF files, so that
+// DetectFunctionsFromELF and the other ELF entry points dispatch to it.
+type ELFArchDetector interface {
+	ArchDetector
+	// MatchELF reports whether the code of f is of the detector's
+	// architecture, e.g. from f.Machine. It is only consulted for files of
+	// machines resurgo does not support.
+	MatchELF(f *elf.File) bool
+}
+
+// builtinArchs lists the architectures resurgo ships detectors for.
+var builtinArchs = []Arch{
+	ArchAMD64, ArchARM64, ArchX86, ArchRISCV64, ArchARM, ArchThumb,
+	ArchPPC64LE, ArchPPC64, ArchXtensa,
+}
+
+var (
+	archRegistryMu sync.RWMutex
+	// archRegistry holds the registered architectures in registration
+	// order, which is the order ELF files are matched in.
+	archRegistry []registeredArch
+)
+
+type registeredArch struct {
+	arch     Arch
+	detector ArchDetector
+}
+
+// RegisterArch makes DetectPrologues, DetectCallSites and the disassembly
+// pipeline dispatch code of arch to detector. Code is passed as is, without
+// WithByteOrder applied. Detectors implementing ELFArchDetector are also
+// used for the ELF files they match. Prologues are ordered like those of
+// built-in architectures, with types added by RegisterPrologueType ranked
+// in registration order, and WithSyntax leaves their Instructions
+// unchanged. It returns an error if arch is empty, built in or already
+// registered.
+func RegisterArch(arch Arch, detector ArchDetector) error {
+	if arch == "" {
+		return fmt.Errorf("empty architecture")
+	}
+	if detector == nil {
+		return fmt.Errorf("nil detector for architecture %s", arch)
+	}
+	if slices.Contains(builtinArchs, arch) {
+		return fmt.Errorf("architecture %s is built in", arch)
+	}
+	archRegistryMu.Lock()
+	defer archRegistryMu.Unlock()
+	if slices.ContainsFunc(archRegistry, func(r registeredArch) bool { return r.arch == arch }) {
+		return fmt.Errorf("architecture %s already registered", arch)
+	}
+	archRegistry = append(archRegistry, registeredArch{arch: arch, detector: detector})
+	return nil
+}
+
+// lookupArch returns the detector registered for arch.
+func lookupArch(arch Arch) (ArchDetector, bool) {
+	archRegistryMu.RLock()
+	defer archRegistryMu.RUnlock()
+	for _, r := range archRegistry {
+		if r.arch == arch {
+			return r.detector, true
+		}
+	}
+	return nil, false
+}
+
+// matchELFArch returns the first registered architecture whose detector
+// claims f.
+func matchELFArch(f *elf.File) (Arch, bool) {
+	archRegistryMu.RLock()
+	defer archRegistryMu.RUnlock()
+	for _, r := range archRegistry {
+		if d, ok := r.detector.(ELFArchDetector); ok && d.MatchELF(f) {
+			return r.arch, true
+		}
+	}
+	return "", false
+}
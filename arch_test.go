@@ -0,0 +1,163 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+const (
+	testArch         resurgo.Arch         = "test-arch"
+	testArchPrologue resurgo.PrologueType = "test-arch-mark"
+)
+
+// testArchDetector is a toy architecture: 0xaa marks a function entry and
+// 0xe8 n calls baseAddr+n. It claims ELF files of EM_MMIX.
+type testArchDetector struct{}
+
+func (testArchDetector) DetectPrologues(code []byte, baseAddr uint64) ([]resurgo.Prologue, error) {
+	var prologues []resurgo.Prologue
+	for i, b := range code {
+		if b == 0xaa {
+			prologues = append(prologues, resurgo.Prologue{
+				Address:      baseAddr + uint64(i),
+				Type:         testArchPrologue,
+				Instructions: "mark",
+				Size:         1,
+			})
+		}
+	}
+	return prologues, nil
+}
+
+func (testArchDetector) DetectCallSites(code []byte, baseAddr uint64) ([]resurgo.CallSiteEdge, error) {
+	var edges []resurgo.CallSiteEdge
+	// Report edges last to first, to check they are sorted.
+	for i := len(code) - 2; i >= 0; i-- {
+		if code[i] == 0xe8 {
+			edges = append(edges, resurgo.CallSiteEdge{
+				SourceAddr:  baseAddr + uint64(i),
+				TargetAddr:  baseAddr + uint64(code[i+1]),
+				Type:        resurgo.CallSiteCall,
+				AddressMode: resurgo.AddressingModeAbsolute,
+				Confidence:  resurgo.ConfidenceHigh,
+			})
+		}
+	}
+	return edges, nil
+}
+
+func (testArchDetector) MatchELF(f *elf.File) bool {
+	return f.Machine == elf.EM_MMIX
+}
+
+// registerTestArch registers testArch once per test binary.
+var registerTestArch = sync.OnceValue(func() error {
+	return resurgo.RegisterArch(testArch, testArchDetector{})
+})
+
+func TestRegisterArch(t *testing.T) {
+	if err := registerTestArch(); err != nil {
+		t.Fatalf("RegisterArch: %v", err)
+	}
+	code := []byte{0xaa, 0xe8, 0x05, 0x00, 0xe8, 0x00, 0xaa}
+
+	prologues, err := resurgo.DetectPrologues(code, 0x1000, testArch, resurgo.WithSyntax(resurgo.SyntaxGNU))
+	if err != nil {
+		t.Fatalf("DetectPrologues: %v", err)
+	}
+	wantPrologues := []resurgo.Prologue{
+		{Address: 0x1000, Type: testArchPrologue, Instructions: "mark", Size: 1},
+		{Address: 0x1006, Type: testArchPrologue, Instructions: "mark", Size: 1},
+	}
+	if !reflect.DeepEqual(prologues, wantPrologues) {
+		t.Errorf("got prologues %+v, want %+v", prologues, wantPrologues)
+	}
+
+	edges, err := resurgo.DetectCallSites(code, 0x1000, testArch)
+	if err != nil {
+		t.Fatalf("DetectCallSites: %v", err)
+	}
+	abs := resurgo.AddressingModeAbsolute
+	wantEdges := []resurgo.CallSiteEdge{
+		{SourceAddr: 0x1001, TargetAddr: 0x1005, Type: resurgo.CallSiteCall, AddressMode: abs, Confidence: resurgo.ConfidenceHigh},
+		{SourceAddr: 0x1004, TargetAddr: 0x1000, Type: resurgo.CallSiteCall, AddressMode: abs, Confidence: resurgo.ConfidenceHigh},
+	}
+	if !reflect.DeepEqual(edges, wantEdges) {
+		t.Errorf("got edges %+v, want %+v", edges, wantEdges)
+	}
+
+	tests := []struct {
+		name string
+		arch resurgo.Arch
+	}{
+		{name: "empty", arch: ""},
+		{name: "builtin", arch: resurgo.ArchAMD64},
+		{name: "duplicate", arch: testArch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := resurgo.RegisterArch(tt.arch, testArchDetector{}); err == nil {
+				t.Errorf("expected error registering %q, got nil", tt.arch)
+			}
+		})
+	}
+}
+
+func TestRegisterArch_ELF(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+	if err := registerTestArch(); err != nil {
+		t.Fatalf("RegisterArch: %v", err)
+	}
+
+	outPath := filepath.Join(t.TempDir(), "demo-app")
+	cmd := exec.Command("gcc", "-O0", "-o", outPath, "testdata/demo-app.c")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	image, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := elf.NewFile(bytes.NewReader(image))
+	if err != nil {
+		t.Fatalf("failed to parse ELF: %v", err)
+	}
+	text := orig.Section(".text")
+	if text == nil || orig.Data != elf.ELFDATA2LSB {
+		t.Skip("no little-endian .text section, skipping")
+	}
+
+	// Retarget the file to EM_MMIX, which resurgo does not support, and
+	// mark the first byte of .text for testArchDetector.
+	binary.LittleEndian.PutUint16(image[18:], uint16(elf.EM_MMIX))
+	image[text.Offset] = 0xaa
+	f, err := elf.NewFile(bytes.NewReader(image))
+	if err != nil {
+		t.Fatalf("failed to parse patched ELF: %v", err)
+	}
+
+	candidates, err := resurgo.NewDisasmDetector()(f)
+	if err != nil {
+		t.Fatalf("DisasmDetector: %v", err)
+	}
+	found := false
+	for _, c := range candidates {
+		if c.Address == text.Addr && c.PrologueType == testArchPrologue {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a %s candidate at 0x%x, got %+v", testArchPrologue, text.Addr, candidates)
+	}
+}
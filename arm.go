@@ -0,0 +1,327 @@
+package resurgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"golang.org/x/arch/arm/armasm"
+)
+
+// armInsnLen is the length of an A32 instruction.
+const armInsnLen = 4
+
+// A32 encodings matched on the raw instruction word.
+const (
+	// armCondAL is the condition field (bits 31:28) of unconditional
+	// instructions.
+	armCondAL = 0xe
+
+	// udf #imm: permanently undefined, emitted as a trap. golang.org/x/arch
+	// does not decode it.
+	armUDFMask = uint32(0xfff000f0)
+	armUDF     = uint32(0xe7f000f0)
+
+	// Register list bits of push {...}.
+	armRegListFP = 1 << 11
+	armRegListLR = 1 << 14
+	armRegListPC = 1 << 15
+)
+
+// armRegNames maps r0-r15 to the names used by GNU tools.
+var armRegNames = [16]string{
+	"r0", "r1", "r2", "r3", "r4", "r5", "r6", "r7",
+	"r8", "r9", "r10", "fp", "ip", "sp", "lr", "pc",
+}
+
+// isUnconditionalARM reports whether inst executes unconditionally (AL).
+func isUnconditionalARM(inst armasm.Inst) bool {
+	return inst.Enc>>28 == armCondAL
+}
+
+// armPushList returns the register list of an unconditional push, which
+// also covers the single-register form str reg, [sp, #-4]!.
+func armPushList(inst armasm.Inst) (armasm.RegList, bool) {
+	if inst.Op != armasm.PUSH {
+		return 0, false
+	}
+	list, ok := inst.Args[0].(armasm.RegList)
+	return list, ok
+}
+
+// armRegListNames returns the names of the registers in list, lowest first.
+func armRegListNames(list armasm.RegList) []string {
+	var names []string
+	for i, name := range armRegNames {
+		if list&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// armSPAlloc returns the immediate of sub sp, sp, #imm.
+func armSPAlloc(inst armasm.Inst) (uint64, bool) {
+	if inst.Op != armasm.SUB || inst.Args[0] != armasm.SP || inst.Args[1] != armasm.SP {
+		return 0, false
+	}
+	imm, ok := inst.Args[2].(armasm.Imm)
+	return uint64(imm), ok && imm > 0
+}
+
+// armSTRLRPreIndex returns N for str lr, [sp, #-N]!, the Go entry sequence
+// that saves the link register and allocates the frame at once. N = 4 is
+// decoded as push {lr}.
+func armSTRLRPreIndex(inst armasm.Inst) (uint64, bool) {
+	if list, ok := armPushList(inst); ok && list == armRegListLR {
+		return 4, true
+	}
+	if inst.Op != armasm.STR || inst.Args[0] != armasm.LR {
+		return 0, false
+	}
+	mem, ok := inst.Args[1].(armasm.Mem)
+	if !ok || mem.Base != armasm.SP || mem.Mode != armasm.AddrPreIndex || mem.Sign != 0 || mem.Offset >= 0 {
+		return 0, false
+	}
+	return uint64(-int64(mem.Offset)), true
+}
+
+// isFramePointerSetupARM reports whether inst is add fp, sp, #imm.
+func isFramePointerSetupARM(inst armasm.Inst) bool {
+	return inst.Op == armasm.ADD && inst.Args[0] == armasm.R11 && inst.Args[1] == armasm.SP
+}
+
+// isBenignARM reports whether inst is a nop or mov r0, r0, neither of which
+// touches the stack frame.
+func isBenignARM(inst armasm.Inst) bool {
+	return inst.Op == armasm.NOP ||
+		(inst.Op == armasm.MOV && inst.Args[0] == armasm.R0 && inst.Args[1] == armasm.R0)
+}
+
+// armEntrySeq describes the A32 function entry sequence that starts with a
+// push.
+type armEntrySeq struct {
+	insns     []string
+	savedRegs []string
+	frame     uint64
+	// end is the offset just past the last instruction of the sequence.
+	end int
+}
+
+// scanEntryARM collects the A32 entry sequence starting with the push at
+// code[offset]: the push, an optional add fp, sp, #imm setting the frame
+// pointer up and an optional sub sp, sp, #imm allocating locals. nop and
+// mov r0, r0 may be interleaved.
+func scanEntryARM(code []byte, offset int, list armasm.RegList) armEntrySeq {
+	names := armRegListNames(list)
+	seq := armEntrySeq{
+		insns:     []string{fmt.Sprintf("push {%s}", strings.Join(names, ", "))},
+		savedRegs: names,
+		frame:     uint64(4 * len(names)),
+		end:       offset + armInsnLen,
+	}
+	for n, off := 0, seq.end; n < maxSavedRegScan && off+armInsnLen <= len(code); n, off = n+1, off+armInsnLen {
+		inst, err := armasm.Decode(code[off:off+armInsnLen], armasm.ModeARM)
+		if err != nil || !isUnconditionalARM(inst) {
+			break
+		}
+		if isBenignARM(inst) {
+			continue
+		}
+		if isFramePointerSetupARM(inst) {
+			seq.insns = append(seq.insns, fmt.Sprintf("add fp, sp, #%d", inst.Args[2]))
+			seq.end = off + armInsnLen
+			continue
+		}
+		if size, ok := armSPAlloc(inst); ok {
+			seq.insns = append(seq.insns, fmt.Sprintf("sub sp, sp, #%d", size))
+			seq.frame += size
+			seq.end = off + armInsnLen
+		}
+		break
+	}
+	return seq
+}
+
+// detectProloguesARM matches A32 function prologues:
+//
+//	push {..., fp, lr}    push-fp-lr, optionally with add fp, sp, #N
+//	push {..., lr}        push-lr
+//	str  lr, [sp, #-N]!   str-lr-preindex (Go), at a function boundary
+//	sub  sp, sp, #N       sub-sp, at a function boundary
+//
+// Only unconditional instructions are considered. Thumb code is matched by
+// detectProloguesThumb.
+func (o *options) detectProloguesARM(code []byte, baseAddr uint64) ([]Prologue, error) {
+	var result []Prologue
+
+	// consumedUntil is the end offset of the last collapsed entry sequence.
+	// Patterns matching inside it are fragments of that sequence.
+	consumedUntil := 0
+	hist := newInsnHistory(o.lookbehind())
+
+	for offset := 0; offset+armInsnLen <= len(code); offset += armInsnLen {
+		if err := o.budget.step(offset); err != nil {
+			return nil, err
+		}
+		word := binary.LittleEndian.Uint32(code[offset:])
+		addr := baseAddr + uint64(offset)
+		inst, err := armasm.Decode(code[offset:offset+armInsnLen], armasm.ModeARM)
+		if err != nil {
+			hist.push(addr, classifyUndecodedARM(word))
+			continue
+		}
+		atBoundary := func(typ PrologueType) bool {
+			return o.atBoundary(hist, addr, typ)
+		}
+
+		if isUnconditionalARM(inst) && offset >= consumedUntil {
+			// Pattern 1: push {..., fp, lr}, the AAPCS frame record, or
+			// push {..., lr} saving the link register with callee-saved
+			// registers.
+			if list, ok := armPushList(inst); ok && list&armRegListLR != 0 && list != armRegListLR {
+				seq := scanEntryARM(code, offset, list)
+				typ := ProloguePushLR
+				if list&armRegListFP != 0 {
+					typ = ProloguePushFPLR
+				}
+				result = append(result, Prologue{
+					Address:      addr,
+					Type:         typ,
+					Instructions: strings.Join(seq.insns, "; "),
+					FrameSize:    seq.frame,
+					Size:         uint64(seq.end - offset),
+					SavedRegs:    seq.savedRegs,
+				})
+				consumedUntil = seq.end
+			}
+
+			// Pattern 2: str lr, [sp, #-N]! (Go-style prologue).
+			if frame, ok := armSTRLRPreIndex(inst); ok && atBoundary(PrologueSTRLRPreIndex) {
+				result = append(result, Prologue{
+					Address:      addr,
+					Type:         PrologueSTRLRPreIndex,
+					Instructions: fmt.Sprintf("str lr, [sp, #-%d]!", frame),
+					FrameSize:    frame,
+					Size:         armInsnLen,
+					SavedRegs:    []string{"lr"},
+				})
+			}
+
+			// Pattern 3: sub sp, sp, #N (stack allocation without saves).
+			if size, ok := armSPAlloc(inst); ok && atBoundary(PrologueSubSP) {
+				result = append(result, Prologue{
+					Address:      addr,
+					Type:         PrologueSubSP,
+					Instructions: fmt.Sprintf("sub sp, sp, #%d", size),
+					FrameSize:    size,
+					Size:         armInsnLen,
+				})
+			}
+		}
+
+		hist.push(addr, classifyARM(inst))
+	}
+
+	return result, nil
+}
+
+// classifyARM returns the boundary-context class of an A32 instruction.
+// Conditional instructions never end a function.
+func classifyARM(inst armasm.Inst) InsnClass {
+	if !isUnconditionalARM(inst) {
+		return InsnClassOther
+	}
+	switch inst.Op {
+	case armasm.BX:
+		if inst.Args[0] == armasm.LR {
+			return InsnClassReturn
+		}
+		return InsnClassJump
+	case armasm.POP:
+		if list, ok := inst.Args[0].(armasm.RegList); ok && list&armRegListPC != 0 {
+			return InsnClassReturn
+		}
+	case armasm.LDM:
+		if list, ok := inst.Args[1].(armasm.RegList); ok && list&armRegListPC != 0 {
+			return InsnClassReturn
+		}
+	case armasm.MOV:
+		if inst.Args[0] == armasm.PC && inst.Args[1] == armasm.LR {
+			return InsnClassReturn
+		}
+	case armasm.B:
+		return InsnClassJump
+	case armasm.BKPT:
+		return InsnClassTrap
+	}
+	if isBenignARM(inst) {
+		return InsnClassPadding
+	}
+	return InsnClassOther
+}
+
+// classifyUndecodedARM returns the boundary-context class of an A32 word
+// golang.org/x/arch cannot decode: udf is a trap, anything else unknown.
+func classifyUndecodedARM(word uint32) InsnClass {
+	if word&armUDFMask == armUDF {
+		return InsnClassTrap
+	}
+	return InsnClassNone
+}
+
+// detectCallSitesARM scans A32 code for bl, blx <label> and b. Branch
+// targets are relative to the address of the instruction plus 8, and wrap
+// around the 32-bit address space. blx <label> calls Thumb code at a
+// halfword-aligned target. Conditional b is mostly intra-function and gets
+// low confidence.
+func detectCallSitesARM(code []byte, baseAddr uint64, wrap bool, b *budget) ([]CallSiteEdge, error) {
+	var result []CallSiteEdge
+
+	for offset := 0; offset+armInsnLen <= len(code); offset += armInsnLen {
+		if err := b.step(offset); err != nil {
+			return nil, err
+		}
+		inst, err := armasm.Decode(code[offset:offset+armInsnLen], armasm.ModeARM)
+		if err != nil {
+			continue
+		}
+		rel, ok := inst.Args[0].(armasm.PCRel)
+		if !ok {
+			continue
+		}
+		addr := baseAddr + uint64(offset)
+		disp := int64(rel) + 8
+
+		edge := CallSiteEdge{
+			SourceAddr:  addr,
+			Type:        CallSiteCall,
+			AddressMode: AddressingModePCRelative,
+			Confidence:  ConfidenceHigh,
+		}
+		switch {
+		case inst.Op == armasm.BLX, inst.Op&^15 == armasm.BL_EQ:
+		case inst.Op&^15 == armasm.B_EQ:
+			edge.Type = CallSiteJump
+			edge.Confidence = ConfidenceMedium
+			if !isUnconditionalARM(inst) {
+				edge.Confidence = ConfidenceLow
+			}
+		default:
+			continue
+		}
+		target, ok := relTarget(addr, disp, wrap)
+		if target > math.MaxUint32 {
+			ok = wrap
+			target &= math.MaxUint32
+		}
+		if !ok {
+			continue
+		}
+		edge.TargetAddr = target
+		result = append(result, edge)
+	}
+
+	return result, nil
+}
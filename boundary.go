@@ -1,6 +1,8 @@
 package resurgo
 
 import (
+	"slices"
+
 	"golang.org/x/arch/arm64/arm64asm"
 	"golang.org/x/arch/x86/x86asm"
 )
@@ -11,8 +13,8 @@ const (
 	alignedEntryAlignment = 16
 
 	// DetectionAlignedEntry indicates the candidate was found by alignment-
-	// boundary analysis: a ret/jmp terminator followed by NOP padding ending
-	// at a 16-byte aligned address.
+	// boundary analysis: a ret/jmp/trap terminator followed by NOP padding
+	// ending at a 16-byte aligned address.
 	DetectionAlignedEntry DetectionType = "aligned-entry"
 
 	// x86INT3 is the single-byte INT3 opcode (0xCC). Compilers emit it as
@@ -20,32 +22,39 @@ const (
 	x86INT3 = byte(0xCC)
 )
 
-// detectAlignedEntriesAMD64 scans raw x86-64 machine code bytes for the
-// pattern emitted by compilers to separate adjacent functions. code is the
-// raw bytes of the executable section; baseAddr is the virtual address
-// corresponding to the first byte of code.
+// detectAlignedEntriesAMD64 scans raw x86 machine code bytes, decoded in mode
+// (64 for x86-64, 32 for i386), for the pattern emitted by compilers to
+// separate adjacent functions. code is the raw bytes of the executable
+// section; baseAddr is the virtual address corresponding to the first byte
+// of code.
 //
 //	<terminator>          ; ret (0xC3) or jmp
 //	<nop padding>...      ; 1 or more NOP-like fill bytes
 //	<aligned address>     ; 16-byte boundary - likely a new function entry
 //
+// When traps is set, a run of int3/ud2 is a terminator too, and the padding
+// after it is optional: traps only occur as fill or after noreturn calls.
+//
 // It returns the virtual addresses of all such aligned boundaries. These are
 // low-confidence candidates: the pattern is necessary but not sufficient to
 // confirm a function entry (alignment padding can also appear inside functions
 // at loop-head alignment points, though that is much less common at 16-byte
 // granularity after a ret).
-func detectAlignedEntriesAMD64(code []byte, baseAddr uint64) []uint64 {
+func detectAlignedEntriesAMD64(code []byte, baseAddr uint64, mode int, traps bool, b *budget) ([]uint64, error) {
 	var entries []uint64
 
 	i := 0
 	for i < len(code) {
+		if err := b.step(i); err != nil {
+			return nil, err
+		}
 		// Skip ENDBR64 / ENDBR32 transparently.
 		if isENDBR(code, i) {
 			i += 4
 			continue
 		}
 
-		inst, err := x86asm.Decode(code[i:], 64)
+		inst, err := x86asm.Decode(code[i:], mode)
 		if err != nil {
 			// undecoded byte, skip
 			i++
@@ -61,28 +70,36 @@ func detectAlignedEntriesAMD64(code []byte, baseAddr uint64) []uint64 {
 		// jump backward relative to the caller's address range.
 		isTerminator := inst.Op == x86asm.RET || inst.Op == x86asm.LRET
 		if inst.Op == x86asm.JMP {
-			if rel, ok := inst.Args[0].(x86asm.Rel); ok {
-				sourceVA := baseAddr + uint64(i)
-				targetVA := sourceVA + uint64(inst.Len) + uint64(int64(rel))
-				if targetVA < sourceVA {
-					isTerminator = true // backward jmp: likely an inter-function tail call
-				}
+			// The direction is taken from the signed displacement rather than
+			// by comparing addresses, which wrap in high-half kernel code.
+			if rel, ok := inst.Args[0].(x86asm.Rel); ok && int64(rel)+int64(inst.Len) < 0 {
+				isTerminator = true // backward jmp: likely an inter-function tail call
 			}
 		}
 
-		if !isTerminator {
+		isTrap := traps && isTrapAMD64(inst)
+
+		if !isTerminator && !isTrap {
 			i += inst.Len
 			// not a function boundary, keep scanning
 			continue
 		}
 
 		// Found a terminator. Advance past it and consume NOP / INT3 padding.
-		j := consumePaddingAMD64(code, i+inst.Len)
+		// A trap run is consumed whole so that it is handled only once.
+		// next is where scanning resumes.
+		next := i + inst.Len
+		j := consumePaddingAMD64(code, next, mode)
+		if isTrap {
+			j = consumeTrapsAMD64(code, j, mode)
+			next = j
+		}
 
 		// Reject if no padding was consumed: a bare RET immediately followed
-		// by code is intra-function (e.g. a base-case branch target).
-		if j == i+inst.Len {
-			i += inst.Len
+		// by code is intra-function (e.g. a base-case branch target). A trap
+		// never falls through, so the next instruction starts a new block.
+		if j == i+inst.Len && !isTrap {
+			i = next
 			continue
 		}
 
@@ -96,30 +113,34 @@ func detectAlignedEntriesAMD64(code []byte, baseAddr uint64) []uint64 {
 		addr := baseAddr + uint64(j)
 		// boundary not 16-byte aligned, not a function entry
 		if addr%alignedEntryAlignment != 0 {
-			i += inst.Len
+			i = next
 			continue
 		}
 
 		// Reject if the instruction at the aligned boundary is itself a
 		// terminator: this indicates an intra-function base-case return
 		// (e.g. factorial's jle→ret path) rather than a new function entry.
-		boundary, err := x86asm.Decode(code[j:], 64)
+		boundary, err := x86asm.Decode(code[j:], mode)
 		if err != nil {
 			// undecoded boundary instruction, skip
-			i += inst.Len
+			i = next
 			continue
 		}
 		if boundary.Op == x86asm.RET || boundary.Op == x86asm.LRET {
-			i += inst.Len
+			i = next
 			continue
 		}
 
-		entries = append(entries, addr)
+		// INT3 padding after a RET is also seen as a trap run ending at
+		// the same boundary.
+		if n := len(entries); n == 0 || entries[n-1] != addr {
+			entries = append(entries, addr)
+		}
 
-		i += inst.Len
+		i = next
 	}
 
-	return entries
+	return entries, nil
 }
 
 // detectAlignedEntriesARM64 applies the same boundary-separator strategy as
@@ -139,12 +160,17 @@ func detectAlignedEntriesAMD64(code []byte, baseAddr uint64) []uint64 {
 // directly followed by the next function at the next 4-byte boundary).
 // Requiring at least one NOP before the boundary is the same threshold that
 // makes this signal meaningful on AMD64.
-func detectAlignedEntriesARM64(code []byte, baseAddr uint64) []uint64 {
+//
+// When traps is set, a run of BRK instructions is a terminator too.
+func detectAlignedEntriesARM64(code []byte, baseAddr uint64, traps bool, b *budget) ([]uint64, error) {
 	var entries []uint64
 
 	const insnLen = 4
 
 	for i := 0; i+insnLen <= len(code); i += insnLen {
+		if err := b.step(i); err != nil {
+			return nil, err
+		}
 		inst, err := arm64asm.Decode(code[i : i+insnLen])
 		if err != nil {
 			// undecoded instruction, skip
@@ -155,22 +181,28 @@ func detectAlignedEntriesARM64(code []byte, baseAddr uint64) []uint64 {
 		// call to a sibling or PLT stub and qualifies as a terminator.
 		isTerminator := inst.Op == arm64asm.RET
 		if inst.Op == arm64asm.B {
-			if pcrel, ok := inst.Args[0].(arm64asm.PCRel); ok {
-				sourceVA := baseAddr + uint64(i)
-				targetVA := sourceVA + uint64(int64(pcrel))
-				if targetVA < sourceVA {
-					isTerminator = true
-				}
+			if pcrel, ok := inst.Args[0].(arm64asm.PCRel); ok && pcrel < 0 {
+				isTerminator = true
 			}
 		}
 
-		if !isTerminator {
+		isTrap := traps && inst.Op == arm64asm.BRK
+
+		if !isTerminator && !isTrap {
 			// not a function boundary, keep scanning
 			continue
 		}
 
-		// Consume NOP padding after the terminator.
+		// Consume NOP padding after the terminator. A BRK run is consumed
+		// whole so that it is handled only once.
 		j := consumePaddingARM64(code, i+insnLen)
+		if isTrap {
+			for j+insnLen <= len(code) && isBRKARM64(code[j:j+insnLen]) {
+				j += insnLen
+			}
+			j = consumePaddingARM64(code, j)
+			i = j - insnLen
+		}
 
 		// On ARM64, tight packing without NOP padding is normal: small leaf
 		// functions are frequently placed back-to-back on 4-byte boundaries
@@ -206,21 +238,21 @@ func detectAlignedEntriesARM64(code []byte, baseAddr uint64) []uint64 {
 		entries = append(entries, addr)
 	}
 
-	return entries
+	return entries, nil
 }
 
 // consumePaddingAMD64 advances past NOP-like and INT3 fill bytes starting at
 // code[start] and returns the index of the first non-padding byte. It handles
 // single- and multi-byte Intel NOP variants as well as INT3 (0xCC), which some
 // compilers use as inter-function filler instead of NOP.
-func consumePaddingAMD64(code []byte, start int) int {
+func consumePaddingAMD64(code []byte, start, mode int) int {
 	j := start
 	for j < len(code) {
 		if code[j] == x86INT3 {
 			j++
 			continue
 		}
-		pad, err := x86asm.Decode(code[j:], 64)
+		pad, err := x86asm.Decode(code[j:], mode)
 		if err != nil {
 			break
 		}
@@ -251,6 +283,34 @@ func consumePaddingARM64(code []byte, start int) int {
 	return j
 }
 
+// consumeTrapsAMD64 advances past a run of INT3 and UD2 instructions and
+// the NOP padding that follows it, starting at code[start].
+func consumeTrapsAMD64(code []byte, start, mode int) int {
+	j := start
+	for j < len(code) {
+		inst, err := x86asm.Decode(code[j:], mode)
+		if err != nil || !isTrapAMD64(inst) {
+			break
+		}
+		j = consumePaddingAMD64(code, j+inst.Len, mode)
+	}
+	return j
+}
+
+// isTrapAMD64 reports whether inst is a trap instruction: INT3 (either
+// encoding) or UD2. Compilers emit them only as padding or after calls to
+// noreturn functions, so control never falls through them.
+func isTrapAMD64(inst x86asm.Inst) bool {
+	return inst.Op == x86asm.UD2 || (inst.Op == x86asm.INT && inst.Args[0] == x86asm.Imm(3))
+}
+
+// isBRKARM64 reports whether b encodes an AArch64 BRK instruction, the trap
+// compilers emit after calls to noreturn functions and for __builtin_trap.
+func isBRKARM64(b []byte) bool {
+	inst, err := arm64asm.Decode(b)
+	return err == nil && inst.Op == arm64asm.BRK
+}
+
 // isNOPLike reports whether inst is a NOP-class instruction used as padding:
 // - Any NOP (single or multi-byte Intel NOP family)
 // - XCHG AX, AX (0x66 0x90, a 2-byte NOP equivalent)
@@ -268,3 +328,295 @@ func isNOPLike(inst x86asm.Inst) bool {
 	}
 	return false
 }
+
+const (
+	// Instruction classes used by the boundary context window.
+	InsnClassNone    InsnClass = "none"
+	InsnClassReturn  InsnClass = "return"
+	InsnClassPush    InsnClass = "push"
+	InsnClassJump    InsnClass = "jump"
+	InsnClassPadding InsnClass = "padding"
+	InsnClassTrap    InsnClass = "trap"
+	InsnClassOther   InsnClass = "other"
+)
+
+// InsnClass is a coarse, architecture-neutral classification of an
+// instruction preceding a candidate prologue. InsnClassNone stands for the
+// start of the input or bytes that could not be decoded.
+type InsnClass string
+
+// ContextWindow configures how prologue patterns decide that they sit at a
+// function boundary. The instructions preceding the candidate are examined
+// nearest first; the candidate is at a boundary as soon as one of them
+// belongs to an Allowed class.
+type ContextWindow struct {
+	// Instructions is the number of preceding instructions examined.
+	// Values below 1 are treated as 1.
+	Instructions int
+	// Bytes limits the lookbehind distance from the candidate address.
+	// Zero means no byte limit.
+	Bytes int
+	// Allowed lists the predecessor classes that mark a function boundary.
+	Allowed []InsnClass
+}
+
+// WithContextWindow replaces the boundary context window of the given
+// boundary-gated prologue types, or of all of them when types is empty.
+// Patterns that are not boundary-gated (classic, stp-frame-pair, stp-only)
+// are unaffected. The window replaces the trap boundary policy for those
+// types: InsnClassTrap must be listed explicitly to accept a trap.
+func WithContextWindow(w ContextWindow, types ...PrologueType) Option {
+	return func(o *options) {
+		if len(types) == 0 {
+			types = boundaryGatedPrologues
+		}
+		if o.contextWindows == nil {
+			o.contextWindows = make(map[PrologueType]ContextWindow)
+		}
+		for _, typ := range types {
+			o.contextWindows[typ] = w
+			delete(o.boundaryStrategies, typ)
+		}
+	}
+}
+
+// BoundaryContext describes a candidate prologue of a boundary-gated type
+// to a BoundaryStrategy.
+type BoundaryContext struct {
+	// Type is the matched prologue pattern.
+	Type PrologueType
+	// Address is the virtual address of the candidate.
+	Address uint64
+	// Preceding holds the instructions before the candidate remembered by
+	// the sweep, oldest first, up to the larger of 8 and the longest
+	// configured context window. It is only valid during the call.
+	Preceding []PrecedingInsn
+	// InputStart reports whether the start of the input, or bytes that
+	// could not be decoded, lie right before the oldest of Preceding.
+	InputStart bool
+}
+
+// BoundaryStrategy decides whether a candidate prologue of a
+// boundary-gated type sits at a function boundary. ContextWindow is the
+// built-in strategy.
+type BoundaryStrategy interface {
+	AtBoundary(ctx BoundaryContext) bool
+}
+
+// BoundaryFunc adapts a function to a BoundaryStrategy.
+type BoundaryFunc func(ctx BoundaryContext) bool
+
+// AtBoundary calls f(ctx).
+func (f BoundaryFunc) AtBoundary(ctx BoundaryContext) bool {
+	return f(ctx)
+}
+
+// strategyLookbehind is the number of preceding instructions remembered for
+// boundary strategies.
+const strategyLookbehind = 8
+
+// WithBoundaryStrategy replaces the boundary predicate of the given
+// boundary-gated prologue types, or of all of them when types is empty,
+// with s, e.g. to require padding and alignment before a candidate, or to
+// accept one after a jump. Like WithContextWindow, it leaves the other
+// patterns unaffected and replaces the trap boundary policy for those
+// types; the later of the two options wins for each type.
+func WithBoundaryStrategy(s BoundaryStrategy, types ...PrologueType) Option {
+	return func(o *options) {
+		if len(types) == 0 {
+			types = boundaryGatedPrologues
+		}
+		if o.boundaryStrategies == nil {
+			o.boundaryStrategies = make(map[PrologueType]BoundaryStrategy)
+		}
+		for _, typ := range types {
+			o.boundaryStrategies[typ] = s
+			delete(o.contextWindows, typ)
+		}
+	}
+}
+
+// WithTrapBoundaries sets the trap boundary policy. When enabled (the
+// default), trap instructions (int3, ud2, brk) are function terminators:
+// the default context windows accept a trap as the predecessor of a
+// boundary-gated prologue, and alignment-boundary detection treats a run of
+// traps like a return. Disable it for code that uses traps as in-function
+// assertions reached by fallthrough.
+func WithTrapBoundaries(enabled bool) Option {
+	return func(o *options) {
+		o.trapBoundaries = enabled
+	}
+}
+
+// boundaryGatedPrologues lists the prologue types that are only reported at
+// a function boundary, because their instructions also occur mid-function.
+var boundaryGatedPrologues = []PrologueType{
+	PrologueNoFramePointer,
+	ProloguePushOnly,
+	PrologueLEABased,
+	PrologueEnter,
+	PrologueSTRLRPreIndex,
+	PrologueSubSP,
+	PrologueSTPCalleeSaved,
+	PrologueHomeSpill,
+	PrologueAddiSP,
+	PrologueStduSP,
+}
+
+// defaultContextWindow returns the built-in boundary rule for typ: the
+// immediately preceding instruction must be a return, or absent. sub rsp
+// additionally accepts a preceding push, since it commonly follows the
+// callee-saved register saves.
+func defaultContextWindow(typ PrologueType) ContextWindow {
+	if typ == PrologueNoFramePointer {
+		return ContextWindow{Instructions: 1, Allowed: []InsnClass{InsnClassNone, InsnClassReturn, InsnClassPush}}
+	}
+	return ContextWindow{Instructions: 1, Allowed: []InsnClass{InsnClassNone, InsnClassReturn}}
+}
+
+// contextWindow returns the effective context window for typ. Under the
+// trap boundary policy the default window also accepts a preceding trap,
+// and toolchains that align functions with NOP fill accept padding.
+func (o *options) contextWindow(typ PrologueType) ContextWindow {
+	if w, ok := o.contextWindows[typ]; ok {
+		return w
+	}
+	w := defaultContextWindow(typ)
+	if o.trapBoundaries {
+		w.Allowed = append(w.Allowed, InsnClassTrap)
+	}
+	if toolchainProfiles[o.toolchain].paddingBoundary {
+		w.Allowed = append(w.Allowed, InsnClassPadding)
+	}
+	return w
+}
+
+// lookbehind returns the number of instructions the scanner must remember to
+// evaluate every configured context window and boundary strategy.
+func (o *options) lookbehind() int {
+	n := 1
+	if len(o.boundaryStrategies) > 0 {
+		n = strategyLookbehind
+	}
+	for _, w := range o.contextWindows {
+		n = max(n, w.Instructions)
+	}
+	return n
+}
+
+// PrecedingInsn is a classified instruction preceding a candidate
+// prologue.
+type PrecedingInsn struct {
+	// Address is the virtual address of the instruction.
+	Address uint64
+	// Class is the boundary-context class of the instruction.
+	Class InsnClass
+}
+
+// insnHistory holds the most recent instructions seen by a linear sweep,
+// oldest first. Undecodable bytes are recorded as InsnClassNone so that the
+// instruction following them is treated like the start of the input.
+type insnHistory struct {
+	entries []PrecedingInsn
+	size    int
+	// trimmed reports whether older entries have been discarded, i.e. the
+	// start of the input is no longer within reach.
+	trimmed bool
+}
+
+func newInsnHistory(size int) *insnHistory {
+	return &insnHistory{size: size}
+}
+
+// push records an instruction at addr with the given class.
+func (h *insnHistory) push(addr uint64, class InsnClass) {
+	if len(h.entries) == h.size {
+		copy(h.entries, h.entries[1:])
+		h.entries = h.entries[:len(h.entries)-1]
+		h.trimmed = true
+	}
+	h.entries = append(h.entries, PrecedingInsn{Address: addr, Class: class})
+}
+
+// context returns the boundary context of a prologue candidate of type typ
+// at addr.
+func (h *insnHistory) context(addr uint64, typ PrologueType) BoundaryContext {
+	return BoundaryContext{Type: typ, Address: addr, Preceding: h.entries, InputStart: !h.trimmed}
+}
+
+// atBoundary reports whether a prologue candidate of type typ at addr,
+// preceded by the instructions in h, sits at a function boundary under the
+// strategy or context window configured for typ.
+func (o *options) atBoundary(h *insnHistory, addr uint64, typ PrologueType) bool {
+	if s, ok := o.boundaryStrategies[typ]; ok {
+		return s.AtBoundary(h.context(addr, typ))
+	}
+	return o.contextWindow(typ).AtBoundary(h.context(addr, typ))
+}
+
+// AtBoundary reports whether the candidate described by ctx satisfies w:
+// one of the Instructions nearest to it, within Bytes, belongs to an
+// Allowed class, or the start of the input lies within the window and
+// InsnClassNone is allowed.
+func (w ContextWindow) AtBoundary(ctx BoundaryContext) bool {
+	limit := max(w.Instructions, 1)
+	n := 0
+	for i := len(ctx.Preceding) - 1; i >= 0 && n < limit; i-- {
+		e := ctx.Preceding[i]
+		if w.Bytes > 0 && ctx.Address-e.Address > uint64(w.Bytes) {
+			return false
+		}
+		if slices.Contains(w.Allowed, e.Class) {
+			return true
+		}
+		n++
+	}
+	// The start of the input lies within the window.
+	if n < limit && ctx.InputStart {
+		return slices.Contains(w.Allowed, InsnClassNone)
+	}
+	return false
+}
+
+// classifyAMD64 returns the boundary-context class of an x86-64 instruction.
+func classifyAMD64(inst x86asm.Inst) InsnClass {
+	switch {
+	case inst.Op == x86asm.RET || inst.Op == x86asm.LRET:
+		return InsnClassReturn
+	case inst.Op == x86asm.PUSH:
+		return InsnClassPush
+	case inst.Op == x86asm.JMP:
+		return InsnClassJump
+	case isTrapAMD64(inst):
+		return InsnClassTrap
+	case isNOPLike(inst):
+		return InsnClassPadding
+	}
+	return InsnClassOther
+}
+
+// classifyARM64 returns the boundary-context class of an AArch64 instruction.
+func classifyARM64(inst arm64asm.Inst, word uint32) InsnClass {
+	switch {
+	case inst.Op == arm64asm.RET:
+		return InsnClassReturn
+	case inst.Op == arm64asm.B && !isConditionalARM64(inst):
+		return InsnClassJump
+	case inst.Op == arm64asm.BRK:
+		return InsnClassTrap
+	case isBenignARM64(word):
+		return InsnClassPadding
+	}
+	return InsnClassOther
+}
+
+// isConditionalARM64 reports whether inst carries a condition argument
+// (B.cond).
+func isConditionalARM64(inst arm64asm.Inst) bool {
+	for _, arg := range inst.Args {
+		if _, ok := arg.(arm64asm.Cond); ok {
+			return true
+		}
+	}
+	return false
+}
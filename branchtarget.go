@@ -0,0 +1,83 @@
+package resurgo
+
+// DetectionBranchTarget is assigned to function candidates found at a
+// branch target marker, the instruction control-flow protected code places
+// at every indirect branch target (ENDBR64 and ENDBR32 on x86, BTI c,
+// BTI jc, PACIASP and PACIBSP on ARM64), with no prologue or call-site
+// signal.
+const DetectionBranchTarget DetectionType = "branch-target"
+
+// WithBranchTargets sets whether DisasmDetector enumerates branch target
+// markers as function-start candidates (default false). On binaries built
+// with -fcf-protection, every function that may be called indirectly starts
+// with ENDBR64, and on ARM64 binaries built with -mbranch-protection=bti
+// with BTI c, or PACIASP, which acts as BTI c, when return addresses are
+// signed too; prologue matching skips the marker, attributing the prologue
+// one instruction late. In this mode a prologue following a marker is
+// reported at the marker with ConfidenceHigh, and the other markers are
+// reported as DetectionBranchTarget with ConfidenceMedium.
+func WithBranchTargets(enabled bool) Option {
+	return func(o *options) {
+		o.branchTargets = enabled
+	}
+}
+
+// branchTargetSize is the size of the ENDBR64, ENDBR32 and BTI
+// instructions.
+const branchTargetSize = 4
+
+// branchTargets returns the addresses of the branch target markers in code
+// of arch at baseAddr, in ascending order. Only x86 and ARM64 code is
+// supported. BTI j, which only jumps land on, marks no function start.
+func branchTargets(code []byte, baseAddr uint64, arch Arch) []uint64 {
+	var targets []uint64
+	switch arch {
+	case ArchAMD64, ArchX86:
+		for i := 0; i+branchTargetSize <= len(code); i++ {
+			if isENDBR(code, i) {
+				targets = append(targets, baseAddr+uint64(i))
+				i += branchTargetSize - 1
+			}
+		}
+	case ArchARM64:
+		for i := 0; i+branchTargetSize <= len(code); i += branchTargetSize {
+			if isBTILandingPad(code[i:]) {
+				targets = append(targets, baseAddr+uint64(i))
+			}
+		}
+	}
+	return targets
+}
+
+// applyBranchTargets attributes the prologue candidates that follow one of
+// the markers at targets to the marker, raised to ConfidenceHigh, and merges
+// them with the call-site candidates at the marker. The other markers are
+// added as DetectionBranchTarget candidates, or replace an aligned-entry
+// candidate, with ConfidenceMedium.
+func applyBranchTargets(candidates map[uint64]*FunctionCandidate, targets []uint64) {
+	for _, target := range targets {
+		c, exists := candidates[target]
+		aligned := exists && c.DetectionType == DetectionAlignedEntry
+		p, ok := candidates[target+branchTargetSize]
+		switch {
+		case ok && p.DetectionType == DetectionPrologueOnly && exists && !aligned:
+			if c.PrologueType != "" {
+				continue
+			}
+			c.DetectionType, c.PrologueType, c.Confidence = DetectionPrologueCallSite, p.PrologueType, ConfidenceHigh
+			delete(candidates, p.Address)
+		case ok && p.DetectionType == DetectionPrologueOnly:
+			delete(candidates, p.Address)
+			p.Address, p.Confidence = target, ConfidenceHigh
+			p.Boundary = p.Boundary || aligned
+			candidates[target] = p
+		case !exists || aligned:
+			candidates[target] = &FunctionCandidate{
+				Address:       target,
+				DetectionType: DetectionBranchTarget,
+				Confidence:    ConfidenceMedium,
+				Boundary:      aligned,
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestWithBranchTargets(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("CET test requires an amd64 compiler")
+	}
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+	outPath := filepath.Join(t.TempDir(), "demo-app-cet")
+	cmd := exec.Command("gcc", "-O0", "-fcf-protection=full", "-o", outPath, "testdata/demo-app.c")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("gcc cannot build CET code: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	want := map[string]uint64{"add": 0, "subtract": 0, "multiply": 0, "divide": 0, "main": 0}
+	for _, s := range syms {
+		if _, ok := want[s.Name]; ok && elf.ST_TYPE(s.Info) == elf.STT_FUNC {
+			want[s.Name] = s.Value
+		}
+	}
+
+	candidates, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithFilters(), resurgo.WithBranchTargets(true))
+	if err != nil {
+		t.Fatalf("resurgo.DetectFunctionsFromELF: %v", err)
+	}
+	got := make(map[uint64]resurgo.FunctionCandidate)
+	for _, c := range candidates {
+		got[c.Address] = c
+	}
+	for name, addr := range want {
+		c, ok := got[addr]
+		if !ok {
+			t.Errorf("%s at 0x%x not detected", name, addr)
+			continue
+		}
+		// The prologue follows ENDBR64 and is attributed to it.
+		if c.PrologueType != resurgo.PrologueClassic || c.Confidence != resurgo.ConfidenceHigh {
+			t.Errorf("%s at 0x%x: got %s/%s/%s", name, addr, c.DetectionType, c.PrologueType, c.Confidence)
+		}
+		if c, ok := got[addr+4]; ok {
+			t.Errorf("%s: unexpected candidate after ENDBR64 at 0x%x (%s)", name, c.Address, c.DetectionType)
+		}
+	}
+
+	// Functions only reached indirectly, with no prologue, are found by
+	// their marker alone.
+	var markers int
+	for _, c := range candidates {
+		if c.DetectionType == resurgo.DetectionBranchTarget {
+			markers++
+			if c.Confidence != resurgo.ConfidenceMedium {
+				t.Errorf("marker 0x%x: got confidence %s", c.Address, c.Confidence)
+			}
+		}
+	}
+	if markers == 0 {
+		t.Error("no branch-target candidates")
+	}
+}
+
+// btiSource holds a framed function, a leaf and a jump target behind BTI
+// landing pads, a BTI j, which only jumps land on, and a leaf behind
+// PACIASP, which acts as BTI c.
+const btiSource = `
+	.text
+	.globl framed
+	.type framed, %function
+framed:
+	bti c
+	stp x29, x30, [sp, #-16]!
+	mov x29, sp
+	ldp x29, x30, [sp], #16
+	ret
+	.globl leaf
+	.type leaf, %function
+leaf:
+	bti c
+	add x0, x0, #1
+	ret
+	.globl jumped
+	.type jumped, %function
+jumped:
+	bti jc
+	ret
+inner:
+	bti j
+	ret
+	.globl signed
+	.type signed, %function
+signed:
+	paciasp
+	add x0, x0, #2
+	autiasp
+	ret
+`
+
+func TestWithBranchTargetsARM64(t *testing.T) {
+	if _, err := exec.LookPath("llvm-mc"); err != nil {
+		t.Skip("llvm-mc not found in PATH")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "bti.s")
+	if err := os.WriteFile(src, []byte(btiSource), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	obj := filepath.Join(dir, "bti.o")
+	cmd := exec.Command("llvm-mc", "-triple=aarch64", "-mattr=+bti", "-filetype=obj", "-o", obj, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("llvm-mc: %v\n%s", err, out)
+	}
+	f, err := elf.Open(obj)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	type result struct {
+		DetectionType resurgo.DetectionType
+		PrologueType  resurgo.PrologueType
+		Confidence    resurgo.Confidence
+	}
+	tests := []struct {
+		name    string
+		enabled bool
+		want    map[uint64]result
+	}{
+		{
+			name:    "disabled",
+			enabled: false,
+			want: map[uint64]result{
+				0x4:  {resurgo.DetectionPrologueOnly, resurgo.PrologueSTPFramePair, resurgo.ConfidenceMedium},
+				0x20: {resurgo.DetectionAlignedEntry, "", resurgo.ConfidenceLow},
+				0x30: {resurgo.DetectionAlignedEntry, "", resurgo.ConfidenceLow},
+			},
+		},
+		{
+			name:    "enabled",
+			enabled: true,
+			want: map[uint64]result{
+				0x0:  {resurgo.DetectionPrologueOnly, resurgo.PrologueSTPFramePair, resurgo.ConfidenceHigh},
+				0x14: {resurgo.DetectionBranchTarget, "", resurgo.ConfidenceMedium},
+				0x20: {resurgo.DetectionBranchTarget, "", resurgo.ConfidenceMedium},
+				0x30: {resurgo.DetectionBranchTarget, "", resurgo.ConfidenceMedium},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithBranchTargets(tt.enabled))
+			if err != nil {
+				t.Fatalf("resurgo.DetectFunctionsFromELF: %v", err)
+			}
+			got := make(map[uint64]result)
+			for _, c := range candidates {
+				got[c.Address] = result{c.DetectionType, c.PrologueType, c.Confidence}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
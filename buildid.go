@@ -0,0 +1,76 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+const (
+	// buildIDSection is the ELF section holding the GNU build ID note.
+	buildIDSection = ".note.gnu.build-id"
+	// ntGNUBuildID is the type of the GNU build ID note.
+	ntGNUBuildID = 3
+)
+
+// PrologueResult is the outcome of AnalyzeProloguesFromELF.
+type PrologueResult struct {
+	// BuildID is the GNU build ID of the file, in hexadecimal; it is empty
+	// if the file has none.
+	BuildID string `json:"build_id,omitempty"`
+	// Prologues holds the prologues detected in the file, ordered as by
+	// DetectProloguesFromELF.
+	Prologues []Prologue `json:"prologues,omitempty"`
+}
+
+// AnalyzeProloguesFromELF returns the prologues of f, as
+// DetectProloguesFromELF does, along with the build ID of f, which
+// symbolization pipelines key their results by.
+func AnalyzeProloguesFromELF(f *elf.File, opts ...Option) (*PrologueResult, error) {
+	buildID, err := BuildID(f)
+	if err != nil {
+		return nil, err
+	}
+	prologues, err := DetectProloguesFromELF(f, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &PrologueResult{BuildID: buildID, Prologues: prologues}, nil
+}
+
+// BuildID returns the GNU build ID of f in hexadecimal, as used by debuginfod
+// and the /usr/lib/debug/.build-id tree. It is read from the
+// .note.gnu.build-id section or, when the section headers are missing, from
+// the PT_NOTE segments. It returns an empty string if f has no build ID.
+func BuildID(f *elf.File) (string, error) {
+	var notes []elfNote
+	if sec := f.Section(buildIDSection); sec != nil && sec.Type == elf.SHT_NOTE {
+		n, err := readNotes(f, sec)
+		if err != nil {
+			return "", err
+		}
+		notes = n
+	} else if len(f.Sections) == 0 {
+		for _, p := range f.Progs {
+			if p.Type != elf.PT_NOTE {
+				continue
+			}
+			data := make([]byte, p.Filesz)
+			if _, err := p.ReadAt(data, 0); err != nil && err != io.EOF {
+				return "", fmt.Errorf("failed to read note segment at %#x: %w", p.Off, err)
+			}
+			n, err := parseNotes(data, f.ByteOrder, "PT_NOTE segment")
+			if err != nil {
+				return "", err
+			}
+			notes = append(notes, n...)
+		}
+	}
+	for _, n := range notes {
+		if n.name == "GNU" && n.typ == ntGNUBuildID {
+			return hex.EncodeToString(n.desc), nil
+		}
+	}
+	return "", nil
+}
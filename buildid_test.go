@@ -0,0 +1,82 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestBuildID(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	const id = "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		name    string
+		flag    string
+		noShdrs bool
+		want    string
+	}{
+		{name: "section", flag: "-Wl,--build-id=0x" + id, want: id},
+		{name: "segment", flag: "-Wl,--build-id=0x" + id, noShdrs: true, want: id},
+		{name: "none", flag: "-Wl,--build-id=none", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "demo-app-c")
+			if out, err := exec.Command("gcc", "-O0", tt.flag, "-o", path, "testdata/demo-app.c").CombinedOutput(); err != nil {
+				t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.noShdrs {
+				// Drop the section header table: e_shoff, e_shnum and
+				// e_shstrndx.
+				copy(data[0x28:], make([]byte, 8))
+				copy(data[0x3c:], make([]byte, 4))
+			}
+			f, err := elf.NewFile(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("failed to parse ELF: %v", err)
+			}
+
+			got, err := resurgo.BuildID(f)
+			if err != nil {
+				t.Fatalf("BuildID: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildID = %q, want %q", got, tt.want)
+			}
+
+			result, err := resurgo.AnalyzeProloguesFromELF(f)
+			if err != nil {
+				t.Fatalf("AnalyzeProloguesFromELF: %v", err)
+			}
+			if result.BuildID != tt.want {
+				t.Errorf("AnalyzeProloguesFromELF build ID = %q, want %q", result.BuildID, tt.want)
+			}
+			if len(result.Prologues) == 0 {
+				t.Error("AnalyzeProloguesFromELF returned no prologues")
+			}
+
+			if tt.noShdrs {
+				return
+			}
+			analysis, err := resurgo.DetectFunctionsFromFile(path)
+			if err != nil {
+				t.Fatalf("DetectFunctionsFromFile: %v", err)
+			}
+			if analysis.BuildID != tt.want {
+				t.Errorf("DetectFunctionsFromFile build ID = %q, want %q", analysis.BuildID, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const (
+	// calibrationVersion is the version of the Calibration format.
+	calibrationVersion = 1
+
+	// Calibrated precision at or above which a signal is reported with
+	// ConfidenceHigh or ConfidenceMedium; signals below are ConfidenceLow.
+	calibratedHigh   = 0.9
+	calibratedMedium = 0.5
+)
+
+// Calibration is a scoring profile fitted by Calibrate to a labeled corpus.
+// It replaces the built-in confidence of each signal with the precision the
+// signal achieved on the corpus and drops the signals whose precision falls
+// below Threshold. It is JSON-serializable; see Save and LoadCalibration.
+type Calibration struct {
+	// Version is the version of the calibration format.
+	Version int `json:"version"`
+	// Signals maps a signal, the detection type of a candidate optionally
+	// followed by a slash and its prologue type, to its statistics.
+	Signals map[string]SignalCalibration `json:"signals"`
+	// Threshold is the precision below which candidates are dropped. It is
+	// the value that maximized the F1 score on the corpus.
+	Threshold float64 `json:"threshold"`
+}
+
+// SignalCalibration holds the statistics of one signal over the corpus.
+type SignalCalibration struct {
+	// Candidates is the number of candidates produced by the signal.
+	Candidates int `json:"candidates"`
+	// TruePositives is the number of them that are function entries.
+	TruePositives int `json:"true_positives"`
+	// Precision is the Laplace-smoothed share of true positives, used as
+	// the weight of the signal.
+	Precision float64 `json:"precision"`
+}
+
+// WithCalibration scores candidates with c, as fitted by Calibrate, once the
+// filters have run: the confidence of a candidate is derived from the
+// calibrated precision of its signal (ConfidenceHigh from 0.9, ConfidenceMedium
+// from 0.5, ConfidenceLow below), and candidates whose signal falls below
+// c.Threshold are dropped. Signals absent from c are left unchanged.
+func WithCalibration(c *Calibration) Option {
+	return func(o *options) {
+		o.calibration = c
+	}
+}
+
+// Calibrate fits a Calibration to corpus, a set of ELF binaries that still
+// carry their symbol tables. Each binary is analyzed with opts; the STT_FUNC
+// symbols within executable sections serve as ground truth. The precision of
+// every signal is measured, and the threshold is chosen to maximize the F1
+// score over the whole corpus.
+func Calibrate(corpus []*elf.File, opts ...Option) (*Calibration, error) {
+	type sample struct {
+		signal string
+		truth  bool
+	}
+	var samples []sample
+	functions := 0
+	for i, f := range corpus {
+		truth, err := functionSymbols(f)
+		if err != nil {
+			return nil, fmt.Errorf("corpus binary %d: %w", i, err)
+		}
+		functions += len(truth)
+		// The signals are measured before any previous calibration.
+		candidates, err := DetectFunctionsFromELF(f, append(slices.Clone(opts), WithCalibration(nil))...)
+		if err != nil {
+			return nil, fmt.Errorf("corpus binary %d: %w", i, err)
+		}
+		for _, c := range candidates {
+			_, ok := truth[c.Address]
+			samples = append(samples, sample{signal: signalKey(c), truth: ok})
+		}
+	}
+	if functions == 0 {
+		return nil, errors.New("corpus has no function symbols")
+	}
+
+	c := &Calibration{Version: calibrationVersion, Signals: make(map[string]SignalCalibration)}
+	for _, s := range samples {
+		sc := c.Signals[s.signal]
+		sc.Candidates++
+		if s.truth {
+			sc.TruePositives++
+		}
+		c.Signals[s.signal] = sc
+	}
+	for key, sc := range c.Signals {
+		sc.Precision = float64(sc.TruePositives+1) / float64(sc.Candidates+2)
+		c.Signals[key] = sc
+	}
+
+	// Try each distinct precision as the threshold, from keeping every
+	// signal upward, and keep the first with the best F1 score.
+	thresholds := []float64{0}
+	for _, sc := range c.Signals {
+		thresholds = append(thresholds, sc.Precision)
+	}
+	slices.Sort(thresholds)
+	thresholds = slices.Compact(thresholds)
+	bestF1 := -1.0
+	for _, t := range thresholds {
+		var kept, tp int
+		for _, sc := range c.Signals {
+			if sc.Precision >= t {
+				kept += sc.Candidates
+				tp += sc.TruePositives
+			}
+		}
+		f1 := 0.0
+		if kept > 0 {
+			f1 = 2 * float64(tp) / float64(kept+functions)
+		}
+		if f1 > bestF1 {
+			bestF1, c.Threshold = f1, t
+		}
+	}
+	return c, nil
+}
+
+// Save writes c as JSON to w.
+func (c *Calibration) Save(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// LoadCalibration reads a Calibration written by Save.
+func LoadCalibration(r io.Reader) (*Calibration, error) {
+	var c Calibration
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, fmt.Errorf("decode calibration: %w", err)
+	}
+	if c.Version != calibrationVersion {
+		return nil, fmt.Errorf("unsupported calibration version %d", c.Version)
+	}
+	return &c, nil
+}
+
+// apply sets the confidence of candidates from the calibrated precision of
+// their signals and drops those below the threshold.
+func (c *Calibration) apply(candidates []FunctionCandidate) []FunctionCandidate {
+	kept := candidates[:0]
+	for _, cand := range candidates {
+		sc, ok := c.Signals[signalKey(cand)]
+		if !ok {
+			kept = append(kept, cand)
+			continue
+		}
+		if sc.Precision < c.Threshold {
+			continue
+		}
+		switch {
+		case sc.Precision >= calibratedHigh:
+			cand.Confidence = ConfidenceHigh
+		case sc.Precision >= calibratedMedium:
+			cand.Confidence = ConfidenceMedium
+		default:
+			cand.Confidence = ConfidenceLow
+		}
+		kept = append(kept, cand)
+	}
+	return kept
+}
+
+// signalKey identifies the signal that produced c.
+func signalKey(c FunctionCandidate) string {
+	if c.PrologueType == "" {
+		return string(c.DetectionType)
+	}
+	return string(c.DetectionType) + "/" + string(c.PrologueType)
+}
+
+// functionSymbols returns the addresses of the STT_FUNC symbols of f that
+// fall within executable sections.
+func functionSymbols(f *elf.File) (map[uint64]struct{}, error) {
+	syms, err := f.Symbols()
+	if err != nil {
+		return nil, fmt.Errorf("read symbols: %w", err)
+	}
+	exec := execRanges(f)
+	truth := make(map[uint64]struct{})
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) == elf.STT_FUNC && inRanges(s.Value, exec) {
+			truth[s.Value] = struct{}{}
+		}
+	}
+	return truth, nil
+}
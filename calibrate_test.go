@@ -0,0 +1,94 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+	"github.com/maxgio92/resurgo/resurgotest"
+)
+
+func TestCalibrate(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	var paths []string
+	var corpus []*elf.File
+	for _, opt := range []string{"-O0", "-O2"} {
+		outPath := filepath.Join(dir, "demo-app"+opt)
+		cmd := exec.Command("gcc", opt, "-o", outPath, "testdata/demo-app.c")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+		}
+		f, err := elf.Open(outPath)
+		if err != nil {
+			t.Fatalf("failed to open ELF: %v", err)
+		}
+		defer f.Close()
+		paths = append(paths, outPath)
+		corpus = append(corpus, f)
+	}
+
+	// The permissive preset emits signals of mixed precision to calibrate.
+	preset := resurgo.WithPreset(resurgo.PresetPermissive)
+	calibration, err := resurgo.Calibrate(corpus, preset)
+	if err != nil {
+		t.Fatalf("resurgo.Calibrate: %v", err)
+	}
+	if len(calibration.Signals) == 0 {
+		t.Fatal("calibration measured no signals")
+	}
+	for key, sc := range calibration.Signals {
+		if sc.TruePositives > sc.Candidates || sc.Precision <= 0 || sc.Precision >= 1 {
+			t.Errorf("signal %s: inconsistent statistics %+v", key, sc)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := calibration.Save(&buf); err != nil {
+		t.Fatalf("Calibration.Save: %v", err)
+	}
+	loaded, err := resurgo.LoadCalibration(&buf)
+	if err != nil {
+		t.Fatalf("resurgo.LoadCalibration: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, calibration) {
+		t.Errorf("calibration changed through Save and LoadCalibration:\ngot  %+v\nwant %+v", loaded, calibration)
+	}
+
+	// The threshold maximizes F1 on the corpus, so calibrating cannot make
+	// the corpus score worse.
+	f1 := func(opts ...resurgo.Option) float64 {
+		var tp, candidates, functions int
+		for i, f := range corpus {
+			truth := resurgotest.FunctionVAs(t, paths[i])
+			result, err := resurgo.DetectFunctionsFromELF(f, opts...)
+			if err != nil {
+				t.Fatalf("resurgo.DetectFunctionsFromELF: %v", err)
+			}
+			for _, c := range result {
+				if _, ok := truth[c.Address]; ok {
+					tp++
+				}
+			}
+			candidates += len(result)
+			functions += len(truth)
+		}
+		return 2 * float64(tp) / float64(candidates+functions)
+	}
+	base, calibrated := f1(preset), f1(preset, resurgo.WithCalibration(loaded))
+	t.Logf("F1 %.3f uncalibrated, %.3f calibrated (threshold %.3f)", base, calibrated, loaded.Threshold)
+	if calibrated < base {
+		t.Errorf("calibrated F1 %.3f is below uncalibrated %.3f", calibrated, base)
+	}
+
+	if _, err := resurgo.LoadCalibration(bytes.NewReader([]byte(`{"version": 99}`))); err == nil {
+		t.Error("expected an error for an unsupported calibration version")
+	}
+}
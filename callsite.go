@@ -1,7 +1,10 @@
 package resurgo
 
 import (
+	"cmp"
 	"fmt"
+	"math"
+	"slices"
 
 	"golang.org/x/arch/arm64/arm64asm"
 	"golang.org/x/arch/x86/x86asm"
@@ -25,6 +28,11 @@ const (
 	// of one or more JMP instructions.
 	DetectionJumpTarget DetectionType = "jump-target"
 
+	// DetectionTailCall indicates the candidate was found as the target of
+	// an unconditional jump from another function, a tail call, at an
+	// address the preceding code ends a function before.
+	DetectionTailCall DetectionType = "tail-call"
+
 	// DetectionPrologueCallSite indicates the candidate was confirmed by both
 	// prologue matching and call-site analysis.
 	DetectionPrologueCallSite DetectionType = "prologue-callsite"
@@ -55,27 +63,98 @@ type CallSiteEdge struct {
 // DetectCallSites analyzes raw machine code bytes and returns detected
 // call sites (CALL and JMP instructions with their targets). baseAddr is the
 // virtual address corresponding to the start of code. arch selects the
-// architecture-specific detection logic. This function performs no I/O and
-// works with any binary format.
-func DetectCallSites(code []byte, baseAddr uint64, arch Arch) ([]CallSiteEdge, error) {
+// architecture-specific detection logic, built in or registered with
+// RegisterArch. Edges are ordered by source address.
+// opts may include WithAddressWrap, WithByteOrder or WithLimits; other
+// options are ignored.
+// This function performs no I/O and works with any binary format.
+func DetectCallSites(code []byte, baseAddr uint64, arch Arch, opts ...Option) ([]CallSiteEdge, error) {
+	return newOptions(opts...).detectCallSites(code, baseAddr, arch)
+}
+
+func (o *options) detectCallSites(code []byte, baseAddr uint64, arch Arch) ([]CallSiteEdge, error) {
+	o = o.withBudget()
+	if err := o.budget.codeSize(uint64(len(code))); err != nil {
+		return nil, err
+	}
+	code, err := o.littleEndianCode(code, arch)
+	if err != nil {
+		return nil, err
+	}
+	var edges []CallSiteEdge
 	switch arch {
 	case ArchAMD64:
-		return detectCallSitesAMD64(code, baseAddr)
+		edges, err = detectCallSitesAMD64(code, baseAddr, 64, o.addressWrap, o.budget)
+	case ArchX86:
+		edges, err = detectCallSitesAMD64(code, baseAddr, 32, o.addressWrap, o.budget)
 	case ArchARM64:
-		return detectCallSitesARM64(code, baseAddr)
+		edges, err = detectCallSitesARM64(code, baseAddr, o.addressWrap, o.budget)
+	case ArchRISCV64:
+		edges, err = detectCallSitesRISCV64(code, baseAddr, o.addressWrap, o.budget)
+	case ArchARM:
+		edges, err = detectCallSitesARM(code, baseAddr, o.addressWrap, o.budget)
+	case ArchThumb:
+		edges, err = detectCallSitesThumb(code, baseAddr, o.addressWrap, o.budget)
+	case ArchPPC64LE, ArchPPC64:
+		edges, err = detectCallSitesPPC64(code, baseAddr, o.addressWrap, o.budget)
+	case ArchXtensa:
+		edges, err = detectCallSitesXtensa(code, baseAddr, o.addressWrap, o.budget)
 	default:
-		return nil, fmt.Errorf("unsupported architecture: %s", arch)
+		d, ok := lookupArch(arch)
+		if !ok {
+			return nil, fmt.Errorf("unsupported architecture: %s", arch)
+		}
+		if edges, err = d.DetectCallSites(code, baseAddr); err == nil {
+			slices.SortStableFunc(edges, func(a, b CallSiteEdge) int {
+				return cmp.Compare(a.SourceAddr, b.SourceAddr)
+			})
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := o.budget.results(len(edges)); err != nil {
+		return nil, err
+	}
+	return edges, nil
+}
+
+// WithAddressWrap sets whether branch targets may wrap around the 64-bit
+// address space. By default a relative target that crosses the top or the
+// bottom of the address space is discarded: no real branch does so, and the
+// instruction was most likely decoded out of data. Enable wrapping to keep
+// such targets modulo 2^64, e.g. for dumps relocated to an arbitrary base.
+func WithAddressWrap(enabled bool) Option {
+	return func(o *options) {
+		o.addressWrap = enabled
 	}
 }
 
+// relTarget returns base + disp. ok is false when the sum wraps around the
+// 64-bit address space and wrap is not set.
+func relTarget(base uint64, disp int64, wrap bool) (target uint64, ok bool) {
+	target = base + uint64(disp)
+	if wrap {
+		return target, true
+	}
+	if disp < 0 {
+		return target, target < base
+	}
+	return target, target >= base
+}
 
-func detectCallSitesAMD64(code []byte, baseAddr uint64) ([]CallSiteEdge, error) {
+// detectCallSitesAMD64 scans x86 code decoded in mode, 64 for x86-64 or 32
+// for i386, for CALL and JMP instructions.
+func detectCallSitesAMD64(code []byte, baseAddr uint64, mode int, wrap bool, b *budget) ([]CallSiteEdge, error) {
 	var result []CallSiteEdge
 
 	offset := 0
 	addr := baseAddr
 
 	for offset < len(code) {
+		if err := b.step(offset); err != nil {
+			return nil, err
+		}
 		// Skip ENDBR64 / ENDBR32: golang.org/x/arch/x86/x86asm does not
 		// recognise these CET instructions. They appear at function entries
 		// on binaries compiled with -fcf-protection and are transparent to
@@ -86,7 +165,7 @@ func detectCallSitesAMD64(code []byte, baseAddr uint64) ([]CallSiteEdge, error)
 			continue
 		}
 
-		inst, err := x86asm.Decode(code[offset:], 64)
+		inst, err := x86asm.Decode(code[offset:], mode)
 		if err != nil {
 			offset++
 			addr++
@@ -95,13 +174,13 @@ func detectCallSitesAMD64(code []byte, baseAddr uint64) ([]CallSiteEdge, error)
 
 		switch inst.Op {
 		case x86asm.CALL:
-			if edge := extractTargetAMD64(inst, addr, CallSiteCall, ConfidenceHigh); edge != nil {
+			if edge := extractTargetAMD64(inst, addr, mode, CallSiteCall, ConfidenceHigh, wrap); edge != nil {
 				result = append(result, *edge)
 			}
 		case x86asm.JMP:
 			// x86asm uses distinct Op values for conditional jumps (JNE, JE, JL, etc.),
 			// so Op == JMP is always unconditional.
-			if edge := extractTargetAMD64(inst, addr, CallSiteJump, ConfidenceMedium); edge != nil {
+			if edge := extractTargetAMD64(inst, addr, mode, CallSiteJump, ConfidenceMedium, wrap); edge != nil {
 				result = append(result, *edge)
 			}
 		}
@@ -113,11 +192,13 @@ func detectCallSitesAMD64(code []byte, baseAddr uint64) ([]CallSiteEdge, error)
 	return result, nil
 }
 
-// extractTargetAMD64 extracts the call site target from an x86-64 CALL or JMP
-// instruction. cfType and baseConfidence are applied to direct (Rel) and absolute
-// (Mem without base/index) operands. Register-indirect and RIP-relative operands
-// receive adjusted confidence levels.
-func extractTargetAMD64(inst x86asm.Inst, sourceAddr uint64, cfType CallSiteType, baseConfidence Confidence) *CallSiteEdge {
+// extractTargetAMD64 extracts the call site target from an x86 CALL or JMP
+// instruction decoded in mode. cfType and baseConfidence are applied to direct
+// (Rel) and absolute (Mem without base/index) operands. Register-indirect and
+// RIP-relative operands receive adjusted confidence levels. Relative targets
+// that wrap around the address space, 32 bits wide in 32-bit mode, are dropped
+// unless wrap is set.
+func extractTargetAMD64(inst x86asm.Inst, sourceAddr uint64, mode int, cfType CallSiteType, baseConfidence Confidence, wrap bool) *CallSiteEdge {
 	edge := &CallSiteEdge{
 		SourceAddr: sourceAddr,
 		Type:       cfType,
@@ -126,24 +207,43 @@ func extractTargetAMD64(inst x86asm.Inst, sourceAddr uint64, cfType CallSiteType
 	switch arg := inst.Args[0].(type) {
 	case x86asm.Rel:
 		// PC-relative: call/jmp rel32 or rel8
-		edge.TargetAddr = sourceAddr + uint64(inst.Len) + uint64(int64(arg))
+		target, ok := relTarget(sourceAddr+uint64(inst.Len), int64(arg), wrap)
+		if mode == 32 && target > math.MaxUint32 {
+			ok = wrap
+			target &= math.MaxUint32
+		}
+		if !ok {
+			return nil
+		}
+		edge.TargetAddr = target
 		edge.AddressMode = AddressingModePCRelative
 		edge.Confidence = baseConfidence
 		return edge
 
 	case x86asm.Mem:
+		// x86asm zero-extends the 32-bit displacement; the CPU sign-extends
+		// it in 64-bit mode. In 32-bit mode it is already an address.
+		disp := int64(int32(arg.Disp))
+		if mode == 32 {
+			disp = arg.Disp
+		}
 		if arg.Base == x86asm.RIP && arg.Index == 0 {
 			// RIP-relative: call/jmp [rip+disp32]  - dominant indirect form in
 			// PIE binaries (PLT/GOT). The referenced memory address is
 			// computable: nextPC + disp.
-			edge.TargetAddr = sourceAddr + uint64(inst.Len) + uint64(arg.Disp)
+			target, ok := relTarget(sourceAddr+uint64(inst.Len), disp, wrap)
+			if !ok {
+				return nil
+			}
+			edge.TargetAddr = target
 			edge.AddressMode = AddressingModePCRelative
 			edge.Confidence = ConfidenceMedium
 			return edge
 		}
 		if arg.Base == 0 && arg.Index == 0 {
-			// Absolute address: call/jmp [disp]
-			edge.TargetAddr = uint64(arg.Disp)
+			// Absolute address: call/jmp [disp]. Negative displacements
+			// address the kernel's top 2 GiB (-mcmodel=kernel).
+			edge.TargetAddr = uint64(disp)
 			edge.AddressMode = AddressingModeAbsolute
 			edge.Confidence = baseConfidence
 			return edge
@@ -164,12 +264,15 @@ func extractTargetAMD64(inst x86asm.Inst, sourceAddr uint64, cfType CallSiteType
 	}
 }
 
-func detectCallSitesARM64(code []byte, baseAddr uint64) ([]CallSiteEdge, error) {
+func detectCallSitesARM64(code []byte, baseAddr uint64, wrap bool, b *budget) ([]CallSiteEdge, error) {
 	var result []CallSiteEdge
 
 	const insnLen = 4
 
 	for offset := 0; offset+insnLen <= len(code); offset += insnLen {
+		if err := b.step(offset); err != nil {
+			return nil, err
+		}
 		inst, err := arm64asm.Decode(code[offset : offset+insnLen])
 		if err != nil {
 			continue
@@ -178,7 +281,7 @@ func detectCallSitesARM64(code []byte, baseAddr uint64) ([]CallSiteEdge, error)
 
 		switch inst.Op {
 		case arm64asm.BL:
-			if edge := extractTargetARM64(inst, addr, CallSiteCall, ConfidenceHigh); edge != nil {
+			if edge := extractTargetARM64(inst, addr, CallSiteCall, ConfidenceHigh, wrap); edge != nil {
 				result = append(result, *edge)
 			}
 		case arm64asm.B:
@@ -186,13 +289,10 @@ func detectCallSitesARM64(code []byte, baseAddr uint64) ([]CallSiteEdge, error)
 			// they are usually intra-function branches (low confidence).
 			// Unconditional B may be a tail call (medium confidence).
 			conf := ConfidenceMedium
-			for _, arg := range inst.Args {
-				if _, ok := arg.(arm64asm.Cond); ok {
-					conf = ConfidenceLow
-					break
-				}
+			if isConditionalARM64(inst) {
+				conf = ConfidenceLow
 			}
-			if edge := extractTargetARM64(inst, addr, CallSiteJump, conf); edge != nil {
+			if edge := extractTargetARM64(inst, addr, CallSiteJump, conf, wrap); edge != nil {
 				result = append(result, *edge)
 			}
 		}
@@ -202,15 +302,20 @@ func detectCallSitesARM64(code []byte, baseAddr uint64) ([]CallSiteEdge, error)
 }
 
 // extractTargetARM64 extracts the PC-relative branch target from an ARM64
-// BL or B instruction. Returns nil if the first argument is not a PCRel offset.
-func extractTargetARM64(inst arm64asm.Inst, sourceAddr uint64, cfType CallSiteType, confidence Confidence) *CallSiteEdge {
+// BL or B instruction. Returns nil if the first argument is not a PCRel offset
+// or the target wraps around the address space and wrap is not set.
+func extractTargetARM64(inst arm64asm.Inst, sourceAddr uint64, cfType CallSiteType, confidence Confidence, wrap bool) *CallSiteEdge {
 	pcrel, ok := inst.Args[0].(arm64asm.PCRel)
 	if !ok {
 		return nil
 	}
+	target, ok := relTarget(sourceAddr, int64(pcrel), wrap)
+	if !ok {
+		return nil
+	}
 	return &CallSiteEdge{
 		SourceAddr:  sourceAddr,
-		TargetAddr:  sourceAddr + uint64(int64(pcrel)),
+		TargetAddr:  target,
 		Type:        cfType,
 		AddressMode: AddressingModePCRelative,
 		Confidence:  confidence,
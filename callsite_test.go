@@ -2,9 +2,11 @@ package resurgo_test
 
 import (
 	"debug/elf"
+	"encoding/binary"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/maxgio92/resurgo"
@@ -324,6 +326,246 @@ func TestDetectCallSitesARM64_B(t *testing.T) {
 	}
 }
 
+func TestDetectCallSitesX86(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       []byte
+		baseAddr   uint64
+		opts       []resurgo.Option
+		wantCount  int
+		wantType   resurgo.CallSiteType
+		wantMode   resurgo.AddressingMode
+		wantTarget uint64
+	}{{
+		// call rel32 +0x10
+		name:       "call-rel32",
+		code:       []byte{0xe8, 0x10, 0x00, 0x00, 0x00},
+		baseAddr:   0x8049000,
+		wantCount:  1,
+		wantType:   resurgo.CallSiteCall,
+		wantMode:   resurgo.AddressingModePCRelative,
+		wantTarget: 0x8049015,
+	}, {
+		// call [0x804c010] - in 32-bit mode the displacement is an
+		// absolute address, not RIP-relative.
+		name:       "call-absolute",
+		code:       []byte{0xff, 0x15, 0x10, 0xc0, 0x04, 0x08},
+		baseAddr:   0x8049000,
+		wantCount:  1,
+		wantType:   resurgo.CallSiteCall,
+		wantMode:   resurgo.AddressingModeAbsolute,
+		wantTarget: 0x804c010,
+	}, {
+		// jmp rel32 +0x20 crossing the top of the 32-bit address space.
+		name:      "jmp-wraps-4g",
+		code:      []byte{0xe9, 0x20, 0x00, 0x00, 0x00},
+		baseAddr:  0xfffffff0,
+		wantCount: 0,
+	}, {
+		name:       "jmp-wraps-4g-allowed",
+		code:       []byte{0xe9, 0x20, 0x00, 0x00, 0x00},
+		baseAddr:   0xfffffff0,
+		opts:       []resurgo.Option{resurgo.WithAddressWrap(true)},
+		wantCount:  1,
+		wantType:   resurgo.CallSiteJump,
+		wantMode:   resurgo.AddressingModePCRelative,
+		wantTarget: 0x15,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edges, err := resurgo.DetectCallSites(tt.code, tt.baseAddr, resurgo.ArchX86, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(edges) != tt.wantCount {
+				t.Fatalf("expected %d edge(s), got %d: %+v", tt.wantCount, len(edges), edges)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+			edge := edges[0]
+			if edge.Type != tt.wantType || edge.AddressMode != tt.wantMode || edge.TargetAddr != tt.wantTarget {
+				t.Errorf("expected %s %s edge to 0x%x, got %+v", tt.wantType, tt.wantMode, tt.wantTarget, edge)
+			}
+		})
+	}
+}
+
+func TestDetectCallSitesARM(t *testing.T) {
+	code := arm64Insn(
+		0xeb000002, // 0x8000: bl +8 (call to 0x8010)
+		0xeafffffd, // 0x8004: b -12 (jump to 0x8000)
+		0x0a000000, // 0x8008: beq +0 (jump to 0x8010)
+		0xfb000000, // 0x800c: blx +0, H=1 (call to Thumb code at 0x8016)
+		0xe12fff33, // 0x8010: blx r3 (not resolvable)
+	)
+	want := []resurgo.CallSiteEdge{
+		{SourceAddr: 0x8000, TargetAddr: 0x8010, Type: resurgo.CallSiteCall, AddressMode: resurgo.AddressingModePCRelative, Confidence: resurgo.ConfidenceHigh},
+		{SourceAddr: 0x8004, TargetAddr: 0x8000, Type: resurgo.CallSiteJump, AddressMode: resurgo.AddressingModePCRelative, Confidence: resurgo.ConfidenceMedium},
+		{SourceAddr: 0x8008, TargetAddr: 0x8010, Type: resurgo.CallSiteJump, AddressMode: resurgo.AddressingModePCRelative, Confidence: resurgo.ConfidenceLow},
+		{SourceAddr: 0x800c, TargetAddr: 0x8016, Type: resurgo.CallSiteCall, AddressMode: resurgo.AddressingModePCRelative, Confidence: resurgo.ConfidenceHigh},
+	}
+
+	edges, err := resurgo.DetectCallSites(code, 0x8000, resurgo.ArchARM)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(edges, want) {
+		t.Errorf("got edges %+v, want %+v", edges, want)
+	}
+}
+
+func TestDetectCallSitesThumb(t *testing.T) {
+	code := []byte{
+		0x00, 0xf0, 0x0b, 0xf8, // 0x0: bl 0x1a
+		0xff, 0xf7, 0xfc, 0xbf, // 0x4: b.w 0x0
+		0x00, 0xf0, 0x07, 0x80, // 0x8: beq.w 0x1a
+		0xf8, 0xe7, // 0xc: b 0x0
+		0x04, 0xd1, // 0xe: bne 0x1a
+		0x00, 0xf0, 0x04, 0xe8, // 0x10: blx 0x1c (A32)
+		0x00, 0xbf, // 0x14: nop
+		0x00, 0xbf, // 0x16: nop
+		0x00, 0xbf, // 0x18: nop
+		0x70, 0x47, // 0x1a: bx lr
+	}
+	pcrel := resurgo.AddressingModePCRelative
+	want := []resurgo.CallSiteEdge{
+		{SourceAddr: 0x0, TargetAddr: 0x1a, Type: resurgo.CallSiteCall, AddressMode: pcrel, Confidence: resurgo.ConfidenceHigh},
+		{SourceAddr: 0x4, TargetAddr: 0x0, Type: resurgo.CallSiteJump, AddressMode: pcrel, Confidence: resurgo.ConfidenceMedium},
+		{SourceAddr: 0x8, TargetAddr: 0x1a, Type: resurgo.CallSiteJump, AddressMode: pcrel, Confidence: resurgo.ConfidenceLow},
+		{SourceAddr: 0xc, TargetAddr: 0x0, Type: resurgo.CallSiteJump, AddressMode: pcrel, Confidence: resurgo.ConfidenceMedium},
+		{SourceAddr: 0xe, TargetAddr: 0x1a, Type: resurgo.CallSiteJump, AddressMode: pcrel, Confidence: resurgo.ConfidenceLow},
+		{SourceAddr: 0x10, TargetAddr: 0x1c, Type: resurgo.CallSiteCall, AddressMode: pcrel, Confidence: resurgo.ConfidenceHigh},
+	}
+
+	edges, err := resurgo.DetectCallSites(code, 0, resurgo.ArchThumb)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(edges, want) {
+		t.Errorf("got edges %+v, want %+v", edges, want)
+	}
+}
+
+func TestDetectCallSitesRISCV64(t *testing.T) {
+	code := arm64Insn(
+		0x010000ef, // 0x10000: jal ra, +16 (call)
+		0xff9ff06f, // 0x10004: j -8
+		0x00001097, // 0x10008: auipc ra, 0x1
+		0xffc080e7, // 0x1000c: jalr ra, -4(ra) (call)
+		0x00000317, // 0x10010: auipc t1, 0
+		0x00830067, // 0x10014: jr 8(t1) (tail call)
+	)
+	code = append(code, 0x01, 0x00, 0xe5, 0xbf) // 0x10018: c.nop; 0x1001a: c.j -8
+	want := []resurgo.CallSiteEdge{
+		{SourceAddr: 0x10000, TargetAddr: 0x10010, Type: resurgo.CallSiteCall, AddressMode: resurgo.AddressingModePCRelative, Confidence: resurgo.ConfidenceHigh},
+		{SourceAddr: 0x10004, TargetAddr: 0xfffc, Type: resurgo.CallSiteJump, AddressMode: resurgo.AddressingModePCRelative, Confidence: resurgo.ConfidenceMedium},
+		{SourceAddr: 0x10008, TargetAddr: 0x11004, Type: resurgo.CallSiteCall, AddressMode: resurgo.AddressingModePCRelative, Confidence: resurgo.ConfidenceHigh},
+		{SourceAddr: 0x10010, TargetAddr: 0x10018, Type: resurgo.CallSiteJump, AddressMode: resurgo.AddressingModePCRelative, Confidence: resurgo.ConfidenceMedium},
+		{SourceAddr: 0x1001a, TargetAddr: 0x10012, Type: resurgo.CallSiteJump, AddressMode: resurgo.AddressingModePCRelative, Confidence: resurgo.ConfidenceMedium},
+	}
+
+	edges, err := resurgo.DetectCallSites(code, 0x10000, resurgo.ArchRISCV64)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(edges, want) {
+		t.Errorf("got edges %+v, want %+v", edges, want)
+	}
+}
+
+func TestDetectCallSitesPPC64LE(t *testing.T) {
+	code := arm64Insn(
+		0x48000025, // 0x10000: bl +0x24
+		0x4bfffffc, // 0x10004: b -4
+		0x41820008, // 0x10008: beq +8
+		0x4200fff4, // 0x1000c: bdnz -12
+		0x42800008, // 0x10010: bc 20, 0, +8 (always)
+		0x4e800020, // 0x10014: blr
+	)
+	pcrel := resurgo.AddressingModePCRelative
+	want := []resurgo.CallSiteEdge{
+		{SourceAddr: 0x10000, TargetAddr: 0x10024, Type: resurgo.CallSiteCall, AddressMode: pcrel, Confidence: resurgo.ConfidenceHigh},
+		{SourceAddr: 0x10004, TargetAddr: 0x10000, Type: resurgo.CallSiteJump, AddressMode: pcrel, Confidence: resurgo.ConfidenceMedium},
+		{SourceAddr: 0x10008, TargetAddr: 0x10010, Type: resurgo.CallSiteJump, AddressMode: pcrel, Confidence: resurgo.ConfidenceLow},
+		{SourceAddr: 0x1000c, TargetAddr: 0x10000, Type: resurgo.CallSiteJump, AddressMode: pcrel, Confidence: resurgo.ConfidenceLow},
+		{SourceAddr: 0x10010, TargetAddr: 0x10018, Type: resurgo.CallSiteJump, AddressMode: pcrel, Confidence: resurgo.ConfidenceMedium},
+	}
+
+	edges, err := resurgo.DetectCallSites(code, 0x10000, resurgo.ArchPPC64LE)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(edges, want) {
+		t.Errorf("got edges %+v, want %+v", edges, want)
+	}
+}
+
+func TestDetectCallSitesXtensa(t *testing.T) {
+	code := []byte{
+		0xe5, 0x01, 0x00, // 0x0: call8 0x20
+		0xc5, 0x01, 0x00, // 0x3: call0 0x20 (from the word-aligned 0x0)
+		0x86, 0xfd, 0xff, // 0x6: j 0x0
+		0x16, 0x32, 0x01, // 0x9: beqz a2, 0x20
+		0x37, 0x92, 0xf0, // 0xc: bne a2, a3, 0x0
+		0xcc, 0xd2, // 0xf: bnez.n a2, 0x20
+		0x76, 0x82, 0x08, // 0x11: loop a2, 0x1d (not an edge)
+		0xe0, 0x02, 0x00, // 0x14: callx8 a2 (indirect)
+		0x0d, 0xf0, // 0x17: ret.n
+		0x3d, 0xf0, // 0x19: nop.n
+		0xf0, 0x20, 0x00, // 0x1b: nop
+		0x3d, 0xf0, // 0x1e: nop.n
+		0x36, 0x41, 0x00, // 0x20: entry a1, 32
+		0x1d, 0xf0, // 0x23: retw.n
+	}
+	pcrel := resurgo.AddressingModePCRelative
+	want := []resurgo.CallSiteEdge{
+		{SourceAddr: 0x40080000, TargetAddr: 0x40080020, Type: resurgo.CallSiteCall, AddressMode: pcrel, Confidence: resurgo.ConfidenceHigh},
+		{SourceAddr: 0x40080003, TargetAddr: 0x40080020, Type: resurgo.CallSiteCall, AddressMode: pcrel, Confidence: resurgo.ConfidenceHigh},
+		{SourceAddr: 0x40080006, TargetAddr: 0x40080000, Type: resurgo.CallSiteJump, AddressMode: pcrel, Confidence: resurgo.ConfidenceMedium},
+		{SourceAddr: 0x40080009, TargetAddr: 0x40080020, Type: resurgo.CallSiteJump, AddressMode: pcrel, Confidence: resurgo.ConfidenceLow},
+		{SourceAddr: 0x4008000c, TargetAddr: 0x40080000, Type: resurgo.CallSiteJump, AddressMode: pcrel, Confidence: resurgo.ConfidenceLow},
+		{SourceAddr: 0x4008000f, TargetAddr: 0x40080020, Type: resurgo.CallSiteJump, AddressMode: pcrel, Confidence: resurgo.ConfidenceLow},
+	}
+
+	edges, err := resurgo.DetectCallSites(code, 0x40080000, resurgo.ArchXtensa)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(edges, want) {
+		t.Errorf("got edges %+v, want %+v", edges, want)
+	}
+}
+
+func TestDetectCallSitesByteOrder(t *testing.T) {
+	// bl +0x24, with big-endian words as stored on ppc64 and byte-swapped
+	// on ARM64.
+	ppc64 := []byte{0x48, 0x00, 0x00, 0x25}
+	arm64 := []byte{0x94, 0x00, 0x00, 0x09}
+
+	tests := []struct {
+		name string
+		code []byte
+		arch resurgo.Arch
+		opts []resurgo.Option
+	}{
+		{"ppc64", ppc64, resurgo.ArchPPC64, nil},
+		{"arm64/big-endian", arm64, resurgo.ArchARM64, []resurgo.Option{resurgo.WithByteOrder(binary.BigEndian)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edges, err := resurgo.DetectCallSites(tt.code, 0x1000, tt.arch, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(edges) != 1 || edges[0].TargetAddr != 0x1024 || edges[0].Type != resurgo.CallSiteCall {
+				t.Errorf("expected a call to 0x1024, got %+v", edges)
+			}
+		})
+	}
+}
+
 func TestDetectCallSites_UnsupportedArch(t *testing.T) {
 	_, err := resurgo.DetectCallSites([]byte{0x00}, 0, resurgo.Arch("mips"))
 	if err == nil {
@@ -427,6 +669,97 @@ func TestDetectCallSites_JumpTarget(t *testing.T) {
 	}
 }
 
+func TestDetectCallSites_HighHalf(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       []byte
+		baseAddr   uint64
+		arch       resurgo.Arch
+		opts       []resurgo.Option
+		wantTarget uint64
+		wantFound  bool
+	}{{
+		// call -0x1000 from kernel text.
+		name:       "amd64/kernel",
+		code:       []byte{0xe8, 0x00, 0xf0, 0xff, 0xff},
+		baseAddr:   0xffffffff81000000,
+		arch:       resurgo.ArchAMD64,
+		wantTarget: 0xffffffff80fff005,
+		wantFound:  true,
+	}, {
+		// call [disp32]: the sign-extended absolute form used by
+		// -mcmodel=kernel.
+		name:       "amd64/kernel-absolute",
+		code:       []byte{0xff, 0x14, 0x25, 0x00, 0x00, 0x00, 0x81},
+		baseAddr:   0xffffffff81000000,
+		arch:       resurgo.ArchAMD64,
+		wantTarget: 0xffffffff81000000,
+		wantFound:  true,
+	}, {
+		// call [rip-0x1000]
+		name:       "amd64/kernel-rip-relative",
+		code:       []byte{0xff, 0x15, 0x00, 0xf0, 0xff, 0xff},
+		baseAddr:   0xffffffff81000000,
+		arch:       resurgo.ArchAMD64,
+		wantTarget: 0xffffffff80fff006,
+		wantFound:  true,
+	}, {
+		// call +0x100 from the top of the address space.
+		name:      "amd64/wrap",
+		code:      []byte{0xe8, 0x00, 0x01, 0x00, 0x00},
+		baseAddr:  0xfffffffffffffff0,
+		arch:      resurgo.ArchAMD64,
+		wantFound: false,
+	}, {
+		name:       "amd64/wrap-enabled",
+		code:       []byte{0xe8, 0x00, 0x01, 0x00, 0x00},
+		baseAddr:   0xfffffffffffffff0,
+		arch:       resurgo.ArchAMD64,
+		opts:       []resurgo.Option{resurgo.WithAddressWrap(true)},
+		wantTarget: 0xf5,
+		wantFound:  true,
+	}, {
+		// bl -0x1000 from kernel text.
+		name:       "arm64/kernel",
+		code:       arm64Insn(0x97fffc00),
+		baseAddr:   0xffff800008010000,
+		arch:       resurgo.ArchARM64,
+		wantTarget: 0xffff80000800f000,
+		wantFound:  true,
+	}, {
+		// bl -0x1000 from the bottom of the address space.
+		name:      "arm64/wrap",
+		code:      arm64Insn(0x97fffc00),
+		baseAddr:  0x10,
+		arch:      resurgo.ArchARM64,
+		wantFound: false,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edges, err := resurgo.DetectCallSites(tt.code, tt.baseAddr, tt.arch, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.wantFound {
+				if len(edges) != 0 {
+					t.Errorf("expected no edges, got %+v", edges)
+				}
+				return
+			}
+			if len(edges) != 1 {
+				t.Fatalf("expected 1 edge, got %+v", edges)
+			}
+			if edges[0].SourceAddr != tt.baseAddr {
+				t.Errorf("expected source 0x%x, got 0x%x", tt.baseAddr, edges[0].SourceAddr)
+			}
+			if edges[0].TargetAddr != tt.wantTarget {
+				t.Errorf("expected target 0x%x, got 0x%x", tt.wantTarget, edges[0].TargetAddr)
+			}
+		})
+	}
+}
+
 func TestDetectCallSitesARM64_BConditional(t *testing.T) {
 	// ARM64 B.EQ (conditional branch):
 	// B.cond has encoding 0x54000000 | (imm19 << 5) | cond
@@ -0,0 +1,370 @@
+package resurgo
+
+import (
+	"cmp"
+	"debug/elf"
+	"fmt"
+	"slices"
+)
+
+const (
+	// DWARF call frame instruction opcodes (DW_CFA_*). The high two bits
+	// select the primary opcodes that embed their first operand; the
+	// extended opcodes use the low six bits with the high bits clear.
+	dwCFAAdvanceLoc        = byte(0x40)
+	dwCFAOffset            = byte(0x80)
+	dwCFARestore           = byte(0xc0)
+	dwCFANop               = byte(0x00)
+	dwCFASetLoc            = byte(0x01)
+	dwCFAAdvanceLoc1       = byte(0x02)
+	dwCFAAdvanceLoc2       = byte(0x03)
+	dwCFAAdvanceLoc4       = byte(0x04)
+	dwCFAOffsetExtended    = byte(0x05)
+	dwCFARestoreExtended   = byte(0x06)
+	dwCFAUndefined         = byte(0x07)
+	dwCFASameValue         = byte(0x08)
+	dwCFARegister          = byte(0x09)
+	dwCFARememberState     = byte(0x0a)
+	dwCFARestoreState      = byte(0x0b)
+	dwCFADefCFA            = byte(0x0c)
+	dwCFADefCFARegister    = byte(0x0d)
+	dwCFADefCFAOffset      = byte(0x0e)
+	dwCFADefCFAExpression  = byte(0x0f)
+	dwCFAExpression        = byte(0x10)
+	dwCFAOffsetExtendedSF  = byte(0x11)
+	dwCFADefCFASF          = byte(0x12)
+	dwCFADefCFAOffsetSF    = byte(0x13)
+	dwCFAValOffset         = byte(0x14)
+	dwCFAValOffsetSF       = byte(0x15)
+	dwCFAValExpression     = byte(0x16)
+	dwCFANegateRAState     = byte(0x2d) // AArch64 pointer authentication
+	dwCFAGNUArgsSize       = byte(0x2e)
+	dwCFAGNUNegOffsetExtnd = byte(0x2f)
+
+	// DWARF register numbers of the stack and frame pointers.
+	dwarfRegRBP = 6
+	dwarfRegRSP = 7
+	dwarfRegX29 = 29
+	dwarfRegSP  = 31
+)
+
+// cfaRule is the canonical frame address rule in effect from pc onwards:
+// CFA = reg + offset. expr is set when the CFA is computed by a DWARF
+// expression, which the evaluator does not interpret.
+type cfaRule struct {
+	pc     uint64
+	reg    uint64
+	offset int64
+	expr   bool
+}
+
+// cfaRules evaluates the CIE initial instructions followed by the FDE
+// instructions of fde and returns the CFA rule rows in address order. Only
+// the CFA rule is tracked; register rules are parsed and discarded.
+// Evaluation stops at the first unknown or truncated instruction, keeping
+// the rows decoded so far. The rules are unknown, and nil is returned, when
+// the CIE initial instructions fail to evaluate.
+func cfaRules(fde fdeInfo) []cfaRule {
+	cur := cfaRule{pc: fde.start}
+	var rows []cfaRule
+	var stack []cfaRule
+
+	run := func(insns []byte) bool {
+		off := 0
+		uleb := func() (uint64, bool) {
+			v, n := readULEB128(insns, off)
+			if n < 0 {
+				return 0, false
+			}
+			off += n
+			return v, true
+		}
+		sleb := func() (int64, bool) {
+			v, n := readSLEB128(insns, off)
+			if n < 0 {
+				return 0, false
+			}
+			off += n
+			return v, true
+		}
+		fixed := func(size int) (uint64, bool) {
+			if off+size > len(insns) {
+				return 0, false
+			}
+			var v uint64
+			// Call frame instructions use the ELF byte order; all
+			// supported targets are little-endian.
+			for i := size - 1; i >= 0; i-- {
+				v = v<<8 | uint64(insns[off+i])
+			}
+			off += size
+			return v, true
+		}
+		advance := func(delta uint64) {
+			rows = append(rows, cur)
+			cur.pc += delta * fde.cie.codeAlign
+		}
+
+		for off < len(insns) {
+			op := insns[off]
+			off++
+			switch op & 0xc0 {
+			case dwCFAAdvanceLoc:
+				advance(uint64(op & 0x3f))
+				continue
+			case dwCFAOffset:
+				if _, ok := uleb(); !ok {
+					return false
+				}
+				continue
+			case dwCFARestore:
+				continue
+			}
+
+			ok := true
+			switch op {
+			case dwCFANop, dwCFANegateRAState:
+			case dwCFARememberState:
+				stack = append(stack, cur)
+			case dwCFARestoreState:
+				if len(stack) > 0 {
+					pc := cur.pc
+					cur = stack[len(stack)-1]
+					cur.pc = pc
+					stack = stack[:len(stack)-1]
+				}
+			case dwCFASetLoc:
+				// Absolute locations are only used with absptr encodings
+				// that the parser does not produce; stop here.
+				return false
+			case dwCFAAdvanceLoc1, dwCFAAdvanceLoc2, dwCFAAdvanceLoc4:
+				// The delta is 1, 2 or 4 bytes wide for opcodes 0x02-0x04.
+				var d uint64
+				if d, ok = fixed(1 << (op - dwCFAAdvanceLoc1)); ok {
+					advance(d)
+				}
+			case dwCFAOffsetExtended, dwCFARegister, dwCFAValOffset, dwCFAGNUNegOffsetExtnd:
+				if _, ok = uleb(); ok {
+					_, ok = uleb()
+				}
+			case dwCFAOffsetExtendedSF, dwCFAValOffsetSF:
+				if _, ok = uleb(); ok {
+					_, ok = sleb()
+				}
+			case dwCFARestoreExtended, dwCFAUndefined, dwCFASameValue, dwCFAGNUArgsSize:
+				_, ok = uleb()
+			case dwCFADefCFA:
+				var reg, o uint64
+				if reg, ok = uleb(); ok {
+					if o, ok = uleb(); ok {
+						cur.reg, cur.offset, cur.expr = reg, int64(o), false
+					}
+				}
+			case dwCFADefCFASF:
+				var reg uint64
+				var o int64
+				if reg, ok = uleb(); ok {
+					if o, ok = sleb(); ok {
+						cur.reg, cur.offset, cur.expr = reg, o*fde.cie.dataAlign, false
+					}
+				}
+			case dwCFADefCFARegister:
+				var reg uint64
+				if reg, ok = uleb(); ok {
+					cur.reg = reg
+				}
+			case dwCFADefCFAOffset:
+				var o uint64
+				if o, ok = uleb(); ok {
+					cur.offset = int64(o)
+				}
+			case dwCFADefCFAOffsetSF:
+				var o int64
+				if o, ok = sleb(); ok {
+					cur.offset = o * fde.cie.dataAlign
+				}
+			case dwCFADefCFAExpression:
+				var n uint64
+				if n, ok = uleb(); ok && n <= uint64(len(insns)-off) {
+					off += int(n)
+					cur.expr = true
+				} else {
+					ok = false
+				}
+			case dwCFAExpression, dwCFAValExpression:
+				var n uint64
+				if _, ok = uleb(); ok {
+					if n, ok = uleb(); ok && n <= uint64(len(insns)-off) {
+						off += int(n)
+					} else {
+						ok = false
+					}
+				}
+			default:
+				return false
+			}
+			if !ok || off > len(insns) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if !run(fde.cie.initialInsns) {
+		return nil
+	}
+	// The initial instructions describe the state at fde.start; any rows
+	// they produced are discarded.
+	rows = rows[:0]
+	cur.pc = fde.start
+	run(fde.insns)
+	return append(rows, cur)
+}
+
+// cfaRuleAt returns the CFA rule in effect at pc.
+func cfaRuleAt(rows []cfaRule, pc uint64) cfaRule {
+	rule := rows[0]
+	for _, r := range rows {
+		if r.pc > pc {
+			break
+		}
+		rule = r
+	}
+	return rule
+}
+
+// CFIConsistencyFilter validates prologue-bearing candidates against the
+// unwind information in .eh_frame and demotes those that contradict it to
+// ConfidenceLow. A candidate contradicts the unwind information when:
+//
+//   - it lies strictly inside the address range of an FDE, so the compiler
+//     recorded a function start elsewhere; or
+//   - it starts an FDE but the stack effect of its prologue (pushes, stack
+//     allocation, frame pointer setup) disagrees with the CFA rule the FDE
+//     establishes at the end of the prologue.
+//
+// Both situations indicate a misdecoded entry. Candidates without a
+// PrologueType, and all candidates when .eh_frame is absent, are returned
+// unchanged. The filter only demotes; it never removes candidates. It is not
+// part of the default pipeline; when combined with EhFrameFilter it must run
+// after it, since EhFrameFilter upgrades every confirmed candidate.
+func CFIConsistencyFilter(candidates []FunctionCandidate, f *elf.File) ([]FunctionCandidate, error) {
+	fdes, err := parseEhFrameFDEs(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse .eh_frame: %w", err)
+	}
+	if len(fdes) == 0 {
+		return candidates, nil
+	}
+	var arch Arch
+	switch f.Machine {
+	case elf.EM_X86_64:
+		arch = ArchAMD64
+	case elf.EM_AARCH64:
+		arch = ArchARM64
+	default:
+		return candidates, nil
+	}
+	sections, err := elfCodeSections(f, arch, nil)
+	if err != nil {
+		return candidates, nil
+	}
+
+	slices.SortFunc(fdes, func(a, b fdeInfo) int {
+		switch {
+		case a.start < b.start:
+			return -1
+		case a.start > b.start:
+			return 1
+		}
+		return 0
+	})
+
+	for i := range candidates {
+		c := &candidates[i]
+		if c.PrologueType == "" {
+			continue
+		}
+		idx, found := slices.BinarySearchFunc(fdes, c.Address, func(fde fdeInfo, addr uint64) int {
+			switch {
+			case fde.start < addr:
+				return -1
+			case fde.start > addr:
+				return 1
+			}
+			return 0
+		})
+		if !found {
+			// Inside the previous FDE's range: a mid-function match.
+			if idx > 0 && c.Address < fdes[idx-1].end {
+				c.Confidence = ConfidenceLow
+			}
+			continue
+		}
+		p, ok := prologueAt(sections, c.Address, c.PrologueType, arch)
+		if !ok {
+			continue
+		}
+		rows := cfaRules(fdes[idx])
+		if rows == nil {
+			continue
+		}
+		if !cfaConsistent(p, cfaRuleAt(rows, p.Address+p.Size), arch) {
+			c.Confidence = ConfidenceLow
+		}
+	}
+	return candidates, nil
+}
+
+// prologueWindow is the number of bytes decoded at a candidate address to
+// recover its prologue record. It covers the longest entry sequence.
+const prologueWindow = 64
+
+// prologueAt re-runs prologue detection on the bytes at addr in sections
+// and returns the record of type typ starting there.
+func prologueAt(sections []codeSection, addr uint64, typ PrologueType, arch Arch) (Prologue, bool) {
+	sec, ok := regionAt(sections, addr)
+	if !ok {
+		return Prologue{}, false
+	}
+	off := addr - sec.addr
+	end := min(off+prologueWindow, uint64(len(sec.code)))
+	prologues, err := DetectPrologues(sec.code[off:end], addr, cmp.Or(sec.arch, arch))
+	if err != nil {
+		return Prologue{}, false
+	}
+	for _, p := range prologues {
+		if p.Address == addr && p.Type == typ {
+			return p, true
+		}
+	}
+	return Prologue{}, false
+}
+
+// cfaConsistent reports whether the stack effect of p agrees with rule, the
+// CFA rule in effect right after the prologue. A frame-pointer-based CFA is
+// accepted as consistent for any prologue that sets the frame pointer up.
+// CFA expressions cannot be checked and are accepted.
+func cfaConsistent(p Prologue, rule cfaRule, arch Arch) bool {
+	if rule.expr || p.Size == 0 {
+		return true
+	}
+	switch arch {
+	case ArchAMD64:
+		switch rule.reg {
+		case dwarfRegRBP:
+			return slices.Contains(p.SavedRegs, "rbp")
+		case dwarfRegRSP:
+			// The CALL pushed the 8-byte return address.
+			return rule.offset == int64(8+p.FrameSize)
+		}
+	case ArchARM64:
+		switch rule.reg {
+		case dwarfRegX29:
+			return slices.Contains(p.SavedRegs, "x29")
+		case dwarfRegSP:
+			return rule.offset == int64(p.FrameSize)
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package resurgo
+
+import (
+	"cmp"
+	"debug/elf"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// BasicBlock is a sequence of instructions of a function that is only
+// entered at its first instruction and only left after its last.
+type BasicBlock struct {
+	// Start is the address of the first instruction of the block.
+	Start uint64 `json:"start"`
+	// End is the address following the last instruction of the block.
+	End uint64 `json:"end"`
+	// Successors holds the start of the blocks of the function control
+	// flows to from the block, the target of its branch before the
+	// instruction following it.
+	Successors []uint64 `json:"successors,omitempty"`
+	// Exit reports whether control leaves the function from the block: at
+	// a return, a trap, an indirect jump, whose targets are unknown, or a
+	// jump out of the function, e.g. a tail call.
+	Exit bool `json:"exit,omitempty"`
+}
+
+// CFG is the control-flow graph of a function: its basic blocks and the
+// edges between them.
+type CFG struct {
+	// Entry is the address of the function entry, the start of its first
+	// block.
+	Entry uint64 `json:"entry"`
+	// Blocks holds the blocks reached from the entry, ordered by address.
+	Blocks []BasicBlock `json:"blocks"`
+}
+
+// Complexity returns the cyclomatic complexity of g, the number of its
+// edges less the number of its blocks plus two, or zero if g is empty.
+func (g CFG) Complexity() int {
+	if len(g.Blocks) == 0 {
+		return 0
+	}
+	edges := 0
+	for _, b := range g.Blocks {
+		edges += len(b.Successors)
+	}
+	return edges - len(g.Blocks) + 2
+}
+
+// BuildCFG recovers the control-flow graph of the function whose code is
+// code, mapped at baseAddr, where it is entered. The instructions reached
+// from the entry are followed through fall-through and direct branches
+// within code; calls do not end a block. Only x86 and ARM64 code is
+// supported. opts may include WithByteOrder or WithLimits.
+func BuildCFG(code []byte, baseAddr uint64, arch Arch, opts ...Option) (CFG, error) {
+	if !descentArch(arch) {
+		return CFG{}, fmt.Errorf("unsupported architecture: %s", arch)
+	}
+	o := newOptions(opts...).withBudget()
+	if err := o.budget.codeSize(uint64(len(code))); err != nil {
+		return CFG{}, err
+	}
+	code, err := o.littleEndianCode(code, arch)
+	if err != nil {
+		return CFG{}, err
+	}
+	return o.buildCFG(code, baseAddr, arch)
+}
+
+// BuildCFGsFromELF recovers the control-flow graph of each of candidates,
+// e.g. those of DetectFunctionsFromELF, in f, as BuildCFG does, and returns
+// them ordered by entry. The code of a candidate runs up to its End, as
+// estimated with WithFunctionSizes, or, when End is unknown, up to the end
+// of the code before the next candidate. Only x86 and ARM64 files are
+// analyzed; others return no graphs. opts may include WithLimits.
+func BuildCFGsFromELF(f *elf.File, candidates []FunctionCandidate, opts ...Option) ([]CFG, error) {
+	arch, err := elfArch(f)
+	if err != nil {
+		return nil, err
+	}
+	if !descentArch(arch) {
+		return nil, nil
+	}
+	o := newOptions(opts...).withBudget()
+	regions, err := elfCodeSections(f, arch, o.budget)
+	if err != nil {
+		return nil, err
+	}
+	o = o.withByteOrder(elfCodeByteOrder(f, arch))
+
+	sized := slices.Clone(candidates)
+	if err := o.setEnds(sized, regions, arch); err != nil {
+		return nil, err
+	}
+	ends := make(map[uint64]uint64, len(candidates))
+	for i, c := range candidates {
+		ends[c.Address] = cmp.Or(c.End, sized[i].End)
+	}
+	for i, region := range regions {
+		if regions[i].code, err = o.littleEndianCode(region.code, arch); err != nil {
+			return nil, err
+		}
+	}
+
+	var graphs []CFG
+	for _, entry := range slices.Sorted(maps.Keys(ends)) {
+		region, ok := regionAt(regions, entry)
+		if !ok {
+			continue
+		}
+		end := min(ends[entry], region.addr+uint64(len(region.code)))
+		if end <= entry {
+			continue
+		}
+		g, err := o.buildCFG(region.code[entry-region.addr:end-region.addr], entry, arch)
+		if err != nil {
+			return nil, err
+		}
+		graphs = append(graphs, g)
+	}
+	if err := o.budget.results(len(graphs)); err != nil {
+		return nil, err
+	}
+	return graphs, nil
+}
+
+// buildCFG recovers the control-flow graph of the little-endian function
+// code of arch, mapped and entered at baseAddr.
+func (o *options) buildCFG(code []byte, baseAddr uint64, arch Arch) (CFG, error) {
+	end := baseAddr + uint64(len(code))
+	inFunction := func(addr uint64) bool {
+		return addr >= baseAddr && addr < end
+	}
+
+	// steps maps the address of each instruction reached to its flow.
+	steps := make(map[uint64]flowStep)
+	leaders := map[uint64]bool{baseAddr: true}
+	// queued records the addresses pushed on work, apart from the leaders:
+	// the instruction after a branch is a leader without being reached.
+	queued := map[uint64]bool{baseAddr: true}
+	work := []uint64{baseAddr}
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+		for inFunction(addr) {
+			if _, ok := steps[addr]; ok {
+				break
+			}
+			off := int(addr - baseAddr)
+			if err := o.budget.step(off); err != nil {
+				return CFG{}, err
+			}
+			step, ok := decodeFlow(code[off:], addr, arch, o.addressWrap)
+			if !ok {
+				break
+			}
+			steps[addr] = step
+			next := addr + uint64(step.length)
+			if br := step.branch; br != nil && !br.call {
+				if inFunction(br.target) && !queued[br.target] {
+					queued[br.target] = true
+					work = append(work, br.target)
+				}
+				leaders[br.target] = true
+				leaders[next] = true
+			}
+			if !step.falls {
+				break
+			}
+			addr = next
+		}
+	}
+
+	g := CFG{Entry: baseAddr}
+	addrs := slices.Sorted(maps.Keys(steps))
+	var block *BasicBlock
+	for i, addr := range addrs {
+		step := steps[addr]
+		next := addr + uint64(step.length)
+		if block == nil {
+			g.Blocks = append(g.Blocks, BasicBlock{Start: addr})
+			block = &g.Blocks[len(g.Blocks)-1]
+		}
+		jump := step.branch != nil && !step.branch.call
+		// The block also ends before an instruction overlapping this one.
+		contiguous := i+1 < len(addrs) && addrs[i+1] == next
+		if !jump && step.falls && contiguous && !leaders[next] {
+			continue
+		}
+		block.End = next
+		if jump {
+			if _, ok := steps[step.branch.target]; ok {
+				block.Successors = append(block.Successors, step.branch.target)
+			} else if !inFunction(step.branch.target) {
+				block.Exit = true
+			}
+		} else if !step.falls {
+			block.Exit = true
+		}
+		if _, ok := steps[next]; ok && step.falls && !slices.Contains(block.Successors, next) {
+			block.Successors = append(block.Successors, next)
+		}
+		block = nil
+	}
+	return g, nil
+}
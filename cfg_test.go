@@ -0,0 +1,176 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestBuildCFG(t *testing.T) {
+	tests := []struct {
+		name           string
+		code           []byte
+		arch           resurgo.Arch
+		want           []resurgo.BasicBlock
+		wantComplexity int
+	}{{
+		name: "if-else",
+		code: []byte{
+			0x55,             // 0x00: push rbp
+			0x48, 0x89, 0xe5, // 0x01: mov rbp, rsp
+			0x85, 0xff, // 0x04: test edi, edi
+			0x74, 0x07, // 0x06: je 0x0f
+			0xb8, 0x01, 0x00, 0x00, 0x00, // 0x08: mov eax, 1
+			0xeb, 0x05, // 0x0d: jmp 0x14
+			0xb8, 0x02, 0x00, 0x00, 0x00, // 0x0f: mov eax, 2
+			0x5d, // 0x14: pop rbp
+			0xc3, // 0x15: ret
+		},
+		arch: resurgo.ArchAMD64,
+		want: []resurgo.BasicBlock{
+			{Start: 0x00, End: 0x08, Successors: []uint64{0x0f, 0x08}},
+			{Start: 0x08, End: 0x0f, Successors: []uint64{0x14}},
+			{Start: 0x0f, End: 0x14, Successors: []uint64{0x14}},
+			{Start: 0x14, End: 0x16, Exit: true},
+		},
+		wantComplexity: 2,
+	}, {
+		name: "tail call",
+		code: []byte{
+			0x85, 0xff, // 0x00: test edi, edi
+			0x75, 0x01, // 0x02: jne 0x05
+			0xc3,                         // 0x04: ret
+			0xe9, 0x00, 0x00, 0x00, 0x00, // 0x05: jmp 0x0a, out of the function
+		},
+		arch: resurgo.ArchAMD64,
+		want: []resurgo.BasicBlock{
+			{Start: 0x00, End: 0x04, Successors: []uint64{0x05, 0x04}},
+			{Start: 0x04, End: 0x05, Exit: true},
+			{Start: 0x05, End: 0x0a, Exit: true},
+		},
+		wantComplexity: 1,
+	}, {
+		name: "backward branch to a fall-through leader",
+		code: []byte{
+			0xeb, 0x01, // 0x00: jmp 0x03
+			0xc3,       // 0x02: ret
+			0x75, 0xfd, // 0x03: jne 0x02
+			0xc3, // 0x05: ret
+		},
+		arch: resurgo.ArchAMD64,
+		want: []resurgo.BasicBlock{
+			{Start: 0x00, End: 0x02, Successors: []uint64{0x03}},
+			{Start: 0x02, End: 0x03, Exit: true},
+			{Start: 0x03, End: 0x05, Successors: []uint64{0x02, 0x05}},
+			{Start: 0x05, End: 0x06, Exit: true},
+		},
+		wantComplexity: 1,
+	}, {
+		name: "arm64",
+		code: []byte{
+			0x40, 0x00, 0x00, 0x34, // 0x00: cbz w0, 0x08
+			0x20, 0x00, 0x80, 0x52, // 0x04: mov w0, #1
+			0xc0, 0x03, 0x5f, 0xd6, // 0x08: ret
+		},
+		arch: resurgo.ArchARM64,
+		want: []resurgo.BasicBlock{
+			{Start: 0x00, End: 0x04, Successors: []uint64{0x08, 0x04}},
+			{Start: 0x04, End: 0x08, Successors: []uint64{0x08}},
+			{Start: 0x08, End: 0x0c, Exit: true},
+		},
+		wantComplexity: 2,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := resurgo.BuildCFG(tt.code, 0, tt.arch)
+			if err != nil {
+				t.Fatalf("BuildCFG: %v", err)
+			}
+			if !reflect.DeepEqual(g.Blocks, tt.want) {
+				t.Errorf("got blocks %+v, want %+v", g.Blocks, tt.want)
+			}
+			if got := g.Complexity(); got != tt.wantComplexity {
+				t.Errorf("got complexity %d, want %d", got, tt.wantComplexity)
+			}
+		})
+	}
+
+	if _, err := resurgo.BuildCFG([]byte{0x13, 0x00, 0x00, 0x00}, 0, resurgo.ArchRISCV64); err == nil {
+		t.Error("expected an error for an unsupported architecture")
+	}
+}
+
+// cfgSource holds a function with a loop and a branch.
+const cfgSource = `#include <stdio.h>
+__attribute__((noinline)) int count(const char *s) {
+	int n = 0;
+	for (; *s; s++)
+		if (*s == ' ')
+			n++;
+	return n;
+}
+int main(int argc, char **argv) { printf("%d\n", count(argv[0])); return 0; }
+`
+
+// TestBuildCFGsFromELF verifies that the graphs of the functions of a
+// compiled binary are consistent: each is entered at its first block, and
+// every edge leads to one of its blocks.
+func TestBuildCFGsFromELF(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("CFG test requires an amd64 compiler")
+	}
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "cfg.c")
+	if err := os.WriteFile(src, []byte(cfgSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	binPath := filepath.Join(dir, "cfg")
+	if out, err := exec.Command("gcc", "-O2", "-o", binPath, src).CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile: %v\n%s", err, out)
+	}
+	f, err := elf.Open(binPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	candidates, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithFunctionSizes(true))
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromELF: %v", err)
+	}
+	graphs, err := resurgo.BuildCFGsFromELF(f, candidates)
+	if err != nil {
+		t.Fatalf("BuildCFGsFromELF: %v", err)
+	}
+	if len(graphs) == 0 {
+		t.Fatal("no graphs recovered")
+	}
+	looped := false
+	for _, g := range graphs {
+		if len(g.Blocks) == 0 || g.Blocks[0].Start != g.Entry {
+			t.Errorf("0x%x: not entered at its first block: %+v", g.Entry, g.Blocks)
+			continue
+		}
+		for _, b := range g.Blocks {
+			for _, succ := range b.Successors {
+				if !slices.ContainsFunc(g.Blocks, func(b resurgo.BasicBlock) bool { return b.Start == succ }) {
+					t.Errorf("0x%x: edge from 0x%x to 0x%x, not a block", g.Entry, b.Start, succ)
+				}
+				looped = looped || succ <= b.Start
+			}
+		}
+	}
+	if !looped {
+		t.Error("no back edge found for the loop of count")
+	}
+}
@@ -0,0 +1,31 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// sectionData returns the contents of sec, decompressed when it is stored
+// compressed: flagged SHF_COMPRESSED, with zlib or zstd, as distributions
+// ship their .debug_* sections, or in a legacy .zdebug section. Metadata
+// readers use it rather than Section.Data, which allocates the size a
+// compressed section declares before decompressing it: the decompressed
+// contents are bounded by maxDecompressedSize.
+func sectionData(sec *elf.Section) ([]byte, error) {
+	if sec.Flags&elf.SHF_COMPRESSED == 0 && !strings.HasPrefix(sec.Name, ".zdebug") {
+		return sec.Data()
+	}
+	if sec.Size > maxDecompressedSize {
+		return nil, fmt.Errorf("%s: decompressed size %d exceeds %d bytes", sec.Name, sec.Size, maxDecompressedSize)
+	}
+	data, err := io.ReadAll(io.LimitReader(sec.Open(), maxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("decompress %s: %w", sec.Name, err)
+	}
+	if len(data) > maxDecompressedSize {
+		return nil, fmt.Errorf("%s: decompressed size exceeds %d bytes", sec.Name, maxDecompressedSize)
+	}
+	return data, nil
+}
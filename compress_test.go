@@ -0,0 +1,89 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+// compressSection returns a copy of the 64-bit little-endian ELF data whose
+// section name holds contents compressed with zlib behind a compression
+// header declaring size bytes, appended to the file.
+func compressSection(t *testing.T, data []byte, name string, contents []byte, size uint64) []byte {
+	t.Helper()
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse ELF: %v", err)
+	}
+	idx := slices.IndexFunc(f.Sections, func(s *elf.Section) bool { return s.Name == name })
+	if idx < 0 {
+		t.Fatalf("no %s section", name)
+	}
+
+	var payload bytes.Buffer
+	// Elf64_Chdr: ch_type, ch_reserved, ch_size, ch_addralign.
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr, uint32(elf.COMPRESS_ZLIB))
+	binary.LittleEndian.PutUint64(hdr[8:], size)
+	binary.LittleEndian.PutUint64(hdr[16:], 1)
+	payload.Write(hdr)
+	zw := zlib.NewWriter(&payload)
+	zw.Write(contents)
+	zw.Close()
+
+	out := bytes.Clone(data)
+	off := uint64(len(out))
+	out = append(out, payload.Bytes()...)
+	// Elf64_Shdr: sh_flags at 8, sh_offset at 24, sh_size at 32.
+	shdr := out[binary.LittleEndian.Uint64(out[0x28:])+uint64(idx)*64:]
+	flags := binary.LittleEndian.Uint64(shdr[8:])
+	binary.LittleEndian.PutUint64(shdr[8:], flags|uint64(elf.SHF_COMPRESSED))
+	binary.LittleEndian.PutUint64(shdr[24:], off)
+	binary.LittleEndian.PutUint64(shdr[32:], uint64(payload.Len()))
+	return out
+}
+
+func TestCompressedSections(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	path := filepath.Join(t.TempDir(), "demo-app-c")
+	if out, err := exec.Command("gcc", "-O0", "-o", path, "testdata/demo-app.c").CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := []byte("rustc version 1.80.0\x00")
+
+	tests := []struct {
+		name string
+		size uint64
+		want resurgo.Toolchain
+	}{
+		{name: "zlib", size: uint64(len(producer)), want: resurgo.ToolchainRust},
+		// A section declaring a huge decompressed size is not allocated.
+		{name: "oversized", size: 1 << 40, want: resurgo.ToolchainGeneric},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := elf.NewFile(bytes.NewReader(compressSection(t, data, ".comment", producer, tt.size)))
+			if err != nil {
+				t.Fatalf("failed to parse ELF: %v", err)
+			}
+			if got := resurgo.DetectToolchain(f); got != tt.want {
+				t.Errorf("DetectToolchain = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package resurgo
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Archives of files, reported with one member per ELF file they hold, named
+// after its path in the archive. Other files are skipped.
+const (
+	// FormatTar is a tar archive, e.g. a container image layer.
+	FormatTar Format = "tar"
+	// FormatZip is a zip archive.
+	FormatZip Format = "zip"
+)
+
+// isTar reports whether r holds a POSIX or GNU tar archive, which carries
+// "ustar" in the header of its first file.
+func isTar(r io.ReaderAt) bool {
+	magic := make([]byte, 5)
+	_, err := r.ReadAt(magic, 257)
+	return err == nil && string(magic) == "ustar"
+}
+
+// DetectFunctionsFromTar detects the functions of each ELF file of the tar
+// archive read from r, in archive order, without extracting it. Archives
+// compressed with gzip, such as OCI image layers, are decompressed on the
+// fly. Each ELF file runs the full DetectFunctionsFromELF pipeline with
+// opts and is reported as a member named after its path; other files,
+// directories and links are skipped.
+func DetectFunctionsFromTar(r io.Reader, opts ...Option) (*AnalysisResult, error) {
+	br := bufio.NewReader(r)
+	r = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte("\x1f\x8b")) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	result := &AnalysisResult{Format: FormatTar}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar: %w", err)
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		member, err := detectELFMember(tr, hdr.Size, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("tar member %s: %w", hdr.Name, err)
+		}
+		if member != nil {
+			member.Name = hdr.Name
+			result.Members = append(result.Members, *member)
+		}
+	}
+}
+
+// DetectFunctionsFromZip detects the functions of each ELF file of the
+// size-byte zip archive read from r, in archive order, without extracting
+// it. Each ELF file runs the full DetectFunctionsFromELF pipeline with opts
+// and is reported as a member named after its path; other files and
+// directories are skipped.
+func DetectFunctionsFromZip(r io.ReaderAt, size int64, opts ...Option) (*AnalysisResult, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("parse zip: %w", err)
+	}
+	result := &AnalysisResult{Format: FormatZip}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("zip member %s: %w", f.Name, err)
+		}
+		member, err := detectELFMember(rc, int64(f.UncompressedSize64), opts...)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("zip member %s: %w", f.Name, err)
+		}
+		if member != nil {
+			member.Name = f.Name
+			result.Members = append(result.Members, *member)
+		}
+	}
+	return result, nil
+}
+
+// detectELFMember detects the functions of the size-byte archive member read
+// from r if it is an ELF file, and returns nil otherwise. The member is read
+// into memory, as the ELF parser needs random access.
+func detectELFMember(r io.Reader, size int64, opts ...Option) (*AnalysisResult, error) {
+	magic := make([]byte, len(elf.ELFMAG))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	if string(magic) != elf.ELFMAG {
+		return nil, nil
+	}
+	if size < 0 || size > maxDecompressedSize {
+		return nil, fmt.Errorf("ELF file exceeds %d bytes", maxDecompressedSize)
+	}
+	data := make([]byte, size)
+	copy(data, magic)
+	if _, err := io.ReadFull(r, data[len(magic):]); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	return detectELF(bytes.NewReader(data), opts...)
+}
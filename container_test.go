@@ -0,0 +1,145 @@
+package resurgo_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+	"github.com/maxgio92/resurgo/resurgotest"
+)
+
+func TestDetectFunctionsFromTar(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "hello.go")
+	if err := os.WriteFile(src, []byte("package main\n\nfunc main() { println(\"hello\") }\n"), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	path := filepath.Join(dir, "hello")
+	resurgotest.CompileGo(t, src, path, "GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0")
+	bin, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := resurgo.DetectFunctionsFromFile(path)
+	if err != nil {
+		t.Fatalf("resurgo.DetectFunctionsFromFile: %v", err)
+	}
+
+	// files is the content of the archives; only usr/bin/hello is ELF.
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{name: "etc/motd", data: []byte("hello\n")},
+		{name: "usr/bin/hello", data: bin},
+		{name: "usr/bin/.wh.removed"},
+	}
+	var tarball bytes.Buffer
+	tw := tar.NewWriter(&tarball)
+	if err := tw.WriteHeader(&tar.Header{Name: "usr/bin/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0o755, Size: int64(len(f.data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "usr/bin/hi", Typeflag: tar.TypeSymlink, Linkname: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var layer bytes.Buffer
+	gw := gzip.NewWriter(&layer)
+	if _, err := gw.Write(tarball.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		detect func() (*resurgo.AnalysisResult, error)
+		format resurgo.Format
+	}{
+		{
+			name: "tar",
+			detect: func() (*resurgo.AnalysisResult, error) {
+				return resurgo.DetectFunctionsFromTar(bytes.NewReader(tarball.Bytes()))
+			},
+			format: resurgo.FormatTar,
+		},
+		{
+			name: "tar.gz",
+			detect: func() (*resurgo.AnalysisResult, error) {
+				return resurgo.DetectFunctionsFromTar(bytes.NewReader(layer.Bytes()))
+			},
+			format: resurgo.FormatTar,
+		},
+		{
+			name: "zip",
+			detect: func() (*resurgo.AnalysisResult, error) {
+				return resurgo.DetectFunctionsFromZip(bytes.NewReader(zipped.Bytes()), int64(zipped.Len()))
+			},
+			format: resurgo.FormatZip,
+		},
+		{
+			name: "sniffed tar",
+			detect: func() (*resurgo.AnalysisResult, error) {
+				return resurgo.DetectFunctionsFromReader(bytes.NewReader(tarball.Bytes()), int64(tarball.Len()))
+			},
+			format: resurgo.FormatTar,
+		},
+		{
+			name: "sniffed zip",
+			detect: func() (*resurgo.AnalysisResult, error) {
+				return resurgo.DetectFunctionsFromReader(bytes.NewReader(zipped.Bytes()), int64(zipped.Len()))
+			},
+			format: resurgo.FormatZip,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.detect()
+			if err != nil {
+				t.Fatalf("detect: %v", err)
+			}
+			if result.Format != tt.format || len(result.Members) != 1 {
+				t.Fatalf("got %s with %d members, want %s with 1", result.Format, len(result.Members), tt.format)
+			}
+			m := result.Members[0]
+			if m.Name != "usr/bin/hello" || m.Format != resurgo.FormatELF || m.Arch != resurgo.ArchAMD64 {
+				t.Errorf("got member %s %s/%s, want usr/bin/hello %s/%s", m.Name, m.Format, m.Arch, resurgo.FormatELF, resurgo.ArchAMD64)
+			}
+			if len(m.Functions) != len(want.Functions) {
+				t.Errorf("got %d functions, want %d as from the extracted file", len(m.Functions), len(want.Functions))
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package resurgo
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// KindCRT marks the start-up and tear-down scaffolding the C runtime links
+// into executables (crt1.o, crti.o, crtbegin.o) rather than user code.
+const KindCRT FunctionKind = "crt"
+
+// DetectionCRT is assigned to the C runtime functions recognized by
+// DetectFunctionsFromELF with WithCRTLabels that were not otherwise among
+// the results.
+const DetectionCRT DetectionType = "crt"
+
+// crtWindow is the number of bytes of a C runtime function decoded to
+// recognize it. The crtbegin.o functions are short and their branches sit
+// in their first instructions.
+const crtWindow = 64
+
+// WithCRTLabels sets whether DetectFunctionsFromELF recognizes the
+// functions of the C runtime of glibc and musl executables and labels them
+// with KindCRT and their conventional name (default false): _start, _init
+// and _fini, and the frame_dummy, register_tm_clones,
+// __do_global_dtors_aux and deregister_tm_clones functions of crtbegin.o.
+// They are recognized by position and structure, so stripped binaries are
+// labelled too. Those no detector reported, or a filter removed, are added
+// with DetectionCRT, as they are function entries whatever their shape.
+func WithCRTLabels(enabled bool) Option {
+	return func(o *options) {
+		o.crtLabels = enabled
+	}
+}
+
+// labelCRT labels the candidates at the C runtime functions of f and adds
+// those missing from candidates.
+func (o *options) labelCRT(candidates []FunctionCandidate, f *elf.File) ([]FunctionCandidate, error) {
+	names, err := o.crtFunctions(f)
+	if err != nil || len(names) == 0 {
+		return candidates, err
+	}
+	for i, c := range candidates {
+		if name, ok := names[c.Address]; ok {
+			candidates[i].Kind, candidates[i].Name = KindCRT, name
+			delete(names, c.Address)
+		}
+	}
+	added := make([]FunctionCandidate, 0, len(names))
+	for addr, name := range names {
+		added = append(added, FunctionCandidate{
+			Address:       addr,
+			DetectionType: DetectionCRT,
+			Confidence:    ConfidenceHigh,
+			Kind:          KindCRT,
+			Name:          name,
+		})
+	}
+	return mergeCandidates(candidates, added), nil
+}
+
+// crtFunctions returns the C runtime functions of f, by address:
+//
+//   - _init and _fini open the .init and .fini sections.
+//   - _start is the entry point when it clears the frame pointer, as the
+//     glibc and musl ones do first.
+//   - frame_dummy is the first .init_array entry when it jumps, and its
+//     target is register_tm_clones.
+//   - __do_global_dtors_aux is the first .fini_array entry when it calls a
+//     function outside the PLT, which is deregister_tm_clones.
+func (o *options) crtFunctions(f *elf.File) (map[uint64]string, error) {
+	if f.Type == elf.ET_REL {
+		return nil, nil
+	}
+	arch, err := elfArch(f)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uint64]string)
+	for _, name := range []string{".init", ".fini"} {
+		if sec := f.Section(name); sec != nil && sec.Flags&elf.SHF_EXECINSTR != 0 && sec.Size > 0 {
+			names[sec.Addr] = "_" + name[1:]
+		}
+	}
+	if isCRTStart(codeAt(f, f.Entry, 8), arch) {
+		names[f.Entry] = "_start"
+	}
+
+	exec, plt := execRanges(f), pltRanges(f)
+	// branch returns the target of the first direct branch of type typ in
+	// the function at addr that lands in code outside the PLT.
+	branch := func(addr uint64, typ CallSiteType) (uint64, bool, error) {
+		code := codeAt(f, addr, crtWindow)
+		if len(code) == 0 {
+			return 0, false, nil
+		}
+		edges, err := o.withByteOrder(elfCodeByteOrder(f, arch)).detectCallSites(code, addr, arch)
+		if err != nil {
+			return 0, false, err
+		}
+		for _, e := range edges {
+			if e.Type == typ && e.AddressMode == AddressingModePCRelative && e.TargetAddr != addr &&
+				inRanges(e.TargetAddr, exec) && !inRanges(e.TargetAddr, plt) {
+				return e.TargetAddr, true, nil
+			}
+		}
+		return 0, false, nil
+	}
+
+	relocs, err := dynamicRelocs(f)
+	if err != nil {
+		return nil, fmt.Errorf("read dynamic relocations: %w", err)
+	}
+	tables := []struct {
+		typ          elf.SectionType
+		name, target string
+		branch       CallSiteType
+	}{
+		{elf.SHT_INIT_ARRAY, "frame_dummy", "register_tm_clones", CallSiteJump},
+		{elf.SHT_FINI_ARRAY, "__do_global_dtors_aux", "deregister_tm_clones", CallSiteCall},
+	}
+	for _, t := range tables {
+		i := slices.IndexFunc(f.Sections, func(sec *elf.Section) bool { return sec.Type == t.typ })
+		if i < 0 || f.Class != elf.ELFCLASS64 {
+			continue
+		}
+		words, err := sectionWords(f, f.Sections[i], relocs)
+		if err != nil {
+			return nil, err
+		}
+		if len(words) == 0 || words[0].symbolic || !inRanges(words[0].value, exec) {
+			continue
+		}
+		fn := words[0].value
+		target, ok, err := branch(fn, t.branch)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			names[fn], names[target] = t.name, t.target
+		}
+	}
+	return names, nil
+}
+
+// isCRTStart reports whether code, at the entry point of a file of arch,
+// starts as the _start of glibc and musl, by clearing the frame pointer.
+func isCRTStart(code []byte, arch Arch) bool {
+	switch arch {
+	case ArchAMD64, ArchX86:
+		if isENDBR(code, 0) {
+			code = code[4:]
+		}
+		// xor ebp, ebp or xor rbp, rbp.
+		return bytes.HasPrefix(code, []byte{0x31, 0xed}) || bytes.HasPrefix(code, []byte{0x48, 0x31, 0xed})
+	case ArchARM64:
+		const (
+			btiC   = 0xd503245f
+			movX29 = 0xd280001d // mov x29, #0
+		)
+		if len(code) >= 4 && binary.LittleEndian.Uint32(code) == btiC {
+			code = code[4:]
+		}
+		return len(code) >= 4 && binary.LittleEndian.Uint32(code) == movX29
+	}
+	return false
+}
+
+// codeAt returns up to n bytes of the contents of f at addr, stopping at the
+// end of the section holding addr. A compressed section, which cannot be
+// read in place, holds no code.
+func codeAt(f *elf.File, addr uint64, n uint64) []byte {
+	sec := sectionAt(f, addr)
+	if sec == nil || sec.Flags&elf.SHF_COMPRESSED != 0 {
+		return nil
+	}
+	code := make([]byte, min(n, sec.Addr+sec.Size-addr))
+	if _, err := sec.ReadAt(code, int64(addr-sec.Addr)); err != nil {
+		return nil
+	}
+	return code
+}
@@ -0,0 +1,143 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestWithCRTLabels(t *testing.T) {
+	for _, tool := range []string{"gcc", "strip"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found, skipping", tool)
+		}
+	}
+	crt := []string{"_start", "_init", "_fini", "frame_dummy", "register_tm_clones", "__do_global_dtors_aux", "deregister_tm_clones"}
+
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "pie", args: []string{"-pie"}},
+		{name: "no-pie", args: []string{"-no-pie"}},
+		{name: "static", args: []string{"-static"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			args := append([]string{"-O2", "-o", path}, tt.args...)
+			if out, err := exec.Command("gcc", append(args, "testdata/demo-app.c")...).CombinedOutput(); err != nil {
+				t.Skipf("failed to compile demo-app.c: %v\n%s", err, out)
+			}
+			orig, err := elf.Open(path)
+			if err != nil {
+				t.Fatalf("failed to open ELF: %v", err)
+			}
+			defer orig.Close()
+			syms, err := orig.Symbols()
+			if err != nil {
+				t.Fatalf("failed to read symbols: %v", err)
+			}
+			want := make(map[uint64]string)
+			for _, sym := range syms {
+				for _, name := range crt {
+					if sym.Name == name && elf.ST_TYPE(sym.Info) == elf.STT_FUNC {
+						want[sym.Value] = name
+					}
+				}
+			}
+
+			stripped := path + ".stripped"
+			if out, err := exec.Command("strip", "-o", stripped, path).CombinedOutput(); err != nil {
+				t.Fatalf("strip: %v\n%s", err, out)
+			}
+			f, err := elf.Open(stripped)
+			if err != nil {
+				t.Fatalf("failed to open ELF: %v", err)
+			}
+			defer f.Close()
+
+			candidates, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithCRTLabels(true))
+			if err != nil {
+				t.Fatalf("DetectFunctionsFromELF: %v", err)
+			}
+			got := make(map[uint64]string)
+			for _, c := range candidates {
+				if c.Kind == resurgo.KindCRT {
+					got[c.Address] = c.Name
+				}
+			}
+			for addr, name := range want {
+				if got[addr] != name {
+					t.Errorf("%s at 0x%x: got label %q", name, addr, got[addr])
+				}
+			}
+			for addr, name := range got {
+				if want[addr] != name {
+					t.Errorf("0x%x labelled %s, is %q", addr, name, want[addr])
+				}
+			}
+
+			// Labels are opt-in.
+			candidates, err = resurgo.DetectFunctionsFromELF(f)
+			if err != nil {
+				t.Fatalf("DetectFunctionsFromELF: %v", err)
+			}
+			for _, c := range candidates {
+				if c.Kind == resurgo.KindCRT || c.Name != "" {
+					t.Errorf("0x%x: labelled %s %q by default", c.Address, c.Kind, c.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestWithCRTLabelsCompressedSection(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+	path := filepath.Join(t.TempDir(), "demo-app")
+	if out, err := exec.Command("gcc", "-O2", "-o", path, "testdata/demo-app.c").CombinedOutput(); err != nil {
+		t.Skipf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse ELF: %v", err)
+	}
+	idx := slices.IndexFunc(orig.Sections, func(s *elf.Section) bool { return s.Name == ".interp" })
+	if idx < 0 {
+		t.Skip("no .interp section")
+	}
+
+	// A compressed .interp moved over the entry point comes before .text:
+	// it has no contents at its address to read _start from.
+	data = compressSection(t, data, ".interp", []byte("/lib/ld.so\x00"), 11)
+	// Elf64_Shdr: sh_addr at 16.
+	shdr := data[binary.LittleEndian.Uint64(data[0x28:])+uint64(idx)*64:]
+	binary.LittleEndian.PutUint64(shdr[16:], orig.Entry)
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse ELF: %v", err)
+	}
+	candidates, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithCRTLabels(true))
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromELF: %v", err)
+	}
+	for _, c := range candidates {
+		if c.Address == f.Entry && c.Name == "_start" {
+			t.Errorf("0x%x: labelled _start through a compressed section", c.Address)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package resurgo
+
+import (
+	"cmp"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"golang.org/x/arch/x86/x86asm"
+)
+
+const (
+	// Recognized kinds of data embedded in executable code.
+	DataKindLiteralPool DataKind = "literal-pool"
+	DataKindJumpTable   DataKind = "jump-table"
+	DataKindString      DataKind = "string"
+)
+
+// DataKind classifies a data region found inside executable code.
+type DataKind string
+
+// DataRegion is a range of bytes inside executable code that holds data
+// rather than instructions. No functions are reported inside it.
+type DataRegion struct {
+	// Address is the virtual address of the first byte of the region.
+	Address uint64 `json:"address"`
+	// Size is the length of the region in bytes.
+	Size uint64 `json:"size"`
+	// Kind is the classification of the data.
+	Kind DataKind `json:"kind"`
+	// Evidence lists the observations that led to the classification, such
+	// as the instructions referencing the region.
+	Evidence []string `json:"evidence"`
+}
+
+const (
+	// minStringLen is the shortest printable run classified as a string
+	// constant. Shorter runs occur in ordinary x86-64 code (e.g. the bytes
+	// of push r15; push r14; push r13 read "AWAVAU").
+	minStringLen = 16
+
+	// maxJumpTableEntries bounds the number of entries decoded from a
+	// single jump table.
+	maxJumpTableEntries = 1024
+
+	// LDR (literal): opc(31:30) 011 V(26) 00 imm19 Rt.
+	arm64LDRLiteralMask  = uint32(0x3b000000)
+	arm64LDRLiteralValue = uint32(0x18000000)
+)
+
+// DetectDataRegions analyzes raw machine code bytes and returns the regions
+// classified as embedded data: literal pools referenced by PC-relative loads,
+// jump tables referenced by indexed loads, and NUL-terminated strings.
+// baseAddr is the virtual address corresponding to the start of code. Only
+// regions inside code are reported, ordered by address. opts may include
+// WithByteOrder or WithLimits; other options are ignored. This function
+// performs no I/O and works with any binary format.
+func DetectDataRegions(code []byte, baseAddr uint64, arch Arch, opts ...Option) ([]DataRegion, error) {
+	return newOptions(opts...).detectDataRegions(code, baseAddr, arch)
+}
+
+func (o *options) detectDataRegions(code []byte, baseAddr uint64, arch Arch) ([]DataRegion, error) {
+	o = o.withBudget()
+	if err := o.budget.codeSize(uint64(len(code))); err != nil {
+		return nil, err
+	}
+	code, err := o.littleEndianCode(code, arch)
+	if err != nil {
+		return nil, err
+	}
+	var regions []DataRegion
+	switch arch {
+	case ArchAMD64:
+		regions, err = detectJumpTablesAMD64(code, baseAddr, o.budget)
+	case ArchARM64:
+		regions, err = detectLiteralPoolsARM64(code, baseAddr, o.budget)
+	default:
+		return nil, fmt.Errorf("unsupported architecture: %s", arch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	regions = mergeDataRegions(append(regions, detectStrings(code, baseAddr)...))
+	if err := o.budget.results(len(regions)); err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
+// WithJumpTableSkipping sets whether DisasmDetector leaves the switch jump
+// tables of x86-64 code out of its sweep (default false). Compilers such as
+// MSVC place jump tables in .text, where the linear sweep decodes their
+// entries as instructions: the garbage throws it out of step with the code
+// after the table and occasionally reads a prologue in it. In this mode the
+// tables referenced by a lea of the table, a movsxd load of an entry and an
+// indirect jmp, or by an indexed indirect jmp, as DetectDataRegions reports
+// them, are taken for data: no prologue, call site or aligned entry is
+// reported inside them, and decoding resumes right after each table, which
+// counts as a function boundary.
+func WithJumpTableSkipping(enabled bool) Option {
+	return func(o *options) {
+		o.jumpTableSkipping = enabled
+	}
+}
+
+// skipJumpTables returns the parts of regions outside the jump tables found
+// in their x86-64 code, each decoded as its region's architecture if set,
+// or as arch.
+func (o *options) skipJumpTables(regions []codeSection, arch Arch) ([]codeSection, error) {
+	var tables []DataRegion
+	for _, region := range regions {
+		if cmp.Or(region.arch, arch) != ArchAMD64 {
+			continue
+		}
+		found, err := detectJumpTablesAMD64(region.code, region.addr, o.budget)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, found...)
+	}
+	if len(tables) == 0 {
+		return regions, nil
+	}
+	tables = mergeDataRegions(tables)
+	claimed := make([][2]uint64, len(tables))
+	for i, t := range tables {
+		claimed[i] = [2]uint64{t.Address, t.Address + t.Size}
+	}
+	return unclaimedRegions(regions, claimed), nil
+}
+
+// detectLiteralPoolsARM64 returns the targets of LDR (literal) and LDRSW
+// (literal) instructions that fall inside code. Compilers and assemblers
+// place these constants next to the function using them.
+func detectLiteralPoolsARM64(code []byte, baseAddr uint64, b *budget) ([]DataRegion, error) {
+	const insnLen = 4
+	var regions []DataRegion
+	end := baseAddr + uint64(len(code))
+	for offset := 0; offset+insnLen <= len(code); offset += insnLen {
+		if err := b.step(offset); err != nil {
+			return nil, err
+		}
+		word := binary.LittleEndian.Uint32(code[offset:])
+		if word&arm64LDRLiteralMask != arm64LDRLiteralValue {
+			continue
+		}
+		var size uint64
+		opc, simd := word>>30, word&(1<<26) != 0
+		switch {
+		case !simd && opc == 3:
+			// PRFM (literal) does not load data.
+			continue
+		case simd:
+			size = 4 << opc // S, D, Q
+		case opc == 1:
+			size = 8 // X
+		default:
+			size = 4 // W, LDRSW
+		}
+		if size > 16 {
+			continue
+		}
+		// imm19 at bits 23:5, scaled by the 4-byte word size.
+		addr := baseAddr + uint64(offset)
+		disp := int64(int32(word<<8)>>13) * 4
+		target, ok := relTarget(addr, disp, false)
+		if !ok || target < baseAddr || target+size > end {
+			continue
+		}
+		regions = append(regions, DataRegion{
+			Address:  target,
+			Size:     size,
+			Kind:     DataKindLiteralPool,
+			Evidence: []string{fmt.Sprintf("ldr (literal) at 0x%x", addr)},
+		})
+	}
+	return regions, nil
+}
+
+// detectJumpTablesAMD64 finds switch jump tables placed inside code. Two
+// forms are recognized:
+//
+//	lea  rB, [rip+table]              ; position-independent
+//	movsxd rY, dword [rB+rI*4]        ; entries are offsets from table
+//	add  rY, rB
+//	jmp  rY
+//
+//	jmp  qword [rI*8+table]           ; absolute, non-PIC
+//
+// Entries are decoded from the start of the table until one does not point
+// back into code.
+func detectJumpTablesAMD64(code []byte, baseAddr uint64, b *budget) ([]DataRegion, error) {
+	var regions []DataRegion
+	end := baseAddr + uint64(len(code))
+	inCode := func(addr uint64) bool { return addr >= baseAddr && addr < end }
+
+	type tableRef struct{ table, leaAddr uint64 }
+	// leaRegs maps a register to the RIP-relative address last loaded into it.
+	leaRegs := make(map[x86asm.Reg]tableRef)
+	// loads maps a register to the table an entry was last loaded from into
+	// it, until the indirect jmp through it.
+	loads := make(map[x86asm.Reg]DataRegion)
+
+	offset := 0
+	for offset < len(code) {
+		if err := b.step(offset); err != nil {
+			return nil, err
+		}
+		if isENDBR(code, offset) {
+			offset += 4
+			continue
+		}
+		addr := baseAddr + uint64(offset)
+		inst, err := x86asm.Decode(code[offset:], 64)
+		if err != nil {
+			offset++
+			continue
+		}
+		offset += inst.Len
+
+		mem, _ := inst.Args[1].(x86asm.Mem)
+		switch {
+		case inst.Op == x86asm.LEA && mem.Base == x86asm.RIP && mem.Index == 0:
+			if reg, ok := inst.Args[0].(x86asm.Reg); ok {
+				if table, ok := relTarget(addr+uint64(inst.Len), int64(int32(mem.Disp)), false); ok {
+					leaRegs[reg] = tableRef{table: table, leaAddr: addr}
+				}
+			}
+			continue
+
+		case inst.Op == x86asm.MOVSXD && mem.Scale == 4 && mem.Index != 0:
+			ref, ok := leaRegs[mem.Base]
+			if !ok || !inCode(ref.table) {
+				break
+			}
+			n := uint64(0)
+			for ; n < maxJumpTableEntries; n++ {
+				off := ref.table - baseAddr + 4*n
+				if off+4 > uint64(len(code)) {
+					break
+				}
+				rel := int64(int32(binary.LittleEndian.Uint32(code[off:])))
+				if target, ok := relTarget(ref.table, rel, false); !ok || !inCode(target) {
+					break
+				}
+			}
+			dst, ok := inst.Args[0].(x86asm.Reg)
+			if n == 0 || !ok {
+				break
+			}
+			delete(leaRegs, dst)
+			loads[dst] = DataRegion{
+				Address: ref.table,
+				Size:    4 * n,
+				Kind:    DataKindJumpTable,
+				Evidence: []string{
+					fmt.Sprintf("lea rip-relative at 0x%x", ref.leaAddr),
+					fmt.Sprintf("movsxd indexed load at 0x%x", addr),
+				},
+			}
+			continue
+
+		case inst.Op == x86asm.JMP:
+			if reg, ok := inst.Args[0].(x86asm.Reg); ok {
+				if table, ok := loads[reg]; ok {
+					table.Evidence = append(table.Evidence, fmt.Sprintf("indirect jmp at 0x%x", addr))
+					regions = append(regions, table)
+				}
+				break
+			}
+			jmpMem, ok := inst.Args[0].(x86asm.Mem)
+			if !ok || jmpMem.Base != 0 || jmpMem.Index == 0 || jmpMem.Scale != 8 {
+				break
+			}
+			table := uint64(int64(int32(jmpMem.Disp)))
+			if !inCode(table) {
+				break
+			}
+			n := uint64(0)
+			for ; n < maxJumpTableEntries; n++ {
+				off := table - baseAddr + 8*n
+				if off+8 > uint64(len(code)) || !inCode(binary.LittleEndian.Uint64(code[off:])) {
+					break
+				}
+			}
+			if n > 0 {
+				regions = append(regions, DataRegion{
+					Address:  table,
+					Size:     8 * n,
+					Kind:     DataKindJumpTable,
+					Evidence: []string{fmt.Sprintf("indexed indirect jmp at 0x%x", addr)},
+				})
+			}
+		}
+
+		// Any other write to a tracked register invalidates it.
+		if reg, ok := inst.Args[0].(x86asm.Reg); ok {
+			switch inst.Op {
+			case x86asm.CMP, x86asm.TEST, x86asm.PUSH:
+			case x86asm.ADD:
+				// add rY, rB turns the loaded entry into the target.
+				delete(leaRegs, reg)
+			default:
+				delete(leaRegs, reg)
+				delete(loads, reg)
+			}
+		}
+	}
+	return regions, nil
+}
+
+// detectStrings returns runs of at least minStringLen printable ASCII bytes
+// terminated by NUL. The region includes the terminator.
+func detectStrings(code []byte, baseAddr uint64) []DataRegion {
+	var regions []DataRegion
+	start := 0
+	for i, b := range code {
+		if isPrintableASCII(b) {
+			continue
+		}
+		if b == 0 && i-start >= minStringLen {
+			regions = append(regions, DataRegion{
+				Address:  baseAddr + uint64(start),
+				Size:     uint64(i - start + 1),
+				Kind:     DataKindString,
+				Evidence: []string{fmt.Sprintf("%d printable bytes terminated by NUL", i-start)},
+			})
+		}
+		start = i + 1
+	}
+	return regions
+}
+
+func isPrintableASCII(b byte) bool {
+	return (b >= 0x20 && b < 0x7f) || b == '\t' || b == '\n' || b == '\r'
+}
+
+// mergeDataRegions sorts regions by address and merges overlapping or
+// adjacent regions of the same kind, concatenating their evidence.
+func mergeDataRegions(regions []DataRegion) []DataRegion {
+	slices.SortStableFunc(regions, func(a, b DataRegion) int {
+		if c := cmp.Compare(a.Address, b.Address); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Kind, b.Kind)
+	})
+	var merged []DataRegion
+	for _, r := range regions {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if last.Kind == r.Kind && r.Address <= last.Address+last.Size {
+				last.Size = max(last.Size, r.Address+r.Size-last.Address)
+				for _, e := range r.Evidence {
+					if !slices.Contains(last.Evidence, e) {
+						last.Evidence = append(last.Evidence, e)
+					}
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// DataRegionFilter removes candidates that fall inside data regions found
+// by DetectDataRegions in the code scanned by DisasmDetector: a "function"
+// decoded out of a literal pool, jump table or string constant is noise. It
+// is not part of the default pipeline. Use DetectDataRegions on the same
+// bytes to inspect the regions and their evidence.
+func DataRegionFilter(candidates []FunctionCandidate, f *elf.File) ([]FunctionCandidate, error) {
+	var arch Arch
+	switch f.Machine {
+	case elf.EM_X86_64:
+		arch = ArchAMD64
+	case elf.EM_AARCH64:
+		arch = ArchARM64
+	default:
+		return candidates, nil
+	}
+	sections, err := elfCodeSections(f, arch, nil)
+	if err != nil {
+		return candidates, nil
+	}
+	var regions []DataRegion
+	for _, sec := range sections {
+		found, err := DetectDataRegions(sec.code, sec.addr, arch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect data regions: %w", err)
+		}
+		regions = append(regions, found...)
+	}
+	if len(regions) == 0 {
+		return candidates, nil
+	}
+
+	filtered := candidates[:0]
+	for _, c := range candidates {
+		inData := slices.ContainsFunc(regions, func(r DataRegion) bool {
+			return c.Address >= r.Address && c.Address < r.Address+r.Size
+		})
+		if !inData {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,182 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestDetectDataRegions(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     []byte
+		baseAddr uint64
+		arch     resurgo.Arch
+		want     []resurgo.DataRegion
+	}{{
+		// ldr x0, 8; ret; .quad 0x1122334455667788
+		name: "arm64/literal-pool",
+		code: append(arm64Insn(0x58000040, 0xd65f03c0), 0x88, 0x77, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11),
+		arch: resurgo.ArchARM64,
+		want: []resurgo.DataRegion{{
+			Address:  8,
+			Size:     8,
+			Kind:     resurgo.DataKindLiteralPool,
+			Evidence: []string{"ldr (literal) at 0x0"},
+		}},
+	}, {
+		// lea rdx, [rip+9]; movsxd rax, dword [rdx+rax*4]; add rax, rdx;
+		// jmp rax; .long -16, -2; .long 0x7fffffff
+		name: "amd64/pic-jump-table",
+		code: []byte{
+			0x48, 0x8d, 0x15, 0x09, 0x00, 0x00, 0x00,
+			0x48, 0x63, 0x04, 0x82,
+			0x48, 0x01, 0xd0,
+			0xff, 0xe0,
+			0xf0, 0xff, 0xff, 0xff,
+			0xfe, 0xff, 0xff, 0xff,
+			0xff, 0xff, 0xff, 0x7f,
+		},
+		arch: resurgo.ArchAMD64,
+		want: []resurgo.DataRegion{{
+			Address:  16,
+			Size:     8,
+			Kind:     resurgo.DataKindJumpTable,
+			Evidence: []string{"lea rip-relative at 0x0", "movsxd indexed load at 0x7", "indirect jmp at 0xe"},
+		}},
+	}, {
+		// jmp qword [rax*8+0x400010]; .quad 0x400000, 0x400007
+		name: "amd64/absolute-jump-table",
+		code: []byte{
+			0xff, 0x24, 0xc5, 0x10, 0x00, 0x40, 0x00,
+			0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc,
+			0x00, 0x00, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x07, 0x00, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00,
+		},
+		baseAddr: 0x400000,
+		arch:     resurgo.ArchAMD64,
+		want: []resurgo.DataRegion{{
+			Address:  0x400010,
+			Size:     16,
+			Kind:     resurgo.DataKindJumpTable,
+			Evidence: []string{"indexed indirect jmp at 0x400000"},
+		}},
+	}, {
+		// ret; "Hello, resurgo world!\0"
+		name:     "amd64/string",
+		code:     append([]byte{0xc3}, "Hello, resurgo world!\x00"...),
+		baseAddr: 0x1000,
+		arch:     resurgo.ArchAMD64,
+		want: []resurgo.DataRegion{{
+			Address:  0x1001,
+			Size:     22,
+			Kind:     resurgo.DataKindString,
+			Evidence: []string{"21 printable bytes terminated by NUL"},
+		}},
+	}, {
+		// push r15; push r14; push r13; push r12; push rbp; push rbx - the
+		// bytes read "AWAVAUATUS" but are not a string.
+		name: "amd64/short-printable-run",
+		code: []byte{0x41, 0x57, 0x41, 0x56, 0x41, 0x55, 0x41, 0x54, 0x55, 0x53, 0x00},
+		arch: resurgo.ArchAMD64,
+		want: nil,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regions, err := resurgo.DetectDataRegions(tt.code, tt.baseAddr, tt.arch)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(regions) != len(tt.want) {
+				t.Fatalf("expected %d regions, got %+v", len(tt.want), regions)
+			}
+			for i, want := range tt.want {
+				got := regions[i]
+				if got.Address != want.Address || got.Size != want.Size || got.Kind != want.Kind {
+					t.Errorf("region %d: expected %+v, got %+v", i, want, got)
+				}
+				if len(got.Evidence) != len(want.Evidence) {
+					t.Fatalf("region %d: expected evidence %q, got %q", i, want.Evidence, got.Evidence)
+				}
+				for j := range want.Evidence {
+					if got.Evidence[j] != want.Evidence[j] {
+						t.Errorf("region %d: expected evidence %q, got %q", i, want.Evidence, got.Evidence)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDetectDataRegions_UnsupportedArch(t *testing.T) {
+	_, err := resurgo.DetectDataRegions([]byte{0x00}, 0, resurgo.Arch("mips"))
+	if err == nil {
+		t.Fatal("expected error for unsupported architecture, got nil")
+	}
+}
+
+func TestWithJumpTableSkipping(t *testing.T) {
+	const base = 0x1000
+	code := []byte{
+		0x55,             // 0x1000: push rbp
+		0x48, 0x89, 0xe5, // 0x1001: mov rbp, rsp
+	}
+	code = append(code, bytes.Repeat([]byte{0x90}, 8)...)
+	code = append(code,
+		0x48, 0x8d, 0x15, 0x09, 0x00, 0x00, 0x00, // 0x100c: lea rdx, [rip+9]
+		0x48, 0x63, 0x04, 0x82, // 0x1013: movsxd rax, dword [rdx+rax*4]
+		0x48, 0x01, 0xd0, // 0x1017: add rax, rdx
+		0xff, 0xe0, // 0x101a: jmp rax
+		// 0x101c: .long -24, -1, read as call 0x1020.
+		0xe8, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff,
+		0x55,             // 0x1024: push rbp
+		0x48, 0x89, 0xe5, // 0x1025: mov rbp, rsp
+		0x5d, // 0x1028: pop rbp
+		0xc3, // 0x1029: ret
+	)
+
+	tests := []struct {
+		name    string
+		enabled bool
+		want    map[uint64]resurgo.DetectionType
+	}{{
+		// The sweep reads a call out of the table and, out of step, misses
+		// the prologue after it.
+		name: "sweep",
+		want: map[uint64]resurgo.DetectionType{
+			0x1000: resurgo.DetectionPrologueOnly,
+			0x1020: resurgo.DetectionCallTarget,
+		},
+	}, {
+		name:    "skipping",
+		enabled: true,
+		want: map[uint64]resurgo.DetectionType{
+			0x1000: resurgo.DetectionPrologueOnly,
+			0x1024: resurgo.DetectionPrologueOnly,
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(code), int64(len(code)),
+				resurgo.WithArch(resurgo.ArchAMD64), resurgo.WithBaseAddress(base), resurgo.WithJumpTableSkipping(tt.enabled))
+			if err != nil {
+				t.Fatalf("resurgo.DetectFunctionsFromReader: %v", err)
+			}
+			got := make(map[uint64]resurgo.DetectionType)
+			for _, c := range result.Functions {
+				got[c.Address] = c.DetectionType
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("got %+v, want %v", result.Functions, tt.want)
+			}
+			for addr, typ := range tt.want {
+				if got[addr] != typ {
+					t.Errorf("0x%x: got %q, want %q", addr, got[addr], typ)
+				}
+			}
+		})
+	}
+}
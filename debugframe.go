@@ -0,0 +1,178 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// DebugFrameDetector is a CandidateDetector that emits function candidates
+// sourced from the FDE records of .debug_frame, the non-loaded variant of
+// the call frame information that some embedded toolchains and kernels emit
+// instead of .eh_frame. Compressed sections, SHF_COMPRESSED or .zdebug_frame,
+// are decompressed. Each candidate carries DetectionCFI and ConfidenceHigh.
+// FDEs of functions discarded at link time, whose initial_location was
+// resolved to zero or to a tombstone value, are skipped. Returns an empty
+// slice (no error) when .debug_frame is absent. It is not part of the
+// default pipeline.
+func DebugFrameDetector(f *elf.File) ([]FunctionCandidate, error) {
+	starts, err := parseDebugFrameEntries(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse .debug_frame: %w", err)
+	}
+	candidates := make([]FunctionCandidate, 0, len(starts))
+	for _, va := range starts {
+		candidates = append(candidates, FunctionCandidate{
+			Address:       va,
+			DetectionType: DetectionCFI,
+			Confidence:    ConfidenceHigh,
+		})
+	}
+	return candidates, nil
+}
+
+// debugFrameCIE holds the fields of a .debug_frame CIE needed to decode the
+// FDEs that reference it.
+type debugFrameCIE struct {
+	// addrSize is the size of initial_location and address_range, and
+	// segSize that of the segment selector preceding them (version 4).
+	addrSize, segSize int
+}
+
+// parseDebugFrameEntries parses the .debug_frame section of f, or its
+// .zdebug_frame variant, and returns the sorted, deduplicated
+// initial_location of every FDE that falls in an executable section.
+//
+// Unlike .eh_frame, .debug_frame stores absolute addresses, CIE ids of all
+// ones and CIE pointers that are offsets from the start of the section, in
+// both the 32-bit and 64-bit DWARF formats.
+//
+// Returns nil (no error) if the section is absent or f is a relocatable
+// object, whose addresses are only resolved at link time. Malformed records
+// are skipped.
+func parseDebugFrameEntries(f *elf.File) ([]uint64, error) {
+	sec := f.Section(".debug_frame")
+	if sec == nil {
+		sec = f.Section(".zdebug_frame")
+	}
+	if sec == nil || sec.Type == elf.SHT_NOBITS || f.Type == elf.ET_REL {
+		return nil, nil
+	}
+	data, err := sectionData(sec)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", sec.Name, err)
+	}
+
+	bo := f.ByteOrder
+	ptrSize := 4
+	if f.Class == elf.ELFCLASS64 {
+		ptrSize = 8
+	}
+	exec := execRanges(f)
+	cies := make(map[uint64]debugFrameCIE)
+
+	var starts []uint64
+	for off := 0; off < len(data); {
+		body, idSize, next, ok := debugFrameRecord(data, off, bo)
+		if !ok {
+			break
+		}
+		off = next
+		if len(body) < idSize {
+			continue
+		}
+		var id uint64
+		if idSize == 8 {
+			id = bo.Uint64(body)
+		} else {
+			id = uint64(bo.Uint32(body))
+			if id == 0xffffffff {
+				id = ^uint64(0)
+			}
+		}
+		if id == ^uint64(0) {
+			continue // CIE, parsed when an FDE references it
+		}
+
+		cie, ok := cies[id]
+		if !ok {
+			if cie, ok = parseDebugFrameCIE(data, id, ptrSize, bo); !ok {
+				continue
+			}
+			cies[id] = cie
+		}
+		loc := idSize + cie.segSize
+		if loc+cie.addrSize > len(body) {
+			continue
+		}
+		var va uint64
+		switch cie.addrSize {
+		case 4:
+			va = uint64(bo.Uint32(body[loc:]))
+		case 8:
+			va = bo.Uint64(body[loc:])
+		default:
+			continue
+		}
+		if inRanges(va, exec) {
+			starts = append(starts, va)
+		}
+	}
+	slices.Sort(starts)
+	return slices.Compact(starts), nil
+}
+
+// debugFrameRecord decodes the length of the record at data[off] and returns
+// its body, from the CIE id or pointer to its end, the size of that id (4,
+// or 8 in the 64-bit DWARF format), and the offset of the next record. ok is
+// false at the end of the section or on a truncated record.
+func debugFrameRecord(data []byte, off int, bo binary.ByteOrder) (body []byte, idSize, next int, ok bool) {
+	if off+4 > len(data) {
+		return nil, 0, 0, false
+	}
+	length, idSize := uint64(bo.Uint32(data[off:])), 4
+	off += 4
+	if length == 0xffffffff {
+		if off+8 > len(data) {
+			return nil, 0, 0, false
+		}
+		length, idSize = bo.Uint64(data[off:]), 8
+		off += 8
+	}
+	if length == 0 || length > uint64(len(data)-off) {
+		return nil, 0, 0, false
+	}
+	end := off + int(length)
+	return data[off:end], idSize, end, true
+}
+
+// parseDebugFrameCIE parses the CIE at byte offset off of data and returns
+// the address and segment selector sizes of its FDEs: those it declares in
+// version 4, and ptrSize with no segment selector in earlier versions.
+func parseDebugFrameCIE(data []byte, off uint64, ptrSize int, bo binary.ByteOrder) (debugFrameCIE, bool) {
+	if off >= uint64(len(data)) {
+		return debugFrameCIE{}, false
+	}
+	body, idSize, _, ok := debugFrameRecord(data, int(off), bo)
+	if !ok || len(body) < idSize+1 {
+		return debugFrameCIE{}, false
+	}
+	body = body[idSize:]
+	cie := debugFrameCIE{addrSize: ptrSize}
+	version := body[0]
+	if version < 4 {
+		return cie, true
+	}
+	// The augmentation string precedes address_size and
+	// segment_selector_size.
+	i := 1
+	for i < len(body) && body[i] != 0 {
+		i++
+	}
+	if i+2 >= len(body) {
+		return debugFrameCIE{}, false
+	}
+	cie.addrSize, cie.segSize = int(body[i+1]), int(body[i+2])
+	return cie, true
+}
@@ -0,0 +1,99 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestDebugFrameDetector(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "frames.c")
+	const code = `
+__attribute__((noinline)) int scale(int x) { return x * 3; }
+__attribute__((noinline)) int shift(int x) { return scale(x) + 1; }
+int main(int argc, char **argv) { return shift(argc); }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+
+	// Without unwind tables the CFI of the program's own functions is only
+	// emitted in .debug_frame.
+	for _, tc := range []struct {
+		name string
+		gz   string
+	}{
+		{name: "plain", gz: "-gz=none"},
+		{name: "compressed", gz: "-gz=zlib"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			outPath := filepath.Join(dir, "frames-"+tc.name)
+			cmd := exec.Command("gcc", "-O1", "-g", tc.gz, "-fno-asynchronous-unwind-tables", "-fno-unwind-tables", "-o", outPath, src)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Skipf("failed to compile frames.c: %v\n%s", err, out)
+			}
+			f, err := elf.Open(outPath)
+			if err != nil {
+				t.Fatalf("failed to open ELF: %v", err)
+			}
+			defer f.Close()
+			sec := f.Section(".debug_frame")
+			if sec == nil {
+				t.Skip("toolchain emitted no .debug_frame")
+			}
+			if compressed := sec.Flags&elf.SHF_COMPRESSED != 0; compressed != (tc.name == "compressed") {
+				t.Skipf(".debug_frame compressed: %v", compressed)
+			}
+
+			syms, err := f.Symbols()
+			if err != nil {
+				t.Fatalf("failed to read symbols: %v", err)
+			}
+			want := map[string]uint64{"scale": 0, "shift": 0, "main": 0}
+			for _, s := range syms {
+				if _, ok := want[s.Name]; ok && elf.ST_TYPE(s.Info) == elf.STT_FUNC {
+					want[s.Name] = s.Value
+				}
+			}
+
+			candidates, err := resurgo.DebugFrameDetector(f)
+			if err != nil {
+				t.Fatalf("resurgo.DebugFrameDetector: %v", err)
+			}
+			got := make(map[uint64]bool)
+			for _, c := range candidates {
+				if c.DetectionType != resurgo.DetectionCFI || c.Confidence != resurgo.ConfidenceHigh {
+					t.Errorf("candidate 0x%x: unexpected %s/%s", c.Address, c.DetectionType, c.Confidence)
+				}
+				got[c.Address] = true
+			}
+			for name, addr := range want {
+				if addr == 0 {
+					t.Errorf("%s: symbol not found", name)
+				} else if !got[addr] {
+					t.Errorf("%s at 0x%x not detected", name, addr)
+				}
+			}
+
+			// None of them is described by .eh_frame.
+			eh, err := resurgo.EhFrameDetector(f)
+			if err != nil {
+				t.Fatalf("resurgo.EhFrameDetector: %v", err)
+			}
+			for _, c := range eh {
+				if c.Address == want["scale"] {
+					t.Errorf("scale at 0x%x unexpectedly in .eh_frame", c.Address)
+				}
+			}
+		})
+	}
+}
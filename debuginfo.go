@@ -0,0 +1,281 @@
+package resurgo
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultDebugDir is the root of the separate debug files installed on
+	// the system, e.g. by -dbgsym and -debuginfo packages.
+	defaultDebugDir = "/usr/lib/debug"
+	// debuglinkSection names the separate debug file of a binary and holds
+	// its CRC.
+	debuglinkSection = ".gnu_debuglink"
+	// debuginfodTimeout bounds a request to a debuginfod server.
+	debuginfodTimeout = 30 * time.Second
+)
+
+// ErrNoDebugFile is returned by OpenDebugFile when the separate debug file
+// of a binary cannot be found.
+var ErrNoDebugFile = errors.New("no debug file found")
+
+// WithDebugDirs sets the directories OpenDebugFile searches for separate
+// debug files (default /usr/lib/debug), both under their .build-id
+// subdirectory and by the name recorded in .gnu_debuglink.
+func WithDebugDirs(dirs ...string) Option {
+	return func(o *options) {
+		o.debugDirs = dirs
+	}
+}
+
+// WithDebuginfod sets the URLs of the debuginfod servers OpenDebugFile
+// queries by build ID when no local debug file is found (default none),
+// e.g. strings.Fields(os.Getenv("DEBUGINFOD_URLS")).
+func WithDebuginfod(urls ...string) Option {
+	return func(o *options) {
+		o.debuginfod = urls
+	}
+}
+
+// WithDebugSymbols sets whether DetectFunctionsFromELF looks up the
+// separate debug file of the binary with OpenDebugFile and uses its symbol
+// table (default false): the candidates at a function symbol are named
+// after it and raised to ConfidenceHigh. Binaries without a debug file are
+// left as detected. The candidates at other addresses are kept; see
+// VerifyCandidates to measure them.
+func WithDebugSymbols(enabled bool) Option {
+	return func(o *options) {
+		o.debugSymbols = enabled
+	}
+}
+
+// OpenDebugFile returns the separate debug file of f, the binary read from
+// path, which carries the symbol table stripped from it. The file is looked
+// up by the build ID of f in the .build-id subdirectory of each directory of
+// WithDebugDirs, then by the name recorded in .gnu_debuglink next to path,
+// in its .debug subdirectory and in each debug directory, as GDB does, and
+// finally downloaded from the servers of WithDebuginfod. path may be empty
+// when unknown. A debug file found through .gnu_debuglink must match its
+// CRC. A server failing to answer is skipped; it is an error if all of them
+// fail, and an ErrNoDebugFile error if none is found. The caller closes the
+// returned file.
+func OpenDebugFile(f *elf.File, path string, opts ...Option) (*elf.File, error) {
+	return newOptions(opts...).openDebugFile(f, path)
+}
+
+func (o *options) openDebugFile(f *elf.File, path string) (*elf.File, error) {
+	buildID, err := BuildID(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(buildID) > 2 {
+		for _, dir := range o.debugDirs {
+			name := filepath.Join(dir, ".build-id", buildID[:2], buildID[2:]+".debug")
+			if debug, err := elf.Open(name); err == nil {
+				return debug, nil
+			}
+		}
+	}
+
+	if name, crc, ok := debuglink(f); ok {
+		var candidates []string
+		if path != "" {
+			dir := filepath.Dir(path)
+			candidates = append(candidates, filepath.Join(dir, name), filepath.Join(dir, ".debug", name))
+			if abs, err := filepath.Abs(dir); err == nil {
+				for _, root := range o.debugDirs {
+					candidates = append(candidates, filepath.Join(root, abs, name))
+				}
+			}
+		}
+		for _, root := range o.debugDirs {
+			candidates = append(candidates, filepath.Join(root, name))
+		}
+		for _, candidate := range candidates {
+			data, err := os.ReadFile(candidate)
+			if err != nil || crc32.ChecksumIEEE(data) != crc {
+				continue
+			}
+			if debug, err := elf.NewFile(bytes.NewReader(data)); err == nil {
+				return debug, nil
+			}
+		}
+	}
+
+	if buildID != "" && len(o.debuginfod) > 0 {
+		// A failing server falls back to the next one.
+		var errs []error
+		for _, url := range o.debuginfod {
+			debug, err := fetchDebuginfo(url, buildID)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if debug != nil {
+				return debug, nil
+			}
+		}
+		if len(errs) == len(o.debuginfod) {
+			return nil, errors.Join(errs...)
+		}
+	}
+	return nil, ErrNoDebugFile
+}
+
+// debuglink returns the file name and CRC recorded in the .gnu_debuglink
+// section of f.
+func debuglink(f *elf.File) (string, uint32, bool) {
+	sec := f.Section(debuglinkSection)
+	if sec == nil {
+		return "", 0, false
+	}
+	data, err := sectionData(sec)
+	if err != nil {
+		return "", 0, false
+	}
+	// The name is NUL-terminated and padded to 4 bytes; the CRC follows.
+	end := bytes.IndexByte(data, 0)
+	if end <= 0 {
+		return "", 0, false
+	}
+	off := (end + 4) &^ 3
+	if off+4 > len(data) {
+		return "", 0, false
+	}
+	return string(data[:end]), f.ByteOrder.Uint32(data[off:]), true
+}
+
+// fetchDebuginfo downloads the debug file with build ID buildID from the
+// debuginfod server at url. It returns nil if the server does not have it.
+func fetchDebuginfo(url, buildID string) (*elf.File, error) {
+	client := &http.Client{Timeout: debuginfodTimeout}
+	resp, err := client.Get(strings.TrimSuffix(url, "/") + "/buildid/" + buildID + "/debuginfo")
+	if err != nil {
+		return nil, fmt.Errorf("debuginfod: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("debuginfod %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("debuginfod %s: %w", url, err)
+	}
+	if len(data) > maxDecompressedSize {
+		return nil, fmt.Errorf("debuginfod %s: debug file exceeds %d bytes", url, maxDecompressedSize)
+	}
+	debug, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("debuginfod %s: parse debug file: %w", url, err)
+	}
+	return debug, nil
+}
+
+// applyDebugSymbols names the candidates at the function symbols of the
+// debug file of f and raises them to ConfidenceHigh.
+func (o *options) applyDebugSymbols(candidates []FunctionCandidate, f *elf.File) error {
+	debug, err := o.openDebugFile(f, "")
+	if errors.Is(err, ErrNoDebugFile) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer debug.Close()
+	syms, err := debug.Symbols()
+	if err != nil {
+		return fmt.Errorf("debug file: read symbols: %w", err)
+	}
+	exec := execRanges(debug)
+	names := make(map[uint64]string)
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) == elf.STT_FUNC && inRanges(s.Value, exec) {
+			if _, ok := names[s.Value]; !ok {
+				names[s.Value] = s.Name
+			}
+		}
+	}
+	for i, c := range candidates {
+		if name, ok := names[c.Address]; ok {
+			candidates[i].Confidence = ConfidenceHigh
+			if candidates[i].Name == "" {
+				candidates[i].Name = name
+			}
+		}
+	}
+	return nil
+}
+
+// Verification measures function candidates against a ground truth: the
+// symbol table of a debug file, or the pclntab of a Go binary.
+type Verification struct {
+	// Functions is the number of functions in the ground truth.
+	Functions int `json:"functions"`
+	// TruePositives is the number of candidates at a function.
+	TruePositives int `json:"true_positives"`
+	// FalsePositives holds the addresses of the other candidates.
+	FalsePositives []uint64 `json:"false_positives,omitempty"`
+	// Missed holds the addresses of the functions no candidate is at, in
+	// ascending order.
+	Missed []uint64 `json:"missed,omitempty"`
+	// Precision is the share of the candidates that are true positives.
+	Precision float64 `json:"precision"`
+	// Recall is the share of the functions that were found.
+	Recall float64 `json:"recall"`
+}
+
+// VerifyCandidates checks candidates, detected in a binary at its link
+// addresses, against the STT_FUNC symbols within executable sections of
+// debug, its debug file, e.g. as returned by OpenDebugFile. This makes
+// the debug file an accuracy oracle where only the stripped binary ships.
+func VerifyCandidates(candidates []FunctionCandidate, debug *elf.File) (*Verification, error) {
+	truth, err := functionSymbols(debug)
+	if err != nil {
+		return nil, fmt.Errorf("debug file: %w", err)
+	}
+	return verify(candidates, truth), nil
+}
+
+// verify measures candidates against the function entries in truth.
+func verify(candidates []FunctionCandidate, truth map[uint64]struct{}) *Verification {
+	v := &Verification{Functions: len(truth)}
+	found := make(map[uint64]bool, len(candidates))
+	for _, c := range candidates {
+		if found[c.Address] {
+			continue
+		}
+		found[c.Address] = true
+		if _, ok := truth[c.Address]; ok {
+			v.TruePositives++
+		} else {
+			v.FalsePositives = append(v.FalsePositives, c.Address)
+		}
+	}
+	for addr := range truth {
+		if !found[addr] {
+			v.Missed = append(v.Missed, addr)
+		}
+	}
+	slices.Sort(v.Missed)
+	if n := v.TruePositives + len(v.FalsePositives); n > 0 {
+		v.Precision = float64(v.TruePositives) / float64(n)
+	}
+	if v.Functions > 0 {
+		v.Recall = float64(v.TruePositives) / float64(v.Functions)
+	}
+	return v
+}
@@ -0,0 +1,136 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestOpenDebugFile(t *testing.T) {
+	for _, tool := range []string{"gcc", "objcopy"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found, skipping", tool)
+		}
+	}
+
+	// demo-app carries a build ID and a .gnu_debuglink to demo-app.debug,
+	// which holds the symbols stripped from it.
+	const id = "00112233445566778899aabbccddeeff00112233"
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "demo-app")
+	debugFile := filepath.Join(dir, "demo-app.debug")
+	for _, args := range [][]string{
+		{"gcc", "-O0", "-g", "-Wl,--build-id=0x" + id, "-o", bin, "testdata/demo-app.c"},
+		{"objcopy", "--only-keep-debug", bin, debugFile},
+		{"objcopy", "--strip-all", "--add-gnu-debuglink=" + debugFile, bin},
+	} {
+		if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			t.Fatalf("%s: %v\n%s", args[0], err, out)
+		}
+	}
+	debugData, err := os.ReadFile(debugFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := elf.Open(bin)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	buildIDDir := t.TempDir()
+	buildIDPath := filepath.Join(buildIDDir, ".build-id", id[:2], id[2:]+".debug")
+	if err := os.MkdirAll(filepath.Dir(buildIDPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(buildIDPath, debugData, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/buildid/"+id+"/debuginfo" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(debugData)
+	}))
+	defer server.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	empty := t.TempDir()
+
+	tests := []struct {
+		name    string
+		path    string
+		opts    []resurgo.Option
+		wantErr error
+	}{
+		{name: "debuglink", path: bin, opts: []resurgo.Option{resurgo.WithDebugDirs(empty)}},
+		{name: "build-id", opts: []resurgo.Option{resurgo.WithDebugDirs(buildIDDir)}},
+		{name: "debuginfod", opts: []resurgo.Option{resurgo.WithDebugDirs(empty), resurgo.WithDebuginfod(server.URL)}},
+		{name: "missing", opts: []resurgo.Option{resurgo.WithDebugDirs(empty)}, wantErr: resurgo.ErrNoDebugFile},
+		{name: "missing on debuginfod", opts: []resurgo.Option{resurgo.WithDebugDirs(empty), resurgo.WithDebuginfod(server.URL + "/other")}, wantErr: resurgo.ErrNoDebugFile},
+		{name: "debuginfod after a failing server", opts: []resurgo.Option{resurgo.WithDebugDirs(empty), resurgo.WithDebuginfod(failing.URL, server.URL)}},
+		{name: "missing after a failing server", opts: []resurgo.Option{resurgo.WithDebugDirs(empty), resurgo.WithDebuginfod(failing.URL, server.URL+"/other")}, wantErr: resurgo.ErrNoDebugFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			debug, err := resurgo.OpenDebugFile(f, tt.path, tt.opts...)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("OpenDebugFile: got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OpenDebugFile: %v", err)
+			}
+			defer debug.Close()
+
+			candidates, err := resurgo.DetectFunctionsFromELF(f)
+			if err != nil {
+				t.Fatalf("DetectFunctionsFromELF: %v", err)
+			}
+			v, err := resurgo.VerifyCandidates(candidates, debug)
+			if err != nil {
+				t.Fatalf("VerifyCandidates: %v", err)
+			}
+			if v.Functions == 0 || v.TruePositives == 0 || v.Recall <= 0 || v.Precision <= 0 {
+				t.Errorf("got verification %+v, want matches", v)
+			}
+			if v.TruePositives+len(v.Missed) != v.Functions {
+				t.Errorf("%d true positives and %d missed of %d functions", v.TruePositives, len(v.Missed), v.Functions)
+			}
+		})
+	}
+
+	// Only the failure of every server is an error.
+	if _, err := resurgo.OpenDebugFile(f, "", resurgo.WithDebugDirs(empty), resurgo.WithDebuginfod(failing.URL)); err == nil || errors.Is(err, resurgo.ErrNoDebugFile) {
+		t.Errorf("OpenDebugFile: got error %v, want the server failure", err)
+	}
+
+	// WithDebugSymbols names the confirmed candidates.
+	candidates, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithDebugSymbols(true), resurgo.WithDebugDirs(buildIDDir))
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromELF: %v", err)
+	}
+	named := make(map[string]resurgo.Confidence)
+	for _, c := range candidates {
+		if c.Name != "" {
+			named[c.Name] = c.Confidence
+		}
+	}
+	for _, name := range []string{"main", "multiply"} {
+		if confidence, ok := named[name]; !ok || confidence != resurgo.ConfidenceHigh {
+			t.Errorf("%s: named %t with confidence %q, want a high-confidence candidate", name, ok, confidence)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package resurgo
+
+import (
+	"cmp"
+	"debug/elf"
+	"maps"
+	"math"
+	"slices"
+
+	"golang.org/x/arch/arm64/arm64asm"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+// DetectionRecursiveDescent is assigned to function candidates reached by
+// following the control flow of the file from its entry point and known
+// functions, as the target of a direct call, with no other signal.
+const DetectionRecursiveDescent DetectionType = "recursive-descent"
+
+// WithRecursiveDescent sets whether DisasmDetector also follows the control
+// flow of the file from its known functions (default false), as
+// RecursiveDescentDetector does. The call targets it reaches are confirmed
+// functions: the candidates of the linear sweep at one of them are raised
+// to ConfidenceHigh, and the others, whose entries no prologue pattern
+// matched, are added as DetectionRecursiveDescent candidates.
+func WithRecursiveDescent(enabled bool) Option {
+	return func(o *options) {
+		o.recursiveDescent = enabled
+	}
+}
+
+// RecursiveDescentDetector is a CandidateDetector that disassembles f by
+// recursive descent: starting at the functions the file names itself (the
+// entry point, DT_INIT and DT_FINI, the exported functions and the function
+// symbols, as emitted by AnchorDetector and SymbolDetector), it follows
+// fall-through and direct branches, and emits the target of every direct
+// call it reaches, with DetectionRecursiveDescent, ConfidenceHigh and its
+// callers in CalledFrom. Execution paths end at returns, indirect jumps
+// and traps; code only reached through a function pointer is left to the
+// linear sweep. Only x86 and ARM64 files are supported; others emit no
+// candidates. It is not part of the default pipeline; WithRecursiveDescent
+// combines it with DisasmDetector.
+func RecursiveDescentDetector(f *elf.File) ([]FunctionCandidate, error) {
+	o := newOptions().withBudget()
+	arch, err := elfArch(f)
+	if err != nil {
+		return nil, err
+	}
+	regions, err := elfCodeSections(f, arch, o.budget)
+	if err != nil {
+		return nil, err
+	}
+	o = o.withByteOrder(elfCodeByteOrder(f, arch))
+	return o.recursiveDescentCandidates(f, regions, arch)
+}
+
+// recursiveDescentCandidates returns the call targets reached from the
+// known functions of f through the code in regions.
+func (o *options) recursiveDescentCandidates(f *elf.File, regions []codeSection, arch Arch) ([]FunctionCandidate, error) {
+	if !descentArch(arch) {
+		return nil, nil
+	}
+	roots, err := descentRoots(f)
+	if err != nil {
+		return nil, err
+	}
+	calls, _, err := o.descend(regions, arch, roots)
+	if err != nil {
+		return nil, err
+	}
+	return descentCandidates(calls), nil
+}
+
+// descentCandidates returns the targets of calls, ordered by target, as
+// DetectionRecursiveDescent candidates.
+func descentCandidates(calls []branch) []FunctionCandidate {
+	var candidates []FunctionCandidate
+	for _, call := range calls {
+		if n := len(candidates); n > 0 && candidates[n-1].Address == call.target {
+			candidates[n-1].CalledFrom = append(candidates[n-1].CalledFrom, call.source)
+			continue
+		}
+		candidates = append(candidates, FunctionCandidate{
+			Address:       call.target,
+			DetectionType: DetectionRecursiveDescent,
+			CalledFrom:    []uint64{call.source},
+			Confidence:    ConfidenceHigh,
+		})
+	}
+	return candidates
+}
+
+// descentRoots returns the addresses recursive descent starts from: those
+// of the candidates of AnchorDetector and SymbolDetector, in ascending
+// order.
+func descentRoots(f *elf.File) ([]uint64, error) {
+	var roots []uint64
+	for _, detect := range []CandidateDetector{AnchorDetector, SymbolDetector} {
+		candidates, err := detect(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range candidates {
+			roots = append(roots, c.Address)
+		}
+	}
+	slices.Sort(roots)
+	return slices.Compact(roots), nil
+}
+
+// descentArch reports whether recursive descent supports code of arch.
+func descentArch(arch Arch) bool {
+	return arch == ArchAMD64 || arch == ArchX86 || arch == ArchARM64
+}
+
+// descend follows the control flow of the code of arch in regions from
+// roots, and returns the direct calls it reaches, ordered by target then
+// source, and the [lo, hi) ranges of the instructions it decodes, sorted
+// and coalesced. Regions of another architecture, such as Thumb regions,
+// are not entered. A call to the next instruction, which reads the program
+// counter rather than calling a function, is followed as a fall-through.
+func (o *options) descend(regions []codeSection, arch Arch, roots []uint64) (calls []branch, reached [][2]uint64, err error) {
+	code := make([]codeSection, 0, len(regions))
+	for _, region := range regions {
+		if cmp.Or(region.arch, arch) != arch {
+			continue
+		}
+		le, err := o.littleEndianCode(region.code, arch)
+		if err != nil {
+			return nil, nil, err
+		}
+		code = append(code, codeSection{code: le, addr: region.addr})
+	}
+
+	// visited maps the address of each decoded instruction to its length.
+	visited := make(map[uint64]int)
+	work := slices.Clone(roots)
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+		for {
+			if _, ok := visited[addr]; ok {
+				break
+			}
+			region, ok := regionAt(code, addr)
+			if !ok {
+				break
+			}
+			off := int(addr - region.addr)
+			if err := o.budget.step(off); err != nil {
+				return nil, nil, err
+			}
+			step, ok := decodeFlow(region.code[off:], addr, arch, o.addressWrap)
+			visited[addr] = step.length
+			if !ok {
+				break
+			}
+			next := addr + uint64(step.length)
+			if br := step.branch; br != nil && br.target != next {
+				if br.call {
+					calls = append(calls, *br)
+				}
+				work = append(work, br.target)
+			}
+			if !step.falls {
+				break
+			}
+			addr = next
+		}
+	}
+	slices.SortFunc(calls, func(a, b branch) int {
+		return cmp.Or(cmp.Compare(a.target, b.target), cmp.Compare(a.source, b.source))
+	})
+
+	for _, addr := range slices.Sorted(maps.Keys(visited)) {
+		end := addr + uint64(visited[addr])
+		if n := len(reached); n > 0 && addr <= reached[n-1][1] {
+			reached[n-1][1] = max(reached[n-1][1], end)
+			continue
+		}
+		if end > addr {
+			reached = append(reached, [2]uint64{addr, end})
+		}
+	}
+	return calls, reached, nil
+}
+
+// flowStep describes the control flow of one instruction.
+type flowStep struct {
+	// length is the size of the instruction.
+	length int
+	// branch is its direct call or jump, if any.
+	branch *branch
+	// falls reports whether execution may continue with the next
+	// instruction.
+	falls bool
+}
+
+// decodeFlow decodes the instruction of arch at the start of code, mapped
+// at addr. ok is false when it does not decode. Targets that wrap around
+// the address space are dropped unless wrap is set.
+func decodeFlow(code []byte, addr uint64, arch Arch, wrap bool) (step flowStep, ok bool) {
+	switch arch {
+	case ArchAMD64, ArchX86:
+		mode := 64
+		if arch == ArchX86 {
+			mode = 32
+		}
+		return decodeFlowAMD64(code, addr, mode, wrap)
+	case ArchARM64:
+		return decodeFlowARM64(code, addr, wrap)
+	}
+	return flowStep{}, false
+}
+
+func decodeFlowAMD64(code []byte, addr uint64, mode int, wrap bool) (flowStep, bool) {
+	// x86asm does not decode ENDBR64 and ENDBR32.
+	if isENDBR(code, 0) {
+		return flowStep{length: 4, falls: true}, true
+	}
+	inst, err := x86asm.Decode(code, mode)
+	if err != nil {
+		return flowStep{}, false
+	}
+	step := flowStep{length: inst.Len, falls: true}
+	switch inst.Op {
+	case x86asm.RET, x86asm.LRET, x86asm.IRET, x86asm.IRETD, x86asm.IRETQ, x86asm.HLT, x86asm.JMP:
+		step.falls = false
+	default:
+		step.falls = !isTrapAMD64(inst)
+	}
+	if rel, ok := inst.Args[0].(x86asm.Rel); ok {
+		target, ok := relTarget(addr+uint64(inst.Len), int64(rel), wrap)
+		if mode == 32 && target > math.MaxUint32 {
+			ok = wrap
+			target &= math.MaxUint32
+		}
+		if ok {
+			step.branch = &branch{
+				source:      addr,
+				target:      target,
+				call:        inst.Op == x86asm.CALL,
+				conditional: inst.Op != x86asm.CALL && inst.Op != x86asm.JMP,
+			}
+		}
+	}
+	return step, true
+}
+
+func decodeFlowARM64(code []byte, addr uint64, wrap bool) (flowStep, bool) {
+	if len(code) < 4 {
+		return flowStep{}, false
+	}
+	inst, err := arm64asm.Decode(code[:4])
+	if err != nil {
+		return flowStep{}, false
+	}
+	step := flowStep{length: 4, falls: true}
+	switch inst.Op {
+	case arm64asm.RET, arm64asm.BR, arm64asm.ERET, arm64asm.BRK, arm64asm.HLT:
+		step.falls = false
+	case arm64asm.B:
+		step.falls = isConditionalARM64(inst)
+	}
+	switch inst.Op {
+	case arm64asm.B, arm64asm.BL, arm64asm.CBZ, arm64asm.CBNZ, arm64asm.TBZ, arm64asm.TBNZ:
+		for _, arg := range inst.Args {
+			pcrel, ok := arg.(arm64asm.PCRel)
+			if !ok {
+				continue
+			}
+			if target, ok := relTarget(addr, int64(pcrel), wrap); ok {
+				step.branch = &branch{
+					source:      addr,
+					target:      target,
+					call:        inst.Op == arm64asm.BL,
+					conditional: inst.Op != arm64asm.BL && step.falls,
+				}
+			}
+			break
+		}
+	}
+	return step, true
+}
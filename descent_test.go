@@ -0,0 +1,110 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+// descentSource holds frameless functions only reached from main, which is
+// exported so that recursive descent starts from it once symbols are
+// stripped.
+const descentSource = `#include <stdio.h>
+__attribute__((noinline)) static int square(int x) { return x * x; }
+__attribute__((noinline)) static int twice(int x) { return square(x + 1) * 2; }
+int main(int argc, char **argv) { printf("%d\n", twice(argc)); return 0; }
+`
+
+func TestRecursiveDescent(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("recursive descent test requires an amd64 compiler")
+	}
+	for _, tool := range []string{"gcc", "llvm-objcopy"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found, skipping", tool)
+		}
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "descent.c")
+	if err := os.WriteFile(src, []byte(descentSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	binPath := filepath.Join(dir, "descent")
+	cmd := exec.Command("gcc", "-O2", "-fno-align-functions", "-Wl,--export-dynamic-symbol=main", "-o", binPath, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("gcc cannot export main: %v\n%s", err, out)
+	}
+	strippedPath := binPath + ".stripped"
+	if out, err := exec.Command("llvm-objcopy", "--strip-all", binPath, strippedPath).CombinedOutput(); err != nil {
+		t.Fatalf("llvm-objcopy: %v\n%s", err, out)
+	}
+
+	full, err := elf.Open(binPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer full.Close()
+	syms, err := full.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	want := map[string]uint64{"square": 0, "twice": 0}
+	for _, s := range syms {
+		if _, ok := want[s.Name]; ok && elf.ST_TYPE(s.Info) == elf.STT_FUNC {
+			want[s.Name] = s.Value
+		}
+	}
+
+	f, err := elf.Open(strippedPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	reached, err := resurgo.RecursiveDescentDetector(f)
+	if err != nil {
+		t.Fatalf("resurgo.RecursiveDescentDetector: %v", err)
+	}
+	byAddr := make(map[uint64]resurgo.FunctionCandidate)
+	for _, c := range reached {
+		if c.DetectionType != resurgo.DetectionRecursiveDescent || c.Confidence != resurgo.ConfidenceHigh || len(c.CalledFrom) == 0 {
+			t.Errorf("0x%x: got %s/%s called from %#x", c.Address, c.DetectionType, c.Confidence, c.CalledFrom)
+		}
+		byAddr[c.Address] = c
+	}
+	for name, addr := range want {
+		if _, ok := byAddr[addr]; !ok {
+			t.Errorf("%s at 0x%x not reached: %v", name, addr, reached)
+		}
+	}
+
+	// The linear sweep finds the frameless functions as call targets only;
+	// recursive descent confirms them.
+	for _, tt := range []struct {
+		enabled bool
+		want    resurgo.Confidence
+	}{
+		{false, resurgo.ConfidenceMedium},
+		{true, resurgo.ConfidenceHigh},
+	} {
+		detect := resurgo.NewDisasmDetector(resurgo.WithRecursiveDescent(tt.enabled))
+		candidates, err := detect(f)
+		if err != nil {
+			t.Fatalf("disasm detector: %v", err)
+		}
+		got := make(map[uint64]resurgo.FunctionCandidate)
+		for _, c := range candidates {
+			got[c.Address] = c
+		}
+		for name, addr := range want {
+			if c := got[addr]; c.Confidence != tt.want {
+				t.Errorf("recursive descent %t: %s at 0x%x has confidence %q, want %s", tt.enabled, name, addr, c.Confidence, tt.want)
+			}
+		}
+	}
+}
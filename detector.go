@@ -3,15 +3,23 @@ package resurgo
 import (
 	"cmp"
 	"debug/elf"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"slices"
+	"strings"
+	"time"
 
 	"golang.org/x/arch/arm64/arm64asm"
 	"golang.org/x/arch/x86/x86asm"
 )
 
 const (
+	// DefaultMaxFrameSize is the largest stack allocation, in bytes, that a
+	// prologue may report before it is discarded as implausible. Immediates
+	// decoded out of data embedded in code routinely exceed it.
+	DefaultMaxFrameSize = 1 << 20
+
 	// Confidence levels ordered from highest to lowest reliability.
 	ConfidenceHigh   Confidence = "high"
 	ConfidenceMedium Confidence = "medium"
@@ -60,6 +68,41 @@ type FunctionCandidate struct {
 	JumpedFrom []uint64 `json:"jumped_from,omitempty"`
 	// Confidence is the reliability level of this candidate.
 	Confidence Confidence `json:"confidence"`
+	// Score refines Confidence into the estimated probability, from 0 to 1,
+	// that Address is a function entry. It combines the matched prologue
+	// pattern, the boundary context, the call and jump sites and the other
+	// signals behind the candidate, and is never below 0.8 for
+	// ConfidenceHigh nor 0.5 for ConfidenceMedium. Set by
+	// DetectFunctionsFromELF, for callers trading precision against recall
+	// with a threshold of their own.
+	Score float64 `json:"score,omitempty"`
+	// Boundary reports whether the code preceding Address ends a function:
+	// a return, jump or trap, possibly followed by padding.
+	Boundary bool `json:"boundary,omitempty"`
+	// Section is the name of the ELF section holding Address, set by
+	// DetectFunctionsFromELF when WithSectionNames is enabled.
+	Section string `json:"section,omitempty"`
+	// Permissions are the access permissions of the PT_LOAD segment
+	// holding Address, e.g. "r-x", set along with Section for linked files.
+	Permissions string `json:"permissions,omitempty"`
+	// Kind classifies the code at Address when it is not ordinary compiled
+	// code, e.g. KindPLTStub.
+	Kind FunctionKind `json:"kind,omitempty"`
+	// Name is the conventional name of a recognized function, e.g.
+	// frame_dummy for KindCRT; stripped binaries carry no other names.
+	Name string `json:"name,omitempty"`
+	// End is the estimated address following the last instruction of the
+	// function, set when WithFunctionSizes is enabled; zero when unknown.
+	End uint64 `json:"end,omitempty"`
+}
+
+// Size returns the estimated size in bytes of the function, or zero when
+// its End is unknown.
+func (c FunctionCandidate) Size() uint64 {
+	if c.End <= c.Address {
+		return 0
+	}
+	return c.End - c.Address
 }
 
 // CandidateDetector reads an ELF file and emits function candidates.
@@ -73,6 +116,158 @@ type Option func(*options)
 type options struct {
 	detectors []CandidateDetector
 	filters   []CandidateFilter
+
+	// patternTolerance is the maximum number of benign instructions allowed
+	// between consecutive elements of a multi-instruction prologue pattern.
+	patternTolerance int
+
+	// contextWindows overrides the boundary context window per prologue type.
+	contextWindows map[PrologueType]ContextWindow
+	// boundaryStrategies overrides the boundary predicate per prologue
+	// type.
+	boundaryStrategies map[PrologueType]BoundaryStrategy
+
+	// maxFrameSize is the largest plausible prologue frame size; zero
+	// disables the check.
+	maxFrameSize uint64
+
+	// trapBoundaries treats trap instructions as function terminators.
+	trapBoundaries bool
+
+	// byteOrder is the byte order of instruction words in the input; nil
+	// means the order the architecture stores instructions in.
+	byteOrder binary.ByteOrder
+
+	// addressWrap keeps branch targets that wrap around the address space.
+	addressWrap bool
+
+	// toolchain selects the heuristic profile.
+	toolchain Toolchain
+
+	// allPrologues enables every prologue pattern regardless of the
+	// toolchain profile.
+	allPrologues bool
+
+	// alignmentSignal upgrades prologue candidates that sit at an alignment
+	// boundary to ConfidenceHigh.
+	alignmentSignal bool
+
+	// minConfidence drops candidates below the given confidence once the
+	// filters have run; empty keeps all candidates.
+	minConfidence Confidence
+
+	// limits bounds the resources of an analysis; budget tracks the
+	// resources consumed by the running one.
+	limits Limits
+	budget *budget
+
+	// syntax selects the rendering of instruction text; empty keeps the
+	// pattern summaries.
+	syntax Syntax
+
+	// telemetry, when set, receives the telemetry of DetectFunctionsFromELF.
+	telemetry *Telemetry
+
+	// anchors seeds DetectFunctionsFromELF with the candidates of
+	// AnchorDetector and keeps them through filtering.
+	anchors bool
+	// packedCheck rejects packed ELF, PE and Mach-O files.
+	packedCheck bool
+	// sectionNames annotates the candidates of DetectFunctionsFromELF with
+	// their section.
+	sectionNames bool
+	// pltStubs reports the results inside PLT sections, tagged KindPLTStub.
+	pltStubs bool
+	// crtLabels labels and keeps the C runtime functions, with KindCRT.
+	crtLabels bool
+	// ifuncLabels tags the IFUNC resolvers and their implementations.
+	ifuncLabels bool
+	// loadBias is added to the addresses reported for ELF shared objects.
+	loadBias uint64
+	// symbols merges the function symbols of the file into the results.
+	symbols bool
+	// merge is the policy the candidates of the detectors are merged with.
+	merge MergeOptions
+	// scoreWeights, when set, replaces the default score weights.
+	scoreWeights *ScoreWeights
+
+	// branchTargets enumerates branch target markers as function-start
+	// candidates.
+	branchTargets bool
+	// recursiveDescent confirms the call targets reached from the known
+	// functions by following the control flow.
+	recursiveDescent bool
+	// hybridSweep claims the code reachable from the known functions
+	// before sweeping the rest.
+	hybridSweep bool
+	// supersetVoting keeps the x86 prologues the decodings of the
+	// preceding bytes converge on.
+	supersetVoting bool
+	// gapFilling scans the gaps between the function starts of the first
+	// pass again with relaxed boundary conditions.
+	gapFilling bool
+	// functionSizes sets the estimated End of the function candidates.
+	functionSizes bool
+	// paddingRegions reports the padding between functions in the
+	// results.
+	paddingRegions bool
+	// jumpTableSkipping leaves the jump tables of x86-64 code out of the
+	// sweep.
+	jumpTableSkipping bool
+
+	// arch and baseAddr describe raw input, which has no header.
+	arch     Arch
+	baseAddr uint64
+
+	// throttle paces the analysis.
+	throttle Throttle
+
+	// calibration, when set, rescores the candidates once the filters have
+	// run.
+	calibration *Calibration
+
+	// debugSymbols names and confirms the candidates with the symbols of
+	// the separate debug file, looked up in debugDirs and from the
+	// debuginfod servers.
+	debugSymbols bool
+	debugDirs    []string
+	debuginfod   []string
+
+	// abi selects the x86-64 calling convention; empty means ABISysV, or
+	// ABIWin64 for PE files. calleeSavedRegs, when set, overrides its
+	// callee-saved registers.
+	abi             ABI
+	calleeSavedRegs []string
+
+	// entryPoints pairs the global and local entry points of ppc64le
+	// functions, from the symbol table of the analyzed file.
+	entryPoints ppc64EntryPoints
+
+	// compressedDepth is the number of compressed streams the analyzed data
+	// was decompressed from.
+	compressedDepth int
+}
+
+// newOptions returns the default options with opts applied. The default
+// detector pipeline is bound to the returned options so that settings such
+// as WithPatternTolerance reach the disassembly-based detector.
+func newOptions(opts ...Option) *options {
+	o := &options{
+		maxFrameSize:   DefaultMaxFrameSize,
+		trapBoundaries: true,
+		toolchain:      ToolchainGeneric,
+		anchors:        true,
+		packedCheck:    true,
+		pltStubs:       true,
+		ifuncLabels:    true,
+		debugDirs:      []string{defaultDebugDir},
+	}
+	o.detectors = []CandidateDetector{o.disasmDetector, EhFrameDetector}
+	o.filters = DefaultFilters()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
 }
 
 // WithDetectors replaces the default detector pipeline with the provided
@@ -84,154 +279,597 @@ func WithDetectors(detectors ...CandidateDetector) Option {
 	}
 }
 
+// WithPatternTolerance allows up to n benign instructions (NOPs, ENDBR64,
+// BTI landing pads, xchg ax, ax) between consecutive elements of a
+// multi-instruction prologue pattern, e.g. between push rbp and mov rbp, rsp.
+// Hot-patch padding and CFI landing pads otherwise break pattern recognition.
+// The default is 0: elements must be adjacent, apart from ENDBR64 which is
+// always transparent on AMD64.
+func WithPatternTolerance(n int) Option {
+	return func(o *options) {
+		o.patternTolerance = n
+	}
+}
+
+// WithMaxFrameSize discards prologues whose stack allocation exceeds n bytes.
+// A sub rsp, imm decoded out of data embedded in code carries an arbitrary
+// immediate; bounding it removes those false positives. Only patterns whose
+// sole evidence is the allocation itself are bounded: a push rbp; mov rbp, rsp
+// sequence followed by a large allocation is kept. The default is
+// DefaultMaxFrameSize; n == 0 disables the check.
+func WithMaxFrameSize(n uint64) Option {
+	return func(o *options) {
+		o.maxFrameSize = n
+	}
+}
+
+// WithByteOrder sets the byte order of the instruction words in the raw
+// bytes passed to DetectPrologues and DetectCallSites. The default is the
+// order the architecture stores instructions in: big-endian for ppc64 and
+// little-endian otherwise, including AArch64 and ARM32 on big-endian (BE8)
+// systems. It is only needed for dumps whose 32-bit words were byte-swapped,
+// e.g. read word by word from a big-endian target, or for legacy BE32 ARM
+// code. x86, RISC-V and ppc64le code is always little-endian; requesting
+// big-endian for it is an error, as it is for Xtensa, whose big-endian
+// variant is not supported. ELF files carry their byte order in their
+// header, which takes precedence.
+func WithByteOrder(order binary.ByteOrder) Option {
+	return func(o *options) {
+		o.byteOrder = order
+	}
+}
+
+// allocationOnlyPrologues lists the prologue types made of a single stack
+// allocation, subject to the WithMaxFrameSize bound.
+var allocationOnlyPrologues = []PrologueType{
+	PrologueNoFramePointer,
+	PrologueLEABased,
+	PrologueEnter,
+	PrologueSubSP,
+	PrologueAddiSP,
+	PrologueStduSP,
+}
+
 // DetectFunctionsFromELF returns detected function candidates from f by running all
 // detectors then all filters in order.
 //
 // By default the detector pipeline is [DisasmDetector, EhFrameDetector] and
 // the filter pipeline is [CETFilter, EhFrameFilter, PLTFilter].
 // opts may include WithDetectors or WithFilters to replace either pipeline.
+// AnchorDetector runs after the detectors, and the anchors it emits are
+// restored after filtering; WithAnchors(false) disables both. WithSymbols
+// merges the function symbols of f in the same way.
+// The result holds one candidate per address, ordered by address, and is
+// identical across runs on the same input. WithLoadBias relocates the
+// candidates of PIE executables and shared libraries to their runtime
+// addresses.
 func DetectFunctionsFromELF(f *elf.File, opts ...Option) ([]FunctionCandidate, error) {
-	o := &options{
-		detectors: []CandidateDetector{DisasmDetector, EhFrameDetector},
-		filters:   []CandidateFilter{CETFilter, EhFrameFilter, PLTFilter},
+	o := newOptions(opts...)
+	if err := o.checkPackedELF(f); err != nil {
+		return nil, err
 	}
-	for _, opt := range opts {
-		opt(o)
+	// The default detectors are bound to o and share its budget.
+	o.budget = newBudget(o.limits, o.throttle)
+
+	start := time.Now()
+	var fdes []fdeInfo
+	if t := o.telemetry; t != nil {
+		*t = Telemetry{}
+		fdes = t.collectSignals(f)
 	}
 
-	var candidates []FunctionCandidate
-	for _, detect := range o.detectors {
+	detectors := o.detectors
+	if o.anchors {
+		detectors = append(slices.Clone(detectors), AnchorDetector)
+	}
+	var candidates, anchors []FunctionCandidate
+	for i, detect := range detectors {
+		stageStart := time.Now()
 		candidate, err := detect(f)
 		if err != nil {
 			return nil, err
 		}
-		candidates = mergeCandidates(candidates, candidate)
+		if o.anchors && i == len(detectors)-1 {
+			anchors = candidate
+		}
+		if t := o.telemetry; t != nil {
+			t.Detectors = append(t.Detectors, StageTelemetry{
+				Name:       stageName(detect),
+				Candidates: len(candidate),
+				Elapsed:    time.Since(stageStart),
+			})
+		}
+		candidates = mergeWithin(candidates, candidate, o.merge)
+		if err := o.budget.results(len(candidates)); err != nil {
+			return nil, err
+		}
 	}
 
-	var err error
 	for _, filter := range o.filters {
+		stageStart, before := time.Now(), len(candidates)
+		in := make(map[uint64]struct{}, len(candidates))
+		for _, c := range candidates {
+			in[c.Address] = struct{}{}
+		}
+		var err error
 		candidates, err = filter(candidates, f)
 		if err != nil {
 			return nil, err
 		}
+		candidates = keepFiltered(in, candidates)
+		if t := o.telemetry; t != nil {
+			t.Filters = append(t.Filters, StageTelemetry{
+				Name:       stageName(filter),
+				Candidates: len(candidates),
+				Dropped:    before - len(candidates),
+				Elapsed:    time.Since(stageStart),
+			})
+		}
+		if err := o.budget.expired(); err != nil {
+			return nil, err
+		}
+	}
+	if o.calibration != nil {
+		candidates = o.calibration.apply(candidates)
 	}
+	if o.minConfidence != "" {
+		candidates = slices.DeleteFunc(candidates, func(c FunctionCandidate) bool {
+			return confidenceRank(c.Confidence) < confidenceRank(o.minConfidence)
+		})
+	}
+	if len(anchors) > 0 {
+		// Exported functions are named and confirmed like symbols.
+		candidates = mergeSymbols(candidates, anchors)
+	}
+	if o.symbols {
+		symbols, err := SymbolDetector(f)
+		if err != nil {
+			return nil, err
+		}
+		candidates = mergeSymbols(candidates, symbols)
+	}
+	if o.crtLabels {
+		var err error
+		if candidates, err = o.labelCRT(candidates, f); err != nil {
+			return nil, err
+		}
+	}
+	if err := o.labelIfuncs(candidates, f); err != nil {
+		return nil, err
+	}
+	if o.debugSymbols {
+		if err := o.applyDebugSymbols(candidates, f); err != nil {
+			return nil, err
+		}
+	}
+	candidates = o.markPLTStubs(candidates, f)
+	if o.functionSizes {
+		if err := o.estimateSizes(candidates, f); err != nil {
+			return nil, fmt.Errorf("failed to estimate function sizes: %w", err)
+		}
+	}
+	if o.sectionNames {
+		annotateSections(candidates, f)
+	}
+	o.scoreCandidates(candidates)
 
+	if t := o.telemetry; t != nil {
+		t.summarize(candidates, f, fdes)
+		t.Elapsed = time.Since(start)
+	}
+	o.rebaseCandidates(candidates, f)
 	return candidates, nil
 }
 
 // DisasmDetector is a CandidateDetector that runs the disassembly-based
 // pipeline (prologue matching, call-site analysis, alignment-based boundary
-// detection) against the .text section of f.
-// The architecture is inferred from the ELF header.
+// detection) against the executable sections of f: every section flagged
+// SHF_EXECINSTR, e.g. .init, .plt, .text.hot or .text.unlikely, of a
+// linked file, and the .text section of a relocatable object, whose
+// sections all start at address zero. Calls in one section confirm entries
+// in another. The architecture is inferred from the ELF header.
 func DisasmDetector(f *elf.File) ([]FunctionCandidate, error) {
-	textSec := f.Section(".text")
-	if textSec == nil {
-		return nil, fmt.Errorf("no .text section found")
+	return newOptions().disasmDetector(f)
+}
+
+// NewDisasmDetector returns a DisasmDetector configured with opts, for use
+// with WithDetectors when the default pipeline is replaced.
+func NewDisasmDetector(opts ...Option) CandidateDetector {
+	return newOptions(opts...).disasmDetector
+}
+
+func (o *options) disasmDetector(f *elf.File) ([]FunctionCandidate, error) {
+	o = o.forFile(f).withBudget()
+
+	arch, err := elfArch(f)
+	if err != nil {
+		return nil, err
+	}
+	regions, err := elfCodeSections(f, arch, o.budget)
+	if err != nil {
+		return nil, err
 	}
+	o = o.withByteOrder(elfCodeByteOrder(f, arch))
+	if arch == ArchPPC64LE || arch == ArchPPC64 {
+		entries, err := ppc64LocalEntries(f)
+		if err != nil {
+			return nil, err
+		}
+		o = o.withEntryPoints(entries)
+	}
+	if o.hybridSweep {
+		return o.detectHybrid(f, regions, arch)
+	}
+	candidates, err := o.detectRegions(regions, arch)
+	if err != nil || !o.recursiveDescent {
+		return candidates, err
+	}
+	reached, err := o.recursiveDescentCandidates(f, regions, arch)
+	if err != nil {
+		return nil, err
+	}
+	return confirmCandidates(candidates, reached), nil
+}
 
-	code, err := textSec.Data()
-	if err != nil && err != io.EOF {
-		return nil, fmt.Errorf("failed to read .text section: %w", err)
+// elfCodeSections reads the code of f scanned by DisasmDetector, in file
+// order: the sections returned by disasmSections, split into their ARM and
+// Thumb regions on ARM. Linked files without executable sections, e.g.
+// whose section header table was stripped or mangled, fall back to their
+// executable PT_LOAD segments, returned by execSegments. The size of the
+// code is checked against b before it is read.
+func elfCodeSections(f *elf.File, arch Arch, b *budget) ([]codeSection, error) {
+	sections := disasmSections(f)
+	if len(sections) == 0 {
+		segments := execSegments(f)
+		if len(segments) == 0 {
+			return nil, fmt.Errorf("no executable section or segment found")
+		}
+		var size uint64
+		for _, p := range segments {
+			size += p.Filesz
+		}
+		if err := b.codeSize(size); err != nil {
+			return nil, err
+		}
+		regions := make([]codeSection, 0, len(segments))
+		for _, p := range segments {
+			code := make([]byte, p.Filesz)
+			if _, err := p.ReadAt(code, 0); err != nil && err != io.EOF {
+				return nil, fmt.Errorf("failed to read segment at %#x: %w", p.Vaddr, err)
+			}
+			regions = append(regions, codeSection{code: code, addr: p.Vaddr})
+		}
+		return regions, nil
 	}
 
-	var arch Arch
-	switch f.Machine {
-	case elf.EM_X86_64:
-		arch = ArchAMD64
-	case elf.EM_AARCH64:
-		arch = ArchARM64
-	default:
-		return nil, fmt.Errorf("unsupported ELF machine: %s", f.Machine)
+	var size uint64
+	for _, sec := range sections {
+		size += sec.Size
+	}
+	if err := b.codeSize(size); err != nil {
+		return nil, err
 	}
+	var regions []codeSection
+	for _, sec := range sections {
+		code, err := sec.Data()
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read %s section: %w", sec.Name, err)
+		}
+		if arch == ArchARM || arch == ArchARM64 {
+			regions = append(regions, armCodeSections(f, sec, code)...)
+		} else {
+			regions = append(regions, codeSection{code: code, addr: sec.Addr})
+		}
+	}
+	return regions, nil
+}
 
-	// Detect prologues
-	prologues, err := DetectPrologues(code, textSec.Addr, arch)
-	if err != nil {
-		return nil, fmt.Errorf("failed to detect prologues: %w", err)
+// execSegments returns the executable PT_LOAD segments of f with file
+// contents, for files whose section headers describe no code. Relocatable
+// objects have no segments, and files with executable sections, even
+// without contents as in separate debug files, return none.
+func execSegments(f *elf.File) []*elf.Prog {
+	if f.Type == elf.ET_REL || slices.ContainsFunc(f.Sections, func(sec *elf.Section) bool {
+		return sec.Flags&elf.SHF_EXECINSTR != 0
+	}) {
+		return nil
+	}
+	var segments []*elf.Prog
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_LOAD && p.Flags&elf.PF_X != 0 && p.Filesz > 0 {
+			segments = append(segments, p)
+		}
 	}
+	return segments
+}
 
-	// Detect call sites
-	edges, err := DetectCallSites(code, textSec.Addr, arch)
-	if err != nil {
-		return nil, fmt.Errorf("failed to detect call sites: %w", err)
+// isCodeSection reports whether sec holds code to scan: it is executable and
+// has contents, and is not the .altinstr_replacement section of kernel code,
+// whose instructions are patched over others at run time.
+func isCodeSection(sec *elf.Section) bool {
+	return sec.Flags&elf.SHF_EXECINSTR != 0 && sec.Type != elf.SHT_NOBITS && sec.Size > 0 &&
+		sec.Name != altInstrReplacement
+}
+
+// disasmSections returns the sections of f scanned by DisasmDetector, in
+// section header order.
+func disasmSections(f *elf.File) []*elf.Section {
+	if f.Type == elf.ET_REL {
+		if sec := f.Section(".text"); sec != nil {
+			return []*elf.Section{sec}
+		}
+		return nil
+	}
+	var sections []*elf.Section
+	for _, sec := range f.Sections {
+		if isCodeSection(sec) {
+			sections = append(sections, sec)
+		}
+	}
+	return sections
+}
+
+// WithSectionNames sets whether DetectFunctionsFromELF names the section
+// holding each candidate in its Section field, and the permissions of the
+// segment holding it in its Permissions field (default false).
+func WithSectionNames(enabled bool) Option {
+	return func(o *options) {
+		o.sectionNames = enabled
+	}
+}
+
+// annotateSections sets the Section of each of candidates to the name of the
+// allocated section of f holding its address, and its Permissions to those
+// of the segment holding it. In relocatable objects, whose sections overlap,
+// only the sections scanned by DisasmDetector are named.
+func annotateSections(candidates []FunctionCandidate, f *elf.File) {
+	sections := f.Sections
+	if f.Type == elf.ET_REL {
+		sections = disasmSections(f)
+	}
+	for i, c := range candidates {
+		candidates[i].Permissions = segmentPermissions(f, c.Address)
+		for _, sec := range sections {
+			if sec.Flags&elf.SHF_ALLOC != 0 && c.Address >= sec.Addr && c.Address-sec.Addr < sec.Size {
+				candidates[i].Section = sec.Name
+				break
+			}
+		}
+	}
+}
+
+// elfArch returns the architecture of the code in f, from its ELF header, or
+// the first architecture registered with RegisterArch that claims f.
+func elfArch(f *elf.File) (Arch, error) {
+	switch {
+	case f.Machine == elf.EM_X86_64:
+		return ArchAMD64, nil
+	case f.Machine == elf.EM_AARCH64:
+		return ArchARM64, nil
+	case f.Machine == elf.EM_386:
+		return ArchX86, nil
+	case f.Machine == elf.EM_RISCV && f.Class == elf.ELFCLASS64:
+		return ArchRISCV64, nil
+	case f.Machine == elf.EM_ARM:
+		return ArchARM, nil
+	case f.Machine == elf.EM_PPC64 && f.Data == elf.ELFDATA2LSB:
+		return ArchPPC64LE, nil
+	case f.Machine == elf.EM_PPC64:
+		return ArchPPC64, nil
+	case f.Machine == elf.EM_XTENSA:
+		return ArchXtensa, nil
+	}
+	if arch, ok := matchELFArch(f); ok {
+		return arch, nil
+	}
+	return "", fmt.Errorf("unsupported ELF machine: %s", f.Machine)
+}
+
+// elfCodeByteOrder returns the byte order of the instructions of arch in f.
+// It follows EI_DATA except where instructions are little-endian on
+// big-endian systems: always on AArch64, and on ARM in linked BE8 images.
+// debug/elf does not expose e_flags, which tells BE8 from the legacy BE32
+// format, so big-endian ARM objects are taken for BE32, as instructions are
+// only byte-swapped at link time, and executables for BE8.
+func elfCodeByteOrder(f *elf.File, arch Arch) binary.ByteOrder {
+	if f.Data != elf.ELFDATA2MSB {
+		return binary.LittleEndian
+	}
+	switch arch {
+	case ArchARM64:
+		return binary.LittleEndian
+	case ArchARM:
+		if f.Type != elf.ET_REL {
+			return binary.LittleEndian
+		}
+	}
+	return binary.BigEndian
+}
+
+// withByteOrder returns o with the byte order of its input set to order.
+func (o *options) withByteOrder(order binary.ByteOrder) *options {
+	resolved := *o
+	resolved.byteOrder = order
+	return &resolved
+}
+
+// detectCode runs prologue matching, call-site analysis and alignment-based
+// boundary detection on code, mapped at baseAddr, and merges their signals
+// into candidates sorted by address.
+func (o *options) detectCode(code []byte, baseAddr uint64, arch Arch) ([]FunctionCandidate, error) {
+	return o.detectRegions([]codeSection{{code: code, addr: baseAddr}}, arch)
+}
+
+// detectRegions is detectCode for code split into regions, each decoded as
+// its own architecture if set, or as arch. Signals are merged across regions,
+// so a call in one region confirms a prologue in another.
+func (o *options) detectRegions(regions []codeSection, arch Arch) ([]FunctionCandidate, error) {
+	var (
+		prologues      []Prologue
+		edges          []CallSiteEdge
+		alignedEntries []uint64
+		targets        []uint64
+		// scanned holds the code of each region in little-endian order.
+		scanned []codeSection
+	)
+	if o.jumpTableSkipping {
+		skipped, err := o.skipJumpTables(regions, arch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect jump tables: %w", err)
+		}
+		regions = skipped
+	}
+	// Code is brought to little-endian order once per region; native runs
+	// the detectors on it.
+	native := o.withByteOrder(binary.LittleEndian)
+	for _, region := range regions {
+		baseAddr, arch := region.addr, cmp.Or(region.arch, arch)
+		code, err := o.littleEndianCode(region.code, arch)
+		if err != nil {
+			return nil, err
+		}
+		scanned = append(scanned, codeSection{code: code, addr: baseAddr, arch: arch})
+
+		// Detect prologues
+		found, err := native.detectPrologues(code, baseAddr, arch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect prologues: %w", err)
+		}
+		prologues = append(prologues, found...)
+
+		// Detect call sites
+		regionEdges, err := native.detectCallSites(code, baseAddr, arch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect call sites: %w", err)
+		}
+		if arch == ArchPPC64LE || arch == ArchPPC64 {
+			// Prologues are reported at global entry points; so are the
+			// functions entered through their local entry point.
+			for i, edge := range regionEdges {
+				if global, _, ok := o.globalEntryPPC64(code, baseAddr, edge.TargetAddr); ok {
+					regionEdges[i].TargetAddr = global
+				}
+			}
+		}
+		edges = append(edges, regionEdges...)
+
+		// Add alignment-based candidates for functions that have no
+		// prologue and no call-site signal (e.g. pure-leaf functions with
+		// external linkage that were never called due to inlining or
+		// compile-time evaluation).
+		var entries []uint64
+		switch arch {
+		case ArchAMD64:
+			entries, err = detectAlignedEntriesAMD64(code, baseAddr, 64, o.trapBoundaries, o.budget)
+		case ArchX86:
+			entries, err = detectAlignedEntriesAMD64(code, baseAddr, 32, o.trapBoundaries, o.budget)
+		case ArchARM64:
+			entries, err = detectAlignedEntriesARM64(code, baseAddr, o.trapBoundaries, o.budget)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect aligned entries: %w", err)
+		}
+		alignedEntries = append(alignedEntries, entries...)
+
+		if o.branchTargets {
+			targets = append(targets, branchTargets(code, baseAddr, arch)...)
+		}
 	}
 
 	// Build a map of function candidates by address
 	candidates := make(map[uint64]*FunctionCandidate)
 
 	// Add prologue-based candidates
+	// Prologues are sorted by priority: the first one at an address wins.
 	for _, p := range prologues {
+		if _, exists := candidates[p.Address]; exists {
+			continue
+		}
 		candidates[p.Address] = &FunctionCandidate{
 			Address:       p.Address,
 			DetectionType: DetectionPrologueOnly,
 			PrologueType:  p.Type,
 			Confidence:    ConfidenceMedium, // Will be upgraded if also a call target
+			// Boundary-gated patterns are only matched after a return.
+			Boundary: slices.Contains(boundaryGatedPrologues, p.Type),
 		}
 	}
 
 	// Process call site edges - include both high-confidence (direct calls)
 	// and medium-confidence (unconditional jumps, which may be tail calls).
+	// A function that is called is a function, whatever its first
+	// instructions, as long as the target lies in the scanned code: memory
+	// operands such as call [rip+disp] resolve to a GOT slot, and code
+	// decoded out of data branches anywhere.
 	for _, edge := range edges {
 		if edge.Confidence != ConfidenceHigh && edge.Confidence != ConfidenceMedium {
 			continue
 		}
-		if candidate, exists := candidates[edge.TargetAddr]; exists {
-			// Address has both prologue and is called/jumped to - highest confidence
-			candidate.DetectionType = DetectionPrologueCallSite
-			candidate.Confidence = ConfidenceHigh
-			if edge.Type == CallSiteCall {
-				candidate.CalledFrom = append(candidate.CalledFrom, edge.SourceAddr)
-			} else {
-				candidate.JumpedFrom = append(candidate.JumpedFrom, edge.SourceAddr)
-			}
-		} else {
+		if _, ok := regionAt(regions, edge.TargetAddr); !ok {
+			continue
+		}
+		candidate, exists := candidates[edge.TargetAddr]
+		switch {
+		case !exists:
 			// New candidate from call site analysis only
 			detType := DetectionCallTarget
 			if edge.Type == CallSiteJump {
 				detType = DetectionJumpTarget
 			}
-			calledFrom := []uint64{}
-			jumpedFrom := []uint64{}
-			if edge.Type == CallSiteCall {
-				calledFrom = []uint64{edge.SourceAddr}
-			} else {
-				jumpedFrom = []uint64{edge.SourceAddr}
-			}
-			candidates[edge.TargetAddr] = &FunctionCandidate{
+			candidate = &FunctionCandidate{
 				Address:       edge.TargetAddr,
 				DetectionType: detType,
-				CalledFrom:    calledFrom,
-				JumpedFrom:    jumpedFrom,
+				CalledFrom:    []uint64{},
+				JumpedFrom:    []uint64{},
 				Confidence:    ConfidenceMedium, // Call/jump target but no prologue
 			}
+			candidates[edge.TargetAddr] = candidate
+		case candidate.PrologueType != "":
+			// Address has both prologue and is called/jumped to - highest confidence
+			candidate.DetectionType = DetectionPrologueCallSite
+			candidate.Confidence = ConfidenceHigh
+		case edge.Type == CallSiteCall:
+			// A jump target that is also called is a call target.
+			candidate.DetectionType = DetectionCallTarget
+		}
+		if edge.Type == CallSiteCall {
+			candidate.CalledFrom = append(candidate.CalledFrom, edge.SourceAddr)
+		} else {
+			candidate.JumpedFrom = append(candidate.JumpedFrom, edge.SourceAddr)
 		}
 	}
 
-	// Add alignment-based candidates for functions that have no prologue and
-	// no call-site signal (e.g. pure-leaf functions with external linkage
-	// that were never called due to inlining or compile-time evaluation).
-	//
-	// These receive ConfidenceLow because the pattern (ret + NOP padding ->
-	// 16-byte aligned address) is reliable for function separators but can
-	// also match intra-function alignment at loop heads.
-	var alignedEntries []uint64
-	switch arch {
-	case ArchAMD64:
-		alignedEntries = detectAlignedEntriesAMD64(code, textSec.Addr)
-	case ArchARM64:
-		alignedEntries = detectAlignedEntriesARM64(code, textSec.Addr)
-	}
+	// Aligned entries receive ConfidenceLow because the pattern (ret + NOP
+	// padding -> 16-byte aligned address) is reliable for function
+	// separators but can also match intra-function alignment at loop heads.
 	for _, addr := range alignedEntries {
-		if _, exists := candidates[addr]; !exists {
+		if candidate, exists := candidates[addr]; exists {
+			candidate.Boundary = true
+			// A prologue at an alignment boundary is a second signal.
+			if o.alignmentSignal && candidate.DetectionType == DetectionPrologueOnly {
+				candidate.Confidence = ConfidenceHigh
+			}
+		} else {
 			candidates[addr] = &FunctionCandidate{
 				Address:       addr,
 				DetectionType: DetectionAlignedEntry,
 				Confidence:    ConfidenceLow,
+				Boundary:      true,
 			}
 		}
 	}
 
+	applyBranchTargets(candidates, targets)
+
 	filterJumpTargetsByAnchorRange(candidates)
+	if o.gapFilling {
+		if err := native.fillGaps(candidates, scanned); err != nil {
+			return nil, fmt.Errorf("failed to fill gaps: %w", err)
+		}
+	}
+	if err := o.budget.results(len(candidates)); err != nil {
+		return nil, err
+	}
 
 	// Convert map to sorted slice
 	result := make([]FunctionCandidate, 0, len(candidates))
@@ -246,17 +884,177 @@ func DisasmDetector(f *elf.File) ([]FunctionCandidate, error) {
 
 // DetectPrologues analyzes raw machine code bytes and returns detected function
 // prologues. baseAddr is the virtual address corresponding to the start of code.
-// arch selects the architecture-specific detection logic.
+// arch selects the architecture-specific detection logic, built in or
+// registered with RegisterArch.
+// opts may include WithPatternTolerance, WithByteOrder, WithSyntax,
+// WithSupersetVoting or WithLimits; options that only affect the ELF
+// pipeline are ignored.
+// Prologues are ordered by address; several prologues at the same address
+// are ordered from the strongest pattern (e.g. classic before push-only).
 // This function performs no I/O and works with any binary format.
-func DetectPrologues(code []byte, baseAddr uint64, arch Arch) ([]Prologue, error) {
+func DetectPrologues(code []byte, baseAddr uint64, arch Arch, opts ...Option) ([]Prologue, error) {
+	return newOptions(opts...).detectPrologues(code, baseAddr, arch)
+}
+
+func (o *options) detectPrologues(code []byte, baseAddr uint64, arch Arch) ([]Prologue, error) {
+	o = o.withBudget()
+	if err := o.budget.codeSize(uint64(len(code))); err != nil {
+		return nil, err
+	}
+	if err := o.checkSyntax(arch); err != nil {
+		return nil, err
+	}
+	code, err := o.littleEndianCode(code, arch)
+	if err != nil {
+		return nil, err
+	}
+	var prologues []Prologue
 	switch arch {
 	case ArchAMD64:
-		return detectProloguesAMD64(code, baseAddr)
+		prologues, err = o.detectProloguesAMD64(code, baseAddr)
+	case ArchX86:
+		prologues, err = o.detectProloguesX86(code, baseAddr)
 	case ArchARM64:
-		return detectProloguesARM64(code, baseAddr)
+		prologues, err = o.detectProloguesARM64(code, baseAddr)
+	case ArchRISCV64:
+		prologues, err = o.detectProloguesRISCV64(code, baseAddr)
+	case ArchARM:
+		prologues, err = o.detectProloguesARM(code, baseAddr)
+	case ArchThumb:
+		prologues, err = o.detectProloguesThumb(code, baseAddr)
+	case ArchPPC64LE, ArchPPC64:
+		prologues, err = o.detectProloguesPPC64(code, baseAddr)
+	case ArchXtensa:
+		prologues, err = o.detectProloguesXtensa(code, baseAddr)
+	default:
+		d, ok := lookupArch(arch)
+		if !ok {
+			return nil, fmt.Errorf("unsupported architecture: %s", arch)
+		}
+		prologues, err = d.DetectPrologues(code, baseAddr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	prologues = append(prologues, matchProloguePatterns(code, baseAddr, arch)...)
+	if o.prologueEnabled(PrologueGoStackCheck) {
+		prologues = foldGoStackChecks(prologues)
+	}
+	prologues = slices.DeleteFunc(prologues, func(p Prologue) bool {
+		if !o.prologueEnabled(p.Type) {
+			return true
+		}
+		return o.maxFrameSize > 0 &&
+			slices.Contains(allocationOnlyPrologues, p.Type) && p.FrameSize > o.maxFrameSize
+	})
+	if o.supersetVoting {
+		switch arch {
+		case ArchAMD64:
+			prologues = supersetVote(prologues, code, baseAddr, 64)
+		case ArchX86:
+			prologues = supersetVote(prologues, code, baseAddr, 32)
+		}
+	}
+	if err := o.budget.results(len(prologues)); err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(prologues, comparePrologues())
+	o.renderInstructions(prologues, code, baseAddr, arch)
+	return prologues, nil
+}
+
+// foldGoStackChecks merges the frame setup that directly follows a Go
+// stack-bound check into the check's record: both belong to the same
+// function entry, which starts at the check.
+func foldGoStackChecks(prologues []Prologue) []Prologue {
+	byAddr := make(map[uint64]int, len(prologues))
+	for i, p := range prologues {
+		byAddr[p.Address] = i
+	}
+	folded := make(map[int]bool)
+	for i := range prologues {
+		g := &prologues[i]
+		if g.Type != PrologueGoStackCheck {
+			continue
+		}
+		j, ok := byAddr[g.Address+g.Size]
+		if !ok || prologues[j].Type == PrologueGoStackCheck {
+			continue
+		}
+		setup := prologues[j]
+		g.Instructions += "; " + setup.Instructions
+		g.FrameSize = setup.FrameSize
+		g.Size += setup.Size
+		g.SavedRegs = setup.SavedRegs
+		folded[j] = true
+	}
+	result := prologues[:0]
+	for i, p := range prologues {
+		if !folded[i] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// littleEndianCode returns code with its instruction words in little-endian
+// order, the order the decoders expect. Input that is already little-endian
+// is returned as is.
+func (o *options) littleEndianCode(code []byte, arch Arch) ([]byte, error) {
+	order := o.byteOrder
+	switch {
+	case order != nil:
+	case arch == ArchPPC64:
+		order = binary.BigEndian
 	default:
-		return nil, fmt.Errorf("unsupported architecture: %s", arch)
+		order = binary.LittleEndian
+	}
+	if order == binary.LittleEndian {
+		return code, nil
 	}
+	if order != binary.BigEndian {
+		return nil, fmt.Errorf("unsupported byte order: %s", order)
+	}
+	switch arch {
+	case ArchARM64, ArchARM, ArchPPC64:
+		const insnLen = 4
+		swapped := make([]byte, len(code))
+		copy(swapped, code)
+		for i := 0; i+insnLen <= len(swapped); i += insnLen {
+			binary.LittleEndian.PutUint32(swapped[i:], binary.BigEndian.Uint32(code[i:]))
+		}
+		return swapped, nil
+	case ArchThumb:
+		const insnLen = 2
+		swapped := make([]byte, len(code))
+		copy(swapped, code)
+		for i := 0; i+insnLen <= len(swapped); i += insnLen {
+			binary.LittleEndian.PutUint16(swapped[i:], binary.BigEndian.Uint16(code[i:]))
+		}
+		return swapped, nil
+	case ArchAMD64, ArchX86, ArchRISCV64, ArchPPC64LE:
+		return nil, fmt.Errorf("%s code is always little-endian", arch)
+	case ArchXtensa:
+		// Big-endian Xtensa mirrors the instruction fields rather than
+		// swapping bytes.
+		return nil, fmt.Errorf("big-endian %s code is not supported", arch)
+	}
+	// Unsupported architectures are reported by the caller.
+	return code, nil
+}
+
+// confidenceRank orders confidence levels from ConfidenceNone (0) to
+// ConfidenceHigh (3).
+func confidenceRank(c Confidence) int {
+	switch c {
+	case ConfidenceHigh:
+		return 3
+	case ConfidenceMedium:
+		return 2
+	case ConfidenceLow:
+		return 1
+	}
+	return 0
 }
 
 // mergeCandidates merges two candidate slices, deduplicating by address.
@@ -272,7 +1070,7 @@ func mergeCandidates(a, b []FunctionCandidate) []FunctionCandidate {
 			merged = append(merged, candidate)
 		}
 	}
-	slices.SortFunc(merged, func(a, b FunctionCandidate) int {
+	slices.SortStableFunc(merged, func(a, b FunctionCandidate) int {
 		return cmp.Compare(a.Address, b.Address)
 	})
 	return merged
@@ -290,14 +1088,33 @@ func isENDBR(code []byte, i int) bool {
 		(code[i+3] == endbr64Byte3 || code[i+3] == endbr32Byte3)
 }
 
-func detectProloguesAMD64(code []byte, baseAddr uint64) ([]Prologue, error) {
+func (o *options) detectProloguesAMD64(code []byte, baseAddr uint64) ([]Prologue, error) {
+	calleeSaved, err := o.calleeSavedRegsAMD64()
+	if err != nil {
+		return nil, err
+	}
 	var result []Prologue
 
 	offset := 0
 	addr := baseAddr
 	var prevInsn *x86asm.Inst
+	// prevAddr is the address of prevInsn; tolerated is the number of benign
+	// instructions skipped since prevInsn.
+	var prevAddr uint64
+	tolerated := 0
+	// consumedFrom and consumedUntil delimit the offsets of the last
+	// collapsed entry sequence. Patterns matching inside it are fragments
+	// of that sequence; a frame setup may still open it.
+	consumedFrom, consumedUntil := 0, 0
+	hist := newInsnHistory(o.lookbehind())
+	atBoundary := func(typ PrologueType) bool {
+		return o.atBoundary(hist, addr, typ)
+	}
 
 	for offset < len(code) {
+		if err := o.budget.step(offset); err != nil {
+			return nil, err
+		}
 		// Skip ENDBR64 / ENDBR32: golang.org/x/arch/x86/x86asm does not
 		// recognise these CET instructions. They appear at function entries
 		// on binaries compiled with -fcf-protection and are transparent to
@@ -310,61 +1127,188 @@ func detectProloguesAMD64(code []byte, baseAddr uint64) ([]Prologue, error) {
 
 		inst, err := x86asm.Decode(code[offset:], 64)
 		if err != nil {
+			hist.push(addr, InsnClassNone)
 			offset++
 			addr++
 			prevInsn = nil
 			continue
 		}
 
-		// Pattern 1: Classic frame pointer setup - push rbp; mov rbp, rsp
-		if prevInsn != nil &&
+		// Skip benign instructions between pattern elements, up to the
+		// configured tolerance. prevInsn is kept so the pattern can resume.
+		if prevInsn != nil && isNOPLike(inst) && tolerated < o.patternTolerance {
+			tolerated++
+			offset += inst.Len
+			addr += uint64(inst.Len)
+			continue
+		}
+		tolerated = 0
+
+		// Pattern 1: Classic frame pointer setup - push rbp; mov rbp, rsp,
+		// extended with the pushes and stack allocation that follow it.
+		prevOffset := int(prevAddr - baseAddr)
+		if prevInsn != nil && (prevOffset >= consumedUntil || prevOffset == consumedFrom) &&
 			prevInsn.Op == x86asm.PUSH && prevInsn.Args[0] == x86asm.RBP &&
 			inst.Op == x86asm.MOV && inst.Args[0] == x86asm.RBP && inst.Args[1] == x86asm.RSP {
-			result = append(result, Prologue{
-				Address:      addr - uint64(prevInsn.Len),
-				Type:         PrologueClassic,
-				Instructions: "push rbp; mov rbp, rsp",
-			})
+			result = dropSubsumedPushOnly(result, prevAddr)
+			if align, andLen, ok := stackRealignAMD64(code, offset+inst.Len); ok {
+				// Pattern 1b: Stack realignment - push rbp; mov rbp, rsp;
+				// and rsp, -N. The realignment is kept in the same record.
+				end := offset + inst.Len + andLen
+				result = append(result, Prologue{
+					Address:      prevAddr,
+					Type:         PrologueStackRealign,
+					Instructions: fmt.Sprintf("push rbp; mov rbp, rsp; and rsp, -0x%x", align),
+					FrameSize:    8,
+					Size:         uint64(end - prevOffset),
+					SavedRegs:    []string{"rbp"},
+				})
+				consumedFrom, consumedUntil = prevOffset, end
+			} else {
+				seq := scanEntryAMD64(code, prevOffset, calleeSaved)
+				result = append(result, Prologue{
+					Address:      prevAddr,
+					Type:         PrologueClassic,
+					Instructions: strings.Join(seq.insns, "; "),
+					FrameSize:    seq.frame,
+					Size:         uint64(seq.end - prevOffset),
+					SavedRegs:    seq.savedRegs,
+				})
+				consumedFrom, consumedUntil = prevOffset, seq.end
+			}
 		}
 
 		// Pattern 2: No-frame-pointer function - sub rsp, imm
-		if inst.Op == x86asm.SUB && inst.Args[0] == x86asm.RSP {
+		if inst.Op == x86asm.SUB && inst.Args[0] == x86asm.RSP && offset >= consumedUntil {
 			if imm, ok := inst.Args[1].(x86asm.Imm); ok && imm > 0 {
-				if prevInsn == nil || prevInsn.Op == x86asm.RET || prevInsn.Op == x86asm.PUSH {
+				if atBoundary(PrologueNoFramePointer) {
 					result = append(result, Prologue{
 						Address:      addr,
 						Type:         PrologueNoFramePointer,
 						Instructions: fmt.Sprintf("sub rsp, 0x%x", int64(imm)),
+						FrameSize:    uint64(imm),
+						Size:         uint64(inst.Len),
 					})
 				}
 			}
 		}
 
-		// Pattern 3: Push callee-saved register at function boundary
-		if inst.Op == x86asm.PUSH {
-			if reg, ok := inst.Args[0].(x86asm.Reg); ok && isCalleeSavedAMD64(reg) {
-				if prevInsn == nil || prevInsn.Op == x86asm.RET {
+		// Pattern 3: Push callee-saved register at function boundary. The
+		// record spans the whole run of pushes and the stack allocation that
+		// closes it, e.g. push rbx; push r12; sub rsp, 0x18.
+		if inst.Op == x86asm.PUSH && offset >= consumedUntil {
+			if reg, ok := inst.Args[0].(x86asm.Reg); ok && slices.Contains(calleeSaved, reg) {
+				if atBoundary(ProloguePushOnly) {
+					seq := scanEntryAMD64(code, offset, calleeSaved)
 					result = append(result, Prologue{
 						Address:      addr,
 						Type:         ProloguePushOnly,
-						Instructions: fmt.Sprintf("push %s", reg),
+						Instructions: strings.Join(seq.insns, "; "),
+						FrameSize:    seq.frame,
+						Size:         uint64(seq.end - offset),
+						SavedRegs:    seq.savedRegs,
 					})
+					consumedFrom, consumedUntil = offset, seq.end
 				}
 			}
 		}
 
 		// Pattern 4: Stack allocation with lea - lea rsp, [rsp-imm]
-		if inst.Op == x86asm.LEA && inst.Args[0] == x86asm.RSP {
-			if prevInsn == nil || prevInsn.Op == x86asm.RET {
+		if inst.Op == x86asm.LEA && inst.Args[0] == x86asm.RSP && offset >= consumedUntil {
+			if atBoundary(PrologueLEABased) {
+				frame, _ := stackAllocAMD64(inst)
 				result = append(result, Prologue{
 					Address:      addr,
 					Type:         PrologueLEABased,
 					Instructions: "lea rsp, [rsp-offset]",
+					FrameSize:    frame,
+					Size:         uint64(inst.Len),
+				})
+			}
+		}
+
+		// Pattern 5: enter imm16, 0 - a single instruction that pushes rbp,
+		// sets up the frame pointer and allocates imm16 bytes of locals.
+		// Nesting levels other than 0 are never emitted by compilers.
+		if inst.Op == x86asm.ENTER && inst.Args[1] == x86asm.Imm(0) {
+			if size, ok := inst.Args[0].(x86asm.Imm); ok && atBoundary(PrologueEnter) {
+				result = append(result, Prologue{
+					Address:      addr,
+					Type:         PrologueEnter,
+					Instructions: fmt.Sprintf("enter 0x%x, 0", int64(size)),
+					FrameSize:    8 + uint64(size),
+					Size:         uint64(inst.Len),
+					SavedRegs:    []string{"rbp"},
+				})
+			}
+		}
+
+		// Pattern 6: Go stack-bound check - cmp rsp, [r14+0x10]; jbe, the
+		// first instructions of every Go function with a frame. Large frames
+		// compare a scratch copy of the lowered stack pointer instead.
+		if o.prologueEnabled(PrologueGoStackCheck) {
+			if check, ok := scanGoStackCheckAMD64(code, offset); ok {
+				result = append(result, Prologue{
+					Address:      addr,
+					Type:         PrologueGoStackCheck,
+					Instructions: strings.Join(check.insns, "; "),
+					Size:         uint64(check.end - offset),
 				})
 			}
 		}
 
+		// Pattern 7: MSVC home-space spills - mov [rsp+8], rcx; mov [rsp+0x10],
+		// rdx; ... store register arguments into the 32 bytes the caller
+		// reserves above the return address.
+		if o.prologueEnabled(PrologueHomeSpill) && offset >= consumedUntil &&
+			isHomeSpillAMD64(inst) && atBoundary(PrologueHomeSpill) {
+			spill := scanHomeSpillAMD64(code, offset)
+			result = append(result, Prologue{
+				Address:      addr,
+				Type:         PrologueHomeSpill,
+				Instructions: strings.Join(spill.insns, "; "),
+				Size:         uint64(spill.end - offset),
+				SavedRegs:    spill.savedRegs,
+			})
+			consumedFrom, consumedUntil = offset, spill.end
+		}
+
+		// Pattern 8: Rust stack probe - mov eax, imm32; call
+		// __rust_probestack; sub rsp, rax, emitted before allocating a frame
+		// larger than a page.
+		if o.prologueEnabled(PrologueRustProbestack) && offset >= consumedUntil &&
+			atBoundary(PrologueRustProbestack) {
+			if frame, end, ok := probestackAMD64(code, offset); ok {
+				result = append(result, Prologue{
+					Address:      addr,
+					Type:         PrologueRustProbestack,
+					Instructions: fmt.Sprintf("mov eax, 0x%x; call __rust_probestack; sub rsp, rax", frame),
+					FrameSize:    frame,
+					Size:         uint64(end - offset),
+				})
+				consumedFrom, consumedUntil = offset, end
+			}
+		}
+
+		// Pattern 9: core::fmt thunk - mov rdi, [rdi]; jmp, forwarding a
+		// trait call on a reference to the implementation for the referent.
+		// These functions are only reached through vtables.
+		if o.prologueEnabled(PrologueFmtThunk) && offset >= consumedUntil &&
+			atBoundary(PrologueFmtThunk) {
+			if end, ok := fmtThunkAMD64(code, offset); ok {
+				result = append(result, Prologue{
+					Address:      addr,
+					Type:         PrologueFmtThunk,
+					Instructions: "mov rdi, [rdi]; jmp",
+					Size:         uint64(end - offset),
+				})
+				consumedFrom, consumedUntil = offset, end
+			}
+		}
+
+		hist.push(addr, classifyAMD64(inst))
 		prevInsn = &inst
+		prevAddr = addr
 		offset += inst.Len
 		addr += uint64(inst.Len)
 	}
@@ -372,12 +1316,309 @@ func detectProloguesAMD64(code []byte, baseAddr uint64) ([]Prologue, error) {
 	return result, nil
 }
 
-func isCalleeSavedAMD64(reg x86asm.Reg) bool {
-	switch reg {
-	case x86asm.RBX, x86asm.RBP, x86asm.R12, x86asm.R13, x86asm.R14, x86asm.R15:
-		return true
+// stackRealignAMD64 reports whether the instruction at code[offset] is
+// and rsp, -N with N a power of two, and returns N and the instruction
+// length. Compilers emit it after the frame pointer setup when locals need
+// more than the 16-byte ABI stack alignment (AVX spills, over-aligned
+// types) and in interrupt entry code.
+func stackRealignAMD64(code []byte, offset int) (align uint64, length int, ok bool) {
+	if offset >= len(code) {
+		return 0, 0, false
 	}
-	return false
+	inst, err := x86asm.Decode(code[offset:], 64)
+	if err != nil || inst.Op != x86asm.AND || inst.Args[0] != x86asm.RSP {
+		return 0, 0, false
+	}
+	imm, isImm := inst.Args[1].(x86asm.Imm)
+	if !isImm || imm >= 0 {
+		return 0, 0, false
+	}
+	align = uint64(-imm)
+	if align&(align-1) != 0 {
+		return 0, 0, false
+	}
+	return align, inst.Len, true
+}
+
+// maxSavedRegScan bounds the number of instructions examined when collecting
+// the registers saved by a prologue.
+const maxSavedRegScan = 16
+
+// entrySeqAMD64 describes the x86-64 function entry sequence that starts at
+// a prologue anchor: callee-saved pushes, an optional frame pointer setup and
+// an optional trailing stack allocation.
+type entrySeqAMD64 struct {
+	// insns is the human-readable form of every instruction in the sequence.
+	insns []string
+	// savedRegs lists the pushed callee-saved registers in push order.
+	savedRegs []string
+	// frame is the total stack allocation: 8 bytes per push plus the
+	// trailing sub rsp / lea rsp amount.
+	frame uint64
+	// end is the offset just past the last instruction of the sequence.
+	end int
+}
+
+// dropSubsumedPushOnly removes the push-only record at addr from the end of
+// result. A frame setup opening with push rbp is first matched as a push-only
+// sequence; once the classic pattern covers the same start, that record is a
+// duplicate.
+func dropSubsumedPushOnly(result []Prologue, addr uint64) []Prologue {
+	if n := len(result); n > 0 && result[n-1].Address == addr && result[n-1].Type == ProloguePushOnly {
+		return result[:n-1]
+	}
+	return result
+}
+
+// scanEntryAMD64 collects the entry sequence starting at code[offset]. The
+// sequence is a run of callee-saved pushes in which mov rbp, rsp may appear,
+// optionally closed by sub rsp, imm, lea rsp, [rsp-imm] or a Rust stack
+// probe. Only pushes of the calleeSaved registers belong to it. ENDBR64 and
+// NOP-like padding are skipped; any other instruction ends the sequence.
+func scanEntryAMD64(code []byte, offset int, calleeSaved []x86asm.Reg) entrySeqAMD64 {
+	seq := entrySeqAMD64{end: offset}
+	for n := 0; n < maxSavedRegScan && offset < len(code); n++ {
+		if isENDBR(code, offset) {
+			offset += 4
+			continue
+		}
+		inst, err := x86asm.Decode(code[offset:], 64)
+		if err != nil {
+			break
+		}
+		offset += inst.Len
+		switch {
+		case inst.Op == x86asm.PUSH:
+			reg, ok := inst.Args[0].(x86asm.Reg)
+			if !ok || !slices.Contains(calleeSaved, reg) {
+				return seq
+			}
+			name := strings.ToLower(reg.String())
+			seq.insns = append(seq.insns, "push "+name)
+			seq.savedRegs = append(seq.savedRegs, name)
+			seq.frame += 8
+		case inst.Op == x86asm.MOV && inst.Args[0] == x86asm.RBP && inst.Args[1] == x86asm.RSP:
+			seq.insns = append(seq.insns, "mov rbp, rsp")
+		case isNOPLike(inst):
+			continue
+		default:
+			if size, ok := stackAllocAMD64(inst); ok {
+				seq.insns = append(seq.insns, fmt.Sprintf("%s rsp, 0x%x", strings.ToLower(inst.Op.String()), size))
+				seq.frame += size
+				seq.end = offset
+			} else if size, end, ok := probestackAMD64(code, offset-inst.Len); ok {
+				seq.insns = append(seq.insns, fmt.Sprintf("mov eax, 0x%x; call __rust_probestack; sub rsp, rax", size))
+				seq.frame += size
+				seq.end = end
+			}
+			return seq
+		}
+		seq.end = offset
+	}
+	return seq
+}
+
+// stackAllocAMD64 returns the number of bytes allocated by sub rsp, imm or
+// lea rsp, [rsp-imm].
+func stackAllocAMD64(inst x86asm.Inst) (uint64, bool) {
+	switch {
+	case inst.Op == x86asm.SUB && inst.Args[0] == x86asm.RSP:
+		if imm, ok := inst.Args[1].(x86asm.Imm); ok && imm > 0 {
+			return uint64(imm), true
+		}
+	case inst.Op == x86asm.LEA && inst.Args[0] == x86asm.RSP:
+		// x86asm zero-extends 32-bit displacements.
+		if mem, ok := inst.Args[1].(x86asm.Mem); ok && mem.Base == x86asm.RSP {
+			if disp := int64(int32(mem.Disp)); disp < 0 {
+				return uint64(-disp), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// probestackAMD64 matches the stack probe rustc emits at code[offset]
+// before allocating a frame larger than a page:
+//
+//	mov eax, imm32
+//	call __rust_probestack
+//	sub rsp, rax
+//
+// The probe touches every page of the new frame; the call target is not
+// checked because stripped binaries do not name it. It returns the frame
+// size and the offset just past the sequence.
+func probestackAMD64(code []byte, offset int) (frame uint64, end int, ok bool) {
+	mov, err := x86asm.Decode(code[offset:], 64)
+	if err != nil || mov.Op != x86asm.MOV || mov.Args[0] != x86asm.EAX {
+		return 0, 0, false
+	}
+	imm, isImm := mov.Args[1].(x86asm.Imm)
+	if !isImm || imm <= 0 {
+		return 0, 0, false
+	}
+	end = offset + mov.Len
+	call, err := x86asm.Decode(code[end:], 64)
+	if err != nil || call.Op != x86asm.CALL {
+		return 0, 0, false
+	}
+	if _, isRel := call.Args[0].(x86asm.Rel); !isRel {
+		return 0, 0, false
+	}
+	end += call.Len
+	sub, err := x86asm.Decode(code[end:], 64)
+	if err != nil || sub.Op != x86asm.SUB || sub.Args[0] != x86asm.RSP || sub.Args[1] != x86asm.RAX {
+		return 0, 0, false
+	}
+	return uint64(imm), end + sub.Len, true
+}
+
+// fmtThunkAMD64 matches mov rdi, [rdi]; jmp rel at code[offset] and returns
+// the offset just past the jump. rustc emits it for the formatting traits
+// implemented on references, e.g. <&T as core::fmt::Display>::fmt.
+func fmtThunkAMD64(code []byte, offset int) (end int, ok bool) {
+	mov, err := x86asm.Decode(code[offset:], 64)
+	if err != nil || mov.Op != x86asm.MOV || mov.Args[0] != x86asm.RDI {
+		return 0, false
+	}
+	mem, isMem := mov.Args[1].(x86asm.Mem)
+	if !isMem || mem != (x86asm.Mem{Base: x86asm.RDI}) {
+		return 0, false
+	}
+	end = offset + mov.Len
+	jmp, err := x86asm.Decode(code[end:], 64)
+	if err != nil || jmp.Op != x86asm.JMP {
+		return 0, false
+	}
+	if _, isRel := jmp.Args[0].(x86asm.Rel); !isRel {
+		return 0, false
+	}
+	return end + jmp.Len, true
+}
+
+// goStackGuardOffset is the offset of g.stackguard0 in the Go runtime's g
+// structure, which Go functions compare the stack pointer against.
+const goStackGuardOffset = 0x10
+
+// isGoStackGuardCmpAMD64 reports whether inst is cmp reg, [r14+0x10]: the
+// comparison of reg with g.stackguard0, r14 holding g under ABIInternal.
+func isGoStackGuardCmpAMD64(inst x86asm.Inst, reg x86asm.Reg) bool {
+	if inst.Op != x86asm.CMP || inst.Args[0] != reg {
+		return false
+	}
+	mem, ok := inst.Args[1].(x86asm.Mem)
+	return ok && mem.Base == x86asm.R14 && mem.Index == 0 && mem.Disp == goStackGuardOffset
+}
+
+// scanGoStackCheckAMD64 matches the Go stack-bound check starting at
+// code[offset] in one of its three forms, each followed by jbe to the
+// morestack call:
+//
+//	cmp rsp, [r14+0x10]
+//	lea r12, [rsp-N]; cmp r12, [r14+0x10]
+//	mov r12, rsp; sub r12, N; jb; cmp r12, [r14+0x10]
+//
+// The returned sequence has no frame: the check allocates nothing.
+func scanGoStackCheckAMD64(code []byte, offset int) (entrySeqAMD64, bool) {
+	seq := entrySeqAMD64{end: offset}
+	next := func() (x86asm.Inst, bool) {
+		if seq.end >= len(code) {
+			return x86asm.Inst{}, false
+		}
+		inst, err := x86asm.Decode(code[seq.end:], 64)
+		if err != nil {
+			return x86asm.Inst{}, false
+		}
+		seq.end += inst.Len
+		return inst, true
+	}
+
+	inst, ok := next()
+	if !ok {
+		return seq, false
+	}
+	switch {
+	case isGoStackGuardCmpAMD64(inst, x86asm.RSP):
+		seq.insns = append(seq.insns, "cmp rsp, [r14+0x10]")
+	case inst.Op == x86asm.LEA && inst.Args[0] == x86asm.R12:
+		mem, ok := inst.Args[1].(x86asm.Mem)
+		// x86asm zero-extends disp32; the displacement is signed.
+		disp := int64(int32(mem.Disp))
+		if !ok || mem.Base != x86asm.RSP || mem.Index != 0 || disp >= 0 {
+			return seq, false
+		}
+		if inst, ok = next(); !ok || !isGoStackGuardCmpAMD64(inst, x86asm.R12) {
+			return seq, false
+		}
+		seq.insns = append(seq.insns, fmt.Sprintf("lea r12, [rsp-0x%x]", -disp), "cmp r12, [r14+0x10]")
+	case inst.Op == x86asm.MOV && inst.Args[0] == x86asm.R12 && inst.Args[1] == x86asm.RSP:
+		if inst, ok = next(); !ok || inst.Op != x86asm.SUB || inst.Args[0] != x86asm.R12 {
+			return seq, false
+		}
+		frame, ok := inst.Args[1].(x86asm.Imm)
+		if !ok {
+			return seq, false
+		}
+		if inst, ok = next(); !ok || inst.Op != x86asm.JB {
+			return seq, false
+		}
+		if inst, ok = next(); !ok || !isGoStackGuardCmpAMD64(inst, x86asm.R12) {
+			return seq, false
+		}
+		seq.insns = append(seq.insns, "mov r12, rsp", fmt.Sprintf("sub r12, 0x%x", int64(frame)), "jb", "cmp r12, [r14+0x10]")
+	default:
+		return seq, false
+	}
+	if inst, ok = next(); !ok || inst.Op != x86asm.JBE {
+		return seq, false
+	}
+	seq.insns = append(seq.insns, "jbe")
+	return seq, true
+}
+
+// isHomeSpillAMD64 reports whether inst stores a register argument into the
+// Windows x64 home space: mov [rsp+8|0x10|0x18|0x20], reg64 or the movsd
+// form for floating-point arguments.
+func isHomeSpillAMD64(inst x86asm.Inst) bool {
+	if inst.Op != x86asm.MOV && inst.Op != x86asm.MOVSD_XMM {
+		return false
+	}
+	mem, ok := inst.Args[0].(x86asm.Mem)
+	if !ok || mem.Base != x86asm.RSP || mem.Index != 0 {
+		return false
+	}
+	switch mem.Disp {
+	case 0x8, 0x10, 0x18, 0x20:
+	default:
+		return false
+	}
+	reg, ok := inst.Args[1].(x86asm.Reg)
+	return ok && ((reg >= x86asm.RAX && reg <= x86asm.R15) || (reg >= x86asm.X0 && reg <= x86asm.X3))
+}
+
+// scanHomeSpillAMD64 collects the run of home-space spills starting at
+// code[offset]. savedRegs lists the spilled registers that are callee-saved
+// under the Windows x64 ABI, which MSVC also parks in the home space.
+func scanHomeSpillAMD64(code []byte, offset int) entrySeqAMD64 {
+	seq := entrySeqAMD64{end: offset}
+	for n := 0; n < 4 && seq.end < len(code); n++ {
+		inst, err := x86asm.Decode(code[seq.end:], 64)
+		if err != nil || !isHomeSpillAMD64(inst) {
+			break
+		}
+		seq.end += inst.Len
+		reg := inst.Args[1].(x86asm.Reg)
+		name := strings.ToLower(reg.String())
+		op := "mov"
+		if inst.Op == x86asm.MOVSD_XMM {
+			op = "movsd"
+		}
+		seq.insns = append(seq.insns, fmt.Sprintf("%s [rsp+0x%x], %s", op, inst.Args[0].(x86asm.Mem).Disp, name))
+		switch reg {
+		case x86asm.RBX, x86asm.RBP, x86asm.RSI, x86asm.RDI, x86asm.R12, x86asm.R13, x86asm.R14, x86asm.R15:
+			seq.savedRegs = append(seq.savedRegs, name)
+		}
+	}
+	return seq
 }
 
 // isSTPx29x30PreIndex checks if an ARM64 instruction is stp x29, x30, [sp, #-N]!
@@ -393,6 +1634,73 @@ func isSTPx29x30PreIndex(inst arm64asm.Inst) bool {
 		mem.Mode == arm64asm.AddrPreIndex
 }
 
+// isSTPCalleeSavedPreIndex checks if an ARM64 instruction is a pre-indexed
+// STP of two AAPCS64 callee-saved registers other than the x29/x30 frame
+// pair, e.g. stp x19, x20, [sp, #-N]! or stp d8, d9, [sp, #-N]!.
+func isSTPCalleeSavedPreIndex(inst arm64asm.Inst) bool {
+	if inst.Op != arm64asm.STP {
+		return false
+	}
+	r0, ok0 := inst.Args[0].(arm64asm.Reg)
+	r1, ok1 := inst.Args[1].(arm64asm.Reg)
+	mem, ok2 := inst.Args[2].(arm64asm.MemImmediate)
+	return ok0 && ok1 && ok2 &&
+		isCalleeSavedARM64(r0) && isCalleeSavedARM64(r1) &&
+		mem.Base == arm64asm.RegSP(arm64asm.SP) && mem.Mode == arm64asm.AddrPreIndex
+}
+
+// isCalleeSavedARM64 reports whether reg is callee-saved under AAPCS64:
+// x19-x28 and the low 64 bits of v8-v15 (d8-d15). The frame pair x29/x30
+// is matched separately by isSTPx29x30PreIndex.
+func isCalleeSavedARM64(reg arm64asm.Reg) bool {
+	return (reg >= arm64asm.X19 && reg <= arm64asm.X28) ||
+		(reg >= arm64asm.D8 && reg <= arm64asm.D15)
+}
+
+// savedRegsARM64 returns the registers stored by the STP/STR sequence that
+// starts the function at code[offset]: the pre-indexed store that allocates
+// the frame followed by any SP-based stores of x29, x30 or callee-saved
+// registers. mov x29, sp and benign hints may be interleaved.
+func savedRegsARM64(code []byte, offset int) []string {
+	const insnLen = 4
+	var regs []string
+	for n := 0; n < maxSavedRegScan && offset+insnLen <= len(code); n++ {
+		word := binary.LittleEndian.Uint32(code[offset:])
+		inst, err := arm64asm.Decode(code[offset : offset+insnLen])
+		offset += insnLen
+		if err != nil {
+			return regs
+		}
+		if isMovX29SP(inst) || isBenignARM64(word) {
+			continue
+		}
+		// count is the number of registers stored.
+		var count int
+		switch inst.Op {
+		case arm64asm.STP:
+			count = 2
+		case arm64asm.STR:
+			count = 1
+		default:
+			return regs
+		}
+		mem, ok := inst.Args[count].(arm64asm.MemImmediate)
+		if !ok || mem.Base != arm64asm.RegSP(arm64asm.SP) {
+			return regs
+		}
+		stored := make([]string, 0, count)
+		for _, arg := range inst.Args[:count] {
+			r, ok := arg.(arm64asm.Reg)
+			if !ok || (!isCalleeSavedARM64(r) && r != arm64asm.X29 && r != arm64asm.X30) {
+				return regs
+			}
+			stored = append(stored, strings.ToLower(r.String()))
+		}
+		regs = append(regs, stored...)
+	}
+	return regs
+}
+
 // isMovX29SP checks if an ARM64 instruction is mov x29, sp.
 // The disassembler decodes this as MOV with both args as RegSP.
 func isMovX29SP(inst arm64asm.Inst) bool {
@@ -404,69 +1712,353 @@ func isMovX29SP(inst arm64asm.Inst) bool {
 	return ok0 && ok1 && r0 == arm64asm.RegSP(arm64asm.X29) && r1 == arm64asm.RegSP(arm64asm.SP)
 }
 
-func detectProloguesARM64(code []byte, baseAddr uint64) ([]Prologue, error) {
+// ARM64 encodings decoded from the raw instruction word. golang.org/x/arch
+// keeps the immediate fields of ImmShift and MemImmediate unexported, so
+// frame sizes are extracted directly from the bit fields.
+const (
+	// sub sp, sp, #imm{, lsl #12}: SUB (immediate), 64-bit, Rn = Rd = SP.
+	arm64SubSPImmMask  = uint32(0xff8003ff)
+	arm64SubSPImmValue = uint32(0xd10003ff)
+
+	// sub sp, sp, Xm{, uxtx}: SUB (extended register), 64-bit, Rn = Rd = SP.
+	arm64SubSPRegMask  = uint32(0xffe0ffff)
+	arm64SubSPRegValue = uint32(0xcb2063ff)
+
+	// MOVZ / MOVK / MOVN (wide immediate), 64-bit.
+	arm64MovWideMask = uint32(0xff800000)
+	arm64MOVZ        = uint32(0xd2800000)
+	arm64MOVK        = uint32(0xf2800000)
+	arm64MOVN        = uint32(0x92800000)
+
+	// STP (pre-index), 64-bit general-purpose and SIMD&FP D-register forms.
+	arm64STPPreMask  = uint32(0xffc00000)
+	arm64STPXPre     = uint32(0xa9800000)
+	arm64STPDPre     = uint32(0x6d800000)
+	arm64STRXPreMask = uint32(0xffe00c00)
+	arm64STRXPre     = uint32(0xf8000c00)
+
+	// ldr x16, [x28, #16]: the load of g.stackguard0 opening Go's
+	// stack-bound check, x28 holding g.
+	arm64GoLoadStackGuard = uint32(0xf9400b90)
+
+	// NOP and BTI {c|j|jc} in the hint space (CRm = 0100, op2 = xx0).
+	arm64NOP     = uint32(0xd503201f)
+	arm64BTIMask = uint32(0xffffff3f)
+	arm64BTI     = uint32(0xd503241f)
+
+	// PACIASP and PACIBSP, hint-space too, signing x30 on function entry.
+	arm64PACIASP = uint32(0xd503233f)
+	arm64PACIBSP = uint32(0xd503237f)
+)
+
+// isBenignARM64 reports whether word is a NOP or a BTI landing pad (bti,
+// bti c, bti j, bti jc). Both are hint-space instructions with no effect on
+// the stack frame and may separate the elements of a prologue pattern.
+func isBenignARM64(word uint32) bool {
+	return word == arm64NOP || word&arm64BTIMask == arm64BTI
+}
+
+// arm64PACMnemonic returns the mnemonic of word if it is PACIASP or
+// PACIBSP, which sign the link register with the A or B key and SP as the
+// modifier, as the first instruction of a pac-ret function.
+func arm64PACMnemonic(word uint32) (string, bool) {
+	switch word {
+	case arm64PACIASP:
+		return "paciasp", true
+	case arm64PACIBSP:
+		return "pacibsp", true
+	}
+	return "", false
+}
+
+// arm64SubSPImm returns the byte count allocated by sub sp, sp, #imm{, lsl #12}
+// encoded in word, including the optional 12-bit left shift.
+func arm64SubSPImm(word uint32) (uint64, bool) {
+	if word&arm64SubSPImmMask != arm64SubSPImmValue {
+		return 0, false
+	}
+	imm := uint64(word>>10) & 0xfff
+	if word&(1<<22) != 0 {
+		imm <<= 12
+	}
+	return imm, true
+}
+
+// arm64SubSPReg returns the index of the register Xm in sub sp, sp, Xm encoded
+// in word. Only the canonical UXTX #0 extension used for stack allocation is
+// accepted.
+func arm64SubSPReg(word uint32) (uint32, bool) {
+	if word&arm64SubSPRegMask != arm64SubSPRegValue {
+		return 0, false
+	}
+	return (word >> 16) & 0x1f, true
+}
+
+// arm64PreIndexFrame returns the number of bytes a pre-indexed STP (X or D
+// pair) or STR Xt instruction subtracts from the base register.
+func arm64PreIndexFrame(word uint32) uint64 {
+	switch {
+	case word&arm64STPPreMask == arm64STPXPre, word&arm64STPPreMask == arm64STPDPre:
+		// imm7 at bits 21:15, scaled by the 8-byte register size.
+		imm7 := int64(int32(word<<10) >> 25)
+		return uint64(-imm7 * 8)
+	case word&arm64STRXPreMask == arm64STRXPre:
+		// imm9 at bits 20:12, unscaled.
+		imm9 := int64(int32(word<<11) >> 23)
+		return uint64(-imm9)
+	}
+	return 0
+}
+
+// arm64MovSeq tracks a run of MOVZ/MOVN followed by MOVK instructions that
+// materialize a constant in a scratch register. Compilers use this sequence
+// to allocate stack frames too large for the 24-bit sub sp immediate:
+//
+//	mov  x16, #0x2340
+//	movk x16, #0x1, lsl #16
+//	sub  sp, sp, x16
+type arm64MovSeq struct {
+	valid bool
+	// reg is the destination register index of the sequence.
+	reg uint32
+	// value is the constant materialized so far.
+	value uint64
+	// start is the virtual address of the first MOVZ/MOVN.
+	start uint64
+	// atBoundary reports whether the first MOVZ/MOVN followed a function
+	// boundary (start of input or RET).
+	atBoundary bool
+}
+
+// step updates the sequence with the instruction word at addr and reports
+// whether the word extended it. atBoundary is evaluated for the word's own
+// predecessor and is only recorded when a new sequence starts.
+func (s *arm64MovSeq) step(word uint32, addr uint64, atBoundary bool) bool {
+	rd := word & 0x1f
+	hw := uint((word >> 21) & 0x3)
+	imm16 := uint64(word>>5) & 0xffff
+	switch word & arm64MovWideMask {
+	case arm64MOVZ:
+		*s = arm64MovSeq{valid: true, reg: rd, value: imm16 << (16 * hw), start: addr, atBoundary: atBoundary}
+		return true
+	case arm64MOVN:
+		*s = arm64MovSeq{valid: true, reg: rd, value: ^(imm16 << (16 * hw)), start: addr, atBoundary: atBoundary}
+		return true
+	case arm64MOVK:
+		if s.valid && s.reg == rd {
+			s.value = s.value&^(0xffff<<(16*hw)) | imm16<<(16*hw)
+			return true
+		}
+	}
+	s.valid = false
+	return false
+}
+
+// scanGoStackCheckARM64 returns the size of the Go stack-bound check that
+// starts with ldr x16, [x28, #16] at code[offset]: the instructions up to the
+// conditional branch following the comparison against x16. Large frames
+// compute the lowered stack pointer with its own overflow check first:
+//
+//	ldr  x16, [x28, #16]
+//	mov  x27, #N
+//	subs x17, sp, x27
+//	b.cc <morestack>
+//	cmp  x17, x16
+//	b.ls <morestack>
+func scanGoStackCheckARM64(code []byte, offset int) (uint64, bool) {
+	const insnLen = 4
+	const maxInsns = 6
+	compared := false
+	for n := 1; n < maxInsns && offset+(n+1)*insnLen <= len(code); n++ {
+		inst, err := arm64asm.Decode(code[offset+n*insnLen:])
+		if err != nil {
+			return 0, false
+		}
+		switch {
+		case inst.Op == arm64asm.CMP:
+			compared = true
+		case inst.Op == arm64asm.B && isConditionalARM64(inst) && compared:
+			return uint64((n + 1) * insnLen), true
+		}
+	}
+	return 0, false
+}
+
+func (o *options) detectProloguesARM64(code []byte, baseAddr uint64) ([]Prologue, error) {
 	var result []Prologue
 
 	const insnLen = 4
 	var prevInsn *arm64asm.Inst
+	var movSeq arm64MovSeq
+	// prevOffset is the offset of prevInsn; tolerated is the number of benign
+	// instructions skipped since prevInsn.
+	prevOffset := 0
+	tolerated := 0
+	hist := newInsnHistory(o.lookbehind())
+	// pacs maps the address following each PACIASP or PACIBSP to its
+	// mnemonic, for the prologues it opens.
+	pacs := make(map[uint64]string)
 
 	for offset := 0; offset+insnLen <= len(code); offset += insnLen {
+		if err := o.budget.step(offset); err != nil {
+			return nil, err
+		}
+		word := binary.LittleEndian.Uint32(code[offset:])
 		inst, err := arm64asm.Decode(code[offset : offset+insnLen])
+		addr := baseAddr + uint64(offset)
 		if err != nil {
+			hist.push(addr, InsnClassNone)
 			prevInsn = nil
+			movSeq.valid = false
 			continue
 		}
-		addr := baseAddr + uint64(offset)
+
+		// Functions built with -mbranch-protection=pac-ret sign the return
+		// address before saving it. The PAC instruction is left out of the
+		// boundary history, so that the patterns following it are checked
+		// against the code preceding the function.
+		if mnemonic, ok := arm64PACMnemonic(word); ok {
+			pacs[addr+insnLen] = mnemonic
+			prevInsn = nil
+			movSeq.valid = false
+			continue
+		}
+
+		// Skip benign instructions between pattern elements, up to the
+		// configured tolerance. prevInsn is kept so the pattern can resume.
+		if prevInsn != nil && isBenignARM64(word) && tolerated < o.patternTolerance {
+			tolerated++
+			continue
+		}
+		tolerated = 0
+
+		atBoundary := func(typ PrologueType) bool {
+			return o.atBoundary(hist, addr, typ)
+		}
 
 		if prevInsn != nil && isSTPx29x30PreIndex(*prevInsn) {
+			prevWord := binary.LittleEndian.Uint32(code[prevOffset:])
+			prevAddr := baseAddr + uint64(prevOffset)
 			if isMovX29SP(inst) {
 				// Pattern 1: STP frame pair - stp x29, x30, [sp, #-N]! ; mov x29, sp
 				result = append(result, Prologue{
-					Address:      addr - insnLen,
+					Address:      prevAddr,
 					Type:         PrologueSTPFramePair,
 					Instructions: "stp x29, x30, [sp, #-N]!; mov x29, sp",
+					FrameSize:    arm64PreIndexFrame(prevWord),
+					Size:         addr + insnLen - prevAddr,
+					SavedRegs:    savedRegsARM64(code, prevOffset),
 				})
 			} else {
 				// Pattern 3: STP-only - stp x29, x30, [sp, #-N]! without mov x29, sp
 				result = append(result, Prologue{
-					Address:      addr - insnLen,
+					Address:      prevAddr,
 					Type:         PrologueSTPOnly,
 					Instructions: "stp x29, x30, [sp, #-N]!",
+					FrameSize:    arm64PreIndexFrame(prevWord),
+					Size:         insnLen,
+					SavedRegs:    savedRegsARM64(code, prevOffset),
 				})
 			}
 		}
 
+		// Pattern 4: STP callee-saved pair - stp x19, x20, [sp, #-N]! (or a
+		// d8-d15 pair) at a function boundary. Functions that save callee-saved
+		// registers before, or instead of, the x29/x30 pair start this way.
+		if isSTPCalleeSavedPreIndex(inst) && atBoundary(PrologueSTPCalleeSaved) {
+			result = append(result, Prologue{
+				Address:      addr,
+				Type:         PrologueSTPCalleeSaved,
+				Instructions: strings.ToLower(inst.String()),
+				FrameSize:    arm64PreIndexFrame(word),
+				Size:         insnLen,
+				SavedRegs:    savedRegsARM64(code, offset),
+			})
+		}
+
 		// Pattern 2: STR LR pre-index - str x30, [sp, #-N]! (Go-style prologue)
 		if inst.Op == arm64asm.STR {
 			if r0, ok := inst.Args[0].(arm64asm.Reg); ok && r0 == arm64asm.X30 {
 				if mem, ok := inst.Args[1].(arm64asm.MemImmediate); ok && mem.Mode == arm64asm.AddrPreIndex {
-					if prevInsn == nil || prevInsn.Op == arm64asm.RET {
+					if atBoundary(PrologueSTRLRPreIndex) {
 						result = append(result, Prologue{
 							Address:      addr,
 							Type:         PrologueSTRLRPreIndex,
 							Instructions: fmt.Sprintf("str x30, %s", inst.Args[1]),
+							FrameSize:    arm64PreIndexFrame(word),
+							Size:         insnLen,
 						})
 					}
 				}
 			}
 		}
 
-		// Pattern 3: Sub SP - sub sp, sp, #N (stack allocation without frame pointer)
-		if inst.Op == arm64asm.SUB {
-			if dst, ok := inst.Args[0].(arm64asm.RegSP); ok && dst == arm64asm.RegSP(arm64asm.SP) {
-				if src, ok := inst.Args[1].(arm64asm.RegSP); ok && src == arm64asm.RegSP(arm64asm.SP) {
-					if prevInsn == nil || prevInsn.Op == arm64asm.RET {
-						result = append(result, Prologue{
-							Address:      addr,
-							Type:         PrologueSubSP,
-							Instructions: fmt.Sprintf("sub sp, sp, #%s", inst.Args[2]),
-						})
+		// Pattern 3: Sub SP - sub sp, sp, #N{, lsl #12} (stack allocation
+		// without frame pointer). Frames larger than 4 KiB are allocated by
+		// a shifted sub optionally followed by a second, unshifted one; both
+		// are folded into the reported frame size.
+		if imm, ok := arm64SubSPImm(word); ok && atBoundary(PrologueSubSP) {
+			insns := fmt.Sprintf("sub sp, sp, %s", inst.Args[2])
+			size := uint64(insnLen)
+			if next := offset + insnLen; next+insnLen <= len(code) {
+				nextWord := binary.LittleEndian.Uint32(code[next:])
+				if nextImm, ok := arm64SubSPImm(nextWord); ok {
+					imm += nextImm
+					size += insnLen
+					if nextInst, err := arm64asm.Decode(code[next : next+insnLen]); err == nil {
+						insns += fmt.Sprintf("; sub sp, sp, %s", nextInst.Args[2])
 					}
 				}
 			}
+			result = append(result, Prologue{
+				Address:      addr,
+				Type:         PrologueSubSP,
+				Instructions: insns,
+				FrameSize:    imm,
+				Size:         size,
+			})
+		}
+
+		// Pattern 3 (large frame): mov/movk Xn, #imm ... ; sub sp, sp, Xn.
+		// The prologue starts at the first instruction materializing the
+		// frame size, which must itself sit at a function boundary.
+		if rm, ok := arm64SubSPReg(word); ok && movSeq.valid && movSeq.reg == rm && movSeq.atBoundary {
+			result = append(result, Prologue{
+				Address:      movSeq.start,
+				Type:         PrologueSubSP,
+				Instructions: fmt.Sprintf("mov x%d, #0x%x; sub sp, sp, x%d", rm, movSeq.value, rm),
+				FrameSize:    movSeq.value,
+				Size:         addr + insnLen - movSeq.start,
+			})
+		}
+		movSeq.step(word, addr, atBoundary(PrologueSubSP))
+
+		// Pattern 6: Go stack-bound check - ldr x16, [x28, #16] loads
+		// g.stackguard0; the check ends at the conditional branch taken
+		// after comparing it with the lowered stack pointer.
+		if word == arm64GoLoadStackGuard && o.prologueEnabled(PrologueGoStackCheck) {
+			if size, ok := scanGoStackCheckARM64(code, offset); ok {
+				result = append(result, Prologue{
+					Address:      addr,
+					Type:         PrologueGoStackCheck,
+					Instructions: "ldr x16, [x28, #16]; cmp; b.ls",
+					Size:         size,
+				})
+			}
 		}
 
+		hist.push(addr, classifyARM64(inst, word))
 		prevInsn = &inst
+		prevOffset = offset
+	}
+
+	// A prologue opened by a PAC instruction starts at it.
+	for i, p := range result {
+		if mnemonic, ok := pacs[p.Address]; ok {
+			result[i].Address -= insnLen
+			result[i].Size += insnLen
+			result[i].Instructions = mnemonic + "; " + p.Instructions
+		}
 	}
 
 	return result, nil
@@ -2,10 +2,14 @@ package resurgo_test
 
 import (
 	"bytes"
+	"cmp"
 	"debug/elf"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
 	"testing"
 
 	"github.com/maxgio92/resurgo"
@@ -153,6 +157,341 @@ func TestWithFilters(t *testing.T) {
 	}
 }
 
+// TestWithExtraFilters verifies that filters appended with WithExtraFilters
+// run after the default pipeline, and that candidates a filter adds are
+// discarded while its annotations are kept.
+func TestWithExtraFilters(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("os.Executable: %v", err)
+	}
+	f, err := elf.Open(exe)
+	if err != nil {
+		t.Fatalf("elf.Open: %v", err)
+	}
+	defer f.Close()
+
+	fakeDetector := func(*elf.File) ([]resurgo.FunctionCandidate, error) {
+		return []resurgo.FunctionCandidate{{Address: 0x1000}}, nil
+	}
+	annotate := func(cs []resurgo.FunctionCandidate, _ *elf.File) ([]resurgo.FunctionCandidate, error) {
+		for i := range cs {
+			cs[i].Name = "annotated"
+		}
+		return append(cs, resurgo.FunctionCandidate{Address: 0x2000}), nil
+	}
+
+	var tel resurgo.Telemetry
+	if _, err := resurgo.DetectFunctionsFromELF(f,
+		resurgo.WithDetectors(fakeDetector),
+		resurgo.WithExtraFilters(annotate),
+		resurgo.WithTelemetry(&tel),
+	); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, st := range tel.Filters {
+		names = append(names, st.Name)
+	}
+	want := []string{"CETFilter", "EhFrameFilter", "PLTFilter", "resurgo_test.TestWithExtraFilters.func2"}
+	if !slices.Equal(names, want) {
+		t.Errorf("filters = %v, want %v", names, want)
+	}
+
+	candidates, err := resurgo.DetectFunctionsFromELF(f,
+		resurgo.WithDetectors(fakeDetector),
+		resurgo.WithFilters(),
+		resurgo.WithExtraFilters(annotate),
+		resurgo.WithAnchors(false),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Address != 0x1000 || candidates[0].Name != "annotated" {
+		t.Errorf("got %+v, want the annotated candidate at 0x1000 only", candidates)
+	}
+}
+
+func TestWithTelemetry(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	outPath := filepath.Join(t.TempDir(), "demo-app-c")
+	cmd := exec.Command("gcc", "-O0", "-o", outPath, "testdata/demo-app.c")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF binary: %v", err)
+	}
+	defer f.Close()
+
+	// dropAll removes every candidate.
+	dropAll := func([]resurgo.FunctionCandidate, *elf.File) ([]resurgo.FunctionCandidate, error) {
+		return nil, nil
+	}
+
+	tests := []struct {
+		name      string
+		opts      []resurgo.Option
+		detectors []string
+		filters   []string
+		dropped   bool
+	}{{
+		name:      "default",
+		detectors: []string{"DisasmDetector", "EhFrameDetector", "AnchorDetector"},
+		filters:   []string{"CETFilter", "EhFrameFilter", "PLTFilter"},
+	}, {
+		name: "custom",
+		opts: []resurgo.Option{
+			resurgo.WithDetectors(resurgo.EhFrameDetector),
+			resurgo.WithFilters(resurgo.PLTFilter, dropAll),
+			resurgo.WithAnchors(false),
+		},
+		detectors: []string{"EhFrameDetector"},
+		filters:   []string{"PLTFilter", "resurgo_test.TestWithTelemetry.func1"},
+		dropped:   true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tel resurgo.Telemetry
+			candidates, err := resurgo.DetectFunctionsFromELF(f, append(tt.opts, resurgo.WithTelemetry(&tel))...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tel.Signals.Toolchain != resurgo.ToolchainGCC {
+				t.Errorf("Signals.Toolchain = %s, want %s", tel.Signals.Toolchain, resurgo.ToolchainGCC)
+			}
+			if tel.Signals.FDEs == 0 || tel.Signals.TextSize == 0 || !tel.Signals.Symbols {
+				t.Errorf("Signals = %+v, want FDEs, .text and symbols", tel.Signals)
+			}
+			var names []string
+			for _, d := range tel.Detectors {
+				names = append(names, d.Name)
+			}
+			if !reflect.DeepEqual(names, tt.detectors) {
+				t.Errorf("detectors = %v, want %v", names, tt.detectors)
+			}
+			names = nil
+			kept := 0
+			for _, s := range tel.Filters {
+				names = append(names, s.Name)
+				kept = s.Candidates
+			}
+			if !reflect.DeepEqual(names, tt.filters) {
+				t.Errorf("filters = %v, want %v", names, tt.filters)
+			}
+			// Anchors dropped by a filter are restored.
+			if tel.Candidates != len(candidates) || kept > len(candidates) {
+				t.Errorf("Candidates = %d, last filter kept %d, got %d candidates", tel.Candidates, kept, len(candidates))
+			}
+			if last := tel.Filters[len(tel.Filters)-1]; tt.dropped && last.Dropped == 0 {
+				t.Errorf("%s: no drops reported", last.Name)
+			}
+			if len(candidates) > 0 && (tel.Coverage <= 0 || tel.Coverage > 100) {
+				t.Errorf("Coverage = %.1f%%, want (0, 100]", tel.Coverage)
+			}
+			if tel.Elapsed <= 0 {
+				t.Error("Elapsed not recorded")
+			}
+		})
+	}
+}
+
+// TestDetectFunctionsFromELF_Thumb verifies that ARM and Thumb regions of a
+// mixed .text section are told apart by their mapping symbols and that the
+// literal pool between them is not decoded.
+func TestDetectFunctionsFromELF_Thumb(t *testing.T) {
+	if _, err := exec.LookPath("llvm-mc"); err != nil {
+		t.Skip("llvm-mc not found in PATH")
+	}
+	outPath := filepath.Join(t.TempDir(), "thumb.o")
+	cmd := exec.Command("llvm-mc", "-triple=thumbv7-linux-gnueabihf", "-filetype=obj", "-o", outPath, "testdata/thumb.s")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to assemble testdata/thumb.s: %v\n%s", err, out)
+	}
+
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	candidates, err := resurgo.DetectFunctionsFromELF(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := make(map[uint64]resurgo.PrologueType)
+	for _, c := range candidates {
+		got[c.Address] = c.PrologueType
+	}
+	want := map[uint64]resurgo.PrologueType{
+		0x00: resurgo.ProloguePushFPLR, // thumb_fp
+		0x0c: resurgo.ProloguePushLR,   // thumb_lr
+		0x24: resurgo.ProloguePushFPLR, // arm_fp
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got candidates %v, want %v", got, want)
+	}
+}
+
+// TestDetectFunctionsFromELF_ARM64MappingSymbols verifies that the literal
+// pool of an ARM64 .text section, marked by a $d mapping symbol, is not
+// decoded, and that the whole section is when the mapping symbols are
+// stripped.
+func TestDetectFunctionsFromELF_ARM64MappingSymbols(t *testing.T) {
+	for _, tool := range []string{"llvm-mc", "llvm-objcopy"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found in PATH", tool)
+		}
+	}
+	dir := t.TempDir()
+	objPath := filepath.Join(dir, "arm64-pool.o")
+	cmd := exec.Command("llvm-mc", "-triple=aarch64-linux-gnu", "-filetype=obj", "-o", objPath, "testdata/arm64-pool.s")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to assemble testdata/arm64-pool.s: %v\n%s", err, out)
+	}
+	strippedPath := filepath.Join(dir, "arm64-pool-stripped.o")
+	cmd = exec.Command("llvm-objcopy", "--wildcard", "--strip-symbol=$*", objPath, strippedPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to strip mapping symbols: %v\n%s", err, out)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want map[uint64]resurgo.PrologueType
+	}{{
+		name: "mapping-symbols",
+		path: objPath,
+		want: map[uint64]resurgo.PrologueType{
+			0x00: resurgo.PrologueSTPFramePair, // framed
+			0x1c: resurgo.PrologueSubSP,        // leaf, after the pool
+		},
+	}, {
+		// The pool decodes as a prologue, hiding leaf.
+		name: "stripped",
+		path: strippedPath,
+		want: map[uint64]resurgo.PrologueType{
+			0x00: resurgo.PrologueSTPFramePair,
+			0x14: resurgo.PrologueSTPFramePair,
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := elf.Open(tt.path)
+			if err != nil {
+				t.Fatalf("failed to open ELF: %v", err)
+			}
+			defer f.Close()
+
+			candidates, err := resurgo.DetectFunctionsFromELF(f)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := make(map[uint64]resurgo.PrologueType)
+			for _, c := range candidates {
+				got[c.Address] = c.PrologueType
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got candidates %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDetectFunctionsFromELF_PPC64LE verifies that ppc64le functions are
+// reported at their global entry point, whether the local entry point is
+// recognized from the code or only known from st_other.
+func TestDetectFunctionsFromELF_PPC64LE(t *testing.T) {
+	if _, err := exec.LookPath("llvm-mc"); err != nil {
+		t.Skip("llvm-mc not found in PATH")
+	}
+	outPath := filepath.Join(t.TempDir(), "ppc64le.o")
+	cmd := exec.Command("llvm-mc", "-triple=powerpc64le-linux-gnu", "-filetype=obj", "-o", outPath, "testdata/ppc64le.s")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to assemble testdata/ppc64le.s: %v\n%s", err, out)
+	}
+
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	candidates, err := resurgo.DetectFunctionsFromELF(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := make(map[uint64]resurgo.DetectionType)
+	for _, c := range candidates {
+		got[c.Address] = c.DetectionType
+	}
+	want := map[uint64]resurgo.DetectionType{
+		0x00: resurgo.DetectionPrologueOnly,     // caller
+		0x40: resurgo.DetectionPrologueCallSite, // leaf, called at 0x50
+		0x60: resurgo.DetectionCallTarget,       // helper
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got candidates %v, want %v", got, want)
+	}
+}
+
+// TestDetectFunctionsFromELF_ByteOrder verifies that the instructions of
+// big-endian ELF files are decoded in the byte order of their header: the
+// same code assembled for either byte order yields the same candidates.
+func TestDetectFunctionsFromELF_ByteOrder(t *testing.T) {
+	if _, err := exec.LookPath("llvm-mc"); err != nil {
+		t.Skip("llvm-mc not found in PATH")
+	}
+	tests := []struct {
+		name   string
+		src    string
+		le, be string
+	}{
+		{"arm", "testdata/thumb.s", "thumbv7-linux-gnueabihf", "thumbebv7-linux-gnueabihf"},
+		{"ppc64", "testdata/ppc64le.s", "powerpc64le-linux-gnu", "powerpc64-linux-gnu"},
+	}
+	detect := func(t *testing.T, triple, src string) ([]resurgo.FunctionCandidate, elf.Data) {
+		t.Helper()
+		outPath := filepath.Join(t.TempDir(), triple+".o")
+		cmd := exec.Command("llvm-mc", "-triple="+triple, "-filetype=obj", "-o", outPath, src)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("failed to assemble %s: %v\n%s", src, err, out)
+		}
+		f, err := elf.Open(outPath)
+		if err != nil {
+			t.Fatalf("failed to open ELF: %v", err)
+		}
+		defer f.Close()
+		candidates, err := resurgo.DetectFunctionsFromELF(f)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return candidates, f.Data
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, _ := detect(t, tt.le, tt.src)
+			got, data := detect(t, tt.be, tt.src)
+			if data != elf.ELFDATA2MSB {
+				t.Fatalf("expected a big-endian file, got %s", data)
+			}
+			if len(want) == 0 {
+				t.Fatal("no candidates detected")
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got candidates %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
 func TestDetectFunctionsFromELF_InvalidELF(t *testing.T) {
 	r := bytes.NewReader([]byte{0x00, 0x01, 0x02, 0x03})
 	f, err := elf.NewFile(r)
@@ -162,6 +501,108 @@ func TestDetectFunctionsFromELF_InvalidELF(t *testing.T) {
 	}
 }
 
+// TestCallTargets verifies that a function without prologue called from
+// several sites is reported once as a call target, and that calls through
+// memory outside the code report no candidate.
+func TestCallTargets(t *testing.T) {
+	const base = 0x1000
+	code := []byte{
+		0xe8, 0x0b, 0x00, 0x00, 0x00, // 0x1000: call 0x1010
+		0xe8, 0x06, 0x00, 0x00, 0x00, // 0x1005: call 0x1010
+		0xff, 0x15, 0x00, 0x10, 0x00, 0x00, // 0x100a: call [rip+0x1000], a GOT slot at 0x2010
+		0x31, 0xc0, // 0x1010: xor eax, eax
+		0xc3, // 0x1012: ret
+	}
+	result, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(code), int64(len(code)),
+		resurgo.WithArch(resurgo.ArchAMD64), resurgo.WithBaseAddress(base))
+	if err != nil {
+		t.Fatalf("resurgo.DetectFunctionsFromReader: %v", err)
+	}
+	got := make(map[uint64]resurgo.FunctionCandidate)
+	for _, c := range result.Functions {
+		got[c.Address] = c
+	}
+	leaf, ok := got[0x1010]
+	if !ok {
+		t.Fatalf("call target 0x1010 not detected: %+v", result.Functions)
+	}
+	if leaf.DetectionType != resurgo.DetectionCallTarget || leaf.Confidence != resurgo.ConfidenceMedium {
+		t.Errorf("0x1010: got %s/%s, want %s/%s", leaf.DetectionType, leaf.Confidence, resurgo.DetectionCallTarget, resurgo.ConfidenceMedium)
+	}
+	if want := []uint64{0x1000, 0x1005}; !reflect.DeepEqual(leaf.CalledFrom, want) {
+		t.Errorf("0x1010: called from %#x, want %#x", leaf.CalledFrom, want)
+	}
+	if c, ok := got[0x2010]; ok {
+		t.Errorf("unexpected candidate at the GOT slot: %+v", c)
+	}
+}
+
+// tailCallSource holds a frameless function only reached by the tail call
+// of another.
+const tailCallSource = `#include <stdio.h>
+__attribute__((noinline)) static int helper(int x) { return x * 3 + 1; }
+__attribute__((noinline)) int entry(int x) { return helper(x + 1); }
+int main(int argc, char **argv) { printf("%d\n", entry(argc)); return 0; }
+`
+
+// TestTailCallTargets verifies that a function reached only by a tail call
+// from another function, after the padding ending the function before it,
+// is reported as a tail-call target.
+func TestTailCallTargets(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("tail call test requires an amd64 compiler")
+	}
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "tailcall.c")
+	if err := os.WriteFile(src, []byte(tailCallSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	binPath := filepath.Join(dir, "tailcall")
+	if out, err := exec.Command("gcc", "-O2", "-o", binPath, src).CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile: %v\n%s", err, out)
+	}
+	f, err := elf.Open(binPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	var helper, entry uint64
+	for _, s := range syms {
+		switch s.Name {
+		case "helper":
+			helper = s.Value
+		case "entry":
+			entry = s.Value
+		}
+	}
+	if helper == 0 || entry == 0 || helper > entry {
+		t.Skipf("unexpected layout: helper at 0x%x, entry at 0x%x", helper, entry)
+	}
+
+	candidates, err := resurgo.DisasmDetector(f)
+	if err != nil {
+		t.Fatalf("resurgo.DisasmDetector: %v", err)
+	}
+	i := slices.IndexFunc(candidates, func(c resurgo.FunctionCandidate) bool { return c.Address == helper })
+	if i < 0 {
+		t.Fatalf("helper at 0x%x not detected", helper)
+	}
+	c := candidates[i]
+	if c.DetectionType != resurgo.DetectionTailCall || c.Confidence != resurgo.ConfidenceHigh || !c.Boundary {
+		t.Errorf("helper: got %s/%s boundary %t, want %s/%s at a boundary", c.DetectionType, c.Confidence, c.Boundary, resurgo.DetectionTailCall, resurgo.ConfidenceHigh)
+	}
+	if len(c.JumpedFrom) != 1 || c.JumpedFrom[0] < entry {
+		t.Errorf("helper: jumped from %#x, want a jump from entry at 0x%x", c.JumpedFrom, entry)
+	}
+}
+
 // TestDetectors verifies that each detector, when run against a C ELF binary,
 // returns non-empty results that include at least one candidate of the expected
 // detection type.
@@ -243,4 +684,430 @@ func TestDetectors(t *testing.T) {
 	}
 }
 
+// TestEhFrameDetector_EhFrameHdr verifies that the FDE starts read from the
+// .eh_frame_hdr search table match those of a full walk of .eh_frame.
+func TestEhFrameDetector_EhFrameHdr(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	path := filepath.Join(t.TempDir(), "demo-app-c")
+	if out, err := exec.Command("gcc", "-O2", "-o", path, "testdata/demo-app.c").CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse ELF: %v", err)
+	}
+	if f.Section(".eh_frame_hdr") == nil {
+		t.Skip("no .eh_frame_hdr section")
+	}
+
+	// Rename .eh_frame_hdr in the section name table, so that .eh_frame
+	// is walked instead.
+	shstrtab := f.Section(".shstrtab")
+	names := data[shstrtab.Offset : shstrtab.Offset+shstrtab.Size]
+	i := bytes.Index(names, []byte(".eh_frame_hdr\x00"))
+	if i < 0 {
+		t.Fatal(".eh_frame_hdr not found in the section name table")
+	}
+	walked := bytes.Clone(data)
+	copy(walked[shstrtab.Offset+uint64(i):], ".eh_frame_xyz")
+	g, err := elf.NewFile(bytes.NewReader(walked))
+	if err != nil {
+		t.Fatalf("failed to parse ELF: %v", err)
+	}
+	if g.Section(".eh_frame_hdr") != nil {
+		t.Fatal(".eh_frame_hdr not renamed")
+	}
+
+	fromHdr, err := resurgo.EhFrameDetector(f)
+	if err != nil {
+		t.Fatalf("EhFrameDetector: %v", err)
+	}
+	fromWalk, err := resurgo.EhFrameDetector(g)
+	if err != nil {
+		t.Fatalf("EhFrameDetector without .eh_frame_hdr: %v", err)
+	}
+	addrs := func(candidates []resurgo.FunctionCandidate) []uint64 {
+		var out []uint64
+		for _, c := range candidates {
+			out = append(out, c.Address)
+		}
+		slices.Sort(out)
+		return out
+	}
+	if got, want := addrs(fromHdr), addrs(fromWalk); len(got) == 0 || !slices.Equal(got, want) {
+		t.Errorf("FDE starts from .eh_frame_hdr %#x, from .eh_frame %#x", got, want)
+	}
+}
+
+// TestDetectFunctionsFromELF_Sections verifies that functions outside .text
+// are detected and named after their section with WithSectionNames.
+func TestDetectFunctionsFromELF_Sections(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "sections.c")
+	code := `__attribute__((noinline, section(".custom"))) int custom(int a) { return a * 3; }
+__attribute__((noinline, section(".custom"))) int other(int a) { return custom(a) + 1; }
+int main(int argc, char **argv) { return other(argc); }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	outPath := filepath.Join(dir, "sections")
+	// -fno-asynchronous-unwind-tables leaves the functions to the
+	// disassembly-based detection, and no filter drops them for lacking an
+	// FDE.
+	cmd := exec.Command("gcc", "-O0", "-fno-asynchronous-unwind-tables", "-o", outPath, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile sections.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF binary: %v", err)
+	}
+	defer f.Close()
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+
+	candidates, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithFilters(), resurgo.WithSectionNames(true))
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromELF: %v", err)
+	}
+	sections := make(map[uint64]string, len(candidates))
+	for _, c := range candidates {
+		sections[c.Address] = c.Section
+	}
+	for _, sym := range syms {
+		want := map[string]string{"custom": ".custom", "other": ".custom", "main": ".text"}[sym.Name]
+		if want == "" {
+			continue
+		}
+		got, ok := sections[sym.Value]
+		if !ok {
+			t.Errorf("%s at 0x%x not detected", sym.Name, sym.Value)
+		} else if got != want {
+			t.Errorf("%s at 0x%x: got section %q, want %q", sym.Name, sym.Value, got, want)
+		}
+	}
+
+	// Without WithSectionNames, candidates carry no section.
+	candidates, err = resurgo.DetectFunctionsFromELF(f, resurgo.WithFilters())
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromELF: %v", err)
+	}
+	for _, c := range candidates {
+		if c.Section != "" {
+			t.Fatalf("0x%x: got section %q without WithSectionNames", c.Address, c.Section)
+		}
+	}
+}
+
+// TestDetectFunctionsFromELF_DisjointSegments verifies that code linked into
+// several executable segments at distant addresses is scanned in full, with
+// and without section headers.
+func TestDetectFunctionsFromELF_DisjointSegments(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "far.c")
+	code := `__attribute__((noinline, section(".far"))) int far(int a) { return a * 3; }
+__attribute__((noinline, section(".far"))) int farther(int a) { return far(a) + 1; }
+int main(int argc, char **argv) { return farther(argc); }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	outPath := filepath.Join(dir, "far")
+	cmd := exec.Command("gcc", "-O0", "-no-pie", "-Wl,--section-start=.far=0x10000000", "-o", outPath, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("failed to compile far.c: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse ELF: %v", err)
+	}
+	syms, err := orig.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	var segments int
+	for _, p := range orig.Progs {
+		if p.Type == elf.PT_LOAD && p.Flags&elf.PF_X != 0 {
+			segments++
+		}
+	}
+	if segments < 2 {
+		t.Fatalf("got %d executable segments, want 2", segments)
+	}
+
+	stripped := bytes.Clone(data)
+	// Drop the section header table: e_shoff, e_shnum and e_shstrndx.
+	copy(stripped[0x28:], make([]byte, 8))
+	copy(stripped[0x3c:], make([]byte, 4))
+
+	for name, image := range map[string][]byte{"sections": data, "segments": stripped} {
+		t.Run(name, func(t *testing.T) {
+			f, err := elf.NewFile(bytes.NewReader(image))
+			if err != nil {
+				t.Fatalf("failed to parse ELF: %v", err)
+			}
+			var tel resurgo.Telemetry
+			candidates, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithFilters(), resurgo.WithTelemetry(&tel))
+			if err != nil {
+				t.Fatalf("DetectFunctionsFromELF: %v", err)
+			}
+			if !slices.IsSortedFunc(candidates, func(a, b resurgo.FunctionCandidate) int {
+				return cmp.Compare(a.Address, b.Address)
+			}) {
+				t.Error("candidates not ordered by address")
+			}
+			prologues, err := resurgo.DetectProloguesFromELF(f)
+			if err != nil {
+				t.Fatalf("DetectProloguesFromELF: %v", err)
+			}
+			found := make(map[uint64]bool)
+			for _, c := range candidates {
+				found[c.Address] = true
+			}
+			hasPrologue := make(map[uint64]bool)
+			for _, p := range prologues {
+				hasPrologue[p.Address] = true
+			}
+			for _, sym := range syms {
+				switch sym.Name {
+				case "far", "farther", "main":
+				default:
+					continue
+				}
+				if !found[sym.Value] {
+					t.Errorf("%s at 0x%x not detected", sym.Name, sym.Value)
+				}
+				if !hasPrologue[sym.Value] {
+					t.Errorf("no prologue for %s at 0x%x", sym.Name, sym.Value)
+				}
+			}
+			if tel.Coverage <= 0 || tel.Signals.TextSize == 0 {
+				t.Errorf("got coverage %.1f%% of %d bytes", tel.Coverage, tel.Signals.TextSize)
+			}
+		})
+	}
+}
+
+// TestDetectFunctionsFromELF_NoSectionHeaders verifies that binaries without
+// a section header table are scanned through their executable segments.
+func TestDetectFunctionsFromELF_NoSectionHeaders(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	outPath := filepath.Join(t.TempDir(), "demo-app-c")
+	cmd := exec.Command("gcc", "-O0", "-o", outPath, "testdata/demo-app.c")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	orig, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF binary: %v", err)
+	}
+	defer orig.Close()
+	syms, err := orig.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+
+	// Drop the section header table: e_shoff, e_shnum and e_shstrndx.
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(data[0x28:], make([]byte, 8))
+	copy(data[0x3c:], make([]byte, 4))
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse ELF without section headers: %v", err)
+	}
+	if len(f.Sections) != 0 {
+		t.Fatalf("got %d sections, want none", len(f.Sections))
+	}
+
+	candidates, err := resurgo.DetectFunctionsFromELF(f)
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromELF: %v", err)
+	}
+	prologues, err := resurgo.DetectProloguesFromELF(f)
+	if err != nil {
+		t.Fatalf("DetectProloguesFromELF: %v", err)
+	}
+	detected := make(map[uint64]bool, len(candidates))
+	for _, c := range candidates {
+		detected[c.Address] = true
+	}
+	matched := make(map[uint64]bool, len(prologues))
+	for _, p := range prologues {
+		matched[p.Address] = true
+	}
+	for _, sym := range syms {
+		switch sym.Name {
+		case "add", "multiply", "subtract", "divide", "main":
+			if !detected[sym.Value] {
+				t.Errorf("%s at 0x%x: not detected", sym.Name, sym.Value)
+			}
+			if !matched[sym.Value] {
+				t.Errorf("%s at 0x%x: no prologue", sym.Name, sym.Value)
+			}
+		}
+	}
+}
+
+// TestDetectToolchain verifies compiler fingerprinting of Go and C binaries.
+func TestDetectToolchain(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(t *testing.T, dir string) string
+		want  resurgo.Toolchain
+	}{{
+		name: "go",
+		build: func(t *testing.T, dir string) string {
+			t.Helper()
+			binPath := filepath.Join(dir, demoAppBinary)
+			cmd := exec.Command("go", "build", "-o", binPath, demoAppSource)
+			cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOARCH=amd64")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("failed to compile demo-app: %v\n%s", err, out)
+			}
+			return binPath
+		},
+		want: resurgo.ToolchainGo,
+	}, {
+		name: "gcc",
+		build: func(t *testing.T, dir string) string {
+			t.Helper()
+			if _, err := exec.LookPath("gcc"); err != nil {
+				t.Skip("gcc not found, skipping")
+			}
+			outPath := filepath.Join(dir, "demo-app-c")
+			cmd := exec.Command("gcc", "-O0", "-o", outPath, "testdata/demo-app.c")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+			}
+			return outPath
+		},
+		want: resurgo.ToolchainGCC,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := elf.Open(tt.build(t, t.TempDir()))
+			if err != nil {
+				t.Fatalf("failed to open ELF binary: %v", err)
+			}
+			defer f.Close()
+
+			if got := resurgo.DetectToolchain(f); got != tt.want {
+				t.Errorf("expected toolchain %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestWithPreset verifies that PresetStrict does not lose precision and
+// PresetPermissive does not lose recall compared to the default options,
+// using the symbol table of the unstripped binaries as ground truth.
+func TestWithPreset(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(t *testing.T, dir string) string
+	}{{
+		name: "go",
+		build: func(t *testing.T, dir string) string {
+			t.Helper()
+			binPath := filepath.Join(dir, demoAppBinary)
+			cmd := exec.Command("go", "build", "-o", binPath, demoAppSource)
+			cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOARCH=amd64")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("failed to compile demo-app: %v\n%s", err, out)
+			}
+			return binPath
+		},
+	}, {
+		name: "c",
+		build: func(t *testing.T, dir string) string {
+			t.Helper()
+			if _, err := exec.LookPath("gcc"); err != nil {
+				t.Skip("gcc not found, skipping")
+			}
+			outPath := filepath.Join(dir, "demo-app-c")
+			cmd := exec.Command("gcc", "-O2", "-o", outPath, "testdata/demo-app.c")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+			}
+			return outPath
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := elf.Open(tt.build(t, t.TempDir()))
+			if err != nil {
+				t.Fatalf("failed to open ELF binary: %v", err)
+			}
+			defer f.Close()
+
+			syms, err := f.Symbols()
+			if err != nil {
+				t.Fatalf("failed to read symbols: %v", err)
+			}
+			truth := make(map[uint64]bool)
+			for _, s := range syms {
+				if elf.ST_TYPE(s.Info) == elf.STT_FUNC && s.Value != 0 {
+					truth[s.Value] = true
+				}
+			}
+
+			// score returns the number of candidates and of true positives.
+			score := func(opts ...resurgo.Option) (n, tp int) {
+				t.Helper()
+				candidates, err := resurgo.DetectFunctionsFromELF(f, opts...)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				for _, c := range candidates {
+					if truth[c.Address] {
+						tp++
+					}
+				}
+				return len(candidates), tp
+			}
 
+			n, tp := score()
+			strictN, strictTP := score(resurgo.WithPreset(resurgo.PresetStrict))
+			permissiveN, permissiveTP := score(resurgo.WithPreset(resurgo.PresetPermissive))
+			t.Logf("default %d/%d, strict %d/%d, permissive %d/%d (true positives/candidates)",
+				tp, n, strictTP, strictN, permissiveTP, permissiveN)
+
+			if strictTP == 0 || strictTP*n < tp*strictN {
+				t.Errorf("strict: precision %d/%d below default %d/%d", strictTP, strictN, tp, n)
+			}
+			if permissiveTP < tp {
+				t.Errorf("permissive: %d true positives, default found %d", permissiveTP, tp)
+			}
+		})
+	}
+}
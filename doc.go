@@ -15,8 +15,14 @@
 // [*elf.File], runs all detectors and filters, and returns a deduplicated,
 // filtered slice of [FunctionCandidate] values.
 //
+// [DetectFunctionsFromFile] and [DetectFunctionsFromReader] sniff the format
+// of their input (ELF, PE, Mach-O, ar archives or raw code) and return an
+// [AnalysisResult].
+//
 // For format-agnostic use (non-ELF binaries, raw memory dumps) the lower-level
 // [DetectPrologues] and [DetectCallSites] APIs accept raw machine code bytes.
 //
-// Supported architectures: x86_64 (AMD64) and ARM64 (AArch64).
+// Supported architectures: x86_64 (AMD64), i386, ARM64 (AArch64), ARM32
+// (A32 and Thumb-2), RISC-V 64, ppc64le, big-endian ppc64 and little-endian
+// Xtensa.
 package resurgo
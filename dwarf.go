@@ -25,6 +25,7 @@ const (
 	ehPeAbsptr      = byte(0x00)             // absolute, pointer-sized (4 or 8 bytes)
 	ehPeSdata4      = byte(0x0b)             // signed 32-bit integer
 	ehPePcrel       = byte(0x10)             // PC-relative: add field's own VA to value
+	ehPeDatarel     = byte(0x30)             // relative to .eh_frame_hdr, in its search table
 	ehPeOmit        = byte(0xff)             // field is not present; skip FDE
 	ehPePcrelSdata4 = ehPePcrel | ehPeSdata4 // 0x1b — most common on Linux
 )
@@ -33,6 +34,33 @@ const (
 // decoding FDEs that reference it.
 type cieInfo struct {
 	fdeEncoding byte // DW_EH_PE_* byte from 'R' augmentation datum
+	// lsdaEncoding is the DW_EH_PE_* byte from the 'L' augmentation datum,
+	// ehPeOmit when FDEs carry no LSDA pointer.
+	lsdaEncoding byte
+	// hasAugData reports whether the augmentation string starts with 'z',
+	// in which case every FDE carries an augmentation data block.
+	hasAugData bool
+	// codeAlign and dataAlign scale the operands of advance and offset
+	// call frame instructions.
+	codeAlign uint64
+	dataAlign int64
+	// initialInsns are the call frame instructions that set up the rules
+	// in effect at the start of every FDE referencing this CIE.
+	initialInsns []byte
+}
+
+// fdeInfo holds the fields of an FDE record used by the detectors and the
+// CFI consistency filter.
+type fdeInfo struct {
+	// start and end delimit the [start, end) address range covered by the FDE.
+	start, end uint64
+	// cie is the CIE the FDE references.
+	cie cieInfo
+	// insns are the FDE's call frame instructions.
+	insns []byte
+	// lsda is the address of the FDE's language-specific data area, zero
+	// when it has none.
+	lsda uint64
 }
 
 // EhFrameDetector is a CandidateDetector that emits function candidates
@@ -87,16 +115,110 @@ func EhFrameFilter(candidates []FunctionCandidate, f *elf.File) ([]FunctionCandi
 // absolute virtual address of every FDE's initial_location field.
 // These addresses are function entry points written by the compiler.
 //
+// When .eh_frame_hdr holds its binary-search table, the addresses are read
+// from the table instead, without walking .eh_frame.
+//
 // Returns nil (no error) if .eh_frame is absent — the caller treats this
 // as a signal to fall back to the disassembly-only pipeline.
 // Returns an error only for I/O failures; malformed records are skipped.
 func parseEhFrameEntries(f *elf.File) ([]uint64, error) {
+	if entries, ok, err := parseEhFrameHdr(f); err != nil || ok {
+		return entries, err
+	}
+	fdes, err := parseEhFrameFDEs(f)
+	if err != nil {
+		return nil, err
+	}
+	var entries []uint64
+	for _, fde := range fdes {
+		entries = append(entries, fde.start)
+	}
+	return entries, nil
+}
+
+// parseEhFrameHdr returns the initial locations listed in the binary-search
+// table of the .eh_frame_hdr section of f, in ascending order. ok is false
+// when the section is absent, has no table or uses encodings that are not
+// handled, and .eh_frame must be parsed instead.
+//
+// The header holds a version byte, the encodings of the eh_frame_ptr,
+// fde_count and table fields, then those fields: a pointer to .eh_frame,
+// the number of FDEs and one (initial_location, FDE address) pair per FDE,
+// sorted by initial_location.
+func parseEhFrameHdr(f *elf.File) (entries []uint64, ok bool, err error) {
+	sec := f.Section(".eh_frame_hdr")
+	if sec == nil || f.Type == elf.ET_REL || f.Section(".eh_frame") == nil {
+		return nil, false, nil
+	}
+	data, err := sectionData(sec)
+	if err != nil {
+		return nil, false, fmt.Errorf("read .eh_frame_hdr: %w", err)
+	}
+	if len(data) < 4 || data[0] != 1 {
+		return nil, false, nil
+	}
+	ptrEnc, countEnc, tableEnc := data[1], data[2], data[3]
+	ptrSize := 4
+	if f.Class == elf.ELFCLASS64 {
+		ptrSize = 8
+	}
+	off := 4
+	if ptrEnc != ehPeOmit {
+		n := encodedValueSize(ptrEnc, ptrSize)
+		if n == 0 {
+			return nil, false, nil
+		}
+		off += n
+	}
+	if countEnc == ehPeOmit || tableEnc == ehPeOmit {
+		return nil, false, nil
+	}
+	count, ok := readEncodedValue(data, off, countEnc&0x0f, f.ByteOrder, ptrSize)
+	if !ok || count == 0 {
+		return nil, false, nil
+	}
+	off += encodedValueSize(countEnc, ptrSize)
+
+	n := encodedValueSize(tableEnc, ptrSize)
+	if n == 0 || count > uint64(len(data)-off)/uint64(2*n) {
+		return nil, false, nil
+	}
+	entries = make([]uint64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		v, ok := readEncodedValue(data, off, tableEnc&0x0f, f.ByteOrder, ptrSize)
+		if !ok {
+			return nil, false, nil
+		}
+		// The table is usually datarel: relative to the start of
+		// .eh_frame_hdr.
+		switch tableEnc & 0x70 {
+		case 0x00: // absptr
+		case ehPePcrel:
+			v += sec.Addr + uint64(off)
+		case ehPeDatarel:
+			v += sec.Addr
+		default:
+			return nil, false, nil
+		}
+		entries = append(entries, v)
+		off += 2 * n
+	}
+	return entries, true, nil
+}
+
+// parseEhFrameFDEs parses the .eh_frame section of f and returns every FDE
+// whose initial_location could be decoded, in section order. The address
+// range and call frame instructions are filled in when they can be decoded.
+//
+// Returns nil (no error) if .eh_frame is absent, or if f is a relocatable
+// object, whose initial_location fields are only resolved at link time.
+func parseEhFrameFDEs(f *elf.File) ([]fdeInfo, error) {
 	sec := f.Section(".eh_frame")
-	if sec == nil {
+	if sec == nil || f.Type == elf.ET_REL {
 		return nil, nil
 	}
 
-	data, err := sec.Data()
+	data, err := sectionData(sec)
 	if err != nil {
 		return nil, fmt.Errorf("read .eh_frame: %w", err)
 	}
@@ -113,7 +235,7 @@ func parseEhFrameEntries(f *elf.File) ([]uint64, error) {
 	// cies maps the byte offset of each CIE record's start within data
 	// to the parsed cieInfo for that CIE.
 	cies := make(map[int]cieInfo)
-	var entries []uint64
+	var fdes []fdeInfo
 
 	off := 0
 	for off < len(data) {
@@ -182,21 +304,107 @@ func parseEhFrameEntries(f *elf.File) ([]uint64, error) {
 				data, off, secAddr, cie.fdeEncoding, bo, ptrSize,
 			)
 			if ok {
-				entries = append(entries, va)
+				fdes = append(fdes, parseFDEBody(data, off, recEnd, secAddr, va, cie, bo, ptrSize))
 			}
 		}
 
 		off = recEnd
 	}
 
-	return entries, nil
+	return fdes, nil
+}
+
+// parseFDEBody decodes the address_range, LSDA pointer and call frame
+// instructions of an FDE whose initial_location (already decoded as start)
+// begins at data[off]. Fields that cannot be decoded are left empty; start
+// is always kept.
+func parseFDEBody(data []byte, off, end int, secAddr, start uint64, cie cieInfo, bo binary.ByteOrder, ptrSize int) fdeInfo {
+	fde := fdeInfo{start: start, end: start, cie: cie}
+
+	// address_range uses the value format of the FDE encoding, never
+	// PC-relative.
+	n := encodedValueSize(cie.fdeEncoding, ptrSize)
+	if n == 0 || off+2*n > end {
+		return fde
+	}
+	off += n
+	rng, ok := readEncodedValue(data, off, cie.fdeEncoding&0x0f, bo, ptrSize)
+	if !ok {
+		return fde
+	}
+	fde.end = start + rng
+	off += n
+
+	if cie.hasAugData {
+		augLen, m := readULEB128(data, off)
+		if m < 0 {
+			return fde
+		}
+		off += m
+		// The LSDA pointer is the only FDE augmentation datum. It uses the
+		// same pointer encodings as initial_location.
+		if augLen > 0 && cie.lsdaEncoding != ehPeOmit {
+			if lsda, ok := decodeFDEInitialLocation(data, off, secAddr, cie.lsdaEncoding, bo, ptrSize); ok {
+				fde.lsda = lsda
+			}
+		}
+		off += int(augLen)
+	}
+	if off <= end {
+		fde.insns = data[off:end]
+	}
+	return fde
+}
+
+// encodedValueSize returns the size in bytes of a fixed-size value encoded
+// with enc, or 0 for variable-length and unsupported formats.
+func encodedValueSize(enc byte, ptrSize int) int {
+	switch enc & 0x0f {
+	case 0x00: // absptr
+		return ptrSize
+	case 0x02, 0x0a: // udata2, sdata2
+		return 2
+	case 0x03, 0x0b: // udata4, sdata4
+		return 4
+	case 0x04, 0x0c: // udata8, sdata8
+		return 8
+	}
+	return 0
+}
+
+// readEncodedValue reads a fixed-size value of the given format (the lower
+// nibble of a DW_EH_PE_* byte) at data[off], sign-extending signed formats.
+func readEncodedValue(data []byte, off int, format byte, bo binary.ByteOrder, ptrSize int) (uint64, bool) {
+	n := encodedValueSize(format, ptrSize)
+	if n == 0 || off+n > len(data) {
+		return 0, false
+	}
+	b := data[off : off+n]
+	switch format {
+	case 0x00:
+		if ptrSize == 8 {
+			return bo.Uint64(b), true
+		}
+		return uint64(bo.Uint32(b)), true
+	case 0x02:
+		return uint64(bo.Uint16(b)), true
+	case 0x03:
+		return uint64(bo.Uint32(b)), true
+	case 0x04, 0x0c:
+		return bo.Uint64(b), true
+	case 0x0a:
+		return uint64(int64(int16(bo.Uint16(b)))), true
+	case 0x0b:
+		return uint64(int64(int32(bo.Uint32(b)))), true
+	}
+	return 0, false
 }
 
 // parseCIE parses the body of a CIE record (the bytes after CIE_id, up to
 // end) and returns the extracted cieInfo. The default fdeEncoding is
 // ehPeAbsptr (absolute pointer) when no 'R' augmentation datum is present.
 func parseCIE(data []byte, off, end, ptrSize int) (cieInfo, error) {
-	info := cieInfo{fdeEncoding: ehPeAbsptr}
+	info := cieInfo{fdeEncoding: ehPeAbsptr, lsdaEncoding: ehPeOmit}
 
 	if off >= end {
 		return info, fmt.Errorf("empty CIE body")
@@ -217,18 +425,20 @@ func parseCIE(data []byte, off, end, ptrSize int) (cieInfo, error) {
 	off++ // skip null terminator
 
 	// Code alignment factor (ULEB128).
-	_, n := readULEB128(data, off)
+	codeAlign, n := readULEB128(data, off)
 	if n < 0 {
 		return info, fmt.Errorf("truncated code alignment factor")
 	}
 	off += n
+	info.codeAlign = codeAlign
 
 	// Data alignment factor (SLEB128).
-	_, n2 := readSLEB128(data, off)
+	dataAlign, n2 := readSLEB128(data, off)
 	if n2 < 0 {
 		return info, fmt.Errorf("truncated data alignment factor")
 	}
 	off += n2
+	info.dataAlign = dataAlign
 
 	// Return address register.
 	// DWARF2 encodes this as a single byte; DWARF3+ uses ULEB128.
@@ -242,8 +452,10 @@ func parseCIE(data []byte, off, end, ptrSize int) (cieInfo, error) {
 
 	// Augmentation data block — present only when augStr starts with 'z'.
 	if len(augStr) == 0 || augStr[0] != 'z' {
+		info.initialInsns = data[off:end]
 		return info, nil
 	}
+	info.hasAugData = true
 
 	augDataLen, n4 := readULEB128(data, off)
 	if n4 < 0 {
@@ -251,6 +463,9 @@ func parseCIE(data []byte, off, end, ptrSize int) (cieInfo, error) {
 	}
 	off += n4
 	augDataEnd := off + int(augDataLen)
+	if augDataEnd <= end {
+		info.initialInsns = data[augDataEnd:end]
+	}
 
 	// Process each augmentation character after 'z'.
 	for _, ch := range augStr[1:] {
@@ -259,7 +474,8 @@ func parseCIE(data []byte, off, end, ptrSize int) (cieInfo, error) {
 		}
 		switch ch {
 		case 'L':
-			// LSDA encoding byte — 1 byte, not needed.
+			// LSDA encoding byte — 1 byte, read back from FDEs.
+			info.lsdaEncoding = data[off]
 			off++
 		case 'P':
 			// Personality routine: 1-byte encoding + the pointer itself.
@@ -0,0 +1,271 @@
+package resurgo
+
+import (
+	"cmp"
+	"debug/elf"
+	"encoding/binary"
+	"slices"
+
+	"golang.org/x/arch/arm64/arm64asm"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+// Recognized function epilogue patterns.
+const (
+	// EpilogueReturn is a return with no recognized frame teardown before
+	// it.
+	EpilogueReturn EpilogueType = "ret"
+	// EpilogueLeave tears the x86 frame down with leave before returning.
+	EpilogueLeave EpilogueType = "leave"
+	// EpiloguePopFrame restores the x86 frame pointer with pop before
+	// returning.
+	EpiloguePopFrame EpilogueType = "pop-frame"
+	// EpilogueLDPFramePair restores the ARM64 frame record with ldp
+	// before returning.
+	EpilogueLDPFramePair EpilogueType = "ldp-frame-pair"
+)
+
+// EpilogueType represents the type of function epilogue.
+type EpilogueType string
+
+// Epilogue represents a detected function epilogue.
+type Epilogue struct {
+	// Address is the virtual address of the first instruction of the
+	// epilogue.
+	Address uint64 `json:"address"`
+	// Type is the matched epilogue pattern.
+	Type EpilogueType `json:"type"`
+	// Instructions is a human-readable representation of the matched
+	// epilogue instructions.
+	Instructions string `json:"instructions"`
+	// Size is the length in bytes of the matched instruction sequence,
+	// starting at Address; the return ends it.
+	Size uint64 `json:"size"`
+}
+
+// WithFunctionSizes sets whether the function candidates of x86 and ARM64
+// code carry the estimated End of their code (default false). Each
+// function is decoded from its start up to the next candidate or the end
+// of its code; the NOP, INT3 or zero padding before the next function is
+// not part of it.
+// When the decoding runs into bytes that are not instructions, e.g. a
+// literal pool or a jump table, the function ends with its last epilogue,
+// as found by DetectEpilogues. The estimate is only as accurate as the
+// candidates are complete: a missed function is counted in the one before
+// it.
+func WithFunctionSizes(enabled bool) Option {
+	return func(o *options) {
+		o.functionSizes = enabled
+	}
+}
+
+// DetectEpilogues analyzes raw machine code bytes and returns the function
+// epilogues found by a linear sweep, ordered by address: each return, along
+// with the frame teardown before it (leave or pop of the frame pointer on
+// x86, ldp of the frame record on ARM64). baseAddr is the virtual address
+// corresponding to the start of code. Only x86 and ARM64 code is
+// supported; other architectures yield no epilogues. opts may include
+// WithByteOrder or WithLimits.
+func DetectEpilogues(code []byte, baseAddr uint64, arch Arch, opts ...Option) ([]Epilogue, error) {
+	o := newOptions(opts...).withBudget()
+	if err := o.budget.codeSize(uint64(len(code))); err != nil {
+		return nil, err
+	}
+	code, err := o.littleEndianCode(code, arch)
+	if err != nil {
+		return nil, err
+	}
+	epilogues, _, err := o.scanEpilogues(code, baseAddr, arch, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.budget.results(len(epilogues)); err != nil {
+		return nil, err
+	}
+	return epilogues, nil
+}
+
+// estimateSizes sets the End of the candidates in the code of f.
+func (o *options) estimateSizes(candidates []FunctionCandidate, f *elf.File) error {
+	arch, err := elfArch(f)
+	if err != nil {
+		return err
+	}
+	regions, err := elfCodeSections(f, arch, o.budget)
+	if err != nil {
+		return err
+	}
+	return o.withByteOrder(elfCodeByteOrder(f, arch)).setEnds(candidates, regions, arch)
+}
+
+// setEnds sets the End of the candidates in regions of x86 or ARM64 code:
+// the end of the code decoded from their start up to the next candidate.
+func (o *options) setEnds(candidates []FunctionCandidate, regions []codeSection, arch Arch) error {
+	starts := make([]uint64, 0, len(candidates))
+	for _, c := range candidates {
+		starts = append(starts, c.Address)
+	}
+	slices.Sort(starts)
+	starts = slices.Compact(starts)
+
+	ends := make(map[uint64]uint64, len(starts))
+	for _, region := range regions {
+		arch := cmp.Or(region.arch, arch)
+		if arch != ArchAMD64 && arch != ArchX86 && arch != ArchARM64 {
+			continue
+		}
+		code, err := o.littleEndianCode(region.code, arch)
+		if err != nil {
+			return err
+		}
+		regionEnd := region.addr + uint64(len(code))
+		i, _ := slices.BinarySearch(starts, region.addr)
+		for ; i < len(starts) && starts[i] < regionEnd; i++ {
+			next := regionEnd
+			if i+1 < len(starts) {
+				next = min(next, starts[i+1])
+			}
+			start := starts[i]
+			_, end, err := o.scanEpilogues(code[start-region.addr:next-region.addr], start, arch, true)
+			if err != nil {
+				return err
+			}
+			if end > 0 {
+				ends[start] = start + uint64(end)
+			}
+		}
+	}
+	for i := range candidates {
+		if end, ok := ends[candidates[i].Address]; ok {
+			candidates[i].End = end
+		}
+	}
+	return nil
+}
+
+// scanEpilogues sweeps the little-endian code of arch mapped at baseAddr and
+// returns its epilogues and the offset of the end of its last instruction
+// other than padding. Undecodable bytes are skipped, unless function is
+// set: code is then the body of a single function, whose end is taken at
+// the last epilogue before them.
+func (o *options) scanEpilogues(code []byte, baseAddr uint64, arch Arch, function bool) (epilogues []Epilogue, end int, err error) {
+	// prev is the offset of the previous instruction, or -1.
+	prev := -1
+	for off := 0; off < len(code); {
+		if err := o.budget.step(off); err != nil {
+			return nil, 0, err
+		}
+		var (
+			length  int
+			padding bool
+			epi     *Epilogue
+			ok      bool
+		)
+		switch arch {
+		case ArchAMD64, ArchX86:
+			mode := 64
+			if arch == ArchX86 {
+				mode = 32
+			}
+			length, padding, epi, ok = epilogueAMD64(code, off, prev, baseAddr, mode)
+		case ArchARM64:
+			length, padding, epi, ok = epilogueARM64(code, off, prev, baseAddr)
+		default:
+			return nil, 0, nil
+		}
+		if !ok {
+			if function {
+				if n := len(epilogues); n > 0 {
+					last := epilogues[n-1]
+					end = int(last.Address - baseAddr + last.Size)
+				}
+				break
+			}
+			prev = -1
+			off++
+			continue
+		}
+		if epi != nil {
+			epilogues = append(epilogues, *epi)
+		}
+		prev = off
+		off += length
+		if !padding {
+			end = off
+		}
+	}
+	return epilogues, end, nil
+}
+
+// epilogueAMD64 decodes the x86 instruction at code[off] and returns its
+// length, whether it is padding and the epilogue it ends, if any. prev is
+// the offset of the previous instruction, or -1. ok is false when the
+// bytes do not decode.
+func epilogueAMD64(code []byte, off, prev int, baseAddr uint64, mode int) (length int, padding bool, epi *Epilogue, ok bool) {
+	if isENDBR(code, off) {
+		return 4, false, nil, true
+	}
+	if n := zeroPaddingAMD64(code, off); n > 0 {
+		return n, true, nil, true
+	}
+	inst, err := x86asm.Decode(code[off:], mode)
+	if err != nil {
+		return 0, false, nil, false
+	}
+	if isNOPLike(inst) || code[off] == x86INT3 {
+		return inst.Len, true, nil, true
+	}
+	if inst.Op != x86asm.RET {
+		return inst.Len, false, nil, true
+	}
+	epi = &Epilogue{Address: baseAddr + uint64(off), Type: EpilogueReturn, Instructions: "ret", Size: uint64(inst.Len)}
+	if prev < 0 {
+		return inst.Len, false, epi, true
+	}
+	frame, pop := x86asm.RBP, "pop rbp; ret"
+	if mode == 32 {
+		frame, pop = x86asm.EBP, "pop ebp; ret"
+	}
+	if before, err := x86asm.Decode(code[prev:], mode); err == nil {
+		switch {
+		case before.Op == x86asm.LEAVE:
+			epi.Type, epi.Instructions = EpilogueLeave, "leave; ret"
+		case before.Op == x86asm.POP && before.Args[0] == frame:
+			epi.Type, epi.Instructions = EpiloguePopFrame, pop
+		}
+	}
+	if epi.Type != EpilogueReturn {
+		epi.Address, epi.Size = baseAddr+uint64(prev), uint64(off-prev+inst.Len)
+	}
+	return inst.Len, false, epi, true
+}
+
+// epilogueARM64 is the ARM64 counterpart of epilogueAMD64.
+func epilogueARM64(code []byte, off, prev int, baseAddr uint64) (length int, padding bool, epi *Epilogue, ok bool) {
+	const insnLen = 4
+	if off+insnLen > len(code) {
+		return 0, false, nil, false
+	}
+	if binary.LittleEndian.Uint32(code[off:]) == 0 {
+		return insnLen, true, nil, true
+	}
+	inst, err := arm64asm.Decode(code[off : off+insnLen])
+	if err != nil {
+		return 0, false, nil, false
+	}
+	if inst.Op == arm64asm.NOP {
+		return insnLen, true, nil, true
+	}
+	if inst.Op != arm64asm.RET {
+		return insnLen, false, nil, true
+	}
+	epi = &Epilogue{Address: baseAddr + uint64(off), Type: EpilogueReturn, Instructions: "ret", Size: insnLen}
+	if prev >= 0 {
+		before, err := arm64asm.Decode(code[prev : prev+insnLen])
+		if err == nil && before.Op == arm64asm.LDP && before.Args[0] == arm64asm.X29 && before.Args[1] == arm64asm.X30 {
+			epi.Type, epi.Instructions = EpilogueLDPFramePair, "ldp x29, x30, [sp], #N; ret"
+			epi.Address, epi.Size = baseAddr+uint64(prev), 2*insnLen
+		}
+	}
+	return insnLen, false, epi, true
+}
@@ -0,0 +1,99 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+// sizedCode holds three x86-64 functions separated by padding.
+var sizedCode = []byte{
+	0x55,             // 0x1000: push rbp
+	0x48, 0x89, 0xe5, // 0x1001: mov rbp, rsp
+	0x5d, // 0x1004: pop rbp
+	0xc3, // 0x1005: ret
+	0x90, 0x90, 0x90, 0x90, 0x90, 0x90, 0x90, 0x90, 0x90, 0x90,
+	0x55,             // 0x1010: push rbp
+	0x48, 0x89, 0xe5, // 0x1011: mov rbp, rsp
+	0x48, 0x83, 0xec, 0x10, // 0x1014: sub rsp, 0x10
+	0xc9, // 0x1018: leave
+	0xc3, // 0x1019: ret
+	0xcc, 0xcc, 0xcc, 0xcc, 0xcc, 0xcc,
+	0x31, 0xc0, // 0x1020: xor eax, eax
+	0xc3, // 0x1022: ret
+}
+
+func TestDetectEpilogues(t *testing.T) {
+	tests := []struct {
+		name string
+		code []byte
+		arch resurgo.Arch
+		want []resurgo.Epilogue
+	}{{
+		name: "amd64",
+		code: sizedCode,
+		arch: resurgo.ArchAMD64,
+		want: []resurgo.Epilogue{
+			{Address: 0x1004, Type: resurgo.EpiloguePopFrame, Instructions: "pop rbp; ret", Size: 2},
+			{Address: 0x1018, Type: resurgo.EpilogueLeave, Instructions: "leave; ret", Size: 2},
+			{Address: 0x1022, Type: resurgo.EpilogueReturn, Instructions: "ret", Size: 1},
+		},
+	}, {
+		// push ebp; mov ebp, esp; pop ebp; ret 4
+		name: "386",
+		code: []byte{0x55, 0x89, 0xe5, 0x5d, 0xc2, 0x04, 0x00},
+		arch: resurgo.ArchX86,
+		want: []resurgo.Epilogue{
+			{Address: 0x1003, Type: resurgo.EpiloguePopFrame, Instructions: "pop ebp; ret", Size: 4},
+		},
+	}, {
+		name: "arm64",
+		code: []byte{
+			0xfd, 0x7b, 0xbf, 0xa9, // stp x29, x30, [sp, #-16]!
+			0xfd, 0x03, 0x00, 0x91, // mov x29, sp
+			0xfd, 0x7b, 0xc1, 0xa8, // ldp x29, x30, [sp], #16
+			0xc0, 0x03, 0x5f, 0xd6, // ret
+			0xe0, 0x03, 0x1f, 0x2a, // mov w0, wzr
+			0xc0, 0x03, 0x5f, 0xd6, // ret
+		},
+		arch: resurgo.ArchARM64,
+		want: []resurgo.Epilogue{
+			{Address: 0x1008, Type: resurgo.EpilogueLDPFramePair, Instructions: "ldp x29, x30, [sp], #N; ret", Size: 8},
+			{Address: 0x1014, Type: resurgo.EpilogueReturn, Instructions: "ret", Size: 4},
+		},
+	}, {
+		name: "unsupported",
+		code: []byte{0x13, 0x01, 0x01, 0xff},
+		arch: resurgo.ArchRISCV64,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resurgo.DetectEpilogues(tt.code, 0x1000, tt.arch)
+			if err != nil {
+				t.Fatalf("DetectEpilogues: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithFunctionSizes(t *testing.T) {
+	result, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(sizedCode), int64(len(sizedCode)),
+		resurgo.WithArch(resurgo.ArchAMD64), resurgo.WithBaseAddress(0x1000), resurgo.WithFunctionSizes(true))
+	if err != nil {
+		t.Fatalf("resurgo.DetectFunctionsFromReader: %v", err)
+	}
+	got := make(map[uint64]uint64)
+	for _, c := range result.Functions {
+		got[c.Address] = c.Size()
+	}
+	// The padding between functions belongs to none of them.
+	want := map[uint64]uint64{0x1000: 6, 0x1010: 10, 0x1020: 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got sizes %#x, want %#x", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"fmt"
+	"slices"
+)
+
+// DetectionExidx is assigned to function candidates read from the ARM
+// exception index table (.ARM.exidx) of a 32-bit ARM file, which maps the
+// start of every function built with unwinding enabled to its unwind
+// instructions and is kept in stripped binaries.
+const DetectionExidx DetectionType = "arm-exidx"
+
+const (
+	// shtARMExidx is the section type of .ARM.exidx and of the
+	// .ARM.exidx.* sections of objects built with -ffunction-sections.
+	shtARMExidx = elf.SectionType(0x70000001)
+	// exidxEntrySize is the size of an index table entry: the prel31
+	// offset of the function, then its unwind data or a reference to it.
+	exidxEntrySize = 8
+)
+
+// ExidxDetector is a CandidateDetector that emits the function start of
+// every entry of the .ARM.exidx sections of a 32-bit ARM file, including
+// those marked EXIDX_CANTUNWIND. The Thumb bit is cleared from the
+// addresses. In relocatable objects, whose index entries are resolved by
+// R_ARM_PREL31 relocations, addresses are offsets into the section the
+// entries refer to, as reported by DetectProloguesFromELF. Each candidate
+// carries DetectionExidx and ConfidenceHigh. Returns an empty slice (no
+// error) when the file has no index table. It is not part of the default
+// pipeline.
+func ExidxDetector(f *elf.File) ([]FunctionCandidate, error) {
+	starts, err := parseExidx(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse .ARM.exidx: %w", err)
+	}
+	candidates := make([]FunctionCandidate, 0, len(starts))
+	for _, va := range starts {
+		candidates = append(candidates, FunctionCandidate{
+			Address:       va,
+			DetectionType: DetectionExidx,
+			Confidence:    ConfidenceHigh,
+		})
+	}
+	return candidates, nil
+}
+
+// parseExidx returns the sorted, deduplicated function starts of the index
+// table entries of f that fall in an executable section.
+func parseExidx(f *elf.File) ([]uint64, error) {
+	if f.Machine != elf.EM_ARM {
+		return nil, nil
+	}
+	var syms []elf.Symbol
+	if f.Type == elf.ET_REL {
+		var err error
+		if syms, err = objectSymbols(f); err != nil {
+			return nil, err
+		}
+	}
+
+	exec := execRanges(f)
+	var starts []uint64
+	for i, sec := range f.Sections {
+		if sec.Type != shtARMExidx {
+			continue
+		}
+		data, err := sectionData(sec)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", sec.Name, err)
+		}
+		if f.Type == elf.ET_REL {
+			if int(sec.Link) >= len(f.Sections) {
+				continue
+			}
+			offs, err := exidxObjectStarts(f, i, data, syms)
+			if err != nil {
+				return nil, err
+			}
+			// Offsets are only meaningful within the section the entries
+			// refer to.
+			size := f.Sections[sec.Link].Size
+			for _, off := range offs {
+				if off < size {
+					starts = append(starts, off)
+				}
+			}
+			continue
+		}
+		for off := 0; off+exidxEntrySize <= len(data); off += exidxEntrySize {
+			word := f.ByteOrder.Uint32(data[off:])
+			if word&0x80000000 != 0 {
+				continue
+			}
+			va := (sec.Addr + uint64(off) + uint64(prel31(word))) &^ 1 & 0xffffffff
+			if inRanges(va, exec) {
+				starts = append(starts, va)
+			}
+		}
+	}
+	slices.Sort(starts)
+	return slices.Compact(starts), nil
+}
+
+// exidxObjectStarts returns the function offsets of the index table in
+// section idx of the relocatable object f, read into data, from the
+// R_ARM_PREL31 relocations of its first words: the value of the symbol
+// plus the addend stored in place, with the Thumb bit cleared.
+func exidxObjectStarts(f *elf.File, idx int, data []byte, syms []elf.Symbol) ([]uint64, error) {
+	const relSize = 8 // Elf32_Rel: r_offset, r_info
+	var offs []uint64
+	for _, rel := range f.Sections {
+		if rel.Type != elf.SHT_REL || int(rel.Info) != idx {
+			continue
+		}
+		relocs, err := sectionData(rel)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", rel.Name, err)
+		}
+		for off := 0; off+relSize <= len(relocs); off += relSize {
+			site := f.ByteOrder.Uint32(relocs[off:])
+			info := f.ByteOrder.Uint32(relocs[off+4:])
+			sym := elf.R_SYM32(info)
+			if elf.R_ARM(elf.R_TYPE32(info)) != elf.R_ARM_PREL31 || site%exidxEntrySize != 0 ||
+				int(site)+4 > len(data) || sym == 0 || int(sym) > len(syms) {
+				continue
+			}
+			// Symbols omits the null symbol at index 0.
+			addend := uint64(prel31(f.ByteOrder.Uint32(data[site:])))
+			offs = append(offs, (syms[sym-1].Value+addend)&^1&0xffffffff)
+		}
+	}
+	return offs, nil
+}
+
+// prel31 sign-extends the 31-bit place-relative offset held in the low bits
+// of word.
+func prel31(word uint32) int64 {
+	return int64(int32(word<<1) >> 1)
+}
@@ -0,0 +1,95 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+// exidxSource holds an ARM and a Thumb function with unwind entries, one
+// that cannot be unwound, and one without any entry.
+const exidxSource = `
+	.syntax unified
+	.text
+	.arm
+	.globl arm_fn
+	.type arm_fn, %function
+arm_fn:
+	.fnstart
+	.save {r4, lr}
+	push {r4, lr}
+	pop {r4, pc}
+	.fnend
+
+	.thumb
+	.thumb_func
+	.globl thumb_fn
+	.type thumb_fn, %function
+thumb_fn:
+	.fnstart
+	.save {r7, lr}
+	push {r7, lr}
+	pop {r7, pc}
+	.fnend
+
+	.thumb_func
+	.globl leaf
+	.type leaf, %function
+leaf:
+	.fnstart
+	.cantunwind
+	bx lr
+	.fnend
+
+	.thumb_func
+	.globl bare
+	.type bare, %function
+bare:
+	bx lr
+`
+
+func TestExidxDetector(t *testing.T) {
+	if _, err := exec.LookPath("llvm-mc"); err != nil {
+		t.Skip("llvm-mc not found in PATH")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "exidx.s")
+	if err := os.WriteFile(src, []byte(exidxSource), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	outPath := filepath.Join(dir, "exidx.o")
+	cmd := exec.Command("llvm-mc", "-triple=armv7-linux-gnueabihf", "-filetype=obj", "-o", outPath, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to assemble exidx.s: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	candidates, err := resurgo.ExidxDetector(f)
+	if err != nil {
+		t.Fatalf("resurgo.ExidxDetector: %v", err)
+	}
+	var got []uint64
+	for _, c := range candidates {
+		if c.DetectionType != resurgo.DetectionExidx || c.Confidence != resurgo.ConfidenceHigh {
+			t.Errorf("candidate 0x%x: unexpected %s/%s", c.Address, c.DetectionType, c.Confidence)
+		}
+		got = append(got, c.Address)
+	}
+	want := []uint64{
+		0x00, // arm_fn
+		0x08, // thumb_fn
+		0x0c, // leaf
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#x, want %#x", got, want)
+	}
+}
@@ -0,0 +1,269 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// DetectionExport is assigned to function candidates read from the dynamic
+// symbol table of an ELF file: the functions a shared library exports, or
+// an executable linked with -rdynamic, which must survive stripping for
+// the dynamic linker to resolve them.
+const DetectionExport DetectionType = "export"
+
+// dynSymbol is an entry of the dynamic symbol table.
+type dynSymbol struct {
+	name  string
+	info  byte
+	other byte
+	shndx elf.SectionIndex
+	value uint64
+}
+
+// ExportDetector is a CandidateDetector that emits the functions exported
+// by f: the defined STT_FUNC and STT_GNU_IFUNC symbols of its dynamic
+// symbol table with global or weak binding and default or protected
+// visibility, named after the symbol. The table is read from .dynsym or,
+// when the section headers are stripped, through the DT_SYMTAB, DT_STRTAB
+// and DT_GNU_HASH or DT_HASH dynamic tags. Each candidate carries
+// DetectionExport and ConfidenceHigh.
+//
+// AnchorDetector emits them too, so DetectFunctionsFromELF keeps them by
+// default; it need not be listed in WithDetectors.
+func ExportDetector(f *elf.File) ([]FunctionCandidate, error) {
+	if f.Type == elf.ET_REL {
+		return nil, nil
+	}
+	syms, err := dynamicSymbols(f)
+	if err != nil {
+		return nil, fmt.Errorf("read dynamic symbols: %w", err)
+	}
+	code := codeRanges(f)
+	names := make(map[uint64]string)
+	for _, s := range syms {
+		if typ := elf.ST_TYPE(s.info); typ != elf.STT_FUNC && typ != elf.STT_GNU_IFUNC {
+			continue
+		}
+		if bind := elf.ST_BIND(s.info); bind != elf.STB_GLOBAL && bind != elf.STB_WEAK {
+			continue
+		}
+		if vis := elf.ST_VISIBILITY(s.other); vis != elf.STV_DEFAULT && vis != elf.STV_PROTECTED {
+			continue
+		}
+		addr := s.value
+		if f.Machine == elf.EM_ARM {
+			addr &^= 1
+		}
+		if s.shndx == elf.SHN_UNDEF || !inRanges(addr, code) {
+			continue
+		}
+		if _, ok := names[addr]; !ok {
+			names[addr] = s.name
+		}
+	}
+
+	candidates := make([]FunctionCandidate, 0, len(names))
+	for addr, name := range names {
+		candidates = append(candidates, FunctionCandidate{
+			Address:       addr,
+			DetectionType: DetectionExport,
+			Confidence:    ConfidenceHigh,
+			Name:          name,
+		})
+	}
+	slices.SortFunc(candidates, func(a, b FunctionCandidate) int {
+		switch {
+		case a.Address < b.Address:
+			return -1
+		case a.Address > b.Address:
+			return 1
+		}
+		return 0
+	})
+	return candidates, nil
+}
+
+// dynamicSymbols returns the dynamic symbol table of f, from .dynsym, or
+// through the dynamic section when f has no section headers.
+func dynamicSymbols(f *elf.File) ([]dynSymbol, error) {
+	if f.SectionByType(elf.SHT_DYNSYM) != nil {
+		syms, err := f.DynamicSymbols()
+		if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+			return nil, err
+		}
+		out := make([]dynSymbol, len(syms))
+		for i, s := range syms {
+			out[i] = dynSymbol{name: s.Name, info: s.Info, other: s.Other, shndx: s.Section, value: s.Value}
+		}
+		return out, nil
+	}
+	if len(f.Sections) > 0 {
+		return nil, nil
+	}
+	// Tables the dynamic tags point at can be malformed in many ways; a
+	// table that cannot be read is taken for an absent one.
+	syms, err := segmentDynamicSymbols(f)
+	if err != nil {
+		return nil, nil
+	}
+	return syms, nil
+}
+
+// segmentDynamicSymbols reads the dynamic symbol table of f, whose section
+// headers are stripped, at the addresses of its dynamic tags. The number
+// of symbols is that of DT_HASH, or the highest index DT_GNU_HASH chains
+// reach.
+func segmentDynamicSymbols(f *elf.File) ([]dynSymbol, error) {
+	tags, err := dynamicTags(f)
+	if err != nil || tags[elf.DT_SYMTAB] == 0 || tags[elf.DT_STRTAB] == 0 {
+		return nil, err
+	}
+	symSize := uint64(16) // Elf32_Sym
+	if f.Class == elf.ELFCLASS64 {
+		symSize = 24 // Elf64_Sym
+	}
+	var count uint64
+	switch {
+	case tags[elf.DT_GNU_HASH] != 0:
+		if count, err = gnuHashSymbols(f, tags[elf.DT_GNU_HASH]); err != nil {
+			return nil, err
+		}
+	case tags[elf.DT_HASH] != 0:
+		hdr, err := readVirtual(f, tags[elf.DT_HASH], 8)
+		if err != nil {
+			return nil, err
+		}
+		count = uint64(f.ByteOrder.Uint32(hdr[4:])) // nchain
+	default:
+		return nil, nil
+	}
+	if count == 0 || count > 1<<24 {
+		return nil, nil
+	}
+	table, err := readVirtual(f, tags[elf.DT_SYMTAB], count*symSize)
+	if err != nil {
+		return nil, err
+	}
+	strtab, err := readVirtual(f, tags[elf.DT_STRTAB], tags[elf.DT_STRSZ])
+	if err != nil {
+		return nil, err
+	}
+
+	bo := f.ByteOrder
+	syms := make([]dynSymbol, 0, count)
+	for i := uint64(1); i < count; i++ { // index 0 is the null symbol
+		e := table[i*symSize:]
+		var s dynSymbol
+		var name uint32
+		if f.Class == elf.ELFCLASS64 {
+			name, s.info, s.other = bo.Uint32(e), e[4], e[5]
+			s.shndx, s.value = elf.SectionIndex(bo.Uint16(e[6:])), bo.Uint64(e[8:])
+		} else {
+			name, s.value = bo.Uint32(e), uint64(bo.Uint32(e[4:]))
+			s.info, s.other, s.shndx = e[12], e[13], elf.SectionIndex(bo.Uint16(e[14:]))
+		}
+		if int(name) < len(strtab) {
+			end := int(name)
+			for end < len(strtab) && strtab[end] != 0 {
+				end++
+			}
+			s.name = string(strtab[name:end])
+		}
+		syms = append(syms, s)
+	}
+	return syms, nil
+}
+
+// dynamicTags returns the values of the dynamic tags of f, read from its
+// PT_DYNAMIC segment, keyed by tag; the last value of a repeated tag wins.
+func dynamicTags(f *elf.File) (map[elf.DynTag]uint64, error) {
+	tags := make(map[elf.DynTag]uint64)
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_DYNAMIC {
+			continue
+		}
+		data, err := io.ReadAll(p.Open())
+		if err != nil {
+			return nil, fmt.Errorf("read PT_DYNAMIC: %w", err)
+		}
+		entSize := 8
+		if f.Class == elf.ELFCLASS64 {
+			entSize = 16
+		}
+		for off := 0; off+entSize <= len(data); off += entSize {
+			var tag, val uint64
+			if entSize == 16 {
+				tag, val = f.ByteOrder.Uint64(data[off:]), f.ByteOrder.Uint64(data[off+8:])
+			} else {
+				tag, val = uint64(f.ByteOrder.Uint32(data[off:])), uint64(f.ByteOrder.Uint32(data[off+4:]))
+			}
+			if elf.DynTag(tag) == elf.DT_NULL {
+				break
+			}
+			tags[elf.DynTag(tag)] = val
+		}
+	}
+	return tags, nil
+}
+
+// gnuHashSymbols returns the number of dynamic symbols of f from the GNU
+// hash table at addr: one past the last symbol of the longest reaching
+// chain, or the index of the first hashed symbol if no bucket is used.
+func gnuHashSymbols(f *elf.File, addr uint64) (uint64, error) {
+	hdr, err := readVirtual(f, addr, 16)
+	if err != nil {
+		return 0, err
+	}
+	bo := f.ByteOrder
+	nbuckets, symOffset, bloomSize := uint64(bo.Uint32(hdr)), uint64(bo.Uint32(hdr[4:])), uint64(bo.Uint32(hdr[8:]))
+	wordSize := uint64(4)
+	if f.Class == elf.ELFCLASS64 {
+		wordSize = 8
+	}
+	bucketsAddr := addr + 16 + bloomSize*wordSize
+	buckets, err := readVirtual(f, bucketsAddr, nbuckets*4)
+	if err != nil {
+		return 0, err
+	}
+	var last uint64
+	for i := uint64(0); i < nbuckets; i++ {
+		last = max(last, uint64(bo.Uint32(buckets[4*i:])))
+	}
+	if last < symOffset {
+		return symOffset, nil
+	}
+	chainAddr := bucketsAddr + nbuckets*4
+	for ; ; last++ {
+		word, err := readVirtual(f, chainAddr+(last-symOffset)*4, 4)
+		if err != nil {
+			return 0, err
+		}
+		if bo.Uint32(word)&1 != 0 {
+			return last + 1, nil
+		}
+	}
+}
+
+// readVirtual reads size bytes at the virtual address addr of f from the
+// PT_LOAD segment holding them.
+func readVirtual(f *elf.File, addr, size uint64) ([]byte, error) {
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_LOAD || addr < p.Vaddr || addr-p.Vaddr > p.Filesz || size > p.Filesz-(addr-p.Vaddr) {
+			continue
+		}
+		// The segment may claim more than the file holds; read what is there
+		// rather than allocating size bytes up front.
+		data, err := io.ReadAll(io.NewSectionReader(p, int64(addr-p.Vaddr), int64(size)))
+		if err != nil {
+			return nil, fmt.Errorf("read %#x: %w", addr, err)
+		}
+		if uint64(len(data)) != size {
+			return nil, fmt.Errorf("read %#x: truncated", addr)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("address %#x+%d not in a loaded segment", addr, size)
+}
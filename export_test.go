@@ -0,0 +1,117 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestExportDetector(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "lib.c")
+	const code = `
+__attribute__((noinline)) static int internal(int x) { return x * 5; }
+__attribute__((visibility("hidden"))) int hidden(int x) { return internal(x) + 1; }
+int exported(int x) { return hidden(x) * 2; }
+__attribute__((weak)) int overridable(int x) { return x - 1; }
+int exported_data = 42;
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	libPath := filepath.Join(dir, "lib.so")
+	cmd := exec.Command("gcc", "-O1", "-shared", "-fPIC", "-Wl,--hash-style=gnu", "-o", libPath, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile lib.c: %v\n%s", err, out)
+	}
+	raw, err := os.ReadFile(libPath)
+	if err != nil {
+		t.Fatalf("failed to read library: %v", err)
+	}
+	f, err := elf.NewFile(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+
+	syms, err := f.DynamicSymbols()
+	if err != nil {
+		t.Fatalf("failed to read dynamic symbols: %v", err)
+	}
+	want := make(map[uint64]string)
+	for _, s := range syms {
+		if s.Name == "exported" || s.Name == "overridable" {
+			want[s.Value] = s.Name
+		}
+	}
+	if len(want) != 2 {
+		t.Fatalf("expected exported and overridable dynamic symbols, got %v", want)
+	}
+
+	// Section headers stripped, as by sstrip: the table is found through
+	// the dynamic segment.
+	if f.Class != elf.ELFCLASS64 || f.ByteOrder != binary.LittleEndian {
+		t.Skip("header patching assumes a little-endian ELF64 file")
+	}
+	stripped := bytes.Clone(raw)
+	binary.LittleEndian.PutUint64(stripped[0x28:], 0) // e_shoff
+	binary.LittleEndian.PutUint16(stripped[0x3c:], 0) // e_shnum
+	binary.LittleEndian.PutUint16(stripped[0x3e:], 0) // e_shstrndx
+	sf, err := elf.NewFile(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("failed to open stripped ELF: %v", err)
+	}
+	if len(sf.Sections) != 0 {
+		t.Fatalf("stripped ELF still has %d sections", len(sf.Sections))
+	}
+
+	for _, tt := range []struct {
+		name string
+		f    *elf.File
+	}{{"sections", f}, {"no-sections", sf}} {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, err := resurgo.ExportDetector(tt.f)
+			if err != nil {
+				t.Fatalf("resurgo.ExportDetector: %v", err)
+			}
+			got := make(map[uint64]string)
+			for _, c := range candidates {
+				if c.DetectionType != resurgo.DetectionExport || c.Confidence != resurgo.ConfidenceHigh {
+					t.Errorf("candidate 0x%x: unexpected %s/%s", c.Address, c.DetectionType, c.Confidence)
+				}
+				got[c.Address] = c.Name
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+
+	// Exports anchor DetectFunctionsFromELF: they survive any filter.
+	dropAll := func([]resurgo.FunctionCandidate, *elf.File) ([]resurgo.FunctionCandidate, error) {
+		return nil, nil
+	}
+	candidates, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithFilters(dropAll))
+	if err != nil {
+		t.Fatalf("resurgo.DetectFunctionsFromELF: %v", err)
+	}
+	got := make(map[uint64]string)
+	for _, c := range candidates {
+		got[c.Address] = c.Name
+	}
+	for addr, name := range want {
+		if got[addr] != name {
+			t.Errorf("%s at 0x%x: got %q", name, addr, got[addr])
+		}
+	}
+}
@@ -0,0 +1,769 @@
+package resurgo
+
+import (
+	"bytes"
+	"cmp"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Supported container formats.
+const (
+	FormatELF     Format = "elf"
+	FormatPE      Format = "pe"
+	FormatMachO   Format = "macho"
+	FormatArchive Format = "archive"
+	FormatRaw     Format = "raw"
+)
+
+// Format identifies the container format of a binary.
+type Format string
+
+// AnalysisResult is the outcome of DetectFunctionsFromFile and
+// DetectFunctionsFromReader.
+type AnalysisResult struct {
+	// Name is the name of an archive member, the architecture of a slice
+	// of a universal Mach-O binary or the name of a decompressed file; it
+	// is empty for the top-level result.
+	Name string `json:"name,omitempty"`
+	// Format is the container format that was detected.
+	Format Format `json:"format"`
+	// Arch is the architecture of the code; it is empty for archives,
+	// universal binaries and compressed files, whose members carry their
+	// own.
+	Arch Arch `json:"arch,omitempty"`
+	// BuildID is the GNU build ID of an ELF file, in hexadecimal; it is
+	// empty for other formats and for files without one.
+	BuildID string `json:"build_id,omitempty"`
+	// Functions holds the detected function candidates, ordered by address.
+	Functions []FunctionCandidate `json:"functions,omitempty"`
+	// Padding holds the padding regions between the functions, ordered by
+	// address, when WithPaddingRegions is enabled.
+	Padding []PaddingRegion `json:"padding,omitempty"`
+	// Members holds the results of the members of an archive, the slices
+	// of a universal Mach-O binary or the content of a compressed file.
+	Members []AnalysisResult `json:"members,omitempty"`
+}
+
+// WithArch sets the architecture of raw input, which has no header to read
+// it from, and of the regions passed to DetectProloguesFromRegions without
+// one. It overrides the architecture recorded in minidumps; other formats
+// that record their architecture ignore it.
+func WithArch(arch Arch) Option {
+	return func(o *options) {
+		o.arch = arch
+	}
+}
+
+// WithBaseAddress sets the virtual address at which raw input is mapped
+// (default 0). Formats that record their load addresses ignore it.
+func WithBaseAddress(addr uint64) Option {
+	return func(o *options) {
+		o.baseAddr = addr
+	}
+}
+
+// DetectFunctionsFromFile opens the binary at path and detects its functions
+// with DetectFunctionsFromReader.
+func DetectFunctionsFromFile(path string, opts ...Option) (*AnalysisResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return DetectFunctionsFromReader(f, info.Size(), opts...)
+}
+
+// DetectFunctionsFromReader detects the functions of the size-byte binary
+// read from r, whatever its format. The format is sniffed from the leading
+// magic bytes:
+//
+//   - ELF files run the full DetectFunctionsFromELF pipeline.
+//   - PE and Mach-O files run the disassembly-based detection on each of
+//     their executable sections; the detectors and filters of the ELF
+//     pipeline do not apply to them.
+//   - Universal Mach-O binaries report one member per slice, and ar(1)
+//     archives one member per object.
+//   - Minidumps run the disassembly-based detection on their executable
+//     memory regions.
+//   - Linux x86 boot images (bzImage) and gzip or bzip2 streams report one
+//     member: the decompressed vmlinux, or the decompressed content.
+//   - Tar and zip archives report one member per ELF file, as
+//     DetectFunctionsFromTar and DetectFunctionsFromZip.
+//   - Anything else is analyzed as raw code, which requires WithArch and
+//     optionally WithBaseAddress. Archive members in no known format are
+//     skipped unless WithArch is set.
+//
+// The architecture is read from the file header.
+func DetectFunctionsFromReader(r io.ReaderAt, size int64, opts ...Option) (*AnalysisResult, error) {
+	magic := make([]byte, 8)
+	n, err := r.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read magic: %w", err)
+	}
+	magic = magic[:n]
+
+	switch {
+	case bytes.HasPrefix(magic, []byte(elf.ELFMAG)):
+		return detectELF(r, opts...)
+	case bytes.HasPrefix(magic, []byte(minidumpMagic)):
+		return detectMinidump(r, size, opts...)
+	case isBzImage(r):
+		return detectBzImage(r, opts...)
+	case isCompressed(magic):
+		return detectCompressed(r, size, opts...)
+	case bytes.HasPrefix(magic, []byte("MZ")):
+		return detectPE(r, opts...)
+	case bytes.HasPrefix(magic, []byte("\xca\xfe\xba\xbe")):
+		return detectFatMachO(r, opts...)
+	case isMachOMagic(magic):
+		f, err := macho.NewFile(r)
+		if err != nil {
+			return nil, fmt.Errorf("parse Mach-O: %w", err)
+		}
+		return detectMachO(f, opts...)
+	case bytes.HasPrefix(magic, []byte(arMagic)):
+		return detectArchive(r, size, opts...)
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		return DetectFunctionsFromZip(r, size, opts...)
+	case isTar(r):
+		return DetectFunctionsFromTar(io.NewSectionReader(r, 0, size), opts...)
+	}
+	return detectRaw(r, size, opts...)
+}
+
+// isMachOMagic reports whether magic starts with the magic number of a
+// 32- or 64-bit Mach-O file of either byte order.
+func isMachOMagic(magic []byte) bool {
+	for _, m := range []string{"\xfe\xed\xfa\xce", "\xce\xfa\xed\xfe", "\xfe\xed\xfa\xcf", "\xcf\xfa\xed\xfe"} {
+		if bytes.HasPrefix(magic, []byte(m)) {
+			return true
+		}
+	}
+	return false
+}
+
+func detectELF(r io.ReaderAt, opts ...Option) (*AnalysisResult, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("parse ELF: %w", err)
+	}
+	arch, err := elfArch(f)
+	if err != nil {
+		return nil, err
+	}
+	// A malformed build ID note does not prevent the analysis.
+	buildID, _ := BuildID(f)
+	candidates, err := DetectFunctionsFromELF(f, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var padding []PaddingRegion
+	if newOptions(opts...).paddingRegions {
+		if padding, err = DetectPaddingFromELF(f, candidates, opts...); err != nil {
+			return nil, err
+		}
+	}
+	return &AnalysisResult{Format: FormatELF, Arch: arch, BuildID: buildID, Functions: candidates, Padding: padding}, nil
+}
+
+func detectPE(r io.ReaderAt, opts ...Option) (*AnalysisResult, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("parse PE: %w", err)
+	}
+	arch, sections, err := peCodeSections(f)
+	if err != nil {
+		return nil, err
+	}
+	o := newPEOptions(opts...)
+	if err := o.checkPackedPE(f, sections); err != nil {
+		return nil, err
+	}
+	known, err := pdataCandidates(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse .pdata: %w", err)
+	}
+	candidates, err := o.detectSections(sections, arch, known)
+	if err != nil {
+		return nil, err
+	}
+	padding, err := o.padding(candidates, sections, arch)
+	if err != nil {
+		return nil, err
+	}
+	return &AnalysisResult{Format: FormatPE, Arch: arch, Functions: candidates, Padding: padding}, nil
+}
+
+// DetectProloguesFromPE returns the function prologues of the executable
+// sections of f, at their virtual addresses: the image base plus the
+// relative address of each section. The architecture is read from the file
+// header. opts are those of DetectPrologues; x86-64 code is matched under
+// ABIWin64 unless WithABI says otherwise. Prologues are ordered as by
+// DetectPrologues.
+func DetectProloguesFromPE(f *pe.File, opts ...Option) ([]Prologue, error) {
+	arch, sections, err := peCodeSections(f)
+	if err != nil {
+		return nil, err
+	}
+	o := newPEOptions(opts...)
+	if err := o.checkPackedPE(f, sections); err != nil {
+		return nil, err
+	}
+	return o.detectSectionPrologues(sections, arch)
+}
+
+// detectSectionPrologues runs DetectPrologues on each section, decoded as
+// its own architecture if set, or as arch. One budget is shared across the
+// sections, and the prologues are ordered as DetectPrologues does.
+func (o *options) detectSectionPrologues(sections []codeSection, arch Arch) ([]Prologue, error) {
+	o = o.withBudget()
+	var prologues []Prologue
+	for _, sec := range sections {
+		found, err := o.detectPrologues(sec.code, sec.addr, cmp.Or(sec.arch, arch))
+		if err != nil {
+			return nil, err
+		}
+		prologues = append(prologues, found...)
+		if err := o.budget.results(len(prologues)); err != nil {
+			return nil, err
+		}
+	}
+	slices.SortStableFunc(prologues, comparePrologues())
+	return prologues, nil
+}
+
+// DetectProloguesFromELF returns the function prologues of the executable
+// sections of f, each tagged with the name of its section. Sections of
+// linked files are scanned at their virtual addresses. Sections of
+// relocatable objects (ET_REL), e.g. the .text.* sections of code built with
+// -ffunction-sections or the .init.text and .exit.text sections of Linux
+// kernel modules, are not laid out yet: each is scanned on its own and its
+// prologues carry offsets relative to the section start. In x86-64 objects,
+// the ftrace calls to __fentry__ are also reported, as PrologueFentry. The
+// .altinstr_replacement section of kernel code is skipped. Linked files
+// whose section header table is missing or describes no code have their
+// executable PT_LOAD segments scanned instead, and their prologues carry no
+// section name. Prologues of linked files carry the permissions of their
+// segment, which flag code mapped writable. The architecture is read from
+// the file header. opts are those of DetectPrologues; the byte order of the
+// code is the one of the file, and WithLoadBias relocates the prologues of
+// PIE executables and shared libraries to their runtime addresses.
+// Prologues are ordered as by DetectPrologues, by section first in
+// relocatable objects.
+func DetectProloguesFromELF(f *elf.File, opts ...Option) ([]Prologue, error) {
+	return detectProloguesELF(f, isCodeSection, opts...)
+}
+
+// DetectProloguesFromELFSection is DetectProloguesFromELF restricted to the
+// section of f named name, e.g. the .text.hot partition of a binary laid
+// out by BOLT or Propeller; the other sections are not read. If several
+// sections share the name, as may happen in relocatable objects, the first
+// is scanned. It is an error if the section does not exist or holds no
+// code.
+func DetectProloguesFromELFSection(f *elf.File, name string, opts ...Option) ([]Prologue, error) {
+	target := f.Section(name)
+	if target == nil {
+		return nil, fmt.Errorf("no %s section found", name)
+	}
+	if target.Flags&elf.SHF_EXECINSTR == 0 || target.Type == elf.SHT_NOBITS {
+		return nil, fmt.Errorf("section %s holds no code", name)
+	}
+	return detectProloguesELF(f, func(sec *elf.Section) bool { return sec == target }, opts...)
+}
+
+// detectProloguesELF is DetectProloguesFromELF for the sections of f
+// selected by scan.
+func detectProloguesELF(f *elf.File, scan func(*elf.Section) bool, opts ...Option) ([]Prologue, error) {
+	arch, err := elfArch(f)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts...).forFile(f).withBudget().withByteOrder(elfCodeByteOrder(f, arch))
+	if err := o.checkPackedELF(f); err != nil {
+		return nil, err
+	}
+	if f.Type != elf.ET_REL && (arch == ArchPPC64LE || arch == ArchPPC64) {
+		// Symbol values are section-relative in relocatable objects, so
+		// entry points can only be paired in linked files.
+		entries, err := ppc64LocalEntries(f)
+		if err != nil {
+			return nil, err
+		}
+		o = o.withEntryPoints(entries)
+	}
+	var syms []elf.Symbol
+	fentry := f.Type == elf.ET_REL && arch == ArchAMD64 && o.prologueEnabled(PrologueFentry)
+	if fentry {
+		if syms, err = objectSymbols(f); err != nil {
+			return nil, err
+		}
+	}
+
+	var prologues []Prologue
+	for _, sec := range f.Sections {
+		plt := f.Type != elf.ET_REL && isPLTSection(sec)
+		if !scan(sec) || plt && !o.pltStubs {
+			continue
+		}
+		code, err := sec.Data()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", sec.Name, err)
+		}
+		regions := []codeSection{{code: code, addr: sec.Addr, arch: arch}}
+		if arch == ArchARM || arch == ArchARM64 {
+			regions = armCodeSections(f, sec, code)
+		}
+		var found []Prologue
+		for _, region := range regions {
+			p, err := o.detectPrologues(region.code, region.addr, region.arch)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", sec.Name, err)
+			}
+			found = append(found, p...)
+		}
+		if fentry {
+			p, err := fentryPrologues(f, sec, code, syms)
+			if err != nil {
+				return nil, err
+			}
+			found = append(found, p...)
+		}
+		slices.SortStableFunc(found, comparePrologues())
+		for i := range found {
+			found[i].Section = sec.Name
+			if plt {
+				found[i].Kind = KindPLTStub
+			}
+		}
+		prologues = append(prologues, found...)
+		if err := o.budget.results(len(prologues)); err != nil {
+			return nil, err
+		}
+	}
+	if segments := execSegments(f); len(segments) > 0 {
+		// The section header table is missing or describes no code: the
+		// executable segments are scanned instead, with no section name.
+		code, err := elfCodeSections(f, arch, o.budget)
+		if err != nil {
+			return nil, err
+		}
+		if prologues, err = o.detectSectionPrologues(code, arch); err != nil {
+			return nil, err
+		}
+	} else if f.Type != elf.ET_REL {
+		slices.SortStableFunc(prologues, comparePrologues())
+	}
+	if err := o.labelIfuncPrologues(prologues, f); err != nil {
+		return nil, err
+	}
+	for i, p := range prologues {
+		prologues[i].Permissions = segmentPermissions(f, p.Address)
+	}
+	o.rebasePrologues(prologues, f)
+	return prologues, nil
+}
+
+// newPEOptions is newOptions for PE files, whose x86-64 code follows the
+// Microsoft x64 calling convention unless WithABI says otherwise.
+func newPEOptions(opts ...Option) *options {
+	o := newOptions(opts...)
+	if o.abi == "" {
+		o.abi = ABIWin64
+	}
+	return o
+}
+
+// peCodeSections returns the architecture of f and its executable sections,
+// trimmed to their virtual size and located at their virtual addresses.
+func peCodeSections(f *pe.File) (Arch, []codeSection, error) {
+	var arch Arch
+	switch f.Machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		arch = ArchAMD64
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		arch = ArchARM64
+	case pe.IMAGE_FILE_MACHINE_I386:
+		arch = ArchX86
+	case pe.IMAGE_FILE_MACHINE_RISCV64:
+		arch = ArchRISCV64
+	case pe.IMAGE_FILE_MACHINE_ARM:
+		arch = ArchARM
+	case pe.IMAGE_FILE_MACHINE_ARMNT:
+		arch = ArchThumb
+	default:
+		return "", nil, fmt.Errorf("unsupported PE machine: %#x", f.Machine)
+	}
+	var imageBase uint64
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader64:
+		imageBase = h.ImageBase
+	case *pe.OptionalHeader32:
+		imageBase = uint64(h.ImageBase)
+	}
+
+	var sections []codeSection
+	for _, sec := range f.Sections {
+		if sec.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE == 0 {
+			continue
+		}
+		code, err := sec.Data()
+		if err != nil {
+			return "", nil, fmt.Errorf("read %s: %w", sec.Name, err)
+		}
+		// The raw data is padded to the file alignment.
+		if sec.VirtualSize > 0 && int(sec.VirtualSize) < len(code) {
+			code = code[:sec.VirtualSize]
+		}
+		sections = append(sections, codeSection{code: code, addr: imageBase + uint64(sec.VirtualAddress)})
+	}
+	return arch, sections, nil
+}
+
+func detectMachO(f *macho.File, opts ...Option) (*AnalysisResult, error) {
+	arch, sections, err := machOCodeSections(f)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts...)
+	if err := o.checkPackedMachO(f, sections); err != nil {
+		return nil, err
+	}
+	known, err := functionStartsCandidates(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse LC_FUNCTION_STARTS: %w", err)
+	}
+	metadata, err := MachOMetadataDetector(f)
+	if err != nil {
+		return nil, err
+	}
+	known = append(known, metadata...)
+	candidates, err := o.detectSections(sections, arch, known)
+	if err != nil {
+		return nil, err
+	}
+	padding, err := o.padding(candidates, sections, arch)
+	if err != nil {
+		return nil, err
+	}
+	return &AnalysisResult{Format: FormatMachO, Arch: arch, Functions: candidates, Padding: padding}, nil
+}
+
+// detectProloguesMachO returns the function prologues of the executable
+// sections of f, at their virtual addresses.
+func detectProloguesMachO(f *macho.File, opts ...Option) ([]Prologue, error) {
+	arch, sections, err := machOCodeSections(f)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts...)
+	if err := o.checkPackedMachO(f, sections); err != nil {
+		return nil, err
+	}
+	return o.detectSectionPrologues(sections, arch)
+}
+
+// machOCodeSections returns the architecture of f and its sections holding
+// instructions, located at their virtual addresses.
+func machOCodeSections(f *macho.File) (Arch, []codeSection, error) {
+	const (
+		sAttrPureInstructions = 0x80000000
+		sAttrSomeInstructions = 0x400
+	)
+	var arch Arch
+	switch f.Cpu {
+	case macho.CpuAmd64:
+		arch = ArchAMD64
+	case macho.CpuArm64:
+		arch = ArchARM64
+	case macho.Cpu386:
+		arch = ArchX86
+	default:
+		return "", nil, fmt.Errorf("unsupported Mach-O CPU: %s", f.Cpu)
+	}
+
+	var sections []codeSection
+	for _, sec := range f.Sections {
+		if sec.Flags&(sAttrPureInstructions|sAttrSomeInstructions) == 0 {
+			continue
+		}
+		code, err := sec.Data()
+		if err != nil {
+			return "", nil, fmt.Errorf("read %s: %w", sec.Name, err)
+		}
+		sections = append(sections, codeSection{code: code, addr: sec.Addr})
+	}
+	return arch, sections, nil
+}
+
+func detectFatMachO(r io.ReaderAt, opts ...Option) (*AnalysisResult, error) {
+	ff, err := macho.NewFatFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("parse universal Mach-O: %w", err)
+	}
+	result := &AnalysisResult{Format: FormatMachO}
+	for _, slice := range ff.Arches {
+		member, err := detectMachO(slice.File, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("%s slice: %w", slice.Cpu, err)
+		}
+		member.Name = string(member.Arch)
+		result.Members = append(result.Members, *member)
+	}
+	return result, nil
+}
+
+func detectRaw(r io.ReaderAt, size int64, opts ...Option) (*AnalysisResult, error) {
+	o := newOptions(opts...)
+	if o.arch == "" {
+		return nil, errNoArch
+	}
+	code, err := io.ReadAll(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("read raw input: %w", err)
+	}
+	sections := []codeSection{{code: code, addr: o.baseAddr}}
+	candidates, err := o.detectSections(sections, o.arch, nil)
+	if err != nil {
+		return nil, err
+	}
+	padding, err := o.padding(candidates, sections, o.arch)
+	if err != nil {
+		return nil, err
+	}
+	return &AnalysisResult{Format: FormatRaw, Arch: o.arch, Functions: candidates, Padding: padding}, nil
+}
+
+// errNoArch is returned for raw input when WithArch is not set.
+var errNoArch = errors.New("raw input: architecture unknown, set it with WithArch")
+
+// codeSection is a section of executable code and its virtual address.
+type codeSection struct {
+	code []byte
+	addr uint64
+	// arch is the architecture of the code when it differs from the one of
+	// the file, e.g. for Thumb regions of ARM code.
+	arch Arch
+}
+
+// regionAt returns the section of sections holding addr.
+func regionAt(sections []codeSection, addr uint64) (codeSection, bool) {
+	for _, sec := range sections {
+		if addr >= sec.addr && addr-sec.addr < uint64(len(sec.code)) {
+			return sec, true
+		}
+	}
+	return codeSection{}, false
+}
+
+// detectSections runs the disassembly-based detection on each section and
+// merges the candidates, sharing one budget across the sections. known holds
+// the function starts recorded by the file format, e.g. in .pdata or
+// LC_FUNCTION_STARTS: the candidates they confirm are upgraded to
+// ConfidenceHigh and the others are added.
+func (o *options) detectSections(sections []codeSection, arch Arch, known []FunctionCandidate) ([]FunctionCandidate, error) {
+	o = o.withBudget()
+	var candidates []FunctionCandidate
+	for _, sec := range sections {
+		if err := o.budget.codeSize(uint64(len(sec.code))); err != nil {
+			return nil, err
+		}
+		found, err := o.detectCode(sec.code, sec.addr, arch)
+		if err != nil {
+			return nil, err
+		}
+		candidates = mergeCandidates(candidates, found)
+		if err := o.budget.results(len(candidates)); err != nil {
+			return nil, err
+		}
+	}
+	if len(known) > 0 {
+		candidates = confirmCandidates(candidates, known)
+		if err := o.budget.results(len(candidates)); err != nil {
+			return nil, err
+		}
+	}
+	if o.calibration != nil {
+		candidates = o.calibration.apply(candidates)
+	}
+	if o.minConfidence != "" {
+		candidates = slices.DeleteFunc(candidates, func(c FunctionCandidate) bool {
+			return confidenceRank(c.Confidence) < confidenceRank(o.minConfidence)
+		})
+	}
+	if o.functionSizes {
+		if err := o.setEnds(candidates, sections, arch); err != nil {
+			return nil, err
+		}
+	}
+	o.scoreCandidates(candidates)
+	return candidates, nil
+}
+
+// confirmCandidates merges the function starts in known into candidates:
+// candidates at a known start are upgraded to ConfidenceHigh and keep their
+// detection type, and known starts without a candidate are added.
+func confirmCandidates(candidates, known []FunctionCandidate) []FunctionCandidate {
+	starts := make(map[uint64]struct{}, len(known))
+	for _, c := range known {
+		starts[c.Address] = struct{}{}
+	}
+	for i := range candidates {
+		if _, ok := starts[candidates[i].Address]; ok {
+			candidates[i].Confidence = ConfidenceHigh
+		}
+	}
+	return mergeCandidates(candidates, known)
+}
+
+// arMagic is the global header of an ar(1) archive.
+const arMagic = "!<arch>\n"
+
+// detectArchive analyzes each object of the ar(1) archive read from r.
+func detectArchive(r io.ReaderAt, size int64, opts ...Option) (*AnalysisResult, error) {
+	result := &AnalysisResult{Format: FormatArchive}
+	err := forEachArchiveMember(r, size, func(name string, data *io.SectionReader) error {
+		member, err := DetectFunctionsFromReader(data, data.Size(), opts...)
+		if errors.Is(err, errNoArch) {
+			// Not an object file, e.g. the export data of a Go package.
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("archive member %s: %w", name, err)
+		}
+		member.Name = name
+		result.Members = append(result.Members, *member)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// MemberPrologues holds the function prologues of one member of an archive.
+type MemberPrologues struct {
+	// Name is the name of the archive member, e.g. "foo.o".
+	Name string `json:"name"`
+	// Prologues holds the prologues detected in the member.
+	Prologues []Prologue `json:"prologues,omitempty"`
+}
+
+// DetectProloguesFromArchive returns the function prologues of each object
+// of the size-byte ar(1) archive read from r, e.g. a static library, in
+// archive order. ELF objects are scanned with DetectProloguesFromELF, so
+// addresses are offsets into the section named by each prologue, and
+// Mach-O objects at the addresses of their sections. Members in other
+// formats, e.g. the export data of Go packages, are skipped. opts are those
+// of DetectPrologues.
+func DetectProloguesFromArchive(r io.ReaderAt, size int64, opts ...Option) ([]MemberPrologues, error) {
+	magic := make([]byte, len(arMagic))
+	if _, err := r.ReadAt(magic, 0); err != nil || string(magic) != arMagic {
+		return nil, fmt.Errorf("not an ar archive")
+	}
+	var members []MemberPrologues
+	err := forEachArchiveMember(r, size, func(name string, data *io.SectionReader) error {
+		magic := make([]byte, 4)
+		n, _ := data.ReadAt(magic, 0)
+		magic = magic[:n]
+
+		var prologues []Prologue
+		var err error
+		switch {
+		case bytes.HasPrefix(magic, []byte(elf.ELFMAG)):
+			var f *elf.File
+			if f, err = elf.NewFile(data); err != nil {
+				return fmt.Errorf("archive member %s: parse ELF: %w", name, err)
+			}
+			prologues, err = DetectProloguesFromELF(f, opts...)
+		case isMachOMagic(magic):
+			var f *macho.File
+			if f, err = macho.NewFile(data); err != nil {
+				return fmt.Errorf("archive member %s: parse Mach-O: %w", name, err)
+			}
+			prologues, err = detectProloguesMachO(f, opts...)
+		default:
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("archive member %s: %w", name, err)
+		}
+		members = append(members, MemberPrologues{Name: name, Prologues: prologues})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// forEachArchiveMember calls fn with the name and data of each member of the
+// ar(1) archive read from r, in archive order, and stops at the first error.
+// Both the System V (GNU) and BSD member name conventions are supported;
+// symbol tables and name tables are skipped.
+func forEachArchiveMember(r io.ReaderAt, size int64, fn func(name string, data *io.SectionReader) error) error {
+	const headerSize = 60
+	var longNames []byte
+	for off := int64(len(arMagic)); off+headerSize <= size; {
+		var hdr [headerSize]byte
+		if _, err := r.ReadAt(hdr[:], off); err != nil {
+			return fmt.Errorf("read archive header at %#x: %w", off, err)
+		}
+		name := strings.TrimRight(string(hdr[0:16]), " ")
+		memberSize, err := strconv.ParseInt(strings.TrimRight(string(hdr[48:58]), " "), 10, 64)
+		if err != nil || memberSize < 0 || off+headerSize+memberSize > size {
+			return fmt.Errorf("malformed archive header at %#x", off)
+		}
+		data := io.NewSectionReader(r, off+headerSize, memberSize)
+		// Members are aligned to two bytes.
+		off += headerSize + memberSize + memberSize%2
+
+		switch {
+		case name == "/" || name == "/SYM64/" || strings.HasPrefix(name, "__.SYMDEF"):
+			continue
+		case name == "//":
+			if longNames, err = io.ReadAll(data); err != nil {
+				return fmt.Errorf("read archive name table: %w", err)
+			}
+			continue
+		case strings.HasPrefix(name, "#1/"):
+			// BSD: the name precedes the member data.
+			n, err := strconv.ParseInt(name[3:], 10, 64)
+			if err != nil || n < 0 || n > memberSize {
+				return fmt.Errorf("malformed archive member name %q", name)
+			}
+			buf := make([]byte, n)
+			if _, err := data.ReadAt(buf, 0); err != nil {
+				return fmt.Errorf("read archive member name: %w", err)
+			}
+			name = strings.TrimRight(string(buf), "\x00")
+			data = io.NewSectionReader(data, n, memberSize-n)
+		case strings.HasPrefix(name, "/"):
+			// System V: an offset into the name table.
+			i, err := strconv.Atoi(name[1:])
+			if err != nil || i < 0 || i >= len(longNames) {
+				return fmt.Errorf("malformed archive member name %q", name)
+			}
+			name, _, _ = strings.Cut(string(longNames[i:]), "/\n")
+		default:
+			name = strings.TrimSuffix(name, "/")
+		}
+
+		if err := fn(name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
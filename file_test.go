@@ -0,0 +1,653 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"cmp"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+	"github.com/maxgio92/resurgo/resurgotest"
+)
+
+func TestDetectFunctionsFromFile(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	sources := map[string]string{
+		"a.c": "int add(int a, int b) { return a + b; }\n",
+		"b.c": "extern int add(int, int);\nint twice(int a) { return add(a, a); }\nint main(void) { return twice(1); }\n",
+	}
+	for name, code := range sources {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(code), 0o644); err != nil {
+			t.Fatalf("failed to write source: %v", err)
+		}
+	}
+	run := func(name string, args ...string) {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, out)
+		}
+	}
+	run("gcc", "-O0", "-c", "a.c", "b.c")
+	run("gcc", "-O0", "-o", "app", "a.o", "b.o")
+	if _, err := exec.LookPath("ar"); err == nil {
+		run("ar", "rcs", "libab.a", "a.o", "b.o")
+	}
+
+	t.Run("elf", func(t *testing.T) {
+		path := filepath.Join(dir, "app")
+		result, err := resurgo.DetectFunctionsFromFile(path)
+		if err != nil {
+			t.Fatalf("resurgo.DetectFunctionsFromFile: %v", err)
+		}
+		if result.Format != resurgo.FormatELF || result.Arch != resurgo.ArchAMD64 {
+			t.Errorf("got %s/%s, want %s/%s", result.Format, result.Arch, resurgo.FormatELF, resurgo.ArchAMD64)
+		}
+
+		f, err := elf.Open(path)
+		if err != nil {
+			t.Fatalf("failed to open ELF: %v", err)
+		}
+		defer f.Close()
+		want, err := resurgo.DetectFunctionsFromELF(f)
+		if err != nil {
+			t.Fatalf("resurgo.DetectFunctionsFromELF: %v", err)
+		}
+		if len(result.Functions) != len(want) {
+			t.Errorf("got %d functions, want %d as from DetectFunctionsFromELF", len(result.Functions), len(want))
+		}
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		path := filepath.Join(dir, "libab.a")
+		if _, err := os.Stat(path); err != nil {
+			t.Skip("ar not found, skipping")
+		}
+		result, err := resurgo.DetectFunctionsFromFile(path)
+		if err != nil {
+			t.Fatalf("resurgo.DetectFunctionsFromFile: %v", err)
+		}
+		if result.Format != resurgo.FormatArchive {
+			t.Errorf("got format %s, want %s", result.Format, resurgo.FormatArchive)
+		}
+		var names []string
+		for _, m := range result.Members {
+			names = append(names, m.Name)
+			if m.Format != resurgo.FormatELF {
+				t.Errorf("%s: got format %s, want %s", m.Name, m.Format, resurgo.FormatELF)
+			}
+			// Each object holds its first function at the start of .text.
+			if len(m.Functions) == 0 || m.Functions[0].Address != 0 {
+				t.Errorf("%s: function at .text start not detected: %+v", m.Name, m.Functions)
+			}
+		}
+		if !slices.Equal(names, []string{"a.o", "b.o"}) {
+			t.Errorf("got members %v, want [a.o b.o]", names)
+		}
+	})
+
+	for _, tc := range []struct {
+		goos, goarch string
+		format       resurgo.Format
+		arch         resurgo.Arch
+	}{
+		{"windows", "amd64", resurgo.FormatPE, resurgo.ArchAMD64},
+		{"darwin", "arm64", resurgo.FormatMachO, resurgo.ArchARM64},
+		{"linux", "386", resurgo.FormatELF, resurgo.ArchX86},
+		{"linux", "riscv64", resurgo.FormatELF, resurgo.ArchRISCV64},
+		{"linux", "arm", resurgo.FormatELF, resurgo.ArchARM},
+		{"linux", "ppc64le", resurgo.FormatELF, resurgo.ArchPPC64LE},
+		{"linux", "ppc64", resurgo.FormatELF, resurgo.ArchPPC64},
+	} {
+		t.Run(string(tc.format)+"/"+tc.goarch, func(t *testing.T) {
+			src := filepath.Join(dir, "hello.go")
+			if err := os.WriteFile(src, []byte("package main\n\nfunc main() { println(\"hello\") }\n"), 0o644); err != nil {
+				t.Fatalf("failed to write source: %v", err)
+			}
+			path := filepath.Join(dir, "hello-"+tc.goos+"-"+tc.goarch)
+			resurgotest.CompileGo(t, src, path, "GOOS="+tc.goos, "GOARCH="+tc.goarch, "CGO_ENABLED=0")
+
+			result, err := resurgo.DetectFunctionsFromFile(path)
+			if err != nil {
+				t.Fatalf("resurgo.DetectFunctionsFromFile: %v", err)
+			}
+			if result.Format != tc.format || result.Arch != tc.arch {
+				t.Errorf("got %s/%s, want %s/%s", result.Format, result.Arch, tc.format, tc.arch)
+			}
+			if len(result.Functions) == 0 {
+				t.Error("no functions detected")
+			}
+		})
+	}
+
+	// push rbp; mov rbp, rsp; pop rbp; ret
+	code := []byte{0x55, 0x48, 0x89, 0xe5, 0x5d, 0xc3}
+
+	t.Run("raw", func(t *testing.T) {
+		const base = 0x400000
+		result, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(code), int64(len(code)),
+			resurgo.WithArch(resurgo.ArchAMD64), resurgo.WithBaseAddress(base))
+		if err != nil {
+			t.Fatalf("resurgo.DetectFunctionsFromReader: %v", err)
+		}
+		if result.Format != resurgo.FormatRaw || result.Arch != resurgo.ArchAMD64 {
+			t.Errorf("got %s/%s, want %s/%s", result.Format, result.Arch, resurgo.FormatRaw, resurgo.ArchAMD64)
+		}
+		if len(result.Functions) != 1 || result.Functions[0].Address != base {
+			t.Errorf("got %+v, want one function at 0x%x", result.Functions, base)
+		}
+	})
+
+	t.Run("raw without arch", func(t *testing.T) {
+		if _, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(code), int64(len(code))); err == nil {
+			t.Error("expected an error for raw input without WithArch")
+		}
+	})
+}
+
+func TestDetectProloguesFromPE(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "hello.go")
+	if err := os.WriteFile(src, []byte("package main\n\nfunc main() { println(\"hello\") }\n"), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	for _, goarch := range []string{"amd64", "arm64"} {
+		t.Run(goarch, func(t *testing.T) {
+			path := filepath.Join(dir, "hello-"+goarch+".exe")
+			resurgotest.CompileGo(t, src, path, "GOOS=windows", "GOARCH="+goarch, "CGO_ENABLED=0")
+			f, err := pe.Open(path)
+			if err != nil {
+				t.Fatalf("failed to open PE: %v", err)
+			}
+			defer f.Close()
+
+			var imageBase uint64
+			switch h := f.OptionalHeader.(type) {
+			case *pe.OptionalHeader64:
+				imageBase = h.ImageBase
+			case *pe.OptionalHeader32:
+				imageBase = uint64(h.ImageBase)
+			}
+			inCode := func(addr uint64) bool {
+				for _, sec := range f.Sections {
+					start := imageBase + uint64(sec.VirtualAddress)
+					if sec.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE != 0 && addr >= start && addr < start+uint64(sec.VirtualSize) {
+						return true
+					}
+				}
+				return false
+			}
+
+			prologues, err := resurgo.DetectProloguesFromPE(f)
+			if err != nil {
+				t.Fatalf("resurgo.DetectProloguesFromPE: %v", err)
+			}
+			if len(prologues) == 0 {
+				t.Fatal("no prologues detected")
+			}
+			for _, p := range prologues {
+				if !inCode(p.Address) {
+					t.Errorf("prologue at 0x%x outside the executable sections", p.Address)
+				}
+			}
+			if !slices.IsSortedFunc(prologues, func(a, b resurgo.Prologue) int { return cmp.Compare(a.Address, b.Address) }) {
+				t.Error("prologues not ordered by address")
+			}
+		})
+	}
+}
+
+func TestDetectFunctionsFromFile_PData(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "hello.go")
+	if err := os.WriteFile(src, []byte("package main\n\nfunc main() { println(\"hello\") }\n"), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	path := filepath.Join(dir, "hello.exe")
+	resurgotest.CompileGo(t, src, path, "GOOS=windows", "GOARCH=amd64", "CGO_ENABLED=0")
+	f, err := pe.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open PE: %v", err)
+	}
+	defer f.Close()
+
+	imageBase := f.OptionalHeader.(*pe.OptionalHeader64).ImageBase
+	sec := f.Section(".pdata")
+	if sec == nil {
+		t.Skip("no .pdata section, skipping")
+	}
+	pdata, err := sec.Data()
+	if err != nil {
+		t.Fatalf("failed to read .pdata: %v", err)
+	}
+
+	result, err := resurgo.DetectFunctionsFromFile(path)
+	if err != nil {
+		t.Fatalf("resurgo.DetectFunctionsFromFile: %v", err)
+	}
+	confidence := make(map[uint64]resurgo.Confidence, len(result.Functions))
+	for _, c := range result.Functions {
+		confidence[c.Address] = c.Confidence
+	}
+	// Every RUNTIME_FUNCTION.BeginAddress must be reported with high
+	// confidence, whether the heuristics found it or not.
+	for off := 0; off+12 <= int(sec.VirtualSize) && off+12 <= len(pdata); off += 12 {
+		begin := binary.LittleEndian.Uint32(pdata[off:])
+		if begin == 0 {
+			continue
+		}
+		addr := imageBase + uint64(begin)
+		if got, ok := confidence[addr]; !ok || got != resurgo.ConfidenceHigh {
+			t.Errorf("function at 0x%x from .pdata: got confidence %q, want %q", addr, got, resurgo.ConfidenceHigh)
+		}
+	}
+}
+
+func TestDetectFunctionsFromFile_FunctionStarts(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "hello.go")
+	if err := os.WriteFile(src, []byte("package main\n\nfunc main() { println(\"hello\") }\n"), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	path := filepath.Join(dir, "hello")
+	resurgotest.CompileGo(t, src, path, "GOOS=darwin", "GOARCH=arm64", "CGO_ENABLED=0")
+	image, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := macho.NewFile(bytes.NewReader(image))
+	if err != nil {
+		t.Fatalf("failed to parse Mach-O: %v", err)
+	}
+	text := f.Segment("__TEXT")
+	textSect := f.Section("__text")
+	if text == nil || textSect == nil || f.Symtab == nil {
+		t.Skip("no __text section or symbol table, skipping")
+	}
+
+	// The Go linker writes no LC_FUNCTION_STARTS table: turn the
+	// LC_CODE_SIGNATURE command, also a linkedit_data_command, into one and
+	// overwrite its data with the starts of the first functions.
+	var starts []uint64
+	for _, sym := range f.Symtab.Syms {
+		if sym.Sect > 0 && f.Sections[sym.Sect-1] == textSect {
+			starts = append(starts, sym.Value)
+		}
+	}
+	slices.Sort(starts)
+	starts = slices.Compact(starts)[:min(len(starts), 32)]
+	var table []byte
+	prev := text.Addr
+	for _, addr := range starts {
+		table = binary.AppendUvarint(table, addr-prev)
+		prev = addr
+	}
+	table = append(table, 0)
+
+	patched := false
+	off := 32 // size of the 64-bit Mach-O header
+	for _, l := range f.Loads {
+		raw := l.Raw()
+		if binary.LittleEndian.Uint32(raw) == 0x1d { // LC_CODE_SIGNATURE
+			binary.LittleEndian.PutUint32(image[off:], 0x26) // LC_FUNCTION_STARTS
+			dataOff := binary.LittleEndian.Uint32(raw[8:])
+			if int(binary.LittleEndian.Uint32(raw[12:])) < len(table) {
+				t.Skip("code signature too small, skipping")
+			}
+			copy(image[dataOff:], table)
+			patched = true
+			break
+		}
+		off += len(raw)
+	}
+	if !patched {
+		t.Skip("no LC_CODE_SIGNATURE command, skipping")
+	}
+
+	result, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(image), int64(len(image)))
+	if err != nil {
+		t.Fatalf("resurgo.DetectFunctionsFromReader: %v", err)
+	}
+	confidence := make(map[uint64]resurgo.Confidence, len(result.Functions))
+	for _, c := range result.Functions {
+		confidence[c.Address] = c.Confidence
+	}
+	for _, addr := range starts {
+		if got, ok := confidence[addr]; !ok || got != resurgo.ConfidenceHigh {
+			t.Errorf("function at 0x%x from LC_FUNCTION_STARTS: got confidence %q, want %q", addr, got, resurgo.ConfidenceHigh)
+		}
+	}
+}
+
+func TestDetectProloguesFromELF(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "object", args: []string{"-c", "-ffunction-sections"}},
+		{name: "executable", args: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			args := append([]string{"-O0", "-fno-omit-frame-pointer", "-o", path}, tt.args...)
+			cmd := exec.Command("gcc", append(args, "testdata/demo-app.c")...)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+			}
+			f, err := elf.Open(path)
+			if err != nil {
+				t.Fatalf("failed to open ELF: %v", err)
+			}
+			defer f.Close()
+
+			prologues, err := resurgo.DetectProloguesFromELF(f)
+			if err != nil {
+				t.Fatalf("resurgo.DetectProloguesFromELF: %v", err)
+			}
+			type location struct {
+				section string
+				addr    uint64
+			}
+			found := make(map[location]bool, len(prologues))
+			for _, p := range prologues {
+				found[location{p.Section, p.Address}] = true
+			}
+
+			// Every function of demo-app.c sets up a frame pointer.
+			syms, err := f.Symbols()
+			if err != nil {
+				t.Fatalf("failed to read symbols: %v", err)
+			}
+			for _, name := range []string{"add", "multiply", "subtract", "divide", "main"} {
+				idx := slices.IndexFunc(syms, func(s elf.Symbol) bool { return s.Name == name })
+				if idx < 0 {
+					t.Fatalf("symbol %s not found", name)
+				}
+				sym := syms[idx]
+				sec := f.Sections[sym.Section]
+				if tt.name == "object" && sec.Name != ".text."+name {
+					t.Errorf("%s in section %s, want .text.%s", name, sec.Name, name)
+				}
+				if !found[location{sec.Name, sym.Value}] {
+					t.Errorf("no prologue for %s at %s+0x%x, got %+v", name, sec.Name, sym.Value, prologues)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectProloguesFromELFSection(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	path := filepath.Join(t.TempDir(), "demo-app.o")
+	cmd := exec.Command("gcc", "-O0", "-fno-omit-frame-pointer", "-c", "-ffunction-sections", "-o", path, "testdata/demo-app.c")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	prologues, err := resurgo.DetectProloguesFromELFSection(f, ".text.multiply")
+	if err != nil {
+		t.Fatalf("resurgo.DetectProloguesFromELFSection: %v", err)
+	}
+	if len(prologues) == 0 || prologues[0].Address != 0 {
+		t.Errorf("got %+v, want a prologue at .text.multiply+0x0", prologues)
+	}
+	for _, p := range prologues {
+		if p.Section != ".text.multiply" {
+			t.Errorf("prologue at %s+0x%x, want only .text.multiply", p.Section, p.Address)
+		}
+	}
+
+	for _, name := range []string{".text.missing", ".data"} {
+		if _, err := resurgo.DetectProloguesFromELFSection(f, name); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDetectProloguesFromELF_Permissions(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "rwx.c")
+	// The section flags end the directive gcc emits, making .wcode
+	// writable code, linked into a RWX segment.
+	code := `__attribute__((noinline, section(".wcode,\"awx\",@progbits #"))) int patched(int a) { return a * 3; }
+int main(int argc, char **argv) { return patched(argc); }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	outPath := filepath.Join(dir, "rwx")
+	cmd := exec.Command("gcc", "-O0", "-fno-omit-frame-pointer", "-fno-asynchronous-unwind-tables", "-o", outPath, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("failed to compile rwx.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+
+	prologues, err := resurgo.DetectProloguesFromELF(f)
+	if err != nil {
+		t.Fatalf("DetectProloguesFromELF: %v", err)
+	}
+	candidates, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithFilters(), resurgo.WithSectionNames(true))
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromELF: %v", err)
+	}
+	type location struct{ section, permissions string }
+	gotPrologues := make(map[uint64]location)
+	for _, p := range prologues {
+		gotPrologues[p.Address] = location{p.Section, p.Permissions}
+	}
+	gotCandidates := make(map[uint64]location)
+	for _, c := range candidates {
+		gotCandidates[c.Address] = location{c.Section, c.Permissions}
+	}
+	want := map[string]location{
+		"patched": {".wcode", "rwx"},
+		"main":    {".text", "r-x"},
+	}
+	for _, sym := range syms {
+		loc, ok := want[sym.Name]
+		if !ok {
+			continue
+		}
+		if got := gotPrologues[sym.Value]; got != loc {
+			t.Errorf("prologue of %s: got %+v, want %+v", sym.Name, got, loc)
+		}
+		if got := gotCandidates[sym.Value]; got != loc {
+			t.Errorf("candidate %s: got %+v, want %+v", sym.Name, got, loc)
+		}
+	}
+}
+
+func TestDetectProloguesFromArchive(t *testing.T) {
+	for _, tool := range []string{"gcc", "ar"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found, skipping", tool)
+		}
+	}
+
+	dir := t.TempDir()
+	sources := map[string]string{
+		"a.c":          "int add(int a, int b) { return a + b; }\n",
+		"b.c":          "extern int add(int, int);\nint twice(int a) { return add(a, a); }\nint square(int a) { return a * a; }\n",
+		"__.PKGDEF.go": "go object linux amd64\n",
+	}
+	for name, code := range sources {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(code), 0o644); err != nil {
+			t.Fatalf("failed to write source: %v", err)
+		}
+	}
+	run := func(name string, args ...string) {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, out)
+		}
+	}
+	run("gcc", "-O0", "-fno-omit-frame-pointer", "-ffunction-sections", "-c", "a.c", "b.c")
+	run("ar", "rcs", "libab.a", "a.o", "__.PKGDEF.go", "b.o")
+
+	lib, err := os.Open(filepath.Join(dir, "libab.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lib.Close()
+	info, err := lib.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	members, err := resurgo.DetectProloguesFromArchive(lib, info.Size())
+	if err != nil {
+		t.Fatalf("resurgo.DetectProloguesFromArchive: %v", err)
+	}
+
+	// Each function starts its own section, at offset 0.
+	want := map[string][]string{
+		"a.o": {".text.add"},
+		"b.o": {".text.twice", ".text.square"},
+	}
+	var names []string
+	for _, m := range members {
+		names = append(names, m.Name)
+		var sections []string
+		for _, p := range m.Prologues {
+			if p.Address == 0 {
+				sections = append(sections, p.Section)
+			}
+		}
+		// Several patterns may match at one address.
+		sections = slices.Compact(sections)
+		if !slices.Equal(sections, want[m.Name]) {
+			t.Errorf("%s: got prologues in %v, want %v", m.Name, sections, want[m.Name])
+		}
+	}
+	if !slices.Equal(names, []string{"a.o", "b.o"}) {
+		t.Errorf("got members %v, want [a.o b.o]", names)
+	}
+
+	if _, err := resurgo.DetectProloguesFromArchive(bytes.NewReader([]byte("not an archive")), 14); err == nil {
+		t.Error("expected an error for input that is not an archive")
+	}
+}
+
+func TestDetectProloguesFromELF_KernelModule(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+	if runtime.GOARCH != "amd64" {
+		t.Skip("kernel module test requires an x86-64 gcc")
+	}
+
+	// A module in the shape of a .ko: ftrace hooks at function entry,
+	// with its init and exit functions in their own sections.
+	dir := t.TempDir()
+	src := filepath.Join(dir, "mod.c")
+	code := `extern int printk(const char *, ...);
+int counter;
+__attribute__((noinline)) int helper(int x) { counter += x; return counter * 3; }
+int work(int a) { if (a) printk("work %d\n", helper(a)); return a; }
+__attribute__((section(".init.text"), used)) static int mod_init(void) { return helper(1); }
+__attribute__((section(".exit.text"), used)) static void mod_exit(void) { printk("exit\n"); }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	tests := []struct {
+		name  string
+		flags []string
+	}{
+		{name: "kernel", flags: []string{"-fno-pic", "-mcmodel=kernel", "-fcf-protection=branch"}},
+		{name: "pic", flags: []string{"-fpic", "-fcf-protection=none"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".ko")
+			args := append([]string{"-O2", "-c", "-pg", "-mfentry", "-o", path}, tt.flags...)
+			cmd := exec.Command("gcc", append(args, src)...)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Skipf("gcc cannot build -mfentry objects: %v\n%s", err, out)
+			}
+			f, err := elf.Open(path)
+			if err != nil {
+				t.Fatalf("failed to open ELF: %v", err)
+			}
+			defer f.Close()
+
+			prologues, err := resurgo.DetectProloguesFromELF(f)
+			if err != nil {
+				t.Fatalf("resurgo.DetectProloguesFromELF: %v", err)
+			}
+			var got []string
+			for _, p := range prologues {
+				if p.Type == resurgo.PrologueFentry {
+					got = append(got, fmt.Sprintf("%s+0x%x", p.Section, p.Address))
+				}
+			}
+
+			syms, err := f.Symbols()
+			if err != nil {
+				t.Fatalf("failed to read symbols: %v", err)
+			}
+			var want []string
+			for _, sym := range syms {
+				if elf.ST_TYPE(sym.Info) == elf.STT_FUNC {
+					want = append(want, fmt.Sprintf("%s+0x%x", f.Sections[sym.Section].Name, sym.Value))
+				}
+			}
+			slices.Sort(got)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("got fentry prologues %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -2,13 +2,26 @@ package resurgo
 
 import (
 	"debug/elf"
+	"encoding/binary"
+	"math"
 	"slices"
 )
 
 // CandidateFilter applies an ELF-aware transformation to a candidate slice.
 // Each filter reads only what it needs from f and returns the updated slice.
+// Filters may only remove candidates or annotate them, e.g. adjusting their
+// confidence or naming them: DetectFunctionsFromELF discards the candidates
+// a filter returns at addresses absent from its input.
 type CandidateFilter func([]FunctionCandidate, *elf.File) ([]FunctionCandidate, error)
 
+// DefaultFilters returns the default filter pipeline of
+// DetectFunctionsFromELF: CETFilter, EhFrameFilter and PLTFilter, in the
+// order they run. Callers insert their own filters into it and pass the
+// result to WithFilters.
+func DefaultFilters() []CandidateFilter {
+	return []CandidateFilter{CETFilter, EhFrameFilter, PLTFilter}
+}
+
 // WithFilters replaces the default filter pipeline with the provided filters.
 // They run in the order provided. Pass no arguments to disable all filters.
 func WithFilters(filters ...CandidateFilter) Option {
@@ -17,36 +30,70 @@ func WithFilters(filters ...CandidateFilter) Option {
 	}
 }
 
+// WithExtraFilters appends filters to the filter pipeline configured so far,
+// the default one or that of a preset, to run after it.
+func WithExtraFilters(filters ...CandidateFilter) Option {
+	return func(o *options) {
+		o.filters = append(slices.Clone(o.filters), filters...)
+	}
+}
+
+// keepFiltered drops from out, returned by a filter, the candidates at
+// addresses absent from in, its input, as filters cannot add candidates.
+func keepFiltered(in map[uint64]struct{}, out []FunctionCandidate) []FunctionCandidate {
+	return slices.DeleteFunc(out, func(c FunctionCandidate) bool {
+		_, ok := in[c.Address]
+		return !ok
+	})
+}
+
 // PLTFilter removes candidates that land inside linker-generated PLT
-// sections (.plt, .plt.got, .plt.sec, .iplt) as reported by f.
+// sections (.plt, .plt.got, .plt.sec, .plt.bnd, .iplt) as reported by f.
 func PLTFilter(candidates []FunctionCandidate, f *elf.File) ([]FunctionCandidate, error) {
-	var pltRanges [][2]uint64
-	for _, name := range []string{".plt", ".plt.got", ".plt.sec", ".iplt"} {
-		if sec := f.Section(name); sec != nil {
-			pltRanges = append(pltRanges, [2]uint64{sec.Addr, sec.Addr + sec.Size})
-		}
-	}
-	return FilterCandidatesInRanges(candidates, pltRanges), nil
+	return FilterCandidatesInRanges(candidates, pltRanges(f)), nil
 }
 
-// CETFilter filters candidates using the CET-aware ENDBR64 heuristic, reading
-// the .text section from f. Non-AMD64 binaries are returned unchanged.
-// The ELF entry point is exempt from the ENDBR64 requirement: it is not an
-// indirect branch target and therefore never carries ENDBR64 even in CET
-// binaries (e.g. _start). The filter must run before EhFrameFilter.
+// CETFilter filters candidates using the landing pads of control-flow
+// protected code, reading the code scanned by DisasmDetector from f.
+//
+// On AMD64, binaries whose GNU property note declares IBT have every
+// indirect branch target start with ENDBR64: aligned-entry candidates
+// lacking it are dropped, and those carrying it are raised to
+// ConfidenceMedium. Binaries without the note are recognized as CET ones
+// by the heuristic of FilterAlignedEntriesCETAMD64. On ARM64, binaries
+// declaring BTI get the same treatment with the BTI c and BTI jc landing
+// pads and the PACIASP and PACIBSP instructions, which are implicit ones.
+// Other binaries are returned unchanged.
+// The ELF entry point is exempt from the landing pad requirement: it is not
+// an indirect branch target and therefore never carries one even in
+// protected binaries (e.g. _start). The filter must run before
+// EhFrameFilter.
 func CETFilter(candidates []FunctionCandidate, f *elf.File) ([]FunctionCandidate, error) {
-	if f.Machine != elf.EM_X86_64 {
+	var arch Arch
+	switch f.Machine {
+	case elf.EM_X86_64:
+		arch = ArchAMD64
+	case elf.EM_AARCH64:
+		arch = ArchARM64
+	default:
 		return candidates, nil
 	}
-	textSec := f.Section(".text")
-	if textSec == nil {
+	// A malformed property note declares nothing.
+	props, _ := ReadGNUProperties(f)
+	if arch == ArchARM64 && !props.BTI {
 		return candidates, nil
 	}
-	textBytes, err := textSec.Data()
+	sections, err := elfCodeSections(f, arch, nil)
 	if err != nil {
-		return nil, err
+		return candidates, nil
+	}
+	switch {
+	case arch == ArchARM64:
+		return filterLandingPads(candidates, sections, f.Entry, isBTILandingPad), nil
+	case props.IBT:
+		return filterLandingPads(candidates, sections, f.Entry, isENDBR64), nil
 	}
-	return FilterAlignedEntriesCETAMD64(candidates, textBytes, textSec.Addr, f.Entry), nil
+	return filterAlignedEntriesCET(candidates, sections, f.Entry), nil
 }
 
 // FilterAlignedEntriesCETAMD64 drops aligned-entry candidates lacking ENDBR64
@@ -63,23 +110,18 @@ func CETFilter(candidates []FunctionCandidate, f *elf.File) ([]FunctionCandidate
 // ENDBR64 hits from CRT helpers. Non-CET binaries are returned unchanged.
 // Only DetectionAlignedEntry candidates are affected.
 func FilterAlignedEntriesCETAMD64(candidates []FunctionCandidate, textBytes []byte, textVA, entryVA uint64) []FunctionCandidate {
-	hasENDBR64 := func(va uint64) bool {
-		if va < textVA {
-			return false
-		}
-		off := va - textVA
-		if off+4 > uint64(len(textBytes)) {
-			return false
-		}
-		return [4]byte(textBytes[off:off+4]) == endbr64Bytes
-	}
+	return filterAlignedEntriesCET(candidates, []codeSection{{code: textBytes, addr: textVA}}, entryVA)
+}
 
+// filterAlignedEntriesCET is FilterAlignedEntriesCETAMD64 for code split into
+// sections.
+func filterAlignedEntriesCET(candidates []FunctionCandidate, sections []codeSection, entryVA uint64) []FunctionCandidate {
 	// Threshold of 5: non-CET binaries can have up to ~4 incidental ENDBR64
 	// hits from CRT helpers; 5 or more reliably indicates a CET binary.
 	const cetMinHits = 5
 	cetHits := 0
 	for i := range candidates {
-		if candidates[i].DetectionType == DetectionAlignedEntry && hasENDBR64(candidates[i].Address) {
+		if candidates[i].DetectionType == DetectionAlignedEntry && startsWith(sections, candidates[i].Address, isENDBR64) {
 			cetHits++
 			if cetHits >= cetMinHits {
 				break
@@ -95,7 +137,7 @@ func FilterAlignedEntriesCETAMD64(candidates []FunctionCandidate, textBytes []by
 	// All other detection types are kept unconditionally.
 	result := candidates[:0]
 	for _, c := range candidates {
-		if c.DetectionType == DetectionAlignedEntry && !hasENDBR64(c.Address) && c.Address != entryVA {
+		if c.DetectionType == DetectionAlignedEntry && !startsWith(sections, c.Address, isENDBR64) && c.Address != entryVA {
 			continue
 		}
 		result = append(result, c)
@@ -103,6 +145,63 @@ func FilterAlignedEntriesCETAMD64(candidates []FunctionCandidate, textBytes []by
 	return result
 }
 
+// filterLandingPads drops the aligned-entry candidates that do not start
+// with a landing pad, as recognized by isPad, from code known to place one
+// at every indirect branch target, and raises those that do to
+// ConfidenceMedium. The entry point at entryVA is exempt. Other detection
+// types are kept unchanged.
+func filterLandingPads(candidates []FunctionCandidate, sections []codeSection, entryVA uint64, isPad func([]byte) bool) []FunctionCandidate {
+	result := candidates[:0]
+	for _, c := range candidates {
+		if c.DetectionType == DetectionAlignedEntry {
+			switch {
+			case startsWith(sections, c.Address, isPad):
+				c.Confidence = ConfidenceMedium
+			case c.Address != entryVA:
+				continue
+			}
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+// startsWith reports whether the 4 bytes of code at va in sections satisfy
+// match.
+func startsWith(sections []codeSection, va uint64, match func([]byte) bool) bool {
+	for _, sec := range sections {
+		if va < sec.addr {
+			continue
+		}
+		if off := va - sec.addr; off+4 <= uint64(len(sec.code)) {
+			return match(sec.code[off : off+4])
+		}
+	}
+	return false
+}
+
+// isENDBR64 reports whether code starts with ENDBR64.
+func isENDBR64(code []byte) bool {
+	return [4]byte(code[:4]) == endbr64Bytes
+}
+
+// isBTILandingPad reports whether code starts with an ARM64 instruction
+// that a call through a register may land on: BTI c, BTI jc, or PACIASP or
+// PACIBSP, which act as BTI c.
+func isBTILandingPad(code []byte) bool {
+	const (
+		btiC    = 0xd503245f
+		btiJC   = 0xd50324df
+		paciasp = 0xd503233f
+		pacibsp = 0xd503237f
+	)
+	switch binary.LittleEndian.Uint32(code) {
+	case btiC, btiJC, paciasp, pacibsp:
+		return true
+	}
+	return false
+}
+
 // FilterCandidatesInRanges removes candidates whose addresses fall within any
 // of the given address ranges. Each range is a [lo, hi) pair.
 //
@@ -130,7 +229,8 @@ func FilterCandidatesInRanges(candidates []FunctionCandidate, ranges [][2]uint64
 }
 
 // filterJumpTargetsByAnchorRange removes DetectionJumpTarget candidates that
-// are intra-function branch targets from the candidates map.
+// are intra-function branch targets from the candidates map, and promotes
+// those that are tail-call targets to DetectionTailCall.
 //
 // An anchor is a candidate confirmed by a CALL instruction
 // (DetectionCallTarget) or a prologue pattern (DetectionPrologueOnly,
@@ -145,7 +245,10 @@ func FilterCandidatesInRanges(candidates []FunctionCandidate, ranges [][2]uint64
 //
 // If JumpedFrom is empty the source is unknown, so the candidate is kept.
 // If any source falls outside the interval the jump is inter-function (e.g.
-// a tail call from another function), so the candidate is kept.
+// a tail call from another function), so the candidate is kept; when the
+// code before the target also ends a function (Boundary), the two signals
+// corroborate each other and the candidate is promoted to DetectionTailCall
+// with ConfidenceHigh.
 //
 // Aligned-entry candidates are intentionally excluded: small leaf functions
 // with no call-site or prologue signal have no enclosing anchor and would
@@ -169,15 +272,19 @@ func filterJumpTargetsByAnchorRange(candidates map[uint64]*FunctionCandidate) {
 		if found {
 			continue // addr is itself an anchor
 		}
-		if idx == 0 || idx >= len(anchors) {
-			continue // outside all anchor intervals
+		// addr falls strictly between lower and upper, the enclosing
+		// anchors or the ends of the address space. Only remove if every
+		// source is within the same interval: that means the jump
+		// originates from within the same function body (intra-function
+		// branch). Any source outside the interval means a different
+		// function is jumping here (inter-function tail call).
+		lower, upper := uint64(0), uint64(math.MaxUint64)
+		if idx > 0 {
+			lower = anchors[idx-1]
+		}
+		if idx < len(anchors) {
+			upper = anchors[idx]
 		}
-		// addr falls strictly between anchors[idx-1] and anchors[idx].
-		// Only remove if every source is within the same interval: that
-		// means the jump originates from within the same function body
-		// (intra-function branch). Any source outside the interval means
-		// a different function is jumping here (inter-function tail call).
-		lower, upper := anchors[idx-1], anchors[idx]
 		allIntra := len(c.JumpedFrom) > 0
 		for _, src := range c.JumpedFrom {
 			if src < lower || src >= upper {
@@ -185,8 +292,11 @@ func filterJumpTargetsByAnchorRange(candidates map[uint64]*FunctionCandidate) {
 				break
 			}
 		}
-		if allIntra {
+		switch {
+		case allIntra && idx > 0 && idx < len(anchors):
 			delete(candidates, addr)
+		case !allIntra && len(c.JumpedFrom) > 0 && c.Boundary:
+			c.DetectionType, c.Confidence = DetectionTailCall, ConfidenceHigh
 		}
 	}
 }
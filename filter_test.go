@@ -1,9 +1,13 @@
 package resurgo_test
 
 import (
+	"bytes"
 	"debug/elf"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"testing"
 
 	"github.com/maxgio92/resurgo"
@@ -259,11 +263,62 @@ func TestFilters(t *testing.T) {
 				}
 			}
 		},
+	}, {
+		name:   "cfi",
+		filter: resurgo.CFIConsistencyFilter,
+		// resurgo.CFIConsistencyFilter must agree with gcc's CFI for every
+		// prologue at an FDE start and never drop candidates.
+		check: func(t *testing.T, result []resurgo.FunctionCandidate) {
+			if len(result) != len(input) {
+				t.Fatalf("candidates dropped: input=%d output=%d", len(input), len(result))
+			}
+			fde, err := resurgo.EhFrameDetector(f)
+			if err != nil {
+				t.Fatalf("resurgo.EhFrameDetector: %v", err)
+			}
+			fdeSet := make(map[uint64]struct{}, len(fde))
+			for _, c := range fde {
+				fdeSet[c.Address] = struct{}{}
+			}
+			for i, c := range result {
+				if _, ok := fdeSet[c.Address]; !ok || c.PrologueType == "" {
+					continue
+				}
+				if c.Confidence != input[i].Confidence {
+					t.Errorf("candidate 0x%x (%s) demoted despite matching FDE",
+						c.Address, c.PrologueType)
+				}
+			}
+		},
+	}, {
+		name:   "dataregion",
+		filter: resurgo.DataRegionFilter,
+		// resurgo.DataRegionFilter must remove every candidate inside a data
+		// region of .text.
+		check: func(t *testing.T, result []resurgo.FunctionCandidate) {
+			text := f.Section(".text")
+			code, err := text.Data()
+			if err != nil {
+				t.Fatalf("failed to read .text: %v", err)
+			}
+			regions, err := resurgo.DetectDataRegions(code, text.Addr, resurgo.ArchAMD64)
+			if err != nil {
+				t.Fatalf("resurgo.DetectDataRegions: %v", err)
+			}
+			for _, c := range result {
+				for _, r := range regions {
+					if c.Address >= r.Address && c.Address < r.Address+r.Size {
+						t.Errorf("candidate 0x%x inside %s region at 0x%x was not removed",
+							c.Address, r.Kind, r.Address)
+					}
+				}
+			}
+		},
 	}}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := tt.filter(input, f)
+			result, err := tt.filter(slices.Clone(input), f)
 			if err != nil {
 				t.Fatalf("%v", err)
 			}
@@ -276,3 +331,221 @@ func TestFilters(t *testing.T) {
 }
 
 
+
+// TestLandingPadFilter verifies that LandingPadFilter drops the landing pads
+// of a C++ binary and nothing else.
+func TestLandingPadFilter(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "eh.cc")
+	const code = `
+#include <cstdio>
+#include <stdexcept>
+#include <string>
+
+__attribute__((noinline)) void check(int v) {
+	if (v < 0) throw std::runtime_error("negative");
+}
+
+__attribute__((noinline)) int guarded(int v) {
+	std::string s = std::to_string(v);
+	try {
+		check(v);
+	} catch (const std::exception &e) {
+		return -1;
+	}
+	return int(s.size());
+}
+
+int main(int argc, char **) { return guarded(argc) + guarded(-argc); }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	outPath := filepath.Join(dir, "eh")
+	cmd := exec.Command("g++", "-O1", "-o", outPath, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile eh.cc: %v\n%s", err, out)
+	}
+
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	// Every address of .text is a candidate.
+	text := f.Section(".text")
+	var input []resurgo.FunctionCandidate
+	for addr := text.Addr; addr < text.Addr+text.Size; addr++ {
+		input = append(input, resurgo.FunctionCandidate{Address: addr})
+	}
+	result, err := resurgo.LandingPadFilter(slices.Clone(input), f)
+	if err != nil {
+		t.Fatalf("resurgo.LandingPadFilter: %v", err)
+	}
+
+	kept := make(map[uint64]bool, len(result))
+	for _, c := range result {
+		kept[c.Address] = true
+	}
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) == elf.STT_FUNC && s.Value >= text.Addr && s.Value < text.Addr+text.Size && !kept[s.Value] {
+			t.Errorf("function %s at 0x%x dropped", s.Name, s.Value)
+		}
+	}
+	if dropped := len(input) - len(result); dropped == 0 {
+		t.Error("no landing pads dropped")
+	} else {
+		t.Logf("%d landing pads dropped", dropped)
+	}
+}
+
+func TestCFIConsistencyFilterOversizedExpression(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("CFI test requires an amd64 compiler")
+	}
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "cfi.c")
+	// DW_CFA_def_cfa_expression with a block length of 2^63.
+	const code = `
+__asm__(
+	".globl framed\n"
+	".type framed, @function\n"
+	"framed:\n"
+	".cfi_startproc\n"
+	"push %rbp\n"
+	".cfi_escape 0x0f,0x80,0x80,0x80,0x80,0x80,0x80,0x80,0x80,0x80,0x01\n"
+	"mov %rsp, %rbp\n"
+	"pop %rbp\n"
+	"ret\n"
+	".cfi_endproc\n");
+
+int framed(void);
+
+void _start(void) { framed(); for (;;); }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	outPath := filepath.Join(dir, "cfi")
+	cmd := exec.Command("gcc", "-O1", "-nostdlib", "-static", "-o", outPath, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile cfi.c: %v\n%s", err, out)
+	}
+
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	framed := resurgo.FunctionCandidate{PrologueType: resurgo.PrologueClassic, Confidence: resurgo.ConfidenceMedium}
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	for _, s := range syms {
+		if s.Name == "framed" {
+			framed.Address = s.Value
+		}
+	}
+	result, err := resurgo.CFIConsistencyFilter([]resurgo.FunctionCandidate{framed}, f)
+	if err != nil {
+		t.Fatalf("resurgo.CFIConsistencyFilter: %v", err)
+	}
+	if len(result) != 1 {
+		t.Errorf("got %d candidates, want 1", len(result))
+	}
+}
+
+func TestCFIConsistencyFilterUnknownCIE(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("CFI test requires an amd64 compiler")
+	}
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "cfi.c")
+	const code = `
+__asm__(
+	".globl framed\n"
+	".type framed, @function\n"
+	"framed:\n"
+	".cfi_startproc\n"
+	"push %rbp\n"
+	".cfi_def_cfa_offset 16\n"
+	".cfi_offset %rbp, -16\n"
+	"mov %rsp, %rbp\n"
+	".cfi_def_cfa_register %rbp\n"
+	"pop %rbp\n"
+	".cfi_def_cfa %rsp, 8\n"
+	"ret\n"
+	".cfi_endproc\n");
+
+int framed(void);
+
+void _start(void) { framed(); for (;;); }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	outPath := filepath.Join(dir, "cfi")
+	cmd := exec.Command("gcc", "-O1", "-nostdlib", "-static", "-fno-asynchronous-unwind-tables", "-o", outPath, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile cfi.c: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse ELF: %v", err)
+	}
+	// Replace the DW_CFA_def_cfa opcode opening the CIE initial
+	// instructions, def_cfa rsp+8; offset rip at cfa-8, with an unknown one.
+	ehFrame := f.Section(".eh_frame")
+	if ehFrame == nil {
+		t.Fatal("no .eh_frame section")
+	}
+	i := bytes.Index(data[ehFrame.Offset:ehFrame.Offset+ehFrame.Size], []byte{0x0c, 0x07, 0x08, 0x90, 0x01})
+	if i < 0 {
+		t.Fatal("CIE initial instructions not found")
+	}
+	data[ehFrame.Offset+uint64(i)] = 0x3f
+	if f, err = elf.NewFile(bytes.NewReader(data)); err != nil {
+		t.Fatalf("failed to parse ELF: %v", err)
+	}
+
+	framed := resurgo.FunctionCandidate{PrologueType: resurgo.PrologueClassic, Confidence: resurgo.ConfidenceMedium}
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	for _, s := range syms {
+		if s.Name == "framed" {
+			framed.Address = s.Value
+		}
+	}
+	result, err := resurgo.CFIConsistencyFilter([]resurgo.FunctionCandidate{framed}, f)
+	if err != nil {
+		t.Fatalf("resurgo.CFIConsistencyFilter: %v", err)
+	}
+	if len(result) != 1 || result[0].Confidence != resurgo.ConfidenceMedium {
+		t.Errorf("got %+v, want the candidate unchanged", result)
+	}
+}
@@ -0,0 +1,175 @@
+package resurgo
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// firmwareChunk is the data of one record of a firmware image.
+type firmwareChunk struct {
+	addr uint64
+	data []byte
+}
+
+// ReadIntelHex parses the Intel HEX firmware image read from r into the
+// regions of contiguous data it describes, ordered by address, for use with
+// DetectProloguesFromRegions. Extended segment and extended linear address
+// records are applied; start address records are ignored. The regions carry
+// no architecture. Records with a bad checksum, records overlapping each
+// other and data after the end-of-file record are errors.
+func ReadIntelHex(r io.Reader) ([]CodeRegion, error) {
+	var (
+		chunks []firmwareChunk
+		base   uint64
+		eof    bool
+	)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if eof {
+			return nil, fmt.Errorf("line %d: data after end-of-file record", n)
+		}
+		if line[0] != ':' {
+			return nil, fmt.Errorf("line %d: missing start code", n)
+		}
+		rec, err := decodeRecord(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		// Count, address (2), type, data, checksum.
+		if len(rec) < 5 || int(rec[0]) != len(rec)-5 {
+			return nil, fmt.Errorf("line %d: bad byte count", n)
+		}
+		if checksum(rec) != 0 {
+			return nil, fmt.Errorf("line %d: bad checksum", n)
+		}
+		addr, data := uint64(rec[1])<<8|uint64(rec[2]), rec[4:len(rec)-1]
+		switch rec[3] {
+		case 0x00: // data
+			chunks = append(chunks, firmwareChunk{addr: base + addr, data: data})
+		case 0x01: // end of file
+			eof = true
+		case 0x02: // extended segment address
+			if len(data) != 2 {
+				return nil, fmt.Errorf("line %d: bad extended segment address", n)
+			}
+			base = (uint64(data[0])<<8 | uint64(data[1])) << 4
+		case 0x04: // extended linear address
+			if len(data) != 2 {
+				return nil, fmt.Errorf("line %d: bad extended linear address", n)
+			}
+			base = (uint64(data[0])<<8 | uint64(data[1])) << 16
+		case 0x03, 0x05: // start segment and start linear address
+		default:
+			return nil, fmt.Errorf("line %d: unknown record type %#02x", n, rec[3])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read Intel HEX: %w", err)
+	}
+	return mergeChunks(chunks)
+}
+
+// ReadSREC parses the Motorola S-record firmware image read from r into the
+// regions of contiguous data it describes, ordered by address, for use with
+// DetectProloguesFromRegions. S1, S2 and S3 data records are read; header,
+// count and termination records are checked and otherwise ignored. The
+// regions carry no architecture. Records with a bad checksum and records
+// overlapping each other are errors.
+func ReadSREC(r io.Reader) ([]CodeRegion, error) {
+	var chunks []firmwareChunk
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(line) < 2 || line[0] != 'S' {
+			return nil, fmt.Errorf("line %d: missing record start", n)
+		}
+		var addrLen int
+		switch line[1] {
+		case '0', '1', '5', '9':
+			addrLen = 2
+		case '2', '6', '8':
+			addrLen = 3
+		case '3', '7':
+			addrLen = 4
+		default:
+			return nil, fmt.Errorf("line %d: unknown record type S%c", n, line[1])
+		}
+		rec, err := decodeRecord(line[2:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		// Count, address, data, checksum.
+		if len(rec) < 2+addrLen || int(rec[0]) != len(rec)-1 {
+			return nil, fmt.Errorf("line %d: bad byte count", n)
+		}
+		if checksum(rec) != 0xff {
+			return nil, fmt.Errorf("line %d: bad checksum", n)
+		}
+		if line[1] < '1' || line[1] > '3' {
+			continue
+		}
+		var addr uint64
+		for _, b := range rec[1 : 1+addrLen] {
+			addr = addr<<8 | uint64(b)
+		}
+		chunks = append(chunks, firmwareChunk{addr: addr, data: rec[1+addrLen : len(rec)-1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read S-records: %w", err)
+	}
+	return mergeChunks(chunks)
+}
+
+// decodeRecord decodes the hexadecimal digits of a record.
+func decodeRecord(digits []byte) ([]byte, error) {
+	rec := make([]byte, hex.DecodedLen(len(digits)))
+	if _, err := hex.Decode(rec, digits); err != nil {
+		return nil, fmt.Errorf("bad hexadecimal data: %w", err)
+	}
+	return rec, nil
+}
+
+// checksum returns the low byte of the sum of the bytes of rec.
+func checksum(rec []byte) byte {
+	var sum byte
+	for _, b := range rec {
+		sum += b
+	}
+	return sum
+}
+
+// mergeChunks sorts chunks by address and joins adjacent ones into regions.
+func mergeChunks(chunks []firmwareChunk) ([]CodeRegion, error) {
+	slices.SortStableFunc(chunks, func(a, b firmwareChunk) int { return cmp.Compare(a.addr, b.addr) })
+	var regions []CodeRegion
+	for _, c := range chunks {
+		if len(c.data) == 0 {
+			continue
+		}
+		if len(regions) > 0 {
+			last := &regions[len(regions)-1]
+			end := last.Addr + uint64(len(last.Code))
+			switch {
+			case c.addr < end:
+				return nil, fmt.Errorf("record at %#x overlaps data at %#x", c.addr, last.Addr)
+			case c.addr == end:
+				last.Code = append(last.Code, c.data...)
+				continue
+			}
+		}
+		regions = append(regions, CodeRegion{Code: slices.Clone(c.data), Addr: c.addr})
+	}
+	return regions, nil
+}
@@ -0,0 +1,117 @@
+package resurgo_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+// hexRecord formats an Intel HEX record.
+func hexRecord(typ byte, addr uint16, data ...byte) string {
+	rec := append([]byte{byte(len(data)), byte(addr >> 8), byte(addr), typ}, data...)
+	var sum byte
+	for _, b := range rec {
+		sum += b
+	}
+	return fmt.Sprintf(":%X%02X\n", rec, -sum)
+}
+
+// srecRecord formats an S-record of type typ with an addrLen-byte address.
+func srecRecord(typ byte, addrLen int, addr uint32, data ...byte) string {
+	rec := []byte{byte(addrLen + len(data) + 1)}
+	for i := addrLen - 1; i >= 0; i-- {
+		rec = append(rec, byte(addr>>(8*i)))
+	}
+	rec = append(rec, data...)
+	var sum byte
+	for _, b := range rec {
+		sum += b
+	}
+	return fmt.Sprintf("S%c%X%02X\n", typ, rec, ^sum)
+}
+
+func TestReadFirmware(t *testing.T) {
+	// push rbp; mov rbp, rsp; pop rbp; ret
+	fn := []byte{0x55, 0x48, 0x89, 0xe5, 0x5d, 0xc3}
+
+	tests := []struct {
+		name  string
+		read  func(r *strings.Reader) ([]resurgo.CodeRegion, error)
+		image string
+		want  []resurgo.CodeRegion
+	}{
+		{
+			name: "intel hex",
+			read: func(r *strings.Reader) ([]resurgo.CodeRegion, error) { return resurgo.ReadIntelHex(r) },
+			image: hexRecord(0x04, 0, 0x08, 0x00) + // base 0x08000000
+				hexRecord(0x00, 0x0003, fn[3:]...) +
+				hexRecord(0x00, 0x0000, fn[:3]...) +
+				hexRecord(0x02, 0, 0x10, 0x00) + // base 0x10000
+				hexRecord(0x00, 0x0100, fn...) +
+				hexRecord(0x05, 0, 0x08, 0x00, 0x00, 0x00) +
+				hexRecord(0x01, 0),
+			want: []resurgo.CodeRegion{
+				{Code: fn, Addr: 0x10100},
+				{Code: fn, Addr: 0x08000000},
+			},
+		},
+		{
+			name: "srec",
+			read: func(r *strings.Reader) ([]resurgo.CodeRegion, error) { return resurgo.ReadSREC(r) },
+			image: srecRecord('0', 2, 0, []byte("fw")...) +
+				srecRecord('1', 2, 0x0100, fn...) +
+				srecRecord('3', 4, 0x08000000, fn[:3]...) +
+				srecRecord('2', 3, 0x080003, fn[3:]...) + // below 16 MiB, so not contiguous
+				srecRecord('3', 4, 0x08000003, fn[3:]...) +
+				srecRecord('5', 2, 4) +
+				srecRecord('7', 4, 0x08000000),
+			want: []resurgo.CodeRegion{
+				{Code: fn, Addr: 0x0100},
+				{Code: fn[3:], Addr: 0x080003},
+				{Code: fn, Addr: 0x08000000},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regions, err := tt.read(strings.NewReader(tt.image))
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if !reflect.DeepEqual(regions, tt.want) {
+				t.Errorf("got regions %+v, want %+v", regions, tt.want)
+			}
+			prologues, err := resurgo.DetectProloguesFromRegions(regions, resurgo.WithArch(resurgo.ArchAMD64))
+			if err != nil {
+				t.Fatalf("DetectProloguesFromRegions: %v", err)
+			}
+			if len(prologues) == 0 || prologues[len(prologues)-1].Address != 0x08000000 {
+				t.Errorf("no prologue at 0x08000000: %+v", prologues)
+			}
+		})
+	}
+
+	errTests := []struct {
+		name  string
+		read  func(r *strings.Reader) ([]resurgo.CodeRegion, error)
+		image string
+	}{
+		{"intel hex checksum", func(r *strings.Reader) ([]resurgo.CodeRegion, error) { return resurgo.ReadIntelHex(r) }, ":0100000055AB\n"},
+		{"intel hex overlap", func(r *strings.Reader) ([]resurgo.CodeRegion, error) { return resurgo.ReadIntelHex(r) },
+			hexRecord(0x00, 0, fn...) + hexRecord(0x00, 2, fn...)},
+		{"intel hex after eof", func(r *strings.Reader) ([]resurgo.CodeRegion, error) { return resurgo.ReadIntelHex(r) },
+			hexRecord(0x01, 0) + hexRecord(0x00, 0, fn...)},
+		{"srec checksum", func(r *strings.Reader) ([]resurgo.CodeRegion, error) { return resurgo.ReadSREC(r) }, "S1040000550A\n"},
+		{"srec type", func(r *strings.Reader) ([]resurgo.CodeRegion, error) { return resurgo.ReadSREC(r) }, "S4030000FC\n"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.read(strings.NewReader(tt.image)); err == nil {
+				t.Error("expected an error, got nil")
+			}
+		})
+	}
+}
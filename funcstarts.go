@@ -0,0 +1,71 @@
+package resurgo
+
+import (
+	"debug/macho"
+	"encoding/binary"
+	"fmt"
+)
+
+// DetectionFunctionStarts is assigned to function candidates whose entry
+// address was read from the LC_FUNCTION_STARTS table of a Mach-O file. The
+// linker records every function start there for the unwinder and the
+// symbolicator, and strip leaves it in place.
+const DetectionFunctionStarts DetectionType = "function-starts"
+
+// loadCmdFunctionStarts is the LC_FUNCTION_STARTS load command.
+const loadCmdFunctionStarts macho.LoadCmd = 0x26
+
+// functionStartsCandidates returns a ConfidenceHigh candidate at every
+// address of the LC_FUNCTION_STARTS table of the Mach-O file f. The table is
+// a run of ULEB128 deltas terminated by zero; the first delta is relative to
+// the start of the __TEXT segment. Files without the table yield no
+// candidates.
+func functionStartsCandidates(f *macho.File) ([]FunctionCandidate, error) {
+	var cmd []byte
+	for _, l := range f.Loads {
+		raw := l.Raw()
+		if len(raw) >= 16 && macho.LoadCmd(f.ByteOrder.Uint32(raw)) == loadCmdFunctionStarts {
+			cmd = raw
+			break
+		}
+	}
+	if cmd == nil {
+		return nil, nil
+	}
+	text := f.Segment("__TEXT")
+	linkedit := f.Segment("__LINKEDIT")
+	if text == nil || linkedit == nil {
+		return nil, fmt.Errorf("LC_FUNCTION_STARTS without __TEXT and __LINKEDIT segments")
+	}
+
+	// linkedit_data_command: cmd, cmdsize, dataoff, datasize.
+	off := uint64(f.ByteOrder.Uint32(cmd[8:]))
+	size := uint64(f.ByteOrder.Uint32(cmd[12:]))
+	if off < linkedit.Offset || off+size > linkedit.Offset+linkedit.Filesz {
+		return nil, fmt.Errorf("LC_FUNCTION_STARTS data %#x+%#x outside __LINKEDIT", off, size)
+	}
+	data := make([]byte, size)
+	if _, err := linkedit.ReadAt(data, int64(off-linkedit.Offset)); err != nil {
+		return nil, fmt.Errorf("read LC_FUNCTION_STARTS data: %w", err)
+	}
+
+	var candidates []FunctionCandidate
+	addr := text.Addr
+	for len(data) > 0 {
+		delta, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("malformed LC_FUNCTION_STARTS entry")
+		}
+		if delta == 0 {
+			break
+		}
+		data = data[n:]
+		addr += delta
+		candidates = append(candidates, FunctionCandidate{
+			Address:       addr,
+			DetectionType: DetectionFunctionStarts,
+			Confidence:    ConfidenceHigh,
+		})
+	}
+	return candidates, nil
+}
@@ -0,0 +1,163 @@
+package resurgo
+
+import (
+	"maps"
+	"slices"
+
+	"golang.org/x/arch/arm64/arm64asm"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+// DetectionGapFill is assigned to function candidates recovered by the
+// second pass of WithGapFilling: a prologue behind padding, in a gap the
+// first pass left between two function starts.
+const DetectionGapFill DetectionType = "gap-fill"
+
+const (
+	// gapFillMinSize is the size of the smallest gap between function
+	// starts that is scanned again.
+	gapFillMinSize = 32
+	// gapFillWindow is the number of bytes after padding scanned for a
+	// prologue.
+	gapFillWindow = 64
+)
+
+// WithGapFilling sets whether DisasmDetector scans the gaps between the
+// function starts of its first pass again (default false). Boundary-gated
+// prologues are only matched after a return, and aligned entries only
+// follow one, so a function placed after a call to a noreturn function, a
+// jump or a trap is missed when no call reaches it. In this mode every
+// run of NOP or trap padding ending on an alignment boundary inside a gap
+// of at least 32 bytes is treated as a function boundary: a prologue found
+// right after it is reported as a DetectionGapFill candidate with
+// ConfidenceLow. x86 and ARM64 code is supported.
+func WithGapFilling(enabled bool) Option {
+	return func(o *options) {
+		o.gapFilling = enabled
+	}
+}
+
+// fillGaps adds the functions recovered from the gaps between the
+// candidates in regions, whose code is in little-endian order and whose
+// architecture is set.
+func (o *options) fillGaps(candidates map[uint64]*FunctionCandidate, regions []codeSection) error {
+	starts := slices.Sorted(maps.Keys(candidates))
+	for _, region := range regions {
+		end := region.addr + uint64(len(region.code))
+		i, _ := slices.BinarySearch(starts, region.addr)
+		for lo := region.addr; lo < end; i++ {
+			hi := end
+			if i < len(starts) && starts[i] < end {
+				hi = starts[i]
+			}
+			if hi-lo >= gapFillMinSize {
+				found, err := o.gapPrologues(region.code[lo-region.addr:hi-region.addr], lo, region.arch)
+				if err != nil {
+					return err
+				}
+				for _, p := range found {
+					candidates[p.Address] = &FunctionCandidate{
+						Address:       p.Address,
+						DetectionType: DetectionGapFill,
+						PrologueType:  p.Type,
+						Confidence:    ConfidenceLow,
+						Boundary:      true,
+					}
+				}
+			}
+			if hi == end {
+				break
+			}
+			lo = hi
+		}
+	}
+	return nil
+}
+
+// gapPrologues returns the prologues found right after the padding runs of
+// the gap code, mapped at baseAddr. The padding stands in for the
+// preceding return: each run starts a scan of its own.
+func (o *options) gapPrologues(code []byte, baseAddr uint64, arch Arch) ([]Prologue, error) {
+	var ends []int
+	switch arch {
+	case ArchAMD64:
+		ends = paddingEndsAMD64(code, baseAddr, 64)
+	case ArchX86:
+		ends = paddingEndsAMD64(code, baseAddr, 32)
+	case ArchARM64:
+		ends = paddingEndsARM64(code, baseAddr)
+	}
+	var found []Prologue
+	for _, off := range ends {
+		if err := o.budget.step(off); err != nil {
+			return nil, err
+		}
+		addr := baseAddr + uint64(off)
+		prologues, err := o.detectPrologues(code[off:min(off+gapFillWindow, len(code))], addr, arch)
+		if err != nil {
+			return nil, err
+		}
+		// The strongest pattern comes first.
+		if len(prologues) > 0 && prologues[0].Address == addr {
+			found = append(found, prologues[0])
+		}
+	}
+	return found, nil
+}
+
+// paddingEndsAMD64 returns the offsets in x86 code decoded in mode, mapped
+// at baseAddr, where a run of NOP, INT3 or UD2 padding ends on an
+// alignment boundary.
+func paddingEndsAMD64(code []byte, baseAddr uint64, mode int) []int {
+	var ends []int
+	for i := 0; i < len(code); {
+		if isENDBR(code, i) {
+			i += 4
+			continue
+		}
+		inst, err := x86asm.Decode(code[i:], mode)
+		if err != nil {
+			i++
+			continue
+		}
+		if !isNOPLike(inst) && !isTrapAMD64(inst) {
+			i += inst.Len
+			continue
+		}
+		j := consumeTrapsAMD64(code, consumePaddingAMD64(code, i, mode), mode)
+		if j < len(code) && (baseAddr+uint64(j))%alignedEntryAlignment == 0 {
+			ends = append(ends, j)
+		}
+		i = j
+	}
+	return ends
+}
+
+// paddingEndsARM64 returns the offsets in ARM64 code, mapped at baseAddr,
+// where a run of NOP or BRK padding ends on an alignment boundary.
+func paddingEndsARM64(code []byte, baseAddr uint64) []int {
+	const insnLen = 4
+	var ends []int
+	for i := 0; i+insnLen <= len(code); i += insnLen {
+		inst, err := arm64asm.Decode(code[i : i+insnLen])
+		if err != nil || (inst.Op != arm64asm.NOP && inst.Op != arm64asm.BRK) {
+			continue
+		}
+		j := i
+		for {
+			k := consumePaddingARM64(code, j)
+			for k+insnLen <= len(code) && isBRKARM64(code[k:k+insnLen]) {
+				k += insnLen
+			}
+			if k == j {
+				break
+			}
+			j = k
+		}
+		if j+insnLen <= len(code) && (baseAddr+uint64(j))%alignedEntryAlignment == 0 {
+			ends = append(ends, j)
+		}
+		i = j - insnLen
+	}
+	return ends
+}
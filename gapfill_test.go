@@ -0,0 +1,68 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestWithGapFilling(t *testing.T) {
+	const base = 0x1000
+	code := []byte{
+		0x55,             // 0x1000: push rbp
+		0x48, 0x89, 0xe5, // 0x1001: mov rbp, rsp
+		0xe8, 0xf7, 0xff, 0xff, 0xff, // 0x1004: call 0x1000, never returning
+	}
+	// NOP padding up to the next function.
+	code = append(code, bytes.Repeat([]byte{0x90}, 0x20-len(code))...)
+	code = append(code,
+		0x53,             // 0x1020: push rbx
+		0x48, 0x89, 0xfb, // 0x1021: mov rbx, rdi
+		0x5b, // 0x1024: pop rbx
+		0xc3, // 0x1025: ret
+	)
+
+	tests := []struct {
+		name string
+		opts []resurgo.Option
+		want map[uint64]resurgo.DetectionType
+	}{{
+		// The push follows padding after a call, not a return.
+		name: "default",
+		want: map[uint64]resurgo.DetectionType{
+			0x1000: resurgo.DetectionPrologueCallSite,
+		},
+	}, {
+		name: "gap filling",
+		opts: []resurgo.Option{resurgo.WithGapFilling(true)},
+		want: map[uint64]resurgo.DetectionType{
+			0x1000: resurgo.DetectionPrologueCallSite,
+			0x1020: resurgo.DetectionGapFill,
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]resurgo.Option{resurgo.WithArch(resurgo.ArchAMD64), resurgo.WithBaseAddress(base)}, tt.opts...)
+			result, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(code), int64(len(code)), opts...)
+			if err != nil {
+				t.Fatalf("resurgo.DetectFunctionsFromReader: %v", err)
+			}
+			got := make(map[uint64]resurgo.FunctionCandidate)
+			for _, c := range result.Functions {
+				got[c.Address] = c
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("got %+v, want %v", result.Functions, tt.want)
+			}
+			for addr, typ := range tt.want {
+				if got[addr].DetectionType != typ {
+					t.Errorf("0x%x: got %q, want %q", addr, got[addr].DetectionType, typ)
+				}
+			}
+			if c, ok := got[0x1020]; ok && (c.Confidence != resurgo.ConfidenceLow || c.PrologueType == "") {
+				t.Errorf("0x1020: got %s/%q, want a prologue with %s", c.Confidence, c.PrologueType, resurgo.ConfidenceLow)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"fmt"
+	"io"
+)
+
+const (
+	// gnuPropertySection is the ELF section holding the GNU property note.
+	gnuPropertySection = ".note.gnu.property"
+	// ptGNUProperty is the type of the segment holding the GNU property
+	// note.
+	ptGNUProperty = elf.ProgType(0x6474e553)
+	// ntGNUPropertyType0 is the type of the GNU property note.
+	ntGNUPropertyType0 = 5
+
+	// gnuPropertyX86Feature1And and gnuPropertyAArch64Feature1And are the
+	// properties listing the control-flow protections every object of the
+	// binary was built with.
+	gnuPropertyX86Feature1And     = 0xc0000002
+	gnuPropertyAArch64Feature1And = 0xc0000000
+
+	// Bits of gnuPropertyX86Feature1And.
+	gnuPropertyX86IBT   = 1 << 0
+	gnuPropertyX86SHSTK = 1 << 1
+	// Bits of gnuPropertyAArch64Feature1And.
+	gnuPropertyAArch64BTI = 1 << 0
+	gnuPropertyAArch64PAC = 1 << 1
+)
+
+// GNUProperties holds the control-flow protections an ELF binary declares
+// in its GNU property note, which the linker only sets when every object
+// of the binary was built with them.
+type GNUProperties struct {
+	// IBT reports x86 indirect branch tracking (CET): every indirect
+	// branch target, such as an exported or address-taken function,
+	// starts with ENDBR64 or ENDBR32.
+	IBT bool `json:"ibt,omitempty"`
+	// SHSTK reports x86 shadow stack compatibility (CET).
+	SHSTK bool `json:"shstk,omitempty"`
+	// BTI reports ARM64 branch target identification: every indirect
+	// branch target starts with a BTI landing pad, or with PACIASP or
+	// PACIBSP, which are implicit ones.
+	BTI bool `json:"bti,omitempty"`
+	// PAC reports ARM64 return address signing.
+	PAC bool `json:"pac,omitempty"`
+}
+
+// ReadGNUProperties returns the control-flow protections f declares in its
+// .note.gnu.property section or, when the section headers are missing, its
+// PT_GNU_PROPERTY segment. Binaries without the note declare none.
+func ReadGNUProperties(f *elf.File) (GNUProperties, error) {
+	var notes []elfNote
+	if sec := f.Section(gnuPropertySection); sec != nil && sec.Type == elf.SHT_NOTE {
+		n, err := readNotes(f, sec)
+		if err != nil {
+			return GNUProperties{}, err
+		}
+		notes = n
+	} else if len(f.Sections) == 0 {
+		for _, p := range f.Progs {
+			if p.Type != ptGNUProperty {
+				continue
+			}
+			data := make([]byte, p.Filesz)
+			if _, err := p.ReadAt(data, 0); err != nil && err != io.EOF {
+				return GNUProperties{}, fmt.Errorf("failed to read property segment at %#x: %w", p.Off, err)
+			}
+			n, err := parseNotes(data, f.ByteOrder, "PT_GNU_PROPERTY segment")
+			if err != nil {
+				return GNUProperties{}, err
+			}
+			notes = n
+			break
+		}
+	}
+
+	// Properties are aligned to 8 bytes in 64-bit files and to 4 in 32-bit
+	// ones.
+	align := uint64(4)
+	if f.Class == elf.ELFCLASS64 {
+		align = 8
+	}
+	var props GNUProperties
+	for _, n := range notes {
+		if n.name != "GNU" || n.typ != ntGNUPropertyType0 {
+			continue
+		}
+		desc := n.desc
+		for len(desc) >= 8 {
+			typ, size := f.ByteOrder.Uint32(desc), uint64(f.ByteOrder.Uint32(desc[4:]))
+			if 8+size > uint64(len(desc)) {
+				return GNUProperties{}, fmt.Errorf("malformed property in %s", gnuPropertySection)
+			}
+			if size >= 4 {
+				bits := f.ByteOrder.Uint32(desc[8:])
+				switch {
+				case typ == gnuPropertyX86Feature1And && (f.Machine == elf.EM_X86_64 || f.Machine == elf.EM_386):
+					props.IBT = bits&gnuPropertyX86IBT != 0
+					props.SHSTK = bits&gnuPropertyX86SHSTK != 0
+				case typ == gnuPropertyAArch64Feature1And && f.Machine == elf.EM_AARCH64:
+					props.BTI = bits&gnuPropertyAArch64BTI != 0
+					props.PAC = bits&gnuPropertyAArch64PAC != 0
+				}
+			}
+			next := min((8+size+align-1)&^(align-1), uint64(len(desc)))
+			desc = desc[next:]
+		}
+	}
+	return props, nil
+}
@@ -0,0 +1,129 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestReadGNUProperties(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	tests := []struct {
+		name    string
+		flags   []string
+		noShdrs bool
+		want    resurgo.GNUProperties
+	}{
+		{
+			name:  "ibt",
+			flags: []string{"-fcf-protection=full", "-Wl,-z,ibt,-z,shstk"},
+			want:  resurgo.GNUProperties{IBT: true, SHSTK: true},
+		},
+		{
+			name:    "ibt without section headers",
+			flags:   []string{"-fcf-protection=full", "-Wl,-z,ibt,-z,shstk"},
+			noShdrs: true,
+			want:    resurgo.GNUProperties{IBT: true, SHSTK: true},
+		},
+		{
+			name:  "none",
+			flags: []string{"-fcf-protection=none"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "demo-app-c")
+			args := append([]string{"-O2", "-o", path, "testdata/demo-app.c"}, tt.flags...)
+			if out, err := exec.Command("gcc", args...).CombinedOutput(); err != nil {
+				t.Skipf("failed to compile demo-app.c: %v\n%s", err, out)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.noShdrs {
+				// Drop the section header table: e_shoff, e_shnum and
+				// e_shstrndx.
+				copy(data[0x28:], make([]byte, 8))
+				copy(data[0x3c:], make([]byte, 4))
+			}
+			f, err := elf.NewFile(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("failed to parse ELF: %v", err)
+			}
+
+			got, err := resurgo.ReadGNUProperties(f)
+			if err != nil {
+				t.Fatalf("ReadGNUProperties: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadGNUProperties = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCETFilter_IBTProperty(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	tests := []struct {
+		name  string
+		flags []string
+		want  resurgo.Confidence
+	}{
+		{name: "ibt", flags: []string{"-fcf-protection=full", "-Wl,-z,ibt"}, want: resurgo.ConfidenceMedium},
+		{name: "none", flags: []string{"-fcf-protection=none"}, want: resurgo.ConfidenceLow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "demo-app-c")
+			args := append([]string{"-O2", "-o", path, "testdata/demo-app.c"}, tt.flags...)
+			if out, err := exec.Command("gcc", args...).CombinedOutput(); err != nil {
+				t.Skipf("failed to compile demo-app.c: %v\n%s", err, out)
+			}
+			f, err := elf.Open(path)
+			if err != nil {
+				t.Fatalf("failed to open ELF: %v", err)
+			}
+			defer f.Close()
+			text := f.Section(".text")
+			code, err := text.Data()
+			if err != nil {
+				t.Fatalf("read .text: %v", err)
+			}
+
+			candidates, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithFilters(resurgo.CETFilter))
+			if err != nil {
+				t.Fatalf("DetectFunctionsFromELF: %v", err)
+			}
+			var aligned int
+			for _, c := range candidates {
+				if c.DetectionType != resurgo.DetectionAlignedEntry || c.Address == f.Entry {
+					continue
+				}
+				aligned++
+				off := c.Address - text.Addr
+				endbr := bytes.HasPrefix(code[off:], []byte{0xf3, 0x0f, 0x1e, 0xfa})
+				if tt.want == resurgo.ConfidenceMedium && !endbr {
+					t.Errorf("aligned entry 0x%x without ENDBR64 kept", c.Address)
+				}
+				if c.Confidence != tt.want {
+					t.Errorf("aligned entry 0x%x: confidence %s, want %s", c.Address, c.Confidence, tt.want)
+				}
+			}
+			if aligned == 0 {
+				t.Fatal("no aligned-entry candidates")
+			}
+		})
+	}
+}
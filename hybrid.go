@@ -0,0 +1,93 @@
+package resurgo
+
+import (
+	"cmp"
+	"debug/elf"
+	"slices"
+)
+
+// WithHybridSweep sets whether DisasmDetector claims the code reachable
+// from the known functions of the file before sweeping it (default false).
+// Recursive descent, as in RecursiveDescentDetector, first follows the
+// control flow from the entry point, exports and symbols: the known
+// functions it enters and the call targets it reaches are reported as
+// DetectionRecursiveDescent candidates with ConfidenceHigh, and the
+// instructions it decodes are claimed. The linear sweep then only scans
+// the gaps of code left unclaimed, where functions reached through
+// pointers or not at all remain. The bodies of reached functions are no
+// longer decoded out of step after embedded data, or mistaken for entries
+// at loop heads. Files of architectures recursive descent does not
+// support are swept whole.
+func WithHybridSweep(enabled bool) Option {
+	return func(o *options) {
+		o.hybridSweep = enabled
+	}
+}
+
+// detectHybrid runs recursive descent from the known functions of f over
+// regions, then the disassembly-based detection over the code it leaves
+// unclaimed.
+func (o *options) detectHybrid(f *elf.File, regions []codeSection, arch Arch) ([]FunctionCandidate, error) {
+	if !descentArch(arch) {
+		return o.detectRegions(regions, arch)
+	}
+	roots, err := descentRoots(f)
+	if err != nil {
+		return nil, err
+	}
+	calls, reached, err := o.descend(regions, arch, roots)
+	if err != nil {
+		return nil, err
+	}
+	var entered []FunctionCandidate
+	for _, root := range roots {
+		if inSortedRanges(root, reached) {
+			entered = append(entered, FunctionCandidate{
+				Address:       root,
+				DetectionType: DetectionRecursiveDescent,
+				Confidence:    ConfidenceHigh,
+			})
+		}
+	}
+	// Called roots keep their callers.
+	functions := mergeCandidates(descentCandidates(calls), entered)
+
+	swept, err := o.detectRegions(unclaimedRegions(regions, reached), arch)
+	if err != nil {
+		return nil, err
+	}
+	return mergeCandidates(functions, swept), nil
+}
+
+// unclaimedRegions returns the parts of regions outside the sorted,
+// coalesced [lo, hi) ranges of claimed, each keeping the architecture of
+// its region.
+func unclaimedRegions(regions []codeSection, claimed [][2]uint64) []codeSection {
+	var gaps []codeSection
+	for _, region := range regions {
+		lo, end := region.addr, region.addr+uint64(len(region.code))
+		gap := func(hi uint64) {
+			if hi > lo {
+				gaps = append(gaps, codeSection{code: region.code[lo-region.addr : hi-region.addr], addr: lo, arch: region.arch})
+			}
+		}
+		i, _ := slices.BinarySearchFunc(claimed, lo, func(r [2]uint64, addr uint64) int {
+			return cmp.Compare(r[1], addr+1)
+		})
+		for ; i < len(claimed) && claimed[i][0] < end; i++ {
+			gap(min(claimed[i][0], end))
+			lo = max(lo, min(claimed[i][1], end))
+		}
+		gap(end)
+	}
+	return gaps
+}
+
+// inSortedRanges reports whether addr falls within one of the sorted,
+// disjoint [lo, hi) ranges.
+func inSortedRanges(addr uint64, ranges [][2]uint64) bool {
+	i, _ := slices.BinarySearchFunc(ranges, addr, func(r [2]uint64, addr uint64) int {
+		return cmp.Compare(r[1], addr+1)
+	})
+	return i < len(ranges) && ranges[i][0] <= addr
+}
@@ -0,0 +1,67 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestWithHybridSweep(t *testing.T) {
+	if _, err := exec.LookPath("llvm-mc"); err != nil {
+		t.Skip("llvm-mc not found, skipping")
+	}
+	objPath := filepath.Join(t.TempDir(), "desync.o")
+	cmd := exec.Command("llvm-mc", "-triple=x86_64-linux-gnu", "-filetype=obj", "-o", objPath, "testdata/desync.s")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to assemble testdata/desync.s: %v\n%s", err, out)
+	}
+	f, err := elf.Open(objPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		name string
+		opts []resurgo.Option
+		want map[uint64]resurgo.DetectionType
+	}{{
+		// The stray byte decodes as a call into the body of framed.
+		name: "linear",
+		want: map[uint64]resurgo.DetectionType{
+			0x00: resurgo.DetectionPrologueOnly,
+			0x1b: resurgo.DetectionCallTarget,
+		},
+	}, {
+		// framed is followed from its symbol; only the stray byte is left
+		// to the sweep.
+		name: "hybrid",
+		opts: []resurgo.Option{resurgo.WithHybridSweep(true)},
+		want: map[uint64]resurgo.DetectionType{
+			0x00: resurgo.DetectionRecursiveDescent,
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, err := resurgo.NewDisasmDetector(tt.opts...)(f)
+			if err != nil {
+				t.Fatalf("disasm detector: %v", err)
+			}
+			got := make(map[uint64]resurgo.DetectionType)
+			for _, c := range candidates {
+				got[c.Address] = c.DetectionType
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			for addr, typ := range tt.want {
+				if got[addr] != typ {
+					t.Errorf("0x%x: got %q, want %q", addr, got[addr], typ)
+				}
+			}
+		})
+	}
+}
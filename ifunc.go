@@ -0,0 +1,282 @@
+package resurgo
+
+import (
+	"cmp"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"golang.org/x/arch/x86/x86asm"
+)
+
+const (
+	// DetectionIfuncResolver is assigned to function candidates that resolve
+	// a GNU indirect function (IFUNC) at load time.
+	DetectionIfuncResolver DetectionType = "ifunc-resolver"
+	// DetectionIfuncTarget is assigned to function candidates whose address
+	// an IFUNC resolver may return: the implementations of a multi-versioned
+	// function.
+	DetectionIfuncTarget DetectionType = "ifunc-target"
+
+	// KindIfuncResolver marks the IFUNC resolvers among the results: code
+	// run once by the dynamic loader, not by the program.
+	KindIfuncResolver FunctionKind = "ifunc-resolver"
+	// KindIfuncTarget marks the implementations an IFUNC resolver selects
+	// among, which the program only reaches through the resolved address.
+	KindIfuncTarget FunctionKind = "ifunc-target"
+
+	// maxResolverScan bounds the bytes of a resolver scanned for the
+	// addresses it returns when .eh_frame does not delimit it.
+	maxResolverScan = 512
+)
+
+// IfuncDetector is a CandidateDetector that emits the GNU indirect function
+// (IFUNC) resolvers of f and the implementations they select among.
+// Resolvers are read from R_X86_64_IRELATIVE and R_AARCH64_IRELATIVE
+// relocations and from STT_GNU_IFUNC symbols; each carries
+// DetectionIfuncResolver and ConfidenceHigh. Implementations are the code
+// addresses a resolver materializes with lea rip-relative on x86_64, or adr
+// and adrp; add on ARM64; each carries DetectionIfuncTarget and
+// ConfidenceMedium. A resolver is scanned up to the end of its FDE or, when
+// .eh_frame does not cover it, up to its first return. It is not part of the
+// default pipeline.
+func IfuncDetector(f *elf.File) ([]FunctionCandidate, error) {
+	var irelative uint32
+	switch f.Machine {
+	case elf.EM_X86_64:
+		irelative = uint32(elf.R_X86_64_IRELATIVE)
+	case elf.EM_AARCH64:
+		irelative = uint32(elf.R_AARCH64_IRELATIVE)
+	default:
+		return nil, nil
+	}
+	if f.Class != elf.ELFCLASS64 {
+		return nil, nil
+	}
+
+	var resolvers []uint64
+	err := forEachRela(f, func(_, info, addend uint64) {
+		if elf.R_TYPE64(info) == irelative {
+			resolvers = append(resolvers, addend)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read dynamic relocations: %w", err)
+	}
+	for _, read := range []func() ([]elf.Symbol, error){f.Symbols, f.DynamicSymbols} {
+		syms, err := read()
+		if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+			return nil, fmt.Errorf("read symbols: %w", err)
+		}
+		for _, s := range syms {
+			if elf.ST_TYPE(s.Info) == elf.STT_GNU_IFUNC && s.Value != 0 {
+				resolvers = append(resolvers, s.Value)
+			}
+		}
+	}
+	slices.Sort(resolvers)
+	resolvers = slices.Compact(resolvers)
+
+	fdes, err := parseEhFrameFDEs(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse .eh_frame: %w", err)
+	}
+	exec := execRanges(f)
+
+	candidates := make(map[uint64]FunctionCandidate)
+	for _, r := range resolvers {
+		sec := sectionAt(f, r)
+		if sec == nil || sec.Flags&elf.SHF_EXECINSTR == 0 {
+			continue
+		}
+		candidates[r] = FunctionCandidate{
+			Address:       r,
+			DetectionType: DetectionIfuncResolver,
+			Confidence:    ConfidenceHigh,
+		}
+
+		code, err := sec.Data()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", sec.Name, err)
+		}
+		start := r - sec.Addr
+		end := min(start+maxResolverScan, sec.Size)
+		bounded := false
+		for _, fde := range fdes {
+			if fde.start == r && fde.end > r {
+				end, bounded = min(fde.end-sec.Addr, sec.Size), true
+				break
+			}
+		}
+		var targets []uint64
+		switch f.Machine {
+		case elf.EM_X86_64:
+			targets = resolverTargetsAMD64(code[start:end], r, !bounded)
+		case elf.EM_AARCH64:
+			targets = resolverTargetsARM64(code[start:end], r, !bounded)
+		}
+		for _, t := range targets {
+			if _, ok := candidates[t]; ok || t == r || !inRanges(t, exec) {
+				continue
+			}
+			candidates[t] = FunctionCandidate{
+				Address:       t,
+				DetectionType: DetectionIfuncTarget,
+				Confidence:    ConfidenceMedium,
+			}
+		}
+	}
+
+	result := make([]FunctionCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		result = append(result, c)
+	}
+	slices.SortFunc(result, func(a, b FunctionCandidate) int {
+		return cmp.Compare(a.Address, b.Address)
+	})
+	return result, nil
+}
+
+// WithIfuncLabels sets whether DetectProloguesFromELF and
+// DetectFunctionsFromELF tag the results at the IFUNC resolvers of the file
+// and at the implementations they select among, as found by IfuncDetector,
+// with KindIfuncResolver and KindIfuncTarget (default true). Relocatable
+// objects are not tagged.
+func WithIfuncLabels(enabled bool) Option {
+	return func(o *options) {
+		o.ifuncLabels = enabled
+	}
+}
+
+// ifuncKinds returns the kind of the IFUNC resolvers and implementations of
+// f by address, or nil when they are not labeled.
+func (o *options) ifuncKinds(f *elf.File) (map[uint64]FunctionKind, error) {
+	if !o.ifuncLabels || f.Type == elf.ET_REL {
+		return nil, nil
+	}
+	found, err := IfuncDetector(f)
+	if err != nil {
+		return nil, err
+	}
+	kinds := make(map[uint64]FunctionKind, len(found))
+	for _, c := range found {
+		kinds[c.Address] = KindIfuncResolver
+		if c.DetectionType == DetectionIfuncTarget {
+			kinds[c.Address] = KindIfuncTarget
+		}
+	}
+	return kinds, nil
+}
+
+// labelIfuncs tags the candidates at the IFUNC resolvers and
+// implementations of f.
+func (o *options) labelIfuncs(candidates []FunctionCandidate, f *elf.File) error {
+	kinds, err := o.ifuncKinds(f)
+	if err != nil {
+		return err
+	}
+	for i, c := range candidates {
+		if kind, ok := kinds[c.Address]; ok {
+			candidates[i].Kind = kind
+		}
+	}
+	return nil
+}
+
+// labelIfuncPrologues tags the prologues at the IFUNC resolvers and
+// implementations of f.
+func (o *options) labelIfuncPrologues(prologues []Prologue, f *elf.File) error {
+	kinds, err := o.ifuncKinds(f)
+	if err != nil {
+		return err
+	}
+	for i, p := range prologues {
+		if kind, ok := kinds[p.Address]; ok {
+			prologues[i].Kind = kind
+		}
+	}
+	return nil
+}
+
+// resolverTargetsAMD64 returns the targets of the lea reg, [rip+disp]
+// instructions in code, the body of a resolver at baseAddr. The scan stops
+// at the first ret when stopAtRet is set.
+func resolverTargetsAMD64(code []byte, baseAddr uint64, stopAtRet bool) []uint64 {
+	var targets []uint64
+	for offset := 0; offset < len(code); {
+		if isENDBR(code, offset) {
+			offset += 4
+			continue
+		}
+		inst, err := x86asm.Decode(code[offset:], 64)
+		if err != nil {
+			offset++
+			continue
+		}
+		offset += inst.Len
+		if inst.Op == x86asm.RET && stopAtRet {
+			break
+		}
+		if inst.Op != x86asm.LEA {
+			continue
+		}
+		if mem, ok := inst.Args[1].(x86asm.Mem); ok && mem.Base == x86asm.RIP && mem.Index == 0 {
+			next := baseAddr + uint64(offset)
+			if target, ok := relTarget(next, int64(int32(mem.Disp)), false); ok {
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets
+}
+
+// resolverTargetsARM64 returns the addresses computed by adr and by adrp
+// followed by add (immediate) in code, the body of a resolver at baseAddr.
+// The scan stops at the first ret when stopAtRet is set.
+func resolverTargetsARM64(code []byte, baseAddr uint64, stopAtRet bool) []uint64 {
+	const (
+		adrMask   = uint32(0x9f000000)
+		adrValue  = uint32(0x10000000)
+		adrpValue = uint32(0x90000000)
+		// ADD (immediate), 64-bit, unshifted.
+		addMask  = uint32(0xffc00000)
+		addValue = uint32(0x91000000)
+		ret      = uint32(0xd65f03c0)
+	)
+	var targets []uint64
+	// pages maps a register to the page address adrp loaded into it.
+	pages := make(map[uint32]uint64)
+	for offset := 0; offset+4 <= len(code); offset += 4 {
+		word := binary.LittleEndian.Uint32(code[offset:])
+		pc := baseAddr + uint64(offset)
+		rd := word & 0x1f
+		switch {
+		case word == ret && stopAtRet:
+			return targets
+		case word&adrMask == adrValue, word&adrMask == adrpValue:
+			// immhi at bits 23:5, immlo at bits 30:29, sign-extended.
+			imm := int64(int32((word>>5&0x7ffff)<<2|word>>29&3) << 11 >> 11)
+			if word&adrMask == adrValue {
+				if target, ok := relTarget(pc, imm, false); ok {
+					targets = append(targets, target)
+				}
+				delete(pages, rd)
+				continue
+			}
+			if page, ok := relTarget(pc&^0xfff, imm<<12, false); ok {
+				pages[rd] = page
+			}
+		case word&addMask == addValue:
+			rn := word >> 5 & 0x1f
+			if page, ok := pages[rn]; ok {
+				targets = append(targets, page+uint64(word>>10&0xfff))
+			}
+			delete(pages, rd)
+		default:
+			delete(pages, rd)
+		}
+	}
+	return targets
+}
@@ -0,0 +1,170 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestIfuncDetector(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "ifunc.c")
+	const code = `
+#include <stdlib.h>
+static int impl_fast(int x) { return x * 2; }
+static int impl_slow(int x) { return x + x; }
+static int (*resolve_double(void))(int) { return getenv("SLOW") ? impl_slow : impl_fast; }
+int dbl(int) __attribute__((ifunc("resolve_double")));
+int main(int argc, char **argv) { return dbl(argc); }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+
+	for _, flag := range []string{"-pie", "-static"} {
+		t.Run(flag[1:], func(t *testing.T) {
+			outPath := filepath.Join(dir, "ifunc"+flag)
+			cmd := exec.Command("gcc", "-O2", flag, "-o", outPath, src)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Skipf("failed to compile ifunc.c: %v\n%s", err, out)
+			}
+			f, err := elf.Open(outPath)
+			if err != nil {
+				t.Fatalf("failed to open ELF: %v", err)
+			}
+			defer f.Close()
+
+			syms, err := f.Symbols()
+			if err != nil {
+				t.Fatalf("failed to read symbols: %v", err)
+			}
+			addrs := make(map[string]uint64)
+			for _, s := range syms {
+				switch s.Name {
+				case "resolve_double", "impl_fast", "impl_slow":
+					addrs[s.Name] = s.Value
+				}
+			}
+
+			candidates, err := resurgo.IfuncDetector(f)
+			if err != nil {
+				t.Fatalf("resurgo.IfuncDetector: %v", err)
+			}
+			got := make(map[uint64]resurgo.DetectionType)
+			for _, c := range candidates {
+				got[c.Address] = c.DetectionType
+			}
+			want := map[string]resurgo.DetectionType{
+				"resolve_double": resurgo.DetectionIfuncResolver,
+				"impl_fast":      resurgo.DetectionIfuncTarget,
+				"impl_slow":      resurgo.DetectionIfuncTarget,
+			}
+			for name, typ := range want {
+				addr, ok := addrs[name]
+				if !ok {
+					t.Fatalf("%s: symbol not found", name)
+				}
+				if got[addr] != typ {
+					t.Errorf("%s at 0x%x: expected %s, got %q", name, addr, typ, got[addr])
+				}
+			}
+		})
+	}
+}
+
+func TestWithIfuncLabels(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "ifunc.c")
+	const code = `
+#include <stdlib.h>
+static int impl_fast(int x) { return x * 2; }
+static int impl_slow(int x) { return x + x; }
+static int (*resolve_double(void))(int) { return getenv("SLOW") ? impl_slow : impl_fast; }
+int dbl(int) __attribute__((ifunc("resolve_double")));
+int main(int argc, char **argv) { return dbl(argc); }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	outPath := filepath.Join(dir, "ifunc")
+	cmd := exec.Command("gcc", "-O0", "-pie", "-o", outPath, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("failed to compile ifunc.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	want := make(map[uint64]resurgo.FunctionKind)
+	for _, s := range syms {
+		switch s.Name {
+		case "resolve_double":
+			want[s.Value] = resurgo.KindIfuncResolver
+		case "impl_fast", "impl_slow":
+			want[s.Value] = resurgo.KindIfuncTarget
+		case "main":
+			want[s.Value] = ""
+		}
+	}
+	if len(want) != 4 {
+		t.Fatalf("got %d of the 4 symbols", len(want))
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{name: "enabled", enabled: true},
+		{name: "disabled", enabled: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := resurgo.WithIfuncLabels(tt.enabled)
+			candidates, err := resurgo.DetectFunctionsFromELF(f, opt)
+			if err != nil {
+				t.Fatalf("DetectFunctionsFromELF: %v", err)
+			}
+			prologues, err := resurgo.DetectProloguesFromELF(f, opt)
+			if err != nil {
+				t.Fatalf("DetectProloguesFromELF: %v", err)
+			}
+			gotCandidates := make(map[uint64]resurgo.FunctionKind)
+			for _, c := range candidates {
+				gotCandidates[c.Address] = c.Kind
+			}
+			gotPrologues := make(map[uint64]resurgo.FunctionKind)
+			for _, p := range prologues {
+				gotPrologues[p.Address] = p.Kind
+			}
+			for addr, kind := range want {
+				if !tt.enabled {
+					kind = ""
+				}
+				if got, ok := gotCandidates[addr]; !ok || got != kind {
+					t.Errorf("candidate 0x%x: kind %q (found %t), want %q", addr, got, ok, kind)
+				}
+				if got, ok := gotPrologues[addr]; !ok || got != kind {
+					t.Errorf("prologue 0x%x: kind %q (found %t), want %q", addr, got, ok, kind)
+				}
+			}
+		})
+	}
+}
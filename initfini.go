@@ -0,0 +1,72 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"fmt"
+	"slices"
+)
+
+// DetectionInitFini is assigned to function candidates read from the
+// constructor and destructor tables of an ELF file (.preinit_array,
+// .init_array, .fini_array) or from its DT_INIT and DT_FINI dynamic tags.
+const DetectionInitFini DetectionType = "init-fini"
+
+// InitFiniDetector is a CandidateDetector that emits the static constructors
+// and destructors the dynamic loader and the C runtime call: the entries of
+// .preinit_array, .init_array and .fini_array, and the functions named by
+// DT_INIT and DT_FINI. Constructors are often tiny and lack a recognizable
+// prologue, and they are only called indirectly. Each candidate carries
+// DetectionInitFini and ConfidenceHigh. Array entries of 0 and -1, used as
+// terminators and placeholders, are skipped. It is not part of the default
+// pipeline.
+func InitFiniDetector(f *elf.File) ([]FunctionCandidate, error) {
+	if f.Class != elf.ELFCLASS64 {
+		return nil, nil
+	}
+	relocs, err := dynamicRelocs(f)
+	if err != nil {
+		return nil, fmt.Errorf("read dynamic relocations: %w", err)
+	}
+
+	var addrs []uint64
+	for _, sec := range f.Sections {
+		switch sec.Type {
+		case elf.SHT_PREINIT_ARRAY, elf.SHT_INIT_ARRAY, elf.SHT_FINI_ARRAY:
+		default:
+			continue
+		}
+		words, err := sectionWords(f, sec, relocs)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range words {
+			if !w.symbolic && w.value != 0 && w.value != ^uint64(0) {
+				addrs = append(addrs, w.value)
+			}
+		}
+	}
+	for _, tag := range []elf.DynTag{elf.DT_INIT, elf.DT_FINI} {
+		vals, err := f.DynValue(tag)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", tag, err)
+		}
+		addrs = append(addrs, vals...)
+	}
+
+	// Only addresses inside executable sections are function entries.
+	exec := execRanges(f)
+	slices.Sort(addrs)
+	addrs = slices.Compact(addrs)
+	var candidates []FunctionCandidate
+	for _, addr := range addrs {
+		if !inRanges(addr, exec) {
+			continue
+		}
+		candidates = append(candidates, FunctionCandidate{
+			Address:       addr,
+			DetectionType: DetectionInitFini,
+			Confidence:    ConfidenceHigh,
+		})
+	}
+	return candidates, nil
+}
@@ -0,0 +1,76 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestInitFiniDetector(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "ctors.c")
+	const code = `
+volatile int sink;
+__attribute__((constructor)) static void setup(void) { sink = 1; }
+__attribute__((destructor)) static void teardown(void) { sink = 2; }
+static void early(int argc, char **argv, char **envp) { sink = 3; }
+__attribute__((section(".preinit_array"), used)) static void (*preinit)(int, char **, char **) = early;
+int main(void) { return sink; }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+
+	for _, flag := range []string{"-pie", "-no-pie"} {
+		t.Run(flag[1:], func(t *testing.T) {
+			outPath := filepath.Join(dir, "ctors"+flag)
+			cmd := exec.Command("gcc", "-O2", flag, "-o", outPath, src)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("failed to compile ctors.c: %v\n%s", err, out)
+			}
+			f, err := elf.Open(outPath)
+			if err != nil {
+				t.Fatalf("failed to open ELF: %v", err)
+			}
+			defer f.Close()
+
+			syms, err := f.Symbols()
+			if err != nil {
+				t.Fatalf("failed to read symbols: %v", err)
+			}
+			want := map[string]uint64{"setup": 0, "teardown": 0, "early": 0, "_init": 0, "_fini": 0}
+			for _, s := range syms {
+				if _, ok := want[s.Name]; ok && elf.ST_TYPE(s.Info) == elf.STT_FUNC {
+					want[s.Name] = s.Value
+				}
+			}
+
+			candidates, err := resurgo.InitFiniDetector(f)
+			if err != nil {
+				t.Fatalf("resurgo.InitFiniDetector: %v", err)
+			}
+			got := make(map[uint64]bool)
+			for _, c := range candidates {
+				if c.DetectionType != resurgo.DetectionInitFini || c.Confidence != resurgo.ConfidenceHigh {
+					t.Errorf("candidate 0x%x: unexpected %s/%s", c.Address, c.DetectionType, c.Confidence)
+				}
+				got[c.Address] = true
+			}
+			for name, addr := range want {
+				if addr == 0 {
+					t.Errorf("%s: symbol not found", name)
+				} else if !got[addr] {
+					t.Errorf("%s at 0x%x not detected", name, addr)
+				}
+			}
+		})
+	}
+}
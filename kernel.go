@@ -0,0 +1,164 @@
+package resurgo
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Container formats wrapping another binary, reported with a single member
+// holding the result of the wrapped binary.
+const (
+	// FormatBzImage is a Linux x86 boot image (bzImage, vmlinuz), whose
+	// compressed payload is the vmlinux ELF image. The member is named
+	// "vmlinux".
+	FormatBzImage Format = "bzimage"
+	// FormatCompressed is a gzip or bzip2 stream, e.g. a vmlinux.gz or an
+	// arm64 Image.gz. The member is named after the original file name
+	// recorded by gzip, if any.
+	FormatCompressed Format = "compressed"
+)
+
+// maxCompressedDepth bounds the nesting of compressed streams, e.g. a
+// gzip-compressed tar archive wrapped in another compression.
+const maxCompressedDepth = 2
+
+// maxDecompressedSize bounds the size of decompressed payloads, which is not
+// known before they are decompressed, and of the archive members read into
+// memory.
+const maxDecompressedSize = 1 << 30
+
+// bzImage setup header fields, at their offsets in the boot image.
+const (
+	bzSetupSects    = 0x1f1
+	bzHeaderMagic   = 0x202
+	bzVersion       = 0x206
+	bzPayloadOffset = 0x248
+	bzPayloadLength = 0x24c
+	// bzPayloadVersion is the first boot protocol version (2.08) that
+	// describes the compressed payload in the setup header.
+	bzPayloadVersion = 0x0208
+)
+
+// isBzImage reports whether r holds a Linux x86 boot image, which carries
+// "HdrS" in its setup header. EFI stub kernels also start with "MZ", so this
+// is checked before PE.
+func isBzImage(r io.ReaderAt) bool {
+	magic := make([]byte, 4)
+	_, err := r.ReadAt(magic, bzHeaderMagic)
+	return err == nil && string(magic) == "HdrS"
+}
+
+// detectBzImage decompresses the vmlinux payload of the boot image read
+// from r and detects its functions.
+func detectBzImage(r io.ReaderAt, opts ...Option) (*AnalysisResult, error) {
+	hdr := make([]byte, bzPayloadLength+4)
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return nil, fmt.Errorf("read bzImage setup header: %w", err)
+	}
+	if v := binary.LittleEndian.Uint16(hdr[bzVersion:]); v < bzPayloadVersion {
+		return nil, fmt.Errorf("bzImage boot protocol %d.%02d predates payload information", v>>8, v&0xff)
+	}
+	// The protected-mode kernel follows the boot sector and the setup
+	// sectors; zero setup sectors means four.
+	setupSects := int64(hdr[bzSetupSects])
+	if setupSects == 0 {
+		setupSects = 4
+	}
+	offset := (setupSects+1)*512 + int64(binary.LittleEndian.Uint32(hdr[bzPayloadOffset:]))
+	length := int64(binary.LittleEndian.Uint32(hdr[bzPayloadLength:]))
+
+	data, _, err := decompress(io.NewSectionReader(r, offset, length))
+	if err != nil {
+		return nil, fmt.Errorf("bzImage payload: %w", err)
+	}
+	member, err := detectELF(bytes.NewReader(data), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("bzImage payload: %w", err)
+	}
+	member.Name = "vmlinux"
+	return &AnalysisResult{Format: FormatBzImage, Members: []AnalysisResult{*member}}, nil
+}
+
+// isCompressed reports whether magic starts a gzip or bzip2 stream.
+func isCompressed(magic []byte) bool {
+	return bytes.HasPrefix(magic, []byte("\x1f\x8b")) ||
+		len(magic) >= 4 && bytes.HasPrefix(magic, []byte("BZh")) && magic[3] >= '1' && magic[3] <= '9'
+}
+
+// detectCompressed decompresses the size-byte stream read from r and
+// detects the functions of its content, whatever its format. It is an error
+// if the stream is nested in more than maxCompressedDepth compressed
+// streams.
+func detectCompressed(r io.ReaderAt, size int64, opts ...Option) (*AnalysisResult, error) {
+	depth := newOptions(opts...).compressedDepth + 1
+	if depth > maxCompressedDepth {
+		return nil, fmt.Errorf("compressed data nested more than %d levels deep", maxCompressedDepth)
+	}
+	data, name, err := decompress(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	opts = append(slices.Clone(opts), func(o *options) {
+		o.compressedDepth = depth
+	})
+	member, err := DetectFunctionsFromReader(bytes.NewReader(data), int64(len(data)), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("decompressed data: %w", err)
+	}
+	member.Name = name
+	return &AnalysisResult{Format: FormatCompressed, Members: []AnalysisResult{*member}}, nil
+}
+
+// decompress returns the content of the compressed stream read from r and
+// the original file name recorded in gzip headers. Only gzip and bzip2 are
+// supported; the other compressions of Linux boot images are named in the
+// error.
+func decompress(r io.ReadSeeker) ([]byte, string, error) {
+	magic := make([]byte, 6)
+	n, _ := io.ReadFull(r, magic)
+	magic = magic[:n]
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+
+	var (
+		zr   io.Reader
+		name string
+	)
+	switch {
+	case bytes.HasPrefix(magic, []byte("\x1f\x8b")):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, "", fmt.Errorf("gzip: %w", err)
+		}
+		zr, name = gz, gz.Name
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		zr = bzip2.NewReader(r)
+	case bytes.HasPrefix(magic, []byte("\xfd7zXZ\x00")):
+		return nil, "", fmt.Errorf("unsupported compression: xz")
+	case bytes.HasPrefix(magic, []byte("\x28\xb5\x2f\xfd")):
+		return nil, "", fmt.Errorf("unsupported compression: zstd")
+	case bytes.HasPrefix(magic, []byte("\x04\x22\x4d\x18")), bytes.HasPrefix(magic, []byte("\x02\x21\x4c\x18")):
+		return nil, "", fmt.Errorf("unsupported compression: lz4")
+	case bytes.HasPrefix(magic, []byte("\x89LZO")):
+		return nil, "", fmt.Errorf("unsupported compression: lzo")
+	case bytes.HasPrefix(magic, []byte("\x5d\x00\x00")):
+		return nil, "", fmt.Errorf("unsupported compression: lzma")
+	default:
+		return nil, "", fmt.Errorf("unknown compression")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(zr, maxDecompressedSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("decompress: %w", err)
+	}
+	if len(data) > maxDecompressedSize {
+		return nil, "", fmt.Errorf("decompressed data exceeds %d bytes", maxDecompressedSize)
+	}
+	return data, name, nil
+}
@@ -0,0 +1,99 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+	"github.com/maxgio92/resurgo/resurgotest"
+)
+
+// bzImage wraps payload in the setup header of a Linux x86 boot image with
+// one setup sector.
+func bzImage(payload []byte) []byte {
+	image := make([]byte, 2*512, 2*512+len(payload))
+	image[0x1f1] = 1                                     // setup_sects
+	copy(image[0x202:], "HdrS")                          // header magic
+	binary.LittleEndian.PutUint16(image[0x206:], 0x020f) // boot protocol 2.15
+	binary.LittleEndian.PutUint32(image[0x24c:], uint32(len(payload)))
+	return append(image, payload...)
+}
+
+func TestDetectFunctionsFromReader_Kernel(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "hello.go")
+	if err := os.WriteFile(src, []byte("package main\n\nfunc main() { println(\"hello\") }\n"), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	path := filepath.Join(dir, "vmlinux")
+	resurgotest.CompileGo(t, src, path, "GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0")
+	vmlinux, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Name = "vmlinux"
+	if _, err := zw.Write(vmlinux); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		input  []byte
+		format resurgo.Format
+	}{
+		{name: "bzImage", input: bzImage(gz.Bytes()), format: resurgo.FormatBzImage},
+		{name: "gzip", input: gz.Bytes(), format: resurgo.FormatCompressed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(tt.input), int64(len(tt.input)))
+			if err != nil {
+				t.Fatalf("resurgo.DetectFunctionsFromReader: %v", err)
+			}
+			if result.Format != tt.format || len(result.Members) != 1 {
+				t.Fatalf("got %s with %d members, want %s with 1", result.Format, len(result.Members), tt.format)
+			}
+			m := result.Members[0]
+			if m.Name != "vmlinux" || m.Format != resurgo.FormatELF || m.Arch != resurgo.ArchAMD64 {
+				t.Errorf("got member %s %s/%s, want vmlinux %s/%s", m.Name, m.Format, m.Arch, resurgo.FormatELF, resurgo.ArchAMD64)
+			}
+			if len(m.Functions) == 0 {
+				t.Error("no functions detected")
+			}
+		})
+	}
+
+	t.Run("nested gzip", func(t *testing.T) {
+		nested := gz.Bytes()
+		for range 2 {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			if _, err := zw.Write(nested); err != nil {
+				t.Fatal(err)
+			}
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			nested = buf.Bytes()
+		}
+		if _, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(nested), int64(len(nested))); err == nil {
+			t.Error("expected an error for gzip streams nested three levels deep")
+		}
+	})
+
+	t.Run("xz payload", func(t *testing.T) {
+		image := bzImage([]byte("\xfd7zXZ\x00\x00\x04"))
+		if _, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(image), int64(len(image))); err == nil {
+			t.Error("expected an error for an xz-compressed payload")
+		}
+	})
+}
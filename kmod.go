@@ -0,0 +1,82 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// PrologueFentry is the ftrace hook of x86-64 code built with -pg -mfentry,
+// e.g. the Linux kernel and its modules: a call to __fentry__ as the first
+// instruction of the function, after its endbr64 if any. It is only
+// recognized in relocatable objects, where the call target is named by a
+// relocation; linked code calls an address, and the kernel patches the
+// call into a nop when it loads the code.
+const PrologueFentry PrologueType = "fentry"
+
+const (
+	// fentrySymbol is the ftrace entry hook called by -mfentry code.
+	fentrySymbol = "__fentry__"
+	// altInstrReplacement holds the replacement instructions the kernel
+	// patches into code through its alternatives mechanism. They are
+	// fragments of other functions, not functions.
+	altInstrReplacement = ".altinstr_replacement"
+)
+
+// fentryPrologues returns the PrologueFentry prologues of sec, read into
+// code, in the relocatable x86-64 object f with symbol table syms. The
+// calls are found through the relocations against __fentry__, in both the
+// direct (call rel32) and the GOT-indirect (call [rip+disp32]) forms.
+func fentryPrologues(f *elf.File, sec *elf.Section, code []byte, syms []elf.Symbol) ([]Prologue, error) {
+	const relaSize = 24 // Elf64_Rela: r_offset, r_info, r_addend
+	idx := uint32(slices.Index(f.Sections, sec))
+	var prologues []Prologue
+	for _, rela := range f.Sections {
+		if rela.Type != elf.SHT_RELA || rela.Info != idx {
+			continue
+		}
+		data, err := sectionData(rela)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", rela.Name, err)
+		}
+		for off := 0; off+relaSize <= len(data); off += relaSize {
+			site := f.ByteOrder.Uint64(data[off:])
+			sym := elf.R_SYM64(f.ByteOrder.Uint64(data[off+8:]))
+			// Symbols omits the null symbol at index 0.
+			if sym == 0 || int(sym) > len(syms) || syms[sym-1].Name != fentrySymbol || site > uint64(len(code)) {
+				continue
+			}
+			var call int
+			switch r := int(site); {
+			case r >= 1 && code[r-1] == 0xe8:
+				call = r - 1
+			case r >= 2 && code[r-2] == 0xff && code[r-1] == 0x15:
+				call = r - 2
+			default:
+				continue
+			}
+			entry, insns := call, "call "+fentrySymbol
+			if call >= 4 && isENDBR(code, call-4) {
+				entry, insns = call-4, "endbr64; "+insns
+			}
+			prologues = append(prologues, Prologue{
+				Address:      sec.Addr + uint64(entry),
+				Type:         PrologueFentry,
+				Instructions: insns,
+				Size:         site + 4 - uint64(entry),
+			})
+		}
+	}
+	return prologues, nil
+}
+
+// objectSymbols returns the symbol table of the relocatable object f, or
+// nil if it has none.
+func objectSymbols(f *elf.File) ([]elf.Symbol, error) {
+	syms, err := f.Symbols()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return nil, fmt.Errorf("read symbols: %w", err)
+	}
+	return syms, nil
+}
@@ -0,0 +1,155 @@
+package resurgo
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+const (
+	// Resource limits reported by LimitError.
+	LimitCodeSize         LimitKind = "code-size"
+	LimitResults          LimitKind = "results"
+	LimitDecodeIterations LimitKind = "decode-iterations"
+	LimitTimeout          LimitKind = "timeout"
+
+	// deadlineCheckInterval is the number of decode iterations between two
+	// reads of the clock.
+	deadlineCheckInterval = 1024
+)
+
+// LimitKind identifies a resource limit.
+type LimitKind string
+
+// Limits bounds the resources an analysis may consume. Zero fields are
+// unlimited.
+type Limits struct {
+	// MaxCodeSize is the largest input, in bytes, that is decoded. For ELF
+	// files it applies to the size of .text, checked before it is read.
+	MaxCodeSize uint64
+	// MaxResults is the largest number of prologues, call sites, data
+	// regions or candidates a single stage may produce.
+	MaxResults int
+	// MaxDecodeIterations is the largest number of scanner iterations,
+	// summed over all stages. Each iteration decodes one instruction.
+	MaxDecodeIterations int
+	// Timeout bounds the wall-clock time of the analysis.
+	Timeout time.Duration
+}
+
+// LimitError is returned when an analysis exceeds one of its Limits. The
+// partial results are discarded. Use errors.As to tell it apart from
+// malformed input.
+type LimitError struct {
+	// Kind is the exceeded limit.
+	Kind LimitKind
+	// Max is the configured value: bytes for LimitCodeSize, a count for
+	// LimitResults and LimitDecodeIterations, nanoseconds for LimitTimeout.
+	Max uint64
+}
+
+func (e *LimitError) Error() string {
+	switch e.Kind {
+	case LimitCodeSize:
+		return fmt.Sprintf("code size limit exceeded: %d bytes", e.Max)
+	case LimitTimeout:
+		return fmt.Sprintf("timeout exceeded: %s", time.Duration(e.Max))
+	}
+	return fmt.Sprintf("%s limit exceeded: %d", e.Kind, e.Max)
+}
+
+// WithLimits bounds the resources used by the disassembly-based analysis,
+// so that crafted or corrupt input cannot exhaust memory or stall the
+// caller. When a limit is exceeded the analysis stops with a *LimitError.
+// By default no limits apply.
+func WithLimits(l Limits) Option {
+	return func(o *options) {
+		o.limits = l
+	}
+}
+
+// budget tracks the resources consumed by one analysis against its limits
+// and paces it according to its throttle. A nil budget is unlimited.
+type budget struct {
+	limits   Limits
+	steps    int
+	deadline time.Time
+
+	throttle Throttle
+	pacer    pacer
+}
+
+// newBudget starts a budget for l and t, or returns nil when neither sets
+// a limit.
+func newBudget(l Limits, t Throttle) *budget {
+	if l == (Limits{}) && !t.enabled() {
+		return nil
+	}
+	b := &budget{limits: l, throttle: t}
+	now := time.Now()
+	if l.Timeout > 0 {
+		b.deadline = now.Add(l.Timeout)
+	}
+	b.pacer.start = now
+	return b
+}
+
+// withBudget returns o with a budget attached, starting one when the
+// caller has not.
+func (o *options) withBudget() *options {
+	if o.budget != nil {
+		return o
+	}
+	started := *o
+	started.budget = newBudget(o.limits, o.throttle)
+	return &started
+}
+
+// step accounts for one decode iteration at byte offset pos of the code
+// being scanned.
+func (b *budget) step(pos int) error {
+	if b == nil {
+		return nil
+	}
+	b.steps++
+	b.pacer.advance(pos)
+	if limit := b.limits.MaxDecodeIterations; limit > 0 && b.steps > limit {
+		return &LimitError{Kind: LimitDecodeIterations, Max: uint64(limit)}
+	}
+	if n := b.throttle.YieldEvery; n > 0 && b.steps%n == 0 {
+		runtime.Gosched()
+	}
+	if b.steps%deadlineCheckInterval == 0 {
+		b.pace()
+		return b.expired()
+	}
+	return nil
+}
+
+// expired returns a *LimitError once the deadline has passed.
+func (b *budget) expired() error {
+	if b == nil || b.deadline.IsZero() || time.Now().Before(b.deadline) {
+		return nil
+	}
+	return &LimitError{Kind: LimitTimeout, Max: uint64(b.limits.Timeout)}
+}
+
+// codeSize checks an input of n bytes against MaxCodeSize.
+func (b *budget) codeSize(n uint64) error {
+	if b == nil || b.limits.MaxCodeSize == 0 || n <= b.limits.MaxCodeSize {
+		return nil
+	}
+	return &LimitError{Kind: LimitCodeSize, Max: b.limits.MaxCodeSize}
+}
+
+// results checks a stage output of n items against MaxResults, and the
+// deadline.
+func (b *budget) results(n int) error {
+	if b == nil {
+		return nil
+	}
+	if limit := b.limits.MaxResults; limit > 0 && n > limit {
+		return &LimitError{Kind: LimitResults, Max: uint64(limit)}
+	}
+	return b.expired()
+}
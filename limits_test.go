@@ -0,0 +1,91 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestWithLimits(t *testing.T) {
+	// push rbp; mov rbp, rsp; call +0; ret - repeated.
+	code := bytes.Repeat([]byte{0x55, 0x48, 0x89, 0xe5, 0xe8, 0x00, 0x00, 0x00, 0x00, 0xc3}, 64)
+
+	tests := []struct {
+		name   string
+		detect func(opts ...resurgo.Option) error
+		limits resurgo.Limits
+		want   resurgo.LimitKind
+	}{{
+		name: "within-limits",
+		detect: func(opts ...resurgo.Option) error {
+			_, err := resurgo.DetectPrologues(code, 0, resurgo.ArchAMD64, opts...)
+			return err
+		},
+		limits: resurgo.Limits{MaxCodeSize: 640, MaxResults: 128, MaxDecodeIterations: 256, Timeout: time.Minute},
+	}, {
+		name: "code-size",
+		detect: func(opts ...resurgo.Option) error {
+			_, err := resurgo.DetectPrologues(code, 0, resurgo.ArchAMD64, opts...)
+			return err
+		},
+		limits: resurgo.Limits{MaxCodeSize: 639},
+		want:   resurgo.LimitCodeSize,
+	}, {
+		name: "results",
+		detect: func(opts ...resurgo.Option) error {
+			_, err := resurgo.DetectCallSites(code, 0, resurgo.ArchAMD64, opts...)
+			return err
+		},
+		limits: resurgo.Limits{MaxResults: 63},
+		want:   resurgo.LimitResults,
+	}, {
+		name: "decode-iterations",
+		detect: func(opts ...resurgo.Option) error {
+			_, err := resurgo.DetectDataRegions(code, 0, resurgo.ArchAMD64, opts...)
+			return err
+		},
+		limits: resurgo.Limits{MaxDecodeIterations: 255},
+		want:   resurgo.LimitDecodeIterations,
+	}, {
+		name: "timeout",
+		detect: func(opts ...resurgo.Option) error {
+			exe, err := os.Executable()
+			if err != nil {
+				t.Fatalf("os.Executable: %v", err)
+			}
+			f, err := elf.Open(exe)
+			if err != nil {
+				t.Fatalf("elf.Open: %v", err)
+			}
+			defer f.Close()
+			_, err = resurgo.DetectFunctionsFromELF(f, opts...)
+			return err
+		},
+		limits: resurgo.Limits{Timeout: time.Nanosecond},
+		want:   resurgo.LimitTimeout,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.detect(resurgo.WithLimits(tt.limits))
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var limitErr *resurgo.LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("expected *LimitError, got %v", err)
+			}
+			if limitErr.Kind != tt.want {
+				t.Errorf("expected %s limit, got %s", tt.want, limitErr.Kind)
+			}
+		})
+	}
+}
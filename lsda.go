@@ -0,0 +1,189 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+)
+
+// ehPeULEB128 is the DW_EH_PE_uleb128 format, the call-site encoding GCC
+// and LLVM use in LSDAs.
+const ehPeULEB128 = byte(0x01)
+
+// LandingPadFilter removes candidates that sit at an exception landing pad.
+// Landing pads are the cleanup and catch blocks the unwinder transfers
+// control to when an exception or a Rust panic unwinds through a function:
+// they are never called, often start right after a call to a noreturn
+// function and are easily mistaken for function entries. They are read from
+// the call-site tables of the LSDAs (.gcc_except_table) referenced by
+// .eh_frame. Addresses that start an FDE are kept: GCC places the cold part
+// of a function, which may begin with a landing pad, under its own FDE.
+// When .eh_frame or the LSDAs are absent the slice is returned unchanged.
+// It is not part of the default pipeline, whose EhFrameFilter already
+// discards addresses that do not start an FDE.
+func LandingPadFilter(candidates []FunctionCandidate, f *elf.File) ([]FunctionCandidate, error) {
+	fdes, err := parseEhFrameFDEs(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse .eh_frame: %w", err)
+	}
+	pads, err := landingPads(f, fdes)
+	if err != nil {
+		return nil, err
+	}
+	if len(pads) == 0 {
+		return candidates, nil
+	}
+	for _, fde := range fdes {
+		delete(pads, fde.start)
+	}
+
+	filtered := candidates[:0]
+	for _, c := range candidates {
+		if _, ok := pads[c.Address]; !ok {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered, nil
+}
+
+// landingPads returns the landing pad addresses listed in the LSDAs of
+// fdes. LSDAs outside the sections of f and malformed call-site tables are
+// skipped.
+func landingPads(f *elf.File, fdes []fdeInfo) (map[uint64]struct{}, error) {
+	ptrSize := 4
+	if f.Class == elf.ELFCLASS64 {
+		ptrSize = 8
+	}
+	pads := make(map[uint64]struct{})
+	// Section data is read once, on the first LSDA it holds.
+	cache := make(map[*elf.Section][]byte)
+	for _, fde := range fdes {
+		if fde.lsda == 0 {
+			continue
+		}
+		sec := sectionAt(f, fde.lsda)
+		if sec == nil {
+			continue
+		}
+		data, ok := cache[sec]
+		if !ok {
+			var err error
+			data, err = sectionData(sec)
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", sec.Name, err)
+			}
+			cache[sec] = data
+		}
+		for _, pad := range parseLSDA(data, int(fde.lsda-sec.Addr), sec.Addr, fde.start, f.ByteOrder, ptrSize) {
+			pads[pad] = struct{}{}
+		}
+	}
+	return pads, nil
+}
+
+// sectionAt returns the allocated section of f holding addr, or nil.
+func sectionAt(f *elf.File, addr uint64) *elf.Section {
+	for _, sec := range f.Sections {
+		if sec.Flags&elf.SHF_ALLOC != 0 && sec.Type != elf.SHT_NOBITS &&
+			addr >= sec.Addr && addr < sec.Addr+sec.Size {
+			return sec
+		}
+	}
+	return nil
+}
+
+// parseLSDA decodes the LSDA at data[off] and returns the absolute
+// addresses of its landing pads. secAddr is the address of data[0];
+// funcStart is the start of the FDE owning the LSDA, the default base of
+// landing pad offsets.
+//
+// The LSDA header is:
+//
+//	lpstart encoding, [lpstart]
+//	ttype encoding, [ttype offset (ULEB128)]
+//	call-site encoding, call-site table length (ULEB128)
+//
+// followed by call-site records of start, length, landing pad and action.
+// A zero landing pad means the call site has none.
+func parseLSDA(data []byte, off int, secAddr, funcStart uint64, bo binary.ByteOrder, ptrSize int) []uint64 {
+	if off < 0 || off >= len(data) {
+		return nil
+	}
+	lpStart := funcStart
+	lpStartEnc := data[off]
+	off++
+	if lpStartEnc != ehPeOmit {
+		v, ok := decodeFDEInitialLocation(data, off, secAddr, lpStartEnc, bo, ptrSize)
+		if !ok {
+			return nil
+		}
+		lpStart = v
+		off += encodedValueSize(lpStartEnc, ptrSize)
+	}
+	if off >= len(data) {
+		return nil
+	}
+	ttypeEnc := data[off]
+	off++
+	if ttypeEnc != ehPeOmit {
+		_, n := readULEB128(data, off)
+		if n < 0 {
+			return nil
+		}
+		off += n
+	}
+	if off >= len(data) {
+		return nil
+	}
+	csEnc := data[off]
+	off++
+	csLen, n := readULEB128(data, off)
+	if n < 0 {
+		return nil
+	}
+	off += n
+	end := off + int(csLen)
+	if end > len(data) || end < off {
+		return nil
+	}
+
+	// read decodes one call-site field.
+	read := func() (uint64, bool) {
+		if csEnc&0x0f == ehPeULEB128 {
+			v, n := readULEB128(data[:end], off)
+			if n < 0 {
+				return 0, false
+			}
+			off += n
+			return v, true
+		}
+		v, ok := readEncodedValue(data[:end], off, csEnc&0x0f, bo, ptrSize)
+		if !ok {
+			return 0, false
+		}
+		off += encodedValueSize(csEnc, ptrSize)
+		return v, true
+	}
+
+	var pads []uint64
+	for off < end {
+		// start, length, landing pad, then the action as ULEB128.
+		var fields [3]uint64
+		for i := range fields {
+			v, ok := read()
+			if !ok {
+				return pads
+			}
+			fields[i] = v
+		}
+		_, n := readULEB128(data[:end], off)
+		if n < 0 {
+			return pads
+		}
+		off += n
+		if lp := fields[2]; lp != 0 {
+			pads = append(pads, lpStart+lp)
+		}
+	}
+	return pads
+}
@@ -0,0 +1,196 @@
+package resurgo
+
+import (
+	"debug/macho"
+	"fmt"
+)
+
+const (
+	// DetectionObjCMethod is assigned to function candidates whose entry
+	// address is the implementation of a method listed in the
+	// __objc_methlist section of a Mach-O file. The Objective-C runtime
+	// reads these lists to build its method tables, so strip leaves them in
+	// place.
+	DetectionObjCMethod DetectionType = "objc-method"
+	// DetectionSwiftMetadata is assigned to function candidates whose entry
+	// address is the metadata access function of a Swift nominal type
+	// listed in the __swift5_types section of a Mach-O file.
+	DetectionSwiftMetadata DetectionType = "swift-metadata"
+)
+
+const (
+	// objcMethodListRelative flags the method lists whose entries are
+	// 32-bit offsets relative to each field rather than pointers.
+	objcMethodListRelative = 0x80000000
+	// objcMethodListEntsizeMask extracts the entry size from the first
+	// word of a method list.
+	objcMethodListEntsizeMask = 0x0000fffc
+	// objcRelativeMethodSize is the size of a relative method entry: the
+	// name, types and implementation offsets.
+	objcRelativeMethodSize = 12
+
+	// Swift context descriptor kinds of the nominal types, in the low five
+	// bits of the descriptor flags.
+	swiftKindClass  = 16
+	swiftKindStruct = 17
+	swiftKindEnum   = 18
+	// swiftAccessFunctionOffset is the offset of the access function in a
+	// nominal type descriptor, after the flags, parent and name.
+	swiftAccessFunctionOffset = 12
+
+	// machOZeroFill is the S_ZEROFILL section type, which has no contents
+	// in the file.
+	machOZeroFill = 0x1
+	// Section attributes flagging the sections holding instructions.
+	machOPureInstructions = 0x80000000
+	machOSomeInstructions = 0x400
+)
+
+// MachOMetadataDetector returns a ConfidenceHigh candidate at every function
+// entry enumerated by the Objective-C and Swift metadata of the Mach-O file
+// f: the implementation of every method of the relative method lists in
+// __objc_methlist, as DetectionObjCMethod, and the metadata access function
+// of every class, struct and enum listed in __swift5_types, as
+// DetectionSwiftMetadata. The runtimes read this metadata, so the Apple
+// toolchains always emit it and strip leaves it in place: on Apple
+// platforms it enumerates function entries far more reliably than prologue
+// heuristics. Entries outside the sections holding instructions are
+// dropped.
+func MachOMetadataDetector(f *macho.File) ([]FunctionCandidate, error) {
+	methods, err := objcMethodCandidates(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse __objc_methlist: %w", err)
+	}
+	types, err := swiftTypeCandidates(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse __swift5_types: %w", err)
+	}
+	var candidates []FunctionCandidate
+	for _, c := range append(methods, types...) {
+		if machOInCode(f, c.Address) {
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates, nil
+}
+
+// objcMethodCandidates returns a ConfidenceHigh candidate at the
+// implementation of every method of the relative method lists in the
+// __objc_methlist section of the Mach-O file f. Each list starts with its
+// entry size and flags and its entry count; each entry holds the offsets of
+// the method name, types and implementation from the field holding them.
+// Lists of pointers, which chained fixups encode, are skipped. Files without
+// the section yield no candidates.
+func objcMethodCandidates(f *macho.File) ([]FunctionCandidate, error) {
+	sec := f.Section("__objc_methlist")
+	if sec == nil {
+		return nil, nil
+	}
+	data, err := sec.Data()
+	if err != nil {
+		return nil, fmt.Errorf("read __objc_methlist: %w", err)
+	}
+
+	var candidates []FunctionCandidate
+	for off := uint64(0); off+8 <= uint64(len(data)); {
+		flags := f.ByteOrder.Uint32(data[off:])
+		count := uint64(f.ByteOrder.Uint32(data[off+4:]))
+		entsize := uint64(flags & objcMethodListEntsizeMask)
+		if entsize == 0 || count > (uint64(len(data))-off-8)/entsize {
+			return nil, fmt.Errorf("malformed method list at %#x", sec.Addr+off)
+		}
+		off += 8
+		if flags&objcMethodListRelative == 0 || entsize < objcRelativeMethodSize {
+			off += count * entsize
+			continue
+		}
+		for range count {
+			field := off + 8
+			rel := int64(int32(f.ByteOrder.Uint32(data[field:])))
+			if imp, ok := relTarget(sec.Addr+field, rel, false); ok && rel != 0 {
+				candidates = append(candidates, FunctionCandidate{
+					Address:       imp,
+					DetectionType: DetectionObjCMethod,
+					Confidence:    ConfidenceHigh,
+				})
+			}
+			off += entsize
+		}
+	}
+	return candidates, nil
+}
+
+// swiftTypeCandidates returns a ConfidenceHigh candidate at the metadata
+// access function of every class, struct and enum listed in the
+// __swift5_types section of the Mach-O file f. The section holds 32-bit
+// offsets to the type context descriptors, relative to each entry; the
+// descriptor holds the offset of the access function, relative to the
+// field. Entries referencing their descriptor indirectly are skipped.
+// Files without the section yield no candidates.
+func swiftTypeCandidates(f *macho.File) ([]FunctionCandidate, error) {
+	sec := f.Section("__swift5_types")
+	if sec == nil {
+		return nil, nil
+	}
+	data, err := sec.Data()
+	if err != nil {
+		return nil, fmt.Errorf("read __swift5_types: %w", err)
+	}
+
+	var candidates []FunctionCandidate
+	for off := uint64(0); off+4 <= uint64(len(data)); off += 4 {
+		rel := int32(f.ByteOrder.Uint32(data[off:]))
+		// The low two bits select a direct or an indirect reference.
+		if rel&3 != 0 {
+			continue
+		}
+		desc, ok := relTarget(sec.Addr+off, int64(rel), false)
+		if !ok {
+			continue
+		}
+		var fields [swiftAccessFunctionOffset + 4]byte
+		if !machOReadAt(f, fields[:], desc) {
+			continue
+		}
+		switch f.ByteOrder.Uint32(fields[:]) & 0x1f {
+		case swiftKindClass, swiftKindStruct, swiftKindEnum:
+		default:
+			continue
+		}
+		access := int64(int32(f.ByteOrder.Uint32(fields[swiftAccessFunctionOffset:])))
+		if fn, ok := relTarget(desc+swiftAccessFunctionOffset, access, false); ok && access != 0 {
+			candidates = append(candidates, FunctionCandidate{
+				Address:       fn,
+				DetectionType: DetectionSwiftMetadata,
+				Confidence:    ConfidenceHigh,
+			})
+		}
+	}
+	return candidates, nil
+}
+
+// machOReadAt reads len(buf) bytes of the contents of the Mach-O file f at
+// the virtual address addr into buf, and reports whether they all lie in
+// one section with contents in the file.
+func machOReadAt(f *macho.File, buf []byte, addr uint64) bool {
+	n := uint64(len(buf))
+	for _, sec := range f.Sections {
+		if sec.Flags&0xff == machOZeroFill || addr < sec.Addr || sec.Size < n || addr-sec.Addr > sec.Size-n {
+			continue
+		}
+		_, err := sec.ReadAt(buf, int64(addr-sec.Addr))
+		return err == nil
+	}
+	return false
+}
+
+// machOInCode reports whether addr lies in a section of the Mach-O file f
+// holding instructions.
+func machOInCode(f *macho.File, addr uint64) bool {
+	for _, sec := range f.Sections {
+		if sec.Flags&(machOPureInstructions|machOSomeInstructions) != 0 && addr >= sec.Addr && addr-sec.Addr < sec.Size {
+			return true
+		}
+	}
+	return false
+}
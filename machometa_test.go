@@ -0,0 +1,140 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+// machOSection is a section of the __TEXT segment of machOImage: its name,
+// file offset, flags and contents.
+type machOSection struct {
+	name   string
+	offset int
+	flags  uint32
+	data   []byte
+}
+
+// machOImage returns a 64-bit little-endian arm64 Mach-O executable whose
+// __TEXT segment, mapped at base from the start of the file, holds the
+// given sections.
+func machOImage(base uint64, size int, sections []machOSection) []byte {
+	const (
+		headerSize  = 32
+		segmentSize = 72
+		sectionSize = 80
+	)
+	img := make([]byte, size)
+	le := binary.LittleEndian
+	le.PutUint32(img[0:], 0xfeedfacf) // MH_MAGIC_64
+	le.PutUint32(img[4:], 0x0100000c) // CPU_TYPE_ARM64
+	le.PutUint32(img[12:], 2)         // MH_EXECUTE
+	le.PutUint32(img[16:], 1)
+	le.PutUint32(img[20:], uint32(segmentSize+sectionSize*len(sections)))
+
+	seg := img[headerSize:]
+	le.PutUint32(seg[0:], 0x19) // LC_SEGMENT_64
+	le.PutUint32(seg[4:], uint32(segmentSize+sectionSize*len(sections)))
+	copy(seg[8:], "__TEXT")
+	le.PutUint64(seg[24:], base)
+	le.PutUint64(seg[32:], uint64(size))
+	le.PutUint64(seg[48:], uint64(size))
+	le.PutUint32(seg[56:], 5) // r-x
+	le.PutUint32(seg[60:], 5)
+	le.PutUint32(seg[64:], uint32(len(sections)))
+
+	for i, s := range sections {
+		sect := seg[segmentSize+sectionSize*i:]
+		copy(sect[0:], s.name)
+		copy(sect[16:], "__TEXT")
+		le.PutUint64(sect[32:], base+uint64(s.offset))
+		le.PutUint64(sect[40:], uint64(len(s.data)))
+		le.PutUint32(sect[48:], uint32(s.offset))
+		le.PutUint32(sect[64:], s.flags)
+		copy(img[s.offset:], s.data)
+	}
+	return img
+}
+
+// metadataImage returns a Mach-O image mapped at base whose __text holds
+// four leaf functions with no prologue, at 0x200, 0x208, 0x210 and 0x218.
+// Its Objective-C metadata lists methods implemented at 0x208 and in
+// __const, and its Swift metadata a struct whose access function is at
+// 0x218.
+func metadataImage(base uint64) []byte {
+	le := binary.LittleEndian
+	// rel returns the 32-bit offset of target from the field at from.
+	rel := func(from, target int) []byte {
+		return le.AppendUint32(nil, uint32(int32(target-from)))
+	}
+
+	// mov w0, #N; ret.
+	text := arm64Insn(0x52800020, 0xd65f03c0, 0x52800040, 0xd65f03c0, 0x52800060, 0xd65f03c0, 0x52800080, 0xd65f03c0)
+	// A relative method list of two methods.
+	methlist := le.AppendUint32(nil, 0x80000000|12)
+	methlist = le.AppendUint32(methlist, 2)
+	methlist = append(methlist, 0, 0, 0, 0, 0, 0, 0, 0)
+	methlist = append(methlist, rel(0x250, 0x208)...)
+	methlist = append(methlist, 0, 0, 0, 0, 0, 0, 0, 0)
+	methlist = append(methlist, rel(0x25c, 0x270)...)
+	// A struct descriptor at 0x270.
+	types := rel(0x260, 0x270)
+	descriptor := le.AppendUint32(nil, 0x11)
+	descriptor = append(descriptor, 0, 0, 0, 0, 0, 0, 0, 0)
+	descriptor = append(descriptor, rel(0x27c, 0x218)...)
+
+	return machOImage(base, 0x280, []machOSection{
+		{name: "__text", offset: 0x200, flags: 0x80000400, data: text},
+		{name: "__objc_methlist", offset: 0x240, data: methlist},
+		{name: "__swift5_types", offset: 0x260, data: types},
+		{name: "__const", offset: 0x270, data: descriptor},
+	})
+}
+
+func TestMachOMetadataDetector(t *testing.T) {
+	const base = 0x100000000
+	f, err := macho.NewFile(bytes.NewReader(metadataImage(base)))
+	if err != nil {
+		t.Fatalf("failed to parse Mach-O: %v", err)
+	}
+	got, err := resurgo.MachOMetadataDetector(f)
+	if err != nil {
+		t.Fatalf("resurgo.MachOMetadataDetector: %v", err)
+	}
+	// The method implemented in __const is not a function.
+	want := []resurgo.FunctionCandidate{
+		{Address: base + 0x208, DetectionType: resurgo.DetectionObjCMethod, Confidence: resurgo.ConfidenceHigh},
+		{Address: base + 0x218, DetectionType: resurgo.DetectionSwiftMetadata, Confidence: resurgo.ConfidenceHigh},
+	}
+	if !slices.EqualFunc(got, want, func(a, b resurgo.FunctionCandidate) bool {
+		return a.Address == b.Address && a.DetectionType == b.DetectionType && a.Confidence == b.Confidence
+	}) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDetectFunctionsFromFile_MachOMetadata(t *testing.T) {
+	const base = 0x100000000
+	img := metadataImage(base)
+	result, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(img), int64(len(img)))
+	if err != nil {
+		t.Fatalf("resurgo.DetectFunctionsFromReader: %v", err)
+	}
+	got := make(map[uint64]resurgo.FunctionCandidate, len(result.Functions))
+	for _, c := range result.Functions {
+		got[c.Address] = c
+	}
+	for addr, typ := range map[uint64]resurgo.DetectionType{
+		base + 0x208: resurgo.DetectionObjCMethod,
+		base + 0x218: resurgo.DetectionSwiftMetadata,
+	} {
+		c, ok := got[addr]
+		if !ok || c.DetectionType != typ || c.Confidence != resurgo.ConfidenceHigh {
+			t.Errorf("0x%x: got %q/%q, want %q/%q", addr, c.DetectionType, c.Confidence, typ, resurgo.ConfidenceHigh)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package resurgo
+
+import (
+	"cmp"
+	"slices"
+)
+
+const (
+	// Candidates kept when the candidates of two detectors are merged.
+	MergeKeepFirst     MergeKeep = "first"
+	MergeKeepLowest    MergeKeep = "lowest"
+	MergeKeepConfident MergeKeep = "confident"
+)
+
+// MergeKeep selects which of two merged candidates DetectFunctionsFromELF
+// keeps.
+type MergeKeep string
+
+// MergeOptions is the policy DetectFunctionsFromELF follows to merge the
+// candidates of each detector into those of the detectors before it.
+//
+// A candidate within Window bytes of a candidate of an earlier detector is
+// taken for the same function, the nearest one if several are, and the two
+// are merged into the one Keep selects, whose fields are kept unchanged:
+//
+//   - MergeKeepFirst, the default, keeps the candidate of the earlier
+//     detector.
+//   - MergeKeepLowest keeps the candidate at the lower address, e.g. an FDE
+//     at an ENDBR64 over the prologue following it.
+//   - MergeKeepConfident keeps the candidate with the higher confidence,
+//     that of the earlier detector on a tie.
+//
+// Candidates of one detector are never merged with each other: a candidate
+// of an earlier detector is merged with the first one in its window only,
+// and the others are added as functions of their own. The zero
+// value merges identical addresses only, keeping the earlier detector's
+// candidate.
+type MergeOptions struct {
+	// Window is the distance in bytes within which two candidates are taken
+	// for the same function; zero requires identical addresses.
+	Window uint64
+	// Keep selects the candidate kept; empty means MergeKeepFirst.
+	Keep MergeKeep
+}
+
+// WithMerge sets the policy DetectFunctionsFromELF follows to merge the
+// candidates of its detectors (default: identical addresses, earlier
+// detector wins).
+func WithMerge(m MergeOptions) Option {
+	return func(o *options) {
+		o.merge = m
+	}
+}
+
+// mergeWithin merges the candidates of b into those of a following m.
+func mergeWithin(a, b []FunctionCandidate, m MergeOptions) []FunctionCandidate {
+	if m.Window == 0 && (m.Keep == "" || m.Keep == MergeKeepFirst) {
+		return mergeCandidates(a, b)
+	}
+	merged := slices.Clone(a)
+	slices.SortStableFunc(merged, func(x, y FunctionCandidate) int {
+		return cmp.Compare(x.Address, y.Address)
+	})
+	// Matches are looked up among the addresses of a, which candidates of b
+	// replacing them do not move.
+	addrs := make([]uint64, len(merged))
+	for i, c := range merged {
+		addrs[i] = c.Address
+	}
+	// matched marks the candidates of a already merged with one of b, which
+	// the other candidates of b are not merged with.
+	matched := make([]bool, len(merged))
+	var added []FunctionCandidate
+	for _, c := range b {
+		i, ok := nearestWithin(addrs, c.Address, m.Window)
+		if !ok || matched[i] {
+			added = append(added, c)
+			continue
+		}
+		matched[i] = true
+		if m.keeps(c, merged[i]) {
+			merged[i] = c
+		}
+	}
+	merged = append(merged, added...)
+	slices.SortStableFunc(merged, func(x, y FunctionCandidate) int {
+		return cmp.Compare(x.Address, y.Address)
+	})
+	// A replaced candidate may now share its address with another: the
+	// first one wins.
+	return slices.CompactFunc(merged, func(x, y FunctionCandidate) bool {
+		return x.Address == y.Address
+	})
+}
+
+// keeps reports whether m keeps c, of a later detector, over prev.
+func (m MergeOptions) keeps(c, prev FunctionCandidate) bool {
+	switch m.Keep {
+	case MergeKeepLowest:
+		return c.Address < prev.Address
+	case MergeKeepConfident:
+		return confidenceRank(c.Confidence) > confidenceRank(prev.Confidence)
+	}
+	return false
+}
+
+// nearestWithin returns the index of the address of addrs, sorted in
+// ascending order, nearest to addr and at most window bytes away from it,
+// the lower one on a tie.
+func nearestWithin(addrs []uint64, addr, window uint64) (int, bool) {
+	i, _ := slices.BinarySearch(addrs, addr)
+	best, found := 0, false
+	var bestDist uint64
+	if i < len(addrs) && addrs[i]-addr <= window {
+		best, bestDist, found = i, addrs[i]-addr, true
+	}
+	if i > 0 && addr-addrs[i-1] <= window && (!found || addr-addrs[i-1] <= bestDist) {
+		best, found = i-1, true
+	}
+	return best, found
+}
@@ -0,0 +1,114 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestWithMerge(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("os.Executable: %v", err)
+	}
+	f, err := elf.Open(exe)
+	if err != nil {
+		t.Fatalf("elf.Open: %v", err)
+	}
+	defer f.Close()
+
+	// An FDE at an ENDBR64 and the prologue following it, plus two nearby
+	// prologues the FDE detector does not know about.
+	fdes := func(*elf.File) ([]resurgo.FunctionCandidate, error) {
+		return []resurgo.FunctionCandidate{
+			{Address: 0x1000, DetectionType: resurgo.DetectionCFI, Confidence: resurgo.ConfidenceMedium},
+			{Address: 0x2000, DetectionType: resurgo.DetectionCFI, Confidence: resurgo.ConfidenceHigh},
+		}, nil
+	}
+	prologues := func(*elf.File) ([]resurgo.FunctionCandidate, error) {
+		return []resurgo.FunctionCandidate{
+			{Address: 0x1004, DetectionType: resurgo.DetectionPrologueOnly, Confidence: resurgo.ConfidenceHigh},
+			{Address: 0x1008, DetectionType: resurgo.DetectionPrologueOnly, Confidence: resurgo.ConfidenceHigh},
+			{Address: 0x2000, DetectionType: resurgo.DetectionPrologueOnly, Confidence: resurgo.ConfidenceLow},
+			{Address: 0x3000, DetectionType: resurgo.DetectionPrologueOnly, Confidence: resurgo.ConfidenceLow},
+		}, nil
+	}
+
+	tests := []struct {
+		name  string
+		merge resurgo.MergeOptions
+		want  map[uint64]resurgo.DetectionType
+	}{{
+		name: "exact",
+		want: map[uint64]resurgo.DetectionType{
+			0x1000: resurgo.DetectionCFI,
+			0x1004: resurgo.DetectionPrologueOnly,
+			0x1008: resurgo.DetectionPrologueOnly,
+			0x2000: resurgo.DetectionCFI,
+			0x3000: resurgo.DetectionPrologueOnly,
+		},
+	}, {
+		// 0x1004 is nearest to 0x1000; 0x1008 is out of the window.
+		name:  "first",
+		merge: resurgo.MergeOptions{Window: 4, Keep: resurgo.MergeKeepFirst},
+		want: map[uint64]resurgo.DetectionType{
+			0x1000: resurgo.DetectionCFI,
+			0x1008: resurgo.DetectionPrologueOnly,
+			0x2000: resurgo.DetectionCFI,
+			0x3000: resurgo.DetectionPrologueOnly,
+		},
+	}, {
+		// 0x1004 and 0x1008 are both in the window of 0x1000: only the
+		// first is merged with it, the other is a function of its own.
+		name:  "lowest",
+		merge: resurgo.MergeOptions{Window: 8, Keep: resurgo.MergeKeepLowest},
+		want: map[uint64]resurgo.DetectionType{
+			0x1000: resurgo.DetectionCFI,
+			0x1008: resurgo.DetectionPrologueOnly,
+			0x2000: resurgo.DetectionCFI,
+			0x3000: resurgo.DetectionPrologueOnly,
+		},
+	}, {
+		name:  "first-wide",
+		merge: resurgo.MergeOptions{Window: 8, Keep: resurgo.MergeKeepFirst},
+		want: map[uint64]resurgo.DetectionType{
+			0x1000: resurgo.DetectionCFI,
+			0x1008: resurgo.DetectionPrologueOnly,
+			0x2000: resurgo.DetectionCFI,
+			0x3000: resurgo.DetectionPrologueOnly,
+		},
+	}, {
+		name:  "confident",
+		merge: resurgo.MergeOptions{Window: 4, Keep: resurgo.MergeKeepConfident},
+		want: map[uint64]resurgo.DetectionType{
+			0x1004: resurgo.DetectionPrologueOnly,
+			0x1008: resurgo.DetectionPrologueOnly,
+			0x2000: resurgo.DetectionCFI,
+			0x3000: resurgo.DetectionPrologueOnly,
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, err := resurgo.DetectFunctionsFromELF(f,
+				resurgo.WithDetectors(fdes, prologues),
+				resurgo.WithFilters(),
+				resurgo.WithAnchors(false),
+				resurgo.WithMerge(tt.merge),
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := make(map[uint64]resurgo.DetectionType)
+			for _, c := range candidates {
+				got[c.Address] = c.DetectionType
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
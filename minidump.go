@@ -0,0 +1,225 @@
+package resurgo
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// FormatMinidump is a Windows or Breakpad minidump. Its executable memory
+// regions are analyzed at the addresses they were mapped at.
+const FormatMinidump Format = "minidump"
+
+// minidumpMagic is the signature of a minidump header.
+const minidumpMagic = "MDMP"
+
+// Minidump stream types.
+const (
+	mdModuleListStream     = 4
+	mdMemoryListStream     = 5
+	mdSystemInfoStream     = 7
+	mdMemory64ListStream   = 9
+	mdMemoryInfoListStream = 16
+)
+
+// Minidump processor architectures, from MINIDUMP_SYSTEM_INFO.
+const (
+	mdArchX86   = 0
+	mdArchARM   = 5
+	mdArchAMD64 = 9
+	mdArchARM64 = 12
+	// mdArchARM64Breakpad is the value Breakpad used for ARM64 before
+	// Windows defined one.
+	mdArchARM64Breakpad = 0x8003
+)
+
+// mdPageExecute is the union of the PAGE_EXECUTE* memory protections.
+const mdPageExecute = 0x10 | 0x20 | 0x40 | 0x80
+
+// DetectProloguesFromMinidump returns the function prologues of the
+// executable memory captured in the size-byte minidump read from r, at the
+// addresses the memory was mapped at. Memory is executable if its
+// protection in the memory info stream allows execution; dumps without
+// that stream, as written by Breakpad, fall back to the memory of the
+// loaded modules. The architecture is read from the system info stream
+// unless WithArch is set. opts are those of DetectPrologues.
+func DetectProloguesFromMinidump(r io.ReaderAt, size int64, opts ...Option) ([]Prologue, error) {
+	o := newOptions(opts...)
+	arch, regions, err := minidumpCodeRegions(r, size, o.arch)
+	if err != nil {
+		return nil, err
+	}
+	return o.detectSectionPrologues(regions, arch)
+}
+
+func detectMinidump(r io.ReaderAt, size int64, opts ...Option) (*AnalysisResult, error) {
+	o := newOptions(opts...)
+	arch, regions, err := minidumpCodeRegions(r, size, o.arch)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := o.detectSections(regions, arch, nil)
+	if err != nil {
+		return nil, err
+	}
+	padding, err := o.padding(candidates, regions, arch)
+	if err != nil {
+		return nil, err
+	}
+	return &AnalysisResult{Format: FormatMinidump, Arch: arch, Functions: candidates, Padding: padding}, nil
+}
+
+// minidumpCodeRegions returns the architecture of the minidump read from r
+// and its executable memory regions, ordered by address. arch, if set,
+// overrides the architecture of the dump.
+func minidumpCodeRegions(r io.ReaderAt, size int64, arch Arch) (Arch, []codeSection, error) {
+	read := func(off, n uint64) ([]byte, error) {
+		if off+n < off || off+n > uint64(size) {
+			return nil, fmt.Errorf("minidump: range %#x+%#x out of bounds", off, n)
+		}
+		buf := make([]byte, n)
+		if _, err := r.ReadAt(buf, int64(off)); err != nil {
+			return nil, fmt.Errorf("minidump: read %#x+%#x: %w", off, n, err)
+		}
+		return buf, nil
+	}
+	le := binary.LittleEndian
+
+	// MINIDUMP_HEADER: Signature, Version, NumberOfStreams,
+	// StreamDirectoryRva, CheckSum, TimeDateStamp, Flags.
+	hdr, err := read(0, 32)
+	if err != nil {
+		return "", nil, err
+	}
+	if string(hdr[:4]) != minidumpMagic {
+		return "", nil, fmt.Errorf("minidump: bad signature")
+	}
+	count, dirRVA := le.Uint32(hdr[8:]), le.Uint32(hdr[12:])
+	// MINIDUMP_DIRECTORY: StreamType, DataSize, Rva.
+	dir, err := read(uint64(dirRVA), uint64(count)*12)
+	if err != nil {
+		return "", nil, err
+	}
+	streams := make(map[uint32][]byte)
+	for i := range int(count) {
+		e := dir[i*12:]
+		typ := le.Uint32(e)
+		if _, ok := streams[typ]; ok || typ == 0 {
+			continue
+		}
+		data, err := read(uint64(le.Uint32(e[8:])), uint64(le.Uint32(e[4:])))
+		if err != nil {
+			return "", nil, err
+		}
+		streams[typ] = data
+	}
+
+	if arch == "" {
+		info := streams[mdSystemInfoStream]
+		if len(info) < 2 {
+			return "", nil, fmt.Errorf("minidump: no system info, set the architecture with WithArch")
+		}
+		switch a := le.Uint16(info); a {
+		case mdArchX86:
+			arch = ArchX86
+		case mdArchAMD64:
+			arch = ArchAMD64
+		case mdArchARM64, mdArchARM64Breakpad:
+			arch = ArchARM64
+		case mdArchARM:
+			// Windows on ARM runs Thumb-2 code only.
+			arch = ArchThumb
+		default:
+			return "", nil, fmt.Errorf("minidump: unsupported processor architecture %#x", a)
+		}
+	}
+
+	// exec holds the [lo, hi) ranges of executable memory.
+	var exec [][2]uint64
+	if info, ok := streams[mdMemoryInfoListStream]; ok {
+		// MINIDUMP_MEMORY_INFO_LIST: SizeOfHeader, SizeOfEntry,
+		// NumberOfEntries; MINIDUMP_MEMORY_INFO: BaseAddress,
+		// AllocationBase, AllocationProtect, RegionSize, State, Protect, Type.
+		if len(info) < 16 {
+			return "", nil, fmt.Errorf("minidump: truncated memory info list")
+		}
+		hdrSize, entrySize, n := uint64(le.Uint32(info)), uint64(le.Uint32(info[4:])), le.Uint64(info[8:])
+		if entrySize < 48 || hdrSize > uint64(len(info)) || n > (uint64(len(info))-hdrSize)/entrySize {
+			return "", nil, fmt.Errorf("minidump: malformed memory info list")
+		}
+		for i := range n {
+			e := info[hdrSize+i*entrySize:]
+			if le.Uint32(e[36:])&mdPageExecute != 0 {
+				base := le.Uint64(e)
+				exec = append(exec, [2]uint64{base, base + le.Uint64(e[24:])})
+			}
+		}
+	} else if modules, ok := streams[mdModuleListStream]; ok {
+		// MINIDUMP_MODULE_LIST: NumberOfModules, then 108-byte
+		// MINIDUMP_MODULE entries starting with BaseOfImage, SizeOfImage.
+		const moduleSize = 108
+		if len(modules) < 4 || 4+uint64(le.Uint32(modules))*moduleSize > uint64(len(modules)) {
+			return "", nil, fmt.Errorf("minidump: malformed module list")
+		}
+		for i := range uint64(le.Uint32(modules)) {
+			e := modules[4+i*moduleSize:]
+			base := le.Uint64(e)
+			exec = append(exec, [2]uint64{base, base + uint64(le.Uint32(e[8:]))})
+		}
+	}
+
+	var regions []codeSection
+	// addRegion adds the parts of the captured memory at addr that fall in
+	// executable ranges.
+	addRegion := func(addr, rva, n uint64) error {
+		for _, x := range exec {
+			lo, hi := max(addr, x[0]), min(addr+n, x[1])
+			if lo >= hi {
+				continue
+			}
+			code, err := read(rva+lo-addr, hi-lo)
+			if err != nil {
+				return err
+			}
+			regions = append(regions, codeSection{code: code, addr: lo})
+		}
+		return nil
+	}
+	if list, ok := streams[mdMemory64ListStream]; ok {
+		// MINIDUMP_MEMORY64_LIST: NumberOfMemoryRanges, BaseRva, then
+		// StartOfMemoryRange, DataSize pairs whose data follows BaseRva
+		// back to back.
+		if len(list) < 16 {
+			return "", nil, fmt.Errorf("minidump: truncated memory64 list")
+		}
+		n, rva := le.Uint64(list), le.Uint64(list[8:])
+		if n > uint64(len(list)-16)/16 {
+			return "", nil, fmt.Errorf("minidump: malformed memory64 list")
+		}
+		for i := range n {
+			e := list[16+i*16:]
+			addr, dataSize := le.Uint64(e), le.Uint64(e[8:])
+			if err := addRegion(addr, rva, dataSize); err != nil {
+				return "", nil, err
+			}
+			rva += dataSize
+		}
+	}
+	if list, ok := streams[mdMemoryListStream]; ok {
+		// MINIDUMP_MEMORY_LIST: NumberOfMemoryRanges, then
+		// StartOfMemoryRange, DataSize, Rva descriptors.
+		if len(list) < 4 || 4+uint64(le.Uint32(list))*16 > uint64(len(list)) {
+			return "", nil, fmt.Errorf("minidump: malformed memory list")
+		}
+		for i := range uint64(le.Uint32(list)) {
+			e := list[4+i*16:]
+			if err := addRegion(le.Uint64(e), uint64(le.Uint32(e[12:])), uint64(le.Uint32(e[8:]))); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+	slices.SortFunc(regions, func(a, b codeSection) int { return cmp.Compare(a.addr, b.addr) })
+	return arch, regions, nil
+}
@@ -0,0 +1,160 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+// mdStream is a minidump stream of type typ.
+type mdStream struct {
+	typ  uint32
+	data []byte
+}
+
+// minidump lays out streams after the header and the stream directory, then
+// appends memory.
+func minidump(streams []mdStream, memory []byte) []byte {
+	le := binary.LittleEndian
+	dump := le.AppendUint32([]byte("MDMP"), 0xa793)
+	dump = le.AppendUint32(dump, uint32(len(streams)))
+	dump = le.AppendUint32(dump, 32)
+	dump = append(dump, make([]byte, 16)...)
+	rva := 32 + 12*len(streams)
+	for _, s := range streams {
+		dump = le.AppendUint32(dump, s.typ)
+		dump = le.AppendUint32(dump, uint32(len(s.data)))
+		dump = le.AppendUint32(dump, uint32(rva))
+		rva += len(s.data)
+	}
+	for _, s := range streams {
+		dump = append(dump, s.data...)
+	}
+	return append(dump, memory...)
+}
+
+// mdHeaderSize returns the size of a minidump with streams, before memory.
+func mdHeaderSize(streams []mdStream) int {
+	n := 32 + 12*len(streams)
+	for _, s := range streams {
+		n += len(s.data)
+	}
+	return n
+}
+
+func TestDetectProloguesFromMinidump(t *testing.T) {
+	le := binary.LittleEndian
+	const (
+		codeAddr = 0x7ff600001000
+		dataAddr = 0x7ff600002000
+	)
+	// push rbp; mov rbp, rsp; pop rbp; ret
+	fn := []byte{0x55, 0x48, 0x89, 0xe5, 0x5d, 0xc3}
+	page := func() []byte {
+		p := make([]byte, 0x20)
+		copy(p, fn)
+		copy(p[0x10:], fn)
+		return p
+	}
+	memory := append(page(), page()...)
+
+	systemInfo := mdStream{typ: 7, data: le.AppendUint16(nil, 9)} // AMD64
+	memInfo := func(regions ...[3]uint64) mdStream {
+		data := le.AppendUint32(nil, 16)
+		data = le.AppendUint32(data, 48)
+		data = le.AppendUint64(data, uint64(len(regions)))
+		for _, r := range regions {
+			e := make([]byte, 48)
+			le.PutUint64(e, r[0])
+			le.PutUint64(e[24:], r[1])
+			le.PutUint32(e[36:], uint32(r[2]))
+			data = append(data, e...)
+		}
+		return mdStream{typ: 16, data: data}
+	}
+	infos := memInfo(
+		[3]uint64{codeAddr, 0x1000, 0x20}, // PAGE_EXECUTE_READ
+		[3]uint64{dataAddr, 0x1000, 0x04}, // PAGE_READWRITE
+	)
+	modules := func() mdStream {
+		data := le.AppendUint32(nil, 1)
+		e := make([]byte, 108)
+		le.PutUint64(e, codeAddr)
+		le.PutUint32(e[8:], 0x1000)
+		return mdStream{typ: 4, data: append(data, e...)}
+	}()
+	memory64 := func(memoryRVA int) mdStream {
+		data := le.AppendUint64(nil, 2)
+		data = le.AppendUint64(data, uint64(memoryRVA))
+		for _, addr := range []uint64{codeAddr, dataAddr} {
+			data = le.AppendUint64(data, addr)
+			data = le.AppendUint64(data, 0x20)
+		}
+		return mdStream{typ: 9, data: data}
+	}
+	memoryList := func(memoryRVA int) mdStream {
+		data := le.AppendUint32(nil, 2)
+		for i, addr := range []uint64{codeAddr, dataAddr} {
+			data = le.AppendUint64(data, addr)
+			data = le.AppendUint32(data, 0x20)
+			data = le.AppendUint32(data, uint32(memoryRVA+i*0x20))
+		}
+		return mdStream{typ: 5, data: data}
+	}
+
+	tests := []struct {
+		name    string
+		streams []mdStream
+		list    func(memoryRVA int) mdStream
+	}{
+		{name: "memory64 list", streams: []mdStream{systemInfo, infos}, list: memory64},
+		{name: "memory list", streams: []mdStream{systemInfo, infos}, list: memoryList},
+		{name: "module fallback", streams: []mdStream{systemInfo, modules}, list: memoryList},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The memory list has the same size whatever its RVA.
+			streams := append(tt.streams, tt.list(0))
+			streams[len(streams)-1] = tt.list(mdHeaderSize(streams))
+			dump := minidump(streams, memory)
+
+			prologues, err := resurgo.DetectProloguesFromMinidump(bytes.NewReader(dump), int64(len(dump)))
+			if err != nil {
+				t.Fatalf("DetectProloguesFromMinidump: %v", err)
+			}
+			var got []uint64
+			for _, p := range prologues {
+				got = append(got, p.Address)
+			}
+			// Several patterns may match at one address.
+			got = slices.Compact(got)
+			want := []uint64{codeAddr, codeAddr + 0x10}
+			if !slices.Equal(got, want) {
+				t.Errorf("got prologues at %#x, want %#x", got, want)
+			}
+
+			result, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(dump), int64(len(dump)))
+			if err != nil {
+				t.Fatalf("DetectFunctionsFromReader: %v", err)
+			}
+			if result.Format != resurgo.FormatMinidump || result.Arch != resurgo.ArchAMD64 {
+				t.Errorf("got %s/%s, want %s/%s", result.Format, result.Arch, resurgo.FormatMinidump, resurgo.ArchAMD64)
+			}
+			for _, c := range result.Functions {
+				if c.Address >= dataAddr {
+					t.Errorf("candidate at %#x in non-executable memory", c.Address)
+				}
+			}
+		})
+	}
+
+	t.Run("bad signature", func(t *testing.T) {
+		dump := append([]byte("MDMX"), make([]byte, 28)...)
+		if _, err := resurgo.DetectProloguesFromMinidump(bytes.NewReader(dump), int64(len(dump))); err == nil {
+			t.Error("expected an error for a bad signature")
+		}
+	})
+}
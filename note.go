@@ -0,0 +1,376 @@
+package resurgo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	// NoteSection is the name of the ELF section WriteNote adds to hold
+	// analysis results.
+	NoteSection = ".note.resurgo"
+
+	// noteName and noteType identify resurgo results among the notes of
+	// NoteSection.
+	noteName = "resurgo"
+	noteType = 1
+
+	// noteVersion is the version of the note payload; notes of another
+	// version are ignored by ReadNote and replaced by WriteNote.
+	noteVersion = 1
+)
+
+// notePayload is the JSON descriptor of a note.
+type notePayload struct {
+	Version   int                 `json:"version"`
+	Config    string              `json:"config"`
+	Functions []FunctionCandidate `json:"functions"`
+}
+
+// elfNote is a note of an SHT_NOTE section.
+type elfNote struct {
+	name string
+	typ  uint32
+	desc []byte
+}
+
+// WriteNote writes to w a copy of the ELF binary read from r, size bytes
+// long, with candidates stored in the NoteSection section, so that results
+// travel with the binary and ReadNote can load them without re-analysis.
+// The results are keyed by the note format version and a hash of the
+// configuration opts, which should be the options the candidates were
+// detected with. Results for other configurations already in the section
+// are kept; results for the same one are replaced.
+//
+// The section is not loaded at run time: the copy executes as the original
+// does. Its contents are appended to the file along with a new section
+// header table.
+func WriteNote(w io.Writer, r io.ReaderAt, size int64, candidates []FunctionCandidate, opts ...Option) error {
+	data, err := io.ReadAll(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return fmt.Errorf("read ELF: %w", err)
+	}
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("parse ELF: %w", err)
+	}
+
+	config := newOptions(opts...).configHash()
+	desc, err := json.Marshal(notePayload{Version: noteVersion, Config: config, Functions: candidates})
+	if err != nil {
+		return fmt.Errorf("encode results: %w", err)
+	}
+	notes := []elfNote{{name: noteName, typ: noteType, desc: desc}}
+	if sec := f.Section(NoteSection); sec != nil {
+		existing, err := readNotes(f, sec)
+		if err != nil {
+			return err
+		}
+		for _, n := range existing {
+			if p, ok := decodeNote(n); ok && p.Version == noteVersion && p.Config == config {
+				continue
+			}
+			notes = append(notes, n)
+		}
+	}
+
+	out, err := appendNoteSection(f, data, encodeNotes(notes, f.ByteOrder.(binary.AppendByteOrder)))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// ReadNote returns the candidates that WriteNote stored in f for the
+// configuration opts. It reports false when f holds no results for that
+// configuration or for the current note format version.
+func ReadNote(f *elf.File, opts ...Option) ([]FunctionCandidate, bool, error) {
+	sec := f.Section(NoteSection)
+	if sec == nil {
+		return nil, false, nil
+	}
+	notes, err := readNotes(f, sec)
+	if err != nil {
+		return nil, false, err
+	}
+	config := newOptions(opts...).configHash()
+	for _, n := range notes {
+		if p, ok := decodeNote(n); ok && p.Version == noteVersion && p.Config == config {
+			return p.Functions, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// decodeNote returns the payload of a resurgo note; it reports false for
+// notes of other owners or types and for malformed payloads.
+func decodeNote(n elfNote) (notePayload, bool) {
+	var p notePayload
+	if n.name != noteName || n.typ != noteType {
+		return p, false
+	}
+	if err := json.Unmarshal(n.desc, &p); err != nil {
+		return p, false
+	}
+	return p, true
+}
+
+// configHash identifies the settings of o that affect detection results.
+// Detectors and filters are identified by name. Resource limits, telemetry
+// and instruction syntax do not change the candidates and are left out.
+func (o *options) configHash() string {
+	h := sha256.New()
+	for _, d := range o.detectors {
+		fmt.Fprintf(h, "detector=%s\n", stageName(d))
+	}
+	for _, fl := range o.filters {
+		fmt.Fprintf(h, "filter=%s\n", stageName(fl))
+	}
+	windows := make([]PrologueType, 0, len(o.contextWindows))
+	for typ := range o.contextWindows {
+		windows = append(windows, typ)
+	}
+	slices.Sort(windows)
+	for _, typ := range windows {
+		fmt.Fprintf(h, "window=%s:%+v\n", typ, o.contextWindows[typ])
+	}
+	strategies := slices.Sorted(maps.Keys(o.boundaryStrategies))
+	for _, typ := range strategies {
+		fmt.Fprintf(h, "strategy=%s:%T\n", typ, o.boundaryStrategies[typ])
+	}
+	byteOrder := "little-endian"
+	if o.byteOrder == binary.BigEndian {
+		byteOrder = "big-endian"
+	}
+	fmt.Fprintf(h, "tolerance=%d frame=%d traps=%t order=%s wrap=%t toolchain=%s all=%t alignment=%t min=%s anchors=%t\n",
+		o.patternTolerance, o.maxFrameSize, o.trapBoundaries, byteOrder, o.addressWrap, o.toolchain,
+		o.allPrologues, o.alignmentSignal, o.minConfidence, o.anchors)
+	if o.abi != "" || o.calleeSavedRegs != nil {
+		fmt.Fprintf(h, "abi=%s saved=%s\n", o.abi, strings.Join(o.calleeSavedRegs, ","))
+	}
+	if o.sectionNames {
+		fmt.Fprintf(h, "sections=%t\n", o.sectionNames)
+	}
+	if !o.pltStubs {
+		fmt.Fprintf(h, "plt=%t\n", o.pltStubs)
+	}
+	if o.crtLabels {
+		fmt.Fprintf(h, "crt=%t\n", o.crtLabels)
+	}
+	if !o.ifuncLabels {
+		fmt.Fprintf(h, "ifunc=%t\n", o.ifuncLabels)
+	}
+	if o.debugSymbols {
+		fmt.Fprintf(h, "debug=%s;%s\n", strings.Join(o.debugDirs, ","), strings.Join(o.debuginfod, ","))
+	}
+	if o.loadBias != 0 {
+		fmt.Fprintf(h, "bias=%#x\n", o.loadBias)
+	}
+	if o.symbols {
+		fmt.Fprintf(h, "symbols=%t\n", o.symbols)
+	}
+	if o.branchTargets {
+		fmt.Fprintf(h, "targets=%t\n", o.branchTargets)
+	}
+	if o.recursiveDescent {
+		fmt.Fprintf(h, "descent=%t\n", o.recursiveDescent)
+	}
+	if o.hybridSweep {
+		fmt.Fprintf(h, "hybrid=%t\n", o.hybridSweep)
+	}
+	if o.supersetVoting {
+		fmt.Fprintf(h, "superset=%t\n", o.supersetVoting)
+	}
+	if o.gapFilling {
+		fmt.Fprintf(h, "gapfill=%t\n", o.gapFilling)
+	}
+	if o.functionSizes {
+		fmt.Fprintf(h, "sizes=%t\n", o.functionSizes)
+	}
+	if o.jumpTableSkipping {
+		fmt.Fprintf(h, "jumptables=%t\n", o.jumpTableSkipping)
+	}
+	if o.merge != (MergeOptions{}) {
+		fmt.Fprintf(h, "merge=%d:%s\n", o.merge.Window, o.merge.Keep)
+	}
+	if o.scoreWeights != nil {
+		// fmt prints maps in key order.
+		fmt.Fprintf(h, "weights=%+v\n", *o.scoreWeights)
+	}
+	if o.calibration != nil {
+		// Encoding a map orders its keys, so equal calibrations hash alike.
+		// Only non-finite precisions fail to encode, and Calibrate never
+		// produces them.
+		_ = json.NewEncoder(h).Encode(o.calibration)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// readNotes decodes the notes of the SHT_NOTE section sec.
+func readNotes(f *elf.File, sec *elf.Section) ([]elfNote, error) {
+	data, err := sectionData(sec)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", sec.Name, err)
+	}
+	return parseNotes(data, f.ByteOrder, sec.Name)
+}
+
+// parseNotes decodes the notes of data, the contents of the SHT_NOTE
+// section or PT_NOTE segment named where in errors.
+func parseNotes(data []byte, bo binary.ByteOrder, where string) ([]elfNote, error) {
+	var notes []elfNote
+	for len(data) >= 12 {
+		namesz, descsz := uint64(bo.Uint32(data[0:])), uint64(bo.Uint32(data[4:]))
+		typ := bo.Uint32(data[8:])
+		nameEnd := 12 + align4(namesz)
+		descEnd := nameEnd + align4(descsz)
+		if descEnd > uint64(len(data)) {
+			return nil, fmt.Errorf("malformed note in %s", where)
+		}
+		name := bytes.TrimRight(data[12:12+namesz], "\x00")
+		notes = append(notes, elfNote{name: string(name), typ: typ, desc: data[nameEnd : nameEnd+descsz]})
+		data = data[descEnd:]
+	}
+	return notes, nil
+}
+
+// encodeNotes encodes notes as the contents of an SHT_NOTE section.
+func encodeNotes(notes []elfNote, bo binary.AppendByteOrder) []byte {
+	var buf []byte
+	for _, n := range notes {
+		name := append([]byte(n.name), 0)
+		buf = bo.AppendUint32(buf, uint32(len(name)))
+		buf = bo.AppendUint32(buf, uint32(len(n.desc)))
+		buf = bo.AppendUint32(buf, n.typ)
+		buf = append(buf, name...)
+		buf = append(buf, make([]byte, align4(uint64(len(name)))-uint64(len(name)))...)
+		buf = append(buf, n.desc...)
+		buf = append(buf, make([]byte, align4(uint64(len(n.desc)))-uint64(len(n.desc)))...)
+	}
+	return buf
+}
+
+func align4(n uint64) uint64 {
+	return (n + 3) &^ 3
+}
+
+// appendNoteSection returns data, the contents of f, with notes appended as
+// the contents of NoteSection. An existing NoteSection is pointed at the new
+// contents; otherwise the section is added, with its name appended to a
+// copy of the section name table. The section header table is rewritten at
+// the end of the file.
+func appendNoteSection(f *elf.File, data []byte, notes []byte) ([]byte, error) {
+	bo := f.ByteOrder
+	var hdr elf.Header64
+	switch f.Class {
+	case elf.ELFCLASS64:
+		if err := binary.Read(bytes.NewReader(data), bo, &hdr); err != nil {
+			return nil, fmt.Errorf("read ELF header: %w", err)
+		}
+	case elf.ELFCLASS32:
+		var h32 elf.Header32
+		if err := binary.Read(bytes.NewReader(data), bo, &h32); err != nil {
+			return nil, fmt.Errorf("read ELF header: %w", err)
+		}
+		hdr = elf.Header64{Shoff: uint64(h32.Shoff), Shentsize: h32.Shentsize, Shnum: h32.Shnum, Shstrndx: h32.Shstrndx}
+	default:
+		return nil, fmt.Errorf("unsupported ELF class: %s", f.Class)
+	}
+	if hdr.Shnum == 0 || hdr.Shnum >= uint16(elf.SHN_LORESERVE) || hdr.Shstrndx >= hdr.Shnum {
+		return nil, fmt.Errorf("unsupported section header table: %d sections, names in %d", hdr.Shnum, hdr.Shstrndx)
+	}
+
+	shdrs := make([]elf.Section64, hdr.Shnum)
+	for i := range shdrs {
+		off := hdr.Shoff + uint64(i)*uint64(hdr.Shentsize)
+		if off > uint64(len(data)) {
+			return nil, fmt.Errorf("section header %d out of bounds", i)
+		}
+		r := bytes.NewReader(data[off:])
+		if f.Class == elf.ELFCLASS64 {
+			if err := binary.Read(r, bo, &shdrs[i]); err != nil {
+				return nil, fmt.Errorf("read section header %d: %w", i, err)
+			}
+			continue
+		}
+		var s32 elf.Section32
+		if err := binary.Read(r, bo, &s32); err != nil {
+			return nil, fmt.Errorf("read section header %d: %w", i, err)
+		}
+		shdrs[i] = elf.Section64{
+			Name: s32.Name, Type: s32.Type, Flags: uint64(s32.Flags), Addr: uint64(s32.Addr),
+			Off: uint64(s32.Off), Size: uint64(s32.Size), Link: s32.Link, Info: s32.Info,
+			Addralign: uint64(s32.Addralign), Entsize: uint64(s32.Entsize),
+		}
+	}
+
+	out := slices.Clone(data)
+	pad := func(align int) {
+		out = append(out, make([]byte, (align-len(out)%align)%align)...)
+	}
+	pad(8)
+	noteOff := uint64(len(out))
+	out = append(out, notes...)
+
+	if i := slices.IndexFunc(f.Sections, func(s *elf.Section) bool { return s.Name == NoteSection }); i >= 0 {
+		shdrs[i].Off, shdrs[i].Size = noteOff, uint64(len(notes))
+	} else {
+		strtab := &shdrs[hdr.Shstrndx]
+		if strtab.Off+strtab.Size > uint64(len(data)) {
+			return nil, fmt.Errorf("section name table out of bounds")
+		}
+		names := slices.Clone(data[strtab.Off : strtab.Off+strtab.Size])
+		nameOff := uint32(len(names))
+		names = append(names, NoteSection+"\x00"...)
+		strtab.Off, strtab.Size = uint64(len(out)), uint64(len(names))
+		out = append(out, names...)
+		shdrs = append(shdrs, elf.Section64{
+			Name: nameOff, Type: uint32(elf.SHT_NOTE), Off: noteOff, Size: uint64(len(notes)), Addralign: 4,
+		})
+	}
+
+	pad(8)
+	shoff := uint64(len(out))
+	for _, s := range shdrs {
+		if f.Class == elf.ELFCLASS64 {
+			out = appendStruct(out, bo, s)
+			continue
+		}
+		out = appendStruct(out, bo, elf.Section32{
+			Name: s.Name, Type: s.Type, Flags: uint32(s.Flags), Addr: uint32(s.Addr),
+			Off: uint32(s.Off), Size: uint32(s.Size), Link: s.Link, Info: s.Info,
+			Addralign: uint32(s.Addralign), Entsize: uint32(s.Entsize),
+		})
+	}
+
+	// Point the ELF header at the new section header table.
+	if f.Class == elf.ELFCLASS64 {
+		bo.PutUint64(out[0x28:], shoff)
+		bo.PutUint16(out[0x3c:], uint16(len(shdrs)))
+	} else {
+		if shoff > uint64(^uint32(0)) {
+			return nil, fmt.Errorf("ELF32 file too large")
+		}
+		bo.PutUint32(out[0x20:], uint32(shoff))
+		bo.PutUint16(out[0x30:], uint16(len(shdrs)))
+	}
+	return out, nil
+}
+
+// appendStruct appends the encoding of the fixed-size value v to buf.
+func appendStruct(buf []byte, bo binary.ByteOrder, v any) []byte {
+	var b bytes.Buffer
+	// v is a fixed-size struct; writing to a bytes.Buffer cannot fail.
+	_ = binary.Write(&b, bo, v)
+	return append(buf, b.Bytes()...)
+}
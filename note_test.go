@@ -0,0 +1,106 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestWriteNote(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.c")
+	const code = `
+int add(int a, int b) { return a + b; }
+int main(void) { return add(1, 2) - 3; }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	binPath := filepath.Join(dir, "app")
+	if out, err := exec.Command("gcc", "-O0", "-s", "-o", binPath, src).CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile app.c: %v\n%s", err, out)
+	}
+
+	// writeNote stores the results of opts in a copy of in and returns the
+	// copy's path and results.
+	writeNote := func(t *testing.T, in, out string, opts ...resurgo.Option) []resurgo.FunctionCandidate {
+		t.Helper()
+		f, err := elf.Open(in)
+		if err != nil {
+			t.Fatalf("failed to open ELF: %v", err)
+		}
+		defer f.Close()
+		candidates, err := resurgo.DetectFunctionsFromELF(f, opts...)
+		if err != nil {
+			t.Fatalf("resurgo.DetectFunctionsFromELF: %v", err)
+		}
+		data, err := os.ReadFile(in)
+		if err != nil {
+			t.Fatalf("failed to read binary: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := resurgo.WriteNote(&buf, bytes.NewReader(data), int64(len(data)), candidates, opts...); err != nil {
+			t.Fatalf("resurgo.WriteNote: %v", err)
+		}
+		if err := os.WriteFile(out, buf.Bytes(), 0o755); err != nil {
+			t.Fatalf("failed to write binary: %v", err)
+		}
+		return candidates
+	}
+	readNote := func(t *testing.T, path string, opts ...resurgo.Option) ([]resurgo.FunctionCandidate, bool) {
+		t.Helper()
+		f, err := elf.Open(path)
+		if err != nil {
+			t.Fatalf("failed to open ELF: %v", err)
+		}
+		defer f.Close()
+		candidates, ok, err := resurgo.ReadNote(f, opts...)
+		if err != nil {
+			t.Fatalf("resurgo.ReadNote: %v", err)
+		}
+		return candidates, ok
+	}
+
+	noted := filepath.Join(dir, "app-noted")
+	want := writeNote(t, binPath, noted)
+	if out, err := exec.Command(noted).CombinedOutput(); err != nil {
+		t.Errorf("annotated binary does not run: %v\n%s", err, out)
+	}
+	if _, ok := readNote(t, binPath); ok {
+		t.Error("original binary unexpectedly holds a note")
+	}
+	if got, ok := readNote(t, noted); !ok || !slices.EqualFunc(got, want, equalCandidates) {
+		t.Errorf("ReadNote: got %d candidates (found %t), want %d", len(got), ok, len(want))
+	}
+
+	strict := resurgo.WithPreset(resurgo.PresetStrict)
+	if _, ok := readNote(t, noted, strict); ok {
+		t.Error("ReadNote found results for a configuration that was not written")
+	}
+
+	// A second configuration is stored alongside the first.
+	both := filepath.Join(dir, "app-both")
+	wantStrict := writeNote(t, noted, both, strict)
+	if got, ok := readNote(t, both); !ok || !slices.EqualFunc(got, want, equalCandidates) {
+		t.Errorf("default results lost after writing strict ones: got %d candidates (found %t)", len(got), ok)
+	}
+	if got, ok := readNote(t, both, strict); !ok || !slices.EqualFunc(got, wantStrict, equalCandidates) {
+		t.Errorf("ReadNote strict: got %d candidates (found %t), want %d", len(got), ok, len(wantStrict))
+	}
+}
+
+func equalCandidates(a, b resurgo.FunctionCandidate) bool {
+	return a.Address == b.Address && a.DetectionType == b.DetectionType &&
+		a.PrologueType == b.PrologueType && a.Confidence == b.Confidence &&
+		slices.Equal(a.CalledFrom, b.CalledFrom) && slices.Equal(a.JumpedFrom, b.JumpedFrom)
+}
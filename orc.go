@@ -0,0 +1,166 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"fmt"
+	"slices"
+)
+
+// DetectionORC is assigned to function candidates read from the ORC unwind
+// table of an x86-64 Linux kernel image (.orc_unwind_ip, .orc_unwind), the
+// only unwind metadata of kernels built without frame pointers and DWARF.
+const DetectionORC DetectionType = "orc"
+
+const (
+	// orcEntrySize is the size of a struct orc_entry: the signed SP and BP
+	// offsets, then the SP and BP registers in one byte and the entry type
+	// and flags in the next.
+	orcEntrySize = 6
+	// orcRegUndefined marks code objtool could not reach, e.g. the padding
+	// between functions, and orcRegSP a CFA relative to the stack pointer.
+	orcRegUndefined = 0
+	orcRegSP        = 5
+)
+
+// orcEntry is an ORC unwind rule, in effect from ip up to the next entry.
+type orcEntry struct {
+	ip           uint64
+	spOffset     int16
+	spReg, bpReg byte
+}
+
+// atEntry reports whether e is the unwind state of a function entry: the
+// return address was just pushed, so the CFA is SP+8, and BP was not
+// saved. It does not depend on the encoding of the entry type, which
+// changed in Linux 6.4.
+func (e orcEntry) atEntry() bool {
+	return e.spReg == orcRegSP && e.spOffset == 8 && e.bpReg == orcRegUndefined
+}
+
+// ORCDetector is a CandidateDetector that emits function starts from the
+// ORC unwind table of an x86-64 kernel image. objtool writes an entry
+// wherever the unwind state changes and marks unreachable code, such as
+// the padding between functions, undefined; a function entry state that
+// follows undefined code, or opens the table, is taken as a function
+// start. Functions laid out back to back without padding share the entry
+// state of the previous return and are not reported. Each candidate
+// carries DetectionORC and ConfidenceHigh. Files without an ORC table,
+// including kernel modules, whose table is only relocated at load time,
+// yield no candidates. It is not part of the default pipeline.
+func ORCDetector(f *elf.File) ([]FunctionCandidate, error) {
+	entries, err := parseORC(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse ORC: %w", err)
+	}
+	exec := execRanges(f)
+	var candidates []FunctionCandidate
+	for i, e := range entries {
+		if !e.atEntry() || !inRanges(e.ip, exec) {
+			continue
+		}
+		if i > 0 && entries[i-1].spReg != orcRegUndefined {
+			continue
+		}
+		candidates = append(candidates, FunctionCandidate{
+			Address:       e.ip,
+			DetectionType: DetectionORC,
+			Confidence:    ConfidenceHigh,
+		})
+	}
+	return candidates, nil
+}
+
+// ORCFilter validates prologue-bearing candidates against the ORC unwind
+// table of an x86-64 kernel image and demotes to ConfidenceLow those whose
+// address is not in the unwind state of a function entry, e.g. a match
+// inside the body of a function whose frame is set up. Candidates without a
+// PrologueType, and all candidates when the table is absent, are returned
+// unchanged. The filter only demotes; it never removes candidates. It is not
+// part of the default pipeline.
+func ORCFilter(candidates []FunctionCandidate, f *elf.File) ([]FunctionCandidate, error) {
+	entries, err := parseORC(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse ORC: %w", err)
+	}
+	if len(entries) == 0 {
+		return candidates, nil
+	}
+	for i := range candidates {
+		c := &candidates[i]
+		if c.PrologueType == "" {
+			continue
+		}
+		// The rule in effect is the last entry at or before the address.
+		idx, found := slices.BinarySearchFunc(entries, c.Address, func(e orcEntry, addr uint64) int {
+			switch {
+			case e.ip < addr:
+				return -1
+			case e.ip > addr:
+				return 1
+			}
+			return 0
+		})
+		if !found {
+			if idx == 0 {
+				continue
+			}
+			idx--
+		}
+		if !entries[idx].atEntry() {
+			c.Confidence = ConfidenceLow
+		}
+	}
+	return candidates, nil
+}
+
+// parseORC reads the ORC unwind table of f, sorted by address. Each int32
+// of .orc_unwind_ip is the address of the code its entry of .orc_unwind
+// applies to, relative to the int32 itself. Returns nil (no error) if f is
+// not a linked x86-64 file or has no ORC table, or if the two sections
+// disagree on the number of entries.
+func parseORC(f *elf.File) ([]orcEntry, error) {
+	ipSec, unwindSec := f.Section(".orc_unwind_ip"), f.Section(".orc_unwind")
+	if f.Machine != elf.EM_X86_64 || f.Type == elf.ET_REL || ipSec == nil || unwindSec == nil {
+		return nil, nil
+	}
+	if ipSec.Type == elf.SHT_NOBITS || unwindSec.Type == elf.SHT_NOBITS {
+		return nil, nil
+	}
+	ips, err := sectionData(ipSec)
+	if err != nil {
+		return nil, fmt.Errorf("read .orc_unwind_ip: %w", err)
+	}
+	unwind, err := sectionData(unwindSec)
+	if err != nil {
+		return nil, fmt.Errorf("read .orc_unwind: %w", err)
+	}
+	n := len(ips) / 4
+	if n == 0 || len(unwind) != n*orcEntrySize {
+		return nil, nil
+	}
+
+	bo := f.ByteOrder
+	entries := make([]orcEntry, n)
+	for i := range entries {
+		rel := int64(int32(bo.Uint32(ips[4*i:])))
+		e := unwind[orcEntrySize*i:]
+		entries[i] = orcEntry{
+			ip:       ipSec.Addr + uint64(4*i) + uint64(rel),
+			spOffset: int16(bo.Uint16(e)),
+			spReg:    e[4] & 0x0f,
+			bpReg:    e[4] >> 4,
+		}
+	}
+	// The table of vmlinux is sorted at build time, that of other images
+	// may not be.
+	slices.SortStableFunc(entries, func(a, b orcEntry) int {
+		switch {
+		case a.ip < b.ip:
+			return -1
+		case a.ip > b.ip:
+			return 1
+		}
+		return 0
+	})
+	return entries, nil
+}
@@ -0,0 +1,134 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+// orcSource lays out two functions separated by padding, with the ORC table
+// objtool would generate for them: the entry state (CFA at SP+8), the frame
+// state after push rbp; mov rbp, rsp, the entry state again after pop rbp,
+// and undefined padding.
+const orcSource = `
+	.text
+	.globl first, second, first_frame, first_epilogue
+	.type first, @function
+first:
+	push %rbp
+	mov %rsp, %rbp
+first_frame:
+	mov $1, %eax
+	pop %rbp
+first_epilogue:
+	ret
+	.size first, . - first
+first_pad:
+	int3
+	int3
+	.type second, @function
+second:
+	mov $2, %eax
+	ret
+	.size second, . - second
+second_pad:
+	int3
+
+	.section .orc_unwind_ip, "a"
+	.long first - .
+	.long first_frame - .
+	.long first_epilogue - .
+	.long first_pad - .
+	.long second - .
+	.long second_pad - .
+
+	.section .orc_unwind, "a"
+	.short 8, 0
+	.byte 0x05, 2
+	.short 16, -16
+	.byte 0x14, 2
+	.short 8, 0
+	.byte 0x05, 2
+	.short 0, 0
+	.byte 0x00, 0
+	.short 8, 0
+	.byte 0x05, 2
+	.short 0, 0
+	.byte 0x00, 0
+`
+
+func TestORC(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("ORC test requires an amd64 assembler")
+	}
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "orc.s")
+	if err := os.WriteFile(src, []byte(orcSource), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	outPath := filepath.Join(dir, "orc")
+	cmd := exec.Command("gcc", "-nostdlib", "-static", "-Wl,-e,first", "-o", outPath, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to link orc.s: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	addr := make(map[string]uint64)
+	for _, s := range syms {
+		addr[s.Name] = s.Value
+	}
+
+	t.Run("detector", func(t *testing.T) {
+		candidates, err := resurgo.ORCDetector(f)
+		if err != nil {
+			t.Fatalf("resurgo.ORCDetector: %v", err)
+		}
+		var got []uint64
+		for _, c := range candidates {
+			if c.DetectionType != resurgo.DetectionORC || c.Confidence != resurgo.ConfidenceHigh {
+				t.Errorf("candidate 0x%x: unexpected %s/%s", c.Address, c.DetectionType, c.Confidence)
+			}
+			got = append(got, c.Address)
+		}
+		want := []uint64{addr["first"], addr["second"]}
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("got %#x, want %#x", got, want)
+		}
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		input := []resurgo.FunctionCandidate{
+			{Address: addr["first"], PrologueType: resurgo.PrologueClassic, Confidence: resurgo.ConfidenceMedium},
+			{Address: addr["first_frame"], PrologueType: resurgo.PrologueNoFramePointer, Confidence: resurgo.ConfidenceMedium},
+			{Address: addr["first_frame"] + 2, PrologueType: resurgo.PrologueNoFramePointer, Confidence: resurgo.ConfidenceMedium},
+			{Address: addr["second"], Confidence: resurgo.ConfidenceMedium},
+		}
+		result, err := resurgo.ORCFilter(input, f)
+		if err != nil {
+			t.Fatalf("resurgo.ORCFilter: %v", err)
+		}
+		want := []resurgo.Confidence{resurgo.ConfidenceMedium, resurgo.ConfidenceLow, resurgo.ConfidenceLow, resurgo.ConfidenceMedium}
+		for i, c := range result {
+			if c.Confidence != want[i] {
+				t.Errorf("candidate 0x%x: got %s, want %s", c.Address, c.Confidence, want[i])
+			}
+		}
+	})
+}
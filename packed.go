@@ -0,0 +1,171 @@
+package resurgo
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrPackedBinary is matched, with errors.Is, by the PackedError returned
+// for binaries whose code is compressed or encrypted by a packer. Their
+// stored code is not what runs, so detection would only find the unpacking
+// stub.
+var ErrPackedBinary = errors.New("packed binary")
+
+// PackedError is returned for a binary that looks packed. Unpack it, e.g.
+// with upx -d, before analysis, or analyze it anyway with
+// WithPackedCheck(false).
+type PackedError struct {
+	// Packer names the packer when it is recognized, e.g. "UPX"; it is
+	// empty when the binary only looks packed.
+	Packer string
+	// Reason is the evidence the binary is packed.
+	Reason string
+}
+
+func (e *PackedError) Error() string {
+	if e.Packer != "" {
+		return fmt.Sprintf("packed binary (%s): %s", e.Packer, e.Reason)
+	}
+	return fmt.Sprintf("packed binary: %s", e.Reason)
+}
+
+// Is reports whether target is ErrPackedBinary.
+func (e *PackedError) Is(target error) bool {
+	return target == ErrPackedBinary
+}
+
+// WithPackedCheck sets whether ELF, PE and Mach-O files are checked for
+// packers before analysis (default true). Packed files are rejected with a
+// PackedError.
+func WithPackedCheck(enabled bool) Option {
+	return func(o *options) {
+		o.packedCheck = enabled
+	}
+}
+
+const (
+	// upxMagic marks the headers UPX leaves in the files it packs.
+	upxMagic = "UPX!"
+	// upxScanSize is the length of the head of the file searched for
+	// upxMagic.
+	upxScanSize = 4096
+	// packedEntropy is the entropy, in bits per byte, above which code is
+	// taken for compressed or encrypted data. Machine code stays well
+	// below 7.
+	packedEntropy = 7.5
+	// minEntropySize is the smallest amount of code whose entropy is
+	// meaningful.
+	minEntropySize = 4096
+)
+
+// peSectionPackers maps the section names packers give PE files to the
+// packer.
+var peSectionPackers = map[string]string{
+	"UPX0":     "UPX",
+	"UPX1":     "UPX",
+	"UPX2":     "UPX",
+	".aspack":  "ASPack",
+	".adata":   "ASPack",
+	"MPRESS1":  "MPRESS",
+	"MPRESS2":  "MPRESS",
+	".petite":  "Petite",
+	".nsp0":    "NsPack",
+	".nsp1":    "NsPack",
+	"PEC2":     "PECompact",
+	"PEC2TO":   "PECompact",
+	".vmp0":    "VMProtect",
+	".vmp1":    "VMProtect",
+	".themida": "Themida",
+}
+
+// checkPackedELF returns a PackedError if f looks packed.
+func (o *options) checkPackedELF(f *elf.File) error {
+	if !o.packedCheck {
+		return nil
+	}
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_LOAD || p.Off != 0 {
+			continue
+		}
+		head := make([]byte, min(p.Filesz, upxScanSize))
+		if _, err := p.ReadAt(head, 0); err == nil && bytes.Contains(head, []byte(upxMagic)) {
+			return &PackedError{Packer: "UPX", Reason: "UPX header in the first loadable segment"}
+		}
+		break
+	}
+	// Packers often strip the section header table; the executable segments
+	// are checked then.
+	sections, err := elfCodeSections(f, "", o.budget)
+	if err != nil {
+		return nil
+	}
+	return checkEntropy(sections)
+}
+
+// checkPackedPE returns a PackedError if f, whose code is in sections,
+// looks packed.
+func (o *options) checkPackedPE(f *pe.File, sections []codeSection) error {
+	if !o.packedCheck {
+		return nil
+	}
+	for _, sec := range f.Sections {
+		if packer, ok := peSectionPackers[sec.Name]; ok {
+			return &PackedError{Packer: packer, Reason: fmt.Sprintf("section %s", sec.Name)}
+		}
+		// The code of an executable section without file data is only
+		// written at run time. MSVC reserves .textbss for incremental
+		// linking.
+		if sec.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE != 0 && sec.Size == 0 && sec.VirtualSize > 0 &&
+			sec.Name != ".textbss" {
+			return &PackedError{Reason: fmt.Sprintf("executable section %s has no file data", sec.Name)}
+		}
+	}
+	return checkEntropy(sections)
+}
+
+// checkPackedMachO returns a PackedError if f, whose code is in sections,
+// looks packed.
+func (o *options) checkPackedMachO(f *macho.File, sections []codeSection) error {
+	if !o.packedCheck {
+		return nil
+	}
+	if text := f.Segment("__TEXT"); text != nil {
+		head := make([]byte, min(text.Filesz, upxScanSize))
+		if _, err := text.ReadAt(head, 0); err == nil && bytes.Contains(head, []byte(upxMagic)) {
+			return &PackedError{Packer: "UPX", Reason: "UPX header in the __TEXT segment"}
+		}
+	}
+	return checkEntropy(sections)
+}
+
+// checkEntropy returns a PackedError if the code of sections, taken
+// together, has the entropy of compressed or encrypted data.
+func checkEntropy(sections []codeSection) error {
+	var counts [256]int
+	var total int
+	for _, sec := range sections {
+		for _, b := range sec.code {
+			counts[b]++
+		}
+		total += len(sec.code)
+	}
+	if total < minEntropySize {
+		return nil
+	}
+	var entropy float64
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(total)
+			entropy -= p * math.Log2(p)
+		}
+	}
+	if entropy > packedEntropy {
+		return &PackedError{Reason: fmt.Sprintf("executable code entropy %.2f bits per byte", entropy)}
+	}
+	return nil
+}
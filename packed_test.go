@@ -0,0 +1,96 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+	"github.com/maxgio92/resurgo/resurgotest"
+)
+
+func TestDetectFunctionsFromFile_Packed(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "hello.go")
+	if err := os.WriteFile(src, []byte("package main\n\nfunc main() { println(\"hello\") }\n"), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	build := func(goos, name string) []byte {
+		path := filepath.Join(dir, name)
+		resurgotest.CompileGo(t, src, path, "GOOS="+goos, "GOARCH=amd64", "CGO_ENABLED=0")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	exe, elfBin := build("windows", "hello.exe"), build("linux", "hello")
+
+	// upxPE renames the .text section the way UPX names its sections.
+	upxPE := bytes.Clone(exe)
+	i := bytes.Index(upxPE, []byte(".text\x00\x00\x00"))
+	if i < 0 {
+		t.Fatal("no .text section header")
+	}
+	copy(upxPE[i:], "UPX1\x00")
+
+	// upxELF carries the UPX header between the program headers and the
+	// code of the first loadable segment.
+	upxELF := bytes.Clone(elfBin)
+	copy(upxELF[0x800:], "UPX!")
+
+	// randomELF has its code replaced with random bytes, as if encrypted.
+	randomELF := bytes.Clone(elfBin)
+	f, err := elf.NewFile(bytes.NewReader(elfBin))
+	if err != nil {
+		t.Fatalf("failed to parse ELF: %v", err)
+	}
+	text := f.Section(".text")
+	rng := rand.New(rand.NewPCG(1, 2))
+	for j := text.Offset; j < text.Offset+text.Size; j++ {
+		randomELF[j] = byte(rng.Uint32())
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantPacker string
+	}{
+		{name: "pe upx sections", data: upxPE, wantPacker: "UPX"},
+		{name: "elf upx header", data: upxELF, wantPacker: "UPX"},
+		{name: "elf high entropy", data: randomELF},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "packed")
+			if err := os.WriteFile(path, tc.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := resurgo.DetectFunctionsFromFile(path)
+			if !errors.Is(err, resurgo.ErrPackedBinary) {
+				t.Fatalf("got error %v, want %v", err, resurgo.ErrPackedBinary)
+			}
+			var packed *resurgo.PackedError
+			if !errors.As(err, &packed) {
+				t.Fatalf("got error %T, want *resurgo.PackedError", err)
+			}
+			if packed.Packer != tc.wantPacker {
+				t.Errorf("got packer %q, want %q", packed.Packer, tc.wantPacker)
+			}
+			if _, err := resurgo.DetectFunctionsFromFile(path, resurgo.WithPackedCheck(false)); err != nil {
+				t.Errorf("with the packed check disabled: %v", err)
+			}
+		})
+	}
+
+	// Unpacked binaries pass the check.
+	for name, data := range map[string][]byte{"hello.exe": exe, "hello": elfBin} {
+		if _, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package resurgo
+
+import (
+	"cmp"
+	"debug/elf"
+	"encoding/binary"
+	"slices"
+
+	"golang.org/x/arch/arm64/arm64asm"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+const (
+	// Recognized kinds of padding between functions.
+	PaddingNOP  PaddingKind = "nop"
+	PaddingTrap PaddingKind = "trap"
+	PaddingZero PaddingKind = "zero"
+)
+
+// PaddingKind classifies the filler of a padding region.
+type PaddingKind string
+
+// PaddingRegion is a run of filler bytes between the end of a function and
+// the start of the next one.
+type PaddingRegion struct {
+	// Address is the virtual address of the first byte of the region.
+	Address uint64 `json:"address"`
+	// Size is the length of the region in bytes.
+	Size uint64 `json:"size"`
+	// Kind is the filler of the region: single- or multi-byte NOPs, INT3
+	// or BRK traps, or zero bytes.
+	Kind PaddingKind `json:"kind"`
+}
+
+// WithPaddingRegions sets whether DetectFunctionsFromFile and
+// DetectFunctionsFromReader report the padding between the functions of
+// x86 and ARM64 code in the Padding field of their results (default
+// false). The padding after a function runs from the end of its code, as
+// estimated by WithFunctionSizes, towards the next function, up to the
+// first byte that is not filler. Padding marks a function boundary, and
+// the estimated size of a function and the padding after it should add up
+// to the distance to the next one.
+func WithPaddingRegions(enabled bool) Option {
+	return func(o *options) {
+		o.paddingRegions = enabled
+	}
+}
+
+// DetectPaddingFromELF returns the padding regions between candidates, e.g.
+// those of DetectFunctionsFromELF called with the same opts, in f, ordered
+// by address, as WithPaddingRegions reports them. Only x86 and ARM64 files
+// are analyzed; others return no regions. opts may include WithLoadBias
+// and WithLimits.
+func DetectPaddingFromELF(f *elf.File, candidates []FunctionCandidate, opts ...Option) ([]PaddingRegion, error) {
+	arch, err := elfArch(f)
+	if err != nil {
+		return nil, err
+	}
+	if !descentArch(arch) {
+		return nil, nil
+	}
+	o := newOptions(opts...).withBudget()
+	regions, err := elfCodeSections(f, arch, o.budget)
+	if err != nil {
+		return nil, err
+	}
+	var bias uint64
+	if f.Type == elf.ET_DYN {
+		bias = o.loadBias
+	}
+	unbiased := make([]FunctionCandidate, len(candidates))
+	for i, c := range candidates {
+		unbiased[i] = FunctionCandidate{Address: c.Address - bias}
+	}
+	padding, err := o.withByteOrder(elfCodeByteOrder(f, arch)).findPadding(unbiased, regions, arch)
+	if err != nil {
+		return nil, err
+	}
+	for i := range padding {
+		padding[i].Address += bias
+	}
+	return padding, nil
+}
+
+// padding returns the padding regions between candidates in sections when
+// WithPaddingRegions is enabled.
+func (o *options) padding(candidates []FunctionCandidate, sections []codeSection, arch Arch) ([]PaddingRegion, error) {
+	if !o.paddingRegions {
+		return nil, nil
+	}
+	return o.withBudget().findPadding(slices.Clone(candidates), sections, arch)
+}
+
+// findPadding returns the padding regions after the candidates in regions,
+// ordered by address. The End of candidates is overwritten.
+func (o *options) findPadding(candidates []FunctionCandidate, regions []codeSection, arch Arch) ([]PaddingRegion, error) {
+	if err := o.setEnds(candidates, regions, arch); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(candidates, func(a, b FunctionCandidate) int {
+		return cmp.Compare(a.Address, b.Address)
+	})
+	native := make([]codeSection, len(regions))
+	for i, region := range regions {
+		native[i].addr, native[i].arch = region.addr, cmp.Or(region.arch, arch)
+		code, err := o.littleEndianCode(region.code, native[i].arch)
+		if err != nil {
+			return nil, err
+		}
+		native[i].code = code
+	}
+	var padding []PaddingRegion
+	for i, c := range candidates {
+		region, ok := regionAt(native, c.Address)
+		if !ok || c.End == 0 {
+			continue
+		}
+		code := region.code
+		next := region.addr + uint64(len(code))
+		if i+1 < len(candidates) {
+			next = min(next, candidates[i+1].Address)
+		}
+		if c.End >= next {
+			continue
+		}
+		padding = append(padding, paddingRuns(code[c.End-region.addr:next-region.addr], c.End, region.arch)...)
+	}
+	if err := o.budget.results(len(padding)); err != nil {
+		return nil, err
+	}
+	return padding, nil
+}
+
+// paddingRuns returns the runs of filler at the start of the little-endian
+// code of arch mapped at baseAddr, up to its first byte that is not
+// filler.
+func paddingRuns(code []byte, baseAddr uint64, arch Arch) []PaddingRegion {
+	var runs []PaddingRegion
+	add := func(off, n int, kind PaddingKind) {
+		if last := len(runs) - 1; last >= 0 && runs[last].Kind == kind {
+			runs[last].Size += uint64(n)
+			return
+		}
+		runs = append(runs, PaddingRegion{Address: baseAddr + uint64(off), Size: uint64(n), Kind: kind})
+	}
+	for off := 0; off < len(code); {
+		n, kind := paddingAt(code, off, arch)
+		if n == 0 {
+			break
+		}
+		add(off, n, kind)
+		off += n
+	}
+	return runs
+}
+
+// paddingAt returns the length and kind of the filler instruction or byte
+// at code[off], or zero if it is not filler.
+func paddingAt(code []byte, off int, arch Arch) (int, PaddingKind) {
+	switch arch {
+	case ArchAMD64, ArchX86:
+		if n := zeroPaddingAMD64(code, off); n > 0 {
+			return n, PaddingZero
+		}
+		mode := 64
+		if arch == ArchX86 {
+			mode = 32
+		}
+		inst, err := x86asm.Decode(code[off:], mode)
+		switch {
+		case err != nil:
+		case isNOPLike(inst):
+			return inst.Len, PaddingNOP
+		case isTrapAMD64(inst):
+			return inst.Len, PaddingTrap
+		}
+	case ArchARM64:
+		const insnLen = 4
+		if off+insnLen > len(code) {
+			break
+		}
+		word := binary.LittleEndian.Uint32(code[off:])
+		if word == 0 {
+			return insnLen, PaddingZero
+		}
+		inst, err := arm64asm.Decode(code[off : off+insnLen])
+		switch {
+		case err != nil:
+		case inst.Op == arm64asm.NOP:
+			return insnLen, PaddingNOP
+		case inst.Op == arm64asm.BRK:
+			return insnLen, PaddingTrap
+		}
+	}
+	return 0, ""
+}
+
+// zeroPaddingAMD64 returns 1 if the byte at code[off] is zero padding: a
+// zero byte followed by another one or ending code. A zero byte followed
+// by another byte is the start of an ADD instruction.
+func zeroPaddingAMD64(code []byte, off int) int {
+	if code[off] == 0 && (off+1 == len(code) || code[off+1] == 0) {
+		return 1
+	}
+	return 0
+}
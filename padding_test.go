@@ -0,0 +1,75 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestWithPaddingRegions(t *testing.T) {
+	tests := []struct {
+		name string
+		code []byte
+		arch resurgo.Arch
+		want []resurgo.PaddingRegion
+	}{{
+		name: "amd64",
+		code: []byte{
+			0x55,             // 0x1000: push rbp
+			0x48, 0x89, 0xe5, // 0x1001: mov rbp, rsp
+			0x5d,                                           // 0x1004: pop rbp
+			0xc3,                                           // 0x1005: ret
+			0x0f, 0x1f, 0x84, 0x00, 0x00, 0x00, 0x00, 0x00, // 0x1006: nop dword [rax+rax]
+			0x66, 0x90, // 0x100e: xchg ax, ax
+			0x55,             // 0x1010: push rbp
+			0x48, 0x89, 0xe5, // 0x1011: mov rbp, rsp
+			0x5d,                   // 0x1014: pop rbp
+			0xc3,                   // 0x1015: ret
+			0xcc, 0xcc, 0xcc, 0xcc, // 0x1016: int3
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // 0x101a: zero fill
+			0x55,             // 0x1020: push rbp
+			0x48, 0x89, 0xe5, // 0x1021: mov rbp, rsp
+			0x5d, // 0x1024: pop rbp
+			0xc3, // 0x1025: ret
+		},
+		arch: resurgo.ArchAMD64,
+		want: []resurgo.PaddingRegion{
+			{Address: 0x1006, Size: 10, Kind: resurgo.PaddingNOP},
+			{Address: 0x1016, Size: 4, Kind: resurgo.PaddingTrap},
+			{Address: 0x101a, Size: 6, Kind: resurgo.PaddingZero},
+		},
+	}, {
+		name: "arm64",
+		code: []byte{
+			0xfd, 0x7b, 0xbf, 0xa9, // 0x1000: stp x29, x30, [sp, #-16]!
+			0xfd, 0x03, 0x00, 0x91, // 0x1004: mov x29, sp
+			0xfd, 0x7b, 0xc1, 0xa8, // 0x1008: ldp x29, x30, [sp], #16
+			0xc0, 0x03, 0x5f, 0xd6, // 0x100c: ret
+			0x1f, 0x20, 0x03, 0xd5, // 0x1010: nop
+			0x00, 0x00, 0x00, 0x00, // 0x1014: zero fill
+			0xfd, 0x7b, 0xbf, 0xa9, // 0x1018: stp x29, x30, [sp, #-16]!
+			0xfd, 0x03, 0x00, 0x91, // 0x101c: mov x29, sp
+			0xfd, 0x7b, 0xc1, 0xa8, // 0x1020: ldp x29, x30, [sp], #16
+			0xc0, 0x03, 0x5f, 0xd6, // 0x1024: ret
+		},
+		arch: resurgo.ArchARM64,
+		want: []resurgo.PaddingRegion{
+			{Address: 0x1010, Size: 4, Kind: resurgo.PaddingNOP},
+			{Address: 0x1014, Size: 4, Kind: resurgo.PaddingZero},
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := resurgo.DetectFunctionsFromReader(bytes.NewReader(tt.code), int64(len(tt.code)),
+				resurgo.WithArch(tt.arch), resurgo.WithBaseAddress(0x1000), resurgo.WithPaddingRegions(true))
+			if err != nil {
+				t.Fatalf("resurgo.DetectFunctionsFromReader: %v", err)
+			}
+			if !reflect.DeepEqual(result.Padding, tt.want) {
+				t.Errorf("got padding %+v, want %+v (functions %+v)", result.Padding, tt.want, result.Functions)
+			}
+		})
+	}
+}
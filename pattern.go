@@ -0,0 +1,207 @@
+package resurgo
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ProloguePattern is a user-defined prologue pattern, matched by the
+// disassembly-based detection alongside the built-in ones once registered
+// with RegisterProloguePattern. It describes the prologue of a compiler
+// resurgo does not know about without forking the detector.
+//
+// Bytes and After are written in a small byte-matching language: a
+// whitespace-separated sequence of bytes, each two hex digits. "??" matches
+// any byte and "?" any nibble, e.g. "4?" a REX prefix; "vv/mm" matches the
+// bits set in the mask mm against vv, e.g. "e0/e0" any byte with its top
+// three bits set. Code is matched in little-endian instruction order: on
+// big-endian targets the bytes of each instruction word are written least
+// significant first.
+//
+// For instance, a prologue saving rbx before setting up the frame pointer,
+// only after a return, int3 or NOP:
+//
+//	resurgo.ProloguePattern{
+//		Type:  "push-rbx-frame",
+//		Arch:  resurgo.ArchAMD64,
+//		Bytes: "53 55 48 89 e5",
+//		After: []string{"c3", "cc", "90"},
+//	}
+type ProloguePattern struct {
+	// Type is the prologue type reported for matches. Types unknown to
+	// LookupPrologueType are registered with ConfidenceMedium.
+	Type PrologueType
+	// Arch is the architecture of the code the pattern applies to.
+	Arch Arch
+	// Bytes is the pattern matched at the prologue address.
+	Bytes string
+	// After, when set, requires one of its patterns to match the bytes
+	// immediately preceding the prologue, e.g. a return; otherwise the
+	// pattern matches anywhere.
+	After []string
+	// Align is the alignment of the prologue address, relative to the
+	// start of the scanned code; zero or one matches at every byte.
+	Align int
+	// Description is the label of Type when it is registered; it defaults
+	// to the pattern itself.
+	Description string
+}
+
+// bytePattern is a compiled byte-matching expression.
+type bytePattern struct {
+	value, mask []byte
+}
+
+// compiledPattern is a registered ProloguePattern, compiled.
+type compiledPattern struct {
+	ProloguePattern
+	bytes bytePattern
+	after []bytePattern
+}
+
+var (
+	prologuePatternsMu sync.RWMutex
+	// prologuePatterns holds the registered patterns in registration order.
+	prologuePatterns []compiledPattern
+)
+
+// RegisterProloguePattern makes DetectPrologues and the disassembly-based
+// pipeline report the matches of p in code of p.Arch, built in or
+// registered with RegisterArch, as prologues of type p.Type ranked after
+// the built-in types. Several patterns may share a type; a match of either
+// is reported once. It returns an error if p has no type or architecture,
+// or if Bytes or After is malformed.
+func RegisterProloguePattern(p ProloguePattern) error {
+	if p.Type == "" {
+		return fmt.Errorf("empty prologue type")
+	}
+	if p.Arch == "" {
+		return fmt.Errorf("prologue pattern %s: empty architecture", p.Type)
+	}
+	c := compiledPattern{ProloguePattern: p}
+	var err error
+	if c.bytes, err = compileBytePattern(p.Bytes); err != nil {
+		return fmt.Errorf("prologue pattern %s: %w", p.Type, err)
+	}
+	for _, after := range p.After {
+		bp, err := compileBytePattern(after)
+		if err != nil {
+			return fmt.Errorf("prologue pattern %s: after: %w", p.Type, err)
+		}
+		c.after = append(c.after, bp)
+	}
+	c.After = slices.Clone(p.After)
+
+	if _, ok := LookupPrologueType(p.Type); !ok {
+		desc := cmp.Or(p.Description, p.Bytes)
+		// A concurrent registration of the type is as good as ours.
+		_ = RegisterPrologueType(PrologueInfo{Type: p.Type, Description: desc, Arch: p.Arch, Confidence: ConfidenceMedium})
+	}
+	prologuePatternsMu.Lock()
+	defer prologuePatternsMu.Unlock()
+	prologuePatterns = append(prologuePatterns, c)
+	return nil
+}
+
+// compileBytePattern compiles the byte-matching expression s.
+func compileBytePattern(s string) (bytePattern, error) {
+	var bp bytePattern
+	for _, tok := range strings.Fields(s) {
+		var value, mask byte
+		if v, m, ok := strings.Cut(tok, "/"); ok {
+			vb, err := strconv.ParseUint(v, 16, 8)
+			if err != nil || len(v) != 2 {
+				return bytePattern{}, fmt.Errorf("invalid byte %q", tok)
+			}
+			mb, err := strconv.ParseUint(m, 16, 8)
+			if err != nil || len(m) != 2 {
+				return bytePattern{}, fmt.Errorf("invalid mask %q", tok)
+			}
+			value, mask = byte(vb)&byte(mb), byte(mb)
+		} else {
+			if len(tok) != 2 {
+				return bytePattern{}, fmt.Errorf("invalid byte %q", tok)
+			}
+			for i := range 2 {
+				shift := uint(4 * (1 - i))
+				if tok[i] == '?' {
+					continue
+				}
+				n, err := strconv.ParseUint(tok[i:i+1], 16, 8)
+				if err != nil {
+					return bytePattern{}, fmt.Errorf("invalid byte %q", tok)
+				}
+				value |= byte(n) << shift
+				mask |= 0xf << shift
+			}
+		}
+		bp.value = append(bp.value, value)
+		bp.mask = append(bp.mask, mask)
+	}
+	if len(bp.value) == 0 {
+		return bytePattern{}, fmt.Errorf("empty pattern")
+	}
+	return bp, nil
+}
+
+// matchAt reports whether bp matches code at offset i.
+func (bp bytePattern) matchAt(code []byte, i int) bool {
+	if i < 0 || i+len(bp.value) > len(code) {
+		return false
+	}
+	for j, v := range bp.value {
+		if code[i+j]&bp.mask[j] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// matchProloguePatterns returns the matches of the patterns registered for
+// arch in code, mapped at baseAddr, one per address and type.
+func matchProloguePatterns(code []byte, baseAddr uint64, arch Arch) []Prologue {
+	prologuePatternsMu.RLock()
+	var patterns []compiledPattern
+	for _, p := range prologuePatterns {
+		if p.Arch == arch {
+			patterns = append(patterns, p)
+		}
+	}
+	prologuePatternsMu.RUnlock()
+
+	type key struct {
+		addr uint64
+		typ  PrologueType
+	}
+	seen := make(map[key]struct{})
+	var result []Prologue
+	for _, p := range patterns {
+		step := max(p.Align, 1)
+		for i := 0; i+len(p.bytes.value) <= len(code); i += step {
+			if !p.bytes.matchAt(code, i) {
+				continue
+			}
+			if len(p.after) > 0 && !slices.ContainsFunc(p.after, func(a bytePattern) bool {
+				return a.matchAt(code, i-len(a.value))
+			}) {
+				continue
+			}
+			k := key{baseAddr + uint64(i), p.Type}
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+			result = append(result, Prologue{
+				Address:      k.addr,
+				Type:         p.Type,
+				Instructions: p.Bytes,
+				Size:         uint64(len(p.bytes.value)),
+			})
+		}
+	}
+	return result
+}
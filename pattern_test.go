@@ -0,0 +1,89 @@
+package resurgo_test
+
+import (
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestRegisterProloguePattern(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		p    resurgo.ProloguePattern
+	}{
+		{"no type", resurgo.ProloguePattern{Arch: resurgo.ArchAMD64, Bytes: "55"}},
+		{"no arch", resurgo.ProloguePattern{Type: "test-invalid", Bytes: "55"}},
+		{"empty", resurgo.ProloguePattern{Type: "test-invalid", Arch: resurgo.ArchAMD64}},
+		{"odd digits", resurgo.ProloguePattern{Type: "test-invalid", Arch: resurgo.ArchAMD64, Bytes: "5 48"}},
+		{"not hex", resurgo.ProloguePattern{Type: "test-invalid", Arch: resurgo.ArchAMD64, Bytes: "5g"}},
+		{"bad mask", resurgo.ProloguePattern{Type: "test-invalid", Arch: resurgo.ArchAMD64, Bytes: "55/f"}},
+		{"bad after", resurgo.ProloguePattern{Type: "test-invalid", Arch: resurgo.ArchAMD64, Bytes: "55", After: []string{"zz"}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := resurgo.RegisterProloguePattern(tt.p); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+	if _, ok := resurgo.LookupPrologueType("test-invalid"); ok {
+		t.Error("invalid pattern registered its type")
+	}
+
+	// push rbx; push rbp; mov rbp, rsp (with or without REX.W) after hlt,
+	// which no test binary emits before a function.
+	const typ resurgo.PrologueType = "test-hlt-push-frame"
+	for _, bytes := range []string{"53 55 48/fc 89 e5", "53 55 89 e5"} {
+		p := resurgo.ProloguePattern{
+			Type:        typ,
+			Arch:        resurgo.ArchAMD64,
+			Bytes:       bytes,
+			After:       []string{"f4"},
+			Description: "rbx save before frame setup",
+		}
+		if err := resurgo.RegisterProloguePattern(p); err != nil {
+			t.Fatalf("RegisterProloguePattern(%q): %v", bytes, err)
+		}
+	}
+	info, ok := resurgo.LookupPrologueType(typ)
+	if !ok || info.Description != "rbx save before frame setup" || info.Arch != resurgo.ArchAMD64 {
+		t.Errorf("LookupPrologueType(%s) = %+v, %v", typ, info, ok)
+	}
+
+	for _, tt := range []struct {
+		name string
+		code []byte
+		want []uint64
+	}{{
+		name: "after hlt",
+		code: []byte{0xf4, 0x53, 0x55, 0x48, 0x89, 0xe5, 0xf4, 0x53, 0x55, 0x89, 0xe5},
+		want: []uint64{0x1001, 0x1007},
+	}, {
+		// 0x4c sets REX.R, which the mask rules out.
+		name: "masked out",
+		code: []byte{0xf4, 0x53, 0x55, 0x4c, 0x89, 0xe5},
+	}, {
+		name: "not after hlt",
+		code: []byte{0x90, 0x53, 0x55, 0x48, 0x89, 0xe5},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, 0x1000, resurgo.ArchAMD64)
+			if err != nil {
+				t.Fatalf("DetectPrologues: %v", err)
+			}
+			var got []uint64
+			for _, p := range prologues {
+				if p.Type == typ {
+					got = append(got, p.Address)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %s prologues at %#x, want %#x", typ, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got %s prologues at %#x, want %#x", typ, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"debug/gosym"
+	"fmt"
+	"slices"
+)
+
+// DetectionPclntab is assigned to function candidates read from the pclntab
+// of a Go binary, the function table the Go runtime needs for stack traces
+// and garbage collection, which is kept when the binary is stripped.
+const DetectionPclntab DetectionType = "pclntab"
+
+// PclntabDetector is a CandidateDetector that emits the entry of every
+// function in the pclntab of a Go binary, read from .gopclntab or, when the
+// section is absent, from the runtime.pclntab symbol. Each candidate carries
+// DetectionPclntab and ConfidenceHigh. Returns an empty slice (no error)
+// when f is not a Go binary. It is not part of the default pipeline.
+func PclntabDetector(f *elf.File) ([]FunctionCandidate, error) {
+	entries, err := pclntabEntries(f)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]FunctionCandidate, 0, len(entries))
+	for _, va := range entries {
+		candidates = append(candidates, FunctionCandidate{
+			Address:       va,
+			DetectionType: DetectionPclntab,
+			Confidence:    ConfidenceHigh,
+		})
+	}
+	return candidates, nil
+}
+
+// VerifyGoCandidates checks candidates detected in the Go binary f against
+// the functions of its pclntab. Unlike VerifyCandidates it needs no debug
+// file: the pclntab is an accuracy oracle present in every Go binary,
+// stripped or not. It returns an error if f has no pclntab.
+func VerifyGoCandidates(candidates []FunctionCandidate, f *elf.File) (*Verification, error) {
+	entries, err := pclntabEntries(f)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		return nil, fmt.Errorf("no pclntab")
+	}
+	truth := make(map[uint64]struct{}, len(entries))
+	for _, va := range entries {
+		truth[va] = struct{}{}
+	}
+	return verify(candidates, truth), nil
+}
+
+// pclntabEntries returns the sorted, deduplicated entries of the functions
+// in the pclntab of f that fall in an executable section, or nil if f has
+// no pclntab.
+func pclntabEntries(f *elf.File) ([]uint64, error) {
+	data, err := pclntabData(f)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(data, pclntabTextStart(f, data)))
+	if err != nil {
+		return nil, fmt.Errorf("parse pclntab: %w", err)
+	}
+	exec := execRanges(f)
+	entries := make([]uint64, 0, len(table.Funcs))
+	for _, fn := range table.Funcs {
+		if inRanges(fn.Entry, exec) {
+			entries = append(entries, fn.Entry)
+		}
+	}
+	slices.Sort(entries)
+	return slices.Compact(entries), nil
+}
+
+// pclntabData reads the pclntab of f from .gopclntab, or from the bytes
+// between the runtime.pclntab and runtime.epclntab symbols of binaries
+// linked without the section, e.g. by an external linker.
+func pclntabData(f *elf.File) ([]byte, error) {
+	if sec := f.Section(".gopclntab"); sec != nil && sec.Type != elf.SHT_NOBITS {
+		data, err := sectionData(sec)
+		if err != nil {
+			return nil, fmt.Errorf("read .gopclntab: %w", err)
+		}
+		return data, nil
+	}
+	start, end := goSymbol(f, "runtime.pclntab"), goSymbol(f, "runtime.epclntab")
+	if start == 0 || end <= start {
+		return nil, nil
+	}
+	for _, sec := range f.Sections {
+		if sec.Type == elf.SHT_NOBITS || sec.Flags&elf.SHF_ALLOC == 0 || start < sec.Addr || end > sec.Addr+sec.Size {
+			continue
+		}
+		data, err := sectionData(sec)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", sec.Name, err)
+		}
+		return data[start-sec.Addr : end-sec.Addr], nil
+	}
+	return nil, nil
+}
+
+// pclntabTextStart returns the address function entries in the pclntab
+// data of f are relative to, in the Go 1.18 and later formats: the
+// runtime.text address recorded in the header, unless the header was left
+// unrelocated as in position-independent binaries, then that of the
+// runtime.text symbol, then the start of .text. Earlier formats store
+// absolute addresses, and 0 is returned.
+func pclntabTextStart(f *elf.File, data []byte) uint64 {
+	const (
+		headerPrefix = 8 // magic, padding, instruction size quantum, pointer size
+		go118Magic   = 0xfffffff0
+		go120Magic   = 0xfffffff1
+	)
+	if len(data) >= headerPrefix {
+		if magic := f.ByteOrder.Uint32(data); magic != go118Magic && magic != go120Magic {
+			return 0
+		}
+		switch ptrSize := int(data[7]); ptrSize {
+		case 4, 8:
+			off := headerPrefix + 2*ptrSize // after nfunc and nfiles
+			if len(data) >= off+ptrSize {
+				var start uint64
+				if ptrSize == 8 {
+					start = f.ByteOrder.Uint64(data[off:])
+				} else {
+					start = uint64(f.ByteOrder.Uint32(data[off:]))
+				}
+				if start != 0 {
+					return start
+				}
+			}
+		}
+	}
+	if start := goSymbol(f, "runtime.text"); start != 0 {
+		return start
+	}
+	if text := f.Section(".text"); text != nil {
+		return text.Addr
+	}
+	return 0
+}
+
+// goSymbol returns the value of the symbol name of f, or 0 if f has no such
+// symbol, e.g. because it was stripped.
+func goSymbol(f *elf.File, name string) uint64 {
+	syms, err := f.Symbols()
+	if err != nil {
+		return 0
+	}
+	for _, s := range syms {
+		if s.Name == name {
+			return s.Value
+		}
+	}
+	return 0
+}
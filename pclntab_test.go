@@ -0,0 +1,109 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestPclntabDetector(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found, skipping")
+	}
+	dir := t.TempDir()
+	build := func(t *testing.T, name string, args ...string) *elf.File {
+		t.Helper()
+		binPath := filepath.Join(dir, name)
+		cmd := exec.Command("go", append(append([]string{"build", "-o", binPath}, args...), demoAppSource)...)
+		cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOARCH=amd64")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("failed to compile demo-app: %v\n%s", err, out)
+		}
+		f, err := elf.Open(binPath)
+		if err != nil {
+			t.Fatalf("failed to open ELF: %v", err)
+		}
+		t.Cleanup(func() { f.Close() })
+		return f
+	}
+
+	f := build(t, demoAppBinary)
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	text := f.Section(".text")
+	// Markers such as runtime.etext are empty function symbols.
+	funcs := make(map[uint64]bool)
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) == elf.STT_FUNC && s.Size > 0 && s.Value >= text.Addr && s.Value < text.Addr+text.Size {
+			funcs[s.Value] = true
+		}
+	}
+
+	candidates, err := resurgo.PclntabDetector(f)
+	if err != nil {
+		t.Fatalf("resurgo.PclntabDetector: %v", err)
+	}
+	got := make(map[uint64]bool)
+	for _, c := range candidates {
+		if c.DetectionType != resurgo.DetectionPclntab || c.Confidence != resurgo.ConfidenceHigh {
+			t.Errorf("candidate 0x%x: unexpected %s/%s", c.Address, c.DetectionType, c.Confidence)
+		}
+		got[c.Address] = true
+	}
+	for addr := range funcs {
+		if !got[addr] {
+			t.Errorf("function at 0x%x not detected", addr)
+		}
+	}
+	for addr := range got {
+		if !funcs[addr] {
+			t.Errorf("candidate 0x%x is not a function symbol", addr)
+		}
+	}
+
+	// The pclntab survives stripping, at the same addresses.
+	t.Run("stripped", func(t *testing.T) {
+		stripped := build(t, demoAppBinary+"-stripped", "-ldflags=-s -w")
+		candidates, err := resurgo.PclntabDetector(stripped)
+		if err != nil {
+			t.Fatalf("resurgo.PclntabDetector: %v", err)
+		}
+		if len(candidates) != len(got) {
+			t.Errorf("got %d candidates, want %d", len(candidates), len(got))
+		}
+		for _, c := range candidates {
+			if !got[c.Address] {
+				t.Errorf("candidate 0x%x not in the unstripped pclntab", c.Address)
+			}
+		}
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		v, err := resurgo.VerifyGoCandidates(candidates, f)
+		if err != nil {
+			t.Fatalf("resurgo.VerifyGoCandidates: %v", err)
+		}
+		if v.Functions != len(got) || v.Precision != 1 || v.Recall != 1 {
+			t.Errorf("pclntab against itself: %+v", v)
+		}
+
+		heuristic, err := resurgo.DetectFunctionsFromELF(f)
+		if err != nil {
+			t.Fatalf("resurgo.DetectFunctionsFromELF: %v", err)
+		}
+		v, err = resurgo.VerifyGoCandidates(heuristic, f)
+		if err != nil {
+			t.Fatalf("resurgo.VerifyGoCandidates: %v", err)
+		}
+		if v.TruePositives == 0 || v.TruePositives+len(v.FalsePositives) != len(heuristic) {
+			t.Errorf("unexpected verification: %d true positives, %d false positives, %d candidates",
+				v.TruePositives, len(v.FalsePositives), len(heuristic))
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package resurgo
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+)
+
+// DetectionPData is assigned to function candidates whose entry address was
+// read from a RUNTIME_FUNCTION entry of the exception directory (.pdata) of
+// an x64 PE file. The linker writes one entry per function that allocates
+// stack or saves registers, so, like CFI on ELF, the addresses are reliable
+// on stripped binaries.
+const DetectionPData DetectionType = "pdata"
+
+const (
+	// runtimeFunctionSize is the size of an x64 RUNTIME_FUNCTION entry:
+	// BeginAddress, EndAddress and UnwindInfoAddress, all image-relative.
+	runtimeFunctionSize = 12
+	// unwFlagChainInfo marks the UNWIND_INFO of a function fragment whose
+	// unwinding continues with the RUNTIME_FUNCTION of its primary part,
+	// e.g. a block moved out of line by the compiler.
+	unwFlagChainInfo = 0x4
+)
+
+// pdataCandidates returns a ConfidenceHigh candidate at the BeginAddress of
+// every RUNTIME_FUNCTION entry of the x64 PE file f. Entries whose unwind
+// information chains to another entry describe fragments of a function,
+// not its start, and are skipped. Files of other machines, and files
+// without an exception directory, yield no candidates.
+func pdataCandidates(f *pe.File) ([]FunctionCandidate, error) {
+	h, ok := f.OptionalHeader.(*pe.OptionalHeader64)
+	if !ok || f.Machine != pe.IMAGE_FILE_MACHINE_AMD64 || h.NumberOfRvaAndSizes <= pe.IMAGE_DIRECTORY_ENTRY_EXCEPTION {
+		return nil, nil
+	}
+	dir := h.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXCEPTION]
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return nil, nil
+	}
+	// cache holds the section data read so far, as most unwind information
+	// shares one section.
+	cache := make(map[*pe.Section][]byte)
+	data, err := peReadRVA(f, cache, dir.VirtualAddress, dir.Size)
+	if err != nil {
+		return nil, fmt.Errorf("read exception directory: %w", err)
+	}
+
+	candidates := make([]FunctionCandidate, 0, len(data)/runtimeFunctionSize)
+	for off := 0; off+runtimeFunctionSize <= len(data); off += runtimeFunctionSize {
+		begin := binary.LittleEndian.Uint32(data[off:])
+		end := binary.LittleEndian.Uint32(data[off+4:])
+		unwind := binary.LittleEndian.Uint32(data[off+8:])
+		if begin == 0 || end <= begin {
+			continue
+		}
+		if info, err := peReadRVA(f, cache, unwind, 1); err == nil && info[0]>>3&unwFlagChainInfo != 0 {
+			continue
+		}
+		candidates = append(candidates, FunctionCandidate{
+			Address:       h.ImageBase + uint64(begin),
+			DetectionType: DetectionPData,
+			Confidence:    ConfidenceHigh,
+		})
+	}
+	return candidates, nil
+}
+
+// peReadRVA returns the size bytes of f at the image-relative address rva.
+// Section data is read once and kept in cache.
+func peReadRVA(f *pe.File, cache map[*pe.Section][]byte, rva, size uint32) ([]byte, error) {
+	for _, sec := range f.Sections {
+		if rva < sec.VirtualAddress || rva-sec.VirtualAddress >= max(sec.VirtualSize, sec.Size) {
+			continue
+		}
+		data, ok := cache[sec]
+		if !ok {
+			var err error
+			if data, err = sec.Data(); err != nil {
+				return nil, fmt.Errorf("read %s: %w", sec.Name, err)
+			}
+			cache[sec] = data
+		}
+		start := uint64(rva - sec.VirtualAddress)
+		if start+uint64(size) > uint64(len(data)) {
+			return nil, fmt.Errorf("%s: range %#x+%#x out of bounds", sec.Name, rva, size)
+		}
+		return data[start : start+uint64(size)], nil
+	}
+	return nil, fmt.Errorf("address %#x not in any section", rva)
+}
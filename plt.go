@@ -0,0 +1,72 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"slices"
+)
+
+// FunctionKind classifies the code a prologue or a function candidate was
+// found in, when it is not ordinary compiled code.
+type FunctionKind string
+
+// KindPLTStub marks results inside the procedure linkage table: stubs the
+// linker generates to jump to functions of other objects, which look like
+// tiny functions.
+const KindPLTStub FunctionKind = "plt-stub"
+
+// pltSections lists the names of the sections linkers emit PLT stubs into.
+var pltSections = []string{".plt", ".plt.got", ".plt.sec", ".plt.bnd", ".iplt"}
+
+// isPLTSection reports whether sec holds PLT stubs.
+func isPLTSection(sec *elf.Section) bool {
+	return slices.Contains(pltSections, sec.Name)
+}
+
+// pltRanges returns the [lo, hi) address ranges of the PLT sections of f.
+func pltRanges(f *elf.File) [][2]uint64 {
+	if f.Type == elf.ET_REL {
+		return nil
+	}
+	var ranges [][2]uint64
+	for _, sec := range f.Sections {
+		if isPLTSection(sec) {
+			ranges = append(ranges, [2]uint64{sec.Addr, sec.Addr + sec.Size})
+		}
+	}
+	return ranges
+}
+
+// WithPLTStubs sets whether DetectProloguesFromELF and DetectFunctionsFromELF
+// report results inside the PLT sections (.plt, .plt.got, .plt.sec,
+// .plt.bnd, .iplt), tagged KindPLTStub (default true). The default filter
+// pipeline of DetectFunctionsFromELF still ends with PLTFilter, which
+// removes the stub candidates: keeping them takes a pipeline without it,
+// set with WithFilters. When disabled, the PLT sections are not scanned and
+// candidates inside them are dropped, even if the filter pipeline does not
+// include PLTFilter.
+func WithPLTStubs(enabled bool) Option {
+	return func(o *options) {
+		o.pltStubs = enabled
+	}
+}
+
+// markPLTStubs tags the candidates inside the PLT sections of f with
+// KindPLTStub, or drops them when PLT stubs are not reported.
+func (o *options) markPLTStubs(candidates []FunctionCandidate, f *elf.File) []FunctionCandidate {
+	ranges := pltRanges(f)
+	if len(ranges) == 0 {
+		return candidates
+	}
+	if !o.pltStubs {
+		return FilterCandidatesInRanges(candidates, ranges)
+	}
+	for i, c := range candidates {
+		for _, r := range ranges {
+			if c.Address >= r[0] && c.Address < r[1] {
+				candidates[i].Kind = KindPLTStub
+				break
+			}
+		}
+	}
+	return candidates
+}
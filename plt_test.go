@@ -0,0 +1,89 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestWithPLTStubs(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	outPath := filepath.Join(t.TempDir(), "demo-app-c")
+	cmd := exec.Command("gcc", "-O0", "-o", outPath, "testdata/demo-app.c")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF binary: %v", err)
+	}
+	defer f.Close()
+
+	var plt [][2]uint64
+	for _, sec := range f.Sections {
+		if slices.Contains([]string{".plt", ".plt.got", ".plt.sec"}, sec.Name) {
+			plt = append(plt, [2]uint64{sec.Addr, sec.Addr + sec.Size})
+		}
+	}
+	if len(plt) == 0 {
+		t.Skip("no PLT section, skipping")
+	}
+	inPLT := func(addr uint64) bool {
+		return slices.ContainsFunc(plt, func(r [2]uint64) bool { return addr >= r[0] && addr < r[1] })
+	}
+
+	// Without PLTFilter, the calls to libc functions leave candidates in
+	// the PLT.
+	candidates, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithFilters())
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromELF: %v", err)
+	}
+	stubs := 0
+	for _, c := range candidates {
+		if got, want := c.Kind == resurgo.KindPLTStub, inPLT(c.Address); got != want {
+			t.Errorf("0x%x: got kind %q, in PLT: %t", c.Address, c.Kind, want)
+		}
+		if c.Kind == resurgo.KindPLTStub {
+			stubs++
+		}
+	}
+	if stubs == 0 {
+		t.Error("no candidate tagged as a PLT stub")
+	}
+	prologues, err := resurgo.DetectProloguesFromELF(f)
+	if err != nil {
+		t.Fatalf("DetectProloguesFromELF: %v", err)
+	}
+	for _, p := range prologues {
+		if got, want := p.Kind == resurgo.KindPLTStub, inPLT(p.Address); got != want {
+			t.Errorf("prologue at 0x%x in %s: got kind %q, in PLT: %t", p.Address, p.Section, p.Kind, want)
+		}
+	}
+
+	// WithPLTStubs(false) drops them.
+	candidates, err = resurgo.DetectFunctionsFromELF(f, resurgo.WithFilters(), resurgo.WithPLTStubs(false))
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromELF: %v", err)
+	}
+	prologues, err = resurgo.DetectProloguesFromELF(f, resurgo.WithPLTStubs(false))
+	if err != nil {
+		t.Fatalf("DetectProloguesFromELF: %v", err)
+	}
+	for _, c := range candidates {
+		if inPLT(c.Address) {
+			t.Errorf("0x%x: candidate in PLT with WithPLTStubs(false)", c.Address)
+		}
+	}
+	for _, p := range prologues {
+		if inPLT(p.Address) {
+			t.Errorf("0x%x: prologue in PLT with WithPLTStubs(false)", p.Address)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package resurgo
+
+import (
+	"bufio"
+	"bytes"
+	"debug/elf"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DetectionPlugin is assigned to function candidates returned by an external
+// plugin that did not set a detection type of its own.
+const DetectionPlugin DetectionType = "plugin"
+
+// CodeView is an executable section of a binary as streamed to a plugin.
+type CodeView struct {
+	// Section is the name of the section.
+	Section string `json:"section"`
+	// Address is the virtual address of the first byte of Code.
+	Address uint64 `json:"address"`
+	// Arch is the architecture of the code.
+	Arch Arch `json:"arch"`
+	// Code holds the bytes of the section, base64-encoded in JSON.
+	Code []byte `json:"code"`
+}
+
+// pluginRequest and pluginResponse are the JSON-RPC 2.0 messages exchanged
+// with a plugin, one per line.
+type pluginRequest struct {
+	JSONRPC string    `json:"jsonrpc"`
+	ID      int       `json:"id"`
+	Method  string    `json:"method"`
+	Params  *CodeView `json:"params"`
+}
+
+type pluginResponse struct {
+	JSONRPC string              `json:"jsonrpc"`
+	ID      int                 `json:"id"`
+	Result  []FunctionCandidate `json:"result,omitempty"`
+	Error   *pluginError        `json:"error,omitempty"`
+}
+
+type pluginError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// pluginMethod is the only method of the plugin protocol.
+const pluginMethod = "detect"
+
+// PluginDetector returns a CandidateDetector backed by an external process,
+// so that detectors written in other languages, or kept out of the caller's
+// binary, join the pipeline. Each run starts the program at path with args
+// and talks JSON-RPC 2.0 over its standard input and output, one message per
+// line: every executable section of the file is sent as a "detect" request
+// whose params are a CodeView, and the plugin answers each with the list of
+// FunctionCandidate values it found in that section. Closing its standard
+// input tells the plugin to exit.
+//
+// Candidates outside the section they were returned for are dropped.
+// Candidates without a detection type get DetectionPlugin, and those
+// without a confidence get ConfidenceLow. ServePlugin implements the plugin
+// side of the protocol in Go.
+func PluginDetector(path string, args ...string) CandidateDetector {
+	return func(f *elf.File) ([]FunctionCandidate, error) {
+		arch, err := elfArch(f)
+		if err != nil {
+			return nil, err
+		}
+		var views []CodeView
+		for _, sec := range f.Sections {
+			if sec.Flags&elf.SHF_EXECINSTR == 0 || sec.Flags&elf.SHF_ALLOC == 0 || sec.Type != elf.SHT_PROGBITS {
+				continue
+			}
+			code, err := sec.Data()
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", sec.Name, err)
+			}
+			views = append(views, CodeView{Section: sec.Name, Address: sec.Addr, Arch: arch, Code: code})
+		}
+		if len(views) == 0 {
+			return nil, nil
+		}
+		return runPlugin(path, args, views)
+	}
+}
+
+func runPlugin(path string, args []string, views []CodeView) ([]FunctionCandidate, error) {
+	cmd := exec.Command(path, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+
+	candidates, err := exchangePlugin(stdin, stdout, views)
+	stdin.Close()
+	if waitErr := cmd.Wait(); err == nil && waitErr != nil {
+		err = waitErr
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("plugin %s: %w: %s", path, err, msg)
+		}
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	return candidates, nil
+}
+
+// exchangePlugin sends one request per view to the plugin and collects the
+// candidates of its responses.
+func exchangePlugin(w io.Writer, r io.Reader, views []CodeView) ([]FunctionCandidate, error) {
+	enc := json.NewEncoder(w)
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var candidates []FunctionCandidate
+	for i := range views {
+		view := &views[i]
+		if err := enc.Encode(pluginRequest{JSONRPC: "2.0", ID: i + 1, Method: pluginMethod, Params: view}); err != nil {
+			return nil, fmt.Errorf("send request: %w", err)
+		}
+		var resp pluginResponse
+		if err := dec.Decode(&resp); err != nil {
+			return nil, fmt.Errorf("read response: %w", err)
+		}
+		if resp.ID != i+1 {
+			return nil, fmt.Errorf("response id %d, want %d", resp.ID, i+1)
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf("%s: error %d: %s", view.Section, resp.Error.Code, resp.Error.Message)
+		}
+		end := view.Address + uint64(len(view.Code))
+		found := make([]FunctionCandidate, 0, len(resp.Result))
+		for _, c := range resp.Result {
+			if c.Address < view.Address || c.Address >= end {
+				continue
+			}
+			if c.DetectionType == "" {
+				c.DetectionType = DetectionPlugin
+			}
+			if c.Confidence == "" {
+				c.Confidence = ConfidenceLow
+			}
+			found = append(found, c)
+		}
+		candidates = mergeCandidates(candidates, found)
+	}
+	return candidates, nil
+}
+
+// ServePlugin runs the plugin side of the PluginDetector protocol on the
+// standard input and output of the process: detect is called for each code
+// view received and its candidates, or its error, are sent back. It returns
+// nil once the standard input is closed.
+func ServePlugin(detect func(CodeView) ([]FunctionCandidate, error)) error {
+	return servePlugin(os.Stdin, os.Stdout, detect)
+}
+
+func servePlugin(r io.Reader, w io.Writer, detect func(CodeView) ([]FunctionCandidate, error)) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	enc := json.NewEncoder(w)
+	for {
+		var req pluginRequest
+		if err := dec.Decode(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("read request: %w", err)
+		}
+		resp := pluginResponse{JSONRPC: "2.0", ID: req.ID}
+		if req.Method != pluginMethod || req.Params == nil {
+			// -32601 is the JSON-RPC code for an unknown method.
+			resp.Error = &pluginError{Code: -32601, Message: fmt.Sprintf("unknown method %q", req.Method)}
+		} else if candidates, err := detect(*req.Params); err != nil {
+			resp.Error = &pluginError{Code: 1, Message: err.Error()}
+		} else {
+			resp.Result = candidates
+		}
+		if err := enc.Encode(resp); err != nil {
+			return fmt.Errorf("send response: %w", err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+// pluginModeEnv makes the test binary act as a detector plugin, see
+// TestPluginHelperProcess.
+const pluginModeEnv = "RESURGO_TEST_PLUGIN"
+
+// TestPluginHelperProcess is not a test: it is run by TestPluginDetector as
+// the plugin process. It reports the first byte of every section, one
+// address past its end, and fails on demand.
+func TestPluginHelperProcess(t *testing.T) {
+	mode := os.Getenv(pluginModeEnv)
+	if mode == "" {
+		t.Skip("run as a plugin by TestPluginDetector")
+	}
+	err := resurgo.ServePlugin(func(v resurgo.CodeView) ([]resurgo.FunctionCandidate, error) {
+		if mode == "fail" {
+			return nil, errors.New("plugin failure")
+		}
+		return []resurgo.FunctionCandidate{
+			{Address: v.Address},
+			{Address: v.Address + uint64(len(v.Code))},
+		}, nil
+	})
+	if err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestPluginDetector(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	outPath := filepath.Join(t.TempDir(), "demo-app")
+	cmd := exec.Command("gcc", "-O0", "-o", outPath, "testdata/demo-app.c")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	want := make(map[uint64]string)
+	for _, sec := range f.Sections {
+		if sec.Flags&elf.SHF_EXECINSTR != 0 && sec.Flags&elf.SHF_ALLOC != 0 && sec.Type == elf.SHT_PROGBITS {
+			want[sec.Addr] = sec.Name
+		}
+	}
+
+	detector := resurgo.PluginDetector(os.Args[0], "-test.run=^TestPluginHelperProcess$")
+
+	t.Run("detect", func(t *testing.T) {
+		t.Setenv(pluginModeEnv, "detect")
+		candidates, err := detector(f)
+		if err != nil {
+			t.Fatalf("plugin detector: %v", err)
+		}
+		if len(candidates) != len(want) {
+			t.Errorf("got %d candidates, want %d: %+v", len(candidates), len(want), candidates)
+		}
+		for _, c := range candidates {
+			if _, ok := want[c.Address]; !ok {
+				t.Errorf("unexpected candidate at 0x%x", c.Address)
+			}
+			if c.DetectionType != resurgo.DetectionPlugin || c.Confidence != resurgo.ConfidenceLow {
+				t.Errorf("candidate 0x%x: got %s/%s, want %s/%s", c.Address, c.DetectionType, c.Confidence,
+					resurgo.DetectionPlugin, resurgo.ConfidenceLow)
+			}
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		t.Setenv(pluginModeEnv, "fail")
+		if _, err := detector(f); err == nil {
+			t.Error("expected the plugin error to be returned")
+		}
+	})
+}
@@ -0,0 +1,435 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/arch/ppc64/ppc64asm"
+)
+
+// ppc64InsnLen is the length of a Power ISA instruction. Prefixed (8-byte)
+// instructions do not occur in function entry sequences and are stepped over
+// one word at a time.
+const ppc64InsnLen = 4
+
+const (
+	// ppc64LRSaveOffset is the offset from the caller's stack pointer of the
+	// ELFv2 link register save doubleword.
+	ppc64LRSaveOffset = 16
+
+	// ppc64BranchAlways is the BO field bits of bc, bclr and bcctr that make
+	// the branch unconditional.
+	ppc64BranchAlways = 0x14
+
+	// ppc64SPRLR is the special purpose register number of the link
+	// register, as read by mflr (mfspr rN, 8).
+	ppc64SPRLR = ppc64asm.SpReg(8)
+
+	// ppc64TrapAlways is the TO field of tw and td that traps
+	// unconditionally.
+	ppc64TrapAlways = 31
+)
+
+// ppc64LocalEntryOffset returns the distance between the global and local
+// entry points of an ELFv2 function, from the three high bits of its
+// symbol's st_other: 0 and 1 mean a single entry point, 2 to 6 an offset of
+// 1<<n bytes.
+func ppc64LocalEntryOffset(other uint8) uint64 {
+	n := other >> 5 & 7
+	if n < 2 || n > 6 {
+		return 0
+	}
+	return 1 << n
+}
+
+// ppc64EntryPoints pairs the global and local entry points of the ELFv2
+// functions of a file that have both.
+type ppc64EntryPoints struct {
+	// global maps a local entry point to its global entry point.
+	global map[uint64]uint64
+	// local maps a global entry point to its local entry point.
+	local map[uint64]uint64
+}
+
+// ppc64LocalEntries returns the entry points of the function symbols of f
+// with two entry points.
+func ppc64LocalEntries(f *elf.File) (ppc64EntryPoints, error) {
+	entries := ppc64EntryPoints{global: make(map[uint64]uint64), local: make(map[uint64]uint64)}
+	for _, read := range []func() ([]elf.Symbol, error){f.Symbols, f.DynamicSymbols} {
+		syms, err := read()
+		if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+			return ppc64EntryPoints{}, fmt.Errorf("read symbols: %w", err)
+		}
+		for _, s := range syms {
+			if elf.ST_TYPE(s.Info) != elf.STT_FUNC || s.Value == 0 {
+				continue
+			}
+			if ofs := ppc64LocalEntryOffset(s.Other); ofs > 0 {
+				entries.global[s.Value+ofs] = s.Value
+				entries.local[s.Value] = s.Value + ofs
+			}
+		}
+	}
+	return entries, nil
+}
+
+// withEntryPoints returns o with the ppc64le entry points of its input set
+// to entries.
+func (o *options) withEntryPoints(entries ppc64EntryPoints) *options {
+	resolved := *o
+	resolved.entryPoints = entries
+	return &resolved
+}
+
+// decodePPC64 decodes the little-endian instruction word at code[offset].
+// Words the decoder does not know are returned with a zero Op.
+func decodePPC64(code []byte, offset int) ppc64asm.Inst {
+	inst, err := ppc64asm.Decode(code[offset:offset+ppc64InsnLen], binary.LittleEndian)
+	if err != nil {
+		return ppc64asm.Inst{}
+	}
+	return inst
+}
+
+// ppc64Imm returns the immediate argument arg.
+func ppc64Imm(arg ppc64asm.Arg) (int64, bool) {
+	imm, ok := arg.(ppc64asm.Imm)
+	return int64(imm), ok
+}
+
+// ppc64TOCSetup returns the ELFv2 global entry sequence at code[offset],
+// which derives the TOC pointer r2 from the function address in r12 before
+// falling through to the local entry point:
+//
+//	addis r2, r12, .TOC.-func@ha   (or lis r2, .TOC.@ha)
+//	addi  r2, r2, .TOC.-func@l
+func ppc64TOCSetup(code []byte, offset int) ([]string, bool) {
+	if offset < 0 || offset+2*ppc64InsnLen > len(code) {
+		return nil, false
+	}
+	hi, lo := decodePPC64(code, offset), decodePPC64(code, offset+ppc64InsnLen)
+	var first string
+	switch {
+	case hi.Op == ppc64asm.ADDIS && hi.Args[0] == ppc64asm.R2 && hi.Args[1] == ppc64asm.R12:
+		imm, _ := ppc64Imm(hi.Args[2])
+		first = fmt.Sprintf("addis r2, r12, %d", imm)
+	case hi.Op == ppc64asm.LIS && hi.Args[0] == ppc64asm.R2:
+		imm, _ := ppc64Imm(hi.Args[1])
+		first = fmt.Sprintf("lis r2, %d", imm)
+	default:
+		return nil, false
+	}
+	if lo.Op != ppc64asm.ADDI || lo.Args[0] != ppc64asm.R2 || lo.Args[1] != ppc64asm.R2 {
+		return nil, false
+	}
+	imm, _ := ppc64Imm(lo.Args[2])
+	return []string{first, fmt.Sprintf("addi r2, r2, %d", imm)}, true
+}
+
+// globalEntryPPC64 returns the global entry point of the function whose
+// local entry point is addr, in code mapped at baseAddr, with a summary of
+// the instructions between the two. Local entry points are read from the
+// symbol table when available and recognized by the TOC pointer setup that
+// precedes them otherwise.
+func (o *options) globalEntryPPC64(code []byte, baseAddr, addr uint64) (uint64, []string, bool) {
+	offset := int(addr - baseAddr)
+	if global, ok := o.entryPoints.global[addr]; ok && global >= baseAddr && global < addr {
+		var insns []string
+		for off := int(global - baseAddr); off < offset; off += ppc64InsnLen {
+			insns = append(insns, ppc64asm.GNUSyntax(decodePPC64(code, off), baseAddr+uint64(off)))
+		}
+		return global, insns, true
+	}
+	global := addr - 2*ppc64InsnLen
+	if local, ok := o.entryPoints.local[global]; ok && local != addr {
+		return 0, nil, false
+	}
+	insns, ok := ppc64TOCSetup(code, offset-2*ppc64InsnLen)
+	return global, insns, ok
+}
+
+// ppc64RegName returns the GNU name of a general purpose register.
+func ppc64RegName(reg ppc64asm.Reg) string {
+	return fmt.Sprintf("r%d", reg-ppc64asm.R0)
+}
+
+// isCalleeSavedPPC64 reports whether reg is a non-volatile general purpose
+// register under the ELFv2 ABI: r14-r31.
+func isCalleeSavedPPC64(reg ppc64asm.Reg) bool {
+	return reg >= ppc64asm.R14 && reg <= ppc64asm.R31
+}
+
+// ppc64MFLR returns the destination register of mflr rN.
+func ppc64MFLR(inst ppc64asm.Inst) (ppc64asm.Reg, bool) {
+	if inst.Op != ppc64asm.MFSPR || inst.Args[1] != ppc64SPRLR {
+		return 0, false
+	}
+	reg, ok := inst.Args[0].(ppc64asm.Reg)
+	return reg, ok
+}
+
+// ppc64SPStore returns the register and offset stored by std reg, off(r1).
+func ppc64SPStore(inst ppc64asm.Inst) (ppc64asm.Reg, int64, bool) {
+	if inst.Op != ppc64asm.STD || inst.Args[2] != ppc64asm.R1 {
+		return 0, 0, false
+	}
+	reg, ok := inst.Args[0].(ppc64asm.Reg)
+	ofs, isOfs := inst.Args[1].(ppc64asm.Offset)
+	return reg, int64(ofs), ok && isOfs
+}
+
+// ppc64SPUpdate returns N for stdu r1, -N(r1), which allocates the frame and
+// stores the back chain at once.
+func ppc64SPUpdate(inst ppc64asm.Inst) (uint64, bool) {
+	if inst.Op != ppc64asm.STDU || inst.Args[0] != ppc64asm.R1 || inst.Args[2] != ppc64asm.R1 {
+		return 0, false
+	}
+	ofs, ok := inst.Args[1].(ppc64asm.Offset)
+	return uint64(-ofs), ok && ofs < 0
+}
+
+// isFramePointerSetupPPC64 reports whether inst is mr r31, r1 (or r31, r1,
+// r1), which sets the frame pointer up.
+func isFramePointerSetupPPC64(inst ppc64asm.Inst) bool {
+	return inst.Op == ppc64asm.OR && inst.Args[0] == ppc64asm.R31 &&
+		inst.Args[1] == ppc64asm.R1 && inst.Args[2] == ppc64asm.R1
+}
+
+// isBranchPPC64 reports whether inst transfers control.
+func isBranchPPC64(inst ppc64asm.Inst) bool {
+	switch inst.Op {
+	case ppc64asm.B, ppc64asm.BA, ppc64asm.BL, ppc64asm.BLA,
+		ppc64asm.BC, ppc64asm.BCL, ppc64asm.BCLR, ppc64asm.BCCTR:
+		return true
+	}
+	return false
+}
+
+// entrySeqPPC64 describes the ELFv2 function entry sequence that starts
+// with mflr.
+type entrySeqPPC64 struct {
+	insns []string
+	// savedRegs lists the registers stored to the stack in store order; the
+	// link register is saved through the mflr destination.
+	savedRegs []string
+	savesLR   bool
+	frame     uint64
+	// end is the offset just past the last instruction of the sequence.
+	end int
+}
+
+// scanEntryPPC64 collects the entry sequence starting with the mflr of reg
+// at code[offset]: the link register save std reg, 16(r1), the callee-saved
+// register saves, the stdu r1, -N(r1) allocating the frame and the mr r31,
+// r1 setting the frame pointer up. Compilers schedule other instructions
+// among them, so unrelated instructions are skipped; a branch or a second
+// stack pointer update ends the sequence.
+func scanEntryPPC64(code []byte, offset int, reg ppc64asm.Reg) entrySeqPPC64 {
+	seq := entrySeqPPC64{insns: []string{"mflr " + ppc64RegName(reg)}, end: offset + ppc64InsnLen}
+	allocated := false
+	for n, off := 0, seq.end; n < maxSavedRegScan && off+ppc64InsnLen <= len(code); n, off = n+1, off+ppc64InsnLen {
+		inst := decodePPC64(code, off)
+		if src, ofs, ok := ppc64SPStore(inst); ok && (src == reg || isCalleeSavedPPC64(src)) {
+			if src == reg {
+				// Before the frame is allocated the LR save slot is in the
+				// caller's frame; after it, N bytes further up.
+				if ofs != ppc64LRSaveOffset+int64(seq.frame) || seq.savesLR {
+					break
+				}
+				seq.savesLR = true
+				seq.savedRegs = append(seq.savedRegs, "lr")
+			} else {
+				seq.savedRegs = append(seq.savedRegs, ppc64RegName(src))
+			}
+			seq.insns = append(seq.insns, fmt.Sprintf("std %s, %d(r1)", ppc64RegName(src), ofs))
+			seq.end = off + ppc64InsnLen
+			continue
+		}
+		if size, ok := ppc64SPUpdate(inst); ok {
+			if allocated {
+				break
+			}
+			allocated = true
+			seq.insns = append(seq.insns, fmt.Sprintf("stdu r1, -%d(r1)", size))
+			seq.frame = size
+			seq.end = off + ppc64InsnLen
+			continue
+		}
+		if isFramePointerSetupPPC64(inst) {
+			seq.insns = append(seq.insns, "mr r31, r1")
+			seq.end = off + ppc64InsnLen
+			break
+		}
+		if isBranchPPC64(inst) || inst.Op == ppc64asm.STDUX {
+			break
+		}
+	}
+	return seq
+}
+
+// detectProloguesPPC64 matches ELFv2 (ppc64le) function prologues:
+//
+//	mflr r0; std r0, 16(r1)   mflr-std-lr, with the callee-saved register
+//	                          saves, stdu r1, -N(r1) and mr r31, r1 folded in
+//	stdu r1, -N(r1)           stdu-sp, at a function boundary
+//	addis r2, r12, N          global-entry, when no frame setup follows it
+//	addi  r2, r2, N
+//
+// Functions that use the TOC have a global entry point, which sets the TOC
+// pointer up, followed by a local entry point, which callers sharing the
+// TOC branch to. A prologue at a local entry point is reported at the global
+// entry point, with the TOC pointer setup folded in.
+func (o *options) detectProloguesPPC64(code []byte, baseAddr uint64) ([]Prologue, error) {
+	var result []Prologue
+
+	// consumedUntil is the end offset of the last collapsed entry sequence.
+	// Patterns matching inside it are fragments of that sequence.
+	consumedUntil := 0
+	hist := newInsnHistory(o.lookbehind())
+
+	for offset := 0; offset+ppc64InsnLen <= len(code); offset += ppc64InsnLen {
+		if err := o.budget.step(offset); err != nil {
+			return nil, err
+		}
+		addr := baseAddr + uint64(offset)
+		inst := decodePPC64(code, offset)
+		global, setup, isLocal := o.globalEntryPPC64(code, baseAddr, addr)
+		matched := len(result)
+
+		if offset >= consumedUntil {
+			// Pattern 1: mflr r0; std r0, 16(r1), saving the link register
+			// of a non-leaf function.
+			if reg, ok := ppc64MFLR(inst); ok {
+				if seq := scanEntryPPC64(code, offset, reg); seq.savesLR {
+					result = append(result, Prologue{
+						Address:      addr,
+						Type:         PrologueMFLRStdLR,
+						Instructions: strings.Join(seq.insns, "; "),
+						FrameSize:    seq.frame,
+						Size:         uint64(seq.end - offset),
+						SavedRegs:    seq.savedRegs,
+					})
+					consumedUntil = seq.end
+				}
+			}
+
+			// Pattern 2: stdu r1, -N(r1) (leaf or frame-only function).
+			if size, ok := ppc64SPUpdate(inst); ok && (isLocal || o.atBoundary(hist, addr, PrologueStduSP)) {
+				result = append(result, Prologue{
+					Address:      addr,
+					Type:         PrologueStduSP,
+					Instructions: fmt.Sprintf("stdu r1, -%d(r1)", size),
+					FrameSize:    size,
+					Size:         ppc64InsnLen,
+				})
+			}
+		}
+
+		if isLocal {
+			for i := matched; i < len(result); i++ {
+				p := &result[i]
+				p.Instructions = strings.Join(append(setup, p.Instructions), "; ")
+				p.Size += addr - global
+				p.Address = global
+			}
+			// Pattern 3: the TOC pointer setup alone.
+			if matched == len(result) && addr-global == 2*ppc64InsnLen {
+				if _, ok := ppc64TOCSetup(code, offset-2*ppc64InsnLen); ok {
+					result = append(result, Prologue{
+						Address:      global,
+						Type:         PrologueGlobalEntry,
+						Instructions: strings.Join(setup, "; "),
+						Size:         addr - global,
+					})
+				}
+			}
+		}
+
+		hist.push(addr, classifyPPC64(inst))
+	}
+
+	return result, nil
+}
+
+// classifyPPC64 returns the boundary-context class of a Power instruction:
+// blr is a return, b and bctr are jumps, and trap (tw 31, r0, r0) and its
+// doubleword and immediate forms are traps. Words that do not decode, such
+// as the zero word opening a traceback table, count as undecodable.
+func classifyPPC64(inst ppc64asm.Inst) InsnClass {
+	switch inst.Op {
+	case 0:
+		return InsnClassNone
+	case ppc64asm.BCLR, ppc64asm.BCCTR:
+		if bo, ok := ppc64Imm(inst.Args[0]); ok && bo&ppc64BranchAlways == ppc64BranchAlways {
+			if inst.Op == ppc64asm.BCLR {
+				return InsnClassReturn
+			}
+			return InsnClassJump
+		}
+	case ppc64asm.B, ppc64asm.BA:
+		return InsnClassJump
+	case ppc64asm.TW, ppc64asm.TD, ppc64asm.TWI, ppc64asm.TDI:
+		if to, ok := ppc64Imm(inst.Args[0]); ok && to == ppc64TrapAlways {
+			return InsnClassTrap
+		}
+	case ppc64asm.NOP:
+		return InsnClassPadding
+	}
+	return InsnClassOther
+}
+
+// detectCallSitesPPC64 scans ppc64le code for bl, b and bc. Branch targets
+// are relative to the address of the branch itself. bc is a jump with low
+// confidence unless its BO field makes it unconditional. Calls between
+// functions sharing a TOC target the local entry point of the callee.
+func detectCallSitesPPC64(code []byte, baseAddr uint64, wrap bool, b *budget) ([]CallSiteEdge, error) {
+	var result []CallSiteEdge
+
+	for offset := 0; offset+ppc64InsnLen <= len(code); offset += ppc64InsnLen {
+		if err := b.step(offset); err != nil {
+			return nil, err
+		}
+		inst := decodePPC64(code, offset)
+		addr := baseAddr + uint64(offset)
+
+		edge := CallSiteEdge{
+			SourceAddr:  addr,
+			Type:        CallSiteCall,
+			AddressMode: AddressingModePCRelative,
+			Confidence:  ConfidenceHigh,
+		}
+		var rel ppc64asm.Arg
+		switch inst.Op {
+		case ppc64asm.BL:
+			rel = inst.Args[0]
+		case ppc64asm.B:
+			rel = inst.Args[0]
+			edge.Type = CallSiteJump
+			edge.Confidence = ConfidenceMedium
+		case ppc64asm.BC:
+			rel = inst.Args[2]
+			edge.Type = CallSiteJump
+			edge.Confidence = ConfidenceLow
+			if bo, ok := ppc64Imm(inst.Args[0]); ok && bo&ppc64BranchAlways == ppc64BranchAlways {
+				edge.Confidence = ConfidenceMedium
+			}
+		default:
+			continue
+		}
+		disp, ok := rel.(ppc64asm.PCRel)
+		if !ok {
+			continue
+		}
+		target, ok := relTarget(addr, int64(disp), wrap)
+		if !ok {
+			continue
+		}
+		edge.TargetAddr = target
+		result = append(result, edge)
+	}
+
+	return result, nil
+}
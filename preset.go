@@ -0,0 +1,56 @@
+package resurgo
+
+const (
+	// Curated option presets.
+	PresetStrict     Preset = "strict"
+	PresetPermissive Preset = "permissive"
+)
+
+// Preset is a curated combination of options trading recall for precision.
+type Preset string
+
+// WithPreset applies the options of preset p:
+//
+//   - PresetStrict favours precision. The toolchain profile is detected
+//     from the file, data regions in .text are excluded, x86 prologues must
+//     sit on the instruction stream superset disassembly converges on,
+//     FDE-confirmed candidates must agree with the CFA rules of their FDE,
+//     a prologue that sits at an alignment boundary counts as a second
+//     signal, and only ConfidenceHigh candidates, i.e. those backed by at
+//     least two independent signals, are returned.
+//   - PresetPermissive favours recall. Every prologue pattern, including
+//     the toolchain-specific ones, is enabled, patterns tolerate up to two
+//     benign instructions between their elements, boundary-gated patterns
+//     accept any control-flow break or padding within two instructions, the
+//     frame size bound is disabled, the targets of C++ vtables, of the
+//     constructor and destructor tables and of IFUNC resolvers are added to
+//     the candidates and only PLTFilter is applied.
+//
+// Options following WithPreset override individual settings of the preset.
+// Unknown presets leave the options unchanged.
+func WithPreset(p Preset) Option {
+	return func(o *options) {
+		switch p {
+		case PresetStrict:
+			o.toolchain = ToolchainAuto
+			o.alignmentSignal = true
+			o.supersetVoting = true
+			o.filters = []CandidateFilter{CETFilter, DataRegionFilter, EhFrameFilter, CFIConsistencyFilter, PLTFilter}
+			o.minConfidence = ConfidenceHigh
+		case PresetPermissive:
+			o.allPrologues = true
+			o.patternTolerance = 2
+			WithContextWindow(ContextWindow{
+				Instructions: 2,
+				Allowed: []InsnClass{
+					InsnClassNone, InsnClassReturn, InsnClassPush,
+					InsnClassJump, InsnClassPadding, InsnClassTrap,
+				},
+			})(o)
+			o.maxFrameSize = 0
+			o.detectors = []CandidateDetector{o.disasmDetector, EhFrameDetector, VtableDetector, InitFiniDetector, IfuncDetector}
+			o.filters = []CandidateFilter{PLTFilter}
+			o.minConfidence = ConfidenceNone
+		}
+	}
+}
@@ -1,25 +1,71 @@
 package resurgo
 
+import "cmp"
+
 const (
 	// Supported architectures.
-	ArchAMD64 Arch = "amd64"
-	ArchARM64 Arch = "arm64"
+	ArchAMD64   Arch = "amd64"
+	ArchARM64   Arch = "arm64"
+	ArchX86     Arch = "386"
+	ArchRISCV64 Arch = "riscv64"
+	ArchARM     Arch = "arm"
+	// ArchThumb is T32 (Thumb-2) code. ELF files of ArchARM are split into
+	// A32 and T32 regions along their mapping symbols.
+	ArchThumb   Arch = "thumb"
+	ArchPPC64LE Arch = "ppc64le"
+	// ArchPPC64 is big-endian 64-bit PowerPC code, matched with the ppc64le
+	// patterns.
+	ArchPPC64 Arch = "ppc64"
+	// ArchXtensa is little-endian Xtensa code, e.g. ESP32 firmware, under
+	// the windowed or the call0 ABI.
+	ArchXtensa Arch = "xtensa"
 
 	// DetectionPrologueOnly indicates the candidate was found by prologue
 	// pattern matching only.
 	DetectionPrologueOnly DetectionType = "prologue-only"
 
-	// Recognized x86_64 function prologue patterns.
+	// Recognized x86_64 function prologue patterns. The classic,
+	// no-frame-pointer and push-only patterns are also recognized on i386.
 	PrologueClassic        PrologueType = "classic"
 	PrologueNoFramePointer PrologueType = "no-frame-pointer"
 	ProloguePushOnly       PrologueType = "push-only"
 	PrologueLEABased       PrologueType = "lea-based"
+	PrologueStackRealign   PrologueType = "stack-realign"
+	PrologueEnter          PrologueType = "enter"
+
+	// Recognized ARM64 function prologue patterns. The str-lr-preindex and
+	// sub-sp patterns are also recognized on ARM32.
+	PrologueSTPFramePair   PrologueType = "stp-frame-pair"
+	PrologueSTRLRPreIndex  PrologueType = "str-lr-preindex"
+	PrologueSubSP          PrologueType = "sub-sp"
+	PrologueSTPOnly        PrologueType = "stp-only"
+	PrologueSTPCalleeSaved PrologueType = "stp-callee-saved"
+
+	// Recognized ARM32 function prologue patterns, in A32 and T32 code.
+	ProloguePushFPLR PrologueType = "push-fp-lr"
+	ProloguePushLR   PrologueType = "push-lr"
+
+	// Recognized RISC-V function prologue patterns, also recognized in
+	// Xtensa call0 code.
+	PrologueAddiSPFrame  PrologueType = "addi-sp-frame"
+	PrologueAddiSPSaveRA PrologueType = "addi-sp-save-ra"
+	PrologueAddiSP       PrologueType = "addi-sp"
 
-	// Recognized ARM64 function prologue patterns.
-	PrologueSTPFramePair  PrologueType = "stp-frame-pair"
-	PrologueSTRLRPreIndex PrologueType = "str-lr-preindex"
-	PrologueSubSP         PrologueType = "sub-sp"
-	PrologueSTPOnly       PrologueType = "stp-only"
+	// Recognized ppc64le (ELFv2) function prologue patterns, also recognized
+	// on big-endian ppc64.
+	PrologueMFLRStdLR   PrologueType = "mflr-std-lr"
+	PrologueStduSP      PrologueType = "stdu-sp"
+	PrologueGlobalEntry PrologueType = "global-entry"
+
+	// Recognized Xtensa windowed ABI function prologue pattern.
+	PrologueEntry PrologueType = "entry"
+
+	// Recognized toolchain-specific prologue patterns, reported only when
+	// the selected toolchain profile enables them.
+	PrologueGoStackCheck   PrologueType = "go-stack-check"
+	PrologueHomeSpill      PrologueType = "home-spill"
+	PrologueRustProbestack PrologueType = "rust-probestack"
+	PrologueFmtThunk       PrologueType = "fmt-thunk"
 )
 
 // Arch represents a CPU architecture.
@@ -37,4 +83,47 @@ type Prologue struct {
 	// Instructions is a human-readable representation of the matched
 	// prologue instructions.
 	Instructions string `json:"instructions"`
+	// FrameSize is the number of stack bytes the matched instructions
+	// allocate, including register saves. Zero when it cannot be determined.
+	FrameSize uint64 `json:"frame_size,omitempty"`
+	// Size is the length in bytes of the matched instruction sequence,
+	// starting at Address.
+	Size uint64 `json:"size,omitempty"`
+	// SavedRegs lists the callee-saved registers stored by the function
+	// entry sequence, in the order they are saved.
+	SavedRegs []string `json:"saved_regs,omitempty"`
+	// Section is the name of the section holding the prologue. It is only
+	// set by DetectProloguesFromELF, where Address is an offset into the
+	// section for relocatable objects.
+	Section string `json:"section,omitempty"`
+	// Kind classifies the code holding the prologue when it is not
+	// ordinary compiled code, e.g. KindPLTStub. It is only set by
+	// DetectProloguesFromELF.
+	Kind FunctionKind `json:"kind,omitempty"`
+	// Permissions are the access permissions of the PT_LOAD segment
+	// holding the prologue, e.g. "r-x", or "rwx" for code mapped writable.
+	// They are only set by DetectProloguesFromELF, for linked files.
+	Permissions string `json:"permissions,omitempty"`
+}
+
+// comparePrologues returns a comparison function ordering prologues by
+// address, then by their rank in the prologue registry. Unknown types sort
+// after known ones, by name.
+func comparePrologues() func(a, b Prologue) int {
+	ranks := prologueRanks()
+	rank := func(typ PrologueType) int {
+		if r, ok := ranks[typ]; ok {
+			return r
+		}
+		return len(ranks)
+	}
+	return func(a, b Prologue) int {
+		if c := cmp.Compare(a.Address, b.Address); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(rank(a.Type), rank(b.Type)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Type, b.Type)
+	}
 }
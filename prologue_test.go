@@ -1,11 +1,14 @@
 package resurgo_test
 
 import (
+	"bytes"
 	"debug/elf"
 	"encoding/binary"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -62,6 +65,36 @@ func TestDetectProloguesAMD64(t *testing.T) {
 		wantCount: 1,
 		wantType:  resurgo.ProloguePushOnly,
 		wantAddr:  0,
+	}, {
+		// nop; push rbp; mov rbp, rsp; and rsp, -0x20
+		name:      string(resurgo.PrologueStackRealign),
+		code:      []byte{0x90, 0x55, 0x48, 0x89, 0xe5, 0x48, 0x83, 0xe4, 0xe0},
+		baseAddr:  0,
+		wantCount: 1,
+		wantType:  resurgo.PrologueStackRealign,
+		wantAddr:  1,
+	}, {
+		// nop; push rbp; mov rbp, rsp; and rsp, -0x18 - not a power of two,
+		// so only the classic pattern fires.
+		name:      "stack-realign-invalid-mask",
+		code:      []byte{0x90, 0x55, 0x48, 0x89, 0xe5, 0x48, 0x83, 0xe4, 0xe8},
+		baseAddr:  0,
+		wantCount: 1,
+		wantType:  resurgo.PrologueClassic,
+		wantAddr:  1,
+	}, {
+		// enter 0x20, 0 at start of code
+		name:      string(resurgo.PrologueEnter),
+		code:      []byte{0xc8, 0x20, 0x00, 0x00},
+		baseAddr:  0,
+		wantCount: 1,
+		wantType:  resurgo.PrologueEnter,
+		wantAddr:  0,
+	}, {
+		// enter 0x20, 1 - nested frames are not a compiler prologue
+		name:      "enter-nested",
+		code:      []byte{0xc8, 0x20, 0x00, 0x01},
+		wantCount: 0,
 	}, {
 		name:      "EmptyNil",
 		code:      nil,
@@ -108,11 +141,18 @@ func TestDetectProloguesARM64(t *testing.T) {
 	// nop                       = 0xd503201f
 	// ret                       = 0xd65f03c0
 
-	stpX29X30 := uint32(0xa9bf7bfd) // stp x29, x30, [sp, #-16]!
-	movX29SP := uint32(0x910003fd)  // mov x29, sp
-	subSP := uint32(0xd10083ff)     // sub sp, sp, #0x20
-	strX30 := uint32(0xf81e0ffe)    // str x30, [sp, #-32]!
-	nop := uint32(0xd503201f)       // nop
+	stpX29X30 := uint32(0xa9bf7bfd)  // stp x29, x30, [sp, #-16]!
+	movX29SP := uint32(0x910003fd)   // mov x29, sp
+	subSP := uint32(0xd10083ff)      // sub sp, sp, #0x20
+	strX30 := uint32(0xf81e0ffe)     // str x30, [sp, #-32]!
+	nop := uint32(0xd503201f)        // nop
+	subSPLsl12 := uint32(0xd14007ff) // sub sp, sp, #0x1, lsl #12
+	movX16 := uint32(0xd2846810)     // mov x16, #0x2340
+	movkX16 := uint32(0xf2a00030)    // movk x16, #0x1, lsl #16
+	subSPX16 := uint32(0xcb3063ff)   // sub sp, sp, x16
+	paciasp := uint32(0xd503233f)    // paciasp
+	pacibsp := uint32(0xd503237f)    // pacibsp
+	ret := uint32(0xd65f03c0)        // ret
 
 	tests := []struct {
 		name      string
@@ -121,6 +161,7 @@ func TestDetectProloguesARM64(t *testing.T) {
 		wantCount int
 		wantType  resurgo.PrologueType
 		wantAddr  uint64
+		wantFrame uint64
 	}{{
 		name:      string(resurgo.PrologueSTPFramePair),
 		code:      arm64Insn(stpX29X30, movX29SP),
@@ -142,6 +183,73 @@ func TestDetectProloguesARM64(t *testing.T) {
 		wantCount: 1,
 		wantType:  resurgo.PrologueSubSP,
 		wantAddr:  0,
+		wantFrame: 0x20,
+	}, {
+		// sub sp, sp, #0x1, lsl #12; sub sp, sp, #0x20 - large frame split
+		// across a shifted and an unshifted immediate.
+		name:      "sub-sp-shifted",
+		code:      arm64Insn(subSPLsl12, subSP),
+		baseAddr:  0,
+		wantCount: 1,
+		wantType:  resurgo.PrologueSubSP,
+		wantAddr:  0,
+		wantFrame: 0x1020,
+	}, {
+		// nop; ret; mov x16, #0x2340; movk x16, #0x1, lsl #16; sub sp, sp, x16
+		name:      "sub-sp-movk",
+		code:      arm64Insn(nop, 0xd65f03c0, movX16, movkX16, subSPX16),
+		baseAddr:  0x1000,
+		wantCount: 1,
+		wantType:  resurgo.PrologueSubSP,
+		wantAddr:  0x1008,
+		wantFrame: 0x12340,
+	}, {
+		// Same sequence not at a function boundary: no prologue.
+		name:      "sub-sp-movk-mid-function",
+		code:      arm64Insn(nop, movX16, movkX16, subSPX16),
+		wantCount: 0,
+	}, {
+		// stp x20, x19, [sp, #-32]! at start of code
+		name:      string(resurgo.PrologueSTPCalleeSaved),
+		code:      arm64Insn(0xa9be4ff4, stpX29X30, movX29SP),
+		baseAddr:  0,
+		wantCount: 2,
+		wantType:  resurgo.PrologueSTPCalleeSaved,
+		wantAddr:  0,
+		wantFrame: 32,
+	}, {
+		// stp d8, d9, [sp, #-32]! at start of code
+		name:      "stp-callee-saved-simd",
+		code:      arm64Insn(0x6dbe27e8),
+		baseAddr:  0,
+		wantCount: 1,
+		wantType:  resurgo.PrologueSTPCalleeSaved,
+		wantAddr:  0,
+		wantFrame: 32,
+	}, {
+		// nop; stp x19, x20, [sp, #-32]! - not at a function boundary
+		name:      "stp-callee-saved-mid-function",
+		code:      arm64Insn(nop, 0xa9be53f3),
+		wantCount: 0,
+	}, {
+		// ret; paciasp; stp x29, x30, [sp, #-16]!; mov x29, sp - pac-ret
+		// function: the prologue starts at the PAC instruction.
+		name:      "stp-frame-pair-paciasp",
+		code:      arm64Insn(ret, paciasp, stpX29X30, movX29SP),
+		baseAddr:  0x1000,
+		wantCount: 1,
+		wantType:  resurgo.PrologueSTPFramePair,
+		wantAddr:  0x1004,
+	}, {
+		// ret; pacibsp; stp x20, x19, [sp, #-32]! - the boundary precedes
+		// the PAC instruction.
+		name:      "stp-callee-saved-pacibsp",
+		code:      arm64Insn(ret, pacibsp, 0xa9be4ff4),
+		baseAddr:  0,
+		wantCount: 1,
+		wantType:  resurgo.PrologueSTPCalleeSaved,
+		wantAddr:  4,
+		wantFrame: 32,
 	}, {
 		// stp x29, x30, [sp, #-16]! followed by nop (not mov x29, sp)
 		name:      string(resurgo.PrologueSTPOnly),
@@ -183,14 +291,1596 @@ func TestDetectProloguesARM64(t *testing.T) {
 			if prologues[0].Address != tt.wantAddr {
 				t.Errorf("expected address 0x%x, got 0x%x", tt.wantAddr, prologues[0].Address)
 			}
+			if tt.wantFrame != 0 && prologues[0].FrameSize != tt.wantFrame {
+				t.Errorf("expected frame size 0x%x, got 0x%x", tt.wantFrame, prologues[0].FrameSize)
+			}
 		})
 	}
 }
 
-func TestDetectPrologues_UnsupportedArch(t *testing.T) {
-	_, err := resurgo.DetectPrologues([]byte{0x00}, 0, resurgo.Arch("mips"))
-	if err == nil {
-		t.Fatal("expected error for unsupported architecture, got nil")
+func TestDetectProloguesX86(t *testing.T) {
+	// i386 instruction encodings:
+	// nop                       = 0x90
+	// ret                       = 0xc3
+	// push ebp                  = 0x55
+	// push ebx / esi            = 0x53 / 0x56
+	// mov ebp, esp              = 0x89 0xe5
+	// sub esp, 0x14             = 0x83 0xec 0x14
+
+	tests := []struct {
+		name      string
+		code      []byte
+		baseAddr  uint64
+		wantCount int
+		wantType  resurgo.PrologueType
+		wantAddr  uint64
+		wantFrame uint64
+		wantRegs  []string
+	}{{
+		// nop; push ebp; mov ebp, esp
+		name:      string(resurgo.PrologueClassic),
+		code:      []byte{0x90, 0x55, 0x89, 0xe5},
+		baseAddr:  0x8049000,
+		wantCount: 1,
+		wantType:  resurgo.PrologueClassic,
+		wantAddr:  0x8049001,
+		wantFrame: 4,
+		wantRegs:  []string{"ebp"},
+	}, {
+		// push ebp; mov ebp, esp; push ebx; sub esp, 0x14 - the push ebp at
+		// start of code is not reported again as a push-only match.
+		name:      "classic-with-saves",
+		code:      []byte{0x55, 0x89, 0xe5, 0x53, 0x83, 0xec, 0x14},
+		wantCount: 1,
+		wantType:  resurgo.PrologueClassic,
+		wantAddr:  0,
+		wantFrame: 0x1c,
+		wantRegs:  []string{"ebp", "ebx"},
+	}, {
+		// sub esp, 0x14 at start of code
+		name:      string(resurgo.PrologueNoFramePointer),
+		code:      []byte{0x83, 0xec, 0x14},
+		wantCount: 1,
+		wantType:  resurgo.PrologueNoFramePointer,
+		wantAddr:  0,
+		wantFrame: 0x14,
+	}, {
+		// ret; push esi; push ebx; nop
+		name:      string(resurgo.ProloguePushOnly),
+		code:      []byte{0xc3, 0x56, 0x53, 0x90},
+		wantCount: 1,
+		wantType:  resurgo.ProloguePushOnly,
+		wantAddr:  1,
+		wantFrame: 8,
+		wantRegs:  []string{"esi", "ebx"},
+	}, {
+		// nop; push ebx - not at a function boundary
+		name:      "push-mid-function",
+		code:      []byte{0x90, 0x53},
+		wantCount: 0,
+	}, {
+		name:      "X86_InvalidBytes",
+		code:      []byte{0xde, 0xad, 0xbe, 0xef},
+		wantCount: 0,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, tt.baseAddr, resurgo.ArchX86)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(prologues) != tt.wantCount {
+				t.Fatalf("expected %d prologue(s), got %d: %+v", tt.wantCount, len(prologues), prologues)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+			p := prologues[0]
+			if p.Type != tt.wantType {
+				t.Errorf("expected type %s, got %s", tt.wantType, p.Type)
+			}
+			if p.Address != tt.wantAddr {
+				t.Errorf("expected address 0x%x, got 0x%x", tt.wantAddr, p.Address)
+			}
+			if p.FrameSize != tt.wantFrame {
+				t.Errorf("expected frame size 0x%x, got 0x%x", tt.wantFrame, p.FrameSize)
+			}
+			if !slices.Equal(p.SavedRegs, tt.wantRegs) {
+				t.Errorf("expected saved registers %v, got %v", tt.wantRegs, p.SavedRegs)
+			}
+		})
+	}
+}
+
+func TestDetectProloguesARM(t *testing.T) {
+	// A32 instructions are little-endian 32-bit words, as on ARM64.
+	pushFPLR := uint32(0xe92d4800)   // push {fp, lr}
+	pushR4FPLR := uint32(0xe92d4810) // push {r4, fp, lr}
+	addFP := uint32(0xe28db008)      // add fp, sp, #8
+	subSP := uint32(0xe24dd010)      // sub sp, sp, #16
+	pushR4LR := uint32(0xe92d4010)   // push {r4, lr}
+	strLR := uint32(0xe52de014)      // str lr, [sp, #-20]!
+	pushLR := uint32(0xe52de004)     // push {lr} (str lr, [sp, #-4]!)
+	pushNELR := uint32(0x192d4010)   // pushne {r4, lr}
+	bxLR := uint32(0xe12fff1e)       // bx lr
+	popPC := uint32(0xe8bd8800)      // pop {fp, pc}
+	nop := uint32(0xe320f000)        // nop
+	movR0 := uint32(0xe3a00000)      // mov r0, #0
+
+	tests := []struct {
+		name      string
+		code      []byte
+		wantCount int
+		wantType  resurgo.PrologueType
+		wantAddr  uint64
+		wantFrame uint64
+		wantRegs  []string
+	}{{
+		name:      string(resurgo.ProloguePushFPLR),
+		code:      arm64Insn(pushFPLR),
+		wantCount: 1,
+		wantType:  resurgo.ProloguePushFPLR,
+		wantFrame: 8,
+		wantRegs:  []string{"fp", "lr"},
+	}, {
+		// The frame pointer setup and the stack allocation that follow the
+		// push belong to the same entry sequence.
+		name:      "push-fp-lr-with-frame",
+		code:      arm64Insn(movR0, pushR4FPLR, nop, addFP, subSP),
+		wantCount: 1,
+		wantType:  resurgo.ProloguePushFPLR,
+		wantAddr:  4,
+		wantFrame: 28,
+		wantRegs:  []string{"r4", "fp", "lr"},
+	}, {
+		name:      string(resurgo.ProloguePushLR),
+		code:      arm64Insn(movR0, pushR4LR),
+		wantCount: 1,
+		wantType:  resurgo.ProloguePushLR,
+		wantAddr:  4,
+		wantFrame: 8,
+		wantRegs:  []string{"r4", "lr"},
+	}, {
+		name:      "push-lr-conditional",
+		code:      arm64Insn(pushNELR),
+		wantCount: 0,
+	}, {
+		name:      "str-lr-preindex",
+		code:      arm64Insn(bxLR, strLR),
+		wantCount: 1,
+		wantType:  resurgo.PrologueSTRLRPreIndex,
+		wantAddr:  4,
+		wantFrame: 20,
+		wantRegs:  []string{"lr"},
+	}, {
+		// str lr, [sp, #-4]! is decoded as push {lr}.
+		name:      "str-lr-preindex-push",
+		code:      arm64Insn(popPC, pushLR),
+		wantCount: 1,
+		wantType:  resurgo.PrologueSTRLRPreIndex,
+		wantAddr:  4,
+		wantFrame: 4,
+		wantRegs:  []string{"lr"},
+	}, {
+		name:      "str-lr-preindex-mid-function",
+		code:      arm64Insn(movR0, strLR),
+		wantCount: 0,
+	}, {
+		name:      "sub-sp",
+		code:      arm64Insn(bxLR, subSP),
+		wantCount: 1,
+		wantType:  resurgo.PrologueSubSP,
+		wantAddr:  4,
+		wantFrame: 16,
+	}, {
+		name:      "sub-sp-mid-function",
+		code:      arm64Insn(movR0, subSP),
+		wantCount: 0,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, 0, resurgo.ArchARM)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(prologues) != tt.wantCount {
+				t.Fatalf("expected %d prologue(s), got %d: %+v", tt.wantCount, len(prologues), prologues)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+			p := prologues[0]
+			if p.Type != tt.wantType {
+				t.Errorf("expected type %s, got %s", tt.wantType, p.Type)
+			}
+			if p.Address != tt.wantAddr {
+				t.Errorf("expected address 0x%x, got 0x%x", tt.wantAddr, p.Address)
+			}
+			if p.FrameSize != tt.wantFrame {
+				t.Errorf("expected frame size %d, got %d", tt.wantFrame, p.FrameSize)
+			}
+			if !slices.Equal(p.SavedRegs, tt.wantRegs) {
+				t.Errorf("expected saved registers %v, got %v", tt.wantRegs, p.SavedRegs)
+			}
+		})
+	}
+}
+
+func TestDetectProloguesThumb(t *testing.T) {
+	// T32 instructions are one or two little-endian halfwords.
+	pushR7LR := []byte{0x80, 0xb5}           // push {r7, lr}
+	addR7 := []byte{0x00, 0xaf}              // add r7, sp, #0
+	subSP8 := []byte{0x82, 0xb0}             // sub sp, #8
+	pushR4R6LR := []byte{0x70, 0xb5}         // push {r4, r5, r6, lr}
+	subwSP := []byte{0xad, 0xf6, 0x04, 0x0d} // subw sp, sp, #2052
+	pushW := []byte{0x2d, 0xe9, 0xf0, 0x4f}  // push.w {r4-r11, lr}
+	addWR7 := []byte{0x0d, 0xf1, 0x0c, 0x07} // add.w r7, sp, #12
+	strLR := []byte{0x4d, 0xf8, 0x08, 0xed}  // str lr, [sp, #-8]!
+	itNE := []byte{0x18, 0xbf}               // it ne
+	bxLR := []byte{0x70, 0x47}               // bx lr
+	movs := []byte{0x00, 0x20}               // movs r0, #0
+	nopW := []byte{0xaf, 0xf3, 0x00, 0x80}   // nop.w
+	concat := func(insns ...[]byte) []byte { return slices.Concat(insns...) }
+
+	tests := []struct {
+		name      string
+		code      []byte
+		wantCount int
+		wantType  resurgo.PrologueType
+		wantAddr  uint64
+		wantFrame uint64
+		wantRegs  []string
+	}{{
+		name:      string(resurgo.ProloguePushFPLR),
+		code:      concat(pushR7LR, addR7, subSP8),
+		wantCount: 1,
+		wantType:  resurgo.ProloguePushFPLR,
+		wantFrame: 16,
+		wantRegs:  []string{"r7", "lr"},
+	}, {
+		name:      "push-fp-lr-wide",
+		code:      concat(movs, pushW, nopW, addWR7),
+		wantCount: 1,
+		wantType:  resurgo.ProloguePushFPLR,
+		wantAddr:  2,
+		wantFrame: 36,
+		wantRegs:  []string{"r4", "r5", "r6", "r7", "r8", "r9", "r10", "fp", "lr"},
+	}, {
+		// r7 is saved as a callee-saved register, not as a frame pointer.
+		name:      string(resurgo.ProloguePushLR),
+		code:      concat(pushR4R6LR, subwSP),
+		wantCount: 1,
+		wantType:  resurgo.ProloguePushLR,
+		wantFrame: 2068,
+		wantRegs:  []string{"r4", "r5", "r6", "lr"},
+	}, {
+		// A push inside an IT block is conditional.
+		name:      "push-lr-it-block",
+		code:      concat(itNE, pushR4R6LR),
+		wantCount: 0,
+	}, {
+		name:      "str-lr-preindex",
+		code:      concat(bxLR, strLR),
+		wantCount: 1,
+		wantType:  resurgo.PrologueSTRLRPreIndex,
+		wantAddr:  2,
+		wantFrame: 8,
+		wantRegs:  []string{"lr"},
+	}, {
+		name:      "sub-sp",
+		code:      concat(bxLR, subSP8),
+		wantCount: 1,
+		wantType:  resurgo.PrologueSubSP,
+		wantAddr:  2,
+		wantFrame: 8,
+	}, {
+		name:      "sub-sp-mid-function",
+		code:      concat(movs, subSP8),
+		wantCount: 0,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, 0, resurgo.ArchThumb)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(prologues) != tt.wantCount {
+				t.Fatalf("expected %d prologue(s), got %d: %+v", tt.wantCount, len(prologues), prologues)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+			p := prologues[0]
+			if p.Type != tt.wantType {
+				t.Errorf("expected type %s, got %s", tt.wantType, p.Type)
+			}
+			if p.Address != tt.wantAddr {
+				t.Errorf("expected address 0x%x, got 0x%x", tt.wantAddr, p.Address)
+			}
+			if p.FrameSize != tt.wantFrame {
+				t.Errorf("expected frame size %d, got %d", tt.wantFrame, p.FrameSize)
+			}
+			if !slices.Equal(p.SavedRegs, tt.wantRegs) {
+				t.Errorf("expected saved registers %v, got %v", tt.wantRegs, p.SavedRegs)
+			}
+		})
+	}
+}
+
+func TestDetectProloguesPPC64LE(t *testing.T) {
+	addisR2 := uint32(0x3c4c0002) // addis r2, r12, 2
+	addiR2 := uint32(0x38428300)  // addi r2, r2, -32000
+	mflrR0 := uint32(0x7c0802a6)  // mflr r0
+	stdR31 := uint32(0xfbe1fff8)  // std r31, -8(r1)
+	stdR0 := uint32(0xf8010010)   // std r0, 16(r1)
+	stdu48 := uint32(0xf821ffd1)  // stdu r1, -48(r1)
+	mrR31 := uint32(0x7c3f0b78)   // mr r31, r1
+	li := uint32(0x38600000)      // li r3, 0
+	blr := uint32(0x4e800020)     // blr
+	bcl := uint32(0x429f0005)     // bcl 20, 31, $+4
+	mflrR30 := uint32(0x7fc802a6) // mflr r30
+
+	tests := []struct {
+		name      string
+		code      []byte
+		wantCount int
+		wantType  resurgo.PrologueType
+		wantAddr  uint64
+		wantInsns string
+		wantFrame uint64
+		wantSize  uint64
+		wantRegs  []string
+	}{{
+		name:      string(resurgo.PrologueMFLRStdLR),
+		code:      arm64Insn(mflrR0, stdR31, stdR0, stdu48, mrR31),
+		wantCount: 1,
+		wantType:  resurgo.PrologueMFLRStdLR,
+		wantInsns: "mflr r0; std r31, -8(r1); std r0, 16(r1); stdu r1, -48(r1); mr r31, r1",
+		wantFrame: 48,
+		wantSize:  20,
+		wantRegs:  []string{"r31", "lr"},
+	}, {
+		// The TOC pointer setup of the global entry point is folded in.
+		name:      "mflr-std-lr-global-entry",
+		code:      arm64Insn(addisR2, addiR2, mflrR0, stdR0, stdu48),
+		wantCount: 1,
+		wantType:  resurgo.PrologueMFLRStdLR,
+		wantInsns: "addis r2, r12, 2; addi r2, r2, -32000; mflr r0; std r0, 16(r1); stdu r1, -48(r1)",
+		wantFrame: 48,
+		wantSize:  20,
+		wantRegs:  []string{"lr"},
+	}, {
+		name:      string(resurgo.PrologueGlobalEntry),
+		code:      arm64Insn(addisR2, addiR2, li, blr),
+		wantCount: 1,
+		wantType:  resurgo.PrologueGlobalEntry,
+		wantInsns: "addis r2, r12, 2; addi r2, r2, -32000",
+		wantSize:  8,
+	}, {
+		name:      string(resurgo.PrologueStduSP),
+		code:      arm64Insn(blr, stdu48),
+		wantCount: 1,
+		wantType:  resurgo.PrologueStduSP,
+		wantAddr:  4,
+		wantInsns: "stdu r1, -48(r1)",
+		wantFrame: 48,
+		wantSize:  4,
+	}, {
+		name:      "stdu-sp-global-entry",
+		code:      arm64Insn(addisR2, addiR2, stdu48),
+		wantCount: 1,
+		wantType:  resurgo.PrologueStduSP,
+		wantInsns: "addis r2, r12, 2; addi r2, r2, -32000; stdu r1, -48(r1)",
+		wantFrame: 48,
+		wantSize:  12,
+	}, {
+		name:      "stdu-sp-mid-function",
+		code:      arm64Insn(li, stdu48),
+		wantCount: 0,
+	}, {
+		// mflr reading the address pushed by bcl does not save the link
+		// register.
+		name:      "mflr-pc-fetch",
+		code:      arm64Insn(bcl, mflrR30, li),
+		wantCount: 0,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, 0, resurgo.ArchPPC64LE)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(prologues) != tt.wantCount {
+				t.Fatalf("expected %d prologue(s), got %d: %+v", tt.wantCount, len(prologues), prologues)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+			p := prologues[0]
+			if p.Type != tt.wantType {
+				t.Errorf("expected type %s, got %s", tt.wantType, p.Type)
+			}
+			if p.Address != tt.wantAddr {
+				t.Errorf("expected address 0x%x, got 0x%x", tt.wantAddr, p.Address)
+			}
+			if p.Instructions != tt.wantInsns {
+				t.Errorf("expected instructions %q, got %q", tt.wantInsns, p.Instructions)
+			}
+			if p.FrameSize != tt.wantFrame {
+				t.Errorf("expected frame size %d, got %d", tt.wantFrame, p.FrameSize)
+			}
+			if p.Size != tt.wantSize {
+				t.Errorf("expected size %d, got %d", tt.wantSize, p.Size)
+			}
+			if !slices.Equal(p.SavedRegs, tt.wantRegs) {
+				t.Errorf("expected saved registers %v, got %v", tt.wantRegs, p.SavedRegs)
+			}
+		})
+	}
+}
+
+func TestDetectProloguesXtensa(t *testing.T) {
+	entry := []byte{0x36, 0x41, 0x00}   // entry a1, 32
+	addi32 := []byte{0x12, 0xc1, 0xe0}  // addi a1, a1, -32
+	addi16 := []byte{0x12, 0xc1, 0xf0}  // addi a1, a1, -16
+	addiPos := []byte{0x12, 0xc1, 0x10} // addi a1, a1, 16
+	saveA0N := []byte{0x09, 0x71}       // s32i.n a0, a1, 28
+	saveA15 := []byte{0xf9, 0x61}       // s32i.n a15, a1, 24
+	saveA0 := []byte{0x02, 0x61, 0x03}  // s32i a0, a1, 12
+	movFP := []byte{0xfd, 0x01}         // mov.n a15, a1
+	mov := []byte{0x30, 0x23, 0x20}     // mov a2, a3
+	retN := []byte{0x0d, 0xf0}          // ret.n
+	retwN := []byte{0x1d, 0xf0}         // retw.n
+
+	tests := []struct {
+		name      string
+		code      []byte
+		wantCount int
+		wantType  resurgo.PrologueType
+		wantAddr  uint64
+		wantInsns string
+		wantFrame uint64
+		wantSize  uint64
+		wantRegs  []string
+	}{{
+		name:      string(resurgo.PrologueEntry),
+		code:      slices.Concat(entry, retwN),
+		wantCount: 1,
+		wantType:  resurgo.PrologueEntry,
+		wantInsns: "entry a1, 32",
+		wantFrame: 32,
+		wantSize:  3,
+	}, {
+		name:      string(resurgo.PrologueAddiSPFrame),
+		code:      slices.Concat(addi32, saveA0N, saveA15, movFP),
+		wantCount: 1,
+		wantType:  resurgo.PrologueAddiSPFrame,
+		wantInsns: "addi a1, a1, -32; s32i.n a0, a1, 28; s32i.n a15, a1, 24; mov.n a15, a1",
+		wantFrame: 32,
+		wantSize:  9,
+		wantRegs:  []string{"a0", "a15"},
+	}, {
+		name:      string(resurgo.PrologueAddiSPSaveRA),
+		code:      slices.Concat(addi16, saveA0, retN),
+		wantCount: 1,
+		wantType:  resurgo.PrologueAddiSPSaveRA,
+		wantInsns: "addi a1, a1, -16; s32i a0, a1, 12",
+		wantFrame: 16,
+		wantSize:  6,
+		wantRegs:  []string{"a0"},
+	}, {
+		name:      string(resurgo.PrologueAddiSP),
+		code:      slices.Concat(retN, addi16, mov, retN),
+		wantCount: 1,
+		wantType:  resurgo.PrologueAddiSP,
+		wantAddr:  2,
+		wantInsns: "addi a1, a1, -16",
+		wantFrame: 16,
+		wantSize:  3,
+	}, {
+		name:      "addi-sp-mid-function",
+		code:      slices.Concat(mov, addi16, retN),
+		wantCount: 0,
+	}, {
+		name:      "addi-sp-release",
+		code:      slices.Concat(retN, addiPos, retN),
+		wantCount: 0,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, 0, resurgo.ArchXtensa)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(prologues) != tt.wantCount {
+				t.Fatalf("expected %d prologue(s), got %d: %+v", tt.wantCount, len(prologues), prologues)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+			p := prologues[0]
+			if p.Type != tt.wantType {
+				t.Errorf("expected type %s, got %s", tt.wantType, p.Type)
+			}
+			if p.Address != tt.wantAddr {
+				t.Errorf("expected address 0x%x, got 0x%x", tt.wantAddr, p.Address)
+			}
+			if p.Instructions != tt.wantInsns {
+				t.Errorf("expected instructions %q, got %q", tt.wantInsns, p.Instructions)
+			}
+			if p.FrameSize != tt.wantFrame {
+				t.Errorf("expected frame size %d, got %d", tt.wantFrame, p.FrameSize)
+			}
+			if p.Size != tt.wantSize {
+				t.Errorf("expected size %d, got %d", tt.wantSize, p.Size)
+			}
+			if !slices.Equal(p.SavedRegs, tt.wantRegs) {
+				t.Errorf("expected saved registers %v, got %v", tt.wantRegs, p.SavedRegs)
+			}
+		})
+	}
+}
+
+func TestDetectProloguesRISCV64(t *testing.T) {
+	// RISC-V base instructions are little-endian 32-bit words, as on ARM64.
+	addiSP32 := uint32(0xfe010113) // addi sp, sp, -32
+	sdRA24 := uint32(0x00113c23)   // sd ra, 24(sp)
+	sdS016 := uint32(0x00813823)   // sd s0, 16(sp)
+	addiS0 := uint32(0x02010413)   // addi s0, sp, 32
+	addiSP16 := uint32(0xff010113) // addi sp, sp, -16
+	sdRA8 := uint32(0x00113423)    // sd ra, 8(sp)
+	addiSP48 := uint32(0xfd010113) // addi sp, sp, -48
+	sdS10 := uint32(0x00913023)    // sd s1, 0(sp)
+	mvA5 := uint32(0x00050793)     // mv a5, a0
+	nop := uint32(0x00000013)      // nop
+	ret := uint32(0x00008067)      // ret
+
+	tests := []struct {
+		name      string
+		code      []byte
+		wantCount int
+		wantType  resurgo.PrologueType
+		wantAddr  uint64
+		wantFrame uint64
+		wantRegs  []string
+	}{{
+		name:      string(resurgo.PrologueAddiSPFrame),
+		code:      arm64Insn(addiSP32, sdRA24, sdS016, addiS0),
+		wantCount: 1,
+		wantType:  resurgo.PrologueAddiSPFrame,
+		wantAddr:  0,
+		wantFrame: 32,
+		wantRegs:  []string{"ra", "s0"},
+	}, {
+		// The saves may be scheduled among other instructions.
+		name:      "addi-sp-frame-interleaved",
+		code:      arm64Insn(addiSP32, sdS016, mvA5, sdRA24, addiS0),
+		wantCount: 1,
+		wantType:  resurgo.PrologueAddiSPFrame,
+		wantAddr:  0,
+		wantFrame: 32,
+		wantRegs:  []string{"s0", "ra"},
+	}, {
+		// Saving ra is entry evidence wherever it occurs.
+		name:      string(resurgo.PrologueAddiSPSaveRA),
+		code:      arm64Insn(nop, addiSP16, sdRA8),
+		wantCount: 1,
+		wantType:  resurgo.PrologueAddiSPSaveRA,
+		wantAddr:  4,
+		wantFrame: 16,
+		wantRegs:  []string{"ra"},
+	}, {
+		// ret; addi sp, sp, -48; sd s1, 0(sp) - a leaf function frame.
+		name:      string(resurgo.PrologueAddiSP),
+		code:      arm64Insn(ret, addiSP48, sdS10),
+		wantCount: 1,
+		wantType:  resurgo.PrologueAddiSP,
+		wantAddr:  4,
+		wantFrame: 48,
+	}, {
+		// c.addi16sp sp, -32; c.sdsp ra, 24(sp); c.sdsp s0, 16(sp);
+		// c.addi4spn s0, sp, 32
+		name:      "addi-sp-frame-compressed",
+		code:      []byte{0x01, 0x11, 0x06, 0xec, 0x22, 0xe8, 0x00, 0x10},
+		wantCount: 1,
+		wantType:  resurgo.PrologueAddiSPFrame,
+		wantAddr:  0,
+		wantFrame: 32,
+		wantRegs:  []string{"ra", "s0"},
+	}, {
+		// c.nop; c.addi16sp sp, -16; c.sdsp ra, 8(sp)
+		name:      "addi-sp-save-ra-compressed",
+		code:      []byte{0x01, 0x00, 0x41, 0x11, 0x06, 0xe4},
+		wantCount: 1,
+		wantType:  resurgo.PrologueAddiSPSaveRA,
+		wantAddr:  2,
+		wantFrame: 16,
+		wantRegs:  []string{"ra"},
+	}, {
+		// c.ret; addi sp, sp, -48; c.sdsp s1, 0(sp) - a base instruction
+		// at a 2-byte aligned address.
+		name:      "addi-sp-mixed-lengths",
+		code:      append(append([]byte{0x82, 0x80}, arm64Insn(addiSP48)...), 0x26, 0xe0),
+		wantCount: 1,
+		wantType:  resurgo.PrologueAddiSP,
+		wantAddr:  2,
+		wantFrame: 48,
+	}, {
+		// nop; addi sp, sp, -48 - not at a function boundary
+		name:      "addi-sp-mid-function",
+		code:      arm64Insn(nop, addiSP48),
+		wantCount: 0,
+	}, {
+		name:      "RISCV64_InvalidBytes",
+		code:      []byte{0xff, 0xff, 0xff, 0xff},
+		wantCount: 0,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, 0, resurgo.ArchRISCV64)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(prologues) != tt.wantCount {
+				t.Fatalf("expected %d prologue(s), got %d: %+v", tt.wantCount, len(prologues), prologues)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+			p := prologues[0]
+			if p.Type != tt.wantType {
+				t.Errorf("expected type %s, got %s", tt.wantType, p.Type)
+			}
+			if p.Address != tt.wantAddr {
+				t.Errorf("expected address 0x%x, got 0x%x", tt.wantAddr, p.Address)
+			}
+			if p.FrameSize != tt.wantFrame {
+				t.Errorf("expected frame size %d, got %d", tt.wantFrame, p.FrameSize)
+			}
+			if !slices.Equal(p.SavedRegs, tt.wantRegs) {
+				t.Errorf("expected saved registers %v, got %v", tt.wantRegs, p.SavedRegs)
+			}
+		})
+	}
+}
+
+func TestDetectPrologues_UnsupportedArch(t *testing.T) {
+	_, err := resurgo.DetectPrologues([]byte{0x00}, 0, resurgo.Arch("mips"))
+	if err == nil {
+		t.Fatal("expected error for unsupported architecture, got nil")
+	}
+}
+
+func TestWithByteOrder(t *testing.T) {
+	// stp x29, x30, [sp, #-16]!; mov x29, sp with byte-swapped words.
+	code := make([]byte, 8)
+	binary.BigEndian.PutUint32(code[0:], 0xa9bf7bfd)
+	binary.BigEndian.PutUint32(code[4:], 0x910003fd)
+
+	prologues, err := resurgo.DetectPrologues(code, 0, resurgo.ArchARM64, resurgo.WithByteOrder(binary.BigEndian))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prologues) != 1 || prologues[0].Type != resurgo.PrologueSTPFramePair {
+		t.Errorf("expected one %s prologue, got %+v", resurgo.PrologueSTPFramePair, prologues)
+	}
+
+	prologues, err = resurgo.DetectPrologues(code, 0, resurgo.ArchARM64)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prologues) != 0 {
+		t.Errorf("expected no prologues without WithByteOrder, got %+v", prologues)
+	}
+
+	_, err = resurgo.DetectPrologues([]byte{0x55}, 0, resurgo.ArchAMD64, resurgo.WithByteOrder(binary.BigEndian))
+	if err == nil {
+		t.Fatal("expected error for big-endian amd64, got nil")
+	}
+
+	// ppc64 stores instructions big-endian: mflr r0; std r0, 16(r1).
+	ppc64 := make([]byte, 8)
+	binary.BigEndian.PutUint32(ppc64[0:], 0x7c0802a6)
+	binary.BigEndian.PutUint32(ppc64[4:], 0xf8010010)
+
+	prologues, err = resurgo.DetectPrologues(ppc64, 0, resurgo.ArchPPC64)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prologues) != 1 || prologues[0].Type != resurgo.PrologueMFLRStdLR {
+		t.Errorf("expected one %s prologue, got %+v", resurgo.PrologueMFLRStdLR, prologues)
+	}
+
+	prologues, err = resurgo.DetectPrologues(ppc64, 0, resurgo.ArchPPC64, resurgo.WithByteOrder(binary.LittleEndian))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prologues) != 0 {
+		t.Errorf("expected no prologues for little-endian ppc64 input, got %+v", prologues)
+	}
+
+	_, err = resurgo.DetectPrologues([]byte{0x36, 0x41, 0x00}, 0, resurgo.ArchXtensa, resurgo.WithByteOrder(binary.BigEndian))
+	if err == nil {
+		t.Fatal("expected error for big-endian xtensa, got nil")
+	}
+}
+
+func TestWithABI(t *testing.T) {
+	// ret; push rdi; push rsi; push rbx; sub rsp, 0x20
+	code := []byte{0xc3, 0x57, 0x56, 0x53, 0x48, 0x83, 0xec, 0x20}
+
+	tests := []struct {
+		name      string
+		opts      []resurgo.Option
+		wantFound bool
+		wantInsns string
+		wantFrame uint64
+		wantRegs  []string
+	}{{
+		name: "sysv",
+	}, {
+		name:      "win64",
+		opts:      []resurgo.Option{resurgo.WithABI(resurgo.ABIWin64)},
+		wantFound: true,
+		wantInsns: "push rdi; push rsi; push rbx; sub rsp, 0x20",
+		wantFrame: 0x38,
+		wantRegs:  []string{"rdi", "rsi", "rbx"},
+	}, {
+		name:      "custom",
+		opts:      []resurgo.Option{resurgo.WithCalleeSavedRegs("RDI", "rsi")},
+		wantFound: true,
+		wantInsns: "push rdi; push rsi",
+		wantFrame: 16,
+		wantRegs:  []string{"rdi", "rsi"},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(code, 0, resurgo.ArchAMD64, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			i := slices.IndexFunc(prologues, func(p resurgo.Prologue) bool { return p.Address == 1 })
+			if !tt.wantFound {
+				if i >= 0 {
+					t.Errorf("expected no prologue at 0x1, got %+v", prologues[i])
+				}
+				return
+			}
+			if i < 0 {
+				t.Fatalf("expected a prologue at 0x1, got %+v", prologues)
+			}
+			p := prologues[i]
+			if p.Type != resurgo.ProloguePushOnly {
+				t.Errorf("expected type %s, got %s", resurgo.ProloguePushOnly, p.Type)
+			}
+			if p.Instructions != tt.wantInsns {
+				t.Errorf("expected instructions %q, got %q", tt.wantInsns, p.Instructions)
+			}
+			if p.FrameSize != tt.wantFrame {
+				t.Errorf("expected frame size %d, got %d", tt.wantFrame, p.FrameSize)
+			}
+			if !slices.Equal(p.SavedRegs, tt.wantRegs) {
+				t.Errorf("expected saved registers %v, got %v", tt.wantRegs, p.SavedRegs)
+			}
+		})
+	}
+
+	if _, err := resurgo.DetectPrologues(code, 0, resurgo.ArchAMD64, resurgo.WithABI("o32")); err == nil {
+		t.Error("expected error for unknown ABI, got nil")
+	}
+	if _, err := resurgo.DetectPrologues(code, 0, resurgo.ArchAMD64, resurgo.WithCalleeSavedRegs("xmm6")); err == nil {
+		t.Error("expected error for unknown register, got nil")
+	}
+}
+
+func TestWithPatternTolerance(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      []byte
+		arch      resurgo.Arch
+		tolerance int
+		wantType  resurgo.PrologueType
+		wantAddr  uint64
+		wantFound bool
+	}{{
+		// nop; push rbp; xchg ax, ax; mov rbp, rsp
+		name:      "amd64/xchg-between-elements",
+		code:      []byte{0x90, 0x55, 0x66, 0x90, 0x48, 0x89, 0xe5},
+		arch:      resurgo.ArchAMD64,
+		tolerance: 1,
+		wantType:  resurgo.PrologueClassic,
+		wantAddr:  1,
+		wantFound: true,
+	}, {
+		// Same sequence with the default tolerance: the pattern is broken.
+		name:      "amd64/default-tolerance",
+		code:      []byte{0x90, 0x55, 0x66, 0x90, 0x48, 0x89, 0xe5},
+		arch:      resurgo.ArchAMD64,
+		tolerance: 0,
+		wantType:  resurgo.PrologueClassic,
+		wantFound: false,
+	}, {
+		// nop; push rbp; nop; nop; mov rbp, rsp - two NOPs exceed tolerance 1.
+		name:      "amd64/exceeds-tolerance",
+		code:      []byte{0x90, 0x55, 0x90, 0x90, 0x48, 0x89, 0xe5},
+		arch:      resurgo.ArchAMD64,
+		tolerance: 1,
+		wantType:  resurgo.PrologueClassic,
+		wantFound: false,
+	}, {
+		// stp x29, x30, [sp, #-16]!; nop; bti c; mov x29, sp
+		name:      "arm64/nop-bti-between-elements",
+		code:      arm64Insn(0xa9bf7bfd, 0xd503201f, 0xd503245f, 0x910003fd),
+		arch:      resurgo.ArchARM64,
+		tolerance: 2,
+		wantType:  resurgo.PrologueSTPFramePair,
+		wantAddr:  0,
+		wantFound: true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, 0, tt.arch,
+				resurgo.WithPatternTolerance(tt.tolerance))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			found := false
+			for _, p := range prologues {
+				if p.Type == tt.wantType {
+					found = true
+					if p.Address != tt.wantAddr {
+						t.Errorf("expected address 0x%x, got 0x%x", tt.wantAddr, p.Address)
+					}
+				}
+			}
+			if found != tt.wantFound {
+				t.Errorf("expected found=%v for %s, got %+v", tt.wantFound, tt.wantType, prologues)
+			}
+		})
+	}
+}
+
+func TestWithContextWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      []byte
+		arch      resurgo.Arch
+		opts      []resurgo.Option
+		wantType  resurgo.PrologueType
+		wantAddr  uint64
+		wantFound bool
+	}{{
+		// nop; ret; nop; push rbx - the default window only looks at the nop.
+		name:      "amd64/default",
+		code:      []byte{0x90, 0xc3, 0x90, 0x53},
+		arch:      resurgo.ArchAMD64,
+		wantType:  resurgo.ProloguePushOnly,
+		wantFound: false,
+	}, {
+		name: "amd64/two-instructions",
+		code: []byte{0x90, 0xc3, 0x90, 0x53},
+		arch: resurgo.ArchAMD64,
+		opts: []resurgo.Option{resurgo.WithContextWindow(resurgo.ContextWindow{
+			Instructions: 2,
+			Allowed:      []resurgo.InsnClass{resurgo.InsnClassReturn},
+		})},
+		wantType:  resurgo.ProloguePushOnly,
+		wantAddr:  3,
+		wantFound: true,
+	}, {
+		// The ret is 2 bytes before the push, beyond the 1-byte limit.
+		name: "amd64/byte-limit",
+		code: []byte{0x90, 0xc3, 0x90, 0x53},
+		arch: resurgo.ArchAMD64,
+		opts: []resurgo.Option{resurgo.WithContextWindow(resurgo.ContextWindow{
+			Instructions: 2,
+			Bytes:        1,
+			Allowed:      []resurgo.InsnClass{resurgo.InsnClassReturn},
+		})},
+		wantType:  resurgo.ProloguePushOnly,
+		wantFound: false,
+	}, {
+		// nop; push rbx; sub rsp, 0x20 - stop accepting push as a predecessor
+		// for sub rsp only.
+		name: "amd64/per-type",
+		code: []byte{0x90, 0x53, 0x48, 0x83, 0xec, 0x20},
+		arch: resurgo.ArchAMD64,
+		opts: []resurgo.Option{resurgo.WithContextWindow(resurgo.ContextWindow{
+			Instructions: 1,
+			Allowed:      []resurgo.InsnClass{resurgo.InsnClassNone, resurgo.InsnClassReturn},
+		}, resurgo.PrologueNoFramePointer)},
+		wantType:  resurgo.PrologueNoFramePointer,
+		wantFound: false,
+	}, {
+		// nop; b <target>; sub sp, sp, #0x20 - accept a preceding tail call.
+		name: "arm64/after-jump",
+		code: arm64Insn(0xd503201f, 0x14000010, 0xd10083ff),
+		arch: resurgo.ArchARM64,
+		opts: []resurgo.Option{resurgo.WithContextWindow(resurgo.ContextWindow{
+			Instructions: 1,
+			Allowed:      []resurgo.InsnClass{resurgo.InsnClassReturn, resurgo.InsnClassJump},
+		})},
+		wantType:  resurgo.PrologueSubSP,
+		wantAddr:  8,
+		wantFound: true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, 0, tt.arch, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			found := false
+			for _, p := range prologues {
+				if p.Type == tt.wantType {
+					found = true
+					if p.Address != tt.wantAddr {
+						t.Errorf("expected address 0x%x, got 0x%x", tt.wantAddr, p.Address)
+					}
+				}
+			}
+			if found != tt.wantFound {
+				t.Errorf("expected found=%v for %s, got %+v", tt.wantFound, tt.wantType, prologues)
+			}
+		})
+	}
+}
+
+func TestWithBoundaryStrategy(t *testing.T) {
+	// alignedAfterPadding requires NOP padding right before a 16-byte
+	// aligned candidate.
+	alignedAfterPadding := resurgo.BoundaryFunc(func(ctx resurgo.BoundaryContext) bool {
+		n := len(ctx.Preceding)
+		return n > 0 && ctx.Preceding[n-1].Class == resurgo.InsnClassPadding && ctx.Address%16 == 0
+	})
+	never := resurgo.BoundaryFunc(func(resurgo.BoundaryContext) bool { return false })
+	// ret; nop, nops times; push rbx
+	padded := func(nops int) []byte {
+		return append(append([]byte{0xc3}, bytes.Repeat([]byte{0x90}, nops)...), 0x53)
+	}
+
+	tests := []struct {
+		name      string
+		code      []byte
+		opts      []resurgo.Option
+		wantAddr  uint64
+		wantFound bool
+	}{{
+		name:      "default",
+		code:      padded(15),
+		wantFound: false,
+	}, {
+		name:      "aligned",
+		code:      padded(15),
+		opts:      []resurgo.Option{resurgo.WithBoundaryStrategy(alignedAfterPadding)},
+		wantAddr:  0x10,
+		wantFound: true,
+	}, {
+		name:      "misaligned",
+		code:      padded(14),
+		opts:      []resurgo.Option{resurgo.WithBoundaryStrategy(alignedAfterPadding)},
+		wantFound: false,
+	}, {
+		// ret; push rbx - a boundary for the default window only.
+		name:      "stricter",
+		code:      []byte{0xc3, 0x53},
+		opts:      []resurgo.Option{resurgo.WithBoundaryStrategy(never, resurgo.ProloguePushOnly)},
+		wantFound: false,
+	}, {
+		// The later context window replaces the strategy.
+		name: "replaced",
+		code: []byte{0xc3, 0x53},
+		opts: []resurgo.Option{
+			resurgo.WithBoundaryStrategy(never),
+			resurgo.WithContextWindow(resurgo.ContextWindow{Allowed: []resurgo.InsnClass{resurgo.InsnClassReturn}}),
+		},
+		wantAddr:  1,
+		wantFound: true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, 0, resurgo.ArchAMD64, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			i := slices.IndexFunc(prologues, func(p resurgo.Prologue) bool { return p.Type == resurgo.ProloguePushOnly })
+			if found := i >= 0; found != tt.wantFound {
+				t.Fatalf("expected found=%v, got %+v", tt.wantFound, prologues)
+			}
+			if i >= 0 && prologues[i].Address != tt.wantAddr {
+				t.Errorf("expected address 0x%x, got 0x%x", tt.wantAddr, prologues[i].Address)
+			}
+		})
+	}
+}
+
+func TestWithTrapBoundaries(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      []byte
+		arch      resurgo.Arch
+		opts      []resurgo.Option
+		wantType  resurgo.PrologueType
+		wantAddr  uint64
+		wantFound bool
+	}{{
+		// call <noreturn>; ud2; push rbx
+		name:      "amd64/ud2",
+		code:      []byte{0xe8, 0x00, 0x00, 0x00, 0x00, 0x0f, 0x0b, 0x53},
+		arch:      resurgo.ArchAMD64,
+		wantType:  resurgo.ProloguePushOnly,
+		wantAddr:  7,
+		wantFound: true,
+	}, {
+		// nop; int3; sub rsp, 0x18
+		name:      "amd64/int3",
+		code:      []byte{0x90, 0xcc, 0x48, 0x83, 0xec, 0x18},
+		arch:      resurgo.ArchAMD64,
+		wantType:  resurgo.PrologueNoFramePointer,
+		wantAddr:  2,
+		wantFound: true,
+	}, {
+		name:      "amd64/disabled",
+		code:      []byte{0xe8, 0x00, 0x00, 0x00, 0x00, 0x0f, 0x0b, 0x53},
+		arch:      resurgo.ArchAMD64,
+		opts:      []resurgo.Option{resurgo.WithTrapBoundaries(false)},
+		wantType:  resurgo.ProloguePushOnly,
+		wantFound: false,
+	}, {
+		// An explicit context window replaces the trap policy.
+		name: "amd64/context-window",
+		code: []byte{0xe8, 0x00, 0x00, 0x00, 0x00, 0x0f, 0x0b, 0x53},
+		arch: resurgo.ArchAMD64,
+		opts: []resurgo.Option{resurgo.WithContextWindow(resurgo.ContextWindow{
+			Instructions: 1,
+			Allowed:      []resurgo.InsnClass{resurgo.InsnClassReturn},
+		})},
+		wantType:  resurgo.ProloguePushOnly,
+		wantFound: false,
+	}, {
+		// bl <noreturn>; brk #0x1; sub sp, sp, #0x20
+		name:      "arm64/brk",
+		code:      arm64Insn(0x94000010, 0xd4200020, 0xd10083ff),
+		arch:      resurgo.ArchARM64,
+		wantType:  resurgo.PrologueSubSP,
+		wantAddr:  8,
+		wantFound: true,
+	}, {
+		name:      "arm64/disabled",
+		code:      arm64Insn(0x94000010, 0xd4200020, 0xd10083ff),
+		arch:      resurgo.ArchARM64,
+		opts:      []resurgo.Option{resurgo.WithTrapBoundaries(false)},
+		wantType:  resurgo.PrologueSubSP,
+		wantFound: false,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, 0, tt.arch, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			found := false
+			for _, p := range prologues {
+				if p.Type == tt.wantType {
+					found = true
+					if p.Address != tt.wantAddr {
+						t.Errorf("expected address 0x%x, got 0x%x", tt.wantAddr, p.Address)
+					}
+				}
+			}
+			if found != tt.wantFound {
+				t.Errorf("expected found=%v for %s, got %+v", tt.wantFound, tt.wantType, prologues)
+			}
+		})
+	}
+}
+
+func TestWithToolchain(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      []byte
+		arch      resurgo.Arch
+		toolchain resurgo.Toolchain
+		want      []resurgo.Prologue
+	}{{
+		// cmp rsp, [r14+0x10]; jbe; push rbp; mov rbp, rsp; sub rsp, 0x18
+		name:      "go/amd64",
+		code:      []byte{0x49, 0x3b, 0x66, 0x10, 0x76, 0x20, 0x55, 0x48, 0x89, 0xe5, 0x48, 0x83, 0xec, 0x18},
+		arch:      resurgo.ArchAMD64,
+		toolchain: resurgo.ToolchainGo,
+		want: []resurgo.Prologue{{
+			Address:      0,
+			Type:         resurgo.PrologueGoStackCheck,
+			Instructions: "cmp rsp, [r14+0x10]; jbe; push rbp; mov rbp, rsp; sub rsp, 0x18",
+			FrameSize:    0x20,
+			Size:         14,
+			SavedRegs:    []string{"rbp"},
+		}},
+	}, {
+		// The same bytes without the Go profile: the frame setup is an
+		// ordinary classic prologue after the check.
+		name:      "generic/amd64",
+		code:      []byte{0x49, 0x3b, 0x66, 0x10, 0x76, 0x20, 0x55, 0x48, 0x89, 0xe5, 0x48, 0x83, 0xec, 0x18},
+		arch:      resurgo.ArchAMD64,
+		toolchain: resurgo.ToolchainGeneric,
+		want: []resurgo.Prologue{{
+			Address:      6,
+			Type:         resurgo.PrologueClassic,
+			Instructions: "push rbp; mov rbp, rsp; sub rsp, 0x18",
+			FrameSize:    0x20,
+			Size:         8,
+			SavedRegs:    []string{"rbp"},
+		}},
+	}, {
+		// lea r12, [rsp-0x1c8]; cmp r12, [r14+0x10]; jbe
+		name:      "go/amd64/large-frame",
+		code:      []byte{0x4c, 0x8d, 0xa4, 0x24, 0x38, 0xfe, 0xff, 0xff, 0x4d, 0x3b, 0x66, 0x10, 0x0f, 0x86, 0xed, 0x00, 0x00, 0x00},
+		arch:      resurgo.ArchAMD64,
+		toolchain: resurgo.ToolchainGo,
+		want: []resurgo.Prologue{{
+			Address:      0,
+			Type:         resurgo.PrologueGoStackCheck,
+			Instructions: "lea r12, [rsp-0x1c8]; cmp r12, [r14+0x10]; jbe",
+			Size:         18,
+		}},
+	}, {
+		// ldr x16, [x28, #16]; sub x17, sp, #0x1d0; cmp x17, x16; b.ls
+		name:      "go/arm64",
+		code:      arm64Insn(0xf9400b90, 0xd10743f1, 0xeb10023f, 0x540006e9),
+		arch:      resurgo.ArchARM64,
+		toolchain: resurgo.ToolchainGo,
+		want: []resurgo.Prologue{{
+			Address:      0,
+			Type:         resurgo.PrologueGoStackCheck,
+			Instructions: "ldr x16, [x28, #16]; cmp; b.ls",
+			Size:         16,
+		}},
+	}, {
+		// mov [rsp+8], rcx; mov [rsp+0x10], rbx; sub rsp, 0x28
+		name:      "msvc/amd64",
+		code:      []byte{0x48, 0x89, 0x4c, 0x24, 0x08, 0x48, 0x89, 0x5c, 0x24, 0x10, 0x48, 0x83, 0xec, 0x28},
+		arch:      resurgo.ArchAMD64,
+		toolchain: resurgo.ToolchainMSVC,
+		want: []resurgo.Prologue{{
+			Address:      0,
+			Type:         resurgo.PrologueHomeSpill,
+			Instructions: "mov [rsp+0x8], rcx; mov [rsp+0x10], rbx",
+			Size:         10,
+			SavedRegs:    []string{"rbx"},
+		}},
+	}, {
+		// enter 0x20, 0 - never emitted by GCC.
+		name:      "gcc/amd64/enter",
+		code:      []byte{0xc8, 0x20, 0x00, 0x00},
+		arch:      resurgo.ArchAMD64,
+		toolchain: resurgo.ToolchainGCC,
+		want:      nil,
+	}, {
+		// ret; nop; push rbx - GCC aligns function entries with NOP fill.
+		name:      "gcc/amd64/padding",
+		code:      []byte{0xc3, 0x90, 0x53},
+		arch:      resurgo.ArchAMD64,
+		toolchain: resurgo.ToolchainGCC,
+		want: []resurgo.Prologue{{
+			Address:      2,
+			Type:         resurgo.ProloguePushOnly,
+			Instructions: "push rbx",
+			FrameSize:    8,
+			Size:         1,
+			SavedRegs:    []string{"rbx"},
+		}},
+	}, {
+		// mov eax, 0x2018; call __rust_probestack; sub rsp, rax
+		name:      "rust/amd64/probestack",
+		code:      []byte{0xb8, 0x18, 0x20, 0x00, 0x00, 0xe8, 0x00, 0x00, 0x00, 0x00, 0x48, 0x29, 0xc4},
+		arch:      resurgo.ArchAMD64,
+		toolchain: resurgo.ToolchainRust,
+		want: []resurgo.Prologue{{
+			Address:      0,
+			Type:         resurgo.PrologueRustProbestack,
+			Instructions: "mov eax, 0x2018; call __rust_probestack; sub rsp, rax",
+			FrameSize:    0x2018,
+			Size:         13,
+		}},
+	}, {
+		// push rbx; mov eax, 0x2018; call __rust_probestack; sub rsp, rax -
+		// the probe closes the entry sequence of the push.
+		name:      "rust/amd64/push-probestack",
+		code:      []byte{0x53, 0xb8, 0x18, 0x20, 0x00, 0x00, 0xe8, 0x00, 0x00, 0x00, 0x00, 0x48, 0x29, 0xc4},
+		arch:      resurgo.ArchAMD64,
+		toolchain: resurgo.ToolchainRust,
+		want: []resurgo.Prologue{{
+			Address:      0,
+			Type:         resurgo.ProloguePushOnly,
+			Instructions: "push rbx; mov eax, 0x2018; call __rust_probestack; sub rsp, rax",
+			FrameSize:    0x2020,
+			Size:         14,
+			SavedRegs:    []string{"rbx"},
+		}},
+	}, {
+		// mov rdi, [rdi]; jmp <T as core::fmt::Display>::fmt
+		name:      "rust/amd64/fmt-thunk",
+		code:      []byte{0x48, 0x8b, 0x3f, 0xe9, 0x00, 0x01, 0x00, 0x00},
+		arch:      resurgo.ArchAMD64,
+		toolchain: resurgo.ToolchainRust,
+		want: []resurgo.Prologue{{
+			Address:      0,
+			Type:         resurgo.PrologueFmtThunk,
+			Instructions: "mov rdi, [rdi]; jmp",
+			Size:         8,
+		}},
+	}, {
+		// The same thunk is not reported without the Rust profile.
+		name:      "generic/amd64/fmt-thunk",
+		code:      []byte{0x48, 0x8b, 0x3f, 0xe9, 0x00, 0x01, 0x00, 0x00},
+		arch:      resurgo.ArchAMD64,
+		toolchain: resurgo.ToolchainGeneric,
+		want:      nil,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resurgo.DetectPrologues(tt.code, 0, tt.arch, resurgo.WithToolchain(tt.toolchain))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d prologues, got %+v", len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				if got[i].Address != want.Address || got[i].Type != want.Type ||
+					got[i].Instructions != want.Instructions || got[i].FrameSize != want.FrameSize ||
+					got[i].Size != want.Size || !slices.Equal(got[i].SavedRegs, want.SavedRegs) {
+					t.Errorf("expected %+v, got %+v", want, got[i])
+				}
+			}
+		})
+	}
+}
+
+// TestDetectPrologues_Order verifies that prologues are ordered by address,
+// then from the strongest pattern, and that repeated runs agree.
+func TestDetectPrologues_Order(t *testing.T) {
+	// push rbp; mov rbp, rsp; ret; sub rsp, 0x18; ret; push rbx; ret
+	code := []byte{0x55, 0x48, 0x89, 0xe5, 0xc3, 0x48, 0x83, 0xec, 0x18, 0xc3, 0x53, 0xc3}
+	want := []struct {
+		addr uint64
+		typ  resurgo.PrologueType
+	}{
+		{0, resurgo.PrologueClassic},
+		{5, resurgo.PrologueNoFramePointer},
+		{10, resurgo.ProloguePushOnly},
+	}
+
+	first, err := resurgo.DetectPrologues(code, 0, resurgo.ArchAMD64)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(first) != len(want) {
+		t.Fatalf("expected %d prologues, got %+v", len(want), first)
+	}
+	for i, w := range want {
+		if first[i].Address != w.addr || first[i].Type != w.typ {
+			t.Errorf("prologue %d: expected %s at 0x%x, got %s at 0x%x", i, w.typ, w.addr, first[i].Type, first[i].Address)
+		}
+	}
+	for range 10 {
+		again, err := resurgo.DetectPrologues(code, 0, resurgo.ArchAMD64)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(first, again) {
+			t.Fatalf("expected identical results across runs, got %+v and %+v", first, again)
+		}
+	}
+}
+
+func TestWithSyntax(t *testing.T) {
+	// push rbp; mov rbp, rsp; sub rsp, 0x18
+	amd64 := []byte{0x55, 0x48, 0x89, 0xe5, 0x48, 0x83, 0xec, 0x18}
+	// stp x29, x30, [sp, #-16]!; mov x29, sp
+	arm64 := arm64Insn(0xa9bf7bfd, 0x910003fd)
+	// c.addi16sp sp, -16; sd ra, 8(sp)
+	riscv64 := append([]byte{0x41, 0x11}, arm64Insn(0x00113423)...)
+
+	tests := []struct {
+		name    string
+		code    []byte
+		arch    resurgo.Arch
+		syntax  resurgo.Syntax
+		want    string
+		wantErr bool
+	}{{
+		name:   "amd64/intel",
+		code:   amd64,
+		arch:   resurgo.ArchAMD64,
+		syntax: resurgo.SyntaxIntel,
+		want:   "push rbp; mov rbp, rsp; sub rsp, 0x18",
+	}, {
+		name:   "amd64/gnu",
+		code:   amd64,
+		arch:   resurgo.ArchAMD64,
+		syntax: resurgo.SyntaxGNU,
+		want:   "push %rbp; mov %rsp,%rbp; sub $0x18,%rsp",
+	}, {
+		name:   "arm64/arm",
+		code:   arm64,
+		arch:   resurgo.ArchARM64,
+		syntax: resurgo.SyntaxARM,
+		want:   "STP X29, X30, [SP,#-16]!; MOV X29, SP",
+	}, {
+		name:   "arm64/gnu",
+		code:   arm64,
+		arch:   resurgo.ArchARM64,
+		syntax: resurgo.SyntaxGNU,
+		want:   "stp x29, x30, [sp,#-16]!; mov x29, sp",
+	}, {
+		name:   "riscv64/gnu",
+		code:   riscv64,
+		arch:   resurgo.ArchRISCV64,
+		syntax: resurgo.SyntaxGNU,
+		want:   "addi x2,x2,-16; sd x1,8(x2)",
+	}, {
+		name:    "riscv64/intel",
+		code:    riscv64,
+		arch:    resurgo.ArchRISCV64,
+		syntax:  resurgo.SyntaxIntel,
+		wantErr: true,
+	}, {
+		name:   "arm/arm",
+		code:   arm64Insn(0xe92d4800, 0xe28db004), // push {fp, lr}; add fp, sp, #4
+		arch:   resurgo.ArchARM,
+		syntax: resurgo.SyntaxARM,
+		want:   "PUSH {R11,LR}; ADD R11, SP, #0x4",
+	}, {
+		name:   "arm/gnu",
+		code:   arm64Insn(0xe92d4800, 0xe28db004),
+		arch:   resurgo.ArchARM,
+		syntax: resurgo.SyntaxGNU,
+		want:   "push {fp, lr}; add fp, sp, #4",
+	}, {
+		name:   "ppc64le/gnu",
+		code:   arm64Insn(0x7c0802a6, 0xf8010010, 0xf821ffd1), // mflr r0; std r0, 16(r1); stdu r1, -48(r1)
+		arch:   resurgo.ArchPPC64LE,
+		syntax: resurgo.SyntaxGNU,
+		want:   "mflr r0; std r0,16(r1); stdu r1,-48(r1)",
+	}, {
+		name:    "amd64/arm",
+		code:    amd64,
+		arch:    resurgo.ArchAMD64,
+		syntax:  resurgo.SyntaxARM,
+		wantErr: true,
+	}, {
+		name:    "arm64/intel",
+		code:    arm64,
+		arch:    resurgo.ArchARM64,
+		syntax:  resurgo.SyntaxIntel,
+		wantErr: true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resurgo.DetectPrologues(tt.code, 0, tt.arch, resurgo.WithSyntax(tt.syntax))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) == 0 {
+				t.Fatal("expected a prologue, got none")
+			}
+			if got[0].Instructions != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got[0].Instructions)
+			}
+		})
+	}
+}
+
+func TestWithMaxFrameSize(t *testing.T) {
+	// sub rsp, 0x7fffffff - an implausible frame decoded out of data.
+	huge := []byte{0x48, 0x81, 0xec, 0xff, 0xff, 0xff, 0x7f}
+	// sub rsp, 0x2000
+	large := []byte{0x48, 0x81, 0xec, 0x00, 0x20, 0x00, 0x00}
+
+	tests := []struct {
+		name      string
+		code      []byte
+		opts      []resurgo.Option
+		wantCount int
+	}{{
+		name:      "default/huge",
+		code:      huge,
+		wantCount: 0,
+	}, {
+		name:      "default/large",
+		code:      large,
+		wantCount: 1,
+	}, {
+		name:      "disabled",
+		code:      huge,
+		opts:      []resurgo.Option{resurgo.WithMaxFrameSize(0)},
+		wantCount: 1,
+	}, {
+		name:      "custom",
+		code:      large,
+		opts:      []resurgo.Option{resurgo.WithMaxFrameSize(0x1000)},
+		wantCount: 0,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, 0, resurgo.ArchAMD64, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(prologues) != tt.wantCount {
+				t.Errorf("expected %d prologue(s), got %d: %+v", tt.wantCount, len(prologues), prologues)
+			}
+		})
+	}
+}
+
+func TestDetectPrologues_EntrySequence(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      []byte
+		wantType  resurgo.PrologueType
+		wantInsns string
+		wantFrame uint64
+		wantSize  uint64
+	}{{
+		// push rbx; push r12; push r13; sub rsp, 0x18
+		name:      "push-only",
+		code:      []byte{0x53, 0x41, 0x54, 0x41, 0x55, 0x48, 0x83, 0xec, 0x18},
+		wantType:  resurgo.ProloguePushOnly,
+		wantInsns: "push rbx; push r12; push r13; sub rsp, 0x18",
+		wantFrame: 3*8 + 0x18,
+		wantSize:  9,
+	}, {
+		// nop; push rbp; mov rbp, rsp; push rbx; sub rsp, 0x28
+		name:      "classic",
+		code:      []byte{0x90, 0x55, 0x48, 0x89, 0xe5, 0x53, 0x48, 0x83, 0xec, 0x28},
+		wantType:  resurgo.PrologueClassic,
+		wantInsns: "push rbp; mov rbp, rsp; push rbx; sub rsp, 0x28",
+		wantFrame: 2*8 + 0x28,
+		wantSize:  9,
+	}, {
+		// lea rsp, [rsp-0x1000] - the displacement needs 32 bits.
+		name:      "lea-disp32",
+		code:      []byte{0x48, 0x8d, 0xa4, 0x24, 0x00, 0xf0, 0xff, 0xff},
+		wantType:  resurgo.PrologueLEABased,
+		wantInsns: "lea rsp, [rsp-offset]",
+		wantFrame: 0x1000,
+		wantSize:  8,
+	}, {
+		// push rbx; lea rsp, [rsp-0x1000]
+		name:      "push-only-lea-disp32",
+		code:      []byte{0x53, 0x48, 0x8d, 0xa4, 0x24, 0x00, 0xf0, 0xff, 0xff},
+		wantType:  resurgo.ProloguePushOnly,
+		wantInsns: "push rbx; lea rsp, 0x1000",
+		wantFrame: 8 + 0x1000,
+		wantSize:  9,
+	}, {
+		// push rbp; mov rbp, rsp; push rbx; sub rsp, 0x18 at start of code,
+		// where push rbp alone also opens a push-only match.
+		name:      "classic-at-start",
+		code:      []byte{0x55, 0x48, 0x89, 0xe5, 0x53, 0x48, 0x83, 0xec, 0x18},
+		wantType:  resurgo.PrologueClassic,
+		wantInsns: "push rbp; mov rbp, rsp; push rbx; sub rsp, 0x18",
+		wantFrame: 2*8 + 0x18,
+		wantSize:  9,
+	}, {
+		// ret; push rbx; push rbp; mov rbp, rsp; sub rsp, 0x18 - the frame
+		// setup inside the push-only record is not reported again.
+		name:      "push-only-then-classic",
+		code:      []byte{0xc3, 0x53, 0x55, 0x48, 0x89, 0xe5, 0x48, 0x83, 0xec, 0x18},
+		wantType:  resurgo.ProloguePushOnly,
+		wantInsns: "push rbx; push rbp; mov rbp, rsp; sub rsp, 0x18",
+		wantFrame: 2*8 + 0x18,
+		wantSize:  9,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, 0, resurgo.ArchAMD64)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(prologues) != 1 {
+				t.Fatalf("expected a single prologue record, got %d: %+v", len(prologues), prologues)
+			}
+			p := prologues[0]
+			if p.Type != tt.wantType {
+				t.Errorf("expected type %s, got %s", tt.wantType, p.Type)
+			}
+			if p.Instructions != tt.wantInsns {
+				t.Errorf("expected instructions %q, got %q", tt.wantInsns, p.Instructions)
+			}
+			if p.FrameSize != tt.wantFrame {
+				t.Errorf("expected frame size 0x%x, got 0x%x", tt.wantFrame, p.FrameSize)
+			}
+			if p.Size != tt.wantSize {
+				t.Errorf("expected size %d, got %d", tt.wantSize, p.Size)
+			}
+		})
+	}
+}
+
+func TestDetectPrologues_SavedRegs(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     []byte
+		arch     resurgo.Arch
+		wantType resurgo.PrologueType
+		wantRegs []string
+	}{{
+		// push r12 (REX.B)
+		name:     "amd64/push-r12",
+		code:     []byte{0x41, 0x54},
+		arch:     resurgo.ArchAMD64,
+		wantType: resurgo.ProloguePushOnly,
+		wantRegs: []string{"r12"},
+	}, {
+		// push r15; push r14; push r13; push rbx; sub rsp, 0x18
+		name:     "amd64/push-r15-r13",
+		code:     []byte{0x41, 0x57, 0x41, 0x56, 0x41, 0x55, 0x53, 0x48, 0x83, 0xec, 0x18},
+		arch:     resurgo.ArchAMD64,
+		wantType: resurgo.ProloguePushOnly,
+		wantRegs: []string{"r15", "r14", "r13", "rbx"},
+	}, {
+		// nop; push rbp; mov rbp, rsp; push r14; push rbx
+		name:     "amd64/classic",
+		code:     []byte{0x90, 0x55, 0x48, 0x89, 0xe5, 0x41, 0x56, 0x53},
+		arch:     resurgo.ArchAMD64,
+		wantType: resurgo.PrologueClassic,
+		wantRegs: []string{"rbp", "r14", "rbx"},
+	}, {
+		// stp x29, x30, [sp, #-32]!; mov x29, sp; stp x19, x20, [sp, #16]
+		name:     "arm64/stp-frame-pair",
+		code:     arm64Insn(0xa9be7bfd, 0x910003fd, 0xa90153f3),
+		arch:     resurgo.ArchARM64,
+		wantType: resurgo.PrologueSTPFramePair,
+		wantRegs: []string{"x29", "x30", "x19", "x20"},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(tt.code, 0, tt.arch)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, p := range prologues {
+				if p.Type != tt.wantType {
+					continue
+				}
+				if !slices.Equal(p.SavedRegs, tt.wantRegs) {
+					t.Errorf("expected saved registers %v, got %v", tt.wantRegs, p.SavedRegs)
+				}
+				return
+			}
+			t.Fatalf("no %s prologue found: %+v", tt.wantType, prologues)
+		})
 	}
 }
 
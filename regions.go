@@ -0,0 +1,48 @@
+package resurgo
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// CodeRegion is a block of machine code of a memory map, e.g. one of the
+// executable regions of a flat firmware image.
+type CodeRegion struct {
+	// Code holds the machine code of the region.
+	Code []byte
+	// Addr is the virtual address Code is mapped at.
+	Addr uint64
+	// Arch is the architecture of Code. When empty, the architecture set
+	// with WithArch applies.
+	Arch Arch
+}
+
+// DetectProloguesFromRegions runs DetectPrologues on each region of a memory
+// map and returns the prologues of all of them, ordered as by
+// DetectPrologues. Regions may be given in any order and may differ in
+// architecture, but must not overlap. opts are those of DetectPrologues,
+// plus WithArch for the regions without an architecture; one budget is
+// shared across the regions.
+func DetectProloguesFromRegions(regions []CodeRegion, opts ...Option) ([]Prologue, error) {
+	o := newOptions(opts...)
+	sections := make([]codeSection, 0, len(regions))
+	for _, r := range regions {
+		arch := cmp.Or(r.Arch, o.arch)
+		if arch == "" {
+			return nil, fmt.Errorf("region at %#x: architecture unknown, set it in the region or with WithArch", r.Addr)
+		}
+		if uint64(len(r.Code)) > ^r.Addr {
+			return nil, fmt.Errorf("region at %#x: %d bytes overflow the address space", r.Addr, len(r.Code))
+		}
+		sections = append(sections, codeSection{code: r.Code, addr: r.Addr, arch: arch})
+	}
+	slices.SortStableFunc(sections, func(a, b codeSection) int { return cmp.Compare(a.addr, b.addr) })
+	for i := 1; i < len(sections); i++ {
+		prev, cur := sections[i-1], sections[i]
+		if prev.addr+uint64(len(prev.code)) > cur.addr {
+			return nil, fmt.Errorf("region at %#x overlaps region at %#x", cur.addr, prev.addr)
+		}
+	}
+	return o.detectSectionPrologues(sections, "")
+}
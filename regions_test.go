@@ -0,0 +1,76 @@
+package resurgo_test
+
+import (
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestDetectProloguesFromRegions(t *testing.T) {
+	// push rbp; mov rbp, rsp; pop rbp; ret
+	amd64 := []byte{0x55, 0x48, 0x89, 0xe5, 0x5d, 0xc3}
+	// stp x29, x30, [sp, #-16]!; mov x29, sp; ldp x29, x30, [sp], #16; ret
+	arm64 := arm64Insn(0xa9bf7bfd, 0x910003fd, 0xa8c17bfd, 0xd65f03c0)
+
+	t.Run("mixed", func(t *testing.T) {
+		regions := []resurgo.CodeRegion{
+			{Code: amd64, Addr: 0x8000},
+			{Code: arm64, Addr: 0x1000, Arch: resurgo.ArchARM64},
+			{Code: amd64, Addr: 0x4000},
+		}
+		prologues, err := resurgo.DetectProloguesFromRegions(regions, resurgo.WithArch(resurgo.ArchAMD64))
+		if err != nil {
+			t.Fatalf("DetectProloguesFromRegions: %v", err)
+		}
+		want := []struct {
+			addr uint64
+			typ  resurgo.PrologueType
+		}{
+			{0x1000, resurgo.PrologueSTPFramePair},
+			{0x4000, resurgo.PrologueClassic},
+			{0x8000, resurgo.PrologueClassic},
+		}
+		var got []resurgo.Prologue
+		for _, p := range prologues {
+			if len(got) == 0 || got[len(got)-1].Address != p.Address {
+				got = append(got, p)
+			}
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %+v, want prologues at %+v", prologues, want)
+		}
+		for i, w := range want {
+			if got[i].Address != w.addr || got[i].Type != w.typ {
+				t.Errorf("prologue %d: got %s at %#x, want %s at %#x", i, got[i].Type, got[i].Address, w.typ, w.addr)
+			}
+		}
+	})
+
+	errTests := []struct {
+		name    string
+		regions []resurgo.CodeRegion
+	}{
+		{
+			name:    "no architecture",
+			regions: []resurgo.CodeRegion{{Code: amd64, Addr: 0x1000}},
+		},
+		{
+			name: "overlap",
+			regions: []resurgo.CodeRegion{
+				{Code: amd64, Addr: 0x1000, Arch: resurgo.ArchAMD64},
+				{Code: amd64, Addr: 0x1004, Arch: resurgo.ArchAMD64},
+			},
+		},
+		{
+			name:    "address overflow",
+			regions: []resurgo.CodeRegion{{Code: amd64, Addr: ^uint64(0) - 2, Arch: resurgo.ArchAMD64}},
+		},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := resurgo.DetectProloguesFromRegions(tt.regions); err == nil {
+				t.Error("expected an error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package resurgo
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// PrologueInfo describes a prologue type for display and export.
+type PrologueInfo struct {
+	// Type is the prologue type described.
+	Type PrologueType `json:"type"`
+	// Description is a short human-readable label.
+	Description string `json:"description"`
+	// Arch is the architecture the pattern applies to.
+	Arch Arch `json:"arch"`
+	// Toolchains lists the toolchains that typically emit the pattern.
+	Toolchains []Toolchain `json:"toolchains,omitempty"`
+	// Confidence is the prior reliability of the pattern on its own, before
+	// other signals are taken into account.
+	Confidence Confidence `json:"confidence"`
+}
+
+var (
+	prologueRegistryMu sync.RWMutex
+	// prologueRegistry holds the known prologue types, strongest evidence
+	// first. Results with several prologues at one address follow this
+	// order; registered types rank after the built-in ones.
+	prologueRegistry = []PrologueInfo{
+		{PrologueGoStackCheck, "Go stack-bound check", ArchAMD64, []Toolchain{ToolchainGo}, ConfidenceHigh},
+		{PrologueStackRealign, "frame pointer setup with stack realignment", ArchAMD64, []Toolchain{ToolchainGCC, ToolchainClang}, ConfidenceHigh},
+		{PrologueRustProbestack, "stack probe call before frame allocation", ArchAMD64, []Toolchain{ToolchainRust}, ConfidenceHigh},
+		{PrologueFentry, "ftrace __fentry__ call at function entry", ArchAMD64, []Toolchain{ToolchainGCC, ToolchainClang}, ConfidenceHigh},
+		{PrologueClassic, "frame pointer setup", ArchAMD64, []Toolchain{ToolchainGCC, ToolchainClang, ToolchainGo, ToolchainRust}, ConfidenceHigh},
+		{PrologueEnter, "enter instruction", ArchAMD64, nil, ConfidenceMedium},
+		{PrologueHomeSpill, "register argument spill to home space", ArchAMD64, []Toolchain{ToolchainMSVC}, ConfidenceMedium},
+		{ProloguePushOnly, "callee-saved register push", ArchAMD64, []Toolchain{ToolchainGCC, ToolchainClang, ToolchainRust}, ConfidenceMedium},
+		{PrologueFmtThunk, "self-dereferencing tail-call thunk", ArchAMD64, []Toolchain{ToolchainRust}, ConfidenceLow},
+		{PrologueNoFramePointer, "stack allocation without frame pointer", ArchAMD64, []Toolchain{ToolchainGCC, ToolchainClang, ToolchainRust, ToolchainMSVC}, ConfidenceLow},
+		{PrologueLEABased, "stack allocation with lea", ArchAMD64, []Toolchain{ToolchainGCC, ToolchainClang}, ConfidenceLow},
+		{PrologueSTPFramePair, "frame record store and frame pointer setup", ArchARM64, []Toolchain{ToolchainGCC, ToolchainClang, ToolchainRust}, ConfidenceHigh},
+		{PrologueSTPCalleeSaved, "callee-saved register pair store", ArchARM64, []Toolchain{ToolchainGCC, ToolchainClang, ToolchainRust}, ConfidenceMedium},
+		{PrologueSTRLRPreIndex, "link register store", ArchARM64, []Toolchain{ToolchainGo}, ConfidenceMedium},
+		{PrologueSTPOnly, "frame record store without frame pointer", ArchARM64, []Toolchain{ToolchainGCC, ToolchainClang}, ConfidenceLow},
+		{PrologueSubSP, "stack allocation", ArchARM64, []Toolchain{ToolchainGCC, ToolchainClang, ToolchainGo, ToolchainRust}, ConfidenceLow},
+		{ProloguePushFPLR, "frame record push", ArchARM, []Toolchain{ToolchainGCC, ToolchainClang}, ConfidenceHigh},
+		{ProloguePushLR, "link register push with callee-saved registers", ArchARM, []Toolchain{ToolchainGCC, ToolchainClang, ToolchainRust}, ConfidenceMedium},
+		{PrologueAddiSPFrame, "stack allocation, return address save and frame pointer setup", ArchRISCV64, []Toolchain{ToolchainGCC, ToolchainClang}, ConfidenceHigh},
+		{PrologueAddiSPSaveRA, "stack allocation and return address save", ArchRISCV64, []Toolchain{ToolchainGCC, ToolchainClang, ToolchainRust}, ConfidenceMedium},
+		{PrologueAddiSP, "stack allocation", ArchRISCV64, []Toolchain{ToolchainGCC, ToolchainClang, ToolchainRust}, ConfidenceLow},
+		{PrologueMFLRStdLR, "link register save", ArchPPC64LE, []Toolchain{ToolchainGCC, ToolchainClang}, ConfidenceHigh},
+		{PrologueGlobalEntry, "TOC pointer setup at the global entry point", ArchPPC64LE, []Toolchain{ToolchainGCC, ToolchainClang}, ConfidenceHigh},
+		{PrologueStduSP, "stack allocation with back chain store", ArchPPC64LE, []Toolchain{ToolchainGCC, ToolchainClang}, ConfidenceLow},
+		{PrologueEntry, "register window rotation and stack allocation", ArchXtensa, []Toolchain{ToolchainGCC, ToolchainClang}, ConfidenceHigh},
+	}
+)
+
+// RegisterPrologueType adds info for a user-defined prologue type, making it
+// available through LookupPrologueType and PrologueTypes. It returns an
+// error if info.Type is empty or already registered.
+func RegisterPrologueType(info PrologueInfo) error {
+	if info.Type == "" {
+		return fmt.Errorf("empty prologue type")
+	}
+	prologueRegistryMu.Lock()
+	defer prologueRegistryMu.Unlock()
+	if slices.ContainsFunc(prologueRegistry, func(i PrologueInfo) bool { return i.Type == info.Type }) {
+		return fmt.Errorf("prologue type %s already registered", info.Type)
+	}
+	info.Toolchains = slices.Clone(info.Toolchains)
+	prologueRegistry = append(prologueRegistry, info)
+	return nil
+}
+
+// LookupPrologueType returns the metadata of typ.
+func LookupPrologueType(typ PrologueType) (PrologueInfo, bool) {
+	prologueRegistryMu.RLock()
+	defer prologueRegistryMu.RUnlock()
+	for _, info := range prologueRegistry {
+		if info.Type == typ {
+			info.Toolchains = slices.Clone(info.Toolchains)
+			return info, true
+		}
+	}
+	return PrologueInfo{}, false
+}
+
+// PrologueTypes returns the metadata of every known prologue type, built-in
+// types first, strongest evidence first.
+func PrologueTypes() []PrologueInfo {
+	prologueRegistryMu.RLock()
+	defer prologueRegistryMu.RUnlock()
+	infos := make([]PrologueInfo, len(prologueRegistry))
+	for i, info := range prologueRegistry {
+		info.Toolchains = slices.Clone(info.Toolchains)
+		infos[i] = info
+	}
+	return infos
+}
+
+// prologueRanks returns the position of every known prologue type in the
+// registry.
+func prologueRanks() map[PrologueType]int {
+	prologueRegistryMu.RLock()
+	defer prologueRegistryMu.RUnlock()
+	ranks := make(map[PrologueType]int, len(prologueRegistry))
+	for i, info := range prologueRegistry {
+		ranks[info.Type] = i
+	}
+	return ranks
+}
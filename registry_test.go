@@ -0,0 +1,75 @@
+package resurgo_test
+
+import (
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestLookupPrologueType(t *testing.T) {
+	builtin := []resurgo.PrologueType{
+		resurgo.PrologueClassic,
+		resurgo.PrologueNoFramePointer,
+		resurgo.ProloguePushOnly,
+		resurgo.PrologueLEABased,
+		resurgo.PrologueStackRealign,
+		resurgo.PrologueEnter,
+		resurgo.PrologueSTPFramePair,
+		resurgo.PrologueSTRLRPreIndex,
+		resurgo.PrologueSubSP,
+		resurgo.PrologueSTPOnly,
+		resurgo.PrologueSTPCalleeSaved,
+		resurgo.PrologueGoStackCheck,
+		resurgo.PrologueHomeSpill,
+		resurgo.PrologueRustProbestack,
+		resurgo.PrologueFmtThunk,
+		resurgo.ProloguePushFPLR,
+		resurgo.ProloguePushLR,
+		resurgo.PrologueAddiSPFrame,
+		resurgo.PrologueAddiSPSaveRA,
+		resurgo.PrologueAddiSP,
+		resurgo.PrologueMFLRStdLR,
+		resurgo.PrologueStduSP,
+		resurgo.PrologueGlobalEntry,
+		resurgo.PrologueEntry,
+		resurgo.PrologueFentry,
+	}
+	for _, typ := range builtin {
+		info, ok := resurgo.LookupPrologueType(typ)
+		if !ok {
+			t.Errorf("%s: not registered", typ)
+			continue
+		}
+		if info.Type != typ || info.Description == "" || info.Arch == "" || info.Confidence == "" {
+			t.Errorf("%s: incomplete metadata %+v", typ, info)
+		}
+	}
+	if _, ok := resurgo.LookupPrologueType("unknown"); ok {
+		t.Error("expected unknown type not to be registered")
+	}
+}
+
+func TestRegisterPrologueType(t *testing.T) {
+	custom := resurgo.PrologueInfo{
+		Type:        "test-custom",
+		Description: "custom pattern",
+		Arch:        resurgo.ArchAMD64,
+		Confidence:  resurgo.ConfidenceLow,
+	}
+	if err := resurgo.RegisterPrologueType(custom); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, ok := resurgo.LookupPrologueType(custom.Type); !ok || info.Description != custom.Description {
+		t.Errorf("expected %+v, got %+v", custom, info)
+	}
+	infos := resurgo.PrologueTypes()
+	if last := infos[len(infos)-1]; last.Type != custom.Type {
+		t.Errorf("expected registered type to rank last, got %s", last.Type)
+	}
+
+	for _, info := range []resurgo.PrologueInfo{custom, {Type: resurgo.PrologueClassic}, {}} {
+		if err := resurgo.RegisterPrologueType(info); err == nil {
+			t.Errorf("%q: expected error, got nil", info.Type)
+		}
+	}
+}
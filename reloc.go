@@ -0,0 +1,113 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"fmt"
+)
+
+// dataWord is a pointer-sized word of a data section as the dynamic loader
+// leaves it.
+type dataWord struct {
+	// value is the word once relative relocations are applied.
+	value uint64
+	// symbolic reports that the word is relocated against a symbol, whose
+	// address is only known at run time.
+	symbolic bool
+}
+
+// dynamicRelocs returns the words written by the RELA dynamic relocations of
+// f, keyed by address. Relative relocations carry their value in the addend
+// and are resolved; absolute relocations against a symbol are marked
+// symbolic. Other relocation types are ignored. Only 64-bit files are
+// supported; nil is returned for others.
+func dynamicRelocs(f *elf.File) (map[uint64]dataWord, error) {
+	if f.Class != elf.ELFCLASS64 {
+		return nil, nil
+	}
+	var relative, absolute uint32
+	switch f.Machine {
+	case elf.EM_X86_64:
+		relative, absolute = uint32(elf.R_X86_64_RELATIVE), uint32(elf.R_X86_64_64)
+	case elf.EM_AARCH64:
+		relative, absolute = uint32(elf.R_AARCH64_RELATIVE), uint32(elf.R_AARCH64_ABS64)
+	default:
+		return nil, nil
+	}
+
+	words := make(map[uint64]dataWord)
+	err := forEachRela(f, func(addr, info, addend uint64) {
+		switch elf.R_TYPE64(info) {
+		case relative:
+			words[addr] = dataWord{value: addend}
+		case absolute:
+			words[addr] = dataWord{symbolic: elf.R_SYM64(info) != 0, value: addend}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// forEachRela calls fn with the offset, info and addend of every relocation
+// in the allocated RELA sections of the 64-bit file f, i.e. the relocations
+// processed at load time.
+func forEachRela(f *elf.File, fn func(addr, info, addend uint64)) error {
+	const relaSize = 24 // Elf64_Rela: r_offset, r_info, r_addend
+	for _, sec := range f.Sections {
+		if sec.Type != elf.SHT_RELA || sec.Flags&elf.SHF_ALLOC == 0 {
+			continue
+		}
+		data, err := sectionData(sec)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", sec.Name, err)
+		}
+		for off := 0; off+relaSize <= len(data); off += relaSize {
+			fn(f.ByteOrder.Uint64(data[off:]), f.ByteOrder.Uint64(data[off+8:]), f.ByteOrder.Uint64(data[off+16:]))
+		}
+	}
+	return nil
+}
+
+// sectionWords returns the pointer-sized words of sec with the dynamic
+// relocations in relocs applied. Linkers may leave the targets of RELA
+// relocations zeroed, so the relocations take precedence over the section
+// contents. A trailing partial word is ignored.
+func sectionWords(f *elf.File, sec *elf.Section, relocs map[uint64]dataWord) ([]dataWord, error) {
+	data, err := sectionData(sec)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", sec.Name, err)
+	}
+	const ptrSize = 8
+	words := make([]dataWord, len(data)/ptrSize)
+	for i := range words {
+		if w, ok := relocs[sec.Addr+uint64(i*ptrSize)]; ok {
+			words[i] = w
+			continue
+		}
+		words[i] = dataWord{value: f.ByteOrder.Uint64(data[i*ptrSize:])}
+	}
+	return words, nil
+}
+
+// execRanges returns the [lo, hi) address ranges of the executable sections
+// of f.
+func execRanges(f *elf.File) [][2]uint64 {
+	var ranges [][2]uint64
+	for _, sec := range f.Sections {
+		if sec.Flags&elf.SHF_EXECINSTR != 0 && sec.Flags&elf.SHF_ALLOC != 0 {
+			ranges = append(ranges, [2]uint64{sec.Addr, sec.Addr + sec.Size})
+		}
+	}
+	return ranges
+}
+
+// inRanges reports whether addr falls within any of the [lo, hi) ranges.
+func inRanges(addr uint64, ranges [][2]uint64) bool {
+	for _, r := range ranges {
+		if addr >= r[0] && addr < r[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package resurgotest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/maxgio92/resurgo"
+)
+
+// Pattern is a prologue encoding injected at function entries by Generate.
+type Pattern struct {
+	// Type is the prologue type the encoding must be detected as.
+	Type resurgo.PrologueType
+	// Code is the encoded prologue, with AArch64 words in little-endian
+	// order.
+	Code []byte
+}
+
+// GenerateConfig configures Generate. Zero fields take their defaults.
+type GenerateConfig struct {
+	// Arch is the architecture of the generated code.
+	Arch resurgo.Arch
+	// Functions is the number of functions generated (default 16).
+	Functions int
+	// MaxBodyInstructions bounds the number of filler instructions between
+	// the prologue and the return of each function (default 8).
+	MaxBodyInstructions int
+	// DataProbability is the probability that a block of random data
+	// follows a function.
+	DataProbability float64
+	// MaxDataSize bounds the size in bytes of each data block (default 64).
+	MaxDataSize int
+	// Patterns are the prologues injected at function entries, picked at
+	// random (default DefaultPatterns(Arch)).
+	Patterns []Pattern
+}
+
+// Span is a byte range of generated code.
+type Span struct {
+	Offset uint64
+	Size   uint64
+}
+
+// GeneratedFunction is a function entry injected by Generate.
+type GeneratedFunction struct {
+	// Offset is the position of the entry in the generated code.
+	Offset uint64
+	// Type is the prologue type injected at the entry.
+	Type resurgo.PrologueType
+}
+
+// GeneratedCode is a pseudo-random instruction stream with known function
+// entries and data blocks.
+type GeneratedCode struct {
+	Code      []byte
+	Functions []GeneratedFunction
+	Data      []Span
+}
+
+// arm64Words encodes AArch64 instruction words in little-endian order.
+func arm64Words(words ...uint32) []byte {
+	code := make([]byte, 0, 4*len(words))
+	for _, w := range words {
+		code = binary.LittleEndian.AppendUint32(code, w)
+	}
+	return code
+}
+
+// DefaultPatterns returns the built-in prologue patterns of arch that are
+// detected with the default options.
+func DefaultPatterns(arch resurgo.Arch) []Pattern {
+	switch arch {
+	case resurgo.ArchAMD64:
+		return []Pattern{
+			{resurgo.PrologueClassic, []byte{0x55, 0x48, 0x89, 0xe5}},                              // push rbp; mov rbp, rsp
+			{resurgo.PrologueClassic, []byte{0x55, 0x48, 0x89, 0xe5, 0x48, 0x83, 0xec, 0x20}},      // ...; sub rsp, 0x20
+			{resurgo.PrologueStackRealign, []byte{0x55, 0x48, 0x89, 0xe5, 0x48, 0x83, 0xe4, 0xe0}}, // ...; and rsp, -0x20
+			{resurgo.ProloguePushOnly, []byte{0x53}},                                               // push rbx
+			{resurgo.ProloguePushOnly, []byte{0x41, 0x54, 0x53}},                                   // push r12; push rbx
+			{resurgo.PrologueNoFramePointer, []byte{0x48, 0x83, 0xec, 0x28}},                       // sub rsp, 0x28
+			{resurgo.PrologueLEABased, []byte{0x48, 0x8d, 0x64, 0x24, 0xe0}},                       // lea rsp, [rsp-0x20]
+			{resurgo.PrologueEnter, []byte{0xc8, 0x20, 0x00, 0x00}},                                // enter 0x20, 0
+		}
+	case resurgo.ArchARM64:
+		return []Pattern{
+			{resurgo.PrologueSTPFramePair, arm64Words(0xa9bf7bfd, 0x910003fd)}, // stp x29, x30, [sp, #-16]!; mov x29, sp
+			{resurgo.PrologueSTPOnly, arm64Words(0xa9bf7bfd)},                  // stp x29, x30, [sp, #-16]!
+			{resurgo.PrologueSTPCalleeSaved, arm64Words(0xa9be53f3)},           // stp x19, x20, [sp, #-32]!
+			{resurgo.PrologueSTRLRPreIndex, arm64Words(0xf81f0ffe)},            // str x30, [sp, #-16]!
+			{resurgo.PrologueSubSP, arm64Words(0xd10083ff)},                    // sub sp, sp, #0x20
+		}
+	}
+	return nil
+}
+
+// fillerAMD64 are x86-64 body instructions that take part in no prologue
+// pattern.
+var fillerAMD64 = [][]byte{
+	{0x89, 0xc8},             // mov eax, ecx
+	{0x48, 0x01, 0xd8},       // add rax, rbx
+	{0x31, 0xd2},             // xor edx, edx
+	{0x48, 0x8d, 0x04, 0x37}, // lea rax, [rdi+rsi]
+	{0x0f, 0xaf, 0xc1},       // imul eax, ecx
+	{0x85, 0xc0},             // test eax, eax
+	{0x48, 0x8b, 0x47, 0x08}, // mov rax, [rdi+8]
+}
+
+// fillerARM64 are AArch64 body instructions that take part in no prologue
+// pattern.
+var fillerARM64 = []uint32{
+	0x8b010000, // add x0, x0, x1
+	0xaa0303e2, // mov x2, x3
+	0xf9400420, // ldr x0, [x1, #8]
+	0xeb01001f, // cmp x0, x1
+	0x4a000000, // eor w0, w0, w0
+	0x9b017c00, // mul x0, x0, x1
+}
+
+const (
+	// functionAlignmentAMD64 is the entry alignment of generated x86-64
+	// functions.
+	functionAlignmentAMD64 = 16
+	// trapRunAMD64 is the minimum number of int3 bytes after a data block:
+	// longer than any x86-64 instruction, so that the decoder resynchronizes
+	// before the next entry whatever the data.
+	trapRunAMD64 = 16
+)
+
+// Generate produces pseudo-random code for fuzzing and property testing:
+// functions made of a prologue picked from cfg.Patterns, filler
+// instructions and a return, optionally followed by random data. On x86-64
+// functions are aligned with int3 padding; on AArch64 they are packed, and a
+// brk separates data from the next function. Every entry is therefore at a
+// function boundary under the default options, and a detector is expected to
+// report each injected prologue at its offset. Data blocks may decode as
+// spurious prologues; they are reported in Data so that callers can ignore
+// them. The same rng state and cfg yield the same code.
+func Generate(rng *rand.Rand, cfg GenerateConfig) (GeneratedCode, error) {
+	if cfg.Functions == 0 {
+		cfg.Functions = 16
+	}
+	if cfg.MaxBodyInstructions == 0 {
+		cfg.MaxBodyInstructions = 8
+	}
+	if cfg.MaxDataSize == 0 {
+		cfg.MaxDataSize = 64
+	}
+	if cfg.Patterns == nil {
+		cfg.Patterns = DefaultPatterns(cfg.Arch)
+	}
+	if cfg.Arch != resurgo.ArchAMD64 && cfg.Arch != resurgo.ArchARM64 {
+		return GeneratedCode{}, fmt.Errorf("unsupported architecture: %s", cfg.Arch)
+	}
+	if len(cfg.Patterns) == 0 {
+		return GeneratedCode{}, fmt.Errorf("no patterns")
+	}
+
+	var g GeneratedCode
+	for range cfg.Functions {
+		p := cfg.Patterns[rng.IntN(len(cfg.Patterns))]
+		g.Functions = append(g.Functions, GeneratedFunction{Offset: uint64(len(g.Code)), Type: p.Type})
+		g.Code = append(g.Code, p.Code...)
+
+		for range rng.IntN(cfg.MaxBodyInstructions + 1) {
+			if cfg.Arch == resurgo.ArchAMD64 {
+				g.Code = append(g.Code, fillerAMD64[rng.IntN(len(fillerAMD64))]...)
+			} else {
+				g.Code = binary.LittleEndian.AppendUint32(g.Code, fillerARM64[rng.IntN(len(fillerARM64))])
+			}
+		}
+
+		data := rng.Float64() < cfg.DataProbability
+		if cfg.Arch == resurgo.ArchAMD64 {
+			g.Code = append(g.Code, 0xc3) // ret
+			traps := 0
+			if data {
+				g.addData(rng, 1+rng.IntN(cfg.MaxDataSize))
+				traps = trapRunAMD64
+			}
+			for traps > 0 || len(g.Code)%functionAlignmentAMD64 != 0 {
+				g.Code = append(g.Code, 0xcc) // int3
+				traps--
+			}
+		} else {
+			g.Code = binary.LittleEndian.AppendUint32(g.Code, 0xd65f03c0) // ret
+			if data {
+				g.addData(rng, 4*(1+rng.IntN(max(cfg.MaxDataSize/4, 1))))
+				g.Code = binary.LittleEndian.AppendUint32(g.Code, 0xd4200000) // brk #0
+			}
+		}
+	}
+	return g, nil
+}
+
+// addData appends n random bytes and records them as a data span.
+func (g *GeneratedCode) addData(rng *rand.Rand, n int) {
+	g.Data = append(g.Data, Span{Offset: uint64(len(g.Code)), Size: uint64(n)})
+	g.Code = slices.Grow(g.Code, n)
+	for range n {
+		g.Code = append(g.Code, byte(rng.Uint32()))
+	}
+}
@@ -0,0 +1,66 @@
+package resurgotest_test
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+	"github.com/maxgio92/resurgo/resurgotest"
+)
+
+// FuzzDetectPrologues checks that every prologue injected by Generate is
+// detected at its offset with its type.
+func FuzzDetectPrologues(f *testing.F) {
+	for seed := range uint64(16) {
+		f.Add(seed, 0.0)
+		f.Add(seed, 0.5)
+	}
+	f.Fuzz(func(t *testing.T, seed uint64, dataProbability float64) {
+		for _, arch := range []resurgo.Arch{resurgo.ArchAMD64, resurgo.ArchARM64} {
+			gen, err := resurgotest.Generate(rand.New(rand.NewPCG(seed, 0)), resurgotest.GenerateConfig{
+				Arch:            arch,
+				DataProbability: dataProbability,
+			})
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			prologues, err := resurgo.DetectPrologues(gen.Code, 0, arch)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", arch, err)
+			}
+			found := make(map[uint64][]resurgo.PrologueType)
+			for _, p := range prologues {
+				found[p.Address] = append(found[p.Address], p.Type)
+			}
+			for _, fn := range gen.Functions {
+				if !slices.Contains(found[fn.Offset], fn.Type) {
+					t.Errorf("%s: expected %s at 0x%x, got %v", arch, fn.Type, fn.Offset, found[fn.Offset])
+				}
+			}
+		}
+	})
+}
+
+func TestGenerate(t *testing.T) {
+	cfg := resurgotest.GenerateConfig{Arch: resurgo.ArchAMD64, Functions: 32, DataProbability: 1}
+	a, err := resurgotest.Generate(rand.New(rand.NewPCG(1, 2)), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := resurgotest.Generate(rand.New(rand.NewPCG(1, 2)), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Error("expected the same seed to generate the same code")
+	}
+	if len(a.Functions) != cfg.Functions || len(a.Data) != cfg.Functions {
+		t.Errorf("expected %d functions and data blocks, got %d and %d", cfg.Functions, len(a.Functions), len(a.Data))
+	}
+
+	if _, err := resurgotest.Generate(rand.New(rand.NewPCG(1, 2)), resurgotest.GenerateConfig{Arch: "mips"}); err == nil {
+		t.Error("expected error for unsupported architecture, got nil")
+	}
+}
@@ -0,0 +1,434 @@
+// Package resurgotest provides utilities for acceptance testing of function
+// detection: compiling and stripping fixtures, reading ground truth from
+// symbol tables, scoring detection results, expectation tables and golden
+// file comparisons. It is used by resurgo's own end-to-end tests and lets
+// projects embedding resurgo run the same checks against their toolchains
+// and corpora.
+package resurgotest
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+// UpdateGoldenEnv is the environment variable that makes Golden rewrite the
+// golden files instead of comparing against them when set to a non-empty
+// value.
+const UpdateGoldenEnv = "RESURGOTEST_UPDATE"
+
+// Stats holds precision/recall metrics for a single detection run.
+type Stats struct {
+	// Total is the number of functions expected.
+	Total int
+	// TruePositives is the number of expected functions found.
+	TruePositives int
+	// FalsePositives is the number of candidates whose address does not
+	// match any function.
+	FalsePositives int
+	// Missed lists the expected functions not found.
+	Missed []string
+}
+
+// TPRate returns the percentage of expected functions found.
+func (s Stats) TPRate() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.TruePositives) / float64(s.Total) * 100
+}
+
+// FPMultiplier returns the ratio of false positives to the total number of
+// real functions (ground truth), regardless of how many were detected.
+// This measures noise relative to the true function population, not just the
+// subset the detector happened to find.
+// Returns +Inf when there are false positives but no real functions at all.
+func (s Stats) FPMultiplier() float64 {
+	if s.Total == 0 {
+		if s.FalsePositives > 0 {
+			return math.Inf(1)
+		}
+		return 0
+	}
+	return float64(s.FalsePositives) / float64(s.Total)
+}
+
+// StatsRow is a labeled Stats entry for LogStatsTable.
+type StatsRow struct {
+	Label string
+	Stats Stats
+}
+
+// LogStatsTable logs a summary table with one row per entry in rows.
+func LogStatsTable(t testing.TB, rows ...StatsRow) {
+	t.Helper()
+	t.Logf("%-22s  %6s  %5s  %7s  %7s  %4s  %8s",
+		"", "total", "tp", "recall", "missed", "fp", "fp_mult")
+	for _, r := range rows {
+		t.Logf("%-22s  %6d  %5d  %6.0f%%  %7d  %4d  %7.2fx",
+			r.Label,
+			r.Stats.Total, r.Stats.TruePositives, r.Stats.TPRate(),
+			r.Stats.Total-r.Stats.TruePositives,
+			r.Stats.FalsePositives, r.Stats.FPMultiplier())
+	}
+}
+
+// CompileC compiles src with compiler and cflags, writing the output to out.
+// Skips the test if compiler is not found in PATH.
+func CompileC(t testing.TB, compiler string, cflags []string, src, out string) {
+	t.Helper()
+	if _, err := exec.LookPath(compiler); err != nil {
+		t.Skipf("%s not found in PATH, skipping", compiler)
+	}
+	args := append(append([]string{}, cflags...), "-o", out, src)
+	cmd := exec.Command(compiler, args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("compile failed: %v\n%s", err, output)
+	}
+}
+
+// CompileGo builds the Go program src with the go command, writing the
+// output to out. env is appended to the environment, e.g. "GOARCH=arm64".
+// Skips the test if go is not found in PATH.
+func CompileGo(t testing.TB, src, out string, env ...string) {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found in PATH, skipping")
+	}
+	cmd := exec.Command("go", "build", "-o", out, src)
+	cmd.Env = append(os.Environ(), env...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, output)
+	}
+}
+
+// Strip strips the symbol table from src using the given strip tool,
+// writing the result to dst. Skips the test if the tool is not in PATH.
+func Strip(t testing.TB, stripTool, src, dst string) {
+	t.Helper()
+	if _, err := exec.LookPath(stripTool); err != nil {
+		t.Skipf("%s not found in PATH, skipping", stripTool)
+	}
+	cmd := exec.Command(stripTool, "--strip-all", "-o", dst, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("strip failed: %v\n%s", err, out)
+	}
+}
+
+// GroundTruth reads the ELF symbol table from binPath and returns a map of
+// name -> virtual address for each function in wantNames.
+func GroundTruth(t testing.TB, binPath string, wantNames []string) map[string]uint64 {
+	t.Helper()
+	f, err := elf.Open(binPath)
+	if err != nil {
+		t.Fatalf("elf.Open(%s): %v", binPath, err)
+	}
+	defer f.Close()
+
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("f.Symbols: %v", err)
+	}
+
+	result := make(map[string]uint64)
+	for _, sym := range syms {
+		if elf.ST_TYPE(sym.Info) != elf.STT_FUNC {
+			continue
+		}
+		if slices.Contains(wantNames, sym.Name) {
+			result[sym.Name] = sym.Value
+		}
+	}
+	return result
+}
+
+// WithoutCRT returns a symbol filter that excludes zero-size STT_FUNC
+// symbols from the ground-truth set. CRT stubs (deregister_tm_clones,
+// frame_dummy, call_weak_fn, _init, _fini, etc.) are all zero-size in the
+// debug file: they have no real body and no .eh_frame FDE entries, so they
+// cannot be recovered by CFI-based detection on stripped binaries. Detecting
+// them by name would require maintaining a fragile allowlist; size == 0 is a
+// structural property detectable directly from the ELF.
+func WithoutCRT() func(elf.Symbol) bool {
+	return func(s elf.Symbol) bool {
+		return s.Size > 0
+	}
+}
+
+// FunctionVAs returns the set of STT_FUNC virtual addresses in binPath,
+// keyed by VA and valued by the full elf.Symbol. Symbols with VA=0 (undefined
+// imports) are excluded. Optional filters further narrow the set: a symbol is
+// included only when all filters return true.
+func FunctionVAs(t testing.TB, binPath string, filters ...func(elf.Symbol) bool) map[uint64]elf.Symbol {
+	t.Helper()
+	f, err := elf.Open(binPath)
+	if err != nil {
+		t.Fatalf("elf.Open(%s): %v", binPath, err)
+	}
+	defer f.Close()
+
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("f.Symbols: %v", err)
+	}
+
+	result := make(map[uint64]elf.Symbol)
+	for _, sym := range syms {
+		if elf.ST_TYPE(sym.Info) != elf.STT_FUNC || sym.Value == 0 {
+			continue
+		}
+		include := true
+		for _, filter := range filters {
+			if !filter(sym) {
+				include = false
+				break
+			}
+		}
+		if include {
+			result[sym.Value] = sym
+		}
+	}
+	return result
+}
+
+// FindDebugFile locates the external debug file for binPath using the
+// GNU build-id convention: /usr/lib/debug/.build-id/XX/XXX...XX.debug.
+// Returns an error if .note.gnu.build-id is absent or the debug file is not
+// installed (e.g. the matching -dbgsym package is not present).
+func FindDebugFile(binPath string) (string, error) {
+	f, err := elf.Open(binPath)
+	if err != nil {
+		return "", fmt.Errorf("elf.Open: %w", err)
+	}
+	defer f.Close()
+
+	sect := f.Section(".note.gnu.build-id")
+	if sect == nil {
+		return "", fmt.Errorf(".note.gnu.build-id not found in %s", binPath)
+	}
+	data, err := sect.Data()
+	if err != nil {
+		return "", fmt.Errorf("read .note.gnu.build-id: %w", err)
+	}
+
+	// ELF note layout (LE): namesz uint32, descsz uint32, ntype uint32,
+	// name[namesz] padded to 4 bytes, desc[descsz] = build ID bytes.
+	if len(data) < 12 {
+		return "", fmt.Errorf("build-id note too short (%d bytes)", len(data))
+	}
+	namesz := int(binary.LittleEndian.Uint32(data[0:4]))
+	descsz := int(binary.LittleEndian.Uint32(data[4:8]))
+	offset := 12 + (namesz+3)&^3 // skip name, padded to 4-byte boundary
+	if offset+descsz > len(data) {
+		return "", fmt.Errorf("build-id note malformed: offset=%d descsz=%d len=%d",
+			offset, descsz, len(data))
+	}
+	buildID := data[offset : offset+descsz]
+	hex := fmt.Sprintf("%x", buildID)
+	dbgPath := fmt.Sprintf("/usr/lib/debug/.build-id/%s/%s.debug", hex[:2], hex[2:])
+	if _, err := os.Stat(dbgPath); err != nil {
+		return "", fmt.Errorf("debug file not installed: %s", dbgPath)
+	}
+	return dbgPath, nil
+}
+
+// IsStripped returns true when binPath has no symbol table (.symtab).
+func IsStripped(t testing.TB, binPath string) bool {
+	t.Helper()
+	f, err := elf.Open(binPath)
+	if err != nil {
+		t.Fatalf("elf.Open(%s): %v", binPath, err)
+	}
+	defer f.Close()
+	_, err = f.Symbols()
+	return err != nil
+}
+
+// Build describes how to produce a stripped C test binary.
+type Build struct {
+	// Compiler is the C compiler, e.g. "gcc" or "aarch64-linux-gnu-gcc".
+	Compiler string
+	// StripTool is the strip binary for the target architecture, e.g.
+	// "strip" or "aarch64-linux-gnu-strip".
+	StripTool string
+	// CFlags are passed to the compiler.
+	CFlags []string
+	// Source is the C source file.
+	Source string
+}
+
+// Result is the outcome of Measure.
+type Result struct {
+	// ByVA holds the detected candidates by address.
+	ByVA map[uint64]resurgo.FunctionCandidate
+	// Truth maps each expected function to its address.
+	Truth map[string]uint64
+	// Stats scores the candidates against the expected functions.
+	Stats Stats
+}
+
+// Measure compiles b, strips it, runs DetectFunctionsFromELF with opts on
+// the stripped binary, and scores the candidates. Recall is measured against
+// userFuncs; a candidate is a false positive only if it matches no STT_FUNC
+// symbol at all, so that detected CRT functions are not penalised.
+func Measure(t testing.TB, b Build, userFuncs []string, opts ...resurgo.Option) Result {
+	t.Helper()
+
+	dir := t.TempDir()
+	unstripped := filepath.Join(dir, "binary")
+	stripped := filepath.Join(dir, "binary-stripped")
+
+	CompileC(t, b.Compiler, b.CFlags, b.Source, unstripped)
+	Strip(t, b.StripTool, unstripped, stripped)
+
+	truth := GroundTruth(t, unstripped, userFuncs)
+	if len(truth) < len(userFuncs) {
+		missing := make([]string, 0)
+		for _, name := range userFuncs {
+			if _, ok := truth[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+		t.Fatalf("ground truth missing functions: %v", missing)
+	}
+
+	f, err := elf.Open(stripped)
+	if err != nil {
+		t.Fatalf("os.Open: %v", err)
+	}
+	defer f.Close()
+
+	candidates, err := resurgo.DetectFunctionsFromELF(f, opts...)
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromELF: %v", err)
+	}
+
+	byVA := make(map[uint64]resurgo.FunctionCandidate, len(candidates))
+	for _, c := range candidates {
+		byVA[c.Address] = c
+	}
+
+	allFuncs := FunctionVAs(t, unstripped)
+
+	stats := Stats{Total: len(userFuncs)}
+	for _, name := range userFuncs {
+		if _, ok := byVA[truth[name]]; ok {
+			stats.TruePositives++
+		} else {
+			stats.Missed = append(stats.Missed, name)
+		}
+	}
+	for va := range byVA {
+		if _, ok := allFuncs[va]; !ok {
+			stats.FalsePositives++
+		}
+	}
+
+	// Log per-function breakdown.
+	names := make([]string, 0, len(truth))
+	for name := range truth {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		va := truth[name]
+		if c, ok := byVA[va]; ok {
+			t.Logf("  %-12s VA=0x%x  %s  %s", name, va, c.DetectionType, c.Confidence)
+		} else {
+			t.Logf("  %-12s VA=0x%x  NOT DETECTED", name, va)
+		}
+	}
+
+	return Result{ByVA: byVA, Truth: truth, Stats: stats}
+}
+
+// Expectation is an acceptance check of one fixture build.
+type Expectation struct {
+	// Name labels the subtest.
+	Name string
+	// Build produces the fixture.
+	Build Build
+	// Options are passed to DetectFunctionsFromELF.
+	Options []resurgo.Option
+	// Functions are the functions that must be detected.
+	Functions []string
+	// HighConfidence are functions that must be detected with
+	// ConfidenceHigh.
+	HighConfidence []string
+	// MinTPRate is the lowest acceptable percentage of Functions found.
+	MinTPRate float64
+	// MaxFPMultiplier is the ratio of false positives to Functions that
+	// must not be reached. Zero disables the check.
+	MaxFPMultiplier float64
+}
+
+// Run runs each expectation as a subtest of t.
+func Run(t *testing.T, expectations []Expectation) {
+	t.Helper()
+	for _, e := range expectations {
+		t.Run(e.Name, func(t *testing.T) {
+			res := Measure(t, e.Build, e.Functions, e.Options...)
+
+			for _, name := range e.HighConfidence {
+				va := res.Truth[name]
+				if c, ok := res.ByVA[va]; !ok {
+					t.Errorf("%s(0x%x): not detected", name, va)
+				} else if c.Confidence != resurgo.ConfidenceHigh {
+					t.Errorf("%s(0x%x): confidence=%s, want high", name, va, c.Confidence)
+				}
+			}
+			if res.Stats.TPRate() < e.MinTPRate {
+				t.Errorf("true positive rate %.0f%% (%d/%d): expected %.0f%%; missed: %v",
+					res.Stats.TPRate(), res.Stats.TruePositives, res.Stats.Total,
+					e.MinTPRate, res.Stats.Missed)
+			}
+			if e.MaxFPMultiplier > 0 && res.Stats.FPMultiplier() >= e.MaxFPMultiplier {
+				t.Errorf("false positive multiplier %.2fx >= %.2fx: detector is too noisy",
+					res.Stats.FPMultiplier(), e.MaxFPMultiplier)
+			}
+
+			LogStatsTable(t, StatsRow{Label: "result", Stats: res.Stats})
+		})
+	}
+}
+
+// Golden compares got, encoded as indented JSON, with the contents of the
+// golden file at path. When the UpdateGoldenEnv environment variable is set
+// the file is written instead.
+func Golden(t testing.TB, path string, got any) {
+	t.Helper()
+	data, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to encode result: %v", err)
+	}
+	data = append(data, '\n')
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (set %s=1 to create it): %v", UpdateGoldenEnv, err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("result differs from golden file %s (set %s=1 to update):\n got: %s\nwant: %s",
+			path, UpdateGoldenEnv, data, want)
+	}
+}
@@ -0,0 +1,60 @@
+package resurgotest_test
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+	"github.com/maxgio92/resurgo/resurgotest"
+)
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		stats      resurgotest.Stats
+		wantTPRate float64
+		wantFPMult float64
+	}{{
+		name:       "partial",
+		stats:      resurgotest.Stats{Total: 4, TruePositives: 3, FalsePositives: 2},
+		wantTPRate: 75,
+		wantFPMult: 0.5,
+	}, {
+		name:       "empty",
+		stats:      resurgotest.Stats{},
+		wantTPRate: 0,
+		wantFPMult: 0,
+	}, {
+		name:       "no-ground-truth",
+		stats:      resurgotest.Stats{FalsePositives: 1},
+		wantTPRate: 0,
+		wantFPMult: math.Inf(1),
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stats.TPRate(); got != tt.wantTPRate {
+				t.Errorf("TPRate: expected %v, got %v", tt.wantTPRate, got)
+			}
+			if got := tt.stats.FPMultiplier(); got != tt.wantFPMult {
+				t.Errorf("FPMultiplier: expected %v, got %v", tt.wantFPMult, got)
+			}
+		})
+	}
+}
+
+func TestGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "candidates.golden")
+	got := []resurgo.FunctionCandidate{{
+		Address:       0x1000,
+		DetectionType: resurgo.DetectionCFI,
+		Confidence:    resurgo.ConfidenceHigh,
+	}}
+
+	t.Setenv(resurgotest.UpdateGoldenEnv, "1")
+	resurgotest.Golden(t, path, got)
+
+	t.Setenv(resurgotest.UpdateGoldenEnv, "")
+	resurgotest.Golden(t, path, got)
+}
@@ -0,0 +1,316 @@
+package resurgo
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/arch/riscv64/riscv64asm"
+)
+
+// riscvUnimp is the canonical unimp instruction (csrrw x0, cycle, x0), which
+// compilers emit as a trap after calls to noreturn functions.
+const riscvUnimp = uint32(0xc0001073)
+
+// riscvRegNames maps the integer registers x0-x31 to their ABI names.
+var riscvRegNames = [32]string{
+	"zero", "ra", "sp", "gp", "tp", "t0", "t1", "t2",
+	"s0", "s1", "a0", "a1", "a2", "a3", "a4", "a5",
+	"a6", "a7", "s2", "s3", "s4", "s5", "s6", "s7",
+	"s8", "s9", "s10", "s11", "t3", "t4", "t5", "t6",
+}
+
+// riscvMinInsnLen is the length of a compressed (RVC) RISC-V instruction, the
+// alignment of every instruction when the C extension is in use.
+const riscvMinInsnLen = 2
+
+// decodeRISCV64 decodes the instruction at code[offset] and returns its
+// length: 4 bytes for base instructions, 2 for compressed ones. The length is
+// read from the low bits of the first byte, so it is valid even when the
+// instruction cannot be decoded, keeping a linear sweep aligned. Compressed
+// instructions are decoded to their base equivalents, e.g. c.sdsp ra, 8(sp)
+// to sd ra, 8(sp).
+func decodeRISCV64(code []byte, offset int) (riscv64asm.Inst, int, error) {
+	n := 4
+	if code[offset]&3 != 3 {
+		n = riscvMinInsnLen
+	}
+	inst, err := riscv64asm.Decode(code[offset:min(offset+n, len(code))])
+	if err == nil && inst.Len == riscvMinInsnLen && inst.Op == riscv64asm.JAL {
+		// riscv64asm misplaces offset bits 1 and 5 of c.j.
+		inst.Args[1] = riscv64asm.Simm{Imm: riscvCJOffset(inst.Enc), Decimal: true, Width: 12}
+	}
+	return inst, n, err
+}
+
+// riscvCJOffset returns the signed offset encoded by c.j (and c.jal), with
+// offset[11|4|9:8|10|6|7|3:1|5] in bits 12:2.
+func riscvCJOffset(enc uint32) int32 {
+	imm := (enc>>12&1)<<11 | (enc>>11&1)<<4 | (enc>>9&3)<<8 | (enc>>8&1)<<10 |
+		(enc>>7&1)<<6 | (enc>>6&1)<<7 | (enc>>3&7)<<1 | (enc>>2&1)<<5
+	return int32(imm<<20) >> 20
+}
+
+// isCalleeSavedRISCV64 reports whether reg is callee-saved under the RISC-V
+// calling convention: s0-s11.
+func isCalleeSavedRISCV64(reg riscv64asm.Reg) bool {
+	return reg == riscv64asm.X8 || reg == riscv64asm.X9 ||
+		(reg >= riscv64asm.X18 && reg <= riscv64asm.X27)
+}
+
+// riscvSPAdjust returns the immediate of addi sp, sp, imm.
+func riscvSPAdjust(inst riscv64asm.Inst) (int64, bool) {
+	if inst.Op != riscv64asm.ADDI || inst.Args[0] != riscv64asm.X2 || inst.Args[1] != riscv64asm.X2 {
+		return 0, false
+	}
+	imm, ok := inst.Args[2].(riscv64asm.Simm)
+	return int64(imm.Imm), ok
+}
+
+// riscvSPStore returns the register and offset stored by sd reg, off(sp).
+func riscvSPStore(inst riscv64asm.Inst) (riscv64asm.Reg, int32, bool) {
+	if inst.Op != riscv64asm.SD {
+		return 0, 0, false
+	}
+	reg, ok := inst.Args[0].(riscv64asm.Reg)
+	mem, isMem := inst.Args[1].(riscv64asm.RegOffset)
+	return reg, mem.Ofs.Imm, ok && isMem && mem.OfsReg == riscv64asm.X2
+}
+
+// riscvFramePointerSetup returns the immediate of addi s0, sp, imm, which
+// c.addi4spn also encodes.
+func riscvFramePointerSetup(inst riscv64asm.Inst) (int32, bool) {
+	if inst.Op != riscv64asm.ADDI || inst.Args[0] != riscv64asm.X8 || inst.Args[1] != riscv64asm.X2 {
+		return 0, false
+	}
+	imm, ok := inst.Args[2].(riscv64asm.Simm)
+	return imm.Imm, ok
+}
+
+// entrySeqRISCV64 describes the RISC-V function entry sequence that starts
+// with addi sp, sp, -N.
+type entrySeqRISCV64 struct {
+	insns []string
+	// savedRegs lists the registers stored to the new frame in store order.
+	savedRegs []string
+	// savesRA and setsFP report whether the sequence stores ra and sets the
+	// frame pointer up.
+	savesRA, setsFP bool
+	// end is the offset just past the last instruction of the sequence.
+	end int
+}
+
+// scanEntryRISCV64 collects the register saves and frame pointer setup that
+// follow the stack allocation of size bytes at code[offset]. Compilers
+// schedule other instructions among them, so unrelated instructions are
+// skipped; a control transfer or another stack pointer update ends the
+// sequence. Base and compressed forms may be mixed.
+func scanEntryRISCV64(code []byte, offset, size int, frame int64) entrySeqRISCV64 {
+	seq := entrySeqRISCV64{insns: []string{fmt.Sprintf("addi sp, sp, -%d", frame)}, end: offset + size}
+	for n, off := 0, offset+size; n < maxSavedRegScan && off+riscvMinInsnLen <= len(code); n++ {
+		inst, size, err := decodeRISCV64(code, off)
+		if err != nil {
+			break
+		}
+		off += size
+		if reg, ofs, ok := riscvSPStore(inst); ok && (reg == riscv64asm.X1 || isCalleeSavedRISCV64(reg)) {
+			name := riscvRegNames[reg-riscv64asm.X0]
+			seq.insns = append(seq.insns, fmt.Sprintf("sd %s, %d(sp)", name, ofs))
+			seq.savedRegs = append(seq.savedRegs, name)
+			seq.savesRA = seq.savesRA || reg == riscv64asm.X1
+			seq.end = off
+			continue
+		}
+		if imm, ok := riscvFramePointerSetup(inst); ok {
+			seq.insns = append(seq.insns, fmt.Sprintf("addi s0, sp, %d", imm))
+			seq.setsFP = true
+			seq.end = off
+			continue
+		}
+		if _, ok := riscvSPAdjust(inst); ok || classifyRISCV64(inst) != InsnClassOther || isBranchRISCV64(inst) {
+			break
+		}
+	}
+	return seq
+}
+
+func (o *options) detectProloguesRISCV64(code []byte, baseAddr uint64) ([]Prologue, error) {
+	var result []Prologue
+	hist := newInsnHistory(o.lookbehind())
+
+	for offset := 0; offset+riscvMinInsnLen <= len(code); {
+		if err := o.budget.step(offset); err != nil {
+			return nil, err
+		}
+		addr := baseAddr + uint64(offset)
+		inst, size, err := decodeRISCV64(code, offset)
+		if err != nil {
+			hist.push(addr, InsnClassNone)
+			offset += size
+			continue
+		}
+
+		// addi sp, sp, -N allocates the frame; the saves of ra and s0 and
+		// the frame pointer setup that follow it grade the evidence. With
+		// the C extension each step may be compressed, to c.addi16sp,
+		// c.sdsp and c.addi4spn:
+		//
+		//	addi sp, sp, -N
+		//	sd   ra, N-8(sp)
+		//	sd   s0, N-16(sp)
+		//	addi s0, sp, N
+		if imm, ok := riscvSPAdjust(inst); ok && imm < 0 {
+			seq := scanEntryRISCV64(code, offset, size, -imm)
+			p := Prologue{
+				Address:      addr,
+				Instructions: strings.Join(seq.insns, "; "),
+				FrameSize:    uint64(-imm),
+				Size:         uint64(seq.end - offset),
+				SavedRegs:    seq.savedRegs,
+			}
+			switch {
+			case seq.savesRA && seq.setsFP:
+				p.Type = PrologueAddiSPFrame
+			case seq.savesRA:
+				p.Type = PrologueAddiSPSaveRA
+			case o.atBoundary(hist, addr, PrologueAddiSP):
+				p.Type = PrologueAddiSP
+				p.Instructions = seq.insns[0]
+				p.Size = uint64(size)
+				p.SavedRegs = nil
+			}
+			if p.Type != "" {
+				result = append(result, p)
+			}
+		}
+
+		hist.push(addr, classifyRISCV64(inst))
+		offset += size
+	}
+
+	return result, nil
+}
+
+// classifyRISCV64 returns the boundary-context class of a RISC-V
+// instruction: ret (jalr zero, 0(ra)) is a return, any other jal or jalr
+// that does not link is a jump. Zeroed halfwords decode as c.unimp and count
+// as traps, like unimp.
+func classifyRISCV64(inst riscv64asm.Inst) InsnClass {
+	switch inst.Op {
+	case riscv64asm.JALR:
+		if inst.Args[0] != riscv64asm.X0 {
+			return InsnClassOther
+		}
+		if mem, ok := inst.Args[1].(riscv64asm.RegOffset); ok && mem.OfsReg == riscv64asm.X1 && mem.Ofs.Imm == 0 {
+			return InsnClassReturn
+		}
+		return InsnClassJump
+	case riscv64asm.JAL:
+		if inst.Args[0] == riscv64asm.X0 {
+			return InsnClassJump
+		}
+	case riscv64asm.EBREAK:
+		return InsnClassTrap
+	case riscv64asm.ADDI:
+		if inst.Args[0] == riscv64asm.X0 && inst.Args[1] == riscv64asm.X0 {
+			return InsnClassPadding
+		}
+	}
+	if inst.Enc == riscvUnimp || (inst.Len == riscvMinInsnLen && inst.Enc == 0) {
+		return InsnClassTrap
+	}
+	return InsnClassOther
+}
+
+// isBranchRISCV64 reports whether inst transfers control: a jump, call or
+// conditional branch.
+func isBranchRISCV64(inst riscv64asm.Inst) bool {
+	switch inst.Op {
+	case riscv64asm.JAL, riscv64asm.JALR,
+		riscv64asm.BEQ, riscv64asm.BNE, riscv64asm.BLT, riscv64asm.BGE, riscv64asm.BLTU, riscv64asm.BGEU:
+		return true
+	}
+	return false
+}
+
+// detectCallSitesRISCV64 returns the direct calls and jumps of RISC-V code:
+// jal and c.j, and the auipc; jalr pairs that reach beyond the ±1 MiB range
+// of jal. A jal or
+// jalr that links a register is a call, one that does not is a jump. An
+// auipc; jalr edge is reported at the address of the auipc.
+func detectCallSitesRISCV64(code []byte, baseAddr uint64, wrap bool, b *budget) ([]CallSiteEdge, error) {
+	var result []CallSiteEdge
+
+	// auipc holds the register and value of an auipc at the previous
+	// instruction, if any.
+	var auipc struct {
+		valid bool
+		reg   riscv64asm.Reg
+		addr  uint64
+		value int64
+	}
+
+	for offset := 0; offset+riscvMinInsnLen <= len(code); {
+		if err := b.step(offset); err != nil {
+			return nil, err
+		}
+		addr := baseAddr + uint64(offset)
+		inst, size, err := decodeRISCV64(code, offset)
+		offset += size
+		if err != nil {
+			auipc.valid = false
+			continue
+		}
+
+		switch inst.Op {
+		case riscv64asm.JAL:
+			rel, ok := inst.Args[1].(riscv64asm.Simm)
+			if !ok {
+				break
+			}
+			target, ok := relTarget(addr, int64(rel.Imm), wrap)
+			if !ok {
+				break
+			}
+			result = append(result, riscvEdge(addr, target, inst.Args[0] != riscv64asm.X0))
+		case riscv64asm.JALR:
+			mem, ok := inst.Args[1].(riscv64asm.RegOffset)
+			if !ok || !auipc.valid || mem.OfsReg != auipc.reg {
+				break
+			}
+			target, ok := relTarget(auipc.addr, auipc.value+int64(mem.Ofs.Imm), wrap)
+			if !ok {
+				break
+			}
+			result = append(result, riscvEdge(auipc.addr, target, inst.Args[0] != riscv64asm.X0))
+		}
+
+		auipc.valid = false
+		if inst.Op == riscv64asm.AUIPC {
+			if imm, ok := inst.Args[1].(riscv64asm.Uimm); ok {
+				auipc.valid = true
+				auipc.reg = inst.Args[0].(riscv64asm.Reg)
+				auipc.addr = addr
+				auipc.value = int64(int32(imm.Imm << 12))
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// riscvEdge returns the call site edge from source to target: a call when
+// the branch links a register, a jump otherwise.
+func riscvEdge(source, target uint64, link bool) CallSiteEdge {
+	edge := CallSiteEdge{
+		SourceAddr:  source,
+		TargetAddr:  target,
+		Type:        CallSiteCall,
+		AddressMode: AddressingModePCRelative,
+		Confidence:  ConfidenceHigh,
+	}
+	if !link {
+		edge.Type = CallSiteJump
+		edge.Confidence = ConfidenceMedium
+	}
+	return edge
+}
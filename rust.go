@@ -0,0 +1,117 @@
+package resurgo
+
+import (
+	"strconv"
+	"strings"
+)
+
+// rustEscapes maps the escape sequences of legacy Rust symbol mangling to
+// the characters they stand for.
+var rustEscapes = map[string]string{
+	"SP": "@",
+	"BP": "*",
+	"RF": "&",
+	"LT": "<",
+	"GT": ">",
+	"LP": "(",
+	"RP": ")",
+	"C":  ",",
+}
+
+// DemangleRust demangles a symbol in the legacy Rust mangling scheme,
+// _ZN<len><ident>...17h<hash>E, as found in the dynamic symbol table of
+// Rust libraries and in panic metadata, e.g.
+// _ZN4core3fmt5write17h0123456789abcdefE becomes core::fmt::write. The hash
+// segment is dropped. It reports false for names in any other scheme.
+func DemangleRust(name string) (string, bool) {
+	// macOS prefixes every symbol with an extra underscore.
+	rest, ok := strings.CutPrefix(name, "_ZN")
+	if !ok {
+		if rest, ok = strings.CutPrefix(name, "__ZN"); !ok {
+			return "", false
+		}
+	}
+
+	var idents []string
+	for !strings.HasPrefix(rest, "E") {
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		n, err := strconv.Atoi(rest[:i])
+		if err != nil || n == 0 || i+n > len(rest) {
+			return "", false
+		}
+		idents = append(idents, rest[i:i+n])
+		rest = rest[i+n:]
+	}
+	// Anything after the terminator must be a compiler-added suffix such
+	// as .llvm.<hash>.
+	if rest = rest[1:]; rest != "" && !strings.HasPrefix(rest, ".") {
+		return "", false
+	}
+	if len(idents) < 2 || !isRustHash(idents[len(idents)-1]) {
+		return "", false
+	}
+
+	idents = idents[:len(idents)-1]
+	for i, ident := range idents {
+		s, ok := unescapeRustIdent(ident)
+		if !ok {
+			return "", false
+		}
+		idents[i] = s
+	}
+	return strings.Join(idents, "::"), true
+}
+
+// isRustHash reports whether ident is the h<16 hex digits> disambiguator
+// that ends legacy Rust symbols.
+func isRustHash(ident string) bool {
+	if len(ident) != 17 || ident[0] != 'h' {
+		return false
+	}
+	_, err := strconv.ParseUint(ident[1:], 16, 64)
+	return err == nil
+}
+
+// unescapeRustIdent decodes the $..$ escapes and the .. path separator of a
+// legacy mangled identifier.
+func unescapeRustIdent(ident string) (string, bool) {
+	// Identifiers starting with an escape are prefixed by an underscore.
+	if strings.HasPrefix(ident, "_$") {
+		ident = ident[1:]
+	}
+	var b strings.Builder
+	for ident != "" {
+		switch {
+		case ident[0] == '$':
+			end := strings.IndexByte(ident[1:], '$')
+			if end < 0 {
+				return "", false
+			}
+			esc := ident[1 : end+1]
+			ident = ident[end+2:]
+			if s, ok := rustEscapes[esc]; ok {
+				b.WriteString(s)
+				continue
+			}
+			hex, ok := strings.CutPrefix(esc, "u")
+			if !ok {
+				return "", false
+			}
+			r, err := strconv.ParseUint(hex, 16, 32)
+			if err != nil {
+				return "", false
+			}
+			b.WriteRune(rune(r))
+		case strings.HasPrefix(ident, ".."):
+			b.WriteString("::")
+			ident = ident[2:]
+		default:
+			b.WriteByte(ident[0])
+			ident = ident[1:]
+		}
+	}
+	return b.String(), true
+}
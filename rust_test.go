@@ -0,0 +1,63 @@
+package resurgo_test
+
+import (
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestDemangleRust(t *testing.T) {
+	tests := []struct {
+		name   string
+		symbol string
+		want   string
+		ok     bool
+	}{{
+		name:   "path",
+		symbol: "_ZN4core3fmt5write17h0123456789abcdefE",
+		want:   "core::fmt::write",
+		ok:     true,
+	}, {
+		name:   "trait-impl",
+		symbol: "_ZN44_$LT$$RF$T$u20$as$u20$core..fmt..Display$GT$3fmt17h5f2a4b3c1d0e9f87E",
+		want:   "<&T as core::fmt::Display>::fmt",
+		ok:     true,
+	}, {
+		name:   "closure",
+		symbol: "_ZN3std2rt10lang_start28_$u7b$$u7b$closure$u7d$$u7d$17h7e1f2a3b4c5d6e7fE",
+		want:   "std::rt::lang_start::{{closure}}",
+		ok:     true,
+	}, {
+		name:   "llvm-suffix",
+		symbol: "_ZN4core9panicking5panic17h0123456789abcdefE.llvm.1234",
+		want:   "core::panicking::panic",
+		ok:     true,
+	}, {
+		name:   "macos",
+		symbol: "__ZN4core3fmt5write17h0123456789abcdefE",
+		want:   "core::fmt::write",
+		ok:     true,
+	}, {
+		// Itanium C++ symbols share the prefix but carry no hash.
+		name:   "cxx",
+		symbol: "_ZN3foo3barEv",
+	}, {
+		name:   "truncated",
+		symbol: "_ZN4core3fm",
+	}, {
+		name:   "v0",
+		symbol: "_RNvCs15kBYyAo9fc_7mycrate4main",
+	}, {
+		name:   "plain",
+		symbol: "main",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := resurgo.DemangleRust(tt.symbol)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("DemangleRust(%q) = %q, %v; want %q, %v", tt.symbol, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package resurgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SchemaVersion is the version of the serialized result format written by
+// Encode. Decode reads every version up to it.
+//
+// Version history:
+//
+//	0  unversioned: a bare JSON array of FunctionCandidate, or an
+//	   AnalysisResult object without schema_version; candidates found by
+//	   both prologue and call-site analysis had detection type "both".
+//	1  an AnalysisResult object with schema_version.
+const SchemaVersion = 1
+
+// detectionBoth is the version 0 name of DetectionPrologueCallSite.
+const detectionBoth DetectionType = "both"
+
+// migrations[v] upgrades a decoded result from schema version v to v+1.
+var migrations = []func(*AnalysisResult){
+	0: func(r *AnalysisResult) {
+		for i := range r.Functions {
+			if r.Functions[i].DetectionType == detectionBoth {
+				r.Functions[i].DetectionType = DetectionPrologueCallSite
+			}
+		}
+	},
+}
+
+// versionedResult is the serialized form of an AnalysisResult.
+type versionedResult struct {
+	SchemaVersion int `json:"schema_version"`
+	*AnalysisResult
+}
+
+// Encode writes result to w as JSON, tagged with SchemaVersion.
+func Encode(w io.Writer, result *AnalysisResult) error {
+	return json.NewEncoder(w).Encode(versionedResult{SchemaVersion: SchemaVersion, AnalysisResult: result})
+}
+
+// Decode reads a result written by Encode with any schema version up to
+// SchemaVersion, and upgrades it to the current one. Unversioned output,
+// such as a JSON array of the candidates returned by
+// DetectFunctionsFromELF, is read as version 0 from an ELF file. A result
+// written by a newer version of the package is an error.
+func Decode(r io.Reader) (*AnalysisResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read result: %w", err)
+	}
+
+	v := versionedResult{AnalysisResult: &AnalysisResult{}}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		v.Format = FormatELF
+		if err := json.Unmarshal(data, &v.Functions); err != nil {
+			return nil, fmt.Errorf("decode result: %w", err)
+		}
+	} else if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("decode result: %w", err)
+	}
+	if v.SchemaVersion < 0 || v.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version %d (supported up to %d)", v.SchemaVersion, SchemaVersion)
+	}
+	for version := v.SchemaVersion; version < SchemaVersion; version++ {
+		migrate(v.AnalysisResult, migrations[version])
+	}
+	return v.AnalysisResult, nil
+}
+
+// migrate applies m to r and its members.
+func migrate(r *AnalysisResult, m func(*AnalysisResult)) {
+	m(r)
+	for i := range r.Members {
+		migrate(&r.Members[i], m)
+	}
+}
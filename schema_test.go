@@ -0,0 +1,96 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestDecode(t *testing.T) {
+	current := &resurgo.AnalysisResult{
+		Format: resurgo.FormatArchive,
+		Members: []resurgo.AnalysisResult{{
+			Name:   "a.o",
+			Format: resurgo.FormatELF,
+			Arch:   resurgo.ArchAMD64,
+			Functions: []resurgo.FunctionCandidate{{
+				Address:       0x10,
+				DetectionType: resurgo.DetectionPrologueCallSite,
+				PrologueType:  resurgo.PrologueClassic,
+				CalledFrom:    []uint64{0x40},
+				Confidence:    resurgo.ConfidenceHigh,
+			}},
+		}},
+	}
+	var encoded bytes.Buffer
+	if err := resurgo.Encode(&encoded, current); err != nil {
+		t.Fatalf("resurgo.Encode: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    *resurgo.AnalysisResult
+		wantErr bool
+	}{
+		{
+			name:  "current version",
+			input: encoded.String(),
+			want:  current,
+		},
+		{
+			name:  "unversioned candidate array",
+			input: `[{"address":4198400,"detection_type":"both","prologue_type":"classic","confidence":"high"},{"address":4198500,"detection_type":"cfi","confidence":"high"}]`,
+			want: &resurgo.AnalysisResult{
+				Format: resurgo.FormatELF,
+				Functions: []resurgo.FunctionCandidate{
+					{Address: 4198400, DetectionType: resurgo.DetectionPrologueCallSite, PrologueType: resurgo.PrologueClassic, Confidence: resurgo.ConfidenceHigh},
+					{Address: 4198500, DetectionType: resurgo.DetectionCFI, Confidence: resurgo.ConfidenceHigh},
+				},
+			},
+		},
+		{
+			name:  "unversioned result object",
+			input: `{"format":"archive","members":[{"name":"a.o","format":"elf","arch":"amd64","functions":[{"address":16,"detection_type":"both","confidence":"high"}]}]}`,
+			want: &resurgo.AnalysisResult{
+				Format: resurgo.FormatArchive,
+				Members: []resurgo.AnalysisResult{{
+					Name:      "a.o",
+					Format:    resurgo.FormatELF,
+					Arch:      resurgo.ArchAMD64,
+					Functions: []resurgo.FunctionCandidate{{Address: 16, DetectionType: resurgo.DetectionPrologueCallSite, Confidence: resurgo.ConfidenceHigh}},
+				}},
+			},
+		},
+		{
+			name:    "newer version",
+			input:   `{"schema_version":999,"format":"elf"}`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			input:   `{"schema_version":`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resurgo.Decode(strings.NewReader(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resurgo.Decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
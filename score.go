@@ -0,0 +1,167 @@
+package resurgo
+
+import (
+	"maps"
+	"slices"
+)
+
+// maxScoredEdges caps the number of callers, and of jump sources, counted
+// as independent evidence: beyond a few, more sites add little.
+const maxScoredEdges = 3
+
+// ScoreWeights is the table of evidence weights the Score of a candidate
+// combines. Each weight is the probability, from 0 to 1, that the evidence
+// alone marks a function entry; the pieces of evidence are taken as
+// independent. It is JSON-serializable, so that a table tuned for a
+// toolchain mix can be kept in a file.
+type ScoreWeights struct {
+	// Prologues weighs each prologue pattern. Patterns it does not list,
+	// e.g. registered after DefaultScoreWeights was called, weigh 0.8, 0.6
+	// or 0.4 after the ConfidenceHigh, ConfidenceMedium or ConfidenceLow
+	// prior they are registered with.
+	Prologues map[PrologueType]float64 `json:"prologues,omitempty"`
+	// Boundary weighs the code preceding the candidate ending a function,
+	// e.g. a push-only prologue after a return against one mid-stream.
+	Boundary float64 `json:"boundary"`
+	// Call and Jump weigh each distinct call and jump site, up to three of
+	// each.
+	Call float64 `json:"call"`
+	Jump float64 `json:"jump"`
+	// Detections weighs the sources of candidates other than disassembly,
+	// by detection type, e.g. DetectionCFI for an FDE.
+	Detections map[DetectionType]float64 `json:"detections,omitempty"`
+}
+
+// DefaultScoreWeights returns the built-in weights table, every registered
+// prologue pattern weighing after its prior. Function starts recorded by
+// the toolchain weigh the most, symbols above all.
+func DefaultScoreWeights() ScoreWeights {
+	w := ScoreWeights{
+		Prologues: make(map[PrologueType]float64),
+		Boundary:  0.3,
+		Call:      0.5,
+		Jump:      0.2,
+		Detections: map[DetectionType]float64{
+			DetectionSymbol:           0.99,
+			DetectionExport:           0.99,
+			DetectionAnchor:           0.99,
+			DetectionCFI:              0.95,
+			DetectionSFrame:           0.95,
+			DetectionORC:              0.95,
+			DetectionExidx:            0.95,
+			DetectionPclntab:          0.95,
+			DetectionPData:            0.95,
+			DetectionFunctionStarts:   0.95,
+			DetectionObjCMethod:       0.95,
+			DetectionSwiftMetadata:    0.95,
+			DetectionCRT:              0.9,
+			DetectionVtable:           0.9,
+			DetectionInitFini:         0.9,
+			DetectionIfuncResolver:    0.9,
+			DetectionIfuncTarget:      0.9,
+			DetectionRecursiveDescent: 0.9,
+			DetectionBranchTarget:     0.6,
+			DetectionPlugin:           0.5,
+		},
+	}
+	for _, info := range PrologueTypes() {
+		w.Prologues[info.Type] = priorWeight(info.Confidence)
+	}
+	return w
+}
+
+// WithScoreWeights sets the weights table candidates are scored with
+// (default DefaultScoreWeights), to tune the score to a compiler or
+// toolchain mix. Start from DefaultScoreWeights and adjust the entries to
+// change; weights outside [0, 1] are clamped. The floors that Confidence
+// sets on the score still apply.
+func WithScoreWeights(w ScoreWeights) Option {
+	return func(o *options) {
+		w.Prologues = maps.Clone(w.Prologues)
+		w.Detections = maps.Clone(w.Detections)
+		o.scoreWeights = &w
+	}
+}
+
+// priorWeight returns the weight of a prologue pattern registered with
+// confidence level c.
+func priorWeight(c Confidence) float64 {
+	switch c {
+	case ConfidenceHigh:
+		return 0.8
+	case ConfidenceMedium:
+		return 0.6
+	}
+	return 0.4
+}
+
+// confidenceFloor returns the lowest score of a candidate at level c: the
+// filters raising a candidate to a level, e.g. EhFrameFilter confirming it
+// with an FDE, leave no other trace of their evidence.
+func confidenceFloor(c Confidence) float64 {
+	switch c {
+	case ConfidenceHigh:
+		return 0.8
+	case ConfidenceMedium:
+		return 0.5
+	}
+	return 0
+}
+
+// score returns the score of c: the probability that at least one of its
+// pieces of evidence marks a function entry, taken as independent, and no
+// lower than the floor of its confidence level.
+func (w *ScoreWeights) score(c FunctionCandidate) float64 {
+	miss := 1.0
+	if c.PrologueType != "" {
+		weight, ok := w.Prologues[c.PrologueType]
+		if !ok {
+			info, _ := LookupPrologueType(c.PrologueType)
+			weight = priorWeight(info.Confidence)
+		}
+		miss *= 1 - clampWeight(weight)
+	}
+	if c.Boundary {
+		miss *= 1 - clampWeight(w.Boundary)
+	}
+	for range min(distinct(c.CalledFrom), maxScoredEdges) {
+		miss *= 1 - clampWeight(w.Call)
+	}
+	for range min(distinct(c.JumpedFrom), maxScoredEdges) {
+		miss *= 1 - clampWeight(w.Jump)
+	}
+	if weight, ok := w.Detections[c.DetectionType]; ok {
+		miss *= 1 - clampWeight(weight)
+	}
+	return max(1-miss, confidenceFloor(c.Confidence))
+}
+
+// scoreCandidates sets the Score of each candidate with the weights of o.
+func (o *options) scoreCandidates(candidates []FunctionCandidate) {
+	w := o.scoreWeights
+	if w == nil {
+		d := DefaultScoreWeights()
+		w = &d
+	}
+	for i := range candidates {
+		candidates[i].Score = w.score(candidates[i])
+	}
+}
+
+// clampWeight clamps w to [0, 1]; NaN weighs nothing.
+func clampWeight(w float64) float64 {
+	if !(w > 0) {
+		return 0
+	}
+	return min(w, 1)
+}
+
+// distinct returns the number of distinct addresses in addrs.
+func distinct(addrs []uint64) int {
+	if len(addrs) < 2 {
+		return len(addrs)
+	}
+	sorted := slices.Clone(addrs)
+	slices.Sort(sorted)
+	return len(slices.Compact(sorted))
+}
@@ -0,0 +1,142 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestCandidateScore(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+	outPath := filepath.Join(t.TempDir(), "demo-app-c")
+	cmd := exec.Command("gcc", "-O0", "-o", outPath, "testdata/demo-app.c")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF binary: %v", err)
+	}
+	defer f.Close()
+
+	for _, tt := range []struct {
+		name string
+		opts []resurgo.Option
+	}{
+		{"default", nil},
+		{"unfiltered", []resurgo.Option{resurgo.WithFilters()}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, err := resurgo.DetectFunctionsFromELF(f, tt.opts...)
+			if err != nil {
+				t.Fatalf("resurgo.DetectFunctionsFromELF: %v", err)
+			}
+			floor := map[resurgo.Confidence]float64{
+				resurgo.ConfidenceHigh:   0.8,
+				resurgo.ConfidenceMedium: 0.5,
+				resurgo.ConfidenceLow:    0,
+			}
+			for _, c := range candidates {
+				if c.Score <= 0 || c.Score > 1 || c.Score < floor[c.Confidence] {
+					t.Errorf("0x%x (%s/%s): score %v out of range", c.Address, c.DetectionType, c.Confidence, c.Score)
+				}
+			}
+		})
+	}
+
+	// The more signals back a candidate, the higher its score.
+	candidates, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithFilters())
+	if err != nil {
+		t.Fatalf("resurgo.DetectFunctionsFromELF: %v", err)
+	}
+	best := make(map[resurgo.DetectionType]float64)
+	for _, c := range candidates {
+		if c.PrologueType == resurgo.PrologueClassic || c.PrologueType == "" {
+			best[c.DetectionType] = max(best[c.DetectionType], c.Score)
+		}
+	}
+	order := []resurgo.DetectionType{
+		resurgo.DetectionPrologueCallSite,
+		resurgo.DetectionPrologueOnly,
+		resurgo.DetectionCallTarget,
+		resurgo.DetectionAlignedEntry,
+	}
+	for i := 1; i < len(order); i++ {
+		hi, lo := best[order[i-1]], best[order[i]]
+		if hi == 0 || lo == 0 {
+			t.Fatalf("missing %s or %s candidates: %v", order[i-1], order[i], best)
+		}
+		if hi <= lo {
+			t.Errorf("%s scores %v, not above %s at %v", order[i-1], hi, order[i], lo)
+		}
+	}
+}
+
+func TestWithScoreWeights(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+	outPath := filepath.Join(t.TempDir(), "demo-app-c")
+	cmd := exec.Command("gcc", "-O0", "-o", outPath, "testdata/demo-app.c")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile demo-app.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF binary: %v", err)
+	}
+	defer f.Close()
+
+	defaults := resurgo.DefaultScoreWeights()
+	if defaults.Prologues[resurgo.PrologueClassic] == 0 || defaults.Detections[resurgo.DetectionCFI] == 0 {
+		t.Fatalf("DefaultScoreWeights lacks built-in entries: %+v", defaults)
+	}
+	tuned := resurgo.DefaultScoreWeights()
+	tuned.Boundary = 0.1
+	tuned.Call = 2 // clamped to 1
+
+	scores := func(opts ...resurgo.Option) map[uint64]resurgo.FunctionCandidate {
+		t.Helper()
+		candidates, err := resurgo.DetectFunctionsFromELF(f, append(opts, resurgo.WithFilters())...)
+		if err != nil {
+			t.Fatalf("resurgo.DetectFunctionsFromELF: %v", err)
+		}
+		m := make(map[uint64]resurgo.FunctionCandidate)
+		for _, c := range candidates {
+			m[c.Address] = c
+		}
+		return m
+	}
+	base := scores()
+	withDefaults := scores(resurgo.WithScoreWeights(defaults))
+	withTuned := scores(resurgo.WithScoreWeights(tuned))
+
+	var aligned, called int
+	for addr, c := range base {
+		if got := withDefaults[addr].Score; got != c.Score {
+			t.Errorf("0x%x: default weights score %v, want %v", addr, got, c.Score)
+		}
+		got := withTuned[addr].Score
+		switch {
+		case c.DetectionType == resurgo.DetectionAlignedEntry:
+			aligned++
+			if math.Abs(got-0.1) > 1e-9 {
+				t.Errorf("0x%x: aligned entry scores %v with boundary weight 0.1", addr, got)
+			}
+		case len(c.CalledFrom) > 0:
+			called++
+			if got != 1 {
+				t.Errorf("0x%x: called candidate scores %v with call weight 1", addr, got)
+			}
+		}
+	}
+	if aligned == 0 || called == 0 {
+		t.Fatalf("expected aligned entries and called candidates, got %d and %d", aligned, called)
+	}
+}
@@ -0,0 +1,108 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"fmt"
+	"slices"
+)
+
+// DetectionSFrame is assigned to function candidates whose entry address was
+// read from an FDE of the SFrame stack trace format (.sframe), which recent
+// GNU toolchains emit for stack unwinding by profilers and tracers. Like
+// CFI, the addresses are written by the toolchain and survive stripping.
+const DetectionSFrame DetectionType = "sframe"
+
+const (
+	// sframeMagic is the magic number opening every SFrame section, in the
+	// byte order of the file.
+	sframeMagic = 0xdee2
+	// sframeHeaderSize is the size of the fixed SFrame header: preamble,
+	// ABI/arch, fixed CFA offsets, auxiliary header length, FDE and FRE
+	// counts, FRE sub-section length, and FDE and FRE sub-section offsets.
+	sframeHeaderSize = 28
+	// sframeFDESizeV1 and sframeFDESizeV2 are the sizes of an FDE in version
+	// 1 and 2 of the format; version 2 appends the repetition block size and
+	// two bytes of padding.
+	sframeFDESizeV1 = 17
+	sframeFDESizeV2 = 20
+	// sframeFlagFuncStartPCRel marks sections whose FDE start addresses are
+	// relative to the field itself rather than to the start of the section.
+	sframeFlagFuncStartPCRel = 0x4
+)
+
+// SFrameDetector is a CandidateDetector that emits the function start
+// address of every FDE of the .sframe section of f. Versions 1 and 2 of
+// the format are read; sections of other versions, or in the wrong byte
+// order, yield no candidates. Each candidate carries DetectionSFrame and
+// ConfidenceHigh. Returns an empty slice (no error) when .sframe is absent.
+// It is not part of the default pipeline.
+func SFrameDetector(f *elf.File) ([]FunctionCandidate, error) {
+	starts, err := parseSFrameEntries(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse .sframe: %w", err)
+	}
+	candidates := make([]FunctionCandidate, 0, len(starts))
+	for _, va := range starts {
+		candidates = append(candidates, FunctionCandidate{
+			Address:       va,
+			DetectionType: DetectionSFrame,
+			Confidence:    ConfidenceHigh,
+		})
+	}
+	return candidates, nil
+}
+
+// parseSFrameEntries parses the .sframe section of f and returns the sorted,
+// deduplicated start addresses of its FDEs that fall in an executable
+// section. Returns nil (no error) if the section is absent, unsupported or
+// malformed, or if f is a relocatable object, whose addresses are only
+// resolved at link time.
+func parseSFrameEntries(f *elf.File) ([]uint64, error) {
+	sec := f.Section(".sframe")
+	if sec == nil || sec.Type == elf.SHT_NOBITS || f.Type == elf.ET_REL {
+		return nil, nil
+	}
+	data, err := sectionData(sec)
+	if err != nil {
+		return nil, fmt.Errorf("read .sframe: %w", err)
+	}
+	if len(data) < sframeHeaderSize {
+		return nil, nil
+	}
+
+	bo := f.ByteOrder
+	if bo.Uint16(data) != sframeMagic {
+		return nil, nil
+	}
+	version, flags := data[2], data[3]
+	var fdeSize int
+	switch version {
+	case 1:
+		fdeSize = sframeFDESizeV1
+	case 2:
+		fdeSize = sframeFDESizeV2
+	default:
+		return nil, nil
+	}
+	auxLen := int(data[7])
+	numFDEs := int(bo.Uint32(data[8:]))
+	fdeOff := sframeHeaderSize + auxLen + int(bo.Uint32(data[20:]))
+	if fdeOff > len(data) || numFDEs > (len(data)-fdeOff)/fdeSize {
+		return nil, nil
+	}
+
+	exec := execRanges(f)
+	starts := make([]uint64, 0, numFDEs)
+	for i := range numFDEs {
+		off := fdeOff + i*fdeSize
+		va := sec.Addr + uint64(int64(int32(bo.Uint32(data[off:]))))
+		if flags&sframeFlagFuncStartPCRel != 0 {
+			va += uint64(off)
+		}
+		if inRanges(va, exec) {
+			starts = append(starts, va)
+		}
+	}
+	slices.Sort(starts)
+	return slices.Compact(starts), nil
+}
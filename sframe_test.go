@@ -0,0 +1,86 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestSFrameDetector(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "sframe.c")
+	const code = `
+__attribute__((noinline)) int scale(int x) { return x * 3; }
+__attribute__((noinline)) int shift(int x) { return scale(x) + 1; }
+int main(int argc, char **argv) { return shift(argc); }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	outPath := filepath.Join(dir, "sframe")
+	cmd := exec.Command("gcc", "-O1", "-Wa,--gsframe", "-o", outPath, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("toolchain cannot emit SFrame: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+	if f.Section(".sframe") == nil {
+		t.Skip("toolchain emitted no .sframe")
+	}
+
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	want := map[string]uint64{"scale": 0, "shift": 0, "main": 0}
+	for _, s := range syms {
+		if _, ok := want[s.Name]; ok && elf.ST_TYPE(s.Info) == elf.STT_FUNC {
+			want[s.Name] = s.Value
+		}
+	}
+
+	candidates, err := resurgo.SFrameDetector(f)
+	if err != nil {
+		t.Fatalf("resurgo.SFrameDetector: %v", err)
+	}
+	got := make(map[uint64]bool)
+	for _, c := range candidates {
+		if c.DetectionType != resurgo.DetectionSFrame || c.Confidence != resurgo.ConfidenceHigh {
+			t.Errorf("candidate 0x%x: unexpected %s/%s", c.Address, c.DetectionType, c.Confidence)
+		}
+		got[c.Address] = true
+	}
+	for name, addr := range want {
+		if addr == 0 {
+			t.Errorf("%s: symbol not found", name)
+		} else if !got[addr] {
+			t.Errorf("%s at 0x%x not detected", name, addr)
+		}
+	}
+
+	// Every FDE is also described by .eh_frame.
+	eh, err := resurgo.EhFrameDetector(f)
+	if err != nil {
+		t.Fatalf("resurgo.EhFrameDetector: %v", err)
+	}
+	fdes := make(map[uint64]bool)
+	for _, c := range eh {
+		fdes[c.Address] = true
+	}
+	for addr := range got {
+		if !fdes[addr] {
+			t.Errorf("0x%x: not an .eh_frame FDE", addr)
+		}
+	}
+}
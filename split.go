@@ -0,0 +1,258 @@
+package resurgo
+
+import (
+	"cmp"
+	"debug/elf"
+	"fmt"
+	"slices"
+
+	"golang.org/x/arch/arm64/arm64asm"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+// AddressRange is the [Start, End) range of virtual addresses of a block of
+// code.
+type AddressRange struct {
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+}
+
+// SplitFunction is a function the compiler split into a hot part, holding
+// its entry, and cold parts placed apart, e.g. GCC's foo.cold blocks in
+// .text.unlikely. Each part is otherwise reported as a function candidate
+// of its own.
+type SplitFunction struct {
+	// Entry is the address of the function entry, the start of the hot
+	// part.
+	Entry uint64 `json:"entry"`
+	// Ranges holds the hot part first, then the cold parts by address.
+	Ranges []AddressRange `json:"ranges"`
+}
+
+// branch is a direct branch of the code.
+type branch struct {
+	source, target uint64
+	call           bool
+	conditional    bool
+}
+
+// DetectSplitFunctions links the cold parts among candidates, e.g. those of
+// DetectFunctionsFromELF, back to their parent and returns the functions of
+// f with cold parts as single logical functions, ordered by entry. A
+// candidate is a cold part of another, its parent, when it is never called
+// and every direct branch to it comes from the parent, either as a
+// conditional branch or answered by a branch from the cold part back into
+// the body of the parent: a function only reached by an unconditional jump
+// is taken for the target of a tail call instead.
+// The range of each part runs up to the next candidate, so it is only as
+// accurate as candidates are complete. Only x86, x86-64 and ARM64 linked
+// files are analyzed; others return no split functions. opts may include
+// WithLimits.
+func DetectSplitFunctions(f *elf.File, candidates []FunctionCandidate, opts ...Option) ([]SplitFunction, error) {
+	if f.Type == elf.ET_REL {
+		return nil, nil
+	}
+	arch, err := elfArch(f)
+	if err != nil {
+		return nil, err
+	}
+	switch arch {
+	case ArchAMD64, ArchX86, ArchARM64:
+	default:
+		return nil, nil
+	}
+	o := newOptions(opts...).withBudget()
+	sections, err := elfCodeSections(f, arch, o.budget)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each candidate owns the code up to the next one or the end of its
+	// section.
+	starts := make([]uint64, 0, len(candidates))
+	for _, c := range candidates {
+		starts = append(starts, c.Address)
+	}
+	slices.Sort(starts)
+	starts = slices.Compact(starts)
+	parts := make([]AddressRange, 0, len(starts))
+	for i, start := range starts {
+		j := slices.IndexFunc(sections, func(sec codeSection) bool {
+			return start >= sec.addr && start-sec.addr < uint64(len(sec.code))
+		})
+		if j < 0 {
+			continue
+		}
+		end := sections[j].addr + uint64(len(sections[j].code))
+		if i+1 < len(starts) {
+			end = min(end, starts[i+1])
+		}
+		parts = append(parts, AddressRange{Start: start, End: end})
+	}
+	// owner returns the index of the part holding addr, or -1.
+	owner := func(addr uint64) int {
+		i, found := slices.BinarySearchFunc(parts, addr, func(r AddressRange, addr uint64) int {
+			return cmp.Compare(r.Start, addr)
+		})
+		if !found {
+			i--
+		}
+		if i < 0 || addr >= parts[i].End {
+			return -1
+		}
+		return i
+	}
+
+	incoming := make(map[uint64][]branch)
+	var outgoing []branch
+	for _, sec := range sections {
+		branches, err := directBranches(sec.code, sec.addr, arch, o.budget)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range branches {
+			incoming[b.target] = append(incoming[b.target], b)
+		}
+		outgoing = append(outgoing, branches...)
+	}
+
+	// parent maps the index of each cold part to the index of its parent.
+	parent := make(map[int]int)
+	for i, part := range parts {
+		in := incoming[part.Start]
+		if len(in) == 0 || part.Start == f.Entry {
+			continue
+		}
+		p, split := owner(in[0].source), false
+		for _, b := range in {
+			if b.call || owner(b.source) != p {
+				p = -1
+				break
+			}
+			split = split || b.conditional
+		}
+		if p < 0 || p == i {
+			continue
+		}
+		if !split {
+			// A branch from the cold part back into the body of the
+			// parent tells it from a tail-called function.
+			split = slices.ContainsFunc(outgoing, func(b branch) bool {
+				return !b.call && b.source >= part.Start && b.source < part.End &&
+					b.target > parts[p].Start && b.target < parts[p].End
+			})
+		}
+		if split {
+			parent[i] = p
+		}
+	}
+
+	byEntry := make(map[int]*SplitFunction)
+	for i, p := range parent {
+		// Cold parts of cold parts belong to the root.
+		for seen := 0; seen < len(parent); seen++ {
+			q, ok := parent[p]
+			if !ok {
+				break
+			}
+			p = q
+		}
+		if _, ok := parent[p]; ok {
+			// A cycle of parts has no entry.
+			continue
+		}
+		fn, ok := byEntry[p]
+		if !ok {
+			fn = &SplitFunction{Entry: parts[p].Start, Ranges: []AddressRange{parts[p]}}
+			byEntry[p] = fn
+		}
+		fn.Ranges = append(fn.Ranges, parts[i])
+	}
+	functions := make([]SplitFunction, 0, len(byEntry))
+	for _, fn := range byEntry {
+		slices.SortFunc(fn.Ranges[1:], func(a, b AddressRange) int { return cmp.Compare(a.Start, b.Start) })
+		functions = append(functions, *fn)
+	}
+	slices.SortFunc(functions, func(a, b SplitFunction) int { return cmp.Compare(a.Entry, b.Entry) })
+	if err := o.budget.results(len(functions)); err != nil {
+		return nil, err
+	}
+	return functions, nil
+}
+
+// directBranches returns the direct calls and jumps, conditional or not, of
+// code mapped at baseAddr.
+func directBranches(code []byte, baseAddr uint64, arch Arch, b *budget) ([]branch, error) {
+	var branches []branch
+	switch arch {
+	case ArchAMD64, ArchX86:
+		mode := 64
+		if arch == ArchX86 {
+			mode = 32
+		}
+		for off := 0; off < len(code); {
+			if err := b.step(off); err != nil {
+				return nil, err
+			}
+			if isENDBR(code, off) {
+				off += 4
+				continue
+			}
+			inst, err := x86asm.Decode(code[off:], mode)
+			if err != nil {
+				off++
+				continue
+			}
+			addr := baseAddr + uint64(off)
+			off += inst.Len
+			rel, ok := inst.Args[0].(x86asm.Rel)
+			if !ok {
+				continue
+			}
+			target, ok := relTarget(addr+uint64(inst.Len), int64(rel), false)
+			if !ok {
+				continue
+			}
+			branches = append(branches, branch{
+				source:      addr,
+				target:      target,
+				call:        inst.Op == x86asm.CALL,
+				conditional: inst.Op != x86asm.CALL && inst.Op != x86asm.JMP,
+			})
+		}
+	case ArchARM64:
+		for off := 0; off+4 <= len(code); off += 4 {
+			if err := b.step(off); err != nil {
+				return nil, err
+			}
+			inst, err := arm64asm.Decode(code[off : off+4])
+			if err != nil {
+				continue
+			}
+			switch inst.Op {
+			case arm64asm.B, arm64asm.BL, arm64asm.CBZ, arm64asm.CBNZ, arm64asm.TBZ, arm64asm.TBNZ:
+			default:
+				continue
+			}
+			addr := baseAddr + uint64(off)
+			for _, arg := range inst.Args {
+				pcrel, ok := arg.(arm64asm.PCRel)
+				if !ok {
+					continue
+				}
+				if target, ok := relTarget(addr, int64(pcrel), false); ok {
+					branches = append(branches, branch{
+						source:      addr,
+						target:      target,
+						call:        inst.Op == arm64asm.BL,
+						conditional: inst.Op != arm64asm.BL && (inst.Op != arm64asm.B || isConditionalARM64(inst)),
+					})
+				}
+				break
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported architecture: %s", arch)
+	}
+	return branches, nil
+}
@@ -0,0 +1,97 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestDetectSplitFunctions(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "cold.c")
+	// The calls to the cold function fail are moved to check.cold and
+	// scale.cold; scale.cold jumps back into scale, check.cold does not
+	// return.
+	code := `#include <stdio.h>
+#include <stdlib.h>
+__attribute__((cold, noinline)) void fail(const char *m) { fprintf(stderr, "%s\n", m); }
+__attribute__((noinline)) int check(int x) {
+  if (x < 0) { fail("negative"); abort(); }
+  return x * 2;
+}
+__attribute__((noinline)) int scale(int x, int y) {
+  if (y == 0) { fail("zero"); return -1; }
+  return x / y + check(x);
+}
+int main(int argc, char **argv) { return scale(argc, argc - 1) + check(argc); }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	path := filepath.Join(dir, "cold")
+	if out, err := exec.Command("gcc", "-O2", "-o", path, src).CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile cold.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	addrs := make(map[string]uint64)
+	for _, sym := range syms {
+		if elf.ST_TYPE(sym.Info) == elf.STT_FUNC {
+			addrs[sym.Name] = sym.Value
+		}
+	}
+	want := make(map[uint64]uint64) // cold part to parent
+	for name, addr := range addrs {
+		if parent, ok := strings.CutSuffix(name, ".cold"); ok {
+			want[addr] = addrs[parent]
+		}
+	}
+	if len(want) == 0 {
+		t.Skip("gcc did not split any function, skipping")
+	}
+
+	candidates, err := resurgo.DetectFunctionsFromELF(f)
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromELF: %v", err)
+	}
+	functions, err := resurgo.DetectSplitFunctions(f, candidates)
+	if err != nil {
+		t.Fatalf("DetectSplitFunctions: %v", err)
+	}
+	got := make(map[uint64]uint64)
+	for _, fn := range functions {
+		if len(fn.Ranges) < 2 || fn.Ranges[0].Start != fn.Entry {
+			t.Errorf("function at 0x%x: malformed ranges %+v", fn.Entry, fn.Ranges)
+			continue
+		}
+		for _, r := range fn.Ranges[1:] {
+			got[r.Start] = fn.Entry
+		}
+	}
+	for cold, parent := range want {
+		if got[cold] != parent {
+			t.Errorf("cold part at 0x%x: got parent 0x%x, want 0x%x", cold, got[cold], parent)
+		}
+	}
+	for cold, parent := range got {
+		if _, ok := want[cold]; !ok {
+			t.Errorf("0x%x reported as a cold part of 0x%x", cold, parent)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package resurgo
+
+import "golang.org/x/arch/x86/x86asm"
+
+// supersetWindow is the number of bytes before a prologue whose decodings
+// vote on it.
+const supersetWindow = 32
+
+// WithSupersetVoting sets whether prologues found in x86 code must sit on
+// the instruction stream the decodings of the preceding bytes converge on
+// (default false). The linear sweep decodes code from a single starting
+// offset, and embedded data can throw it out of step with the real
+// instructions, so that it reads a prologue inside the operand of one. In
+// this mode each of the 32 bytes before a prologue starts a decoding of its
+// own, as in superset disassembly: x86 decodings quickly converge, and the
+// prologue is kept only when at least as many of them reach its address as
+// step over it. Other architectures, whose instructions are aligned, are
+// unaffected.
+func WithSupersetVoting(enabled bool) Option {
+	return func(o *options) {
+		o.supersetVoting = enabled
+	}
+}
+
+// supersetVote returns the prologues of x86 code decoded in mode, mapped at
+// baseAddr, that the decodings starting in the supersetWindow bytes before
+// them agree on.
+func supersetVote(prologues []Prologue, code []byte, baseAddr uint64, mode int) []Prologue {
+	s := supersetDecoder{code: code, mode: mode, lengths: make([]int8, len(code))}
+	kept := prologues[:0]
+	for _, p := range prologues {
+		if p.Address < baseAddr || p.Address-baseAddr >= uint64(len(code)) || s.convergent(int(p.Address-baseAddr)) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// supersetDecoder decodes x86 code at any offset, caching the length of
+// the instruction at each.
+type supersetDecoder struct {
+	code []byte
+	mode int
+	// lengths holds the length of the instruction at each offset: zero
+	// when not yet decoded, -1 when the bytes do not decode.
+	lengths []int8
+}
+
+// length returns the length of the instruction at offset off, or zero if
+// the bytes there do not decode.
+func (s *supersetDecoder) length(off int) int {
+	if s.lengths[off] == 0 {
+		s.lengths[off] = -1
+		if isENDBR(s.code, off) {
+			s.lengths[off] = 4
+		} else if inst, err := x86asm.Decode(s.code[off:], s.mode); err == nil {
+			s.lengths[off] = int8(inst.Len)
+		}
+	}
+	return max(int(s.lengths[off]), 0)
+}
+
+// convergent reports whether at least as many of the decodings started in
+// the supersetWindow bytes before off reach off as step over it. Decodings
+// that run into undecodable bytes abstain.
+func (s *supersetDecoder) convergent(off int) bool {
+	var reach, skip int
+	for start := max(off-supersetWindow, 0); start < off; start++ {
+		pos := start
+		for pos < off {
+			n := s.length(pos)
+			if n == 0 {
+				break
+			}
+			pos += n
+		}
+		switch {
+		case pos == off:
+			reach++
+		case pos > off:
+			skip++
+		}
+	}
+	return reach >= skip
+}
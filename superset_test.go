@@ -0,0 +1,51 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestWithSupersetVoting(t *testing.T) {
+	// ret, then a constant pool of 0xb8 bytes ending in cmp al, 0xb8: the
+	// sweep, in step with the pool, reads push rbp; mov rbp, rsp out of
+	// the last constant, which most decodings read as mov eax, imm32.
+	var code []byte
+	code = append(code, 0xc3)
+	code = append(code, bytes.Repeat([]byte{0xb8}, 40)...)
+	code = append(code, 0x3c, 0xb8)
+	fake := len(code)
+	code = append(code, 0x55, 0x48, 0x89, 0xe5, 0x5d, 0xc3)
+	// int3 padding, then a real function.
+	code = append(code, 0xcc, 0xcc, 0xcc, 0xcc)
+	entry := len(code)
+	code = append(code, 0x55, 0x48, 0x89, 0xe5, 0x5d, 0xc3)
+
+	const base = 0x1000
+	for _, tt := range []struct {
+		name    string
+		enabled bool
+		want    []uint64
+	}{
+		{"sweep", false, []uint64{base + uint64(fake), base + uint64(entry)}},
+		{"voting", true, []uint64{base + uint64(entry)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			prologues, err := resurgo.DetectPrologues(code, base, resurgo.ArchAMD64, resurgo.WithSupersetVoting(tt.enabled))
+			if err != nil {
+				t.Fatalf("DetectPrologues: %v", err)
+			}
+			var got []uint64
+			for _, p := range prologues {
+				if p.Type == resurgo.PrologueClassic {
+					got = append(got, p.Address)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got classic prologues at %#x, want %#x", got, tt.want)
+			}
+		})
+	}
+}
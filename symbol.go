@@ -0,0 +1,110 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// DetectionSymbol is assigned to function candidates read from the symbol
+// tables of an ELF file (.symtab, .dynsym), which partially stripped
+// binaries and shared libraries keep.
+const DetectionSymbol DetectionType = "symbol"
+
+// WithSymbols sets whether DetectFunctionsFromELF merges the function
+// symbols of the file into its results (default false). Candidates at a
+// symbol are named after it and raised to ConfidenceHigh, and the symbols no
+// detector found, or that a filter removed, are added as emitted by
+// SymbolDetector. This mixes the symbols of partially stripped binaries
+// with the heuristic results in one pass.
+func WithSymbols(enabled bool) Option {
+	return func(o *options) {
+		o.symbols = enabled
+	}
+}
+
+// SymbolDetector is a CandidateDetector that emits the STT_FUNC and
+// STT_GNU_IFUNC symbols of .symtab and .dynsym defined in an executable
+// section, named after the symbol, .symtab taking precedence when both
+// name an address. The Thumb bit is cleared on ARM. In relocatable
+// objects, only the symbols of the sections scanned by DisasmDetector are
+// emitted, at their offset into the section. Each candidate carries
+// DetectionSymbol and ConfidenceHigh. Fully stripped files yield no
+// candidates. It is not part of the default pipeline; WithSymbols runs it
+// along with it.
+func SymbolDetector(f *elf.File) ([]FunctionCandidate, error) {
+	var syms []elf.Symbol
+	for _, read := range []func() ([]elf.Symbol, error){f.Symbols, f.DynamicSymbols} {
+		s, err := read()
+		if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+			return nil, fmt.Errorf("read symbols: %w", err)
+		}
+		syms = append(syms, s...)
+	}
+
+	// The sections of relocatable objects overlap; only those scanned by
+	// DisasmDetector are kept.
+	code := disasmSections(f)
+	names := make(map[uint64]string)
+	for _, s := range syms {
+		if typ := elf.ST_TYPE(s.Info); typ != elf.STT_FUNC && typ != elf.STT_GNU_IFUNC {
+			continue
+		}
+		if s.Section == elf.SHN_UNDEF || int(s.Section) >= len(f.Sections) {
+			continue
+		}
+		sec := f.Sections[s.Section]
+		if sec.Flags&elf.SHF_EXECINSTR == 0 || (f.Type == elf.ET_REL && !slices.Contains(code, sec)) {
+			continue
+		}
+		addr := s.Value
+		if f.Machine == elf.EM_ARM {
+			addr &^= 1
+		}
+		if _, ok := names[addr]; !ok {
+			names[addr] = s.Name
+		}
+	}
+
+	candidates := make([]FunctionCandidate, 0, len(names))
+	for addr, name := range names {
+		candidates = append(candidates, FunctionCandidate{
+			Address:       addr,
+			DetectionType: DetectionSymbol,
+			Confidence:    ConfidenceHigh,
+			Name:          name,
+		})
+	}
+	slices.SortFunc(candidates, func(a, b FunctionCandidate) int {
+		switch {
+		case a.Address < b.Address:
+			return -1
+		case a.Address > b.Address:
+			return 1
+		}
+		return 0
+	})
+	return candidates, nil
+}
+
+// mergeSymbols names the candidates at the addresses of the named
+// candidates in symbols, raises them to ConfidenceHigh, and adds the
+// symbols no candidate is at.
+func mergeSymbols(candidates, symbols []FunctionCandidate) []FunctionCandidate {
+	names := make(map[uint64]string, len(symbols))
+	for _, s := range symbols {
+		if s.Name != "" {
+			names[s.Address] = s.Name
+		}
+	}
+	for i, c := range candidates {
+		if name, ok := names[c.Address]; ok {
+			candidates[i].Confidence = ConfidenceHigh
+			if c.Name == "" {
+				candidates[i].Name = name
+			}
+		}
+	}
+	return mergeCandidates(candidates, symbols)
+}
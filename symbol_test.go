@@ -0,0 +1,105 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestWithSymbols(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "syms.c")
+	// The asm function has no prologue and no CFI, and is only called
+	// indirectly: no heuristic finds it.
+	const code = `
+__asm__(".text\n.globl bare\n.type bare, @function\nbare:\n\tret\n.size bare, .-bare\n");
+void bare(void);
+void (*volatile hook)(void) = bare;
+__attribute__((noinline)) static int helper(int x) { return x * 7; }
+int main(int argc, char **argv) { hook(); return helper(argc); }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	outPath := filepath.Join(dir, "syms")
+	cmd := exec.Command("gcc", "-O1", "-o", outPath, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile syms.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	want := map[string]uint64{"bare": 0, "helper": 0, "main": 0}
+	for _, s := range syms {
+		if _, ok := want[s.Name]; ok && elf.ST_TYPE(s.Info) == elf.STT_FUNC {
+			want[s.Name] = s.Value
+		}
+	}
+
+	detected, err := resurgo.SymbolDetector(f)
+	if err != nil {
+		t.Fatalf("resurgo.SymbolDetector: %v", err)
+	}
+	byAddr := make(map[uint64]resurgo.FunctionCandidate)
+	for _, c := range detected {
+		if c.DetectionType != resurgo.DetectionSymbol || c.Confidence != resurgo.ConfidenceHigh {
+			t.Errorf("candidate 0x%x: unexpected %s/%s", c.Address, c.DetectionType, c.Confidence)
+		}
+		byAddr[c.Address] = c
+	}
+	for name, addr := range want {
+		if c, ok := byAddr[addr]; !ok || c.Name != name {
+			t.Errorf("%s at 0x%x: got %+v", name, addr, c)
+		}
+	}
+
+	without, err := resurgo.DetectFunctionsFromELF(f)
+	if err != nil {
+		t.Fatalf("resurgo.DetectFunctionsFromELF: %v", err)
+	}
+	for _, c := range without {
+		if c.Address == want["bare"] {
+			t.Fatalf("bare at 0x%x detected without symbols", c.Address)
+		}
+	}
+
+	with, err := resurgo.DetectFunctionsFromELF(f, resurgo.WithSymbols(true))
+	if err != nil {
+		t.Fatalf("resurgo.DetectFunctionsFromELF: %v", err)
+	}
+	got := make(map[uint64]resurgo.FunctionCandidate)
+	for _, c := range with {
+		got[c.Address] = c
+	}
+	for name, addr := range want {
+		c, ok := got[addr]
+		if !ok {
+			t.Errorf("%s at 0x%x not detected", name, addr)
+			continue
+		}
+		if c.Name != name || c.Confidence != resurgo.ConfidenceHigh {
+			t.Errorf("%s at 0x%x: got name %q, confidence %s", name, addr, c.Name, c.Confidence)
+		}
+	}
+	if typ := got[want["bare"]].DetectionType; typ != resurgo.DetectionSymbol {
+		t.Errorf("bare: got detection type %s, want %s", typ, resurgo.DetectionSymbol)
+	}
+	if typ := got[want["main"]].DetectionType; typ == resurgo.DetectionSymbol {
+		t.Errorf("main: heuristic detection type replaced by %s", typ)
+	}
+}
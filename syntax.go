@@ -0,0 +1,188 @@
+package resurgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"golang.org/x/arch/arm/armasm"
+	"golang.org/x/arch/arm64/arm64asm"
+	"golang.org/x/arch/ppc64/ppc64asm"
+	"golang.org/x/arch/riscv64/riscv64asm"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+const (
+	// Supported instruction syntaxes.
+	SyntaxIntel Syntax = "intel"
+	SyntaxGNU   Syntax = "gnu"
+	SyntaxARM   Syntax = "arm"
+)
+
+// Syntax selects the assembly syntax used to render instruction text.
+type Syntax string
+
+// WithSyntax renders Prologue.Instructions as the exact decoded instructions
+// of the match, from Address to Address+Size, in syntax s: SyntaxIntel or
+// SyntaxGNU (AT&T) for x86-64 and i386, SyntaxARM (the Arm reference syntax)
+// or SyntaxGNU for AArch64 and ARM32, and SyntaxGNU for RISC-V and ppc64le.
+// Thumb and Xtensa code have no decoder and keep their summary. Requesting
+// a syntax the architecture does not use is an error. By default
+// Instructions is a short summary of the matched pattern in Intel, Arm or
+// GNU syntax, with operands that do not characterize the pattern, such as
+// branch targets, omitted.
+func WithSyntax(s Syntax) Option {
+	return func(o *options) {
+		o.syntax = s
+	}
+}
+
+// checkSyntax reports whether the selected syntax applies to arch.
+func (o *options) checkSyntax(arch Arch) error {
+	switch {
+	case o.syntax == "", o.syntax == SyntaxGNU:
+		return nil
+	case o.syntax == SyntaxIntel && (arch == ArchAMD64 || arch == ArchX86):
+		return nil
+	case o.syntax == SyntaxARM && (arch == ArchARM64 || arch == ArchARM || arch == ArchThumb):
+		return nil
+	}
+	return fmt.Errorf("unsupported syntax %s for %s", o.syntax, arch)
+}
+
+// renderInstructions replaces the instruction summary of each prologue with
+// its decoded instructions in the selected syntax. code holds little-endian
+// instruction words starting at baseAddr. golang.org/x/arch has no T32 or
+// Xtensa decoder, so Thumb and Xtensa prologues keep their summary.
+func (o *options) renderInstructions(prologues []Prologue, code []byte, baseAddr uint64, arch Arch) {
+	if o.syntax == "" || arch == ArchThumb || arch == ArchXtensa {
+		return
+	}
+	for i := range prologues {
+		p := &prologues[i]
+		start := p.Address - baseAddr
+		end := min(start+p.Size, uint64(len(code)))
+		switch arch {
+		case ArchARM64:
+			p.Instructions = renderARM64(code[start:end], o.syntax)
+		case ArchRISCV64:
+			p.Instructions = renderRISCV64(code[start:end])
+		case ArchARM:
+			p.Instructions = renderARM(code[start:end], o.syntax)
+		case ArchPPC64LE, ArchPPC64:
+			p.Instructions = renderPPC64(code[start:end], p.Address)
+		case ArchX86:
+			p.Instructions = renderAMD64(code[start:end], p.Address, 32, o.syntax)
+		case ArchAMD64:
+			p.Instructions = renderAMD64(code[start:end], p.Address, 64, o.syntax)
+		}
+	}
+}
+
+// renderAMD64 renders the x86 instructions in code, located at addr and
+// decoded in mode.
+func renderAMD64(code []byte, addr uint64, mode int, syntax Syntax) string {
+	var insns []string
+	for offset := 0; offset < len(code); {
+		if isENDBR(code, offset) {
+			if code[offset+3] == endbr32Byte3 {
+				insns = append(insns, "endbr32")
+			} else {
+				insns = append(insns, "endbr64")
+			}
+			offset += 4
+			continue
+		}
+		inst, err := x86asm.Decode(code[offset:], mode)
+		if err != nil {
+			insns = append(insns, fmt.Sprintf(".byte 0x%x", code[offset]))
+			offset++
+			continue
+		}
+		// x86asm zero-extends disp32; register-relative displacements are
+		// signed.
+		for j, arg := range inst.Args {
+			if mem, ok := arg.(x86asm.Mem); ok && (mem.Base != 0 || mem.Index != 0) {
+				mem.Disp = int64(int32(mem.Disp))
+				inst.Args[j] = mem
+			}
+		}
+		pc := addr + uint64(offset)
+		if syntax == SyntaxGNU {
+			insns = append(insns, x86asm.GNUSyntax(inst, pc, nil))
+		} else {
+			insns = append(insns, x86asm.IntelSyntax(inst, pc, nil))
+		}
+		offset += inst.Len
+	}
+	return strings.Join(insns, "; ")
+}
+
+// renderARM64 renders the AArch64 instructions in code.
+func renderARM64(code []byte, syntax Syntax) string {
+	const insnLen = 4
+	var insns []string
+	for offset := 0; offset+insnLen <= len(code); offset += insnLen {
+		inst, err := arm64asm.Decode(code[offset : offset+insnLen])
+		switch {
+		case err != nil:
+			insns = append(insns, fmt.Sprintf(".word 0x%08x", binary.LittleEndian.Uint32(code[offset:])))
+		case syntax == SyntaxGNU:
+			insns = append(insns, arm64asm.GNUSyntax(inst))
+		default:
+			insns = append(insns, inst.String())
+		}
+	}
+	return strings.Join(insns, "; ")
+}
+
+// renderARM renders the A32 instructions in code.
+func renderARM(code []byte, syntax Syntax) string {
+	var insns []string
+	for offset := 0; offset+armInsnLen <= len(code); offset += armInsnLen {
+		inst, err := armasm.Decode(code[offset:offset+armInsnLen], armasm.ModeARM)
+		switch {
+		case err != nil:
+			insns = append(insns, fmt.Sprintf(".word 0x%08x", binary.LittleEndian.Uint32(code[offset:])))
+		case syntax == SyntaxGNU:
+			insns = append(insns, armasm.GNUSyntax(inst))
+		default:
+			insns = append(insns, inst.String())
+		}
+	}
+	return strings.Join(insns, "; ")
+}
+
+// renderPPC64 renders the Power instructions in code, located at addr, in
+// GNU syntax.
+func renderPPC64(code []byte, addr uint64) string {
+	var insns []string
+	for offset := 0; offset+ppc64InsnLen <= len(code); offset += ppc64InsnLen {
+		inst, err := ppc64asm.Decode(code[offset:offset+ppc64InsnLen], binary.LittleEndian)
+		if err != nil || inst.Op == 0 {
+			insns = append(insns, fmt.Sprintf(".long 0x%08x", binary.LittleEndian.Uint32(code[offset:])))
+			continue
+		}
+		insns = append(insns, ppc64asm.GNUSyntax(inst, addr+uint64(offset)))
+	}
+	return strings.Join(insns, "; ")
+}
+
+// renderRISCV64 renders the RISC-V instructions in code in GNU syntax.
+// Compressed instructions are rendered as their base equivalents.
+func renderRISCV64(code []byte) string {
+	var insns []string
+	for offset := 0; offset+riscvMinInsnLen <= len(code); {
+		inst, size, err := decodeRISCV64(code, offset)
+		switch {
+		case err == nil:
+			insns = append(insns, riscv64asm.GNUSyntax(inst))
+		case size == riscvMinInsnLen:
+			insns = append(insns, fmt.Sprintf(".half 0x%04x", binary.LittleEndian.Uint16(code[offset:])))
+		case offset+size <= len(code):
+			insns = append(insns, fmt.Sprintf(".word 0x%08x", binary.LittleEndian.Uint32(code[offset:])))
+		}
+		offset += size
+	}
+	return strings.Join(insns, "; ")
+}
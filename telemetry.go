@@ -0,0 +1,167 @@
+package resurgo
+
+import (
+	"debug/elf"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Telemetry reports how DetectFunctionsFromELF reached its result: which
+// signals the file offered, what each stage of the pipeline contributed and
+// how long it took. It is meant for monitoring detection quality across
+// many binaries over time.
+type Telemetry struct {
+	// Signals reports the sources of evidence available in the file.
+	Signals Signals `json:"signals"`
+	// Detectors holds one entry per detector, in pipeline order.
+	Detectors []StageTelemetry `json:"detectors"`
+	// Filters holds one entry per filter, in pipeline order.
+	Filters []StageTelemetry `json:"filters"`
+	// Candidates is the number of candidates returned.
+	Candidates int `json:"candidates"`
+	// ByConfidence counts the returned candidates per confidence level.
+	ByConfidence map[Confidence]int `json:"by_confidence"`
+	// Coverage is the percentage of the code bytes counted by
+	// Signals.TextSize attributed to a returned candidate. A candidate
+	// extends to the end of its FDE when .eh_frame describes it, and to the
+	// next candidate otherwise.
+	Coverage float64 `json:"coverage"`
+	// Elapsed is the wall-clock time of the whole analysis.
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Signals reports the sources of evidence available in an ELF file.
+type Signals struct {
+	// Toolchain is the toolchain fingerprinted by DetectToolchain.
+	Toolchain Toolchain `json:"toolchain"`
+	// TextSize is the size in bytes of the code DisasmDetector scans: the
+	// executable sections, or segments when there are none.
+	TextSize uint64 `json:"text_size"`
+	// FDEs is the number of FDE records decoded from .eh_frame.
+	FDEs int `json:"fdes"`
+	// Symbols reports whether the file carries a .symtab.
+	Symbols bool `json:"symbols"`
+	// DynamicSymbols reports whether the file carries a .dynsym.
+	DynamicSymbols bool `json:"dynamic_symbols"`
+}
+
+// StageTelemetry reports the outcome of one detector or filter.
+type StageTelemetry struct {
+	// Name is the name of the detector or filter function. Functions outside
+	// resurgo are qualified with their package name.
+	Name string `json:"name"`
+	// Candidates is the number of candidates the stage emitted. For a
+	// filter, it is the number of candidates it kept.
+	Candidates int `json:"candidates"`
+	// Dropped is the number of candidates a filter removed.
+	Dropped int `json:"dropped,omitempty"`
+	// Elapsed is the wall-clock time of the stage.
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// WithTelemetry makes DetectFunctionsFromELF fill t with the telemetry of
+// the analysis. t is reset first and is only complete when no error is
+// returned. Other entry points ignore it.
+func WithTelemetry(t *Telemetry) Option {
+	return func(o *options) {
+		o.telemetry = t
+	}
+}
+
+// collectSignals fills t.Signals from f.
+func (t *Telemetry) collectSignals(f *elf.File) []fdeInfo {
+	t.Signals.Toolchain = DetectToolchain(f)
+	for _, r := range codeRanges(f) {
+		t.Signals.TextSize += r[1] - r[0]
+	}
+	t.Signals.Symbols = f.Section(".symtab") != nil
+	t.Signals.DynamicSymbols = f.Section(".dynsym") != nil
+	// Malformed .eh_frame is reported by the pipeline itself.
+	fdes, _ := parseEhFrameFDEs(f)
+	t.Signals.FDEs = len(fdes)
+	return fdes
+}
+
+// summarize fills the result fields of t from the returned candidates.
+func (t *Telemetry) summarize(candidates []FunctionCandidate, f *elf.File, fdes []fdeInfo) {
+	t.Candidates = len(candidates)
+	t.ByConfidence = make(map[Confidence]int)
+	for _, c := range candidates {
+		t.ByConfidence[c.Confidence]++
+	}
+
+	ranges := codeRanges(f)
+	var size uint64
+	for _, r := range ranges {
+		size += r[1] - r[0]
+	}
+	if size == 0 {
+		return
+	}
+	fdeEnd := make(map[uint64]uint64, len(fdes))
+	for _, fde := range fdes {
+		if fde.end > fde.start {
+			fdeEnd[fde.start] = fde.end
+		}
+	}
+	var covered uint64
+	for _, r := range ranges {
+		lo, hi := r[0], r[1]
+		var entries []uint64
+		for _, c := range candidates {
+			if c.Address >= lo && c.Address < hi {
+				entries = append(entries, c.Address)
+			}
+		}
+		slices.Sort(entries)
+		entries = slices.Compact(entries)
+
+		for i, start := range entries {
+			end := hi
+			if i+1 < len(entries) {
+				end = entries[i+1]
+			}
+			if e, ok := fdeEnd[start]; ok {
+				end = min(end, e)
+			}
+			covered += end - start
+		}
+	}
+	t.Coverage = 100 * float64(covered) / float64(size)
+}
+
+// codeRanges returns the [lo, hi) address ranges of the code of f scanned
+// by DisasmDetector: its executable sections, or its executable segments
+// when it has none.
+func codeRanges(f *elf.File) [][2]uint64 {
+	var ranges [][2]uint64
+	for _, sec := range disasmSections(f) {
+		ranges = append(ranges, [2]uint64{sec.Addr, sec.Addr + sec.Size})
+	}
+	for _, p := range execSegments(f) {
+		ranges = append(ranges, [2]uint64{p.Vaddr, p.Vaddr + p.Filesz})
+	}
+	return ranges
+}
+
+// stageName returns the name of the detector or filter function fn, without
+// its import path.
+func stageName(fn any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	if pkg, rest, ok := strings.Cut(name, "."); ok && pkg == "resurgo" {
+		name = rest
+	}
+	// Detectors bound to options, such as the default disassembly detector,
+	// are reported by their exported name.
+	if bound, ok := strings.CutPrefix(name, "(*options)."); ok && bound != "" {
+		name = strings.ToUpper(bound[:1]) + bound[1:]
+	}
+	return name
+}
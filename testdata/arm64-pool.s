@@ -0,0 +1,23 @@
+	// ARM64 code with an embedded literal pool for the ELF mapping symbol
+	// test: llvm-mc -triple=aarch64-linux-gnu -filetype=obj
+	.text
+
+	.globl framed
+	.p2align 2
+framed:
+	stp x29, x30, [sp, #-16]!
+	mov x29, sp
+	ldr x0, pool
+	ldp x29, x30, [sp], #16
+	ret
+
+	// Constants that decode as stp x29, x30, [sp, #-16]!; mov x29, sp.
+pool:
+	.word 0xa9bf7bfd
+	.word 0x910003fd
+
+	.globl leaf
+leaf:
+	sub sp, sp, #0x20
+	add sp, sp, #0x20
+	ret
@@ -0,0 +1,21 @@
+	// x86-64 code that desynchronizes the linear sweep for the hybrid mode
+	// test: llvm-mc -triple=x86_64-linux-gnu -filetype=obj
+	.text
+
+	.globl framed
+	.type framed, @function
+framed:
+	push %rbp
+	mov %rsp, %rbp
+	jmp 1f
+	// A stray byte decoding as call rel32 with the bytes that follow,
+	// whose target is the xor below.
+	.byte 0xe8
+1:
+	adc %al, (%rax)
+	add %al, (%rax)
+	.fill 16, 1, 0x90
+	xor %eax, %eax
+	pop %rbp
+	ret
+	.size framed, .-framed
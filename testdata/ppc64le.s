@@ -0,0 +1,46 @@
+	# ELFv2 functions with one and two entry points, for the ppc64le ELF
+	# test: llvm-mc -triple=powerpc64le-linux-gnu -filetype=obj
+	.abiversion 2
+	.text
+
+	.type caller,@function
+caller:
+0:	addis 2, 12, .TOC.-0b@ha
+	addi 2, 2, .TOC.-0b@l
+	.localentry caller, .-caller
+	mflr 0
+	std 31, -8(1)
+	std 0, 16(1)
+	stdu 1, -48(1)
+	mr 31, 1
+	# The linker resolves calls between functions sharing a TOC to the
+	# local entry point of the callee; the assembler does not.
+	bl leaf+16
+	nop
+	bl helper
+	nop
+	addi 1, 1, 48
+	ld 0, 16(1)
+	mtlr 0
+	ld 31, -8(1)
+	blr
+
+	# The local entry point is 16 bytes in: only the symbol tells.
+	.p2align 4
+	.type leaf,@function
+leaf:
+0:	addis 2, 12, .TOC.-0b@ha
+	addi 2, 2, .TOC.-0b@l
+	nop
+	nop
+	.localentry leaf, .-leaf
+	stdu 1, -32(1)
+	li 3, 0
+	addi 1, 1, 32
+	blr
+
+	.p2align 4
+	.type helper,@function
+helper:
+	li 3, 1
+	blr
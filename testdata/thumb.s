@@ -0,0 +1,35 @@
+	@ Mixed ARM and Thumb code for the ELF mapping symbol test:
+	@ llvm-mc -triple=thumbv7-linux-gnueabihf -filetype=obj
+	.syntax unified
+	.text
+
+	.thumb
+	.thumb_func
+	.globl thumb_fp
+thumb_fp:
+	push {r7, lr}
+	add r7, sp, #0
+	sub sp, #8
+	movs r0, #0
+	add sp, #8
+	pop {r7, pc}
+
+	.thumb_func
+	.globl thumb_lr
+thumb_lr:
+	push.w {r4-r11, lr}
+	cmp r0, #0
+	it eq
+	popeq {r4-r11, pc}
+	ldr r0, =0x12345678
+	pop.w {r4-r11, pc}
+	.ltorg
+
+	.p2align 2
+	.arm
+	.globl arm_fp
+arm_fp:
+	push {fp, lr}
+	add fp, sp, #4
+	mov r0, #0
+	pop {fp, pc}
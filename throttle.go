@@ -0,0 +1,87 @@
+package resurgo
+
+import "time"
+
+// defaultIdlePoll is the interval at which Throttle.Idle is polled while
+// the analysis is paused, unless Throttle.IdlePoll is set.
+const defaultIdlePoll = 100 * time.Millisecond
+
+// Throttle paces an analysis so that agents running on production hosts do
+// not cause CPU spikes. Zero fields apply no throttling. Time spent paused
+// counts toward Limits.Timeout.
+type Throttle struct {
+	// BytesPerSecond caps the rate at which code is scanned, summed over
+	// all stages: each stage of the disassembly-based analysis scans the
+	// code once.
+	BytesPerSecond uint64
+	// YieldEvery yields the processor to other goroutines every YieldEvery
+	// decode iterations.
+	YieldEvery int
+	// Idle, when set, is a scheduling hint polled during the analysis: while
+	// it reports false, e.g. because the host is busy, the analysis pauses.
+	Idle func() bool
+	// IdlePoll is the interval at which Idle is polled while paused. The
+	// default is 100ms.
+	IdlePoll time.Duration
+}
+
+// WithThrottle paces the disassembly-based analysis according to t. Pacing
+// is checked every 1024 decode iterations.
+func WithThrottle(t Throttle) Option {
+	return func(o *options) {
+		o.throttle = t
+	}
+}
+
+// enabled reports whether t throttles at all.
+func (t Throttle) enabled() bool {
+	return t.BytesPerSecond > 0 || t.YieldEvery > 0 || t.Idle != nil
+}
+
+// pacer tracks the bytes scanned by an analysis. Stages report their
+// position within the code they scan; a position lower than the previous
+// one starts a new stage.
+type pacer struct {
+	start   time.Time
+	scanned uint64
+	pos     uint64
+}
+
+// advance records that the current stage reached byte offset pos.
+func (p *pacer) advance(pos int) {
+	if uint64(pos) < p.pos {
+		p.scanned += p.pos
+	}
+	p.pos = uint64(pos)
+}
+
+// total returns the bytes scanned so far.
+func (p *pacer) total() uint64 {
+	return p.scanned + p.pos
+}
+
+// pace blocks while the throttle asks the analysis to wait: until the host
+// is idle, then until the scan rate is back under its cap. It returns early
+// once the deadline has passed.
+func (b *budget) pace() {
+	t := b.throttle
+	if t.Idle != nil {
+		poll := t.IdlePoll
+		if poll <= 0 {
+			poll = defaultIdlePoll
+		}
+		for !t.Idle() && b.expired() == nil {
+			time.Sleep(poll)
+		}
+	}
+	if t.BytesPerSecond > 0 {
+		due := time.Duration(float64(b.pacer.total()) / float64(t.BytesPerSecond) * float64(time.Second))
+		wait := due - time.Since(b.pacer.start)
+		if !b.deadline.IsZero() {
+			wait = min(wait, time.Until(b.deadline))
+		}
+		if wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+}
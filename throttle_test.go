@@ -0,0 +1,64 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestWithThrottle(t *testing.T) {
+	// push rbp; mov rbp, rsp; call +0; ret - repeated, 64000 bytes.
+	code := bytes.Repeat([]byte{0x55, 0x48, 0x89, 0xe5, 0xe8, 0x00, 0x00, 0x00, 0x00, 0xc3}, 6400)
+	want, err := resurgo.DetectPrologues(code, 0, resurgo.ArchAMD64)
+	if err != nil {
+		t.Fatalf("resurgo.DetectPrologues: %v", err)
+	}
+
+	t.Run("rate", func(t *testing.T) {
+		start := time.Now()
+		got, err := resurgo.DetectPrologues(code, 0, resurgo.ArchAMD64,
+			resurgo.WithThrottle(resurgo.Throttle{BytesPerSecond: 128 << 10, YieldEvery: 64}))
+		if err != nil {
+			t.Fatalf("resurgo.DetectPrologues: %v", err)
+		}
+		// Pacing is checked every 1024 iterations, so the tail of the scan
+		// is not accounted for.
+		if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+			t.Errorf("scanned 64000 bytes at 128KiB/s in %s", elapsed)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Error("throttling changed the result")
+		}
+	})
+
+	t.Run("idle", func(t *testing.T) {
+		polls := 0
+		got, err := resurgo.DetectPrologues(code, 0, resurgo.ArchAMD64, resurgo.WithThrottle(resurgo.Throttle{
+			Idle:     func() bool { polls++; return polls > 5 },
+			IdlePoll: time.Millisecond,
+		}))
+		if err != nil {
+			t.Fatalf("resurgo.DetectPrologues: %v", err)
+		}
+		if polls <= 5 {
+			t.Errorf("Idle polled %d times, want more than 5", polls)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Error("throttling changed the result")
+		}
+	})
+
+	t.Run("never idle", func(t *testing.T) {
+		_, err := resurgo.DetectPrologues(code, 0, resurgo.ArchAMD64,
+			resurgo.WithThrottle(resurgo.Throttle{Idle: func() bool { return false }, IdlePoll: time.Millisecond}),
+			resurgo.WithLimits(resurgo.Limits{Timeout: 20 * time.Millisecond}))
+		var limitErr *resurgo.LimitError
+		if !errors.As(err, &limitErr) || limitErr.Kind != resurgo.LimitTimeout {
+			t.Fatalf("got %v, want a %s limit error", err, resurgo.LimitTimeout)
+		}
+	})
+}
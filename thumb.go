@@ -0,0 +1,451 @@
+package resurgo
+
+import (
+	"cmp"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+	"strings"
+
+	"golang.org/x/arch/arm/armasm"
+)
+
+// golang.org/x/arch decodes A32 only, so the T32 (Thumb-2) instructions the
+// detectors rely on are matched on their raw halfwords.
+
+// thumbInsn is a T32 instruction: a single halfword, or two for the 32-bit
+// encodings.
+type thumbInsn struct {
+	hw1, hw2 uint16
+	len      int
+}
+
+// decodeThumb returns the T32 instruction at code[offset]. ok is false when a
+// 32-bit encoding is truncated by the end of code.
+func decodeThumb(code []byte, offset int) (in thumbInsn, ok bool) {
+	in = thumbInsn{hw1: binary.LittleEndian.Uint16(code[offset:]), len: 2}
+	// 32-bit encodings start with 0b11101, 0b11110 or 0b11111.
+	if in.hw1>>11 < 0x1d {
+		return in, true
+	}
+	if offset+4 > len(code) {
+		return in, false
+	}
+	in.hw2 = binary.LittleEndian.Uint16(code[offset+2:])
+	in.len = 4
+	return in, true
+}
+
+func (in thumbInsn) wide() bool { return in.len == 4 }
+
+// pushList returns the register list of push {...}, push.w {...} (stmdb sp!)
+// and str.w rt, [sp, #-4]!, the single-register push.w.
+func (in thumbInsn) pushList() (armasm.RegList, bool) {
+	switch {
+	case !in.wide() && in.hw1&0xfe00 == 0xb400:
+		// The M bit stands for lr.
+		return armasm.RegList(in.hw1&0xff | (in.hw1&0x100)<<6), true
+	case in.wide() && in.hw1 == 0xe92d && in.hw2&0xa000 == 0:
+		return armasm.RegList(in.hw2), true
+	case in.wide() && in.hw1 == 0xf84d && in.hw2&0x0fff == 0x0d04:
+		return armasm.RegList(1 << (in.hw2 >> 12)), true
+	}
+	return 0, false
+}
+
+// strLRPreIndex returns N for str.w lr, [sp, #-N]!.
+func (in thumbInsn) strLRPreIndex() (uint64, bool) {
+	if list, ok := in.pushList(); ok && list == armRegListLR {
+		return 4, true
+	}
+	if in.wide() && in.hw1 == 0xf84d && in.hw2&0xff00 == 0xed00 {
+		return uint64(in.hw2 & 0xff), true
+	}
+	return 0, false
+}
+
+// imm12 returns the i:imm3:imm8 immediate of 32-bit data-processing
+// encodings.
+func (in thumbInsn) imm12() uint32 {
+	return uint32(in.hw1>>10&1)<<11 | uint32(in.hw2>>12&7)<<8 | uint32(in.hw2&0xff)
+}
+
+// spAlloc returns the immediate of sub sp, sp, #imm: the 16-bit form,
+// sub.w with a modified immediate and subw with a 12-bit one.
+func (in thumbInsn) spAlloc() (uint64, bool) {
+	var imm uint32
+	switch {
+	case !in.wide() && in.hw1&0xff80 == 0xb080:
+		imm = uint32(in.hw1&0x7f) << 2
+	case in.wide() && in.hw1&0xfbff == 0xf1ad && in.hw2&0x8f00 == 0x0d00:
+		imm = thumbExpandImm(in.imm12())
+	case in.wide() && in.hw1&0xfbff == 0xf2ad && in.hw2&0x8f00 == 0x0d00:
+		imm = in.imm12()
+	}
+	return uint64(imm), imm > 0
+}
+
+// framePointerSetup returns the register and offset of the frame pointer
+// setup add rd, sp, #imm or mov rd, sp, for r7 (GCC and Clang in Thumb code)
+// and fp (r11, AAPCS).
+func (in thumbInsn) framePointerSetup() (reg string, imm uint32, ok bool) {
+	switch {
+	case !in.wide() && in.hw1&0xff00 == 0xaf00:
+		return "r7", uint32(in.hw1&0xff) << 2, true
+	case !in.wide() && in.hw1 == 0x466f:
+		return "r7", 0, true
+	case !in.wide() && in.hw1 == 0x46eb:
+		return "fp", 0, true
+	case in.wide() && in.hw1&0xfbff == 0xf10d && in.hw2&0x8000 == 0:
+		if rd := in.hw2 >> 8 & 0xf; rd == 7 || rd == 11 {
+			return armRegNames[rd], thumbExpandImm(in.imm12()), true
+		}
+	}
+	return "", 0, false
+}
+
+// isBenign reports whether in is nop, nop.w or mov r8, r8.
+func (in thumbInsn) isBenign() bool {
+	if in.wide() {
+		return in.hw1 == 0xf3af && in.hw2 == 0x8000
+	}
+	return in.hw1 == 0xbf00 || in.hw1 == 0x46c0
+}
+
+// itBlock returns the number of instructions made conditional by an IT
+// instruction, or 0 if in is not one.
+func (in thumbInsn) itBlock() int {
+	if in.wide() || in.hw1&0xff00 != 0xbf00 || in.hw1&0xf == 0 {
+		return 0
+	}
+	return 4 - bits.TrailingZeros16(in.hw1&0xf)
+}
+
+// thumbExpandImm expands the modified immediate of 32-bit data-processing
+// encodings: a byte replicated over the word, or rotated into place.
+func thumbExpandImm(imm12 uint32) uint32 {
+	if imm12>>10 == 0 {
+		b := imm12 & 0xff
+		switch imm12 >> 8 & 3 {
+		case 0:
+			return b
+		case 1:
+			return b<<16 | b
+		case 2:
+			return b<<24 | b<<8
+		default:
+			return b * 0x01010101
+		}
+	}
+	return bits.RotateLeft32(0x80|imm12&0x7f, -int(imm12>>7))
+}
+
+// thumbEntrySeq is armEntrySeq for T32 code.
+type thumbEntrySeq struct {
+	armEntrySeq
+	// setsFP reports whether the sequence sets the frame pointer up.
+	setsFP bool
+}
+
+// scanEntryThumb collects the T32 entry sequence starting with the push at
+// code[offset]: the push, an optional frame pointer setup (add r7, sp, #imm
+// or mov r7, sp) and an optional stack allocation. nops may be interleaved.
+func scanEntryThumb(code []byte, offset int, push thumbInsn, list armasm.RegList) thumbEntrySeq {
+	names := armRegListNames(list)
+	seq := thumbEntrySeq{armEntrySeq: armEntrySeq{
+		insns:     []string{fmt.Sprintf("push {%s}", strings.Join(names, ", "))},
+		savedRegs: names,
+		frame:     uint64(4 * len(names)),
+		end:       offset + push.len,
+	}}
+	for n, off := 0, seq.end; n < maxSavedRegScan && off+2 <= len(code); n++ {
+		in, ok := decodeThumb(code, off)
+		if !ok {
+			break
+		}
+		off += in.len
+		if in.isBenign() {
+			continue
+		}
+		if reg, imm, ok := in.framePointerSetup(); ok {
+			seq.insns = append(seq.insns, fmt.Sprintf("add %s, sp, #%d", reg, imm))
+			seq.setsFP = true
+			seq.end = off
+			continue
+		}
+		if size, ok := in.spAlloc(); ok {
+			seq.insns = append(seq.insns, fmt.Sprintf("sub sp, sp, #%d", size))
+			seq.frame += size
+			seq.end = off
+		}
+		break
+	}
+	return seq
+}
+
+// detectProloguesThumb matches T32 function prologues, the Thumb forms of
+// the A32 ones:
+//
+//	push {..., r7, lr}    push-fp-lr, followed by add r7, sp, #N
+//	push {..., lr}        push-lr
+//	str.w lr, [sp, #-N]!  str-lr-preindex, at a function boundary
+//	sub  sp, #N           sub-sp, at a function boundary
+//
+// Instructions made conditional by an IT block are not considered.
+func (o *options) detectProloguesThumb(code []byte, baseAddr uint64) ([]Prologue, error) {
+	var result []Prologue
+
+	// consumedUntil is the end offset of the last collapsed entry sequence.
+	// Patterns matching inside it are fragments of that sequence.
+	consumedUntil := 0
+	// itLeft is the number of instructions left in the current IT block.
+	itLeft := 0
+	hist := newInsnHistory(o.lookbehind())
+
+	for offset := 0; offset+2 <= len(code); {
+		if err := o.budget.step(offset); err != nil {
+			return nil, err
+		}
+		addr := baseAddr + uint64(offset)
+		in, ok := decodeThumb(code, offset)
+		if !ok {
+			break
+		}
+		atBoundary := func(typ PrologueType) bool {
+			return o.atBoundary(hist, addr, typ)
+		}
+
+		if itLeft > 0 {
+			itLeft--
+			hist.push(addr, InsnClassOther)
+			offset += in.len
+			continue
+		}
+		itLeft = in.itBlock()
+
+		if offset >= consumedUntil {
+			// Pattern 1: push {..., lr}, a frame record when the frame
+			// pointer is set up next.
+			if list, ok := in.pushList(); ok && list&armRegListLR != 0 && list != armRegListLR {
+				seq := scanEntryThumb(code, offset, in, list)
+				typ := ProloguePushLR
+				if seq.setsFP {
+					typ = ProloguePushFPLR
+				}
+				result = append(result, Prologue{
+					Address:      addr,
+					Type:         typ,
+					Instructions: strings.Join(seq.insns, "; "),
+					FrameSize:    seq.frame,
+					Size:         uint64(seq.end - offset),
+					SavedRegs:    seq.savedRegs,
+				})
+				consumedUntil = seq.end
+			}
+
+			// Pattern 2: str.w lr, [sp, #-N]!
+			if frame, ok := in.strLRPreIndex(); ok && atBoundary(PrologueSTRLRPreIndex) {
+				result = append(result, Prologue{
+					Address:      addr,
+					Type:         PrologueSTRLRPreIndex,
+					Instructions: fmt.Sprintf("str lr, [sp, #-%d]!", frame),
+					FrameSize:    frame,
+					Size:         uint64(in.len),
+					SavedRegs:    []string{"lr"},
+				})
+			}
+
+			// Pattern 3: sub sp, sp, #N
+			if size, ok := in.spAlloc(); ok && atBoundary(PrologueSubSP) {
+				result = append(result, Prologue{
+					Address:      addr,
+					Type:         PrologueSubSP,
+					Instructions: fmt.Sprintf("sub sp, sp, #%d", size),
+					FrameSize:    size,
+					Size:         uint64(in.len),
+				})
+			}
+		}
+
+		hist.push(addr, classifyThumb(in))
+		offset += in.len
+	}
+
+	return result, nil
+}
+
+// classifyThumb returns the boundary-context class of a T32 instruction
+// outside an IT block.
+func classifyThumb(in thumbInsn) InsnClass {
+	hw1, hw2 := in.hw1, in.hw2
+	if !in.wide() {
+		switch {
+		case hw1 == 0x4770, hw1&0xff00 == 0xbd00: // bx lr, pop {..., pc}
+			return InsnClassReturn
+		case hw1&0xf800 == 0xe000, hw1&0xff87 == 0x4700: // b, bx rm
+			return InsnClassJump
+		case hw1&0xff00 == 0xde00, hw1&0xff00 == 0xbe00: // udf, bkpt
+			return InsnClassTrap
+		}
+	} else {
+		switch {
+		case hw1 == 0xe8bd && hw2&0x8000 != 0, hw1 == 0xf85d && hw2 == 0xfb04: // pop.w {..., pc}, ldr.w pc, [sp], #4
+			return InsnClassReturn
+		case hw1&0xf800 == 0xf000 && hw2&0xd000 == 0x9000: // b.w
+			return InsnClassJump
+		case hw1&0xfff0 == 0xf7f0 && hw2&0xf000 == 0xa000: // udf.w
+			return InsnClassTrap
+		}
+	}
+	if in.isBenign() {
+		return InsnClassPadding
+	}
+	return InsnClassOther
+}
+
+// thumbBranchOffset returns the offset encoded by the 32-bit bl, blx and
+// b.w: SignExtend(S:I1:I2:imm10:imm11:0), where I1 = NOT(J1 XOR S) and
+// I2 = NOT(J2 XOR S).
+func thumbBranchOffset(hw1, hw2 uint16) int64 {
+	s := uint32(hw1 >> 10 & 1)
+	i1 := ^(uint32(hw2>>13&1) ^ s) & 1
+	i2 := ^(uint32(hw2>>11&1) ^ s) & 1
+	imm := s<<24 | i1<<23 | i2<<22 | uint32(hw1&0x3ff)<<12 | uint32(hw2&0x7ff)<<1
+	return int64(int32(imm<<7) >> 7)
+}
+
+// thumbCondBranchOffset returns the offset encoded by the 32-bit b<c>.w:
+// SignExtend(S:J2:J1:imm6:imm11:0).
+func thumbCondBranchOffset(hw1, hw2 uint16) int64 {
+	imm := uint32(hw1>>10&1)<<20 | uint32(hw2>>11&1)<<19 | uint32(hw2>>13&1)<<18 |
+		uint32(hw1&0x3f)<<12 | uint32(hw2&0x7ff)<<1
+	return int64(int32(imm<<11) >> 11)
+}
+
+// detectCallSitesThumb scans T32 code for bl, blx <label> and the 16- and
+// 32-bit b and b<c>. Branch targets are relative to the address of the
+// instruction plus 4; blx <label> calls A32 code at a word-aligned target.
+// Targets wrap around the 32-bit address space.
+func detectCallSitesThumb(code []byte, baseAddr uint64, wrap bool, b *budget) ([]CallSiteEdge, error) {
+	var result []CallSiteEdge
+
+	for offset := 0; offset+2 <= len(code); {
+		if err := b.step(offset); err != nil {
+			return nil, err
+		}
+		in, ok := decodeThumb(code, offset)
+		if !ok {
+			break
+		}
+		addr := baseAddr + uint64(offset)
+		offset += in.len
+
+		pc := addr + 4
+		edge := CallSiteEdge{
+			SourceAddr:  addr,
+			Type:        CallSiteJump,
+			AddressMode: AddressingModePCRelative,
+			Confidence:  ConfidenceMedium,
+		}
+		var disp int64
+		hw1, hw2 := in.hw1, in.hw2
+		switch {
+		case in.wide() && hw1&0xf800 == 0xf000 && hw2&0xd000 == 0xd000: // bl
+			edge.Type, edge.Confidence = CallSiteCall, ConfidenceHigh
+			disp = thumbBranchOffset(hw1, hw2)
+		case in.wide() && hw1&0xf800 == 0xf000 && hw2&0xd001 == 0xc000: // blx
+			edge.Type, edge.Confidence = CallSiteCall, ConfidenceHigh
+			pc &^= 3
+			disp = thumbBranchOffset(hw1, hw2)
+		case in.wide() && hw1&0xf800 == 0xf000 && hw2&0xd000 == 0x9000: // b.w
+			disp = thumbBranchOffset(hw1, hw2)
+		case in.wide() && hw1&0xf800 == 0xf000 && hw2&0xd000 == 0x8000 && hw1>>6&0xe != 0xe: // b<c>.w
+			edge.Confidence = ConfidenceLow
+			disp = thumbCondBranchOffset(hw1, hw2)
+		case !in.wide() && hw1&0xf800 == 0xe000: // b
+			disp = int64(int32(uint32(hw1)<<21) >> 20)
+		case !in.wide() && hw1&0xf000 == 0xd000 && hw1>>8&0xe != 0xe: // b<c>
+			edge.Confidence = ConfidenceLow
+			disp = int64(int8(hw1)) << 1
+		default:
+			continue
+		}
+		target, ok := relTarget(pc, disp, wrap)
+		if target > math.MaxUint32 {
+			ok = wrap
+			target &= math.MaxUint32
+		}
+		if !ok {
+			continue
+		}
+		edge.TargetAddr = target
+		result = append(result, edge)
+	}
+
+	return result, nil
+}
+
+// armCodeSections splits the ARM code of sec, read into code, into A32 and
+// T32 regions along the ELF mapping symbols: $a starts A32 code, $t T32 code
+// and $d data, which is skipped. Code before the first mapping symbol, or all
+// of it when there is none, e.g. in stripped binaries, is assumed to be in
+// the instruction set of the entry point, whose low bit is set for Thumb.
+// ARM64 code is split the same way along $x, which starts A64 code, and $d,
+// so that literal pools and jump tables in the section are not decoded.
+func armCodeSections(f *elf.File, sec *elf.Section, code []byte) []codeSection {
+	type mark struct {
+		addr uint64
+		arch Arch
+	}
+	arch := ArchARM
+	switch {
+	case f.Machine == elf.EM_AARCH64:
+		arch = ArchARM64
+	case f.Entry&1 != 0:
+		arch = ArchThumb
+	}
+	marks := []mark{{sec.Addr, arch}}
+
+	idx := slices.Index(f.Sections, sec)
+	syms, _ := f.Symbols()
+	for _, sym := range syms {
+		if int(sym.Section) != idx || elf.ST_TYPE(sym.Info) != elf.STT_NOTYPE ||
+			sym.Value < sec.Addr || sym.Value >= sec.Addr+uint64(len(code)) {
+			continue
+		}
+		name, _, _ := strings.Cut(sym.Name, ".")
+		switch {
+		case name == "$a" && arch != ArchARM64:
+			marks = append(marks, mark{sym.Value, ArchARM})
+		case name == "$t" && arch != ArchARM64:
+			marks = append(marks, mark{sym.Value, ArchThumb})
+		case name == "$x" && arch == ArchARM64:
+			marks = append(marks, mark{sym.Value, ArchARM64})
+		case name == "$d":
+			marks = append(marks, mark{sym.Value, ""})
+		}
+	}
+	// The implicit mark at the section start sorts first; later marks at
+	// the same address override it.
+	slices.SortStableFunc(marks, func(a, b mark) int { return cmp.Compare(a.addr, b.addr) })
+
+	var sections []codeSection
+	for i, m := range marks {
+		end := sec.Addr + uint64(len(code))
+		if i+1 < len(marks) {
+			end = marks[i+1].addr
+		}
+		if m.arch == "" || end <= m.addr {
+			continue
+		}
+		sections = append(sections, codeSection{
+			code: code[m.addr-sec.Addr : end-sec.Addr],
+			addr: m.addr,
+			arch: m.arch,
+		})
+	}
+	return sections
+}
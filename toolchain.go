@@ -0,0 +1,161 @@
+package resurgo
+
+import (
+	"bytes"
+	"debug/elf"
+	"slices"
+	"strings"
+)
+
+const (
+	// Supported toolchain profiles.
+	ToolchainGeneric Toolchain = "generic"
+	ToolchainAuto    Toolchain = "auto"
+	ToolchainGCC     Toolchain = "gcc"
+	ToolchainClang   Toolchain = "clang"
+	ToolchainGo      Toolchain = "go"
+	ToolchainMSVC    Toolchain = "msvc"
+	ToolchainRust    Toolchain = "rust"
+)
+
+// Toolchain selects a heuristic profile tuned to the code generation of a
+// compiler toolchain.
+type Toolchain string
+
+// toolchainProfile describes how a toolchain deviates from the generic
+// heuristics.
+type toolchainProfile struct {
+	// enabled lists the toolchain-specific patterns turned on.
+	enabled []PrologueType
+	// disabled lists the generic patterns the toolchain never emits.
+	disabled []PrologueType
+	// paddingBoundary accepts NOP padding before a boundary-gated prologue:
+	// the toolchain aligns function entries with NOP fill.
+	paddingBoundary bool
+}
+
+// profileOnlyPrologues lists the patterns that are only detected when a
+// toolchain profile enables them.
+var profileOnlyPrologues = []PrologueType{
+	PrologueGoStackCheck,
+	PrologueHomeSpill,
+	PrologueRustProbestack,
+	PrologueFmtThunk,
+}
+
+var toolchainProfiles = map[Toolchain]toolchainProfile{
+	ToolchainGCC: {
+		disabled:        []PrologueType{PrologueEnter},
+		paddingBoundary: true,
+	},
+	ToolchainClang: {
+		disabled:        []PrologueType{PrologueEnter},
+		paddingBoundary: true,
+	},
+	// Rust calls a stack probe before allocating large frames and forwards
+	// core::fmt trait calls through references with one-jump thunks.
+	ToolchainRust: {
+		enabled:         []PrologueType{PrologueRustProbestack, PrologueFmtThunk},
+		disabled:        []PrologueType{PrologueEnter},
+		paddingBoundary: true,
+	},
+	// Go has no callee-saved registers besides the frame pointer and never
+	// realigns the stack. Its INT3 padding between functions is accepted by
+	// the trap boundaries of WithTrapBoundaries, not by paddingBoundary.
+	ToolchainGo: {
+		enabled: []PrologueType{PrologueGoStackCheck},
+		disabled: []PrologueType{
+			ProloguePushOnly,
+			PrologueLEABased,
+			PrologueStackRealign,
+			PrologueEnter,
+			PrologueSTPCalleeSaved,
+		},
+	},
+	// MSVC spills register arguments to their home space. Its INT3 padding
+	// between functions is accepted by the trap boundaries of
+	// WithTrapBoundaries, not by paddingBoundary.
+	ToolchainMSVC: {
+		enabled:  []PrologueType{PrologueHomeSpill},
+		disabled: []PrologueType{PrologueEnter},
+	},
+}
+
+// WithToolchain selects the heuristic profile of toolchain t. Profiles turn
+// on toolchain-specific patterns (Go's stack-bound check, MSVC's home-space
+// spills, Rust's stack probe and formatting thunks), turn off patterns the
+// toolchain never emits and tune the boundary rules. ToolchainAuto
+// fingerprints the compiler from the ELF file and falls back to
+// ToolchainGeneric, the default, when it cannot be identified or no file is
+// available.
+func WithToolchain(t Toolchain) Option {
+	return func(o *options) {
+		o.toolchain = t
+	}
+}
+
+// prologueEnabled reports whether the selected profile reports typ. All
+// patterns are enabled under PresetPermissive.
+func (o *options) prologueEnabled(typ PrologueType) bool {
+	if o.allPrologues {
+		return true
+	}
+	profile := toolchainProfiles[o.toolchain]
+	if slices.Contains(profile.disabled, typ) {
+		return false
+	}
+	if slices.Contains(profileOnlyPrologues, typ) {
+		return slices.Contains(profile.enabled, typ)
+	}
+	return true
+}
+
+// forFile returns o with ToolchainAuto resolved against f.
+func (o *options) forFile(f *elf.File) *options {
+	if o.toolchain != ToolchainAuto {
+		return o
+	}
+	resolved := *o
+	resolved.toolchain = DetectToolchain(f)
+	return &resolved
+}
+
+// DetectToolchain fingerprints the toolchain that produced f. Go binaries
+// are recognized by their runtime metadata sections; C, C++ and Rust
+// binaries by the producer strings compilers record in .comment. Rust and
+// Clang are checked first because their binaries also carry the GCC strings
+// of the C runtime objects. It returns ToolchainGeneric when no fingerprint
+// matches.
+func DetectToolchain(f *elf.File) Toolchain {
+	for _, name := range []string{".go.buildinfo", ".gopclntab", ".note.go.buildid"} {
+		if f.Section(name) != nil {
+			return ToolchainGo
+		}
+	}
+	sec := f.Section(".comment")
+	if sec == nil {
+		return ToolchainGeneric
+	}
+	data, err := sectionData(sec)
+	if err != nil {
+		return ToolchainGeneric
+	}
+	var producers []string
+	for _, s := range bytes.Split(data, []byte{0}) {
+		producers = append(producers, string(s))
+	}
+	match := func(substr string) bool {
+		return slices.ContainsFunc(producers, func(p string) bool {
+			return strings.Contains(p, substr)
+		})
+	}
+	switch {
+	case match("rustc version"):
+		return ToolchainRust
+	case match("clang version"):
+		return ToolchainClang
+	case match("GCC:"):
+		return ToolchainGCC
+	}
+	return ToolchainGeneric
+}
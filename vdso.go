@@ -0,0 +1,99 @@
+package resurgo
+
+import (
+	"bufio"
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// vdsoMapping is the name /proc/pid/maps gives to the mapping of the vDSO.
+const vdsoMapping = "[vdso]"
+
+// DetectFunctionsFromVDSO returns the function candidates of the vDSO
+// mapped into the running process pid, or into the calling process if pid
+// is 0, as DetectFunctionsFromELF does with opts. The vDSO is a shared
+// library the kernel maps into every process without a file behind it; its
+// image is located through /proc/pid/maps and read from /proc/pid/mem,
+// which requires the permission to trace the process. The candidates carry
+// their runtime addresses in the process.
+func DetectFunctionsFromVDSO(pid int, opts ...Option) ([]FunctionCandidate, error) {
+	proc := "/proc/self"
+	if pid != 0 {
+		proc = "/proc/" + strconv.Itoa(pid)
+	}
+	start, end, perms, err := vdsoRange(proc + "/maps")
+	if err != nil {
+		return nil, err
+	}
+	mem, err := os.Open(proc + "/mem")
+	if err != nil {
+		return nil, err
+	}
+	defer mem.Close()
+	image := make([]byte, end-start)
+	if _, err := mem.ReadAt(image, int64(start)); err != nil {
+		return nil, fmt.Errorf("read vDSO at %#x: %w", start, err)
+	}
+	f, err := elf.NewFile(bytes.NewReader(image))
+	if err != nil {
+		return nil, fmt.Errorf("parse vDSO: %w", err)
+	}
+	// The mapping starts with the ELF header, at file offset 0.
+	bias, err := LoadBias(f, start, 0, perms)
+	if err != nil {
+		return nil, fmt.Errorf("vDSO: %w", err)
+	}
+	return DetectFunctionsFromELF(f, append(slices.Clone(opts), WithLoadBias(bias))...)
+}
+
+// DetectFunctionsFromVDSOImage returns the function candidates of image, a
+// vDSO image, e.g. dumped from a process or extracted from a kernel build
+// (vdso64.so), as DetectFunctionsFromELF does with opts. Unless opts
+// include WithLoadBias, the candidates carry the addresses the vDSO is
+// linked at.
+func DetectFunctionsFromVDSOImage(image []byte, opts ...Option) ([]FunctionCandidate, error) {
+	f, err := elf.NewFile(bytes.NewReader(image))
+	if err != nil {
+		return nil, fmt.Errorf("parse vDSO: %w", err)
+	}
+	return DetectFunctionsFromELF(f, opts...)
+}
+
+// vdsoRange returns the [start, end) address range and the permissions of
+// the vDSO mapping listed in the /proc/pid/maps file at path.
+func vdsoRange(path string) (start, end uint64, perms string, err error) {
+	maps, err := os.Open(path)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	defer maps.Close()
+	scanner := bufio.NewScanner(maps)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || fields[5] != vdsoMapping {
+			continue
+		}
+		lo, hi, ok := strings.Cut(fields[0], "-")
+		if !ok {
+			return 0, 0, "", fmt.Errorf("malformed mapping %q in %s", fields[0], path)
+		}
+		start, err = strconv.ParseUint(lo, 16, 64)
+		if err != nil {
+			return 0, 0, "", fmt.Errorf("malformed mapping %q in %s: %w", fields[0], path, err)
+		}
+		end, err = strconv.ParseUint(hi, 16, 64)
+		if err != nil || end <= start {
+			return 0, 0, "", fmt.Errorf("malformed mapping %q in %s", fields[0], path)
+		}
+		return start, end, fields[1], nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, "", fmt.Errorf("read %s: %w", path, err)
+	}
+	return 0, 0, "", fmt.Errorf("no vDSO mapping in %s", path)
+}
@@ -0,0 +1,102 @@
+package resurgo_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestDetectFunctionsFromVDSO(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("vDSO is only mapped on Linux")
+	}
+	maps, err := os.ReadFile("/proc/self/maps")
+	if err != nil {
+		t.Skipf("read /proc/self/maps: %v", err)
+	}
+	var start, end uint64
+	var perms string
+	for _, line := range strings.Split(string(maps), "\n") {
+		if strings.HasSuffix(line, "[vdso]") {
+			fields := strings.Fields(line)
+			perms = fields[1]
+			lo, hi, _ := strings.Cut(fields[0], "-")
+			start, _ = strconv.ParseUint(lo, 16, 64)
+			end, _ = strconv.ParseUint(hi, 16, 64)
+		}
+	}
+	if start == 0 {
+		t.Skip("no vDSO mapping")
+	}
+
+	candidates, err := resurgo.DetectFunctionsFromVDSO(0)
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromVDSO: %v", err)
+	}
+	if len(candidates) == 0 {
+		t.Fatal("no function candidates in the vDSO")
+	}
+	got := make(map[uint64]bool)
+	for _, c := range candidates {
+		if c.Address < start || c.Address >= end {
+			t.Errorf("candidate 0x%x outside the vDSO mapping [0x%x, 0x%x)", c.Address, start, end)
+		}
+		got[c.Address] = true
+	}
+
+	// The exported functions of the vDSO are found at their runtime
+	// addresses.
+	mem, err := os.Open("/proc/self/mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	image := make([]byte, end-start)
+	if _, err := mem.ReadAt(image, int64(start)); err != nil {
+		t.Fatalf("read vDSO: %v", err)
+	}
+	f, err := elf.NewFile(bytes.NewReader(image))
+	if err != nil {
+		t.Fatalf("parse vDSO: %v", err)
+	}
+	bias, err := resurgo.LoadBias(f, start, 0, perms)
+	if err != nil {
+		t.Fatalf("LoadBias: %v", err)
+	}
+	syms, err := f.DynamicSymbols()
+	if err != nil {
+		t.Fatalf("read dynamic symbols: %v", err)
+	}
+	var exported int
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) != elf.STT_FUNC || s.Value == 0 {
+			continue
+		}
+		exported++
+		if !got[s.Value+bias] {
+			t.Errorf("%s at 0x%x not detected", s.Name, s.Value+bias)
+		}
+	}
+	if exported == 0 {
+		t.Fatal("the vDSO exports no function")
+	}
+
+	linked, err := resurgo.DetectFunctionsFromVDSOImage(image)
+	if err != nil {
+		t.Fatalf("DetectFunctionsFromVDSOImage: %v", err)
+	}
+	if len(linked) != len(candidates) {
+		t.Fatalf("got %d candidates from the image, %d from the process", len(linked), len(candidates))
+	}
+	for i, c := range linked {
+		if c.Address+bias != candidates[i].Address {
+			t.Errorf("image candidate 0x%x, want 0x%x", c.Address, candidates[i].Address-bias)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package resurgo
+
+import (
+	"cmp"
+	"debug/elf"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// DetectionVtable is assigned to function candidates read from the
+	// function pointer slots of a C++ virtual table.
+	DetectionVtable DetectionType = "vtable"
+
+	// maxOffsetToTop bounds the magnitude of the offset-to-top field of a
+	// vtable: the offset of a base class subobject within its object.
+	maxOffsetToTop = 1 << 20
+)
+
+// VtableDetector is a CandidateDetector that emits the targets of the
+// virtual function slots of C++ vtables found in the data sections of f
+// (.data.rel.ro, .rodata, .data). Virtual methods are usually only called
+// indirectly, so call-site analysis misses them. Dynamic relocations are
+// applied first, since position-independent binaries store vtable slots as
+// relative relocations. Each candidate carries DetectionVtable and
+// ConfidenceMedium.
+//
+// An Itanium C++ ABI vtable is recognized by its layout:
+//
+//	offset-to-top   ; zero or a small negative displacement
+//	typeinfo        ; zero (-fno-rtti), a data pointer or a symbol reference
+//	slot 0..n       ; pointers into executable sections
+//
+// Slots relocated against a symbol, such as __cxa_pure_virtual, are part of
+// the table but emit no candidate. Other tables of function pointers with
+// the same layout are reported too. Go binaries are skipped. It is not part
+// of the default pipeline.
+func VtableDetector(f *elf.File) ([]FunctionCandidate, error) {
+	// Go binaries have no vtables, and their read-only data holds tables of
+	// code addresses with the same layout.
+	if f.Class != elf.ELFCLASS64 || DetectToolchain(f) == ToolchainGo {
+		return nil, nil
+	}
+	relocs, err := dynamicRelocs(f)
+	if err != nil {
+		return nil, fmt.Errorf("read dynamic relocations: %w", err)
+	}
+	exec := execRanges(f)
+	var data [][2]uint64
+	for _, sec := range f.Sections {
+		if sec.Flags&elf.SHF_ALLOC != 0 && sec.Flags&elf.SHF_EXECINSTR == 0 {
+			data = append(data, [2]uint64{sec.Addr, sec.Addr + sec.Size})
+		}
+	}
+	isCode := func(w dataWord) bool { return !w.symbolic && inRanges(w.value, exec) }
+	isOffsetToTop := func(w dataWord) bool {
+		off := int64(w.value)
+		return !w.symbolic && off <= 0 && off > -maxOffsetToTop
+	}
+	isTypeinfo := func(w dataWord) bool {
+		return w.symbolic || w.value == 0 || inRanges(w.value, data)
+	}
+
+	seen := make(map[uint64]struct{})
+	var candidates []FunctionCandidate
+	for _, sec := range f.Sections {
+		if sec.Type != elf.SHT_PROGBITS || sec.Flags&elf.SHF_ALLOC == 0 || sec.Flags&elf.SHF_EXECINSTR != 0 {
+			continue
+		}
+		if !strings.HasPrefix(sec.Name, ".data") && !strings.HasPrefix(sec.Name, ".rodata") {
+			continue
+		}
+		words, err := sectionWords(f, sec, relocs)
+		if err != nil {
+			return nil, err
+		}
+		for i := 2; i < len(words); {
+			if !isCode(words[i]) || !isOffsetToTop(words[i-2]) || !isTypeinfo(words[i-1]) {
+				i++
+				continue
+			}
+			// The slots run until the word that ends them, which may open
+			// the next vtable.
+			for ; i < len(words) && (words[i].symbolic || isCode(words[i])); i++ {
+				if words[i].symbolic {
+					continue
+				}
+				if _, ok := seen[words[i].value]; ok {
+					continue
+				}
+				seen[words[i].value] = struct{}{}
+				candidates = append(candidates, FunctionCandidate{
+					Address:       words[i].value,
+					DetectionType: DetectionVtable,
+					Confidence:    ConfidenceMedium,
+				})
+			}
+		}
+	}
+	slices.SortFunc(candidates, func(a, b FunctionCandidate) int {
+		return cmp.Compare(a.Address, b.Address)
+	})
+	return candidates, nil
+}
@@ -0,0 +1,139 @@
+package resurgo_test
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxgio92/resurgo"
+)
+
+func TestVtableDetector(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "vt.cc")
+	// Seven distinct virtual functions: D::f, D::g, E::g and the two
+	// destructors of D and E. Base::g is pure virtual.
+	const code = `
+struct Base { virtual ~Base() {} virtual int f(int x) { return x + 1; } virtual int g() = 0; };
+struct D : Base { int f(int x) override { return x * 3; } int g() override { return 7; } };
+struct E : D { int g() override { return 9; } };
+__attribute__((noinline)) Base *make(int k) { if (k) return new D; return new E; }
+int main(int argc, char **) { Base *b = make(argc - 1); int r = b->f(argc) + b->g(); delete b; return r; }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		flags []string
+	}{
+		{name: "pie", flags: []string{"-O2", "-pie", "-fPIE"}},
+		{name: "no-pie", flags: []string{"-O2", "-no-pie"}},
+		{name: "no-rtti", flags: []string{"-O2", "-fno-rtti"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outPath := filepath.Join(dir, tt.name)
+			cmd := exec.Command("g++", append(tt.flags, "-o", outPath, src)...)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("failed to compile vt.cc: %v\n%s", err, out)
+			}
+			f, err := elf.Open(outPath)
+			if err != nil {
+				t.Fatalf("failed to open ELF: %v", err)
+			}
+			defer f.Close()
+
+			syms, err := f.Symbols()
+			if err != nil {
+				t.Fatalf("failed to read symbols: %v", err)
+			}
+			funcs := make(map[uint64]string)
+			for _, s := range syms {
+				if elf.ST_TYPE(s.Info) == elf.STT_FUNC && s.Value != 0 {
+					funcs[s.Value] = s.Name
+				}
+			}
+
+			candidates, err := resurgo.VtableDetector(f)
+			if err != nil {
+				t.Fatalf("resurgo.VtableDetector: %v", err)
+			}
+			if len(candidates) != 7 {
+				t.Errorf("expected 7 candidates, got %d: %+v", len(candidates), candidates)
+			}
+			for _, c := range candidates {
+				if _, ok := funcs[c.Address]; !ok {
+					t.Errorf("candidate 0x%x is not a function entry", c.Address)
+				}
+				if c.DetectionType != resurgo.DetectionVtable || c.Confidence != resurgo.ConfidenceMedium {
+					t.Errorf("candidate 0x%x: unexpected %s/%s", c.Address, c.DetectionType, c.Confidence)
+				}
+			}
+		})
+	}
+}
+
+func TestVtableDetector_Adjacent(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "vt.c")
+	// Two vtables laid out back to back, without RTTI: the second starts
+	// right after the last slot of the first.
+	const code = `
+__attribute__((noinline)) int f1(void) { return 1; }
+__attribute__((noinline)) int f2(void) { return 2; }
+__attribute__((noinline)) int f3(void) { return 3; }
+__attribute__((used)) static int (*const vtables[])(void) = { 0, 0, f1, f2, 0, 0, f3 };
+int main(void) { return 0; }
+`
+	if err := os.WriteFile(src, []byte(code), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	outPath := filepath.Join(dir, "vt")
+	if out, err := exec.Command("gcc", "-O2", "-no-pie", "-o", outPath, src).CombinedOutput(); err != nil {
+		t.Fatalf("failed to compile vt.c: %v\n%s", err, out)
+	}
+	f, err := elf.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open ELF: %v", err)
+	}
+	defer f.Close()
+
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("failed to read symbols: %v", err)
+	}
+	candidates, err := resurgo.VtableDetector(f)
+	if err != nil {
+		t.Fatalf("resurgo.VtableDetector: %v", err)
+	}
+	found := make(map[uint64]bool)
+	for _, c := range candidates {
+		found[c.Address] = true
+	}
+	slots := 0
+	for _, s := range syms {
+		switch s.Name {
+		case "f1", "f2", "f3":
+			slots++
+			if !found[s.Value] {
+				t.Errorf("slot %s at 0x%x not detected", s.Name, s.Value)
+			}
+		}
+	}
+	if slots != 3 {
+		t.Fatalf("expected 3 slot functions in the symbol table, got %d", slots)
+	}
+}
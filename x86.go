@@ -0,0 +1,190 @@
+package resurgo
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/arch/x86/x86asm"
+)
+
+// detectProloguesX86 matches i386 function prologues. Code is decoded in
+// 32-bit mode; the patterns are the i386 forms of their x86-64 counterparts:
+// push ebp; mov ebp, esp, and sub esp, imm or callee-saved pushes at a
+// function boundary. Callee-saved registers follow the System V i386 ABI.
+func (o *options) detectProloguesX86(code []byte, baseAddr uint64) ([]Prologue, error) {
+	var result []Prologue
+
+	offset := 0
+	addr := baseAddr
+	var prevInsn *x86asm.Inst
+	// prevAddr is the address of prevInsn; tolerated is the number of benign
+	// instructions skipped since prevInsn.
+	var prevAddr uint64
+	tolerated := 0
+	// consumedFrom and consumedUntil delimit the offsets of the last
+	// collapsed entry sequence. Patterns matching inside it are fragments
+	// of that sequence; a frame setup may still open it.
+	consumedFrom, consumedUntil := 0, 0
+	hist := newInsnHistory(o.lookbehind())
+	atBoundary := func(typ PrologueType) bool {
+		return o.atBoundary(hist, addr, typ)
+	}
+
+	for offset < len(code) {
+		if err := o.budget.step(offset); err != nil {
+			return nil, err
+		}
+		// Skip ENDBR32, emitted at function entries with -fcf-protection.
+		if isENDBR(code, offset) {
+			offset += 4
+			addr += 4
+			continue
+		}
+
+		inst, err := x86asm.Decode(code[offset:], 32)
+		if err != nil {
+			hist.push(addr, InsnClassNone)
+			offset++
+			addr++
+			prevInsn = nil
+			continue
+		}
+
+		if prevInsn != nil && isNOPLike(inst) && tolerated < o.patternTolerance {
+			tolerated++
+			offset += inst.Len
+			addr += uint64(inst.Len)
+			continue
+		}
+		tolerated = 0
+
+		// Pattern 1: Classic frame pointer setup - push ebp; mov ebp, esp,
+		// extended with the pushes and stack allocation that follow it.
+		prevOffset := int(prevAddr - baseAddr)
+		if prevInsn != nil && (prevOffset >= consumedUntil || prevOffset == consumedFrom) &&
+			prevInsn.Op == x86asm.PUSH && prevInsn.Args[0] == x86asm.EBP &&
+			inst.Op == x86asm.MOV && inst.Args[0] == x86asm.EBP && inst.Args[1] == x86asm.ESP {
+			result = dropSubsumedPushOnly(result, prevAddr)
+			seq := scanEntryX86(code, prevOffset)
+			result = append(result, Prologue{
+				Address:      prevAddr,
+				Type:         PrologueClassic,
+				Instructions: strings.Join(seq.insns, "; "),
+				FrameSize:    seq.frame,
+				Size:         uint64(seq.end - prevOffset),
+				SavedRegs:    seq.savedRegs,
+			})
+			consumedFrom, consumedUntil = prevOffset, seq.end
+		}
+
+		// Pattern 2: No-frame-pointer function - sub esp, imm
+		if size, ok := stackAllocX86(inst); ok && inst.Op == x86asm.SUB && offset >= consumedUntil {
+			if atBoundary(PrologueNoFramePointer) {
+				result = append(result, Prologue{
+					Address:      addr,
+					Type:         PrologueNoFramePointer,
+					Instructions: fmt.Sprintf("sub esp, 0x%x", size),
+					FrameSize:    size,
+					Size:         uint64(inst.Len),
+				})
+			}
+		}
+
+		// Pattern 3: Push callee-saved register at function boundary, with
+		// the run of pushes and the stack allocation that closes it.
+		if inst.Op == x86asm.PUSH && offset >= consumedUntil {
+			if reg, ok := inst.Args[0].(x86asm.Reg); ok && isCalleeSavedX86(reg) {
+				if atBoundary(ProloguePushOnly) {
+					seq := scanEntryX86(code, offset)
+					result = append(result, Prologue{
+						Address:      addr,
+						Type:         ProloguePushOnly,
+						Instructions: strings.Join(seq.insns, "; "),
+						FrameSize:    seq.frame,
+						Size:         uint64(seq.end - offset),
+						SavedRegs:    seq.savedRegs,
+					})
+					consumedFrom, consumedUntil = offset, seq.end
+				}
+			}
+		}
+
+		hist.push(addr, classifyAMD64(inst))
+		prevInsn = &inst
+		prevAddr = addr
+		offset += inst.Len
+		addr += uint64(inst.Len)
+	}
+
+	return result, nil
+}
+
+// scanEntryX86 collects the i386 entry sequence starting at code[offset]: a
+// run of callee-saved pushes in which mov ebp, esp may appear, optionally
+// closed by sub esp, imm or lea esp, [esp-imm]. Each push allocates 4 bytes.
+func scanEntryX86(code []byte, offset int) entrySeqAMD64 {
+	seq := entrySeqAMD64{end: offset}
+	for n := 0; n < maxSavedRegScan && offset < len(code); n++ {
+		if isENDBR(code, offset) {
+			offset += 4
+			continue
+		}
+		inst, err := x86asm.Decode(code[offset:], 32)
+		if err != nil {
+			break
+		}
+		offset += inst.Len
+		switch {
+		case inst.Op == x86asm.PUSH:
+			reg, ok := inst.Args[0].(x86asm.Reg)
+			if !ok || !isCalleeSavedX86(reg) {
+				return seq
+			}
+			name := strings.ToLower(reg.String())
+			seq.insns = append(seq.insns, "push "+name)
+			seq.savedRegs = append(seq.savedRegs, name)
+			seq.frame += 4
+		case inst.Op == x86asm.MOV && inst.Args[0] == x86asm.EBP && inst.Args[1] == x86asm.ESP:
+			seq.insns = append(seq.insns, "mov ebp, esp")
+		case isNOPLike(inst):
+			continue
+		default:
+			if size, ok := stackAllocX86(inst); ok {
+				seq.insns = append(seq.insns, fmt.Sprintf("%s esp, 0x%x", strings.ToLower(inst.Op.String()), size))
+				seq.frame += size
+				seq.end = offset
+			}
+			return seq
+		}
+		seq.end = offset
+	}
+	return seq
+}
+
+// stackAllocX86 returns the number of bytes allocated by sub esp, imm or
+// lea esp, [esp-imm].
+func stackAllocX86(inst x86asm.Inst) (uint64, bool) {
+	switch {
+	case inst.Op == x86asm.SUB && inst.Args[0] == x86asm.ESP:
+		if imm, ok := inst.Args[1].(x86asm.Imm); ok && imm > 0 {
+			return uint64(imm), true
+		}
+	case inst.Op == x86asm.LEA && inst.Args[0] == x86asm.ESP:
+		if mem, ok := inst.Args[1].(x86asm.Mem); ok && mem.Base == x86asm.ESP {
+			if disp := int64(int32(mem.Disp)); disp < 0 {
+				return uint64(-disp), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// isCalleeSavedX86 reports whether reg is callee-saved under the System V
+// i386 ABI.
+func isCalleeSavedX86(reg x86asm.Reg) bool {
+	switch reg {
+	case x86asm.EBX, x86asm.EBP, x86asm.ESI, x86asm.EDI:
+		return true
+	}
+	return false
+}
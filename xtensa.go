@@ -0,0 +1,337 @@
+package resurgo
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// golang.org/x/arch has no Xtensa decoder, so the instructions the detectors
+// rely on are matched on their raw little-endian encodings. Field names
+// follow the Xtensa ISA reference: op0 in bits 3:0, t in 7:4, s in 11:8 and
+// r in 15:12.
+
+// xtensaInsn is an Xtensa instruction: a 24-bit one, or a 16-bit one from
+// the Code Density option.
+type xtensaInsn struct {
+	enc uint32
+	len int
+}
+
+// Registers of the Xtensa ABIs: a1 is the stack pointer under both the
+// windowed and the call0 ABI, and a0 holds the return address.
+const (
+	xtensaSP = 1
+	xtensaRA = 0
+)
+
+// decodeXtensa returns the Xtensa instruction at code[offset]. Its length is
+// read from op0, so the sweep stays aligned across unknown instructions. ok
+// is false when the instruction is truncated by the end of code.
+func decodeXtensa(code []byte, offset int) (in xtensaInsn, ok bool) {
+	in.len = 3
+	if op0 := code[offset] & 0xf; op0 >= 8 && op0 <= 13 {
+		in.len = 2
+	}
+	if offset+in.len > len(code) {
+		return in, false
+	}
+	for i := range in.len {
+		in.enc |= uint32(code[offset+i]) << (8 * i)
+	}
+	return in, true
+}
+
+func (in xtensaInsn) narrow() bool { return in.len == 2 }
+func (in xtensaInsn) op0() uint32  { return in.enc & 0xf }
+func (in xtensaInsn) t() uint32    { return in.enc >> 4 & 0xf }
+func (in xtensaInsn) s() uint32    { return in.enc >> 8 & 0xf }
+func (in xtensaInsn) r() uint32    { return in.enc >> 12 & 0xf }
+
+// imm8 returns the signed 8-bit immediate in bits 23:16 of the RRI8 format.
+func (in xtensaInsn) imm8() int64 { return int64(int8(in.enc >> 16)) }
+
+// entry returns the frame size of entry a1, N, the windowed ABI prologue
+// that rotates the register window and allocates the frame. N is the
+// 12-bit immediate times 8.
+func (in xtensaInsn) entry() (uint64, bool) {
+	if in.narrow() || in.enc&0xff != 0x36 || in.s() != xtensaSP {
+		return 0, false
+	}
+	return uint64(in.enc>>12) * 8, true
+}
+
+// spAlloc returns N for addi a1, a1, -N and addmi a1, a1, -N, the call0
+// ABI stack allocation.
+func (in xtensaInsn) spAlloc() (uint64, bool) {
+	if in.narrow() || in.op0() != 2 || in.s() != xtensaSP || in.t() != xtensaSP {
+		return 0, false
+	}
+	var imm int64
+	switch in.r() {
+	case 0xc: // addi
+		imm = in.imm8()
+	case 0xd: // addmi
+		imm = in.imm8() << 8
+	}
+	if imm >= 0 {
+		return 0, false
+	}
+	return uint64(-imm), true
+}
+
+// spStore returns the register and offset stored by s32i reg, a1, off and
+// s32i.n reg, a1, off.
+func (in xtensaInsn) spStore() (reg, off uint32, ok bool) {
+	switch {
+	case in.narrow() && in.op0() == 9 && in.s() == xtensaSP:
+		return in.t(), in.r() * 4, true
+	case !in.narrow() && in.op0() == 2 && in.r() == 6 && in.s() == xtensaSP:
+		return in.t(), (in.enc >> 16) * 4, true
+	}
+	return 0, 0, false
+}
+
+// isFramePointerSetup reports whether in is mov.n a15, a1 or its 24-bit
+// form or a15, a1, a1.
+func (in xtensaInsn) isFramePointerSetup() bool {
+	return in.enc == 0x01fd && in.narrow() || in.enc == 0x20f110 && !in.narrow()
+}
+
+// isBenign reports whether in is nop or nop.n.
+func (in xtensaInsn) isBenign() bool {
+	return in.enc == 0x0020f0 && !in.narrow() || in.enc == 0xf03d && in.narrow()
+}
+
+// isBranch reports whether in transfers control: a call, jump, return or
+// conditional branch.
+func (in xtensaInsn) isBranch() bool {
+	switch {
+	case in.narrow():
+		return in.op0() == 12 && in.t()&8 != 0 || in.enc&0xffef == 0xf00d // beqz.n, bnez.n, ret.n, retw.n
+	case in.op0() == 5, in.op0() == 7: // call0-12, b<cond>
+		return true
+	case in.op0() == 6:
+		// j and the compare-with-zero and -immediate branches; entry and the
+		// loops set up no transfer.
+		return in.enc&0xff != 0x36 && !(in.enc&0xff == 0x76 && in.r() >= 8)
+	case in.enc&0xfff00f == 0: // ret, retw, jx, callx0-12
+		return in.t() >= 8
+	}
+	return false
+}
+
+// isCalleeSavedXtensa reports whether a register is callee-saved under the
+// call0 ABI: a12-a15.
+func isCalleeSavedXtensa(reg uint32) bool {
+	return reg >= 12 && reg <= 15
+}
+
+// scanEntryXtensa collects the register saves and frame pointer setup that
+// follow the call0 stack allocation alloc at code[offset], like
+// scanEntryRISCV64: a0 and a12-a15 stored to the new frame and the frame
+// pointer a15 set up. Unrelated instructions are skipped; a control transfer
+// or another stack pointer update ends the sequence.
+func scanEntryXtensa(code []byte, offset int, alloc xtensaInsn, frame uint64) entrySeqRISCV64 {
+	op := "addi"
+	if alloc.r() == 0xd {
+		op = "addmi"
+	}
+	seq := entrySeqRISCV64{insns: []string{fmt.Sprintf("%s a1, a1, -%d", op, frame)}, end: offset + alloc.len}
+	for n, off := 0, seq.end; n < maxSavedRegScan && off+2 <= len(code); n++ {
+		in, ok := decodeXtensa(code, off)
+		if !ok {
+			break
+		}
+		off += in.len
+		if reg, ofs, ok := in.spStore(); ok && (reg == xtensaRA || isCalleeSavedXtensa(reg)) {
+			mnemonic := "s32i"
+			if in.narrow() {
+				mnemonic = "s32i.n"
+			}
+			name := fmt.Sprintf("a%d", reg)
+			seq.insns = append(seq.insns, fmt.Sprintf("%s %s, a1, %d", mnemonic, name, ofs))
+			seq.savedRegs = append(seq.savedRegs, name)
+			seq.savesRA = seq.savesRA || reg == xtensaRA
+			seq.end = off
+			continue
+		}
+		if in.isFramePointerSetup() {
+			seq.insns = append(seq.insns, "mov.n a15, a1")
+			seq.setsFP = true
+			seq.end = off
+			continue
+		}
+		if _, ok := in.spAlloc(); ok || in.isBranch() || classifyXtensa(in) != InsnClassOther {
+			break
+		}
+	}
+	return seq
+}
+
+// detectProloguesXtensa matches Xtensa function prologues of both ABIs:
+//
+//	entry a1, N              entry (windowed ABI)
+//	addi  a1, a1, -N         addi-sp-frame, with a0 saved and mov.n a15, a1
+//	s32i  a0, a1, N-4        addi-sp-save-ra, with a0 saved
+//	                         addi-sp, alone at a function boundary
+//
+// The call0 patterns are the RISC-V ones, a1 standing for sp and a0 for ra.
+func (o *options) detectProloguesXtensa(code []byte, baseAddr uint64) ([]Prologue, error) {
+	var result []Prologue
+	hist := newInsnHistory(o.lookbehind())
+
+	for offset := 0; offset+2 <= len(code); {
+		if err := o.budget.step(offset); err != nil {
+			return nil, err
+		}
+		addr := baseAddr + uint64(offset)
+		in, ok := decodeXtensa(code, offset)
+		if !ok {
+			break
+		}
+
+		// Pattern 1: entry a1, N. Only windowed ABI functions called with
+		// call4, call8 or call12 start with it, so it is reported anywhere.
+		if frame, ok := in.entry(); ok {
+			result = append(result, Prologue{
+				Address:      addr,
+				Type:         PrologueEntry,
+				Instructions: fmt.Sprintf("entry a1, %d", frame),
+				FrameSize:    frame,
+				Size:         uint64(in.len),
+			})
+		}
+
+		// Pattern 2: addi a1, a1, -N, graded by the saves and frame pointer
+		// setup that follow it.
+		if frame, ok := in.spAlloc(); ok {
+			seq := scanEntryXtensa(code, offset, in, frame)
+			p := Prologue{
+				Address:      addr,
+				Instructions: strings.Join(seq.insns, "; "),
+				FrameSize:    frame,
+				Size:         uint64(seq.end - offset),
+				SavedRegs:    seq.savedRegs,
+			}
+			switch {
+			case seq.savesRA && seq.setsFP:
+				p.Type = PrologueAddiSPFrame
+			case seq.savesRA:
+				p.Type = PrologueAddiSPSaveRA
+			case o.atBoundary(hist, addr, PrologueAddiSP):
+				p.Type = PrologueAddiSP
+				p.Instructions = seq.insns[0]
+				p.Size = uint64(in.len)
+				p.SavedRegs = nil
+			}
+			if p.Type != "" {
+				result = append(result, p)
+			}
+		}
+
+		hist.push(addr, classifyXtensa(in))
+		offset += in.len
+	}
+
+	return result, nil
+}
+
+// classifyXtensa returns the boundary-context class of an Xtensa
+// instruction. Zeroed bytes decode as ill and count as traps.
+func classifyXtensa(in xtensaInsn) InsnClass {
+	if in.narrow() {
+		switch {
+		case in.enc == 0xf00d, in.enc == 0xf01d: // ret.n, retw.n
+			return InsnClassReturn
+		case in.enc == 0xf06d, in.enc&0xf0ff == 0xf02d: // ill.n, break.n
+			return InsnClassTrap
+		}
+	} else {
+		switch {
+		case in.enc == 0x000080, in.enc == 0x000090: // ret, retw
+			return InsnClassReturn
+		case in.enc&0xfff0ff == 0x0000a0, in.enc&0x3f == 0x06: // jx, j
+			return InsnClassJump
+		case in.enc == 0, in.enc&0xfff00f == 0x004000: // ill, break
+			return InsnClassTrap
+		}
+	}
+	if in.isBenign() {
+		return InsnClassPadding
+	}
+	return InsnClassOther
+}
+
+// xtensaSignExtend returns the low n bits of v as a signed value.
+func xtensaSignExtend(v uint32, n int) int64 {
+	return int64(int32(v<<(32-n)) >> (32 - n))
+}
+
+// detectCallSitesXtensa scans Xtensa code for call0, call4, call8, call12,
+// j and the conditional branches. Targets are relative to the address of the
+// instruction plus 4; call targets are word-aligned, the offset counting
+// words from the instruction address rounded down to a word. Targets wrap
+// around the 32-bit address space. The loop instructions, whose target is
+// the end of the loop body, are not reported.
+func detectCallSitesXtensa(code []byte, baseAddr uint64, wrap bool, b *budget) ([]CallSiteEdge, error) {
+	var result []CallSiteEdge
+
+	for offset := 0; offset+2 <= len(code); {
+		if err := b.step(offset); err != nil {
+			return nil, err
+		}
+		in, ok := decodeXtensa(code, offset)
+		if !ok {
+			break
+		}
+		addr := baseAddr + uint64(offset)
+		offset += in.len
+
+		pc := addr + 4
+		edge := CallSiteEdge{
+			SourceAddr:  addr,
+			Type:        CallSiteJump,
+			AddressMode: AddressingModePCRelative,
+			Confidence:  ConfidenceLow,
+		}
+		var disp int64
+		enc := in.enc
+		switch {
+		case in.narrow():
+			if in.op0() != 12 || in.t()&8 == 0 { // beqz.n, bnez.n
+				continue
+			}
+			disp = int64(enc>>4&3<<4 | in.r())
+		case in.op0() == 5: // call0, call4, call8, call12
+			edge.Type, edge.Confidence = CallSiteCall, ConfidenceHigh
+			pc = addr&^3 + 4
+			disp = xtensaSignExtend(enc>>6, 18) << 2
+		case enc&0x3f == 0x06: // j
+			edge.Confidence = ConfidenceMedium
+			disp = xtensaSignExtend(enc>>6, 18)
+		case enc&0x3f == 0x16: // beqz, bnez, bltz, bgez
+			disp = xtensaSignExtend(enc>>12, 12)
+		case enc&0x3f == 0x26, enc&0xff == 0xb6, enc&0xff == 0xf6: // beqi, bnei, blti, bgei, bltui, bgeui
+			disp = in.imm8()
+		case enc&0xff == 0x76 && in.r() < 2: // bf, bt
+			disp = in.imm8()
+		case in.op0() == 7: // beq, bne, bbc, bbs and the other register branches
+			disp = in.imm8()
+		default:
+			continue
+		}
+		target, ok := relTarget(pc, disp, wrap)
+		if target > math.MaxUint32 {
+			ok = wrap
+			target &= math.MaxUint32
+		}
+		if !ok {
+			continue
+		}
+		edge.TargetAddr = target
+		result = append(result, edge)
+	}
+
+	return result, nil
+}